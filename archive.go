@@ -0,0 +1,153 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+const (
+	// archivePrefix is where notification records live in ARCHIVE_BUCKET,
+	// grouped by job. Retention is the bucket's lifecycle rule, set by
+	// Bootstrap from --archive-days.
+	archivePrefix = "archive/"
+	// archiveTimeout bounds each archive write so a slow bucket cannot hold
+	// up the rest of the alert.
+	archiveTimeout = 5 * time.Second
+)
+
+// NotificationRecord is what was sent on one channel for one alert, and why.
+type NotificationRecord struct {
+	Job          string    `json:"job"`
+	Channel      string    `json:"channel"`
+	Recipient    string    `json:"recipient"`
+	At           time.Time `json:"at"`
+	ScannedAt    time.Time `json:"scanned_at"`
+	CampgroundID string    `json:"campground_id"`
+	Arrival      time.Time `json:"arrival"`
+	Departure    time.Time `json:"departure"`
+	Sites        []string  `json:"sites"`
+	Primary      string    `json:"primary,omitempty"`
+	Partial      bool      `json:"partial,omitempty"`
+	Rehearsal    bool      `json:"rehearsal,omitempty"`
+	SubjectHash  string    `json:"subject_sha256"`
+	BodyHash     string    `json:"body_sha256"`
+	// Body is the rendered plain-text body, kept only when ARCHIVE_BODIES is
+	// "true".
+	Body string `json:"body,omitempty"`
+	// Outcome is "sent", "skipped: ..." or "failed: ...".
+	Outcome string `json:"outcome"`
+}
+
+// archiveDir is the prefix of job's records in ARCHIVE_BUCKET. Alerts carry
+// the full resource name and the CLI may pass a bare ID, so both are keyed
+// on the job ID alone.
+func archiveDir(job string) string {
+	if name, err := ParseJobName(job); err == nil {
+		job = name.Job
+	}
+	return archivePrefix + job + "/"
+}
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// archiveNotification stores a record of one delivery attempt in
+// ARCHIVE_BUCKET. It never returns an error: archiving is best effort and
+// failures are only logged.
+func archiveNotification(ctx context.Context, client *storage.Client, a alert, channel string, recipient string,
+	subject string, plain string, outcome string) {
+	bucket := os.Getenv("ARCHIVE_BUCKET")
+	if bucket == "" || client == nil {
+		return
+	}
+	record := NotificationRecord{
+		Job:          a.JobName,
+		Channel:      channel,
+		Recipient:    recipient,
+		At:           DefaultScraper.now().UTC(),
+		ScannedAt:    a.ScannedAt,
+		CampgroundID: a.CampgroundID,
+		Arrival:      a.Arrival.Time(),
+		Departure:    a.Departure.Time(),
+		Sites:        a.Sites,
+		Primary:      a.Primary,
+		Partial:      a.Partial != nil,
+		Rehearsal:    a.Rehearsal,
+		SubjectHash:  sha256Hex(subject),
+		BodyHash:     sha256Hex(plain),
+		Outcome:      outcome,
+	}
+	if os.Getenv("ARCHIVE_BODIES") == "true" {
+		record.Body = plain
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+	object := fmt.Sprintf("%s%s-%s.json", archiveDir(a.JobName), record.At.Format("20060102T150405.000000000Z"), channel)
+	w := client.Bucket(bucket).Object(object).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(record); err != nil {
+		w.Close()
+		logger.Println("archiving notification:", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		logger.Println("archiving notification:", err)
+	}
+}
+
+// GetNotificationArchive returns the archived notifications for a watch
+// recorded since the given time, oldest first.
+func GetNotificationArchive(ctx context.Context, watchName string, since time.Time) ([]NotificationRecord, error) {
+	bucket := os.Getenv("ARCHIVE_BUCKET")
+	if bucket == "" {
+		return nil, fmt.Errorf("ARCHIVE_BUCKET is not set")
+	}
+	if _, err := ParseJobName(watchName); err != nil {
+		return nil, err
+	}
+	client, err := storageClient()
+	if err != nil {
+		return nil, err
+	}
+
+	records := []NotificationRecord{}
+	it := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: archiveDir(watchName)})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return records, err
+		}
+		if attrs.Created.Before(since) || !strings.HasSuffix(attrs.Name, ".json") {
+			continue
+		}
+		r, err := client.Bucket(bucket).Object(attrs.Name).NewReader(ctx)
+		if err != nil {
+			return records, err
+		}
+		var record NotificationRecord
+		err = json.NewDecoder(r).Decode(&record)
+		r.Close()
+		if err != nil {
+			return records, fmt.Errorf("%s: %v", attrs.Name, err)
+		}
+		records = append(records, record)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].At.Before(records[j].At) })
+	return records, nil
+}
@@ -0,0 +1,41 @@
+package scraper
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// Alerts carry a watch's full resource name, while the CLI, diagnostics and
+// summaries look a watch up by whatever name they were given; both must land
+// on the same records.
+func TestArchiveRoundTrip(t *testing.T) {
+	useFakeGCS(t, "results")
+	t.Setenv("ARCHIVE_BUCKET", "results")
+	useClock(t, time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	sent := []alert{}
+	old := notifiersFor
+	notifiersFor = func(a alert) []notifier { return []notifier{recordingNotifier{"camper@example.com", &sent}} }
+	defer func() { notifiersFor = old }()
+	ctx := context.Background()
+
+	job := "projects/camp-finder/locations/us-central1/jobs/camp-232447-2027-07-14"
+	a := alert{JobName: job, CampgroundID: "232447", CampgroundName: "Upper Pines",
+		Arrival: core.CivilDate{Year: 2027, Month: 7, Day: 14}, Departure: core.CivilDate{Year: 2027, Month: 7, Day: 16},
+		Sites: []string{"1001"}}
+	if err := sendAlert(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{job, "camp-232447-2027-07-14"} {
+		records, err := GetNotificationArchive(ctx, name, time.Time{})
+		if err != nil {
+			t.Fatalf("GetNotificationArchive(%q): %v", name, err)
+		}
+		if len(records) != 1 || records[0].Job != job || records[0].Channel != "recording" || records[0].Outcome != "sent" {
+			t.Errorf("GetNotificationArchive(%q) = %+v, want the one sent record", name, records)
+		}
+	}
+}
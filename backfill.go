@@ -0,0 +1,266 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// backfillPrefix is where each campground's backfilled months are kept in
+// RESULTS_BUCKET.
+const backfillPrefix = "backfill/"
+
+// DefaultBackfillInterval is the gap Backfill leaves between requests
+// unless told otherwise, far wider than a watch's, as it fetches many
+// months in one go.
+const DefaultBackfillInterval = 5 * time.Second
+
+// BackfillStore keeps, per campground, every month Backfill has finished,
+// so an interrupted backfill picks up where it stopped and a wider one
+// fetches only the months it has not done.
+type BackfillStore interface {
+	// BackfilledMonths returns the months finished, such as "2027-03",
+	// in no particular order.
+	BackfilledMonths(ctx context.Context, campgroundID string) ([]string, error)
+	AddBackfilledMonth(ctx context.Context, campgroundID string, month string) error
+}
+
+// BackfilledMonths implements BackfillStore.
+func (s *MemoryStore) BackfilledMonths(ctx context.Context, campgroundID string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.backfill[campgroundID]...), nil
+}
+
+// AddBackfilledMonth implements BackfillStore.
+func (s *MemoryStore) AddBackfilledMonth(ctx context.Context, campgroundID string, month string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.backfill == nil {
+		s.backfill = map[string][]string{}
+	}
+	s.backfill[campgroundID] = addMonth(s.backfill[campgroundID], month)
+	return nil
+}
+
+// BackfilledMonths implements BackfillStore. The object lists one month
+// per line; one written when only the latest month was kept reads as that
+// month alone, and the months before it are fetched again without writing
+// any night twice.
+func (s BucketStore) BackfilledMonths(ctx context.Context, campgroundID string) ([]string, error) {
+	client, err := storageClient()
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Bucket(s.Bucket).Object(backfillPrefix + campgroundID).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	return strings.Fields(string(data)), err
+}
+
+// AddBackfilledMonth implements BackfillStore. Backfills of a campground
+// are run one at a time from the CLI, so it reads and rewrites the list
+// without a precondition.
+func (s BucketStore) AddBackfilledMonth(ctx context.Context, campgroundID string, month string) error {
+	months, err := s.BackfilledMonths(ctx, campgroundID)
+	if err != nil {
+		return err
+	}
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	w := client.Bucket(s.Bucket).Object(backfillPrefix + campgroundID).NewWriter(ctx)
+	w.ContentType = "text/plain"
+	if _, err := w.Write([]byte(strings.Join(addMonth(months, month), "\n") + "\n")); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// addMonth adds month to the sorted set months.
+func addMonth(months []string, month string) []string {
+	i := sort.SearchStrings(months, month)
+	if i < len(months) && months[i] == month {
+		return months
+	}
+	months = append(months, "")
+	copy(months[i+1:], months[i:])
+	months[i] = month
+	return months
+}
+
+// backfillStore is where backfilled months are kept: RESULTS_BUCKET when
+// set, memory otherwise, as for scanStore. Replace it in tests.
+var backfillStore BackfillStore = defaultBackfillStore()
+
+func defaultBackfillStore() BackfillStore {
+	if bucket := os.Getenv("RESULTS_BUCKET"); bucket != "" {
+		return BucketStore{Bucket: bucket}
+	}
+	return &MemoryStore{}
+}
+
+// BackfillOptions says what Backfill fetches.
+type BackfillOptions struct {
+	CampgroundID string
+	// Months is how many whole months before the current one to fetch.
+	Months int
+	// Interval is the least time between requests, DefaultBackfillInterval
+	// when zero.
+	Interval time.Duration
+	// Restart ignores the months stored as done and fetches every month
+	// again. Nights already backfilled are not written twice.
+	Restart bool
+}
+
+// BackfillResult is what Backfill did.
+type BackfillResult struct {
+	// Done lists the months fetched, oldest first, and Skipped those
+	// already done by an earlier backfill.
+	Done    []string
+	Skipped []string
+	// Rows counts the rows written and Duplicates the nights left out
+	// because an earlier backfill wrote them.
+	Rows       int
+	Duplicates int
+}
+
+// Backfill seeds s.History with past months of campgroundID, which
+// recreation.gov still serves with each night's final status, so odds and
+// other analysis have something to go on before watches have run for
+// long. Rows are marked Backfilled. Months are fetched oldest first, no
+// faster than opts.Interval, and each is recorded in backfillStore as it is
+// written, so after a failure running again carries on from the month that
+// failed, and running with more Months fetches only the earlier ones. s.History's Sink must be a HistoryReader, which
+// keeps a night from being written twice. In s.ReplayDir, the months are
+// read from there instead.
+func (s *Scraper) Backfill(ctx context.Context, opts BackfillOptions) (BackfillResult, error) {
+	result := BackfillResult{}
+	if opts.CampgroundID == "" {
+		return result, &ValidationError{Field: "CampgroundID", Reason: "is required"}
+	}
+	if opts.Months < 1 {
+		return result, &ValidationError{Field: "Months", Value: fmt.Sprint(opts.Months), Reason: "must be at least 1"}
+	}
+	if s.History == nil {
+		return result, ErrNoHistory
+	}
+	reader, ok := s.History.Sink.(HistoryReader)
+	if !ok {
+		return result, ErrNoHistory
+	}
+	done := map[string]bool{}
+	if !opts.Restart {
+		months, err := backfillStore.BackfilledMonths(ctx, opts.CampgroundID)
+		if err != nil {
+			return result, fmt.Errorf("reading the backfilled months: %v", err)
+		}
+		for _, month := range months {
+			done[month] = true
+		}
+	}
+	provider := s.backfillProvider(opts.Interval)
+	now := s.now().UTC()
+	current := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	for month := current.AddDate(0, -opts.Months, 0); month.Before(current); month = month.AddDate(0, 1, 0) {
+		label := month.Format("2006-01")
+		if done[label] {
+			result.Skipped = append(result.Skipped, label)
+			continue
+		}
+		rows, duplicates, err := backfillMonth(ctx, provider, reader, opts.CampgroundID, month, now)
+		if err != nil {
+			return result, fmt.Errorf("backfilling %s: %w", label, err)
+		}
+		if err := s.insertBackfill(ctx, rows); err != nil {
+			return result, fmt.Errorf("backfilling %s: %v", label, err)
+		}
+		if err := backfillStore.AddBackfilledMonth(ctx, opts.CampgroundID, label); err != nil {
+			return result, fmt.Errorf("recording %s as backfilled: %v", label, err)
+		}
+		result.Done = append(result.Done, label)
+		result.Rows += len(rows)
+		result.Duplicates += duplicates
+	}
+	return result, nil
+}
+
+// backfillProvider is s's provider for Backfill: s.ReplayDir, or
+// recreation.gov spaced interval apart, uncached as no watch wants the
+// months again.
+func (s *Scraper) backfillProvider(interval time.Duration) core.Provider {
+	if s.ReplayDir != "" {
+		return core.SnapshotDir{Dir: s.ReplayDir}
+	}
+	if interval <= 0 {
+		interval = DefaultBackfillInterval
+	}
+	provider := s.Provider()
+	provider.Pacer = &core.Pacer{Interval: interval, Cooldown: core.DefaultBlockedCooldown, Clock: scraperClock{s}}
+	return provider
+}
+
+// backfillMonth returns the rows for month's nights, stamped now, leaving
+// out and counting those already backfilled.
+func backfillMonth(ctx context.Context, provider core.Provider, reader HistoryReader, campgroundID string, month time.Time, now time.Time) ([]HistoryRow, int, error) {
+	existing, err := reader.ReadHistory(ctx, campgroundID, month, month.AddDate(0, 1, 0))
+	if err != nil {
+		return nil, 0, fmt.Errorf("reading history: %v", err)
+	}
+	done := map[string]bool{}
+	for _, row := range existing {
+		if row.Backfilled {
+			done[row.CampsiteID+"/"+row.Night] = true
+		}
+	}
+	log := &historyLog{}
+	if _, err := (historyProvider{provider}).FetchMonth(withHistory(ctx, log), campgroundID, month); err != nil {
+		return nil, 0, err
+	}
+	rows, duplicates := []HistoryRow{}, 0
+	for _, row := range log.take("backfill") {
+		if done[row.CampsiteID+"/"+row.Night] {
+			duplicates++
+			continue
+		}
+		row.ScrapedAt, row.Backfilled = now, true
+		rows = append(rows, row)
+	}
+	return rows, duplicates, nil
+}
+
+// insertBackfill writes rows to s.History's Sink DefaultHistoryBatch at a
+// time. Unlike a batcher it fails on an error, so the month is retried.
+func (s *Scraper) insertBackfill(ctx context.Context, rows []HistoryRow) error {
+	for len(rows) > 0 {
+		n := DefaultHistoryBatch
+		if n > len(rows) {
+			n = len(rows)
+		}
+		if err := s.History.Sink.Insert(ctx, rows[:n]); err != nil {
+			return err
+		}
+		rows = rows[n:]
+	}
+	return nil
+}
+
+// Backfill is Scraper.Backfill using DefaultScraper.
+func Backfill(ctx context.Context, opts BackfillOptions) (BackfillResult, error) {
+	return DefaultScraper.Backfill(ctx, opts)
+}
@@ -0,0 +1,200 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// backfillNow is the time Backfill runs at: the fixtures in
+// testdata/backfill are the three months before it.
+var backfillNow = time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)
+
+// backfillScraper reads past months from a copy of the fixtures for months
+// and writes history to sink. The copy's directory is returned so a test
+// can add months later.
+func backfillScraper(t *testing.T, sink *scraper.MemorySink, months ...string) (*scraper.Scraper, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "232447"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, month := range months {
+		copyBackfillFixture(t, dir, month)
+	}
+	s := scraper.NewScraper(nil, core.RetryPolicy{})
+	s.ReplayDir, s.Clock = dir, fakerecgov.NewClock(backfillNow)
+	if sink != nil {
+		s.History = &scraper.HistoryBatcher{Sink: sink}
+	}
+	t.Cleanup(scraper.UseServices(scraper.Services{Backfills: &scraper.MemoryStore{}}))
+	return s, dir
+}
+
+func copyBackfillFixture(t *testing.T, dir string, month string) {
+	t.Helper()
+	data, err := ioutil.ReadFile(filepath.Join("testdata", "backfill", "232447", month+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "232447", month+".json"), data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Past months come back with every night's final status, often all
+// "Closed" or "Reserved"; each is written once, marked as backfilled.
+func TestBackfill(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    scraper.BackfillOptions
+		noSink  bool
+		wantErr error
+		// wantDone lists the months fetched, and wantStatuses counts the
+		// rows written by status.
+		wantDone     []string
+		wantStatuses map[string]int
+	}{
+		{name: "three months", opts: scraper.BackfillOptions{CampgroundID: "232447", Months: 3},
+			wantDone:     []string{"2027-03", "2027-04", "2027-05"},
+			wantStatuses: map[string]int{"Closed": 93 + 28, "Reserved": 90 + 34, "Not Reservable": 31}},
+		{name: "last month", opts: scraper.BackfillOptions{CampgroundID: "232447", Months: 1},
+			wantDone:     []string{"2027-05"},
+			wantStatuses: map[string]int{"Closed": 28, "Reserved": 34, "Not Reservable": 31}},
+		{name: "no history", opts: scraper.BackfillOptions{CampgroundID: "232447", Months: 3}, noSink: true, wantErr: scraper.ErrNoHistory},
+		{name: "no months", opts: scraper.BackfillOptions{CampgroundID: "232447"}, wantErr: &scraper.ValidationError{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := &scraper.MemorySink{}
+			if test.noSink {
+				sink = nil
+			}
+			s, _ := backfillScraper(t, sink, "2027-03", "2027-04", "2027-05")
+
+			got, err := s.Backfill(context.Background(), test.opts)
+			var invalid *scraper.ValidationError
+			if _, wantInvalid := test.wantErr.(*scraper.ValidationError); wantInvalid && !errors.As(err, &invalid) ||
+				!wantInvalid && !errors.Is(err, test.wantErr) {
+				t.Fatalf("Backfill error %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if !reflect.DeepEqual(got.Done, test.wantDone) {
+				t.Errorf("done %v, want %v", got.Done, test.wantDone)
+			}
+			statuses := map[string]int{}
+			for _, row := range sink.Rows() {
+				statuses[row.Status]++
+				if !row.Backfilled || !row.ScrapedAt.Equal(backfillNow) || row.JobName != "backfill" {
+					t.Errorf("row %+v not marked as backfilled now", row)
+				}
+			}
+			if !reflect.DeepEqual(statuses, test.wantStatuses) {
+				t.Errorf("wrote %v, want %v", statuses, test.wantStatuses)
+			}
+			if got.Rows != len(sink.Rows()) {
+				t.Errorf("reported %d rows, wrote %d", got.Rows, len(sink.Rows()))
+			}
+		})
+	}
+}
+
+// A backfill that fails part way picks up at the month that failed, and
+// one started over writes nothing twice.
+func TestBackfillResumes(t *testing.T) {
+	sink := &scraper.MemorySink{}
+	s, dir := backfillScraper(t, sink, "2027-03", "2027-05")
+	ctx := context.Background()
+	opts := scraper.BackfillOptions{CampgroundID: "232447", Months: 3}
+
+	first, err := s.Backfill(ctx, opts)
+	if err == nil || !strings.Contains(err.Error(), "2027-04") {
+		t.Fatalf("Backfill error %v, want the missing April", err)
+	}
+	if !reflect.DeepEqual(first.Done, []string{"2027-03"}) || len(sink.Rows()) != 93 {
+		t.Fatalf("first backfill did %v and wrote %d rows, want March's 93", first.Done, len(sink.Rows()))
+	}
+
+	copyBackfillFixture(t, dir, "2027-04")
+	second, err := s.Backfill(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(second.Skipped, []string{"2027-03"}) || !reflect.DeepEqual(second.Done, []string{"2027-04", "2027-05"}) {
+		t.Errorf("second backfill skipped %v and did %v, want March skipped", second.Skipped, second.Done)
+	}
+	if n := len(sink.Rows()); n != 276 {
+		t.Errorf("wrote %d rows in all, want 276", n)
+	}
+
+	opts.Restart = true
+	again, err := s.Backfill(ctx, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Rows != 0 || again.Duplicates != 276 || len(sink.Rows()) != 276 {
+		t.Errorf("restarted backfill wrote %d rows and skipped %d, leaving %d; want none written", again.Rows, again.Duplicates, len(sink.Rows()))
+	}
+}
+
+// Widening a finished backfill fetches the earlier months it now covers and
+// skips only those already done.
+func TestBackfillWidened(t *testing.T) {
+	sink := &scraper.MemorySink{}
+	s, _ := backfillScraper(t, sink, "2027-03", "2027-04", "2027-05")
+	ctx := context.Background()
+
+	first, err := s.Backfill(ctx, scraper.BackfillOptions{CampgroundID: "232447", Months: 1})
+	if err != nil || !reflect.DeepEqual(first.Done, []string{"2027-05"}) {
+		t.Fatalf("one month did %v, %v, want May", first.Done, err)
+	}
+	wider, err := s.Backfill(ctx, scraper.BackfillOptions{CampgroundID: "232447", Months: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(wider.Done, []string{"2027-03", "2027-04"}) || !reflect.DeepEqual(wider.Skipped, []string{"2027-05"}) {
+		t.Errorf("three months did %v and skipped %v, want March and April done and May skipped", wider.Done, wider.Skipped)
+	}
+	if n := len(sink.Rows()); n != 276 || wider.Duplicates != 0 {
+		t.Errorf("wrote %d rows in all with %d duplicates, want 276 and none", n, wider.Duplicates)
+	}
+}
+
+// Against recreation.gov, Backfill waits the interval between months.
+func TestBackfillPaced(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(backfillNow)
+	sink := &scraper.MemorySink{}
+	scraper.DefaultScraper.History = &scraper.HistoryBatcher{Sink: sink}
+
+	start := time.Now()
+	got, err := scraper.Backfill(context.Background(), scraper.BackfillOptions{CampgroundID: "232447", Months: 3, Interval: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 60*time.Millisecond {
+		t.Errorf("three months fetched in %v, want at least two intervals", elapsed)
+	}
+	if months := h.Server.MonthsFetched("232447"); !reflect.DeepEqual(months, got.Done) {
+		t.Errorf("fetched %v, want %v", months, got.Done)
+	}
+	if n := len(sink.Rows()); n != 3*(31+30+31) {
+		t.Errorf("wrote %d rows, want every night of every site", n)
+	}
+	if months, _ := h.Store.BackfilledMonths(context.Background(), "232447"); !reflect.DeepEqual(months, got.Done) {
+		t.Errorf("recorded %v as backfilled, want %v", months, got.Done)
+	}
+}
@@ -0,0 +1,292 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"google.golang.org/api/googleapi"
+)
+
+// openingsPrefix is where each campground's openings are kept in
+// RESULTS_BUCKET.
+const openingsPrefix = "openings/"
+
+// Time-to-book statistics: a campground's alerts say how soon its sites
+// are typically booked once at least minBookedOpenings openings were seen
+// booked within bookingStatsWindow. At most maxBookedOpenings are kept per
+// campground.
+const (
+	minBookedOpenings  = 10
+	bookingStatsWindow = 30 * 24 * time.Hour
+	maxBookedOpenings  = 500
+)
+
+// openingAttempts bounds how often trackOpenings rereads a campground's
+// openings that another run changed while it was updating them.
+const openingAttempts = 3
+
+// errOpeningsChanged is returned by SetOpenings when the stored state is no
+// longer the version it was given.
+var errOpeningsChanged = errors.New("openings changed since they were read")
+
+// Opening is one night at one campsite seen available: when a scrape first
+// saw it so and, once a later scrape saw it reserved, when that was.
+type Opening struct {
+	CampgroundID string    `json:"campground_id"`
+	CampsiteID   string    `json:"campsite_id"`
+	Night        string    `json:"night"`
+	FirstSeen    time.Time `json:"first_seen"`
+	Booked       time.Time `json:"booked"`
+}
+
+// TimeToBook is how long the opening lasted.
+func (o Opening) TimeToBook() time.Duration {
+	return o.Booked.Sub(o.FirstSeen)
+}
+
+// OpeningState is what a campground's scrapes have seen of its openings:
+// when each night still available, by "campsite/night", was first seen,
+// and the latest openings seen booked, oldest first.
+type OpeningState struct {
+	Open   map[string]time.Time `json:"open"`
+	Booked []Opening            `json:"booked"`
+}
+
+// OpeningStore keeps each campground's OpeningState. Runs of watches on the
+// same campground update it at once, so each write names the version it
+// read, as claimSiteOnce does with a generation, and fails if another write
+// came first.
+type OpeningStore interface {
+	// Openings returns the state stored for campgroundID, or an empty one,
+	// and its version, zero when nothing is stored.
+	Openings(ctx context.Context, campgroundID string) (OpeningState, int64, error)
+	// SetOpenings stores state if the stored version is still version, and
+	// otherwise returns errOpeningsChanged.
+	SetOpenings(ctx context.Context, campgroundID string, state OpeningState, version int64) error
+}
+
+// Openings implements OpeningStore.
+func (s *MemoryStore) Openings(ctx context.Context, campgroundID string) (OpeningState, int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyOpenings(s.openings[campgroundID]), s.openingVersions[campgroundID], nil
+}
+
+// SetOpenings implements OpeningStore.
+func (s *MemoryStore) SetOpenings(ctx context.Context, campgroundID string, state OpeningState, version int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.openingVersions[campgroundID] != version {
+		return errOpeningsChanged
+	}
+	if s.openings == nil {
+		s.openings, s.openingVersions = map[string]OpeningState{}, map[string]int64{}
+	}
+	s.openings[campgroundID] = copyOpenings(state)
+	s.openingVersions[campgroundID]++
+	return nil
+}
+
+func copyOpenings(state OpeningState) OpeningState {
+	copied := OpeningState{Open: map[string]time.Time{}, Booked: append([]Opening{}, state.Booked...)}
+	for key, at := range state.Open {
+		copied.Open[key] = at
+	}
+	return copied
+}
+
+// Openings implements OpeningStore. The version is the object's
+// generation.
+func (s BucketStore) Openings(ctx context.Context, campgroundID string) (OpeningState, int64, error) {
+	client, err := storageClient()
+	if err != nil {
+		return OpeningState{}, 0, err
+	}
+	r, err := client.Bucket(s.Bucket).Object(openingsPrefix + campgroundID + ".json").NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return OpeningState{Open: map[string]time.Time{}}, 0, nil
+	}
+	if err != nil {
+		return OpeningState{}, 0, err
+	}
+	defer r.Close()
+	state := OpeningState{}
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return OpeningState{}, 0, fmt.Errorf("decoding openings for %s: %v", campgroundID, err)
+	}
+	if state.Open == nil {
+		state.Open = map[string]time.Time{}
+	}
+	return state, r.Attrs.Generation, nil
+}
+
+// SetOpenings implements OpeningStore. The write is conditional on the
+// object's generation, or on its not existing for version zero.
+func (s BucketStore) SetOpenings(ctx context.Context, campgroundID string, state OpeningState, version int64) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	condition := storage.Conditions{DoesNotExist: true}
+	if version != 0 {
+		condition = storage.Conditions{GenerationMatch: version}
+	}
+	w := client.Bucket(s.Bucket).Object(openingsPrefix + campgroundID + ".json").If(condition).NewWriter(ctx)
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		w.Close()
+		return err
+	}
+	err = w.Close()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+		return errOpeningsChanged
+	}
+	return err
+}
+
+// openingStore is where campgrounds' openings are kept: RESULTS_BUCKET when
+// set, memory otherwise, as for scanStore. Replace it in tests.
+var openingStore OpeningStore = defaultOpeningStore()
+
+func defaultOpeningStore() OpeningStore {
+	if bucket := os.Getenv("RESULTS_BUCKET"); bucket != "" {
+		return BucketStore{Bucket: bucket}
+	}
+	return &MemoryStore{}
+}
+
+// trackOpenings updates each campground's openings with the statuses one
+// run's scrapes saw, and returns the openings they saw booked: nights
+// first seen available by an earlier scrape and now reserved. A night
+// that closes for any other reason is forgotten, and nights the run did
+// not fetch are left as they were. Backfilled rows are skipped, as they
+// only hold final statuses. A campground whose openings another run changed
+// meanwhile is read and updated again. Failures are logged; the statistics
+// only lose a little history.
+func (s *Scraper) trackOpenings(ctx context.Context, rows []HistoryRow) []Opening {
+	byCampground := map[string][]HistoryRow{}
+	for _, row := range rows {
+		if !row.Backfilled {
+			byCampground[row.CampgroundID] = append(byCampground[row.CampgroundID], row)
+		}
+	}
+	today := core.CivilDateOf(s.now()).String()
+	booked := []Opening{}
+	for campgroundID, rows := range byCampground {
+		for attempt := 1; ; attempt++ {
+			state, version, err := openingStore.Openings(ctx, campgroundID)
+			if err != nil {
+				logger.Printf("campground %s: reading openings: %v", campgroundID, err)
+				break
+			}
+			seen := updateOpenings(&state, campgroundID, rows, today)
+			err = openingStore.SetOpenings(ctx, campgroundID, state, version)
+			if err == errOpeningsChanged && attempt < openingAttempts {
+				continue
+			}
+			if err != nil {
+				logger.Printf("campground %s: saving openings: %v", campgroundID, err)
+				break
+			}
+			booked = append(booked, seen...)
+			break
+		}
+	}
+	return booked
+}
+
+// updateOpenings applies one campground's rows to state as trackOpenings
+// describes, and returns the openings they saw booked.
+func updateOpenings(state *OpeningState, campgroundID string, rows []HistoryRow, today string) []Opening {
+	if state.Open == nil {
+		state.Open = map[string]time.Time{}
+	}
+	booked := []Opening{}
+	for _, row := range rows {
+		key := row.CampsiteID + "/" + row.Night
+		first, open := state.Open[key]
+		status := core.ParseStatus(row.Status)
+		switch {
+		case status == core.StatusAvailable && !open:
+			state.Open[key] = row.ScrapedAt
+		case status == core.StatusAvailable:
+		case open && status == core.StatusReserved:
+			opening := Opening{CampgroundID: campgroundID, CampsiteID: row.CampsiteID, Night: row.Night, FirstSeen: first, Booked: row.ScrapedAt}
+			state.Booked = append(state.Booked, opening)
+			booked = append(booked, opening)
+			delete(state.Open, key)
+		case open:
+			delete(state.Open, key)
+		}
+	}
+	for key := range state.Open {
+		if night := key[strings.LastIndex(key, "/")+1:]; night < today {
+			delete(state.Open, key)
+		}
+	}
+	if len(state.Booked) > maxBookedOpenings {
+		state.Booked = state.Booked[len(state.Booked)-maxBookedOpenings:]
+	}
+	return booked
+}
+
+// typicalTimeToBook is the median time campgroundID's openings lasted,
+// over those booked within bookingStatsWindow. ok is false when fewer than
+// minBookedOpenings were, as the median would mean little.
+func (s *Scraper) typicalTimeToBook(ctx context.Context, campgroundID string) (time.Duration, bool) {
+	state, _, err := openingStore.Openings(ctx, campgroundID)
+	if err != nil {
+		logger.Printf("campground %s: reading openings: %v", campgroundID, err)
+		return 0, false
+	}
+	since := s.now().Add(-bookingStatsWindow)
+	durations := []time.Duration{}
+	for _, o := range state.Booked {
+		if o.Booked.After(since) {
+			durations = append(durations, o.TimeToBook())
+		}
+	}
+	if len(durations) < minBookedOpenings {
+		return 0, false
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	middle := len(durations) / 2
+	if len(durations)%2 == 0 {
+		return (durations[middle-1] + durations[middle]) / 2, true
+	}
+	return durations[middle], true
+}
+
+// timeToBookNote is the alert line for a campground whose openings
+// typically last d, such as "Sites here are typically gone within ~15
+// min." It is empty when d is zero.
+func timeToBookNote(d time.Duration) string {
+	if d <= 0 {
+		return ""
+	}
+	return "Sites here are typically gone within " + approximately(d) + "."
+}
+
+// approximately rounds d for people: "~3 min", "~15 min" to the nearest
+// five minutes from ten, "~2 h" and, from two days, "~3 days".
+func approximately(d time.Duration) string {
+	switch {
+	case d < 10*time.Minute:
+		return fmt.Sprintf("~%d min", int(math.Max(1, math.Round(d.Minutes()))))
+	case d < time.Hour:
+		return fmt.Sprintf("~%d min", int(math.Round(d.Minutes()/5))*5)
+	case d < 48*time.Hour:
+		return fmt.Sprintf("~%d h", int(math.Round(d.Hours())))
+	}
+	return fmt.Sprintf("~%d days", int(math.Round(d.Hours()/24)))
+}
@@ -0,0 +1,228 @@
+package scraper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// openingRows are the statuses one scrape at at saw, by "campsite/night".
+func openingRows(at time.Time, statuses map[string]string) []HistoryRow {
+	rows := []HistoryRow{}
+	for key, status := range statuses {
+		rows = append(rows, HistoryRow{ScrapedAt: at, CampgroundID: "232447", CampsiteID: key[:4], Night: key[5:], Status: status})
+	}
+	return rows
+}
+
+func TestTrackOpenings(t *testing.T) {
+	start := time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name string
+		// scrapes are fed in turn, ten minutes apart.
+		scrapes    []map[string]string
+		wantBooked []Opening
+		wantOpen   map[string]time.Time
+	}{
+		{
+			name:     "first seen",
+			scrapes:  []map[string]string{{"1001/2027-07-14": "Reserved"}, {"1001/2027-07-14": "Available"}, {"1001/2027-07-14": "Available"}},
+			wantOpen: map[string]time.Time{"1001/2027-07-14": start.Add(10 * time.Minute)},
+		},
+		{
+			name:    "booked",
+			scrapes: []map[string]string{{"1001/2027-07-14": "Available"}, {"1001/2027-07-14": "Available"}, {"1001/2027-07-14": "Reserved"}},
+			wantBooked: []Opening{{CampgroundID: "232447", CampsiteID: "1001", Night: "2027-07-14",
+				FirstSeen: start, Booked: start.Add(20 * time.Minute)}},
+			wantOpen: map[string]time.Time{},
+		},
+		{
+			name:     "closed is not booked",
+			scrapes:  []map[string]string{{"1001/2027-07-14": "Available"}, {"1001/2027-07-14": "Not Available"}},
+			wantOpen: map[string]time.Time{},
+		},
+		{
+			name:     "never open",
+			scrapes:  []map[string]string{{"1001/2027-07-14": "Reserved"}, {"1001/2027-07-14": "Reserved"}},
+			wantOpen: map[string]time.Time{},
+		},
+		{
+			name:     "other nights kept",
+			scrapes:  []map[string]string{{"1001/2027-07-14": "Available", "1002/2027-07-15": "Available"}, {"1002/2027-07-15": "Not Available"}},
+			wantOpen: map[string]time.Time{"1001/2027-07-14": start},
+		},
+		{
+			name:     "past nights dropped",
+			scrapes:  []map[string]string{{"1001/2027-06-01": "Available", "1001/2027-05-31": "Available"}},
+			wantOpen: map[string]time.Time{"1001/2027-06-01": start},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c := useClock(t, start)
+			store := &MemoryStore{}
+			old := openingStore
+			openingStore = store
+			defer func() { openingStore = old }()
+
+			ctx := context.Background()
+			booked := []Opening{}
+			for _, statuses := range test.scrapes {
+				booked = append(booked, DefaultScraper.trackOpenings(ctx, openingRows(c.at, statuses))...)
+				c.at = c.at.Add(10 * time.Minute)
+			}
+			if !reflect.DeepEqual(booked, append([]Opening{}, test.wantBooked...)) {
+				t.Errorf("booked %+v, want %+v", booked, test.wantBooked)
+			}
+			state, _, _ := store.Openings(ctx, "232447")
+			if !reflect.DeepEqual(state.Open, test.wantOpen) {
+				t.Errorf("open %v, want %v", state.Open, test.wantOpen)
+			}
+			if !reflect.DeepEqual(state.Booked, append([]Opening{}, test.wantBooked...)) {
+				t.Errorf("stored booked %+v, want %+v", state.Booked, test.wantBooked)
+			}
+		})
+	}
+}
+
+// Backfilled rows hold final statuses, not what a scrape saw as it
+// happened, so they neither open nor book anything.
+func TestTrackOpeningsBackfilled(t *testing.T) {
+	c := useClock(t, time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	store := &MemoryStore{}
+	old := openingStore
+	openingStore = store
+	defer func() { openingStore = old }()
+	ctx := context.Background()
+
+	DefaultScraper.trackOpenings(ctx, openingRows(c.at, map[string]string{"1001/2027-07-14": "Available"}))
+	rows := openingRows(c.at.Add(time.Minute), map[string]string{"1001/2027-07-14": "Reserved", "1002/2027-07-14": "Available"})
+	for i := range rows {
+		rows[i].Backfilled = true
+	}
+	if booked := DefaultScraper.trackOpenings(ctx, rows); len(booked) != 0 {
+		t.Errorf("backfilled rows booked %+v", booked)
+	}
+	state, _, _ := store.Openings(ctx, "232447")
+	if want := map[string]time.Time{"1001/2027-07-14": c.at}; !reflect.DeepEqual(state.Open, want) {
+		t.Errorf("open %v, want %v", state.Open, want)
+	}
+}
+
+// racingStore is a MemoryStore into which another run's scrape of a second
+// site lands between trackOpenings reading the openings and writing them.
+type racingStore struct {
+	*MemoryStore
+	raced bool
+}
+
+func (s *racingStore) SetOpenings(ctx context.Context, campgroundID string, state OpeningState, version int64) error {
+	if !s.raced {
+		s.raced = true
+		other, v, _ := s.MemoryStore.Openings(ctx, campgroundID)
+		other.Open["1002/2027-07-15"] = time.Date(2027, 6, 1, 11, 0, 0, 0, time.UTC)
+		if err := s.MemoryStore.SetOpenings(ctx, campgroundID, other, v); err != nil {
+			return err
+		}
+	}
+	return s.MemoryStore.SetOpenings(ctx, campgroundID, state, version)
+}
+
+// Runs updating the same campground's openings at once each keep what they
+// saw: the one that loses the race reads the other's write and tries again.
+func TestTrackOpeningsConcurrentUpdate(t *testing.T) {
+	c := useClock(t, time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	store := &racingStore{MemoryStore: &MemoryStore{}}
+	old := openingStore
+	openingStore = store
+	defer func() { openingStore = old }()
+	ctx := context.Background()
+
+	DefaultScraper.trackOpenings(ctx, openingRows(c.at, map[string]string{"1001/2027-07-14": "Available"}))
+	state, version, _ := store.Openings(ctx, "232447")
+	want := map[string]time.Time{"1001/2027-07-14": c.at, "1002/2027-07-15": time.Date(2027, 6, 1, 11, 0, 0, 0, time.UTC)}
+	if !reflect.DeepEqual(state.Open, want) {
+		t.Errorf("open %v, want both runs' openings %v", state.Open, want)
+	}
+	if version != 2 {
+		t.Errorf("version %d, want 2 writes", version)
+	}
+}
+
+func TestMemoryStoreOpeningsVersion(t *testing.T) {
+	store := &MemoryStore{}
+	ctx := context.Background()
+	if err := store.SetOpenings(ctx, "232447", OpeningState{}, 1); err != errOpeningsChanged {
+		t.Errorf("writing a version never stored: %v, want errOpeningsChanged", err)
+	}
+	if err := store.SetOpenings(ctx, "232447", OpeningState{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetOpenings(ctx, "232447", OpeningState{}, 0); err != errOpeningsChanged {
+		t.Errorf("writing a stale version: %v, want errOpeningsChanged", err)
+	}
+	if _, version, _ := store.Openings(ctx, "232447"); version != 1 {
+		t.Errorf("version %d, want 1", version)
+	}
+}
+
+func TestTypicalTimeToBook(t *testing.T) {
+	now := time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)
+	// booked returns n openings lasting each of durations in turn, booked
+	// age ago.
+	booked := func(n int, age time.Duration, durations ...time.Duration) []Opening {
+		openings := []Opening{}
+		for i := 0; i < n; i++ {
+			at := now.Add(-age)
+			openings = append(openings, Opening{CampgroundID: "232447", FirstSeen: at.Add(-durations[i%len(durations)]), Booked: at})
+		}
+		return openings
+	}
+	tests := []struct {
+		name   string
+		booked []Opening
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "no history"},
+		{name: "sparse", booked: booked(minBookedOpenings-1, time.Hour, 5*time.Minute)},
+		{name: "odd", booked: booked(11, time.Hour, 5*time.Minute, 10*time.Minute, time.Hour), want: 10 * time.Minute, wantOK: true},
+		{name: "even", booked: booked(10, time.Hour, 10*time.Minute, 20*time.Minute), want: 15 * time.Minute, wantOK: true},
+		{name: "stale", booked: append(booked(20, bookingStatsWindow+time.Hour, time.Minute), booked(5, time.Hour, time.Minute)...)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useClock(t, now)
+			store := &MemoryStore{}
+			old := openingStore
+			openingStore = store
+			defer func() { openingStore = old }()
+			store.SetOpenings(context.Background(), "232447", OpeningState{Booked: test.booked}, 0)
+
+			got, ok := DefaultScraper.typicalTimeToBook(context.Background(), "232447")
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("typicalTimeToBook() = %v, %v, want %v, %v", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestTimeToBookNote(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{0, ""},
+		{20 * time.Second, "Sites here are typically gone within ~1 min."},
+		{3 * time.Minute, "Sites here are typically gone within ~3 min."},
+		{14 * time.Minute, "Sites here are typically gone within ~15 min."},
+		{2*time.Hour + 10*time.Minute, "Sites here are typically gone within ~2 h."},
+		{80 * time.Hour, "Sites here are typically gone within ~3 days."},
+	}
+	for _, test := range tests {
+		if got := timeToBookNote(test.d); got != test.want {
+			t.Errorf("timeToBookNote(%v) = %q, want %q", test.d, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"cloud.google.com/go/pubsub"
+	scheduler "cloud.google.com/go/scheduler/apiv1"
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/iterator"
+	"google.golang.org/api/serviceusage/v1"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// BootstrapConfig describes the GCP resources a deployment needs.
+type BootstrapConfig struct {
+	ProjectID string
+	Region    string
+	// Topic is the topic ScrapeFromMessage is subscribed to, WATCH_TOPIC by
+	// default.
+	Topic string
+	// ResultsTopic and StatsTopic are created only when set.
+	ResultsTopic string
+	StatsTopic   string
+	// ResultsBucket is RESULTS_BUCKET, where watches keep their state. It
+	// is created when set, private.
+	ResultsBucket string
+	// PagesBucket is PAGES_BUCKET, which hosts the results pages linked
+	// from short-form alerts. It is created when set, with objects deleted
+	// after PagesDays days (default 7). The bucket itself is private; each
+	// page is made public on its own, so nobody can list the others.
+	PagesBucket string
+	PagesDays   int64
+	// ArchiveBucket holds the notification archive. It is created when set,
+	// private, with records deleted after ArchiveDays days (default 90).
+	ArchiveBucket string
+	ArchiveDays   int64
+	// HistoryDataset and HistoryTable are the BigQuery table for scrape
+	// history, as BQ_DATASET and BQ_TABLE. The table is created when both
+	// are set; the dataset must already exist.
+	HistoryDataset string
+	HistoryTable   string
+	// DigestTopic is the topic DailyDigest is triggered by. When set, it is
+	// created along with the scheduler job publishing to it on
+	// DigestSchedule, by default every morning at 7, in DigestTimeZone, by
+	// default UTC.
+	DigestTopic    string
+	DigestSchedule string
+	DigestTimeZone string
+	// ServiceAccount is the account the function runs as. It defaults to the
+	// App Engine default service account used by Cloud Functions.
+	ServiceAccount string
+}
+
+// BootstrapStatus is the outcome of a single bootstrap step.
+type BootstrapStatus string
+
+// Bootstrap step outcomes.
+const (
+	BootstrapCreated  BootstrapStatus = "created"
+	BootstrapExisting BootstrapStatus = "existing"
+	BootstrapFailed   BootstrapStatus = "failed"
+)
+
+// BootstrapResult reports what happened to one resource.
+type BootstrapResult struct {
+	Resource string
+	Status   BootstrapStatus
+	Detail   string
+}
+
+// requiredRoles are the roles the function's service account needs, with the
+// reason each one is needed.
+var requiredRoles = map[string]string{
+	"roles/cloudscheduler.admin":    "delete watch jobs once availability is found",
+	"roles/pubsub.publisher":        "publish to the results and stats topics",
+	"roles/datastore.user":          "read and write the watch registry in Firestore",
+	"roles/monitoring.metricWriter": "write run metrics to Cloud Monitoring",
+	"roles/bigquery.dataEditor":     "stream scrape history into BigQuery",
+	"roles/storage.objectAdmin":     "keep watch state, results pages and the notification archive in Cloud Storage",
+}
+
+// digestJobID is the ID of the scheduler job triggering DailyDigest.
+const digestJobID = "campfinder-daily-digest"
+
+// Bootstrap idempotently creates or verifies every resource in cfg. It never
+// stops at the first failure, so the returned results cover every step and
+// the whole thing can be re-run until nothing reports BootstrapFailed.
+func Bootstrap(ctx context.Context, cfg BootstrapConfig) []BootstrapResult {
+	results := []BootstrapResult{}
+	if cfg.Topic == "" {
+		cfg.Topic = activeConfig.Topic
+	}
+
+	client, err := pubsub.NewClient(ctx, cfg.ProjectID)
+	if err != nil {
+		results = append(results, BootstrapResult{"pubsub", BootstrapFailed, err.Error()})
+	} else {
+		defer client.Close()
+		for _, topicID := range []string{cfg.Topic, cfg.ResultsTopic, cfg.StatsTopic, cfg.DigestTopic} {
+			if topicID != "" {
+				results = append(results, ensureTopic(ctx, client, topicID))
+			}
+		}
+	}
+
+	if cfg.ResultsBucket != "" {
+		results = append(results, ensureBucket(ctx, cfg, cfg.ResultsBucket, 0, false))
+	}
+	if cfg.PagesBucket != "" {
+		results = append(results, ensureBucket(ctx, cfg, cfg.PagesBucket, orDefault(cfg.PagesDays, 7), true))
+	}
+	if cfg.ArchiveBucket != "" {
+		results = append(results, ensureBucket(ctx, cfg, cfg.ArchiveBucket, orDefault(cfg.ArchiveDays, 90), false))
+	}
+	if cfg.HistoryDataset != "" && cfg.HistoryTable != "" {
+		results = append(results, ensureHistoryTable(ctx, cfg))
+	}
+	results = append(results, checkSchedulerLocation(ctx, cfg))
+	if cfg.DigestTopic != "" {
+		results = append(results, ensureDigestJob(ctx, cfg))
+	}
+	results = append(results, checkFirestore(ctx, cfg))
+	results = append(results, checkServiceAccountRoles(ctx, cfg)...)
+	return results
+}
+
+func ensureTopic(ctx context.Context, client *pubsub.Client, topicID string) BootstrapResult {
+	resource := "topic " + topicID
+	exists, err := client.Topic(topicID).Exists(ctx)
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	if exists {
+		return BootstrapResult{resource, BootstrapExisting, ""}
+	}
+	if _, err := client.CreateTopic(ctx, topicID); err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	return BootstrapResult{resource, BootstrapCreated, ""}
+}
+
+// ensureBucket creates a private bucket, with a rule deleting objects after
+// days when days is set, or adds the rule to an existing bucket that lacks
+// it. A public grant on the bucket, which would let anyone list its
+// objects, is removed. A pages bucket keeps fine-grained access control, so
+// each results page can be made public on its own.
+func ensureBucket(ctx context.Context, cfg BootstrapConfig, name string, days int64, pages bool) BootstrapResult {
+	resource := "bucket " + name
+	rules := []storage.LifecycleRule{}
+	if days > 0 {
+		rules = append(rules, storage.LifecycleRule{
+			Action:    storage.LifecycleAction{Type: storage.DeleteAction},
+			Condition: storage.LifecycleCondition{AgeInDays: days},
+		})
+	}
+
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	defer client.Close()
+	bucket := client.Bucket(name)
+
+	status := BootstrapExisting
+	attrs, err := bucket.Attrs(ctx)
+	switch {
+	case err == storage.ErrBucketNotExist:
+		err = bucket.Create(ctx, cfg.ProjectID, &storage.BucketAttrs{
+			Location:  cfg.Region,
+			Lifecycle: storage.Lifecycle{Rules: rules},
+		})
+		if err != nil {
+			return BootstrapResult{resource, BootstrapFailed, err.Error()}
+		}
+		status = BootstrapCreated
+	case err != nil:
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	default:
+		if pages && attrs.UniformBucketLevelAccess.Enabled {
+			return BootstrapResult{resource, BootstrapFailed, "uniform bucket-level access is on, so pages cannot be made public one by one"}
+		}
+		if len(rules) > 0 && !hasRule(attrs.Lifecycle, rules[0]) {
+			lifecycle := attrs.Lifecycle
+			lifecycle.Rules = append(lifecycle.Rules, rules[0])
+			if _, err := bucket.Update(ctx, storage.BucketAttrsToUpdate{Lifecycle: &lifecycle}); err != nil {
+				return BootstrapResult{resource, BootstrapFailed, "adding expiry rule: " + err.Error()}
+			}
+		}
+	}
+
+	policy, err := bucket.IAM().Policy(ctx)
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, "reading IAM policy: " + err.Error()}
+	}
+	public := false
+	for _, role := range policy.Roles() {
+		for _, member := range []string{"allUsers", "allAuthenticatedUsers"} {
+			if policy.HasRole(member, role) {
+				policy.Remove(member, role)
+				public = true
+			}
+		}
+	}
+	if public {
+		if err := bucket.IAM().SetPolicy(ctx, policy); err != nil {
+			return BootstrapResult{resource, BootstrapFailed, "removing public access: " + err.Error()}
+		}
+	}
+	switch {
+	case pages:
+		return BootstrapResult{resource, status, fmt.Sprintf("pages expire after %d days", days)}
+	case days > 0:
+		return BootstrapResult{resource, status, fmt.Sprintf("objects expire after %d days", days)}
+	}
+	return BootstrapResult{resource, status, ""}
+}
+
+// orDefault returns n, or def when n is not positive.
+func orDefault(n int64, def int64) int64 {
+	if n <= 0 {
+		return def
+	}
+	return n
+}
+
+func hasRule(lifecycle storage.Lifecycle, want storage.LifecycleRule) bool {
+	for _, rule := range lifecycle.Rules {
+		if rule.Action.Type == want.Action.Type && rule.Condition.AgeInDays == want.Condition.AgeInDays {
+			return true
+		}
+	}
+	return false
+}
+
+func ensureHistoryTable(ctx context.Context, cfg BootstrapConfig) BootstrapResult {
+	resource := "bigquery table " + cfg.HistoryDataset + "." + cfg.HistoryTable
+	sink := BigQuerySink{Project: cfg.ProjectID, Dataset: cfg.HistoryDataset, Table: cfg.HistoryTable}
+	created, err := sink.EnsureSchema(ctx)
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	if created {
+		return BootstrapResult{resource, BootstrapCreated, ""}
+	}
+	return BootstrapResult{resource, BootstrapExisting, ""}
+}
+
+func checkSchedulerLocation(ctx context.Context, cfg BootstrapConfig) BootstrapResult {
+	parent := fmt.Sprintf("projects/%s/locations/%s", cfg.ProjectID, cfg.Region)
+	resource := "scheduler location " + parent
+	c, err := scheduler.NewCloudSchedulerClient(ctx)
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	defer c.Close()
+
+	it := c.ListJobs(ctx, &schedulerpb.ListJobsRequest{Parent: parent, PageSize: 1})
+	if _, err := it.Next(); err != nil && err != iterator.Done {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	return BootstrapResult{resource, BootstrapExisting, ""}
+}
+
+// ensureDigestJob creates the scheduler job publishing to cfg.DigestTopic.
+// An existing job is left as it is, so a schedule changed since is kept.
+func ensureDigestJob(ctx context.Context, cfg BootstrapConfig) BootstrapResult {
+	location := Config{Project: cfg.ProjectID, Location: cfg.Region}
+	name := location.JobName(digestJobID).String()
+	resource := "scheduler job " + name
+	c, err := newWatchScheduler()
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	job, err := c.GetJob(ctx, name)
+	if err == nil {
+		return BootstrapResult{resource, BootstrapExisting, fmt.Sprintf("runs %q in %s", job.Schedule, job.TimeZone)}
+	}
+	if status.Code(err) != codes.NotFound {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	schedule, timeZone := cfg.DigestSchedule, cfg.DigestTimeZone
+	if schedule == "" {
+		schedule = "0 7 * * *"
+	}
+	if timeZone == "" {
+		timeZone = "Etc/UTC"
+	}
+	err = c.CreateJob(ctx, location.Parent(), &schedulerpb.Job{
+		Name:        name,
+		Description: "Emails the daily watch digest",
+		Schedule:    schedule,
+		TimeZone:    timeZone,
+		Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{
+			TopicName: fmt.Sprintf("projects/%s/topics/%s", cfg.ProjectID, cfg.DigestTopic),
+			Data:      []byte("{}"),
+		}},
+	})
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	return BootstrapResult{resource, BootstrapCreated, fmt.Sprintf("runs %q in %s", schedule, timeZone)}
+}
+
+func checkFirestore(ctx context.Context, cfg BootstrapConfig) BootstrapResult {
+	name := fmt.Sprintf("projects/%s/services/firestore.googleapis.com", cfg.ProjectID)
+	resource := "firestore"
+	svc, err := serviceusage.NewService(ctx)
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	service, err := svc.Services.Get(name).Context(ctx).Do()
+	if err != nil {
+		return BootstrapResult{resource, BootstrapFailed, err.Error()}
+	}
+	if service.State != "ENABLED" {
+		return BootstrapResult{resource, BootstrapFailed, "firestore.googleapis.com is " + service.State + "; enable it in the console"}
+	}
+	return BootstrapResult{resource, BootstrapExisting, ""}
+}
+
+// checkServiceAccountRoles reports one result per required role, marking the
+// ones the service account is not yet bound to as failed.
+func checkServiceAccountRoles(ctx context.Context, cfg BootstrapConfig) []BootstrapResult {
+	account := cfg.ServiceAccount
+	if account == "" {
+		account = cfg.ProjectID + "@appspot.gserviceaccount.com"
+	}
+	member := "serviceAccount:" + account
+
+	roles := make([]string, 0, len(requiredRoles))
+	for role := range requiredRoles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	granted, err := projectRoles(ctx, cfg.ProjectID, member)
+	results := []BootstrapResult{}
+	for _, role := range roles {
+		resource := fmt.Sprintf("role %s for %s", role, account)
+		switch {
+		case err != nil:
+			results = append(results, BootstrapResult{resource, BootstrapFailed, "could not read project IAM policy: " + err.Error()})
+		case granted[role]:
+			results = append(results, BootstrapResult{resource, BootstrapExisting, ""})
+		default:
+			detail := fmt.Sprintf("missing, needed to %s: gcloud projects add-iam-policy-binding %s --member=%s --role=%s",
+				requiredRoles[role], cfg.ProjectID, member, role)
+			results = append(results, BootstrapResult{resource, BootstrapFailed, detail})
+		}
+	}
+	return results
+}
+
+// projectRoles returns the set of roles bound to member on the project. The
+// caller's own access to read the policy is checked first so a permission
+// problem is reported as such rather than as every role missing.
+func projectRoles(ctx context.Context, projectID string, member string) (map[string]bool, error) {
+	svc, err := cloudresourcemanager.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	perms, err := svc.Projects.TestIamPermissions(projectID, &cloudresourcemanager.TestIamPermissionsRequest{
+		Permissions: []string{"resourcemanager.projects.getIamPolicy"},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(perms.Permissions) == 0 {
+		return nil, fmt.Errorf("caller lacks resourcemanager.projects.getIamPolicy on %s", projectID)
+	}
+
+	policy, err := svc.Projects.GetIamPolicy(projectID, &cloudresourcemanager.GetIamPolicyRequest{}).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	roles := map[string]bool{}
+	for _, binding := range policy.Bindings {
+		for _, m := range binding.Members {
+			if m == member {
+				roles[binding.Role] = true
+			}
+		}
+	}
+	return roles, nil
+}
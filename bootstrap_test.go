@@ -0,0 +1,52 @@
+package scraper_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// The digest job is created once, publishing to the digest topic, and a
+// later bootstrap finds it rather than replacing it.
+func TestBootstrapDigestJob(t *testing.T) {
+	h := fakerecgov.Start(fakerecgov.Fixture{})
+	defer h.Close()
+	cfg := scraper.BootstrapConfig{ProjectID: "fakerecgov", Region: "us-central1", DigestTopic: "campfinder-digest"}
+	name := "projects/fakerecgov/locations/us-central1/jobs/campfinder-daily-digest"
+
+	if r := scraper.EnsureDigestJob(context.Background(), cfg); r.Status != scraper.BootstrapCreated {
+		t.Fatalf("first bootstrap: %+v, want created", r)
+	}
+	job := h.Scheduler.Job(name)
+	if job == nil {
+		t.Fatalf("no job %s", name)
+	}
+	if job.Schedule != "0 7 * * *" || job.TimeZone != "Etc/UTC" || job.GetPubsubTarget().GetTopicName() != "projects/fakerecgov/topics/campfinder-digest" {
+		t.Errorf("digest job %+v", job)
+	}
+
+	job.Schedule = "30 6 * * *"
+	if r := scraper.EnsureDigestJob(context.Background(), cfg); r.Status != scraper.BootstrapExisting {
+		t.Errorf("second bootstrap: %+v, want existing", r)
+	}
+	if got := h.Scheduler.Job(name).Schedule; got != "30 6 * * *" {
+		t.Errorf("schedule %q after a second bootstrap, want the changed one kept", got)
+	}
+
+	// The digest job is no watch, so verifying watches passes over it.
+	defer scraper.UseConfig(testConfig)()
+	problems, err := scraper.VerifyWatches(context.Background(), scraper.VerifyOptions{})
+	if err != nil || len(problems) != 0 {
+		t.Errorf("VerifyWatches = %+v, %v, want no problems", problems, err)
+	}
+}
+
+func TestBootstrapRoles(t *testing.T) {
+	for _, role := range []string{"roles/cloudscheduler.admin", "roles/pubsub.publisher", "roles/storage.objectAdmin"} {
+		if scraper.RequiredRoles[role] == "" {
+			t.Errorf("role %s is not checked", role)
+		}
+	}
+}
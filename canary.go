@@ -0,0 +1,54 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// canarySpecPath is read relative to the function's source directory unless
+// CANARY_SPEC names another file.
+const canarySpecPath = "canary_spec.json"
+
+// Canary checks a known campground's payload against the checked-in
+// expectations and emails a drift report when they no longer hold. It is
+// meant to be triggered daily by its own scheduler job; the message body is
+// ignored.
+func Canary(ctx context.Context, m pubsub.Message) error {
+	path := os.Getenv("CANARY_SPEC")
+	if path == "" {
+		path = canarySpecPath
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("reading canary spec: %v", err)
+	}
+	var spec core.PayloadSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	payload, err := DefaultScraper.Provider().FetchMonthRaw(ctx, spec.CampgroundID, DefaultScraper.now())
+	if err != nil {
+		return fmt.Errorf("canary fetch: %v", err)
+	}
+	problems := core.CheckPayload(payload, spec)
+	if len(problems) == 0 {
+		logger.Printf("canary: campground %s matches %s", spec.CampgroundID, path)
+		return nil
+	}
+	logger.Printf("canary: %d expectations failed for campground %s", len(problems), spec.CampgroundID)
+
+	subject := "recreation.gov payload drift detected"
+	plain := fmt.Sprintf("The availability payload for campground %s no longer matches %s:\n\n%s\n\n"+
+		"Lines starting with + are new, - missing and ~ changed. If the change is benign, update the spec.",
+		spec.CampgroundID, path, strings.Join(problems, "\n"))
+	return sendNotice(ctx, subject, plain, "<pre>"+html.EscapeString(plain)+"</pre>")
+}
@@ -0,0 +1,167 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// maxConcurrentDetails bounds the campsite detail fetches one watch makes
+// at once.
+const maxConcurrentDetails = 4
+
+// Campsite details are cached for the life of the process since a
+// campsite's occupancy limits and attributes do not change. Failed fetches
+// are not cached.
+var (
+	detailMu    sync.Mutex
+	detailCache = map[string]core.CampsiteDetail{}
+)
+
+// unlistedCampsites counts the open sites whose campsite details
+// recreation.gov does not have.
+var unlistedCampsites = expvar.NewInt("campsite_metadata_missing")
+
+// campsiteDetail returns a campsite's details, from the cache when they
+// have been fetched before, or from the ingested result when s has one.
+func (s *Scraper) campsiteDetail(ctx context.Context, campsiteID string) (core.CampsiteDetail, error) {
+	if s.ingested != nil {
+		return s.ingested.campsiteDetail(campsiteID)
+	}
+	detailMu.Lock()
+	detail, ok := detailCache[campsiteID]
+	detailMu.Unlock()
+	if ok {
+		return detail, nil
+	}
+	detail, err := s.Provider().FetchCampsiteDetail(ctx, campsiteID)
+	if err != nil {
+		return detail, err
+	}
+	detailMu.Lock()
+	detailCache[campsiteID] = detail
+	detailMu.Unlock()
+	return detail, nil
+}
+
+// errUnlisted is the error campsiteDetails gives for a site in unlisted.
+var errUnlisted = errors.New("recreation.gov has no details for it")
+
+// campsiteDetails fetches the details of each site, a few at a time. The
+// sites in unlisted are not fetched and get errUnlisted.
+func (s *Scraper) campsiteDetails(ctx context.Context, sites []string, unlisted map[string]bool) ([]core.CampsiteDetail, []error) {
+	details := make([]core.CampsiteDetail, len(sites))
+	errs := make([]error, len(sites))
+	slots := make(chan struct{}, maxConcurrentDetails)
+	var wg sync.WaitGroup
+	for i, site := range sites {
+		if unlisted[site] {
+			errs[i] = errUnlisted
+			continue
+		}
+		wg.Add(1)
+		go func(i int, site string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			details[i], errs[i] = s.campsiteDetail(ctx, site)
+		}(i, site)
+	}
+	wg.Wait()
+	return details, errs
+}
+
+// unlistedSites returns the sites in the availability feed that
+// recreation.gov has no campsite details for, such as a site added since
+// the campground's metadata was cached. Each is logged and counted in
+// campsite_metadata_missing, and the campground's cached facility is
+// dropped so the next lookup fetches it afresh. The per-site filters keep
+// such a site and mark it unknown without fetching its details again, and
+// it is not priced.
+func (s *Scraper) unlistedSites(ctx context.Context, jobName string, campgroundID string, sites []string) map[string]bool {
+	_, errs := s.campsiteDetails(ctx, sites, nil)
+	unlisted := map[string]bool{}
+	for i, site := range sites {
+		var status *core.StatusError
+		if errors.As(errs[i], &status) && status.StatusCode == http.StatusNotFound {
+			logger.Printf("job %s: campground %s lists site %s as available but has no details for it", jobName, campgroundID, site)
+			unlistedCampsites.Add(1)
+			unlisted[site] = true
+		}
+	}
+	if len(unlisted) > 0 {
+		facilityMu.Lock()
+		delete(facilityCache, campgroundID)
+		facilityMu.Unlock()
+	}
+	return unlisted
+}
+
+// filterByCapacity keeps the sites that take at least people, fetching the
+// candidates' details a few at a time. A site whose details cannot be
+// fetched, or that does not state a maximum, is kept and marked unknown
+// rather than dropped on a guess, as is one in unlisted.
+func (s *Scraper) filterByCapacity(ctx context.Context, jobName string, sites []string, people int, unlisted map[string]bool) ([]string, map[string]bool) {
+	details, errs := s.campsiteDetails(ctx, sites, unlisted)
+	kept := []string{}
+	unknown := map[string]bool{}
+	for i, site := range sites {
+		switch {
+		case errs[i] != nil:
+			logger.Printf("job %s: capacity of site %s unknown: %v", jobName, site, errs[i])
+			unknown[site] = true
+		case details[i].Capacity.Max == 0:
+			unknown[site] = true
+		case details[i].Capacity.Max < people:
+			continue
+		}
+		kept = append(kept, site)
+	}
+	return kept, unknown
+}
+
+// missingAttributesNote tells the reader how many open sites were left out
+// for not listing a required attribute.
+func missingAttributesNote(n int) string {
+	if n == 1 {
+		return "1 open site was left out because its details do not list a required attribute."
+	}
+	return fmt.Sprintf("%d open sites were left out because their details do not list a required attribute.", n)
+}
+
+// filterByAttributes keeps the sites whose attributes meet required; see
+// core.MatchAttributes. As with capacity, a site whose details cannot be
+// fetched, or is in unlisted, is kept and marked unknown. A site that lacks
+// a required attribute altogether is dropped and counted in missing.
+func (s *Scraper) filterByAttributes(ctx context.Context, jobName string, sites []string, required map[string]string, unlisted map[string]bool) (kept []string, unknown map[string]bool, missing int) {
+	details, errs := s.campsiteDetails(ctx, sites, unlisted)
+	kept = []string{}
+	unknown = map[string]bool{}
+	for i, site := range sites {
+		if errs[i] != nil {
+			logger.Printf("job %s: attributes of site %s unknown: %v", jobName, site, errs[i])
+			unknown[site] = true
+			kept = append(kept, site)
+			continue
+		}
+		matched, absent := core.MatchAttributes(details[i].Attributes, required)
+		if len(absent) > 0 {
+			logger.Printf("job %s: site %s does not list %s", jobName, site, strings.Join(absent, ", "))
+			missing++
+		}
+		if matched {
+			kept = append(kept, site)
+		}
+	}
+	return kept, unknown, missing
+}
@@ -0,0 +1,128 @@
+package scraper_test
+
+import (
+	"context"
+	"expvar"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// unlistedFixture is e2eFixture with two sites, A004 and A005, open in the
+// availability feed but missing from the campsite details.
+func unlistedFixture() fakerecgov.Fixture {
+	f := e2eFixture(true)
+	open := map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}
+	f.Campgrounds[0].Sites = append(f.Campgrounds[0].Sites,
+		fakerecgov.Site{ID: "1004", Site: "A004", Loop: "A", Type: "STANDARD NONELECTRIC", Nights: open, Unlisted: true},
+		fakerecgov.Site{ID: "1005", Site: "A005", Loop: "A", Type: "STANDARD NONELECTRIC", Nights: open, Unlisted: true},
+	)
+	return f
+}
+
+// A site recreation.gov has no details for passes the per-site filters
+// marked attributes unknown, goes unpriced, is counted and has the
+// campground's details fetched afresh.
+func TestUnlistedSites(t *testing.T) {
+	tests := []struct {
+		name  string
+		watch func(m *scraper.MessageContent)
+		// wantSites are the alert's sites, in order. The unlisted sites are
+		// marked price unknown when others are priced; when none are, the
+		// alert carries no prices at all.
+		wantSites        []string
+		wantPriceUnknown bool
+	}{
+		{"priced", func(m *scraper.MessageContent) {}, []string{"1001", "1004", "1005"}, true},
+		{"capacity", func(m *scraper.MessageContent) { m.MinCapacity = 4 }, []string{"1001", "1004", "1005"}, true},
+		{"attributes", func(m *scraper.MessageContent) { m.RequiredAttributes = map[string]string{"pets allowed": "Yes"} }, []string{"1004", "1005"}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(unlistedFixture())
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("unlisted-" + test.name)
+			test.watch(&m)
+			missing := expvar.Get("campsite_metadata_missing").(*expvar.Int).Value()
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			alerts := h.Notifier.Alerts()
+			if len(alerts) != 1 {
+				t.Fatalf("got %d alerts, want 1", len(alerts))
+			}
+			ids := []string{}
+			for _, site := range alerts[0].Sites {
+				ids = append(ids, site.ID)
+				unlisted := site.ID == "1004" || site.ID == "1005"
+				if site.UnknownAttributes != unlisted {
+					t.Errorf("site %s unknown_attributes = %v, want %v", site.ID, site.UnknownAttributes, unlisted)
+				}
+				if want := unlisted && test.wantPriceUnknown; site.PriceUnknown != want {
+					t.Errorf("site %s price_unknown = %v, want %v", site.ID, site.PriceUnknown, want)
+				}
+			}
+			if strings.Join(ids, ",") != strings.Join(test.wantSites, ",") {
+				t.Errorf("alert has sites %v, want %v", ids, test.wantSites)
+			}
+			if n := expvar.Get("campsite_metadata_missing").(*expvar.Int).Value() - missing; n != 2 {
+				t.Errorf("campsite_metadata_missing rose by %d, want 2", n)
+			}
+
+			lastUnlisted, lastCampground, unlistedFetches := -1, -1, 0
+			for i, r := range h.Server.Requests() {
+				switch r {
+				case "/api/camps/campsites/1004", "/api/camps/campsites/1005":
+					lastUnlisted = i
+					unlistedFetches++
+				case "/api/camps/campgrounds/232447":
+					lastCampground = i
+				}
+			}
+			if unlistedFetches != 2 {
+				t.Errorf("fetched the unlisted sites' details %d times, want once each", unlistedFetches)
+			}
+			if lastCampground < lastUnlisted {
+				t.Errorf("campground details not fetched again after the unlisted sites (requests %v)", h.Server.Requests())
+			}
+		})
+	}
+}
+
+// A site whose details fail to load is not dropped by a capacity watch on a
+// guess: it is alerted, marked unknown capacity, and the email says so.
+func TestCapacityDetailFailure(t *testing.T) {
+	f := e2eFixture(true)
+	f.Campgrounds[0].Sites = append(f.Campgrounds[0].Sites, fakerecgov.Site{ID: "1006", Site: "A006", Loop: "A",
+		Type: "STANDARD NONELECTRIC", Nights: map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}, DetailStatus: 500})
+	h := fakerecgov.Start(f)
+	defer h.Close()
+	defer scraper.UseChannels()()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := e2eWatch("capacity-detail-failure")
+	m.MinCapacity, m.NotifyEmail = 4, "camper@example.com"
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	notices := h.Notifier.Notices()
+	if len(notices) != 1 {
+		t.Fatalf("got %d emails, want the alert", len(notices))
+	}
+	for _, line := range strings.Split(notices[0].Plain, "\n") {
+		if strings.Contains(line, "A006") && !strings.Contains(line, "(unknown capacity)") {
+			t.Errorf("site A006 listed as %q, want it marked unknown capacity", line)
+		}
+	}
+	if !strings.Contains(notices[0].Plain, "A006") {
+		t.Errorf("alert %q left out the site whose details failed", notices[0].Plain)
+	}
+	if h.Scheduler.Job(m.Name) != nil {
+		t.Error("watch still scheduled after alerting")
+	}
+}
@@ -0,0 +1,100 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// moreNote tells the reader of a text that n results did not fit.
+func moreNote(n int) string {
+	return fmt.Sprintf(" and %d more, see the email for the full list", n)
+}
+
+// textChunks lays out a text alert for a channel taking messages of at most
+// limit characters: head, then items joined by ", ", then tail, such as a
+// link. It uses as few messages as it can, up to maxParts, and breaks only
+// between items, so neither an item nor tail is ever split across
+// messages. With more than one message each begins "(1/2) " and so on.
+// Items that do not fit in maxParts messages are counted by moreNote at the
+// end of the last, before tail. Only a head or item too long for a message
+// of its own is cut, and then between characters.
+func textChunks(head string, items []string, tail string, limit int, maxParts int) []string {
+	if maxParts < 1 {
+		maxParts = 1
+	}
+	var parts []string
+	for n := 1; n <= maxParts; n++ {
+		var placed int
+		parts, placed = packChunks(head, items, tail, limit, n)
+		if placed == len(items) {
+			break
+		}
+	}
+	if len(parts) > 1 {
+		for i := range parts {
+			parts[i] = fmt.Sprintf("(%d/%d) ", i+1, len(parts)) + parts[i]
+		}
+	}
+	for i, part := range parts {
+		if utf8.RuneCountInString(part) > limit {
+			parts[i] = string([]rune(part)[:limit])
+		}
+	}
+	return parts
+}
+
+// packChunks fills at most n messages for textChunks, leaving room for the
+// "(i/n) " prefixes, and returns them along with how many items they hold.
+func packChunks(head string, items []string, tail string, limit int, n int) ([]string, int) {
+	width := limit
+	if n > 1 {
+		width -= utf8.RuneCountInString(fmt.Sprintf("(%d/%d) ", n, n))
+	}
+	parts := []string{}
+	body, listed, i := head, 0, 0
+	for {
+		last := len(parts) == n-1
+		if last && i < len(items) {
+			// The note is only needed if the rest will not all fit.
+			rest := strings.Join(items[i:], ", ")
+			if listed > 0 {
+				rest = ", " + rest
+			}
+			if utf8.RuneCountInString(body+rest+tail) <= width {
+				return append(parts, body+rest+tail), len(items)
+			}
+		}
+		for ; i < len(items); i++ {
+			sep := ", "
+			if listed == 0 {
+				sep = ""
+			}
+			// The final item needs room for tail after it, and any item in
+			// the last message room for tail and the note counting the
+			// items after it.
+			reserve := ""
+			if i == len(items)-1 {
+				reserve = tail
+			} else if last {
+				reserve = moreNote(len(items)-i-1) + tail
+			}
+			// An item too long for a message of its own goes in anyway, to
+			// be cut, rather than holding up the rest.
+			alone := listed == 0 && body == ""
+			if !alone && utf8.RuneCountInString(body+sep+items[i]+reserve) > width {
+				break
+			}
+			body += sep + items[i]
+			listed++
+		}
+		if i == len(items) || last {
+			if i < len(items) {
+				body += moreNote(len(items) - i)
+			}
+			return append(parts, body+tail), i
+		}
+		parts = append(parts, body)
+		body, listed = "", 0
+	}
+}
@@ -0,0 +1,104 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// chunkItems is n ten-character items, site-00001 and on.
+func chunkItems(n int) []string {
+	items := make([]string, n)
+	for i := range items {
+		items[i] = fmt.Sprintf("site-%05d", i+1)
+	}
+	return items
+}
+
+func TestTextChunks(t *testing.T) {
+	// With head "head: " and tail " http://x/1", five items take
+	// 6+5*10+4*2+11 = 75 characters. moreNote is 44 characters for fewer
+	// than ten items and 45 for up to 99.
+	const five = "head: site-00001, site-00002, site-00003, site-00004, site-00005 http://x/1"
+	tests := []struct {
+		name     string
+		head     string
+		items    []string
+		limit    int
+		maxParts int
+		want     []string
+	}{
+		{"exactly fits", "head: ", chunkItems(5), 75, 2, []string{five}},
+		{"one under", "head: ", chunkItems(5), 74, 2, []string{
+			"(1/2) head: site-00001, site-00002, site-00003, site-00004",
+			"(2/2) site-00005 http://x/1",
+		}},
+		{"folded into one", "head: ", chunkItems(8), 100, 1, []string{
+			"head: site-00001, site-00002, site-00003" + moreNote(5) + " http://x/1",
+		}},
+		{"folded into two", "head: ", chunkItems(20), 100, 2, []string{
+			"(1/2) head: site-00001, site-00002, site-00003, site-00004, site-00005, site-00006, site-00007",
+			"(2/2) site-00008, site-00009, site-00010" + moreNote(10) + " http://x/1",
+		}},
+		{"three parts", "head: ", chunkItems(5), 40, 3, []string{
+			"(1/3) head: site-00001, site-00002",
+			"(2/3) site-00003, site-00004",
+			"(3/3) site-00005 http://x/1",
+		}},
+		{"no items", "head: ", nil, 75, 2, []string{"head:  http://x/1"}},
+		{"nothing fits", "head:", chunkItems(5), 60, 1, []string{"head:" + moreNote(5) + " http://x/1"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := textChunks(test.head, test.items, " http://x/1", test.limit, test.maxParts)
+			for _, part := range got {
+				if n := utf8.RuneCountInString(part); n > test.limit {
+					t.Errorf("part %q is %d characters, over %d", part, n, test.limit)
+				}
+			}
+			if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+				t.Errorf("textChunks =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(test.want, "\n"))
+			}
+		})
+	}
+}
+
+// However the limit falls, no part is over it, no item or link is split
+// and every item is either listed or counted.
+func TestTextChunksBoundaries(t *testing.T) {
+	items := make([]string, 30)
+	for i := range items {
+		items[i] = fmt.Sprintf("Ñandú%03d", i)
+	}
+	const tail = " https://www.recreation.gov/camping/campsites/1001"
+	for limit := 120; limit <= 400; limit++ {
+		for maxParts := 1; maxParts <= 3; maxParts++ {
+			parts := textChunks("Campsites open: ", items, tail, limit, maxParts)
+			if len(parts) > maxParts {
+				t.Fatalf("limit %d: %d parts, over %d", limit, len(parts), maxParts)
+			}
+			joined := strings.Join(parts, "\n")
+			listed := 0
+			for _, item := range items {
+				if strings.Contains(joined, item) {
+					listed++
+				}
+			}
+			if listed < len(items) && !strings.Contains(joined, moreNote(len(items)-listed)) {
+				t.Errorf("limit %d, %d parts: lists %d items without counting the rest", limit, maxParts, listed)
+			}
+			for i, part := range parts {
+				if n := utf8.RuneCountInString(part); n > limit || !utf8.ValidString(part) {
+					t.Fatalf("limit %d: part %d is %d characters or invalid: %q", limit, i, n, part)
+				}
+				if strings.Contains(part, "https://") && !strings.HasSuffix(part, tail) {
+					t.Errorf("limit %d: link split in %q", limit, part)
+				}
+			}
+			if !strings.HasSuffix(parts[len(parts)-1], tail) {
+				t.Errorf("limit %d: last part %q lost the link", limit, parts[len(parts)-1])
+			}
+		}
+	}
+}
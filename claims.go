@@ -0,0 +1,269 @@
+package scraper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+const (
+	// claimPrefix is where claims live in RESULTS_BUCKET, one object per
+	// job and site.
+	claimPrefix = "claims/"
+	// claimTTL is how long a claim holds before someone else may claim the
+	// site. Claims are advisory; nothing stops a second booking.
+	claimTTL = time.Hour
+	// claimLinkTTL is how long the links in an alert stay usable.
+	claimLinkTTL = 24 * time.Hour
+)
+
+// claim is the record stored for a claimed site.
+type claim struct {
+	Job       string    `json:"job"`
+	Site      string    `json:"site"`
+	Claimer   string    `json:"claimer"`
+	ClaimedAt time.Time `json:"claimed_at"`
+}
+
+// claimLink returns the signed "I'm booking this" URL for a site, or "" when
+// CLAIM_URL, CLAIM_SECRET or RESULTS_BUCKET is not configured. page names
+// the alert's results page, if it has one, which is marked when the site
+// is claimed.
+func claimLink(job string, site string, page string) string {
+	base, secret := os.Getenv("CLAIM_URL"), os.Getenv("CLAIM_SECRET")
+	if base == "" || secret == "" || os.Getenv("RESULTS_BUCKET") == "" {
+		return ""
+	}
+	exp := strconv.FormatInt(DefaultScraper.now().Add(claimLinkTTL).Unix(), 10)
+	query := url.Values{
+		"job":  {job},
+		"site": {site},
+		"exp":  {exp},
+		"sig":  {claimSignature(secret, job, site, exp, page)},
+	}
+	if page != "" {
+		query.Set("page", page)
+	}
+	return base + "?" + query.Encode()
+}
+
+// claimSignature signs a claim link's parameters, page included even when
+// it is empty.
+func claimSignature(secret string, job string, site string, exp string, page string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(job + "\n" + site + "\n" + exp + "\n" + page))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ClaimSite is an HTTP Cloud Function behind the claim links in alerts. The
+// first valid claim on a site wins until it expires; later visitors are told
+// who claimed it. A winning claim marks the site on the alert's results
+// page and, for watches with AnnounceClaims, is announced to the watch's
+// recipients. An optional "name" parameter identifies the claimer.
+func ClaimSite(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	job, site, exp, page, sig := q.Get("job"), q.Get("site"), q.Get("exp"), q.Get("page"), q.Get("sig")
+	secret := os.Getenv("CLAIM_SECRET")
+	expected := claimSignature(secret, job, site, exp, page)
+	if secret == "" || !hmac.Equal([]byte(expected), []byte(sig)) {
+		http.Error(w, "invalid claim link", http.StatusUnauthorized)
+		return
+	}
+	expires, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || DefaultScraper.now().After(time.Unix(expires, 0)) {
+		http.Error(w, "this claim link has expired", http.StatusGone)
+		return
+	}
+	claimer := q.Get("name")
+	if claimer == "" {
+		claimer = "someone"
+	}
+
+	c := claim{Job: job, Site: site, Claimer: claimer, ClaimedAt: DefaultScraper.now()}
+	held, err := claimSiteOnce(r.Context(), c)
+	if err != nil {
+		logger.Printf("claim for job %s site %s: %v", job, site, err)
+		http.Error(w, "could not record the claim", http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if held.ClaimedAt.IsZero() {
+		if err := markPageClaimed(r.Context(), page, c); err != nil {
+			logger.Printf("claim for job %s site %s: marking results page: %v", job, site, err)
+		}
+		announceClaim(r.Context(), c)
+		fmt.Fprintf(w, "<p>Site %s is yours to book. Others will see it as claimed for the next hour.</p>", html.EscapeString(site))
+		return
+	}
+	fmt.Fprintf(w, "<p>Site %s was claimed by %s at %s. Pick another site.</p>",
+		html.EscapeString(site), html.EscapeString(held.Claimer), held.ClaimedAt.Format(time.Kitchen))
+}
+
+// claimSiteOnce stores c unless an unexpired claim already exists, in which
+// case that claim is returned instead; a zero claim means c was stored. The
+// create is conditional on the object not existing, and replacing an
+// expired claim is conditional on its generation, so simultaneous claims
+// cannot both win.
+func claimSiteOnce(ctx context.Context, c claim) (claim, error) {
+	client, err := storageClient()
+	if err != nil {
+		return claim{}, err
+	}
+	object := client.Bucket(os.Getenv("RESULTS_BUCKET")).Object(claimPrefix + c.Job + "/" + c.Site + ".json")
+
+	condition := storage.Conditions{DoesNotExist: true}
+	for attempt := 0; attempt < 2; attempt++ {
+		err := writeClaim(ctx, object.If(condition), c)
+		if err == nil {
+			return claim{}, nil
+		}
+		if e, ok := err.(*googleapi.Error); !ok || e.Code != http.StatusPreconditionFailed {
+			return claim{}, err
+		}
+
+		reader, err := object.NewReader(ctx)
+		if err != nil {
+			return claim{}, err
+		}
+		var existing claim
+		err = json.NewDecoder(reader).Decode(&existing)
+		generation := reader.Attrs.Generation
+		reader.Close()
+		if err != nil {
+			return claim{}, err
+		}
+		if DefaultScraper.since(existing.ClaimedAt) < claimTTL {
+			return existing, nil
+		}
+		condition = storage.Conditions{GenerationMatch: generation}
+	}
+	return claim{}, fmt.Errorf("claim for site %s kept changing underneath us", c.Site)
+}
+
+func writeClaim(ctx context.Context, object *storage.ObjectHandle, c claim) error {
+	w := object.NewWriter(ctx)
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(c); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// claimLinkPattern matches the claim link for the site whose escaped query
+// value is %s, which every link ends with as parameters are sorted.
+const claimLinkPattern = `<a href="[^"]*[?;]site=%s">I(?:'|&#39;)m booking this</a>`
+
+// markPageClaimed replaces the claim link for c's site on results page
+// with who claimed it, so later visitors of the page see it taken. The
+// rewrite is conditional on the page's generation, so claims on two sites
+// at once do not undo each other. A page that is gone, or no page, is left
+// alone.
+func markPageClaimed(ctx context.Context, page string, c claim) error {
+	bucket := os.Getenv("PAGES_BUCKET")
+	if page == "" || bucket == "" {
+		return nil
+	}
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	object := client.Bucket(bucket).Object(page)
+	link := regexp.MustCompile(fmt.Sprintf(claimLinkPattern, regexp.QuoteMeta(url.QueryEscape(c.Site))))
+	mark := fmt.Sprintf("<strong>Claimed by %s at %s</strong>", html.EscapeString(c.Claimer), c.ClaimedAt.Format(time.Kitchen))
+	for attempt := 0; attempt < 3; attempt++ {
+		reader, err := object.NewReader(ctx)
+		if err == storage.ErrObjectNotExist {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		content, err := ioutil.ReadAll(reader)
+		generation := reader.Attrs.Generation
+		reader.Close()
+		if err != nil {
+			return err
+		}
+		marked := link.ReplaceAllLiteralString(string(content), mark)
+		if marked == string(content) {
+			return nil
+		}
+		err = writePage(ctx, object.If(storage.Conditions{GenerationMatch: generation}), marked)
+		if e, ok := err.(*googleapi.Error); !ok || e.Code != http.StatusPreconditionFailed {
+			return err
+		}
+	}
+	return fmt.Errorf("page %s kept changing underneath us", page)
+}
+
+// announceClaim tells the recipients of c's watch, by email and on its
+// Slack webhooks, that c's site is taken, when the watch sets
+// AnnounceClaims. Failures are logged; the claim stands either way.
+func announceClaim(ctx context.Context, c claim) {
+	m, ok := claimedWatch(ctx, c.Job)
+	if !ok || !m.AnnounceClaims {
+		return
+	}
+	if err := m.resolveContact(ctx); err != nil {
+		logger.Printf("claim for job %s: %v", c.Job, err)
+	}
+	place := m.Campground
+	if place == "" {
+		place = strings.Join(m.Campgrounds, ", ")
+	}
+	text := fmt.Sprintf("%s is booking site %s at %s, claimed at %s. Pick another site.",
+		c.Claimer, c.Site, place, c.ClaimedAt.Format(time.Kitchen))
+	subject := fmt.Sprintf("Site %s is claimed", c.Site)
+	if err := sendNoticeTo(ctx, m.recipient(), subject, text, "<p>"+html.EscapeString(text)+"</p>"); err != nil {
+		logger.Printf("claim for job %s: announcing by email: %v", c.Job, err)
+	}
+	webhooks := []string{}
+	if webhook := os.Getenv("SLACK_WEBHOOK_URL"); webhook != "" {
+		webhooks = append(webhooks, webhook)
+	}
+	if m.slackWebhook != "" && m.slackWebhook != os.Getenv("SLACK_WEBHOOK_URL") {
+		webhooks = append(webhooks, m.slackWebhook)
+	}
+	for _, webhook := range webhooks {
+		if err := postSlack(ctx, webhook, slackMessage{Text: text}); err != nil {
+			logger.Printf("claim for job %s: announcing on Slack: %v", c.Job, err)
+		}
+	}
+}
+
+// claimedWatch looks up the watch job names, from its job's payload or the
+// registry.
+func claimedWatch(ctx context.Context, job string) (MessageContent, bool) {
+	name, err := ParseJobName(job)
+	if err != nil {
+		return MessageContent{}, false
+	}
+	c, err := newWatchScheduler()
+	if err != nil {
+		logger.Printf("claim for job %s: %v", job, err)
+		return MessageContent{}, false
+	}
+	j, err := c.GetJob(ctx, name.String())
+	if err != nil {
+		logger.Printf("claim for job %s: looking up the watch: %v", job, err)
+		return MessageContent{}, false
+	}
+	m, _, ok := watchFromJob(ctx, newWatchStore(activeConfig), j.GetPubsubTarget().GetData())
+	return m, ok
+}
@@ -0,0 +1,274 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+const claimJob = "projects/p/locations/l/jobs/watch-232447-20270714-20270716"
+
+// useClaims turns claims on against a fakeGCS, with the clock at noon on
+// June 1, 2027.
+func useClaims(t *testing.T) (*fakeGCS, *testClock) {
+	t.Helper()
+	gcs := useFakeGCS(t, "state")
+	t.Setenv("CLAIM_URL", "https://claims.example/claim")
+	t.Setenv("CLAIM_SECRET", "s3cret")
+	return gcs, useClock(t, time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+}
+
+// followClaim follows link as name and returns the response.
+func followClaim(t *testing.T, link string, name string) *httptest.ResponseRecorder {
+	t.Helper()
+	u, err := url.Parse(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if name != "" {
+		q.Set("name", name)
+	}
+	w := httptest.NewRecorder()
+	ClaimSite(w, httptest.NewRequest(http.MethodGet, "/claim?"+q.Encode(), nil))
+	return w
+}
+
+func TestClaimLink(t *testing.T) {
+	_, clock := useClaims(t)
+	link := claimLink(claimJob, "1001", "")
+	u, err := url.Parse(link)
+	if err != nil || !strings.HasPrefix(link, "https://claims.example/claim?") {
+		t.Fatalf("claimLink = %q, %v", link, err)
+	}
+	q := u.Query()
+	if q.Get("job") != claimJob || q.Get("site") != "1001" || q.Get("page") != "" {
+		t.Errorf("link parameters %v", q)
+	}
+	if exp := q.Get("exp"); exp != strconv.FormatInt(clock.at.Add(claimLinkTTL).Unix(), 10) {
+		t.Errorf("link expires at %s, want a day from now", exp)
+	}
+	if q.Get("sig") != claimSignature("s3cret", claimJob, "1001", q.Get("exp"), "") {
+		t.Errorf("link signature %s does not verify", q.Get("sig"))
+	}
+	if paged := claimLink(claimJob, "1001", "results/abc.html"); !strings.Contains(paged, "page=results%2Fabc.html") || paged == link {
+		t.Errorf("paged link %q", paged)
+	}
+
+	for _, unset := range []string{"CLAIM_URL", "CLAIM_SECRET", "RESULTS_BUCKET"} {
+		t.Run("without "+unset, func(t *testing.T) {
+			t.Setenv(unset, "")
+			if link := claimLink(claimJob, "1001", ""); link != "" {
+				t.Errorf("claimLink = %q, want none", link)
+			}
+		})
+	}
+}
+
+// Every signed parameter is checked, and so is the link's expiry.
+func TestClaimSiteVerifies(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(q url.Values)
+		after  time.Duration
+		want   int
+	}{
+		{name: "valid", want: http.StatusOK},
+		{name: "another site", change: func(q url.Values) { q.Set("site", "1002") }, want: http.StatusUnauthorized},
+		{name: "another job", change: func(q url.Values) { q.Set("job", strings.Replace(claimJob, "20270716", "20270717", 1)) }, want: http.StatusUnauthorized},
+		{name: "later expiry", change: func(q url.Values) { q.Set("exp", "1911851200") }, want: http.StatusUnauthorized},
+		{name: "another page", change: func(q url.Values) { q.Set("page", "results/other.html") }, want: http.StatusUnauthorized},
+		{name: "page dropped", change: func(q url.Values) { q.Del("page") }, want: http.StatusUnauthorized},
+		{name: "no signature", change: func(q url.Values) { q.Del("sig") }, want: http.StatusUnauthorized},
+		{name: "just in time", after: claimLinkTTL, want: http.StatusOK},
+		{name: "expired", after: claimLinkTTL + time.Second, want: http.StatusGone},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, clock := useClaims(t)
+			u, _ := url.Parse(claimLink(claimJob, "1001", "results/abc.html"))
+			q := u.Query()
+			if test.change != nil {
+				test.change(q)
+			}
+			u.RawQuery = q.Encode()
+			clock.at = clock.at.Add(test.after)
+			if w := followClaim(t, u.String(), "Ana"); w.Code != test.want {
+				t.Errorf("status %d, want %d: %s", w.Code, test.want, w.Body)
+			}
+		})
+	}
+
+	t.Run("without a secret", func(t *testing.T) {
+		useClaims(t)
+		link := claimLink(claimJob, "1001", "")
+		t.Setenv("CLAIM_SECRET", "")
+		if w := followClaim(t, link, "Ana"); w.Code != http.StatusUnauthorized {
+			t.Errorf("status %d, want %d", w.Code, http.StatusUnauthorized)
+		}
+	})
+}
+
+// However many follow a link at once, the conditional create lets one of
+// them win, and everyone else is told who. Once the claim expires the next
+// visitor takes the site over. (The storage client rewrites its base path on
+// every upload to an emulator, so -race flags this test inside the library.)
+func TestClaimSiteFirstWins(t *testing.T) {
+	gcs, clock := useClaims(t)
+	link := claimLink(claimJob, "1001", "")
+	names := []string{"Ana", "Ben", "Cy", "Dee", "Eli", "Flo"}
+	bodies := make([]string, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func(i int, name string) {
+			defer wg.Done()
+			bodies[i] = followClaim(t, link, name).Body.String()
+		}(i, name)
+	}
+	wg.Wait()
+
+	winners := []string{}
+	for i, body := range bodies {
+		if strings.Contains(body, "is yours to book") {
+			winners = append(winners, names[i])
+		}
+	}
+	if len(winners) != 1 {
+		t.Fatalf("winners %v, want exactly one:\n%s", winners, strings.Join(bodies, "\n"))
+	}
+	for i, body := range bodies {
+		if names[i] != winners[0] && !strings.Contains(body, "claimed by "+winners[0]) {
+			t.Errorf("%s was told %q, want who claimed the site", names[i], body)
+		}
+	}
+
+	clock.at = clock.at.Add(claimTTL + time.Minute)
+	if body := followClaim(t, link, "Gus").Body.String(); !strings.Contains(body, "is yours to book") {
+		t.Errorf("after the claim expired: %q", body)
+	}
+	var stored claim
+	if err := json.Unmarshal([]byte(gcs.objects["state/"+claimPrefix+claimJob+"/1001.json"]), &stored); err != nil || stored.Claimer != "Gus" {
+		t.Errorf("stored claim %+v, %v, want Gus's", stored, err)
+	}
+}
+
+// A winning claim marks its site on the alert's results page, and leaves
+// the other sites' links alone until they are claimed too.
+func TestClaimSiteMarksPage(t *testing.T) {
+	gcs, _ := useClaims(t)
+	t.Setenv("PAGES_BUCKET", "pages")
+	slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer slack.Close()
+	var email string
+	oldNotice, oldNotifiers := sendNoticeTo, notifiersFor
+	sendNoticeTo = func(ctx context.Context, to *mail.Email, subject string, plain string, html string) error {
+		email = html
+		return nil
+	}
+	notifiersFor = func(a alert) []notifier {
+		return []notifier{emailNotifier{to: mail.NewEmail("", "group@example.com")}, slackNotifier{webhook: slack.URL}}
+	}
+	defer func() { sendNoticeTo, notifiersFor = oldNotice, oldNotifiers }()
+	a := goldenAlert()
+	if err := sendAlert(context.Background(), a); err != nil {
+		t.Fatal(err)
+	}
+	links := map[string]string{}
+	for _, m := range regexp.MustCompile(`<a href="([^"]*)">I'm booking this</a>`).FindAllStringSubmatch(email, -1) {
+		link := strings.Replace(m[1], "&amp;", "&", -1)
+		u, _ := url.Parse(link)
+		links[u.Query().Get("site")] = link
+	}
+	pages := gcs.names("pages", resultsPagePrefix)
+	if len(links) != 2 || len(pages) != 1 {
+		t.Fatalf("claim links %v and pages %v, want two links to one page", links, pages)
+	}
+	page := func() string { return gcs.objects["pages/"+pages[0]] }
+	if !strings.Contains(links["1001"], "page="+url.QueryEscape(pages[0])) || strings.Count(page(), "booking this") != 2 {
+		t.Fatalf("links %v do not name page %s:\n%s", links, pages[0], page())
+	}
+
+	followClaim(t, links["1001"], "Ana <3")
+	if !strings.Contains(page(), "<strong>Claimed by Ana &lt;3 at 12:00PM</strong>") || strings.Count(page(), "booking this") != 1 {
+		t.Errorf("page after claiming 1001:\n%s", page())
+	}
+	followClaim(t, links["1002"], "Ben")
+	if !strings.Contains(page(), "Claimed by Ben") || !strings.Contains(page(), "Claimed by Ana") || strings.Contains(page(), "booking this") {
+		t.Errorf("page after claiming 1002:\n%s", page())
+	}
+	if gcs.acls["pages/"+pages[0]] != "publicRead" {
+		t.Errorf("marked page ACL %q, want it still public", gcs.acls["pages/"+pages[0]])
+	}
+}
+
+// oneJobScheduler serves job to GetJob; the watch's other calls are not
+// made by claims.
+type oneJobScheduler struct {
+	Scheduler
+	job *schedulerpb.Job
+}
+
+func (s oneJobScheduler) GetJob(ctx context.Context, name string) (*schedulerpb.Job, error) {
+	return s.job, nil
+}
+
+// With AnnounceClaims, the winning claim alone is announced by email and on
+// Slack.
+func TestClaimSiteAnnounces(t *testing.T) {
+	for _, announce := range []bool{true, false} {
+		t.Run(map[bool]string{true: "announced", false: "quiet"}[announce], func(t *testing.T) {
+			useClaims(t)
+			posted := []string{}
+			slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var message slackMessage
+				json.NewDecoder(r.Body).Decode(&message)
+				posted = append(posted, message.Text)
+			}))
+			defer slack.Close()
+			t.Setenv("SLACK_WEBHOOK_URL", slack.URL)
+			notices := []string{}
+			oldNotice, oldScheduler := sendNoticeTo, newWatchScheduler
+			sendNoticeTo = func(ctx context.Context, to *mail.Email, subject string, plain string, html string) error {
+				notices = append(notices, to.Address+": "+subject+": "+plain)
+				return nil
+			}
+			data, _ := json.Marshal(MessageContent{Name: claimJob, Campground: "232447", Arrival: "2027-07-14", Departure: "2027-07-16",
+				NotifyEmail: "group@example.com", AnnounceClaims: announce})
+			newWatchScheduler = func() (Scheduler, error) {
+				return oneJobScheduler{job: &schedulerpb.Job{Name: claimJob,
+					Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{Data: data}}}}, nil
+			}
+			defer func() { sendNoticeTo, newWatchScheduler = oldNotice, oldScheduler }()
+
+			link := claimLink(claimJob, "1001", "")
+			followClaim(t, link, "Ana")
+			followClaim(t, link, "Ben")
+
+			want := "Ana is booking site 1001 at 232447, claimed at 12:00PM. Pick another site."
+			if !announce {
+				if len(notices) != 0 || len(posted) != 0 {
+					t.Errorf("announced %v and %v without AnnounceClaims", notices, posted)
+				}
+				return
+			}
+			if len(notices) != 1 || notices[0] != "group@example.com: Site 1001 is claimed: "+want {
+				t.Errorf("emailed %q, want one announcement to the group", notices)
+			}
+			if len(posted) != 1 || posted[0] != want {
+				t.Errorf("posted %q to Slack, want %q", posted, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	scheduler "cloud.google.com/go/scheduler/apiv1"
+	"cloud.google.com/go/storage"
+)
+
+// clientRetryDelay is how long a failed client creation is remembered before
+// the next call tries again, so a burst of invocations during an outage does
+// not each wait on a doomed dial.
+const clientRetryDelay = 10 * time.Second
+
+// processClients holds the GCP clients shared by every invocation in the
+// process. Each is created on first use and outlives the context of the
+// invocation that created it.
+type processClients struct {
+	mu sync.Mutex
+
+	scheduler       *scheduler.CloudSchedulerClient
+	schedulerErr    error
+	schedulerFailed time.Time
+
+	storage       *storage.Client
+	storageErr    error
+	storageFailed time.Time
+
+	// pubsub holds one client per project and topics one handle per
+	// project/topic.
+	pubsub map[string]*pubsub.Client
+	topics map[string]*pubsub.Topic
+}
+
+var clients = &processClients{}
+
+// recentFailure returns err when it happened less than clientRetryDelay ago.
+func recentFailure(err error, at time.Time) error {
+	if err != nil && DefaultScraper.since(at) < clientRetryDelay {
+		return err
+	}
+	return nil
+}
+
+// schedulerClient returns the process-wide Cloud Scheduler client, creating
+// it on first use. A failed creation is retried once clientRetryDelay has
+// passed.
+func schedulerClient() (*scheduler.CloudSchedulerClient, error) {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	if clients.scheduler != nil {
+		return clients.scheduler, nil
+	}
+	if err := recentFailure(clients.schedulerErr, clients.schedulerFailed); err != nil {
+		return nil, err
+	}
+	c, err := scheduler.NewCloudSchedulerClient(context.Background())
+	if err != nil {
+		clients.schedulerErr = fmt.Errorf("scheduler.NewCloudSchedulerClient: %v", err)
+		clients.schedulerFailed = DefaultScraper.now()
+		return nil, clients.schedulerErr
+	}
+	clients.scheduler, clients.schedulerErr = c, nil
+	return c, nil
+}
+
+// storageClient returns the process-wide Cloud Storage client, on the same
+// terms as schedulerClient.
+func storageClient() (*storage.Client, error) {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	if clients.storage != nil {
+		return clients.storage, nil
+	}
+	if err := recentFailure(clients.storageErr, clients.storageFailed); err != nil {
+		return nil, err
+	}
+	c, err := storage.NewClient(context.Background())
+	if err != nil {
+		clients.storageErr = fmt.Errorf("storage.NewClient: %v", err)
+		clients.storageFailed = DefaultScraper.now()
+		return nil, clients.storageErr
+	}
+	clients.storage, clients.storageErr = c, nil
+	return c, nil
+}
+
+// sharedTopic returns the process-wide handle for a topic, creating the
+// project's Pub/Sub client on first use. Failures are not remembered: topics
+// are only published to after a scrape, well spaced out.
+func sharedTopic(project string, topicID string) (*pubsub.Topic, error) {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	key := project + "/" + topicID
+	if t, ok := clients.topics[key]; ok {
+		return t, nil
+	}
+	client, ok := clients.pubsub[project]
+	if !ok {
+		var err error
+		client, err = pubsub.NewClient(context.Background(), project)
+		if err != nil {
+			return nil, fmt.Errorf("pubsub.NewClient: %v", err)
+		}
+		if clients.pubsub == nil {
+			clients.pubsub = map[string]*pubsub.Client{}
+			clients.topics = map[string]*pubsub.Topic{}
+		}
+		clients.pubsub[project] = client
+	}
+	t := client.Topic(topicID)
+	clients.topics[key] = t
+	return t, nil
+}
+
+// Shutdown flushes pending publishes and closes every shared client. The
+// next call needing one creates it afresh, so tests can call Shutdown
+// between cases.
+func Shutdown() error {
+	clients.mu.Lock()
+	defer clients.mu.Unlock()
+	errs := &MultiError{}
+	for _, t := range clients.topics {
+		t.Stop()
+	}
+	for _, c := range clients.pubsub {
+		errs.Add(c.Close())
+	}
+	if clients.scheduler != nil {
+		errs.Add(clients.scheduler.Close())
+	}
+	if clients.storage != nil {
+		errs.Add(clients.storage.Close())
+	}
+	clients.scheduler, clients.schedulerErr = nil, nil
+	clients.storage, clients.storageErr = nil, nil
+	clients.pubsub, clients.topics = nil, nil
+	return errs.Err()
+}
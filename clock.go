@@ -0,0 +1,15 @@
+package scraper
+
+import "time"
+
+// Clock is the source of the current time for a Scraper, and through
+// DefaultScraper for every time-dependent decision in the package, so tests
+// can substitute a fixed or controllable time.
+type Clock interface {
+	Now() time.Time
+	Since(t time.Time) time.Duration
+}
+
+// Now is the current time by DefaultScraper's clock, for callers such as
+// the CLI that compute times the package then compares against its own.
+func Now() time.Time { return DefaultScraper.now() }
@@ -0,0 +1,200 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// testClock is a Clock that only moves when told to.
+type testClock struct{ at time.Time }
+
+func (c *testClock) Now() time.Time                  { return c.at }
+func (c *testClock) Since(t time.Time) time.Duration { return c.at.Sub(t) }
+
+// useClock makes DefaultScraper's clock read now until the test ends.
+func useClock(t *testing.T, now time.Time) *testClock {
+	c := &testClock{at: now}
+	s, old := DefaultScraper, DefaultScraper.Clock
+	s.Clock = c
+	t.Cleanup(func() { s.Clock = old })
+	return c
+}
+
+func TestScraperClock(t *testing.T) {
+	c := &testClock{at: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewScraper(nil, core.RetryPolicy{})
+	s.Clock = c
+	s.Cache.Set("232447/2027-07", core.Campground{})
+	if _, ok := s.Cache.Get("232447/2027-07"); !ok {
+		t.Fatal("month missing straight after caching it")
+	}
+	c.at = c.at.Add(core.DefaultCacheTTL)
+	if _, ok := s.Cache.Get("232447/2027-07"); ok {
+		t.Error("month still cached after the TTL passed on the Scraper's clock")
+	}
+	if got := s.Provider().Clock.Now(); !got.Equal(c.at) {
+		t.Errorf("provider clock reads %v, want %v", got, c.at)
+	}
+}
+
+// skewClock answers Since from its own offset rather than Now, so a test can
+// tell which of the two a caller used.
+type skewClock struct {
+	testClock
+	skew time.Duration
+}
+
+func (c *skewClock) Since(t time.Time) time.Duration { return c.at.Sub(t) + c.skew }
+
+func TestScraperSinceUsesClock(t *testing.T) {
+	at := time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)
+	s := NewScraper(nil, core.RetryPolicy{})
+	s.Clock = &skewClock{testClock{at}, time.Hour}
+	if got := s.since(at.Add(-time.Minute)); got != time.Hour+time.Minute {
+		t.Errorf("since = %v, want the clock's own %v", got, time.Hour+time.Minute)
+	}
+}
+
+// The package's time-dependent decisions go by DefaultScraper's Clock, with
+// no other clock to set.
+func TestScraperClockDrivesDecisions(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	c := &testClock{at: time.Date(2027, 7, 14, 22, 30, 0, 0, la)}
+	s := NewScraper(nil, core.RetryPolicy{})
+	s.Clock = c
+	saved := DefaultScraper
+	DefaultScraper = s
+	defer func() { DefaultScraper = saved }()
+	store := &MemoryStore{}
+	old := deliveryStore
+	deliveryStore = store
+	defer func() { deliveryStore = old }()
+	m := MessageContent{Name: "clocked", Campground: "232447", Arrival: "2027-07-14", Departure: "2027-07-16",
+		TimeZone: "America/Los_Angeles", QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}
+
+	if m.expired() {
+		t.Error("expired on the evening of arrival")
+	}
+	if _, held, _, err := gateDelivery(context.Background(), m, &alert{Sites: []string{"1001"}}); err != nil || !held {
+		t.Errorf("gateDelivery at 22:30 held = %v, %v, want held", held, err)
+	}
+	c.at = time.Date(2027, 7, 15, 7, 30, 0, 0, la)
+	if !m.expired() {
+		t.Error("not expired the morning after arrival")
+	}
+	if _, held, _, err := gateDelivery(context.Background(), m, &alert{Sites: []string{"1001"}}); err != nil || held {
+		t.Errorf("gateDelivery at 07:30 held = %v, %v, want sent", held, err)
+	}
+	if got := Now(); !got.Equal(c.at) {
+		t.Errorf("Now() = %v, want %v", got, c.at)
+	}
+}
+
+func TestExpired(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	stay := MessageContent{Campground: "232447", Arrival: "2027-07-14", Departure: "2027-07-16"}
+	tests := []struct {
+		name  string
+		watch func(m *MessageContent)
+		now   time.Time
+		want  bool
+	}{
+		{"before arrival", func(m *MessageContent) {}, time.Date(2027, 7, 13, 12, 0, 0, 0, la), false},
+		{"late on arrival day", func(m *MessageContent) {}, time.Date(2027, 7, 14, 23, 59, 0, 0, la), false},
+		{"day after arrival", func(m *MessageContent) {}, time.Date(2027, 7, 15, 0, 0, 0, 0, la), true},
+		{"UTC midnight is still arrival day in the west", func(m *MessageContent) {}, time.Date(2027, 7, 15, 1, 0, 0, 0, time.UTC), false},
+		{"eastern watch after its arrival day", func(m *MessageContent) { m.TimeZone = "America/New_York" }, time.Date(2027, 7, 15, 5, 0, 0, 0, time.UTC), true},
+		{"until departure, during the stay", func(m *MessageContent) { m.ExpireAfter = "departure" }, time.Date(2027, 7, 15, 12, 0, 0, 0, la), false},
+		{"until departure, after it", func(m *MessageContent) { m.ExpireAfter = "departure" }, time.Date(2027, 7, 17, 0, 0, 0, 0, la), true},
+		{"window with a stay left", func(m *MessageContent) {
+			m.Arrival, m.Departure, m.WindowStart, m.WindowEnd, m.Nights = "", "", "2027-07-01", "2027-07-31", 2
+		}, time.Date(2027, 7, 29, 12, 0, 0, 0, la), false},
+		{"window past its last start", func(m *MessageContent) {
+			m.Arrival, m.Departure, m.WindowStart, m.WindowEnd, m.Nights = "", "", "2027-07-01", "2027-07-31", 2
+		}, time.Date(2027, 7, 30, 0, 0, 0, 0, la), true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useClock(t, test.now)
+			m := stay
+			test.watch(&m)
+			if got := m.expired(); got != test.want {
+				at, _ := m.expiresAt()
+				t.Errorf("expired at %v = %v, want %v (expires %v)", test.now, got, test.want, at)
+			}
+		})
+	}
+}
+
+func TestGateDeliveryQuietHours(t *testing.T) {
+	la, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	store := &MemoryStore{}
+	old := deliveryStore
+	deliveryStore = store
+	defer func() { deliveryStore = old }()
+	c := useClock(t, time.Date(2027, 6, 1, 22, 30, 0, 0, la))
+	m := MessageContent{Name: "quiet", TimeZone: "America/Los_Angeles", QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}
+
+	steps := []struct {
+		at       time.Time
+		sites    []string
+		wantHeld bool
+		// wantSites is what the alert carries when it goes out.
+		wantSites []string
+	}{
+		{time.Date(2027, 6, 1, 22, 30, 0, 0, la), []string{"1001"}, true, nil},
+		{time.Date(2027, 6, 2, 6, 59, 0, 0, la), []string{"1002"}, true, nil},
+		{time.Date(2027, 6, 2, 7, 0, 0, 0, la), []string{"1002"}, false, []string{"1002"}},
+	}
+	for i, step := range steps {
+		c.at = step.at
+		a := alert{Sites: step.sites}
+		state, held, capped, err := gateDelivery(context.Background(), m, &a)
+		if err != nil || capped {
+			t.Fatalf("step %d: gateDelivery = %v, capped %v", i, err, capped)
+		}
+		if held != step.wantHeld {
+			t.Fatalf("step %d at %s: held = %v, want %v", i, step.at.Format("15:04"), held, step.wantHeld)
+		}
+		if held {
+			continue
+		}
+		if strings.Join(a.Sites, ",") != strings.Join(step.wantSites, ",") {
+			t.Errorf("step %d: alert sites %v, want %v", i, a.Sites, step.wantSites)
+		}
+		if a.Held == nil || a.Held.Runs != 2 || strings.Join(a.Held.Sites, ",") != "1001,1002" {
+			t.Errorf("step %d: digest %+v, want both held runs", i, a.Held)
+		}
+		recordDelivery(context.Background(), m, state)
+	}
+	if state, _ := store.DeliveryState(context.Background(), m.Name); state.Held != nil || state.Sent != 1 {
+		t.Errorf("delivery state after the digest went out: %+v", state)
+	}
+
+	// Sites held overnight and gone by morning are not alerted.
+	c.at = time.Date(2027, 6, 2, 23, 0, 0, 0, la)
+	if _, held, _, _ := gateDelivery(context.Background(), m, &alert{Sites: []string{"1003"}}); !held {
+		t.Fatal("a find during quiet hours was not held")
+	}
+	c.at = time.Date(2027, 6, 3, 7, 30, 0, 0, la)
+	a := alert{}
+	if _, held, capped, err := gateDelivery(context.Background(), m, &a); err != nil || held || capped || len(a.Sites) != 0 || a.Held != nil {
+		t.Errorf("after quiet hours with nothing open: held %v, capped %v, %v, alert %+v, want nothing to send", held, capped, err, a)
+	}
+	if state, _ := store.DeliveryState(context.Background(), m.Name); state.Held != nil {
+		t.Errorf("digest %+v kept after its sites were gone", state.Held)
+	}
+}
@@ -0,0 +1,983 @@
+// Command campfinder manages a camp_finder deployment from the command line.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+const usage = `usage: campfinder <command> [flags]
+
+commands:
+  bootstrap       create or verify the GCP resources a deployment needs
+  check           check a campground's availability now, without deploying anything
+  backfill        seed the scrape history with a campground's past months
+  tonight         watch a campground for a site tonight until a cutoff hour
+  release         scan in a burst around the moment a stay's dates are released
+  watch create    create a watch for fixed dates from flags
+  watch list      list the watches in the configured project
+  watch update    replace a registered watch's campground, dates and recipient
+  watch pause     stop a registered watch from scanning
+  watch rehearse  send a labelled test alert through a watch's channels
+  watch verify    report watches whose payloads no longer validate
+  watch notifications
+                  list the archived notifications for a watch
+  watch diagnose  write a redacted diagnostic bundle for a watch
+  contact set     create or change a named contact watches can alert
+  contact list    list an owner's contacts
+  contact delete  delete a contact, refused while watches use it unless --cascade
+  replay          diff alerts for stored snapshots against a golden set
+  control         show or change the operator pause flag and blocklist
+
+examples:
+  campfinder check --campground 232447 --arrival 2027-04-10 --nights 2 --site-type "tent only"
+  campfinder bootstrap --project camp-finder-258618 --region us-west2
+  campfinder backfill --campground 232447 --months 6
+  campfinder tonight --campground 232447 --phone +14155550100
+  campfinder release --campground 232447 --arrival 2027-04-10 --departure 2027-04-12
+  campfinder watch create --campground 232447 --arrival 2027-04-10 --departure 2027-04-12
+  campfinder contact set --name mom --email mom@example.com --phone +14155550100
+  campfinder watch create --campground 232447 --arrival 2027-04-10 --departure 2027-04-12 --contact mom
+  campfinder watch rehearse my-yosemite-watch
+  campfinder watch verify --migrate
+  campfinder control pause --reason "recreation.gov asked us to back off"
+  campfinder replay --snapshots ./snapshots --watches watches.json --golden golden.json
+`
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command in args, which exclude the program name, writing
+// to stdout and stderr, and returns its exit status.
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "bootstrap":
+		return runBootstrap(args[1:], stdout, stderr)
+	case "check":
+		return runCheck(args[1:], stdout, stderr)
+	case "backfill":
+		return runBackfill(args[1:], stdout, stderr)
+	case "tonight":
+		return runTonight(args[1:], stdout, stderr)
+	case "release":
+		return runRelease(args[1:], stdout, stderr)
+	case "watch":
+		return runWatch(args[1:], stdout, stderr)
+	case "contact":
+		return runContact(args[1:], stdout, stderr)
+	case "replay":
+		return runReplay(args[1:], stdout, stderr)
+	case "control":
+		return runControl(args[1:], stdout, stderr)
+	default:
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+}
+
+// parseFlags parses args into fs, failing with the status flag.ExitOnError
+// would exit with: 0 after -h, 2 for anything else.
+func parseFlags(fs *flag.FlagSet, args []string) (int, bool) {
+	switch err := fs.Parse(args); err {
+	case nil:
+		return 0, true
+	case flag.ErrHelp:
+		return 0, false
+	default:
+		return 2, false
+	}
+}
+
+func runBootstrap(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("bootstrap", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg := scraper.BootstrapConfig{}
+	fs.StringVar(&cfg.ProjectID, "project", "", "GCP project ID (required)")
+	fs.StringVar(&cfg.Region, "region", "", "Cloud Scheduler location, e.g. us-west2 (required)")
+	fs.StringVar(&cfg.Topic, "topic", "", "topic the scrape function is triggered by (default WATCH_TOPIC)")
+	fs.StringVar(&cfg.ResultsTopic, "results-topic", "", "results topic to create, if any")
+	fs.StringVar(&cfg.StatsTopic, "stats-topic", "", "stats topic to create, if any")
+	fs.StringVar(&cfg.ResultsBucket, "results-bucket", "", "private bucket for watch state (RESULTS_BUCKET), if any")
+	fs.StringVar(&cfg.PagesBucket, "pages-bucket", "", "bucket for hosted results pages (PAGES_BUCKET), if any")
+	fs.Int64Var(&cfg.PagesDays, "pages-days", 7, "days before hosted results pages expire")
+	fs.StringVar(&cfg.ArchiveBucket, "archive-bucket", "", "bucket for the notification archive, if any")
+	fs.Int64Var(&cfg.ArchiveDays, "archive-days", 90, "days to keep notification archive records")
+	fs.StringVar(&cfg.HistoryDataset, "history-dataset", "", "BigQuery dataset holding the scrape history table, if any")
+	fs.StringVar(&cfg.HistoryTable, "history-table", "", "BigQuery table to create for scrape history, if any")
+	fs.StringVar(&cfg.DigestTopic, "digest-topic", "campfinder-digest", "topic DailyDigest is triggered by, created with its morning job; empty skips both")
+	fs.StringVar(&cfg.DigestSchedule, "digest-schedule", "0 7 * * *", "cron schedule of the daily digest job")
+	fs.StringVar(&cfg.DigestTimeZone, "digest-time-zone", "Etc/UTC", "time zone of the digest schedule")
+	fs.StringVar(&cfg.ServiceAccount, "service-account", "", "function service account (default PROJECT@appspot.gserviceaccount.com)")
+	timeout := fs.Duration("timeout", 2*time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+
+	if cfg.ProjectID == "" || cfg.Region == "" {
+		fmt.Fprintln(stderr, "bootstrap: --project and --region are required")
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	exit := 0
+	for _, r := range scraper.Bootstrap(ctx, cfg) {
+		line := fmt.Sprintf("%-9s %s", r.Status, r.Resource)
+		if r.Detail != "" {
+			line += ": " + r.Detail
+		}
+		fmt.Fprintln(stdout, line)
+		if r.Status == scraper.BootstrapFailed {
+			exit = 1
+		}
+	}
+	return exit
+}
+
+// checkSite is one available campsite in check's --json output.
+type checkSite struct {
+	ID   string `json:"id"`
+	Site string `json:"site,omitempty"`
+	Type string `json:"type,omitempty"`
+	// Remaining is the units left every night, for group and overflow
+	// sites.
+	Remaining int `json:"remaining,omitempty"`
+	// Price is what the stay costs at the site, such as "$70.00", or
+	// empty when its fees are unknown.
+	Price string `json:"price,omitempty"`
+	URL   string `json:"url"`
+}
+
+// runCheck scrapes recreation.gov directly and prints the available sites.
+// It exits 0 when something is available, 1 when nothing is and 3 when the
+// check itself failed, so scripts can tell the two apart.
+func runCheck(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprint(stderr, `usage: campfinder check [flags]
+
+Checks a campground for sites available every night of a stay, straight from
+recreation.gov. Nothing is deployed, scheduled or sent. Exits 0 when sites are
+available, 1 when none are and 3 when the check fails.
+
+--odds also rates the chance of a site opening up from the scrape history in
+BQ_DATASET and BQ_TABLE, or says the history is too thin to tell. With --json
+the output is then an object with "sites" and "odds".
+
+example:
+  campfinder check --campground 232447 --arrival 2027-04-10 --nights 2 --site-type "tent only"
+  campfinder check --campground 232447 --arrival 2027-04-10 --nights 2 --record-dir ./fixtures
+  campfinder check --campground 232447 --arrival 2027-04-10 --nights 2 --replay-dir ./fixtures
+  campfinder check --campground 232447 --arrival 2027-04-10 --nights 2 --odds
+
+flags:
+`)
+		fs.PrintDefaults()
+	}
+	campground := fs.String("campground", "", "recreation.gov campground ID (required)")
+	arrival := fs.String("arrival", "", "arrival date, YYYY-MM-DD (required)")
+	departure := fs.String("departure", "", "departure date, YYYY-MM-DD")
+	nights := fs.Int("nights", 0, "length of the stay, instead of --departure")
+	siteTypes := fs.String("site-type", "", "only sites whose type matches one of these comma-separated types")
+	asJSON := fs.Bool("json", false, "print the sites as JSON")
+	locale := fs.String("locale", "", "language to write the stay's dates in: en, es, fr or de (default en)")
+	replayDir := fs.String("replay-dir", "", "read availability from <campground>/<YYYY-MM>.json files here instead of recreation.gov")
+	recordDir := fs.String("record-dir", "", "save each month fetched from recreation.gov here, for --replay-dir")
+	withOdds := fs.Bool("odds", false, "also estimate the odds of a site opening up from scrape history")
+	fs.Int64Var(&scraper.DefaultScraper.MaxResponseBytes, "max-response-bytes", scraper.DefaultScraper.MaxResponseBytes,
+		"largest recreation.gov response to read, for very large campgrounds (default REQUEST_MAX_BYTES, else 8 MiB)")
+	timeout := fs.Duration("timeout", 30*time.Second, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	start, end, err := checkDates(*arrival, *departure, *nights)
+	if *campground == "" || err != nil {
+		if err != nil {
+			fmt.Fprintln(stderr, "check:", err)
+		} else {
+			fmt.Fprintln(stderr, "check: --campground is required")
+		}
+		fs.Usage()
+		return 2
+	}
+	filter := core.SiteFilter{}
+	if *siteTypes != "" {
+		filter.Types = strings.Split(*siteTypes, ",")
+	}
+	if *replayDir != "" {
+		scraper.DefaultScraper.ReplayDir = *replayDir
+	}
+	if *recordDir != "" {
+		scraper.DefaultScraper.RecordTo(*recordDir)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	result, err := scraper.ScrapeAvailabilityDetailed(ctx, *campground, start, end)
+	if err != nil {
+		fmt.Fprintln(stderr, "check:", err)
+		return 3
+	}
+	sites := checkSites(result.Filter(filter))
+	if *replayDir == "" && len(sites) > 0 {
+		sites = priceSites(ctx, *campground, sites, start, end)
+	}
+	var odds *scraper.Odds
+	if *withOdds {
+		estimate, err := scraper.EstimateOdds(ctx, *campground, start, end)
+		if err != nil {
+			fmt.Fprintln(stderr, "check: odds:", err)
+		} else {
+			odds = &estimate
+		}
+	}
+	switch {
+	case *asJSON && *withOdds:
+		out, _ := json.MarshalIndent(struct {
+			Sites []checkSite   `json:"sites"`
+			Odds  *scraper.Odds `json:"odds"`
+		}{sites, odds}, "", "  ")
+		fmt.Fprintln(stdout, string(out))
+	case *asJSON:
+		out, _ := json.MarshalIndent(sites, "", "  ")
+		fmt.Fprintln(stdout, string(out))
+	default:
+		fmt.Fprintln(stdout, scraper.FormatStay(core.CivilDateOf(start), core.CivilDateOf(end), *locale))
+		printCheck(stdout, sites, result.Filter(filter))
+		if odds != nil {
+			fmt.Fprintln(stdout, odds)
+		}
+	}
+	if len(sites) == 0 {
+		return 1
+	}
+	return 0
+}
+
+// checkDates parses check's dates, taking the departure from --nights when
+// --departure is not given.
+func checkDates(arrival string, departure string, nights int) (time.Time, time.Time, error) {
+	if arrival == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("--arrival is required")
+	}
+	start, err := core.ParseCivilDate(arrival)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("--arrival: %v", err)
+	}
+	switch {
+	case departure != "" && nights > 0:
+		return time.Time{}, time.Time{}, fmt.Errorf("give --departure or --nights, not both")
+	case nights > 0:
+		return start.Time(), start.Time().AddDate(0, 0, nights), nil
+	case departure == "":
+		return time.Time{}, time.Time{}, fmt.Errorf("--departure or --nights is required")
+	}
+	end, err := core.ParseCivilDate(departure)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("--departure: %v", err)
+	}
+	if !end.Time().After(start.Time()) {
+		return time.Time{}, time.Time{}, fmt.Errorf("--departure must be after --arrival")
+	}
+	return start.Time(), end.Time(), nil
+}
+
+// checkSites lists the fully available sites in result with their names
+// and types.
+func checkSites(result core.AvailabilityResult) []checkSite {
+	summaries := map[string]core.SiteSummary{}
+	for _, s := range result.Summaries {
+		summaries[s.CampsiteID] = s
+	}
+	sites := []checkSite{}
+	for _, id := range result.Sites {
+		s := summaries[id]
+		sites = append(sites, checkSite{ID: id, Site: s.Site, Type: s.CampsiteType, Remaining: s.Remaining, URL: "https://www.recreation.gov/camping/campsites/" + id})
+	}
+	return sites
+}
+
+// priceSites adds what the stay costs at each site and lists the sites
+// cheapest first, those with unknown fees last. Replays have no fees to
+// read, so they are not priced.
+func priceSites(ctx context.Context, campground string, sites []checkSite, start time.Time, end time.Time) []checkSite {
+	ids := make([]string, len(sites))
+	for i, s := range sites {
+		ids[i] = s.ID
+	}
+	prices := scraper.StayPrices(ctx, campground, ids, start, end)
+	for i := range sites {
+		if price, ok := prices[sites[i].ID]; ok {
+			sites[i].Price = core.FormatPrice(price.Total)
+		}
+	}
+	sort.SliceStable(sites, func(i, j int) bool {
+		pi, iKnown := prices[sites[i].ID]
+		pj, jKnown := prices[sites[j].ID]
+		if iKnown != jKnown {
+			return iKnown
+		}
+		return iKnown && pi.Total < pj.Total
+	})
+	return sites
+}
+
+// printCheck writes sites as an aligned table, or the result's summary
+// when there are none.
+func printCheck(w io.Writer, sites []checkSite, result core.AvailabilityResult) {
+	if len(sites) == 0 {
+		fmt.Fprintf(w, "no sites available (%s): %s\n", result.Class, result.Summary())
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SITE\tID\tPRICE\tBOOK")
+	for _, s := range sites {
+		label := s.Site
+		if label == "" {
+			label = s.ID
+		}
+		if s.Type != "" {
+			label += " (" + s.Type + ")"
+		}
+		if s.Remaining > 0 {
+			label += fmt.Sprintf(", %d left", s.Remaining)
+		}
+		price := s.Price
+		if price == "" {
+			price = "unknown"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", label, s.ID, price, s.URL)
+	}
+	tw.Flush()
+}
+
+func runTonight(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("tonight", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := scraper.LastMinuteOptions{}
+	fs.StringVar(&opts.CampgroundID, "campground", "", "recreation.gov campground ID (required)")
+	fs.BoolVar(&opts.Tomorrow, "tomorrow", false, "also require tomorrow night")
+	fs.StringVar(&opts.NotifyPhone, "phone", "", "E.164 number to text first when a site opens, such as +14155550100")
+	fs.StringVar(&opts.NotifyEmail, "email", "", "address to send alerts to (default NOTIFY_EMAIL)")
+	fs.IntVar(&opts.CutoffHour, "cutoff", 20, "local hour after which to give up")
+	fs.StringVar(&opts.TimeZone, "timezone", "America/Los_Angeles", "campground time zone")
+	fs.StringVar(&opts.Schedule, "schedule", "*/5 * * * *", "scan schedule")
+	fs.BoolVar(&opts.Jitter, "jitter", false, "offset the schedule by a stable per-watch number of minutes")
+	fs.StringVar(&opts.ProjectID, "project", "", "GCP project ID (default the package default)")
+	fs.StringVar(&opts.Location, "region", "", "Cloud Scheduler location (default the package default)")
+	fs.StringVar(&opts.Topic, "topic", "", "topic the scrape function is triggered by (default WATCH_TOPIC)")
+	fs.BoolVar(&opts.Force, "force", false, "create the watch even if an overlapping one exists")
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if opts.CampgroundID == "" {
+		fmt.Fprintln(stderr, "tonight: --campground is required")
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	name, merged, err := scraper.CreateLastMinuteWatch(ctx, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if merged {
+		fmt.Fprintln(stdout, "merged into existing watch", name)
+		return 0
+	}
+	fmt.Fprintln(stdout, "created", name)
+	return 0
+}
+
+func runRelease(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("release", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := scraper.ReleaseOptions{}
+	arrival := fs.String("arrival", "", "arrival date, YYYY-MM-DD (required)")
+	departure := fs.String("departure", "", "departure date, YYYY-MM-DD (required)")
+	fs.StringVar(&opts.CampgroundID, "campground", "", "recreation.gov campground ID (required)")
+	fs.IntVar(&opts.MonthsAhead, "months-ahead", 6, "booking window in months")
+	opts.ReleaseHour = fs.Int("release-hour", 10, "local hour dates are released, 0 to 23")
+	fs.StringVar(&opts.TimeZone, "timezone", "America/New_York", "time zone of the release hour")
+	fs.StringVar(&opts.ProjectID, "project", "", "GCP project ID (default the package default)")
+	fs.StringVar(&opts.Location, "region", "", "Cloud Scheduler location (default the package default)")
+	fs.StringVar(&opts.Topic, "topic", "", "topic the scrape function is triggered by (default WATCH_TOPIC)")
+	fs.BoolVar(&opts.Force, "force", false, "create the watch even if an overlapping one exists")
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if opts.CampgroundID == "" || *arrival == "" || *departure == "" {
+		fmt.Fprintln(stderr, "release: --campground, --arrival and --departure are required")
+		fs.Usage()
+		return 2
+	}
+	var err error
+	if opts.Arrival, err = core.ParseCivilDate(*arrival); err != nil {
+		fmt.Fprintln(stderr, "release: --arrival:", err)
+		return 2
+	}
+	if opts.Departure, err = core.ParseCivilDate(*departure); err != nil {
+		fmt.Fprintln(stderr, "release: --departure:", err)
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	w, err := scraper.CreateReleaseWatch(ctx, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	verb := "created"
+	if w.Merged {
+		verb = "merged into existing watch"
+	}
+	fmt.Fprintf(stdout, "%s %s; dates release at %s\n", verb, w.Name, w.Release.Format(time.RFC1123))
+	return 0
+}
+
+func runWatch(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	switch args[0] {
+	case "create":
+		return runCreate(args[1:], stdout, stderr)
+	case "list":
+		return runList(args[1:], stdout, stderr)
+	case "update":
+		return runUpdate(args[1:], stdout, stderr)
+	case "pause":
+		return runPause(args[1:], stdout, stderr)
+	case "rehearse":
+		return runRehearse(args[1:], stdout, stderr)
+	case "verify":
+		return runVerify(args[1:], stdout, stderr)
+	case "notifications":
+		return runNotifications(args[1:], stdout, stderr)
+	case "diagnose":
+		return runDiagnose(args[1:], stdout, stderr)
+	}
+	fmt.Fprint(stderr, usage)
+	return 2
+}
+
+func runRehearse(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch rehearse", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: campfinder watch rehearse [flags] <job name>")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := scraper.Rehearse(ctx, fs.Arg(0)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "rehearsal published; check the watch's notification channels")
+	return 0
+}
+
+// watchConfig registers the flags overriding the environment's Config.
+func watchConfig(fs *flag.FlagSet) *scraper.Config {
+	cfg, _ := scraper.ConfigFromEnv()
+	fs.StringVar(&cfg.Project, "project", cfg.Project, "GCP project ID (default GCP_PROJECT)")
+	fs.StringVar(&cfg.Location, "region", cfg.Location, "Cloud Scheduler location (default SCHEDULER_LOCATION)")
+	fs.StringVar(&cfg.Topic, "topic", cfg.Topic, "topic the scrape function is triggered by (default WATCH_TOPIC)")
+	return &cfg
+}
+
+// watchFlags registers the flags defining a fixed-date watch and returns
+// a function filling in m once they are parsed, reporting whether the
+// required ones were given.
+func watchFlags(fs *flag.FlagSet, m *scraper.MessageContent) func() bool {
+	campgrounds := fs.String("campground", "", "recreation.gov campground ID, or several separated by commas (required)")
+	fs.StringVar(&m.Arrival, "arrival", "", "arrival date, YYYY-MM-DD (required)")
+	fs.StringVar(&m.Departure, "departure", "", "departure date, YYYY-MM-DD (required)")
+	fs.StringVar(&m.TimeZone, "timezone", "", "campground time zone, used for the schedule too (default UTC)")
+	fs.StringVar(&m.NotifyEmail, "email", "", "address to send alerts to (default NOTIFY_EMAIL)")
+	fs.StringVar(&m.Contact, "contact", "", "contact to alert instead of --email, looked up on every run")
+	fs.StringVar(&m.Owner, "owner", "", "whose watch it is, and whose contacts --contact names")
+	return func() bool {
+		if *campgrounds == "" || m.Arrival == "" || m.Departure == "" {
+			return false
+		}
+		if ids := strings.Split(*campgrounds, ","); len(ids) > 1 {
+			m.Campgrounds = ids
+		} else {
+			m.Campground = ids[0]
+		}
+		return true
+	}
+}
+
+func runCreate(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch create", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg := watchConfig(fs)
+	m := scraper.MessageContent{}
+	parsed := watchFlags(fs, &m)
+	schedule := fs.String("schedule", "*/10 * * * *", "scan schedule")
+	var opts scraper.CreateOptions
+	fs.BoolVar(&opts.Force, "force", false, "create the watch even if an overlapping one exists")
+	fs.BoolVar(&opts.Jitter, "jitter", false, "offset the schedule by a stable per-watch number of minutes")
+	fs.BoolVar(&opts.Confirm, "confirm", false, "email the watch's recipient that it is set up, with its odds")
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if !parsed() {
+		fmt.Fprintln(stderr, "watch create: --campground, --arrival and --departure are required")
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	created, err := scraper.CreateWatch(ctx, *cfg, m, *schedule, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if created.Merged {
+		fmt.Fprintln(stdout, "merged into existing watch", created.Name)
+		return 0
+	}
+	fmt.Fprintln(stdout, "created", created.Name)
+	return 0
+}
+
+func runUpdate(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch update", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg := watchConfig(fs)
+	m := scraper.MessageContent{}
+	parsed := watchFlags(fs, &m)
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 1 || !parsed() {
+		fmt.Fprintln(stderr, "usage: campfinder watch update --campground ID --arrival DATE --departure DATE [flags] <watch ID>")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := scraper.UpdateWatch(ctx, *cfg, fs.Arg(0), m); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "updated", fs.Arg(0))
+	return 0
+}
+
+func runPause(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch pause", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg := watchConfig(fs)
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: campfinder watch pause [flags] <watch ID>")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	if err := scraper.PauseWatch(ctx, *cfg, fs.Arg(0)); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "paused", fs.Arg(0))
+	return 0
+}
+
+func runList(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch list", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg := watchConfig(fs)
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	watches, err := scraper.ListWatches(ctx, *cfg)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	for _, w := range watches {
+		m := w.Watch
+		campgrounds := m.Campground
+		if len(m.Campgrounds) > 0 {
+			campgrounds = strings.Join(m.Campgrounds, ",")
+		}
+		fmt.Fprintf(stdout, "%s\t%s\t%s\t%s\t%s (+%dm)\t%.0f req/h\n", w.Name, campgrounds, m.Arrival, m.Departure, w.Schedule, w.JitterMinutes, w.RequestsPerHour)
+	}
+	return 0
+}
+
+func runVerify(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch verify", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := scraper.VerifyOptions{}
+	fs.BoolVar(&opts.Migrate, "migrate", false, "rewrite watches whose only problem is a legacy date format")
+	timeout := fs.Duration("timeout", 2*time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	report, err := scraper.VerifyWatches(ctx, opts)
+	for _, p := range report {
+		status := "invalid "
+		if p.Migrated {
+			status = "migrated"
+		}
+		fmt.Fprintf(stdout, "%s %s\n", status, p.Job)
+		for _, problem := range p.Problems {
+			fmt.Fprintf(stdout, "         %s\n", problem)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	for _, p := range report {
+		if !p.Migrated {
+			return 1
+		}
+	}
+	return 0
+}
+
+func runReplay(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	snapshots := fs.String("snapshots", "", "directory of <campground>/<YYYY-MM>.json payloads (required)")
+	watchesFile := fs.String("watches", "", "JSON array of watch payloads (required)")
+	goldenFile := fs.String("golden", "", "JSON object of expected notifications by job name (required)")
+	update := fs.Bool("update", false, "rewrite the golden file with the replayed notifications")
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if *snapshots == "" || *watchesFile == "" || *goldenFile == "" {
+		fmt.Fprintln(stderr, "replay: --snapshots, --watches and --golden are required")
+		fs.Usage()
+		return 2
+	}
+
+	data, err := ioutil.ReadFile(*watchesFile)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	var watches []scraper.MessageContent
+	if err := json.Unmarshal(data, &watches); err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", *watchesFile, err)
+		return 1
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	got, err := scraper.Replay(ctx, core.SnapshotDir{Dir: *snapshots}, watches)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	if *update {
+		out, err := json.MarshalIndent(got, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(*goldenFile, append(out, '\n'), 0644)
+		}
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintf(stdout, "wrote %d notifications to %s\n", len(got), *goldenFile)
+		return 0
+	}
+
+	golden := map[string]string{}
+	data, err = ioutil.ReadFile(*goldenFile)
+	if err == nil {
+		err = json.Unmarshal(data, &golden)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", *goldenFile, err)
+		return 1
+	}
+	diffs := scraper.DiffReplay(golden, got)
+	for _, d := range diffs {
+		fmt.Fprintf(stdout, "%-7s %s\n", d.Change, d.Job)
+		if d.Want != "" {
+			fmt.Fprintf(stdout, "  want: %q\n", d.Want)
+		}
+		if d.Got != "" {
+			fmt.Fprintf(stdout, "  got:  %q\n", d.Got)
+		}
+	}
+	if len(diffs) > 0 {
+		return 1
+	}
+	fmt.Fprintf(stdout, "%d notifications match\n", len(got))
+	return 0
+}
+
+func runNotifications(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch notifications", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	since := fs.Duration("since", 7*24*time.Hour, "how far back to look")
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: campfinder watch notifications [flags] <job name>")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	records, err := scraper.GetNotificationArchive(ctx, fs.Arg(0), scraper.Now().Add(-*since))
+	for _, r := range records {
+		fmt.Fprintf(stdout, "%s %-5s %s %s: %d sites, %s\n", r.At.Local().Format("2006-01-02 15:04"), r.Channel, r.Recipient,
+			r.Outcome, len(r.Sites), r.BodyHash[:12])
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func runDiagnose(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("watch diagnose", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	out := fs.String("o", "", "file to write the bundle to (default stdout)")
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() != 1 {
+		fmt.Fprintln(stderr, "usage: campfinder watch diagnose [flags] <job name>")
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	bundle, err := scraper.GenerateDiagnostics(ctx, fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *out == "" {
+		stdout.Write(append(bundle, '\n'))
+		return 0
+	}
+	if err := ioutil.WriteFile(*out, append(bundle, '\n'), 0600); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	fmt.Fprintln(stdout, "wrote", *out)
+	return 0
+}
+
+func runContact(args []string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) < 1 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	fs := flag.NewFlagSet("contact "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	cfg := watchConfig(fs)
+	contact := scraper.Contact{}
+	fs.StringVar(&contact.Owner, "owner", "", "whose contact it is")
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	switch args[0] {
+	case "set":
+		fs.StringVar(&contact.Name, "name", "", "name watches refer to the contact by (required)")
+		fs.StringVar(&contact.Email, "email", "", "address to email alerts to")
+		fs.StringVar(&contact.Phone, "phone", "", "E.164 number to text alerts to")
+		fs.StringVar(&contact.Slack, "slack", "", "Slack incoming webhook to post alerts to")
+	case "list":
+	case "delete":
+	default:
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	cascade := fs.Bool("cascade", false, "delete: also end every watch alerting the contact")
+	if status, ok := parseFlags(fs, args[1:]); !ok {
+		return status
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	switch args[0] {
+	case "set":
+		saved, err := scraper.SaveContact(ctx, *cfg, contact)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		fmt.Fprintln(stdout, "saved contact", saved.Name)
+	case "list":
+		contacts, err := scraper.ListContacts(ctx, *cfg, contact.Owner)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		for _, c := range contacts {
+			slack := ""
+			if c.Slack != "" {
+				slack = "slack"
+			}
+			fmt.Fprintf(stdout, "%s\t%s\t%s\t%s\n", c.Name, c.Email, c.Phone, slack)
+		}
+	case "delete":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(stderr, "usage: campfinder contact delete [--owner OWNER] [--cascade] <name>")
+			return 2
+		}
+		ended, err := scraper.DeleteContact(ctx, *cfg, contact.Owner, fs.Arg(0), *cascade)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+		for _, name := range ended {
+			fmt.Fprintln(stdout, "ended", name)
+		}
+		fmt.Fprintln(stdout, "deleted contact", fs.Arg(0))
+	}
+	return 0
+}
+
+func runControl(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("control", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	bucket := fs.String("bucket", os.Getenv("CONTROL_BUCKET"), "bucket holding the control document (default $CONTROL_BUCKET)")
+	reason := fs.String("reason", "", "reason recorded with pause")
+	timeout := fs.Duration("timeout", time.Minute, "overall timeout")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: campfinder control [flags] show|pause|resume|block <campground>|unblock <campground>")
+		fs.PrintDefaults()
+	}
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if fs.NArg() < 1 || *bucket == "" {
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	control, err := scraper.LoadControl(ctx, *bucket)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	command := fs.Arg(0)
+	switch {
+	case command == "show":
+	case command == "pause":
+		control.Paused, control.Reason = true, *reason
+	case command == "resume":
+		control.Paused, control.Reason = false, ""
+	case command == "block" && fs.NArg() == 2:
+		control.Block(fs.Arg(1))
+	case command == "unblock" && fs.NArg() == 2:
+		control.Unblock(fs.Arg(1))
+	default:
+		fs.Usage()
+		return 2
+	}
+	if command != "show" {
+		if err := scraper.SaveControl(ctx, *bucket, control); err != nil {
+			fmt.Fprintln(stderr, err)
+			return 1
+		}
+	}
+	fmt.Fprintf(stdout, "paused:  %v %s\nblocked: %v\n", control.Paused, control.Reason, control.Blocked)
+	return 0
+}
+
+// runBackfill writes a campground's past months into the scrape history in
+// BQ_DATASET and BQ_TABLE. Run it again after a failure to carry on.
+func runBackfill(args []string, stdout io.Writer, stderr io.Writer) int {
+	fs := flag.NewFlagSet("backfill", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	opts := scraper.BackfillOptions{}
+	fs.StringVar(&opts.CampgroundID, "campground", "", "recreation.gov campground ID (required)")
+	fs.IntVar(&opts.Months, "months", 6, "whole months before this one to fetch")
+	fs.DurationVar(&opts.Interval, "interval", scraper.DefaultBackfillInterval, "least time between requests to recreation.gov")
+	fs.BoolVar(&opts.Restart, "restart", false, "fetch every month again, ignoring those already backfilled")
+	fs.Int64Var(&scraper.DefaultScraper.MaxResponseBytes, "max-response-bytes", scraper.DefaultScraper.MaxResponseBytes,
+		"largest recreation.gov response to read, for very large campgrounds (default REQUEST_MAX_BYTES, else 8 MiB)")
+	timeout := fs.Duration("timeout", 2*time.Hour, "overall timeout")
+	if status, ok := parseFlags(fs, args); !ok {
+		return status
+	}
+	if opts.CampgroundID == "" {
+		fmt.Fprintln(stderr, "backfill: --campground is required")
+		fs.Usage()
+		return 2
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	result, err := scraper.Backfill(ctx, opts)
+	for _, month := range result.Skipped {
+		fmt.Fprintln(stdout, month, "already backfilled")
+	}
+	for _, month := range result.Done {
+		fmt.Fprintln(stdout, month, "done")
+	}
+	if len(result.Done) > 0 {
+		fmt.Fprintf(stdout, "%d rows written, %d nights already in the history\n", result.Rows, result.Duplicates)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, "backfill:", err)
+		return 1
+	}
+	return 0
+}
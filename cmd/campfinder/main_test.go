@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// runArgs runs the command line args and returns its status and output.
+func runArgs(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	status := run(args, &stdout, &stderr)
+	return status, stdout.String(), stderr.String()
+}
+
+func TestRunUsage(t *testing.T) {
+	tests := []struct {
+		name       string
+		args       []string
+		wantStatus int
+		wantStderr string
+	}{
+		{name: "no command", wantStatus: 2, wantStderr: "usage: campfinder <command>"},
+		{name: "unknown command", args: []string{"frobnicate"}, wantStatus: 2, wantStderr: "usage: campfinder <command>"},
+		{name: "unknown watch command", args: []string{"watch", "frobnicate"}, wantStatus: 2, wantStderr: "usage: campfinder <command>"},
+		{name: "unknown flag", args: []string{"check", "--campsite", "1001"}, wantStatus: 2, wantStderr: "flag provided but not defined: -campsite"},
+		{name: "help", args: []string{"check", "-h"}, wantStatus: 0, wantStderr: "usage: campfinder check [flags]"},
+		{name: "no campground", args: []string{"check", "--arrival", "2027-07-14", "--nights", "2"}, wantStatus: 2, wantStderr: "check: --campground is required"},
+		{name: "no arrival", args: []string{"check", "--campground", "232447", "--nights", "2"}, wantStatus: 2, wantStderr: "check: --arrival is required"},
+		{name: "bad nights", args: []string{"check", "--campground", "232447", "--arrival", "2027-07-14", "--nights", "two"}, wantStatus: 2, wantStderr: `invalid value "two" for flag -nights`},
+		{name: "bootstrap without a project", args: []string{"bootstrap", "--region", "us-west2"}, wantStatus: 2, wantStderr: "bootstrap: --project and --region are required"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, stdout, stderr := runArgs(test.args...)
+			if status != test.wantStatus {
+				t.Errorf("status %d, want %d", status, test.wantStatus)
+			}
+			if !strings.Contains(stderr, test.wantStderr) {
+				t.Errorf("stderr %q, want it to contain %q", stderr, test.wantStderr)
+			}
+			if stdout != "" {
+				t.Errorf("stdout %q, want nothing", stdout)
+			}
+		})
+	}
+}
+
+func TestCheckDates(t *testing.T) {
+	tests := []struct {
+		name      string
+		arrival   string
+		departure string
+		nights    int
+		want      string
+		wantErr   string
+	}{
+		{name: "departure", arrival: "2027-07-14", departure: "2027-07-16", want: "2027-07-14 2027-07-16"},
+		{name: "nights", arrival: "2027-07-30", nights: 3, want: "2027-07-30 2027-08-02"},
+		{name: "US dates", arrival: "7/14/2027", departure: "7/16/2027", want: "2027-07-14 2027-07-16"},
+		{name: "both", arrival: "2027-07-14", departure: "2027-07-16", nights: 2, wantErr: "give --departure or --nights, not both"},
+		{name: "neither", arrival: "2027-07-14", wantErr: "--departure or --nights is required"},
+		{name: "bad arrival", arrival: "July 14", nights: 2, wantErr: "--arrival:"},
+		{name: "departure first", arrival: "2027-07-16", departure: "2027-07-14", wantErr: "--departure must be after --arrival"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end, err := checkDates(test.arrival, test.departure, test.nights)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Errorf("checkDates error = %v, want %q", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := start.Format("2006-01-02") + " " + end.Format("2006-01-02"); got != test.want {
+				t.Errorf("checkDates = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// checkFixture has A001 open on July 14 and 15, 2027, at $36 a night.
+func checkFixture() fakerecgov.Fixture {
+	return fakerecgov.Fixture{Campgrounds: []fakerecgov.Campground{{
+		ID:         "232447",
+		Name:       "Upper Pines",
+		NightlyFee: 36,
+		Sites: []fakerecgov.Site{
+			{ID: "1001", Site: "A001", Loop: "A", Type: "STANDARD NONELECTRIC", Nights: map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}},
+			{ID: "1002", Site: "A002", Loop: "A", Type: "TENT ONLY NONELECTRIC"},
+		},
+	}}}
+}
+
+func TestRunCheck(t *testing.T) {
+	h := fakerecgov.Start(checkFixture())
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	status, stdout, stderr := runArgs("check", "--campground", "232447", "--arrival", "2027-07-14", "--nights", "2")
+	if status != 0 {
+		t.Fatalf("status %d, want 0: %s", status, stderr)
+	}
+	want := "2 nights, Wed Jul 14 → Fri Jul 16\n" +
+		"SITE                         ID    PRICE   BOOK\n" +
+		"A001 (STANDARD NONELECTRIC)  1001  $72.00  https://www.recreation.gov/camping/campsites/1001\n"
+	if stdout != want {
+		t.Errorf("stdout:\n%s\nwant:\n%s", stdout, want)
+	}
+
+	status, stdout, _ = runArgs("check", "--campground", "232447", "--arrival", "2027-07-14", "--nights", "2", "--json")
+	var sites []checkSite
+	if err := json.Unmarshal([]byte(stdout), &sites); err != nil {
+		t.Fatalf("decoding %q: %v", stdout, err)
+	}
+	if status != 0 || len(sites) != 1 || sites[0].ID != "1001" || sites[0].Price != "$72.00" {
+		t.Errorf("--json exited %d with %+v, want A001 at $72.00", status, sites)
+	}
+
+	status, stdout, _ = runArgs("check", "--campground", "232447", "--arrival", "2027-07-14", "--nights", "2", "--site-type", "tent only")
+	if status != 1 || !strings.HasPrefix(stdout, "2 nights, Wed Jul 14 → Fri Jul 16\nno sites available") {
+		t.Errorf("tent sites exited %d with %q, want 1 and no sites", status, stdout)
+	}
+}
+
+// A failed check exits 3, distinct from finding nothing.
+func TestRunCheckFailure(t *testing.T) {
+	f := checkFixture()
+	f.Campgrounds[0].FailStatus = 500
+	h := fakerecgov.Start(f)
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	status, stdout, stderr := runArgs("check", "--campground", "232447", "--arrival", "2027-07-14", "--nights", "2")
+	if status != 3 || stdout != "" || !strings.HasPrefix(stderr, "check: ") {
+		t.Errorf("exited %d with stdout %q and stderr %q, want 3 and the error", status, stdout, stderr)
+	}
+}
+
+// A campground's months larger than --max-response-bytes are refused.
+func TestRunCheckMaxResponseBytes(t *testing.T) {
+	h := fakerecgov.Start(checkFixture())
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	status, _, stderr := runArgs("check", "--campground", "232447", "--arrival", "2027-07-14", "--nights", "2", "--max-response-bytes", "64")
+	if status != 3 || !strings.Contains(stderr, "64 bytes") {
+		t.Errorf("exited %d with stderr %q, want 3 and the limit", status, stderr)
+	}
+}
+
+// copyReplay copies the package's replay testdata to a temporary directory,
+// so tests may rewrite its golden file, and returns the directory.
+func copyReplay(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	src := filepath.Join("..", "..", "testdata", "replay")
+	err := filepath.Walk(src, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(src, path)
+		if info.IsDir() {
+			return os.MkdirAll(filepath.Join(dir, rel), 0755)
+		}
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return ioutil.WriteFile(filepath.Join(dir, rel), data, 0644)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// replay reruns the stored snapshots against the golden set, reporting each
+// notification added, removed or changed, and sends nothing.
+func TestRunReplay(t *testing.T) {
+	f := checkFixture()
+	f.Campgrounds[0].Sites = append(f.Campgrounds[0].Sites, fakerecgov.Site{ID: "1003", Site: "G01", Loop: "Group", Type: "GROUP STANDARD NONELECTRIC"})
+	h := fakerecgov.Start(f)
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	dir := copyReplay(t)
+	goldenFile := filepath.Join(dir, "golden.json")
+	args := []string{"replay", "--snapshots", filepath.Join(dir, "snapshots"), "--watches", filepath.Join(dir, "watches.json"), "--golden", goldenFile}
+
+	status, stdout, stderr := runArgs(args...)
+	if status != 0 || stdout != "2 notifications match\n" {
+		t.Fatalf("exited %d with %q and %q, want the stored goldens to match", status, stdout, stderr)
+	}
+
+	// Drift the golden set: one notification changed, one gone and one
+	// that replays no longer send.
+	golden := map[string]string{}
+	data, _ := ioutil.ReadFile(goldenFile)
+	if err := json.Unmarshal(data, &golden); err != nil {
+		t.Fatal(err)
+	}
+	plain, priority := "projects/fakerecgov/locations/us-central1/jobs/replay-plain", "projects/fakerecgov/locations/us-central1/jobs/replay-priority"
+	golden[plain] = "an older email"
+	delete(golden, priority)
+	golden["projects/fakerecgov/locations/us-central1/jobs/replay-gone"] = "a retired watch's email"
+	data, _ = json.Marshal(golden)
+	if err := ioutil.WriteFile(goldenFile, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	status, stdout, _ = runArgs(args...)
+	changes := []string{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && strings.HasPrefix(fields[1], "projects/") {
+			changes = append(changes, fields[0]+" "+fields[1][strings.LastIndex(fields[1], "/")+1:])
+		}
+	}
+	if want := []string{"removed replay-gone", "changed replay-plain", "added replay-priority"}; status != 1 || strings.Join(changes, ",") != strings.Join(want, ",") {
+		t.Errorf("exited %d reporting %v, want 1 and %v:\n%s", status, changes, want, stdout)
+	}
+
+	status, stdout, _ = runArgs(append(args, "--update")...)
+	if status != 0 || stdout != "wrote 2 notifications to "+goldenFile+"\n" {
+		t.Errorf("--update exited %d with %q", status, stdout)
+	}
+	if status, stdout, _ = runArgs(args...); status != 0 {
+		t.Errorf("after --update exited %d with %q, want a match", status, stdout)
+	}
+
+	if alerts, notices := h.Notifier.Alerts(), h.Notifier.Notices(); len(alerts) != 0 || len(notices) != 0 {
+		t.Errorf("replay sent alerts %+v and notices %+v", alerts, notices)
+	}
+	if deleted := h.Scheduler.Deleted(); len(deleted) != 0 {
+		t.Errorf("replay deleted jobs %v", deleted)
+	}
+	if published := h.Results.Messages(); len(published) != 0 {
+		t.Errorf("replay published %+v", published)
+	}
+}
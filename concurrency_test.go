@@ -0,0 +1,183 @@
+package scraper_test
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+// Concurrent invocations share the process's clients, caches and stores, as
+// they do on a busy function instance. Run with -race.
+func TestConcurrentScrapeFromMessage(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	const jobs, deliveries = 8, 4
+	var messages []scraper.MessageContent
+	for i := 0; i < jobs; i++ {
+		m := e2eWatch(fmt.Sprintf("e2e-concurrent-%d", i))
+		message := fakerecgov.Message(m)
+		h.Scheduler.AddJob(&schedulerpb.Job{
+			Name:   m.Name,
+			Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{Data: message.Data}},
+		})
+		messages = append(messages, m)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, jobs*deliveries)
+	for _, m := range messages {
+		// Each job's message is delivered several times at once, as Pub/Sub
+		// may do.
+		for i := 0; i < deliveries; i++ {
+			wg.Add(1)
+			go func(m scraper.MessageContent) {
+				defer wg.Done()
+				errs <- scraper.ScrapeFromMessage(context.Background(), fakerecgov.Message(m))
+			}(m)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("ScrapeFromMessage: %v", err)
+		}
+	}
+
+	perJob := map[string]int{}
+	for _, alert := range h.Notifier.Alerts() {
+		perJob[alert.Job]++
+	}
+	for _, m := range messages {
+		if perJob[m.Name] != 1 {
+			t.Errorf("job %s alerted %d times, want once", m.Name, perJob[m.Name])
+		}
+		if h.Scheduler.Job(m.Name) != nil {
+			t.Errorf("job %s still scheduled after its alert", m.Name)
+		}
+	}
+}
+
+// Of many deliveries claiming the same alert at once, exactly one wins.
+func TestMemoryStoreConcurrentClaims(t *testing.T) {
+	store := &scraper.MemoryStore{}
+	const claimers = 32
+	var wg sync.WaitGroup
+	won := make(chan bool, claimers)
+	for i := 0; i < claimers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ctx := context.Background()
+			claimed, err := store.Claim(ctx, "alert", time.Hour)
+			if err != nil {
+				t.Error(err)
+			}
+			won <- claimed
+			job := fmt.Sprintf("job-%d", i%4)
+			store.SetLastNotified(ctx, job, []string{"1001"})
+			store.LastNotified(ctx, job)
+			store.SetLastScan(ctx, job, []string{"1001"})
+			store.DeleteScan(ctx, job)
+		}(i)
+	}
+	wg.Wait()
+	close(won)
+	wins := 0
+	for claimed := range won {
+		if claimed {
+			wins++
+		}
+	}
+	if wins != 1 {
+		t.Errorf("%d claims won, want 1", wins)
+	}
+}
+
+// sendingNotifier is a fakerecgov.Notifier that calls during, once, while
+// the first alert is being sent.
+type sendingNotifier struct {
+	*fakerecgov.Notifier
+	during func()
+}
+
+func (n *sendingNotifier) NotifyAlert(ctx context.Context, alert scraper.WebhookPayload) error {
+	if during := n.during; during != nil {
+		n.during = nil
+		during()
+	}
+	return n.Notifier.NotifyAlert(ctx, alert)
+}
+
+// A redelivery that arrives while the first run is still sending leaves the
+// alert and the job to that run, so a watch is notified exactly once and
+// still ends, or is retried, as the first run's send decides.
+func TestRedeliveryDuringSend(t *testing.T) {
+	tests := []struct {
+		name string
+		// sendErr fails the first run's send.
+		sendErr error
+	}{
+		{"send succeeds", nil},
+		{"send fails", fmt.Errorf("twilio is down")},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(true))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			ctx := context.Background()
+			m := e2eWatch("e2e-redelivered-" + strings.Replace(test.name, " ", "-", -1))
+			notifier := &sendingNotifier{Notifier: h.Notifier}
+			defer scraper.UseServices(scraper.Services{Notifier: notifier})()
+			notifier.during = func() {
+				if err := h.Run(ctx, m); err != nil {
+					t.Errorf("redelivery: %v", err)
+				}
+				if h.Scheduler.Job(m.Name) == nil {
+					t.Errorf("redelivery deleted job %s while the first run was sending", m.Name)
+				}
+				h.Notifier.Err = test.sendErr
+			}
+
+			err := h.Run(ctx, m)
+			if test.sendErr == nil && err != nil {
+				t.Fatalf("first run: %v", err)
+			}
+			if test.sendErr != nil {
+				if err == nil {
+					t.Fatal("first run succeeded despite its send failing")
+				}
+				if h.Scheduler.Job(m.Name) == nil {
+					t.Fatalf("job %s deleted after its send failed", m.Name)
+				}
+				// Pub/Sub redelivers the failed run, and this time the
+				// alert goes out.
+				h.Notifier.Err = nil
+				if err := h.Run(ctx, m); err != nil {
+					t.Fatalf("retry: %v", err)
+				}
+			}
+
+			sent := len(h.Notifier.Alerts())
+			if test.sendErr != nil {
+				sent-- // the failed attempt is recorded too
+			}
+			if sent != 1 {
+				t.Errorf("sent %d alerts, want exactly 1", sent)
+			}
+			if deleted := h.Scheduler.Deleted(); len(deleted) != 1 || h.Scheduler.Job(m.Name) != nil {
+				t.Errorf("deleted %v, want job %s deleted once", deleted, m.Name)
+			}
+		})
+	}
+}
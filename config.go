@@ -0,0 +1,95 @@
+package scraper
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"time"
+)
+
+// Config is where the deployment's watch jobs live. It is read from the
+// environment when the package loads.
+type Config struct {
+	// Project is the GCP project, from GCP_PROJECT.
+	Project string
+	// Location is the Cloud Scheduler location, from SCHEDULER_LOCATION.
+	Location string
+	// Topic is the topic watches publish to and ScrapeFromMessage is
+	// triggered by, from WATCH_TOPIC.
+	Topic string
+	// Timeout bounds each ScrapeFromMessage run, from SCRAPE_TIMEOUT as a
+	// duration such as "90s". Zero means no bound beyond the function's own.
+	Timeout time.Duration
+}
+
+// The original deployment, used for anything not set in the environment.
+const (
+	fallbackProject  = "camp-finder-258618"
+	fallbackLocation = "us-west2"
+	fallbackTopic    = "TEST_TOPIC"
+	// fallbackTimeout leaves ten seconds of the default 60 second function
+	// timeout for returning cleanly.
+	fallbackTimeout = 50 * time.Second
+)
+
+var (
+	projectIDPattern = regexp.MustCompile(`^[a-z][a-z0-9-]{4,28}[a-z0-9]$`)
+	locationPattern  = regexp.MustCompile(`^[a-z]+-[a-z]+[0-9]+$`)
+)
+
+// ConfigFromEnv reads GCP_PROJECT, SCHEDULER_LOCATION, WATCH_TOPIC and
+// SCRAPE_TIMEOUT, falling back to the original deployment's values, and
+// validates the result.
+func ConfigFromEnv() (Config, error) {
+	c := Config{Project: os.Getenv("GCP_PROJECT"), Location: os.Getenv("SCHEDULER_LOCATION"), Topic: os.Getenv("WATCH_TOPIC")}
+	if c.Project == "" {
+		c.Project = fallbackProject
+	}
+	if c.Location == "" {
+		c.Location = fallbackLocation
+	}
+	if c.Topic == "" {
+		c.Topic = fallbackTopic
+	}
+	c.Timeout = fallbackTimeout
+	if value := os.Getenv("SCRAPE_TIMEOUT"); value != "" {
+		timeout, err := time.ParseDuration(value)
+		if err != nil {
+			return c, fmt.Errorf("SCRAPE_TIMEOUT %q is not a duration such as 90s", value)
+		}
+		c.Timeout = timeout
+	}
+	return c, c.Validate()
+}
+
+// Validate reports a project ID or location that Cloud Scheduler would
+// reject.
+func (c Config) Validate() error {
+	if !projectIDPattern.MatchString(c.Project) {
+		return fmt.Errorf("GCP_PROJECT %q is not a valid project ID", c.Project)
+	}
+	if !locationPattern.MatchString(c.Location) {
+		return fmt.Errorf("SCHEDULER_LOCATION %q is not a valid location such as us-west2", c.Location)
+	}
+	if c.Topic == "" {
+		return fmt.Errorf("WATCH_TOPIC must not be empty")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("SCRAPE_TIMEOUT %v must not be negative", c.Timeout)
+	}
+	return nil
+}
+
+// JobName returns the full name of the job with the given ID in c.
+func (c Config) JobName(job string) JobName {
+	return JobName{Project: c.Project, Location: c.Location, Job: job}
+}
+
+// Parent is the scheduler location resource jobs are created under.
+func (c Config) Parent() string {
+	return fmt.Sprintf("projects/%s/locations/%s", c.Project, c.Location)
+}
+
+// activeConfig is the configuration loaded at startup; configErr is set when
+// it is invalid, and ScrapeFromMessage then refuses to run.
+var activeConfig, configErr = ConfigFromEnv()
@@ -0,0 +1,41 @@
+package scraper
+
+import "testing"
+
+// useConfig makes c the package configuration until the test ends.
+func useConfig(t *testing.T, c Config) {
+	old := activeConfig
+	activeConfig = c
+	t.Cleanup(func() { activeConfig = old })
+}
+
+// Options left unset take the deployment's project, location and topic, so
+// watches created from the CLI publish to WATCH_TOPIC.
+func TestOptionDefaultsFollowConfig(t *testing.T) {
+	useConfig(t, Config{Project: "camp-finder-test", Location: "us-east1", Topic: "scrape"})
+	tests := []struct {
+		name                      string
+		project, location, topic  string
+		wantProject, wantLocation string
+		wantTopic                 string
+	}{
+		{"unset", "", "", "", "camp-finder-test", "us-east1", "scrape"},
+		{"set", "other-project", "europe-west1", "other", "other-project", "europe-west1", "other"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			last := LastMinuteOptions{ProjectID: test.project, Location: test.location, Topic: test.topic}
+			last.setDefaults()
+			release := ReleaseOptions{ProjectID: test.project, Location: test.location, Topic: test.topic}
+			release.setDefaults()
+			for kind, got := range map[string][3]string{
+				"LastMinuteOptions": {last.ProjectID, last.Location, last.Topic},
+				"ReleaseOptions":    {release.ProjectID, release.Location, release.Topic},
+			} {
+				if want := [3]string{test.wantProject, test.wantLocation, test.wantTopic}; got != want {
+					t.Errorf("%s defaults to project, location and topic %v, want %v", kind, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,506 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"io/ioutil"
+	"net/http"
+	netmail "net/mail"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/firestore/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// contactProblemInterval is how often the deployment's recipient is told,
+// per watch, that its contact cannot be resolved.
+const contactProblemInterval = 24 * time.Hour
+
+// Contact is a named set of alert destinations kept per owner. Watches
+// naming it in MessageContent.Contact are resolved when they run, so
+// changing the contact changes where all of them alert.
+type Contact struct {
+	// Owner is whose contact it is, such as the email address of the
+	// person managing the watches; the deployment's own contacts have none.
+	Owner string `json:"owner"`
+	// Name is how watches refer to the contact, in any case. Emails are
+	// addressed to it.
+	Name string `json:"name"`
+	// Email, Phone and Slack are where the contact's alerts go: an email
+	// address, an E.164 number to text and a Slack incoming webhook. At
+	// least one is needed; email goes to the deployment's own address when
+	// Email is empty.
+	Email   string    `json:"email,omitempty"`
+	Phone   string    `json:"phone,omitempty"`
+	Slack   string    `json:"slack,omitempty"`
+	Updated time.Time `json:"updated"`
+}
+
+// Validate checks that c has a name and destinations alerts can be sent to.
+func (c Contact) Validate() error {
+	if strings.TrimSpace(c.Name) == "" {
+		return &ValidationError{Field: "Name", Reason: "must not be empty"}
+	}
+	if c.Email == "" && c.Phone == "" && c.Slack == "" {
+		return &ValidationError{Field: "Email", Reason: "a contact needs an email address, a phone number or a Slack webhook"}
+	}
+	if c.Email != "" {
+		if address, err := netmail.ParseAddress(c.Email); err != nil || address.Address != c.Email {
+			return &ValidationError{Field: "Email", Value: c.Email, Reason: "is not a plain email address"}
+		}
+	}
+	if c.Phone != "" && !phoneNumberPattern.MatchString(c.Phone) {
+		return &ValidationError{Field: "Phone", Value: c.Phone, Reason: "is not an E.164 number such as +14155550100"}
+	}
+	if c.Slack != "" {
+		if u, err := url.Parse(c.Slack); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return &ValidationError{Field: "Slack", Reason: "is not an http or https URL"}
+		}
+	}
+	return nil
+}
+
+// contactKey identifies a contact: its owner and name, ignoring case and
+// surrounding space.
+func contactKey(owner string, name string) string {
+	return strings.ToLower(strings.TrimSpace(owner)) + "/" + strings.ToLower(strings.TrimSpace(name))
+}
+
+// ErrContactNotFound is returned by a ContactStore for an unknown contact.
+var ErrContactNotFound = errors.New("contact not found")
+
+// errNoContactStore is returned when contacts are used without a store.
+var errNoContactStore = errors.New("CONTACTS_COLLECTION is not set")
+
+// ContactStore keeps contacts by owner and name.
+type ContactStore interface {
+	// GetContact returns owner's contact name, or ErrContactNotFound.
+	GetContact(ctx context.Context, owner string, name string) (Contact, error)
+	// PutContact creates or replaces c.
+	PutContact(ctx context.Context, c Contact) error
+	// DeleteContact removes owner's contact name, if there is one.
+	DeleteContact(ctx context.Context, owner string, name string) error
+	// ListContacts returns owner's contacts, ordered by name.
+	ListContacts(ctx context.Context, owner string) ([]Contact, error)
+}
+
+// GetContact implements ContactStore.
+func (s *MemoryStore) GetContact(ctx context.Context, owner string, name string) (Contact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.contacts[contactKey(owner, name)]
+	if !ok {
+		return Contact{}, ErrContactNotFound
+	}
+	return c, nil
+}
+
+// PutContact implements ContactStore.
+func (s *MemoryStore) PutContact(ctx context.Context, c Contact) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.contacts == nil {
+		s.contacts = map[string]Contact{}
+	}
+	s.contacts[contactKey(c.Owner, c.Name)] = c
+	return nil
+}
+
+// DeleteContact implements ContactStore.
+func (s *MemoryStore) DeleteContact(ctx context.Context, owner string, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.contacts, contactKey(owner, name))
+	return nil
+}
+
+// ListContacts implements ContactStore.
+func (s *MemoryStore) ListContacts(ctx context.Context, owner string) ([]Contact, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	contacts := []Contact{}
+	for _, c := range s.contacts {
+		if strings.EqualFold(strings.TrimSpace(c.Owner), strings.TrimSpace(owner)) {
+			contacts = append(contacts, c)
+		}
+	}
+	sortContacts(contacts)
+	return contacts, nil
+}
+
+func sortContacts(contacts []Contact) {
+	sort.Slice(contacts, func(i, j int) bool {
+		return strings.ToLower(contacts[i].Name) < strings.ToLower(contacts[j].Name)
+	})
+}
+
+// FirestoreContacts is a ContactStore keeping one document per contact in
+// Collection of Project's default Firestore database, with the contact as
+// JSON in its "record" field next to "owner".
+type FirestoreContacts struct {
+	Project    string
+	Collection string
+}
+
+func (s FirestoreContacts) parent() string {
+	return fmt.Sprintf("projects/%s/databases/(default)/documents", s.Project)
+}
+
+// document names the contact's document after a hash of its key, since
+// owners and names may hold characters document IDs cannot.
+func (s FirestoreContacts) document(owner string, name string) string {
+	sum := sha256.Sum256([]byte(contactKey(owner, name)))
+	return s.parent() + "/" + s.Collection + "/contact-" + hex.EncodeToString(sum[:8])
+}
+
+// GetContact implements ContactStore.
+func (s FirestoreContacts) GetContact(ctx context.Context, owner string, name string) (Contact, error) {
+	svc, err := firestore.NewService(ctx)
+	if err != nil {
+		return Contact{}, fmt.Errorf("firestore.NewService: %v", err)
+	}
+	doc, err := svc.Projects.Databases.Documents.Get(s.document(owner, name)).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return Contact{}, ErrContactNotFound
+	}
+	if err != nil {
+		return Contact{}, fmt.Errorf("reading contact %q: %v", name, err)
+	}
+	return decodeContactDocument(ctx, doc)
+}
+
+// PutContact implements ContactStore.
+func (s FirestoreContacts) PutContact(ctx context.Context, c Contact) error {
+	svc, err := firestore.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("firestore.NewService: %v", err)
+	}
+	doc, err := encodeContactDocument(ctx, c)
+	if err != nil {
+		return err
+	}
+	if _, err := svc.Projects.Databases.Documents.Patch(s.document(c.Owner, c.Name), doc).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("writing contact %q: %v", c.Name, err)
+	}
+	return nil
+}
+
+// DeleteContact implements ContactStore.
+func (s FirestoreContacts) DeleteContact(ctx context.Context, owner string, name string) error {
+	svc, err := firestore.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("firestore.NewService: %v", err)
+	}
+	if _, err := svc.Projects.Databases.Documents.Delete(s.document(owner, name)).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("deleting contact %q: %v", name, err)
+	}
+	return nil
+}
+
+// ListContacts implements ContactStore.
+func (s FirestoreContacts) ListContacts(ctx context.Context, owner string) ([]Contact, error) {
+	svc, err := firestore.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("firestore.NewService: %v", err)
+	}
+	contacts := []Contact{}
+	want := strings.ToLower(strings.TrimSpace(owner))
+	err = svc.Projects.Databases.Documents.List(s.parent(), s.Collection).Pages(ctx, func(page *firestore.ListDocumentsResponse) error {
+		for _, doc := range page.Documents {
+			if doc.Fields["owner"].StringValue != want {
+				continue
+			}
+			c, err := decodeContactDocument(ctx, doc)
+			if err != nil {
+				return err
+			}
+			contacts = append(contacts, c)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing contacts in %s: %v", s.Collection, err)
+	}
+	sortContacts(contacts)
+	return contacts, nil
+}
+
+// encodeContactDocument is c's document. Its Slack webhook is left out of
+// "record" and stored in "secrets", encrypted with SECRETS_KMS_KEY; a
+// contact with one cannot be stored without it.
+func encodeContactDocument(ctx context.Context, c Contact) (*firestore.Document, error) {
+	slack := c.Slack
+	c.Slack = ""
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	doc := &firestore.Document{Fields: map[string]firestore.Value{
+		"record": {StringValue: string(data)},
+		"owner":  {StringValue: strings.ToLower(strings.TrimSpace(c.Owner))},
+	}}
+	if slack != "" {
+		sealed, err := sealSecrets(ctx, slack)
+		if err != nil {
+			return nil, fmt.Errorf("storing the Slack webhook of contact %q: %w", c.Name, err)
+		}
+		doc.Fields["secrets"] = firestore.Value{StringValue: sealed}
+	}
+	return doc, nil
+}
+
+// decodeContactDocument is the contact encodeContactDocument stored, with
+// its Slack webhook decrypted.
+func decodeContactDocument(ctx context.Context, doc *firestore.Document) (Contact, error) {
+	var c Contact
+	if err := json.Unmarshal([]byte(doc.Fields["record"].StringValue), &c); err != nil {
+		return Contact{}, fmt.Errorf("decoding %s: %v", doc.Name, err)
+	}
+	if sealed := doc.Fields["secrets"].StringValue; sealed != "" {
+		if err := openSecrets(ctx, sealed, &c.Slack); err != nil {
+			return Contact{}, fmt.Errorf("decoding the secrets of %s: %w", doc.Name, err)
+		}
+	}
+	return c, nil
+}
+
+// newContactStore returns the contacts for cfg: the CONTACTS_COLLECTION
+// Firestore collection in cfg.Project, or nil when that is unset, which
+// leaves watches to name their destinations themselves. Tests replace it.
+var newContactStore = func(cfg Config) ContactStore {
+	if collection := os.Getenv("CONTACTS_COLLECTION"); collection != "" {
+		return FirestoreContacts{Project: cfg.Project, Collection: collection}
+	}
+	return nil
+}
+
+// SaveContact creates or replaces c in cfg's contacts. Watches already
+// naming it alert its new destinations from their next run.
+func SaveContact(ctx context.Context, cfg Config, c Contact) (Contact, error) {
+	store := newContactStore(cfg)
+	if store == nil {
+		return Contact{}, fmt.Errorf("saving contact %q: %w", c.Name, errNoContactStore)
+	}
+	c.Name = strings.TrimSpace(c.Name)
+	if err := c.Validate(); err != nil {
+		return Contact{}, err
+	}
+	c.Updated = DefaultScraper.now().UTC()
+	return c, store.PutContact(ctx, c)
+}
+
+// ListContacts returns owner's contacts in cfg.
+func ListContacts(ctx context.Context, cfg Config, owner string) ([]Contact, error) {
+	store := newContactStore(cfg)
+	if store == nil {
+		return nil, fmt.Errorf("listing contacts: %w", errNoContactStore)
+	}
+	return store.ListContacts(ctx, owner)
+}
+
+// ContactInUseError is returned by DeleteContact, without cascade, for a
+// contact that watches still alert.
+type ContactInUseError struct {
+	Name    string
+	Watches []JobName
+}
+
+func (e *ContactInUseError) Error() string {
+	names := make([]string, len(e.Watches))
+	for i, name := range e.Watches {
+		names[i] = name.Job
+	}
+	return fmt.Sprintf("contact %q is used by %d watches (%s); point them at another contact or delete it with cascade to end them",
+		e.Name, len(e.Watches), strings.Join(names, ", "))
+}
+
+// DeleteContact removes owner's contact name from cfg. While any watch
+// names it the delete is refused with a *ContactInUseError, unless cascade
+// is set: those watches are then ended, their jobs deleted and registered
+// ones marked completed, and returned.
+func DeleteContact(ctx context.Context, cfg Config, owner string, name string, cascade bool) ([]JobName, error) {
+	store := newContactStore(cfg)
+	if store == nil {
+		return nil, fmt.Errorf("deleting contact %q: %w", name, errNoContactStore)
+	}
+	if _, err := store.GetContact(ctx, owner, name); err != nil {
+		return nil, fmt.Errorf("deleting contact %q: %w", name, err)
+	}
+	watches, err := ListWatches(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	using := []ListedWatch{}
+	for _, w := range watches {
+		if w.Watch.Contact != "" && contactKey(w.Watch.Owner, w.Watch.Contact) == contactKey(owner, name) {
+			using = append(using, w)
+		}
+	}
+	ended := []JobName{}
+	for _, w := range using {
+		ended = append(ended, w.Name)
+	}
+	if len(using) > 0 && !cascade {
+		return nil, &ContactInUseError{Name: name, Watches: ended}
+	}
+	if len(using) > 0 {
+		s, err := newWatchScheduler()
+		if err != nil {
+			return nil, err
+		}
+		registry := newWatchStore(cfg)
+		for _, w := range using {
+			if err := s.DeleteJob(ctx, w.Name.String()); err != nil && status.Code(err) != codes.NotFound {
+				return nil, fmt.Errorf("deleting job %s: %v", w.Name, err)
+			}
+			pruneJobState(ctx, w.Watch.Name)
+			markWatchCompleted(ctx, registry, w.Watch.Name)
+		}
+	}
+	if err := store.DeleteContact(ctx, owner, name); err != nil {
+		return nil, err
+	}
+	return ended, nil
+}
+
+// checkContact rejects a watch naming a contact store does not have, so a
+// typo is caught when the watch is created rather than when it alerts.
+func checkContact(ctx context.Context, store ContactStore, m MessageContent) error {
+	if m.Contact == "" {
+		return nil
+	}
+	if store == nil {
+		return &ValidationError{Field: "Contact", Value: m.Contact, Reason: "needs CONTACTS_COLLECTION"}
+	}
+	_, err := store.GetContact(ctx, m.Owner, m.Contact)
+	if errors.Is(err, ErrContactNotFound) {
+		return &ValidationError{Field: "Contact", Value: m.Contact, Reason: "is not a contact of " + ownerLabel(m.Owner)}
+	}
+	return err
+}
+
+func ownerLabel(owner string) string {
+	if owner == "" {
+		return "the deployment"
+	}
+	return owner
+}
+
+// resolveContact replaces m's Contact with the contact's destinations, so
+// the rest of the run reads them like any watch's own. When the contact
+// cannot be looked up, m is left with no destination of its own, so it
+// alerts the deployment's recipient instead, and the error says why.
+func (m *MessageContent) resolveContact(ctx context.Context) error {
+	if m.Contact == "" {
+		return nil
+	}
+	name := m.Contact
+	m.Contact = ""
+	store := newContactStore(activeConfig)
+	if store == nil {
+		return fmt.Errorf("contact %q: %w", name, errNoContactStore)
+	}
+	c, err := store.GetContact(ctx, m.Owner, name)
+	if err != nil {
+		return fmt.Errorf("contact %q of %s: %w", name, ownerLabel(m.Owner), err)
+	}
+	m.NotifyEmail, m.NotifyName, m.NotifyPhone, m.slackWebhook = c.Email, c.Name, c.Phone, c.Slack
+	return nil
+}
+
+// reportContactProblem tells the deployment's recipient that watch job
+// could not resolve its contact, at most once per contactProblemInterval,
+// since its alerts now go to them instead.
+func reportContactProblem(ctx context.Context, job string, err error) {
+	to := defaultRecipient()
+	logger.Printf("job %s: %v; alerting %s instead", job, err, to.Address)
+	claimed, claimErr := idempotencyStore.Claim(ctx, "contact-problem/"+job, contactProblemInterval)
+	if claimErr == nil && !claimed {
+		return
+	}
+	subject := fmt.Sprintf("Watch %s cannot reach its contact", job)
+	body := fmt.Sprintf("Watch %s could not look up its contact: %v. Until the contact is fixed or the watch is changed, its alerts come to this address instead.", job, err)
+	if err := sendNoticeTo(ctx, to, subject, body, "<p>"+html.EscapeString(body)+"</p>"); err != nil {
+		logger.Printf("job %s: reporting contact problem: %v", job, err)
+	}
+}
+
+// contactDeleted is the Contacts function's response to a delete.
+type contactDeleted struct {
+	Deleted string   `json:"deleted"`
+	Ended   []string `json:"ended_watches"`
+}
+
+// Contacts is an HTTP Cloud Function managing contacts: GET ?owner= lists
+// an owner's contacts, PUT or POST a Contact saves it, and DELETE
+// ?owner=&name= removes one, refused with 409 while watches use it unless
+// cascade=true ends them too. Callers authenticate with "Authorization:
+// Bearer <CONTACTS_API_KEY>".
+func Contacts(w http.ResponseWriter, r *http.Request) {
+	key := os.Getenv("CONTACTS_API_KEY")
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(given)) != 1 {
+		writeProblem(w, http.StatusUnauthorized, "missing or wrong API key")
+		return
+	}
+	ctx, query := r.Context(), r.URL.Query()
+	var result interface{}
+	var err error
+	switch r.Method {
+	case http.MethodGet:
+		result, err = ListContacts(ctx, activeConfig, query.Get("owner"))
+	case http.MethodPut, http.MethodPost:
+		body, readErr := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<16))
+		if readErr != nil {
+			writeProblem(w, http.StatusRequestEntityTooLarge, "body is over 64 KiB")
+			return
+		}
+		var c Contact
+		if err := json.Unmarshal(body, &c); err != nil {
+			writeProblem(w, http.StatusBadRequest, "malformed JSON: "+err.Error())
+			return
+		}
+		result, err = SaveContact(ctx, activeConfig, c)
+	case http.MethodDelete:
+		var ended []JobName
+		ended, err = DeleteContact(ctx, activeConfig, query.Get("owner"), query.Get("name"), query.Get("cascade") == "true")
+		deleted := contactDeleted{Deleted: query.Get("name"), Ended: []string{}}
+		for _, name := range ended {
+			deleted.Ended = append(deleted.Ended, name.Job)
+		}
+		result = deleted
+	default:
+		writeProblem(w, http.StatusMethodNotAllowed, "use GET, PUT, POST or DELETE")
+		return
+	}
+	var invalid *ValidationError
+	var inUse *ContactInUseError
+	switch {
+	case errors.As(err, &invalid):
+		writeProblem(w, http.StatusUnprocessableEntity, err.Error())
+	case errors.As(err, &inUse):
+		writeProblem(w, http.StatusConflict, err.Error())
+	case errors.Is(err, ErrContactNotFound):
+		writeProblem(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, errNoContactStore), errors.Is(err, errNoSecretsKey):
+		writeProblem(w, http.StatusNotImplemented, err.Error())
+	case err != nil:
+		writeProblem(w, http.StatusInternalServerError, err.Error())
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	}
+}
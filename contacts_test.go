@@ -0,0 +1,329 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// openFixture is e2eFixture with the stay open at the given sites only.
+func openFixture(sites ...string) fakerecgov.Fixture {
+	f := e2eFixture(false)
+	for i, site := range f.Campgrounds[0].Sites {
+		for _, open := range sites {
+			if site.ID == open {
+				f.Campgrounds[0].Sites[i].Nights = map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}
+			}
+		}
+	}
+	return f
+}
+
+// contactWatch is e2eWatch alerting sam@example.com's contact mom.
+func contactWatch(id string) scraper.MessageContent {
+	m := e2eWatch(id)
+	m.Contact, m.Owner = "Mom", "sam@example.com"
+	return m
+}
+
+// startContacts starts a harness whose alerts go out as email, with
+// NOTIFY_EMAIL as the deployment's recipient and contacts in h.Store.
+func startContacts(t *testing.T, f fakerecgov.Fixture) *fakerecgov.Harness {
+	t.Setenv("NOTIFY_EMAIL", "admin@example.com")
+	h := fakerecgov.Start(f)
+	t.Cleanup(h.Close)
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	t.Cleanup(scraper.UseServices(scraper.Services{Contacts: h.Store}))
+	t.Cleanup(scraper.UseChannels())
+	return h
+}
+
+// emails splits the notices sent since the first skip into alerts and the
+// deployment's contact problem notices.
+func emails(h *fakerecgov.Harness, skip int) (alerts []scraper.Notice, problems []scraper.Notice) {
+	for _, n := range h.Notifier.Notices()[skip:] {
+		if strings.Contains(n.Subject, "cannot reach its contact") {
+			problems = append(problems, n)
+		} else {
+			alerts = append(alerts, n)
+		}
+	}
+	return alerts, problems
+}
+
+// A watch naming a contact alerts the contact's destinations, and one
+// whose contact is gone alerts the deployment's recipient instead, saying
+// why, and tells them once.
+func TestContactAlerts(t *testing.T) {
+	tests := []struct {
+		name   string
+		stored bool
+		slack  bool
+		// wantTo is who the alert is emailed to.
+		wantTo    string
+		wantSlack int32
+		wantNote  bool
+	}{
+		{name: "email", stored: true, wantTo: "mom@example.com"},
+		{name: "slack", stored: true, slack: true, wantTo: "mom@example.com", wantSlack: 1},
+		{name: "missing", wantTo: "admin@example.com", wantNote: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := startContacts(t, openFixture("1001"))
+			var posts int32
+			slack := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&posts, 1)
+			}))
+			defer slack.Close()
+			if test.stored {
+				mom := scraper.Contact{Owner: "sam@example.com", Name: "mom", Email: "mom@example.com"}
+				if test.slack {
+					mom.Slack = slack.URL
+				}
+				h.Store.PutContact(context.Background(), mom)
+			}
+
+			if err := h.Run(context.Background(), contactWatch("contact-"+test.name)); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			alerts, problems := emails(h, 0)
+			if len(alerts) != 1 || alerts[0].To != test.wantTo {
+				t.Fatalf("sent alerts %+v, want one to %s", alerts, test.wantTo)
+			}
+			if note := strings.Contains(alerts[0].Plain, "contact could not be looked up"); note != test.wantNote {
+				t.Errorf("alert notes the missing contact: %v, want %v\n%s", note, test.wantNote, alerts[0].Plain)
+			}
+			if n := atomic.LoadInt32(&posts); n != test.wantSlack {
+				t.Errorf("posted to the contact's Slack %d times, want %d", n, test.wantSlack)
+			}
+			if test.wantNote && (len(problems) != 1 || problems[0].To != "admin@example.com") {
+				t.Errorf("sent problem notices %+v, want one to admin@example.com", problems)
+			}
+			if !test.wantNote && len(problems) != 0 {
+				t.Errorf("sent problem notices %+v, want none", problems)
+			}
+		})
+	}
+}
+
+// A persistent watch looks its contact up on every run: a changed contact
+// gets the next alert, and once it is gone alerts go to the deployment's
+// recipient, who is told of the problem once a day.
+func TestContactRuns(t *testing.T) {
+	steps := []struct {
+		name      string
+		contact   *scraper.Contact
+		delete    bool
+		open      []string
+		wantTo    string
+		wantNotes int
+	}{
+		{name: "first", contact: &scraper.Contact{Owner: "sam@example.com", Name: "mom", Email: "mom@example.com"}, open: []string{"1001"}, wantTo: "mom@example.com"},
+		{name: "contact changed", contact: &scraper.Contact{Owner: "sam@example.com", Name: "mom", Email: "mom@new.example"}, open: []string{"1001", "1002"}, wantTo: "mom@new.example"},
+		{name: "contact deleted", delete: true, open: []string{"1002"}, wantTo: "admin@example.com", wantNotes: 1},
+		{name: "still deleted", open: []string{"1001"}, wantTo: "admin@example.com"},
+	}
+	h := startContacts(t, openFixture())
+	ctx := context.Background()
+	m := contactWatch("contact-runs")
+	m.KeepJob = true
+	sent := 0
+	for i, step := range steps {
+		switch {
+		case step.contact != nil:
+			if _, err := scraper.SaveContact(ctx, testConfig, *step.contact); err != nil {
+				t.Fatalf("%s: SaveContact: %v", step.name, err)
+			}
+		case step.delete:
+			// Behind DeleteContact's back, which would refuse.
+			h.Store.DeleteContact(ctx, "sam@example.com", "mom")
+		}
+		h.Server.SetFixture(openFixture(step.open...))
+		var err error
+		if i == 0 {
+			err = h.Run(ctx, m)
+		} else {
+			err = h.Fire(ctx, m.Name)
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		alerts, problems := emails(h, sent)
+		sent = len(h.Notifier.Notices())
+		if len(alerts) != 1 || alerts[0].To != step.wantTo {
+			t.Errorf("%s: sent alerts %+v, want one to %s", step.name, alerts, step.wantTo)
+		}
+		if len(problems) != step.wantNotes {
+			t.Errorf("%s: sent %d problem notices, want %d", step.name, len(problems), step.wantNotes)
+		}
+	}
+}
+
+// A contact needs a name and at least one destination that parses, and a
+// watch naming one takes no destinations of its own.
+func TestContactValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		contact   scraper.Contact
+		watch     func(m *scraper.MessageContent)
+		wantField string
+	}{
+		{name: "email", contact: scraper.Contact{Name: "mom", Email: "mom@example.com"}},
+		{name: "phone and slack", contact: scraper.Contact{Name: "mom", Phone: "+14155550100", Slack: "https://hooks.slack.example/T1"}},
+		{name: "no name", contact: scraper.Contact{Name: " ", Email: "mom@example.com"}, wantField: "Name"},
+		{name: "no destination", contact: scraper.Contact{Name: "mom"}, wantField: "Email"},
+		{name: "bad email", contact: scraper.Contact{Name: "mom", Email: "Mom <mom@example.com>"}, wantField: "Email"},
+		{name: "bad phone", contact: scraper.Contact{Name: "mom", Phone: "555-0100"}, wantField: "Phone"},
+		{name: "bad slack", contact: scraper.Contact{Name: "mom", Slack: "hooks.slack.example"}, wantField: "Slack"},
+		{name: "watch with contact", watch: func(m *scraper.MessageContent) { m.Contact = "mom" }},
+		{name: "watch with contact and email", watch: func(m *scraper.MessageContent) { m.Contact, m.NotifyEmail = "mom", "me@example.com" }, wantField: "Contact"},
+		{name: "watch with contact and phone", watch: func(m *scraper.MessageContent) { m.Contact, m.NotifyPhone = "mom", "+14155550100" }, wantField: "Contact"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := test.contact.Validate()
+			if test.watch != nil {
+				m := e2eWatch("contact-validate")
+				test.watch(&m)
+				err = m.Validate()
+			}
+			var invalid *scraper.ValidationError
+			switch {
+			case test.wantField == "" && err != nil:
+				t.Errorf("Validate: %v, want nil", err)
+			case test.wantField != "" && (!errors.As(err, &invalid) || invalid.Field != test.wantField):
+				t.Errorf("Validate: %v, want an invalid %s", err, test.wantField)
+			}
+		})
+	}
+}
+
+// A watch can only be created for a contact its owner has, and a contact
+// with watches is deleted only with cascade, which ends them.
+func TestDeleteContact(t *testing.T) {
+	tests := []struct {
+		name    string
+		watches int
+		cascade bool
+		missing bool
+		// wantInUse is set when the delete is refused.
+		wantInUse   bool
+		wantEnded   int
+		wantDeleted bool
+	}{
+		{name: "unused", wantDeleted: true},
+		{name: "in use", watches: 2, wantInUse: true},
+		{name: "cascade", watches: 2, cascade: true, wantEnded: 2, wantDeleted: true},
+		{name: "missing", missing: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := startContacts(t, openFixture())
+			defer scraper.UseServices(scraper.Services{Watches: h.Store})()
+			ctx := context.Background()
+			if !test.missing {
+				h.Store.PutContact(ctx, scraper.Contact{Owner: "sam@example.com", Name: "mom", Email: "mom@example.com"})
+			}
+			for i := 0; i < test.watches; i++ {
+				m := newWatch("2027-07-14", "2027-07-16")
+				m.NotifyEmail, m.Contact, m.Owner = "", "mom", "sam@example.com"
+				m.Arrival = time.Date(2027, 7, 14+3*i, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+				m.Departure = time.Date(2027, 7, 16+3*i, 0, 0, 0, 0, time.UTC).Format("2006-01-02")
+				if _, err := scraper.CreateWatch(ctx, testConfig, m, "*/10 * * * *", scraper.CreateOptions{}); err != nil {
+					t.Fatalf("CreateWatch: %v", err)
+				}
+			}
+
+			ended, err := scraper.DeleteContact(ctx, testConfig, "SAM@example.com", "Mom", test.cascade)
+			var inUse *scraper.ContactInUseError
+			if errors.As(err, &inUse) != test.wantInUse {
+				t.Errorf("DeleteContact error %v, want in use %v", err, test.wantInUse)
+			}
+			if test.missing && !errors.Is(err, scraper.ErrContactNotFound) {
+				t.Errorf("DeleteContact error %v, want ErrContactNotFound", err)
+			}
+			if len(ended) != test.wantEnded {
+				t.Errorf("ended %v, want %d watches", ended, test.wantEnded)
+			}
+			_, getErr := h.Store.GetContact(ctx, "sam@example.com", "mom")
+			if deleted := errors.Is(getErr, scraper.ErrContactNotFound); deleted != (test.wantDeleted || test.missing) {
+				t.Errorf("contact deleted: %v, want %v", deleted, test.wantDeleted)
+			}
+			records, _ := h.Store.ListWatchRecords(ctx)
+			jobs, _ := h.Scheduler.ListJobs(ctx, testConfig.Parent())
+			if want := test.watches - test.wantEnded; len(jobs) != want {
+				t.Errorf("got %d jobs, want %d", len(jobs), want)
+			}
+			for _, r := range records {
+				if completed := r.Status == scraper.WatchCompleted; completed != test.cascade {
+					t.Errorf("watch %s is %s after the delete", r.ID, r.Status)
+				}
+			}
+		})
+	}
+}
+
+// CreateWatch rejects a contact the owner does not have.
+func TestCreateWatchUnknownContact(t *testing.T) {
+	h := startContacts(t, openFixture())
+	ctx := context.Background()
+	h.Store.PutContact(ctx, scraper.Contact{Owner: "sam@example.com", Name: "mom", Email: "mom@example.com"})
+	m := newWatch("2027-07-14", "2027-07-16")
+	m.NotifyEmail, m.Contact, m.Owner = "", "mom", "alex@example.com"
+	_, err := scraper.CreateWatch(ctx, testConfig, m, "*/10 * * * *", scraper.CreateOptions{})
+	var invalid *scraper.ValidationError
+	if !errors.As(err, &invalid) || invalid.Field != "Contact" {
+		t.Errorf("CreateWatch error %v, want an invalid Contact", err)
+	}
+}
+
+// The Contacts function saves, lists and deletes contacts for callers with
+// the API key.
+func TestContactsHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		target   string
+		body     string
+		key      string
+		wantCode int
+		wantBody string
+	}{
+		{name: "no key", method: "GET", target: "/?owner=sam@example.com", key: "wrong", wantCode: 401},
+		{name: "list", method: "GET", target: "/?owner=sam@example.com", wantCode: 200, wantBody: `"email":"mom@example.com"`},
+		{name: "list other owner", method: "GET", target: "/?owner=alex@example.com", wantCode: 200, wantBody: "[]"},
+		{name: "save", method: "PUT", target: "/", body: `{"owner":"sam@example.com","name":"dad","phone":"+14155550100"}`, wantCode: 200, wantBody: `"name":"dad"`},
+		{name: "save invalid", method: "PUT", target: "/", body: `{"owner":"sam@example.com","name":"dad"}`, wantCode: 422},
+		{name: "malformed", method: "POST", target: "/", body: `{`, wantCode: 400},
+		{name: "delete", method: "DELETE", target: "/?owner=sam@example.com&name=mom", wantCode: 200, wantBody: `"deleted":"mom"`},
+		{name: "delete missing", method: "DELETE", target: "/?owner=sam@example.com&name=dad", wantCode: 404},
+		{name: "method", method: "PATCH", target: "/", wantCode: 405},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("CONTACTS_API_KEY", "test-key")
+			h := startContacts(t, openFixture())
+			h.Store.PutContact(context.Background(), scraper.Contact{Owner: "sam@example.com", Name: "mom", Email: "mom@example.com"})
+			r := httptest.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			key := test.key
+			if key == "" {
+				key = "test-key"
+			}
+			r.Header.Set("Authorization", "Bearer "+key)
+			w := httptest.NewRecorder()
+			scraper.Contacts(w, r)
+			if w.Code != test.wantCode || !strings.Contains(w.Body.String(), test.wantBody) {
+				t.Errorf("got %d %s, want %d containing %s", w.Code, w.Body, test.wantCode, test.wantBody)
+			}
+		})
+	}
+}
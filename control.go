@@ -0,0 +1,131 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+const (
+	// controlObject is the operator control document in CONTROL_BUCKET.
+	controlObject = "control.json"
+	// controlTTL is how long a function instance trusts its cached copy, so
+	// a pause takes effect within a minute without a read per scrape.
+	controlTTL = time.Minute
+)
+
+// OperatorControl lets an operator stop scraping without touching watches.
+type OperatorControl struct {
+	// Paused stops every scrape.
+	Paused bool   `json:"paused"`
+	Reason string `json:"reason,omitempty"`
+	// Blocked lists campground IDs that are never scraped.
+	Blocked   []string  `json:"blocked,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Blocks reports whether a scrape of campgroundID should be skipped, and why.
+func (c OperatorControl) Blocks(campgroundID string) (bool, string) {
+	if c.Paused {
+		return true, "all scraping paused: " + c.Reason
+	}
+	for _, id := range c.Blocked {
+		if id == campgroundID {
+			return true, "campground " + campgroundID + " is blocklisted"
+		}
+	}
+	return false, ""
+}
+
+// Block adds campgroundID to the blocklist if it is not already there.
+func (c *OperatorControl) Block(campgroundID string) {
+	for _, id := range c.Blocked {
+		if id == campgroundID {
+			return
+		}
+	}
+	c.Blocked = append(c.Blocked, campgroundID)
+	sort.Strings(c.Blocked)
+}
+
+// Unblock removes campgroundID from the blocklist.
+func (c *OperatorControl) Unblock(campgroundID string) {
+	kept := c.Blocked[:0]
+	for _, id := range c.Blocked {
+		if id != campgroundID {
+			kept = append(kept, id)
+		}
+	}
+	c.Blocked = kept
+}
+
+var controlCache struct {
+	sync.Mutex
+	control OperatorControl
+	fetched time.Time
+}
+
+// currentControl returns the operator control document, cached for
+// controlTTL. With no CONTROL_BUCKET, or when the document cannot be read,
+// nothing is blocked: a broken control plane must not stop every watch.
+func currentControl(ctx context.Context) OperatorControl {
+	bucket := os.Getenv("CONTROL_BUCKET")
+	if bucket == "" {
+		return OperatorControl{}
+	}
+	controlCache.Lock()
+	defer controlCache.Unlock()
+	if !controlCache.fetched.IsZero() && DefaultScraper.since(controlCache.fetched) < controlTTL {
+		return controlCache.control
+	}
+	control, err := LoadControl(ctx, bucket)
+	if err != nil {
+		logger.Println("reading operator control, assuming none:", err)
+		return controlCache.control
+	}
+	controlCache.control, controlCache.fetched = control, DefaultScraper.now()
+	return control
+}
+
+// LoadControl reads the control document from bucket. A missing document is
+// the zero OperatorControl.
+func LoadControl(ctx context.Context, bucket string) (OperatorControl, error) {
+	control := OperatorControl{}
+	client, err := storageClient()
+	if err != nil {
+		return control, err
+	}
+	r, err := client.Bucket(bucket).Object(controlObject).NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return control, nil
+	}
+	if err != nil {
+		return control, err
+	}
+	defer r.Close()
+	err = json.NewDecoder(r).Decode(&control)
+	return control, err
+}
+
+// SaveControl writes the control document to bucket, stamping UpdatedAt.
+// Running functions pick it up within controlTTL.
+func SaveControl(ctx context.Context, bucket string, control OperatorControl) error {
+	control.UpdatedAt = DefaultScraper.now().UTC()
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	w := client.Bucket(bucket).Object(controlObject).NewWriter(ctx)
+	w.ContentType = "application/json"
+	w.CacheControl = "no-store"
+	if err := json.NewEncoder(w).Encode(control); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
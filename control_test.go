@@ -0,0 +1,149 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+func TestOperatorControlBlocks(t *testing.T) {
+	tests := []struct {
+		name       string
+		control    OperatorControl
+		campground string
+		want       bool
+	}{
+		{"nothing set", OperatorControl{}, "232447", false},
+		{"paused", OperatorControl{Paused: true, Reason: "asked to back off"}, "232447", true},
+		{"blocklisted", OperatorControl{Blocked: []string{"232447"}}, "232447", true},
+		{"other campground blocklisted", OperatorControl{Blocked: []string{"232450"}}, "232447", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got, why := test.control.Blocks(test.campground); got != test.want {
+				t.Errorf("Blocks(%s) = %v (%q), want %v", test.campground, got, why, test.want)
+			}
+		})
+	}
+}
+
+func TestOperatorControlBlockUnblock(t *testing.T) {
+	c := OperatorControl{}
+	c.Block("2")
+	c.Block("1")
+	c.Block("2")
+	if len(c.Blocked) != 2 || c.Blocked[0] != "1" || c.Blocked[1] != "2" {
+		t.Fatalf("Blocked = %v, want [1 2]", c.Blocked)
+	}
+	c.Unblock("1")
+	c.Unblock("3")
+	if len(c.Blocked) != 1 || c.Blocked[0] != "2" {
+		t.Fatalf("Blocked = %v, want [2]", c.Blocked)
+	}
+}
+
+// useControl makes currentControl return control until the test ends, as if
+// it had just been read from CONTROL_BUCKET.
+func useControl(t *testing.T, control OperatorControl) {
+	t.Helper()
+	t.Setenv("CONTROL_BUCKET", "control-test")
+	controlCache.Lock()
+	controlCache.control, controlCache.fetched = control, DefaultScraper.now()
+	controlCache.Unlock()
+	t.Cleanup(func() {
+		controlCache.Lock()
+		controlCache.control, controlCache.fetched = OperatorControl{}, time.Time{}
+		controlCache.Unlock()
+	})
+}
+
+func TestScrapeFromMessageHonorsControl(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+	saved := DefaultScraper
+	defer func() { DefaultScraper = saved }()
+	DefaultScraper = NewScraper(nil, core.RetryPolicy{})
+	DefaultScraper.BaseURL, DefaultScraper.Cache = server.URL, nil
+
+	single := MessageContent{Name: "projects/p/locations/l/jobs/j", Campground: "232447", Arrival: "2027-07-14", Departure: "2027-07-16"}
+	multi := single
+	multi.Campground, multi.Campgrounds = "", []string{"232447", "232450"}
+	tests := []struct {
+		name    string
+		watch   MessageContent
+		control OperatorControl
+	}{
+		{"paused", single, OperatorControl{Paused: true}},
+		{"blocklisted", single, OperatorControl{Blocked: []string{"232447"}}},
+		{"several paused", multi, OperatorControl{Paused: true}},
+		{"several blocklisted", multi, OperatorControl{Blocked: []string{"232447", "232450"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useControl(t, test.control)
+			data, _ := json.Marshal(test.watch)
+			run := &watchRun{}
+			if err := scrapeMessage(context.Background(), pubsub.Message{Data: data}, run); err != nil {
+				t.Errorf("scrapeMessage with %+v: %v", test.control, err)
+			}
+			if run.outcome != outcomePaused {
+				t.Errorf("outcome %q, want %q", run.outcome, outcomePaused)
+			}
+		})
+	}
+	if n := atomic.LoadInt32(&requests); n != 0 {
+		t.Errorf("recreation.gov got %d requests while blocked", n)
+	}
+}
+
+// A blocklisted campground of a multi-campground watch is listed as paused,
+// and whether the run failed is judged on the others alone.
+func TestMatchCampgroundsPaused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+	s := NewScraper(nil, core.RetryPolicy{})
+	s.BaseURL, s.Cache = server.URL, nil
+	useControl(t, OperatorControl{Blocked: []string{"232447"}})
+	m := MessageContent{Name: "projects/p/locations/l/jobs/j", Campgrounds: []string{"232447", "232450"}, Arrival: "2027-07-14", Departure: "2027-07-16"}
+
+	a, err := s.matchCampgrounds(context.Background(), m, false)
+	multi, ok := err.(*MultiError)
+	if !ok || multi.Attempted != 1 {
+		t.Fatalf("error %v, want the one campground scraped to have failed", err)
+	}
+	if why := a.Paused["232447"]; why != "campground 232447 is blocklisted" || len(a.Paused) != 1 {
+		t.Errorf("paused %v, want 232447 blocklisted", a.Paused)
+	}
+	if _, ok := a.Failed["232450"]; !ok || len(a.Failed) != 1 {
+		t.Errorf("failed %v, want only 232450", a.Failed)
+	}
+}
+
+// Ingest refuses results for a blocked campground before looking up the
+// watch, so nothing is delivered for it.
+func TestIngestHonorsControl(t *testing.T) {
+	t.Setenv("INGEST_API_KEY", "test-key")
+	useControl(t, OperatorControl{Blocked: []string{"232447"}})
+	body, _ := json.Marshal(ScrapeResult{Provider: "external", JobName: "projects/p/locations/l/jobs/j", CampgroundID: "232447",
+		Arrival: time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC), Departure: time.Date(2027, 7, 16, 0, 0, 0, 0, time.UTC), Sites: []string{"1001"}})
+	r := httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body))
+	r.Header.Set("Authorization", "Bearer test-key")
+	w := httptest.NewRecorder()
+	Ingest(w, r)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "232447 is blocklisted") {
+		t.Errorf("Ingest answered %d %s, want a 409 saying the campground is blocklisted", w.Code, w.Body)
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// attributeOperators are the comparisons a required attribute value may
+// start with, longest first so ">=" is not read as ">".
+var attributeOperators = []string{">=", "<=", "!=", ">", "<", "="}
+
+// attributeKey is how attribute names are compared: lower-cased, with
+// surrounding space dropped.
+func attributeKey(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// parseRequirement splits a required attribute value into its operator and
+// operand. A value without an operator is an equality test, and an empty
+// value only requires the attribute to be listed.
+func parseRequirement(expr string) (op string, operand string) {
+	expr = strings.TrimSpace(expr)
+	for _, candidate := range attributeOperators {
+		if strings.HasPrefix(expr, candidate) {
+			return candidate, strings.TrimSpace(expr[len(candidate):])
+		}
+	}
+	return "=", expr
+}
+
+// ValidateAttributeRequirement reports whether expr is a usable required
+// attribute value: empty, a plain value such as "Yes", or a comparison such
+// as ">=30". Orderings need a number.
+func ValidateAttributeRequirement(expr string) error {
+	op, operand := parseRequirement(expr)
+	if op == "=" || op == "!=" {
+		return nil
+	}
+	if _, err := strconv.ParseFloat(operand, 64); err != nil {
+		return fmt.Errorf("%q compares with %s but %q is not a number", expr, op, operand)
+	}
+	return nil
+}
+
+// leadingNumber reads the number a value starts with, such as 40 from
+// "40 ft".
+func leadingNumber(value string) (float64, bool) {
+	fields := strings.Fields(value)
+	if len(fields) == 0 {
+		return 0, false
+	}
+	n, err := strconv.ParseFloat(fields[0], 64)
+	return n, err == nil
+}
+
+// meets reports whether value satisfies the requirement expr. Text is
+// compared ignoring case, and as numbers when both sides are numbers.
+func meets(value string, expr string) bool {
+	op, operand := parseRequirement(expr)
+	if operand == "" && op == "=" {
+		return true
+	}
+	have, haveNum := leadingNumber(value)
+	want, err := strconv.ParseFloat(operand, 64)
+	if haveNum && err == nil {
+		switch op {
+		case ">=":
+			return have >= want
+		case "<=":
+			return have <= want
+		case ">":
+			return have > want
+		case "<":
+			return have < want
+		case "!=":
+			return have != want
+		default:
+			return have == want
+		}
+	}
+	switch op {
+	case "=":
+		return strings.EqualFold(strings.TrimSpace(value), operand)
+	case "!=":
+		return !strings.EqualFold(strings.TrimSpace(value), operand)
+	}
+	return false
+}
+
+// MatchAttributes checks a campsite's attributes, as in
+// CampsiteDetail.Attributes, against required, keyed by attribute name in
+// any case. missing lists, sorted, the required attributes the campsite
+// does not have at all; a campsite with any missing does not match.
+func MatchAttributes(attrs map[string]string, required map[string]string) (matched bool, missing []string) {
+	matched = true
+	for name, expr := range required {
+		value, ok := attrs[attributeKey(name)]
+		if !ok {
+			missing = append(missing, name)
+			matched = false
+			continue
+		}
+		if !meets(value, expr) {
+			matched = false
+		}
+	}
+	sort.Strings(missing)
+	return matched, missing
+}
@@ -0,0 +1,176 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// SiteSummary counts how a campsite's nights in the requested range break
+// down by status. Open counts walk-up nights, NotYetReleased nights not yet
+// open for booking, and NotReservable both kinds of not reservable. Missing
+// counts nights the payload had no entry for; Other counts any status not
+// listed here, such as "Not Available" or an unknown one. Remaining is set
+// for sites booked by the unit and is the fewest units left on any night.
+type SiteSummary struct {
+	CampsiteID     string
+	Site           string
+	CampsiteType   string
+	Available      int
+	Reserved       int
+	NotReservable  int
+	Open           int
+	NotYetReleased int
+	Other          int
+	Missing        int
+	Remaining      int
+}
+
+// label is the campsite's name where recreation.gov gives one.
+func (s SiteSummary) label() string {
+	if s.Site != "" {
+		return s.Site
+	}
+	return s.CampsiteID
+}
+
+// AvailabilityResult is the outcome of checking a campground for a stay:
+// the sites available every night, and a per-site status breakdown that
+// explains why the others did not match. Class sums the result up, and
+// LastUnreleased is the latest night any site reported as not yet
+// released, zero when there is none.
+type AvailabilityResult struct {
+	Sites          []string
+	Nights         int
+	Summaries      []SiteSummary
+	Class          Classification
+	LastUnreleased CivilDate
+}
+
+// BestPartial returns the site that is not fully available but has the most
+// available nights, if any site has at least one.
+func (r AvailabilityResult) BestPartial() (SiteSummary, bool) {
+	best, found := SiteSummary{}, false
+	for _, s := range r.Summaries {
+		if s.Available < r.Nights && s.Available > best.Available {
+			best, found = s, true
+		}
+	}
+	return best, found
+}
+
+// Summary is a one-line account of the result, such as "12 sites checked, 0
+// fully available, best partial match: site 042 with 3/4 nights".
+func (r AvailabilityResult) Summary() string {
+	line := fmt.Sprintf("%d sites checked, %d fully available", len(r.Summaries), len(r.Sites))
+	if len(r.Sites) > 0 {
+		return line
+	}
+	if best, ok := r.BestPartial(); ok {
+		return fmt.Sprintf("%s, best partial match: site %s with %d/%d nights", line, best.label(), best.Available, r.Nights)
+	}
+	var reserved, notReservable, open, notReleased, missing int
+	for _, s := range r.Summaries {
+		reserved += s.Reserved
+		notReservable += s.NotReservable
+		open += s.Open
+		notReleased += s.NotYetReleased
+		missing += s.Missing
+	}
+	line = fmt.Sprintf("%s; no site has any open night (%d reserved, %d not reservable, %d missing site-nights", line, reserved, notReservable, missing)
+	if open > 0 {
+		line += fmt.Sprintf(", %d first-come first-served", open)
+	}
+	if notReleased > 0 {
+		line += fmt.Sprintf(", %d not yet released", notReleased)
+	}
+	return line + ")"
+}
+
+// Filter returns the result restricted to the campsites f accepts.
+func (r AvailabilityResult) Filter(f SiteFilter) AvailabilityResult {
+	if f.IsZero() {
+		return r
+	}
+	kept := AvailabilityResult{Sites: []string{}, Nights: r.Nights, Class: r.Class, LastUnreleased: r.LastUnreleased}
+	accepted := map[string]bool{}
+	for _, s := range r.Summaries {
+		if f.Keeps(s.CampsiteID, s.CampsiteType) {
+			accepted[s.CampsiteID] = true
+			kept.Summaries = append(kept.Summaries, s)
+		}
+	}
+	for _, id := range r.Sites {
+		if accepted[id] {
+			kept.Sites = append(kept.Sites, id)
+		}
+	}
+	if r.Class != ClassClosed {
+		kept.Class = kept.classify()
+	}
+	return kept
+}
+
+// ScrapeDetailed is Scrape returning the full AvailabilityResult. A closed
+// campground is returned as a ClassClosed result with a *SeasonClosedError.
+func ScrapeDetailed(ctx context.Context, p Provider, campgroundID string, arrival CivilDate, departure CivilDate) (AvailabilityResult, error) {
+	campground, err := FetchRange(ctx, p, campgroundID, arrival, departure)
+	if err != nil {
+		return AvailabilityResult{}, err
+	}
+	if seasonClosed(campground, Nights(arrival, departure)) {
+		return AvailabilityResult{Class: ClassClosed}, &SeasonClosedError{CampgroundID: campgroundID, Arrival: arrival, Departure: departure}
+	}
+	return CheckAvailability(ctx, campground, arrival, departure)
+}
+
+// CheckAvailability summarises every campsite in campground for the nights
+// from arrival up to departure. ctx is checked before each campsite; when it
+// is done the result so far is returned with a *PartialResultError.
+func CheckAvailability(ctx context.Context, campground Campground, arrival CivilDate, departure CivilDate) (AvailabilityResult, error) {
+	dates := Nights(arrival, departure)
+	result := AvailabilityResult{Sites: []string{}, Nights: len(dates)}
+
+	ids := campground.SiteIDs()
+	for checked, id := range ids {
+		if err := ctx.Err(); err != nil {
+			result.Class = result.classify()
+			return result, &PartialResultError{Checked: checked, Total: len(ids), Err: err}
+		}
+		site := campground.Campsites[id]
+		summary := SiteSummary{CampsiteID: id, Site: site.Site, CampsiteType: site.CampsiteType}
+		counted := false
+		for _, date := range dates {
+			if remaining, ok := site.Quantities[date]; ok && (!counted || remaining < summary.Remaining) {
+				summary.Remaining, counted = remaining, true
+			}
+			raw, ok := site.Availabilities[date]
+			if !ok {
+				summary.Missing++
+				continue
+			}
+			switch ParseStatus(raw) {
+			case StatusAvailable:
+				summary.Available++
+			case StatusReserved:
+				summary.Reserved++
+			case StatusNotReservable, StatusNotReservableManagement:
+				summary.NotReservable++
+			case StatusOpen:
+				summary.Open++
+			case StatusNotYetReleased:
+				summary.NotYetReleased++
+				if date.After(result.LastUnreleased) {
+					result.LastUnreleased = date
+				}
+			default:
+				summary.Other++
+			}
+		}
+		if len(dates) > 0 && summary.Available == len(dates) {
+			result.Sites = append(result.Sites, id)
+		}
+		result.Summaries = append(result.Summaries, summary)
+	}
+	result.Class = result.classify()
+	return result, nil
+}
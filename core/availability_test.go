@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// cancellingProvider serves one campground and cancels the scrape's context
+// as it does, as a deadline arriving mid-scrape would.
+type cancellingProvider struct {
+	campground Campground
+	cancel     context.CancelFunc
+}
+
+func (p cancellingProvider) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	p.cancel()
+	return p.campground, nil
+}
+
+func TestMatchingStopsWhenCancelled(t *testing.T) {
+	campground, err := decodeCampground(readFixture(t, "month.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrival := CivilDate{2027, time.July, 14}
+	departure := arrival.AddDays(2)
+	search := WindowSearch{WindowStart: arrival, WindowEnd: departure.AddDays(14), Nights: 2}
+	tests := []struct {
+		name   string
+		scrape func(ctx context.Context, p Provider) error
+	}{
+		{"stay", func(ctx context.Context, p Provider) error {
+			_, err := ScrapeDetailed(ctx, p, "232447", arrival, departure)
+			return err
+		}},
+		{"partial stays", func(ctx context.Context, p Provider) error {
+			_, err := ScrapePartialStays(ctx, p, "232447", arrival, departure, 1)
+			return err
+		}},
+		{"window", func(ctx context.Context, p Provider) error {
+			_, err := ScrapeRuns(ctx, p, "232447", search)
+			return err
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			err := test.scrape(ctx, cancellingProvider{campground: campground, cancel: cancel})
+			var partial *PartialResultError
+			if !errors.As(err, &partial) {
+				t.Fatalf("error %v is not a *PartialResultError", err)
+			}
+			if partial.Checked != 0 || partial.Total != len(campground.Campsites) || !errors.Is(err, context.Canceled) {
+				t.Errorf("got %+v, want 0 of %d checked, cancelled", partial, len(campground.Campsites))
+			}
+		})
+	}
+}
+
+// mixed.json has a site partly booked, one with a night missing, one with
+// no nights in July's middle at all, one walk-up, unreleased and not
+// reservable, and one open from the 14th through the 16th.
+func TestCheckAvailabilityMixed(t *testing.T) {
+	campground, err := decodeCampground(readFixture(t, "mixed.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := func(id string, available, reserved, notReservable, open, notYetReleased, missing int) SiteSummary {
+		return SiteSummary{CampsiteID: "300" + id, Site: "C00" + id, CampsiteType: "STANDARD NONELECTRIC", Available: available,
+			Reserved: reserved, NotReservable: notReservable, Open: open, NotYetReleased: notYetReleased, Missing: missing}
+	}
+	tests := []struct {
+		name        string
+		arrival     string
+		departure   string
+		wantSites   []string
+		wantSummary []SiteSummary
+		wantLine    string
+	}{
+		{name: "one site open throughout", arrival: "2027-07-14", departure: "2027-07-17", wantSites: []string{"3005"},
+			wantSummary: []SiteSummary{site("1", 2, 1, 0, 0, 0, 0), site("2", 2, 0, 0, 0, 0, 1), site("3", 0, 0, 0, 0, 0, 3),
+				site("4", 0, 0, 1, 1, 1, 0), site("5", 3, 0, 0, 0, 0, 0)},
+			wantLine: "5 sites checked, 1 fully available"},
+		{name: "best partial", arrival: "2027-07-15", departure: "2027-07-18", wantSites: []string{},
+			wantSummary: []SiteSummary{site("1", 1, 1, 0, 0, 0, 1), site("2", 1, 0, 0, 0, 0, 2), site("3", 0, 0, 0, 0, 0, 3),
+				site("4", 0, 0, 1, 0, 1, 1), site("5", 2, 0, 0, 0, 0, 1)},
+			wantLine: "5 sites checked, 0 fully available, best partial match: site C005 with 2/3 nights"},
+		{name: "no open night", arrival: "2027-07-01", departure: "2027-07-03", wantSites: []string{},
+			wantSummary: []SiteSummary{site("1", 0, 0, 0, 0, 0, 2), site("2", 0, 0, 0, 0, 0, 2), site("3", 0, 1, 0, 0, 0, 1),
+				site("4", 0, 0, 0, 0, 0, 2), site("5", 0, 0, 0, 0, 0, 2)},
+			wantLine: "5 sites checked, 0 fully available; no site has any open night (1 reserved, 0 not reservable, 9 missing site-nights)"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := CheckAvailability(context.Background(), campground, mustCivilDate(t, test.arrival), mustCivilDate(t, test.departure))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Sites, test.wantSites) {
+				t.Errorf("sites %v, want %v", result.Sites, test.wantSites)
+			}
+			if !reflect.DeepEqual(result.Summaries, test.wantSummary) {
+				t.Errorf("summaries\n%+v\nwant\n%+v", result.Summaries, test.wantSummary)
+			}
+			if got := result.Summary(); got != test.wantLine {
+				t.Errorf("Summary() = %q, want %q", got, test.wantLine)
+			}
+		})
+	}
+}
+
+// At group.json's campground, a site booked by the unit matches while units
+// remain every night, and its Remaining is the fewest left on any of them.
+func TestCheckAvailabilityQuantities(t *testing.T) {
+	campground, err := decodeCampground(readFixture(t, "group.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name          string
+		arrival       string
+		departure     string
+		wantSites     []string
+		wantRemaining map[string]int
+	}{
+		{name: "two nights", arrival: "2027-07-14", departure: "2027-07-16", wantSites: []string{"4001", "4004", "4002"},
+			wantRemaining: map[string]int{"4001": 2, "4002": 9}},
+		{name: "one night", arrival: "2027-07-15", departure: "2027-07-16", wantSites: []string{"4001", "4003", "4004", "4002"},
+			wantRemaining: map[string]int{"4001": 3, "4002": 9, "4003": 1}},
+		{name: "three nights", arrival: "2027-07-14", departure: "2027-07-17", wantSites: []string{"4001"},
+			wantRemaining: map[string]int{"4001": 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, err := CheckAvailability(context.Background(), campground, mustCivilDate(t, test.arrival), mustCivilDate(t, test.departure))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(result.Sites, test.wantSites) {
+				t.Errorf("sites %v, want %v", result.Sites, test.wantSites)
+			}
+			remaining := map[string]int{}
+			for _, summary := range result.Summaries {
+				if summary.Remaining > 0 {
+					remaining[summary.CampsiteID] = summary.Remaining
+				}
+			}
+			if !reflect.DeepEqual(remaining, test.wantRemaining) {
+				t.Errorf("remaining %v, want %v", remaining, test.wantRemaining)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is how long a MemoryCache made by NewMemoryCache keeps a
+// month: short enough that a site opening up is seen within a run or two of
+// a five-minute schedule, long enough to share one fetch between the watches
+// on a campground that fire together.
+const DefaultCacheTTL = 2 * time.Minute
+
+// Cache stores decoded months between fetches. Cached campgrounds are shared
+// between callers, so nothing may modify one after it is stored.
+type Cache interface {
+	Get(key string) (Campground, bool)
+	Set(key string, campground Campground)
+}
+
+// CacheKey is the key a CachedProvider stores a campground's month under.
+func CacheKey(campgroundID string, month time.Time) string {
+	return campgroundID + "/" + month.Format("2006-01")
+}
+
+var (
+	cacheHits   = expvar.NewInt("month_cache_hits")
+	cacheMisses = expvar.NewInt("month_cache_misses")
+)
+
+// CachedProvider serves months from Cache, fetching them from Provider on a
+// miss. Errors are not cached.
+type CachedProvider struct {
+	Provider Provider
+	Cache    Cache
+}
+
+// FetchMonth implements Provider.
+func (p CachedProvider) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	key := CacheKey(campgroundID, month)
+	if campground, ok := p.Cache.Get(key); ok {
+		cacheHits.Add(1)
+		return campground, nil
+	}
+	cacheMisses.Add(1)
+	campground, err := p.Provider.FetchMonth(ctx, campgroundID, month)
+	if err != nil {
+		return campground, err
+	}
+	p.Cache.Set(key, campground)
+	return campground, nil
+}
+
+// MemoryCache is a Cache held in process memory, with entries expiring TTL
+// after they are set. A Cloud Functions instance is reused between
+// invocations, so it also saves fetches across runs on the same instance.
+type MemoryCache struct {
+	TTL time.Duration
+	// Clock dates the entries. Nil means the wall clock.
+	Clock Clock
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	campground Campground
+	expires    time.Time
+}
+
+// NewMemoryCache returns an empty MemoryCache keeping entries for ttl.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{TTL: ttl}
+}
+
+// Get implements Cache.
+func (c *MemoryCache) Get(key string) (Campground, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[key]
+	if !ok || !clockNow(c.Clock).Before(entry.expires) {
+		return Campground{}, false
+	}
+	return entry.campground, true
+}
+
+// Set implements Cache. Expired entries are dropped as it goes so the map
+// only ever holds what is live.
+func (c *MemoryCache) Set(key string, campground Campground) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := clockNow(c.Clock)
+	if c.entries == nil {
+		c.entries = map[string]cacheEntry{}
+	}
+	for k, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = cacheEntry{campground: campground, expires: now.Add(c.TTL)}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a Clock that only moves when told to.
+type fakeClock struct{ at time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.at }
+func (c *fakeClock) advance(d time.Duration) { c.at = c.at.Add(d) }
+
+func TestMemoryCacheExpiry(t *testing.T) {
+	tests := []struct {
+		name    string
+		elapsed time.Duration
+		want    bool
+	}{
+		{"fresh", 0, true},
+		{"just before the TTL", time.Minute - time.Nanosecond, true},
+		{"at the TTL", time.Minute, false},
+		{"long after", time.Hour, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{at: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)}
+			c := &MemoryCache{TTL: time.Minute, Clock: clock}
+			c.Set("232447/2027-07", Campground{})
+			clock.advance(test.elapsed)
+			if _, ok := c.Get("232447/2027-07"); ok != test.want {
+				t.Errorf("Get after %v hit = %v, want %v", test.elapsed, ok, test.want)
+			}
+		})
+	}
+}
+
+func TestMemoryCacheSetDropsExpired(t *testing.T) {
+	clock := &fakeClock{at: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)}
+	c := &MemoryCache{TTL: time.Minute, Clock: clock}
+	c.Set("old", Campground{})
+	clock.advance(2 * time.Minute)
+	c.Set("new", Campground{})
+	if _, ok := c.entries["old"]; ok {
+		t.Error("expired entry kept after Set")
+	}
+	if _, ok := c.Get("new"); !ok {
+		t.Error("fresh entry missing")
+	}
+}
@@ -0,0 +1,119 @@
+// Package core finds campsite availability on recreation.gov. It has no
+// cloud dependencies so it can be embedded in any binary; the scraper
+// package wraps it with the Cloud Functions entry points, scheduler helpers
+// and notifiers.
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Campground is one month of availability for a campground, keyed by
+// campsite ID.
+type Campground struct {
+	Campsites map[string]Campsite
+	// Conflicts lists campsite metadata that differed between the months
+	// merged into this campground by FetchRange.
+	Conflicts []MergeConflict `json:"-"`
+}
+
+// Campsite is one campsite and its status on each night of the month.
+type Campsite struct {
+	CampsiteID     int    `json:"campsite_id"`
+	CampsiteType   string `json:"campsite_type"`
+	Loop           string `json:"loop"`
+	Site           string `json:"site"`
+	Availabilities map[CivilDate]string
+	// Quantities is set for sites booked by the unit, such as group and
+	// overflow areas, and holds how many units remain each night.
+	Quantities map[CivilDate]int
+}
+
+// UnmarshalJSON decodes a campsite from a recreation.gov payload. The API
+// keys availabilities by timestamps such as "2023-07-04T00:00:00Z"; each key
+// is reduced to the date written, so lookups by Nights always hit whatever
+// offset the key was written with. campsite_id is accepted as a
+// number or a quoted number. A night in quantities is Available when units
+// remain and Reserved when none do, whatever its status string says, since
+// recreation.gov does not keep the two in step for sites booked by the unit.
+func (c *Campsite) UnmarshalJSON(data []byte) error {
+	var raw struct {
+		CampsiteID     json.RawMessage   `json:"campsite_id"`
+		CampsiteType   string            `json:"campsite_type"`
+		Loop           string            `json:"loop"`
+		Site           string            `json:"site"`
+		Availabilities map[string]string `json:"availabilities"`
+		Quantities     map[string]int    `json:"quantities"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	*c = Campsite{CampsiteType: raw.CampsiteType, Loop: raw.Loop, Site: raw.Site}
+	if len(raw.CampsiteID) > 0 && string(raw.CampsiteID) != "null" {
+		id := strings.Trim(string(raw.CampsiteID), `"`)
+		n, err := strconv.Atoi(id)
+		if err != nil {
+			return fmt.Errorf("campsite_id %s is not a number", raw.CampsiteID)
+		}
+		c.CampsiteID = n
+	}
+	if raw.Availabilities != nil {
+		c.Availabilities = make(map[CivilDate]string, len(raw.Availabilities))
+		for key, status := range raw.Availabilities {
+			night, err := time.Parse(time.RFC3339, key)
+			if err != nil {
+				return fmt.Errorf("availability date %q: %v", key, err)
+			}
+			c.Availabilities[CivilDateOf(night)] = status
+		}
+	}
+	if raw.Quantities != nil {
+		c.Quantities = make(map[CivilDate]int, len(raw.Quantities))
+		if c.Availabilities == nil {
+			c.Availabilities = make(map[CivilDate]string, len(raw.Quantities))
+		}
+		for key, remaining := range raw.Quantities {
+			night, err := time.Parse(time.RFC3339, key)
+			if err != nil {
+				return fmt.Errorf("quantity date %q: %v", key, err)
+			}
+			key := CivilDateOf(night)
+			c.Quantities[key] = remaining
+			if remaining > 0 {
+				c.Availabilities[key] = string(StatusAvailable)
+			} else {
+				c.Availabilities[key] = string(StatusReserved)
+			}
+		}
+	}
+	return nil
+}
+
+// SiteIDs returns the campsite IDs ordered naturally by site name, such as
+// A9 before A10, falling back to the ID for sites without a name and to
+// break ties.
+func (c Campground) SiteIDs() []string {
+	ids := make([]string, 0, len(c.Campsites))
+	for id := range c.Campsites {
+		ids = append(ids, id)
+	}
+	label := func(id string) string {
+		if name := c.Campsites[id].Site; name != "" {
+			return name
+		}
+		return id
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := label(ids[i]), label(ids[j])
+		if a != b {
+			return NaturalLess(a, b)
+		}
+		return NaturalLess(ids[i], ids[j])
+	})
+	return ids
+}
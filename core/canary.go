@@ -0,0 +1,124 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+)
+
+// PayloadSpec is what a healthy month payload looks like. It is data rather
+// than code so a benign upstream change only needs the spec updated.
+type PayloadSpec struct {
+	// CampgroundID is a campground known to be open year round, used by the
+	// canary.
+	CampgroundID string `json:"campground_id"`
+	// RequiredFields must be present on every campsite.
+	RequiredFields []string `json:"required_fields"`
+	// FieldTypes maps every known campsite field to its JSON type:
+	// "string", "number", "bool", "object" or "array". Fields missing from
+	// it are reported as new.
+	FieldTypes map[string]string `json:"field_types"`
+	// Statuses is every availability status string known to the matcher.
+	Statuses []string `json:"statuses"`
+	// MinCampsites is the fewest campsites the campground should report.
+	MinCampsites int `json:"min_campsites"`
+}
+
+// CheckPayload compares a raw month payload against spec and returns one
+// line per expectation that failed, sorted, or nil when the payload matches.
+// It also reports whether the payload still decodes, since drift that
+// decoding tolerates is exactly what the check exists to catch.
+func CheckPayload(data []byte, spec PayloadSpec) []string {
+	problems := map[string]bool{}
+	if _, err := decodeCampground(data); err != nil {
+		problems["payload no longer decodes: "+err.Error()] = true
+	}
+
+	var payload struct {
+		Campsites map[string]map[string]json.RawMessage `json:"campsites"`
+	}
+	if err := json.Unmarshal(data, &payload); err != nil {
+		return []string{"payload is not a campsites object: " + err.Error()}
+	}
+	if len(payload.Campsites) < spec.MinCampsites {
+		problems[fmt.Sprintf("expected at least %d campsites, got %d", spec.MinCampsites, len(payload.Campsites))] = true
+	}
+
+	known := map[string]bool{}
+	for _, status := range spec.Statuses {
+		known[status] = true
+	}
+	for _, site := range payload.Campsites {
+		for _, field := range spec.RequiredFields {
+			if _, ok := site[field]; !ok {
+				problems["- missing field "+field] = true
+			}
+		}
+		for field, want := range spec.FieldTypes {
+			raw, ok := site[field]
+			if !ok {
+				continue
+			}
+			if got := jsonType(raw); got != want && got != "null" {
+				problems[fmt.Sprintf("~ field %s is %s, expected %s", field, got, want)] = true
+			}
+		}
+		if raw, ok := site["availabilities"]; ok {
+			statuses := map[string]string{}
+			if err := json.Unmarshal(raw, &statuses); err == nil {
+				for _, status := range statuses {
+					if !known[status] {
+						problems[fmt.Sprintf("+ unknown status %q", status)] = true
+					}
+				}
+			}
+		}
+		for field := range site {
+			if !specMentions(spec, field) {
+				problems["+ new field "+field] = true
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	lines := make([]string, 0, len(problems))
+	for line := range problems {
+		lines = append(lines, line)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func specMentions(spec PayloadSpec, field string) bool {
+	if _, ok := spec.FieldTypes[field]; ok {
+		return true
+	}
+	for _, f := range spec.RequiredFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonType(raw json.RawMessage) string {
+	var v interface{}
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return "invalid"
+	}
+	switch v.(type) {
+	case string:
+		return "string"
+	case float64:
+		return "number"
+	case bool:
+		return "bool"
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	}
+	return "null"
+}
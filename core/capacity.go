@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Capacity is how many people a campsite takes. Max is zero when
+// recreation.gov does not say.
+type Capacity struct {
+	Min int
+	Max int
+}
+
+// campsiteDetail is the part of the campsite detail response used here.
+type campsiteDetail struct {
+	Campsite struct {
+		MaxNumPeople json.RawMessage `json:"max_num_people"`
+		MinNumPeople json.RawMessage `json:"min_num_people"`
+		Attributes   []struct {
+			Name  string `json:"attribute_name"`
+			Value string `json:"attribute_value"`
+		} `json:"attributes"`
+		Rates []feeRate `json:"rates"`
+	} `json:"campsite"`
+}
+
+// CampsiteDetail is what recreation.gov's campsite detail endpoint says
+// about a campsite beyond the availability feed.
+type CampsiteDetail struct {
+	Capacity Capacity
+	// Attributes maps each attribute's lower-cased name to its value, such
+	// as "electricity hookup": "50" or "pets allowed": "Yes".
+	Attributes map[string]string
+	// Fees are the site's own nightly rates, often none; the campground's
+	// then apply.
+	Fees Fees
+}
+
+// FetchCampsiteDetail reads a campsite's occupancy limits, attributes and
+// fees from recreation.gov's campsite detail endpoint.
+func (r RecreationGov) FetchCampsiteDetail(ctx context.Context, campsiteID string) (CampsiteDetail, error) {
+	base := r.BaseURL
+	if base == "" {
+		base = recreationGovURL
+	}
+	endpoint := fmt.Sprintf("%s/api/camps/campsites/%s", base, campsiteID)
+	data, err := r.Retry.do(ctx, endpoint, func() ([]byte, error) {
+		return r.fetchOnce(ctx, endpoint)
+	})
+	if err != nil {
+		return CampsiteDetail{}, err
+	}
+	var raw campsiteDetail
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return CampsiteDetail{}, fmt.Errorf("decoding campsite %s: %w", campsiteID, err)
+	}
+	detail := CampsiteDetail{
+		Capacity:   Capacity{Min: jsonInt(raw.Campsite.MinNumPeople), Max: jsonInt(raw.Campsite.MaxNumPeople)},
+		Attributes: map[string]string{},
+		Fees:       decodeFees(raw.Campsite.Rates),
+	}
+	for _, attr := range raw.Campsite.Attributes {
+		detail.Attributes[attributeKey(attr.Name)] = strings.TrimSpace(attr.Value)
+	}
+	return detail, nil
+}
+
+// FetchCapacity reads a campsite's occupancy limits; see
+// FetchCampsiteDetail.
+func (r RecreationGov) FetchCapacity(ctx context.Context, campsiteID string) (Capacity, error) {
+	detail, err := r.FetchCampsiteDetail(ctx, campsiteID)
+	return detail.Capacity, err
+}
+
+// jsonInt reads a count that recreation.gov sends as either a number or a
+// string, returning zero for anything else.
+func jsonInt(raw json.RawMessage) int {
+	n, _ := strconv.Atoi(strings.Trim(string(raw), `"`))
+	return n
+}
@@ -0,0 +1,122 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CivilDate is a calendar date with no time of day or zone. Use it for stay
+// dates and nights instead of time.Time, converting with Time only where a
+// UTC midnight is needed, such as to ask recreation.gov for a month.
+type CivilDate struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+// civilDateLayouts are the accepted input formats, canonical first. The
+// month and day digits may be unpadded.
+var civilDateLayouts = []string{"2006-1-2", "1/2/2006", time.RFC3339}
+
+// ParseCivilDate parses "YYYY-MM-DD" and the other formats watches have been
+// created with: "MM/DD/YYYY" and RFC 3339 timestamps, whose date part is
+// used as written.
+func ParseCivilDate(s string) (CivilDate, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range civilDateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return CivilDateOf(t), nil
+		}
+	}
+	return CivilDate{}, fmt.Errorf("%q is not a YYYY-MM-DD date", s)
+}
+
+// CivilDateOf returns the date of t in t's own location.
+func CivilDateOf(t time.Time) CivilDate {
+	y, m, d := t.Date()
+	return CivilDate{y, m, d}
+}
+
+// Time returns midnight UTC at the start of d, which is how recreation.gov
+// keys its availability.
+func (d CivilDate) Time() time.Time {
+	return time.Date(d.Year, d.Month, d.Day, 0, 0, 0, 0, time.UTC)
+}
+
+// IsZero reports whether d is the zero value.
+func (d CivilDate) IsZero() bool {
+	return d == CivilDate{}
+}
+
+// String formats d as "YYYY-MM-DD".
+func (d CivilDate) String() string {
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// AddDays returns the date n days after d; n may be negative. Month and year
+// boundaries are normalised.
+func (d CivilDate) AddDays(n int) CivilDate {
+	return CivilDateOf(d.Time().AddDate(0, 0, n))
+}
+
+// Weekday returns the day of the week d falls on.
+func (d CivilDate) Weekday() time.Weekday {
+	return d.Time().Weekday()
+}
+
+// Next returns the following day.
+func (d CivilDate) Next() CivilDate {
+	return d.AddDays(1)
+}
+
+// Before reports whether d is earlier than other.
+func (d CivilDate) Before(other CivilDate) bool {
+	return d.Time().Before(other.Time())
+}
+
+// After reports whether d is later than other.
+func (d CivilDate) After(other CivilDate) bool {
+	return d.Time().After(other.Time())
+}
+
+// DaysUntil returns the number of days from d to end, negative if end is
+// earlier.
+func (d CivilDate) DaysUntil(end CivilDate) int {
+	return int(end.Time().Sub(d.Time()).Hours() / 24)
+}
+
+// RangeUntil returns every date from d up to, but not including, end: the
+// nights of a stay arriving on d and leaving on end.
+func (d CivilDate) RangeUntil(end CivilDate) []CivilDate {
+	dates := []CivilDate{}
+	for day := d; day.Before(end); day = day.Next() {
+		dates = append(dates, day)
+	}
+	return dates
+}
+
+// MarshalJSON encodes d as "YYYY-MM-DD".
+func (d CivilDate) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+// UnmarshalJSON accepts any format ParseCivilDate does. An empty string
+// decodes to the zero date.
+func (d *CivilDate) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == "" {
+		*d = CivilDate{}
+		return nil
+	}
+	parsed, err := ParseCivilDate(s)
+	if err != nil {
+		return err
+	}
+	*d = parsed
+	return nil
+}
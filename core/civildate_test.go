@@ -0,0 +1,103 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func mustCivilDate(t *testing.T, s string) CivilDate {
+	t.Helper()
+	d, err := ParseCivilDate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+func TestCivilDateRoundTrip(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"2027-07-14", "2027-07-14"},
+		{"2027-7-4", "2027-07-04"},
+		{" 2027-07-14 ", "2027-07-14"},
+		{"07/14/2027", "2027-07-14"},
+		{"7/4/2027", "2027-07-04"},
+		{"2027-07-14T23:30:00-07:00", "2027-07-14"},
+		{"2028-02-29", "2028-02-29"},
+	}
+	for _, test := range tests {
+		d, err := ParseCivilDate(test.in)
+		if err != nil {
+			t.Errorf("ParseCivilDate(%q): %v", test.in, err)
+			continue
+		}
+		if got := d.String(); got != test.want {
+			t.Errorf("ParseCivilDate(%q) = %s, want %s", test.in, got, test.want)
+		}
+		if again, err := ParseCivilDate(d.String()); err != nil || again != d {
+			t.Errorf("ParseCivilDate(%q) = %v, %v, want %v", d.String(), again, err, d)
+		}
+		data, err := json.Marshal(d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var decoded CivilDate
+		if err := json.Unmarshal(data, &decoded); err != nil || decoded != d {
+			t.Errorf("JSON %s decoded to %v, %v, want %v", data, decoded, err, d)
+		}
+	}
+	for _, in := range []string{"", "July 14", "2027-13-01", "2027-02-29", "14.07.2027"} {
+		if d, err := ParseCivilDate(in); err == nil {
+			t.Errorf("ParseCivilDate(%q) = %v, want an error", in, d)
+		}
+	}
+}
+
+func TestCivilDateAddDays(t *testing.T) {
+	tests := []struct {
+		from string
+		n    int
+		want string
+	}{
+		{"2027-07-14", 2, "2027-07-16"},
+		{"2027-07-30", 3, "2027-08-02"},
+		{"2027-08-01", -1, "2027-07-31"},
+		{"2027-02-28", 1, "2027-03-01"},
+		{"2028-02-28", 1, "2028-02-29"},
+		{"2027-12-31", 1, "2028-01-01"},
+		{"2027-03-14", 1, "2027-03-15"},
+	}
+	for _, test := range tests {
+		if got := mustCivilDate(t, test.from).AddDays(test.n).String(); got != test.want {
+			t.Errorf("%s.AddDays(%d) = %s, want %s", test.from, test.n, got, test.want)
+		}
+	}
+	nights := mustCivilDate(t, "2027-07-30").RangeUntil(mustCivilDate(t, "2027-08-02"))
+	if len(nights) != 3 || nights[2].String() != "2027-08-01" {
+		t.Errorf("RangeUntil() = %v, want Jul 30 to Aug 1", nights)
+	}
+}
+
+// CivilDateOf takes the date on t's own clock, which need not be the UTC
+// date.
+func TestCivilDateOf(t *testing.T) {
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	late := time.Date(2027, 7, 14, 23, 30, 0, 0, losAngeles)
+	if got := CivilDateOf(late).String(); got != "2027-07-14" {
+		t.Errorf("CivilDateOf(%v) = %s, want 2027-07-14", late, got)
+	}
+	if got := CivilDateOf(late.UTC()).String(); got != "2027-07-15" {
+		t.Errorf("CivilDateOf(%v) = %s, want 2027-07-15", late.UTC(), got)
+	}
+	tokyo := time.FixedZone("JST", 9*60*60)
+	early := time.Date(2027, 8, 1, 1, 0, 0, 0, tokyo)
+	if got := CivilDateOf(early).String(); got != "2027-08-01" {
+		t.Errorf("CivilDateOf(%v) = %s, want 2027-08-01", early, got)
+	}
+}
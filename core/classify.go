@@ -0,0 +1,38 @@
+package core
+
+// Classification is the overall state of a campground for a stay, as found
+// by CheckAvailability.
+type Classification string
+
+// Campground classifications. NotYetReleased is a stay no site can be
+// booked for yet because some of its nights are still outside the booking
+// window; Closed is a campground not operating on any of the nights.
+const (
+	ClassAvailable          Classification = "available"
+	ClassPartiallyAvailable Classification = "partially available"
+	ClassFullyBooked        Classification = "fully booked"
+	ClassNotYetReleased     Classification = "not yet released"
+	ClassClosed             Classification = "closed"
+)
+
+// classify works out r's classification from its summaries. A stay with no
+// available night at any site but some nights not yet released is waiting
+// on the booking window rather than booked up, whatever the other nights
+// say, since it cannot be booked whole until those nights open.
+func (r AvailabilityResult) classify() Classification {
+	if len(r.Sites) > 0 {
+		return ClassAvailable
+	}
+	available, notReleased := 0, 0
+	for _, s := range r.Summaries {
+		available += s.Available
+		notReleased += s.NotYetReleased
+	}
+	switch {
+	case available > 0:
+		return ClassPartiallyAvailable
+	case notReleased > 0:
+		return ClassNotYetReleased
+	}
+	return ClassFullyBooked
+}
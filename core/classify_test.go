@@ -0,0 +1,75 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// Each fixture in testdata/classify holds a campground in one state for the
+// nights of Jul 14 and 15.
+func TestClassification(t *testing.T) {
+	tests := []struct {
+		fixture        string
+		want           Classification
+		lastUnreleased string
+	}{
+		{"available", ClassAvailable, ""},
+		{"partially-available", ClassPartiallyAvailable, ""},
+		{"fully-booked", ClassFullyBooked, ""},
+		{"not-yet-released", ClassNotYetReleased, "2027-07-15"},
+		{"closed", ClassClosed, ""},
+		// No campsites at all is no evidence of a closed season.
+		{"empty", ClassFullyBooked, ""},
+	}
+	arrival := CivilDate{2027, time.July, 14}
+	departure := arrival.AddDays(2)
+	for _, test := range tests {
+		t.Run(test.fixture, func(t *testing.T) {
+			campground, err := decodeCampground(readFixture(t, "classify/"+test.fixture+".json"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, err := ScrapeDetailed(context.Background(), staticProvider{campground}, "232447", arrival, departure)
+			var closed *SeasonClosedError
+			if test.want == ClassClosed && !errors.As(err, &closed) {
+				t.Fatalf("ScrapeDetailed error %v, want a *SeasonClosedError", err)
+			}
+			if test.want != ClassClosed && err != nil {
+				t.Fatalf("ScrapeDetailed: %v", err)
+			}
+			if result.Class != test.want {
+				t.Errorf("class %q, want %q", result.Class, test.want)
+			}
+			last := ""
+			if !result.LastUnreleased.IsZero() {
+				last = result.LastUnreleased.String()
+			}
+			if last != test.lastUnreleased {
+				t.Errorf("last unreleased %q, want %q", last, test.lastUnreleased)
+			}
+		})
+	}
+}
+
+// Filtering a result classifies it again from the sites kept, except that a
+// closed campground stays closed.
+func TestClassificationFiltered(t *testing.T) {
+	campground, err := decodeCampground(readFixture(t, "classify/available.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrival := CivilDate{2027, time.July, 14}
+	result, err := CheckAvailability(context.Background(), campground, arrival, arrival.AddDays(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := result.Filter(SiteFilter{Sites: []string{"1002"}}).Class; got != ClassFullyBooked {
+		t.Errorf("without the open site: %q, want fully booked", got)
+	}
+	closed := AvailabilityResult{Class: ClassClosed}
+	if got := closed.Filter(SiteFilter{Sites: []string{"1002"}}).Class; got != ClassClosed {
+		t.Errorf("closed, filtered: %q, want closed", got)
+	}
+}
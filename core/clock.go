@@ -0,0 +1,17 @@
+package core
+
+import "time"
+
+// Clock is the source of the current time for cache expiry, pacing and
+// Retry-After dates. Wherever a Clock field is nil, the wall clock is used.
+type Clock interface {
+	Now() time.Time
+}
+
+// clockNow reads c, or the wall clock when c is nil.
+func clockNow(c Clock) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.Now()
+}
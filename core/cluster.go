@@ -0,0 +1,157 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMaxSiteGap is how far apart site numbers may be in a Cluster when
+// ClusterSearch.MaxGap is not set.
+const DefaultMaxSiteGap = 3
+
+// ClusterSearch asks for clusters of at least MinSites available campsites
+// in the same loop whose site numbers are at most MaxGap apart, for groups
+// needing several sites near each other.
+type ClusterSearch struct {
+	MinSites int
+	MaxGap   int
+}
+
+// Cluster is a group of available campsites near each other in one loop.
+// Sites holds their IDs and Names their site names, in the same order.
+type Cluster struct {
+	Loop  string
+	Sites []string
+	Names []string
+}
+
+// String renders the cluster as "Loop B: B014, B015, B017".
+func (c Cluster) String() string {
+	sites := strings.Join(c.Names, ", ")
+	switch {
+	case c.Loop == "":
+		return sites
+	case strings.HasPrefix(strings.ToLower(c.Loop), "loop"):
+		return c.Loop + ": " + sites
+	default:
+		return "Loop " + c.Loop + ": " + sites
+	}
+}
+
+// siteNumber splits a site name such as "B014" or "A-12" into its prefix
+// and number. ok is false for names that do not end in a number, such as
+// "GROUP A".
+func siteNumber(name string) (prefix string, n int, ok bool) {
+	end := len(name)
+	start := end
+	for start > 0 && name[start-1] >= '0' && name[start-1] <= '9' {
+		start--
+	}
+	if start == end {
+		return "", 0, false
+	}
+	n, err := strconv.Atoi(name[start:end])
+	if err != nil {
+		return "", 0, false
+	}
+	return strings.ToUpper(strings.TrimRight(name[:start], "- ")), n, true
+}
+
+// ScrapeClusters fetches availability like Scrape and returns the clusters
+// of campsites available every night, as FindClusters does, together with
+// every available site so callers can fall back to them.
+func ScrapeClusters(ctx context.Context, p Provider, campgroundID string, arrival CivilDate, departure CivilDate,
+	search ClusterSearch) ([]Cluster, []string, error) {
+	campground, err := FetchRange(ctx, p, campgroundID, arrival, departure)
+	if err != nil {
+		return nil, nil, err
+	}
+	if seasonClosed(campground, Nights(arrival, departure)) {
+		return nil, nil, &SeasonClosedError{CampgroundID: campgroundID, Arrival: arrival, Departure: departure}
+	}
+	available, err := AvailableSites(ctx, campground, arrival, departure)
+	return FindClusters(campground, available, search), available, err
+}
+
+// FindClusters groups the available campsites of campground as search asks.
+// Within a loop, numbered sites sharing a prefix are chained while each is
+// at most MaxGap from the last. Sites whose names have no number cannot be
+// measured, so all of a loop's unnumbered sites form one cluster. Clusters
+// smaller than MinSites, which defaults to 2, are dropped. Clusters are
+// sorted by loop and then first site name.
+func FindClusters(campground Campground, available []string, search ClusterSearch) []Cluster {
+	minSites, maxGap := search.MinSites, search.MaxGap
+	if minSites < 2 {
+		minSites = 2
+	}
+	if maxGap <= 0 {
+		maxGap = DefaultMaxSiteGap
+	}
+
+	type numbered struct {
+		id string
+		n  int
+	}
+	// runs holds the numbered sites of each loop and prefix, and unnumbered
+	// the rest of each loop.
+	runs := map[[2]string][]numbered{}
+	unnumbered := map[string][]string{}
+	for _, id := range available {
+		site := campground.Campsites[id]
+		if prefix, n, ok := siteNumber(site.Site); ok {
+			key := [2]string{site.Loop, prefix}
+			runs[key] = append(runs[key], numbered{id, n})
+		} else {
+			unnumbered[site.Loop] = append(unnumbered[site.Loop], id)
+		}
+	}
+
+	clusters := []Cluster{}
+	add := func(loop string, ids []string) {
+		if len(ids) < minSites {
+			return
+		}
+		c := Cluster{Loop: loop, Sites: ids}
+		for _, id := range ids {
+			name := campground.Campsites[id].Site
+			if name == "" {
+				name = id
+			}
+			c.Names = append(c.Names, name)
+		}
+		clusters = append(clusters, c)
+	}
+	for key, sites := range runs {
+		sort.Slice(sites, func(i, j int) bool {
+			if sites[i].n != sites[j].n {
+				return sites[i].n < sites[j].n
+			}
+			return sites[i].id < sites[j].id
+		})
+		chain := []string{sites[0].id}
+		for i := 1; i < len(sites); i++ {
+			if sites[i].n-sites[i-1].n > maxGap {
+				add(key[0], chain)
+				chain = nil
+			}
+			chain = append(chain, sites[i].id)
+		}
+		add(key[0], chain)
+	}
+	for loop, ids := range unnumbered {
+		sort.Slice(ids, func(i, j int) bool {
+			return NaturalLess(campground.Campsites[ids[i]].Site, campground.Campsites[ids[j]].Site)
+		})
+		add(loop, ids)
+	}
+
+	sort.Slice(clusters, func(i, j int) bool {
+		if clusters[i].Loop != clusters[j].Loop {
+			return NaturalLess(clusters[i].Loop, clusters[j].Loop)
+		}
+		return NaturalLess(clusters[i].Names[0], clusters[j].Names[0])
+	})
+	return clusters
+}
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSiteNumber(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		n      int
+		ok     bool
+	}{
+		{"B014", "B", 14, true},
+		{"A-12", "A", 12, true},
+		{"a 7", "A", 7, true},
+		{"042", "", 42, true},
+		{"A1", "A", 1, true},
+		{"GROUP A", "", 0, false},
+		{"", "", 0, false},
+	}
+	for _, test := range tests {
+		prefix, n, ok := siteNumber(test.name)
+		if prefix != test.prefix || n != test.n || ok != test.ok {
+			t.Errorf("siteNumber(%q) = %q, %d, %t, want %q, %d, %t", test.name, prefix, n, ok, test.prefix, test.n, test.ok)
+		}
+	}
+}
+
+// clusterCampground lists sites as loop and site name pairs, with IDs
+// "a", "b" and so on.
+func clusterCampground(sites ...[2]string) (Campground, []string) {
+	campground := Campground{Campsites: map[string]Campsite{}}
+	ids := []string{}
+	for i, s := range sites {
+		id := string(rune('a' + i))
+		campground.Campsites[id] = Campsite{Loop: s[0], Site: s[1]}
+		ids = append(ids, id)
+	}
+	return campground, ids
+}
+
+func TestFindClusters(t *testing.T) {
+	tests := []struct {
+		name   string
+		sites  [][2]string
+		search ClusterSearch
+		want   []string
+	}{
+		{name: "within the gap", sites: [][2]string{{"B", "B014"}, {"B", "B015"}, {"B", "B017"}, {"B", "B022"}},
+			want: []string{"Loop B: B014, B015, B017"}},
+		{name: "wider gap", sites: [][2]string{{"B", "B014"}, {"B", "B022"}}, search: ClusterSearch{MaxGap: 8},
+			want: []string{"Loop B: B014, B022"}},
+		{name: "mixed prefixes in one loop", sites: [][2]string{{"Loop A", "A1"}, {"Loop A", "B1"}, {"Loop A", "A2"}},
+			want: []string{"Loop A: A1, A2"}},
+		{name: "mixed prefixes, each a cluster", sites: [][2]string{{"North", "A1"}, {"North", "A2"}, {"North", "B1"}, {"North", "B2"}},
+			want: []string{"Loop North: A1, A2", "Loop North: B1, B2"}},
+		{name: "dashes and spaces", sites: [][2]string{{"A", "A-12"}, {"A", "A13"}, {"A", "a 14"}},
+			want: []string{"Loop A: A-12, A13, a 14"}},
+		{name: "bare numbers against prefixed", sites: [][2]string{{"C", "12"}, {"C", "C13"}}, want: []string{}},
+		{name: "no loop", sites: [][2]string{{"", "001"}, {"", "002"}}, want: []string{"001, 002"}},
+		{name: "unnumbered by loop", sites: [][2]string{{"Group", "GROUP B"}, {"Group", "GROUP A"}, {"Group", "G10"}, {"Other", "MEADOW"}},
+			want: []string{"Loop Group: GROUP A, GROUP B"}},
+		{name: "same numbers, other loops", sites: [][2]string{{"A", "5"}, {"B", "6"}}, want: []string{}},
+		{name: "too few", sites: [][2]string{{"B", "B1"}, {"B", "B2"}, {"C", "C1"}, {"C", "C2"}, {"C", "C3"}}, search: ClusterSearch{MinSites: 3},
+			want: []string{"Loop C: C1, C2, C3"}},
+		{name: "natural order", sites: [][2]string{{"Loop 10", "1"}, {"Loop 10", "2"}, {"Loop 9", "1"}, {"Loop 9", "2"}},
+			want: []string{"Loop 9: 1, 2", "Loop 10: 1, 2"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			campground, ids := clusterCampground(test.sites...)
+			got := []string{}
+			for _, c := range FindClusters(campground, ids, test.search) {
+				got = append(got, c.String())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("FindClusters() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+)
+
+// DefaultMaxResponseBytes bounds how much of a month payload is read when
+// RecreationGov.MaxResponseBytes is not set. The largest campgrounds return
+// a few hundred kilobytes.
+const DefaultMaxResponseBytes = 8 << 20
+
+// Limits on the shape of a month payload. Anything beyond these is not a
+// real recreation.gov response.
+const (
+	maxPayloadDepth   = 32
+	maxCampsiteIDLen  = 64
+	maxCampsiteTypeLn = 256
+)
+
+// ResponseTooLargeError is returned when a response body exceeds the
+// configured size limit.
+type ResponseTooLargeError struct {
+	Limit int64
+}
+
+func (e *ResponseTooLargeError) Error() string {
+	return fmt.Sprintf("response exceeds %d bytes", e.Limit)
+}
+
+// MalformedPayloadError is returned when a response is valid JSON but not a
+// plausible month payload.
+type MalformedPayloadError struct {
+	Reason string
+}
+
+func (e *MalformedPayloadError) Error() string {
+	return "malformed availability payload: " + e.Reason
+}
+
+// readLimited reads all of r, failing with *ResponseTooLargeError rather
+// than truncating when there is more than limit bytes.
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	data, err := ioutil.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, &ResponseTooLargeError{Limit: limit}
+	}
+	return data, nil
+}
+
+// decodeCampground decodes a month payload after checking its nesting depth
+// and that no campsite ID appears twice, which encoding/json would otherwise
+// resolve silently by keeping the last one.
+func decodeCampground(data []byte) (Campground, error) {
+	campground := Campground{}
+	if err := checkStructure(data); err != nil {
+		return campground, err
+	}
+	if err := json.Unmarshal(data, &campground); err != nil {
+		return campground, err
+	}
+	for id, site := range campground.Campsites {
+		if len(id) > maxCampsiteIDLen {
+			return Campground{}, &MalformedPayloadError{fmt.Sprintf("campsite ID of %d bytes", len(id))}
+		}
+		if len(site.CampsiteType) > maxCampsiteTypeLn {
+			return Campground{}, &MalformedPayloadError{fmt.Sprintf("campsite %s type of %d bytes", id, len(site.CampsiteType))}
+		}
+	}
+	return campground, nil
+}
+
+// payloadFrame is one open object or array while walking a payload.
+type payloadFrame struct {
+	object    bool
+	expectKey bool
+	key       string
+}
+
+// checkStructure walks the token stream once, rejecting payloads nested
+// deeper than maxPayloadDepth and duplicate keys in the top-level campsites
+// object.
+func checkStructure(data []byte) error {
+	stack := []payloadFrame{}
+	var campsiteIDs map[string]bool
+	// valueDone records that the innermost object consumed a value and now
+	// expects its next key.
+	valueDone := func() {
+		if len(stack) > 0 && stack[len(stack)-1].object {
+			stack[len(stack)-1].expectKey = true
+		}
+	}
+	inCampsites := func() bool {
+		return len(stack) == 2 && strings.EqualFold(stack[0].key, "campsites")
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if d, ok := tok.(json.Delim); ok {
+			switch d {
+			case '{', '[':
+				if len(stack) >= maxPayloadDepth {
+					return &MalformedPayloadError{fmt.Sprintf("nested deeper than %d levels", maxPayloadDepth)}
+				}
+				stack = append(stack, payloadFrame{object: d == '{', expectKey: d == '{'})
+				if inCampsites() {
+					campsiteIDs = map[string]bool{}
+				}
+			case '}', ']':
+				stack = stack[:len(stack)-1]
+				valueDone()
+			}
+			continue
+		}
+
+		if len(stack) > 0 && stack[len(stack)-1].expectKey {
+			key := tok.(string)
+			stack[len(stack)-1].key = key
+			stack[len(stack)-1].expectKey = false
+			if inCampsites() {
+				if campsiteIDs[key] {
+					return &MalformedPayloadError{"duplicate campsite ID " + key}
+				}
+				campsiteIDs[key] = true
+			}
+			continue
+		}
+		valueDone()
+	}
+}
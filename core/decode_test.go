@@ -0,0 +1,213 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func readFixture(t testing.TB, name string) []byte {
+	t.Helper()
+	data, err := ioutil.ReadFile("testdata/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeCampground(t *testing.T) {
+	july := func(day int) CivilDate { return CivilDate{2027, time.July, day} }
+	campground, err := decodeCampground(readFixture(t, "month.json"))
+	if err != nil {
+		t.Fatalf("decodeCampground: %v", err)
+	}
+	tests := []struct {
+		id     string
+		night  CivilDate
+		status string
+	}{
+		{"1001", july(14), "Available"},
+		{"1001", july(16), "Not Reservable"},
+		{"1002", july(15), "Open"},
+		// Quantities win over the status strings.
+		{"1003", july(14), "Reserved"},
+		{"1003", july(15), "Available"},
+	}
+	for _, test := range tests {
+		site, ok := campground.Campsites[test.id]
+		if !ok {
+			t.Fatalf("campsite %s missing", test.id)
+		}
+		if got := site.Availabilities[test.night]; got != test.status {
+			t.Errorf("campsite %s on %s = %q, want %q", test.id, test.night, got, test.status)
+		}
+	}
+	if id := campground.Campsites["1003"].CampsiteID; id != 1003 {
+		t.Errorf("numeric campsite_id decoded as %d", id)
+	}
+}
+
+// group.json is a month at a campground booked by the unit, recorded while
+// its status strings lagged behind the units left.
+func TestDecodeQuantities(t *testing.T) {
+	july := func(day int) CivilDate { return CivilDate{2027, time.July, day} }
+	campground, err := decodeCampground(readFixture(t, "group.json"))
+	if err != nil {
+		t.Fatalf("decodeCampground: %v", err)
+	}
+	tests := []struct {
+		name      string
+		id        string
+		night     CivilDate
+		remaining int
+		status    string
+	}{
+		{"units left though listed reserved", "4001", july(14), 2, "Available"},
+		{"last unit", "4001", july(16), 1, "Available"},
+		{"none left", "4001", july(1), 0, "Reserved"},
+		{"none left though listed available", "4003", july(14), 0, "Reserved"},
+		{"overflow", "4002", july(15), 9, "Available"},
+	}
+	for _, test := range tests {
+		site := campground.Campsites[test.id]
+		remaining, ok := site.Quantities[test.night]
+		if !ok || remaining != test.remaining {
+			t.Errorf("%s: %s has %d left on %s (listed %v), want %d", test.name, test.id, remaining, test.night, ok, test.remaining)
+		}
+		if got := site.Availabilities[test.night]; got != test.status {
+			t.Errorf("%s: %s on %s = %q, want %q", test.name, test.id, test.night, got, test.status)
+		}
+	}
+	if site := campground.Campsites["4004"]; site.Quantities != nil || site.Availabilities[july(14)] != "Available" {
+		t.Errorf("site 4004 has quantities %v and is %q on July 14, want none and Available", site.Quantities, site.Availabilities[july(14)])
+	}
+}
+
+// Each availability key is the night of the date written in it, whatever
+// its offset and whatever day it is in UTC at that instant.
+func TestDecodeAvailabilityKeys(t *testing.T) {
+	tests := []struct {
+		key  string
+		want string
+	}{
+		{"2027-07-14T00:00:00Z", "2027-07-14"},
+		{"2027-07-14T00:00:00-07:00", "2027-07-14"},
+		// Already the 15th in UTC.
+		{"2027-07-14T20:00:00-07:00", "2027-07-14"},
+		// Still the 13th in UTC.
+		{"2027-07-14T00:00:00+10:00", "2027-07-14"},
+		// The day Pacific clocks go forward, before and after they do.
+		{"2027-03-14T00:00:00-08:00", "2027-03-14"},
+		{"2027-03-15T00:00:00-07:00", "2027-03-15"},
+	}
+	for _, test := range tests {
+		campground, err := decodeCampground([]byte(`{"campsites":{"1":{"availabilities":{"` + test.key + `":"Available"}}}}`))
+		if err != nil {
+			t.Fatalf("%s: %v", test.key, err)
+		}
+		for night := range campground.Campsites["1"].Availabilities {
+			if night.String() != test.want {
+				t.Errorf("%s read as %v, want %s", test.key, night, test.want)
+			}
+		}
+	}
+}
+
+func TestDecodeCampgroundRejects(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		// malformed is set when the error must be a *MalformedPayloadError.
+		malformed bool
+	}{
+		{"duplicate campsite", `{"campsites":{"1":{},"1":{}}}`, true},
+		{"too deep", `{"campsites":` + strings.Repeat("[", maxPayloadDepth) + strings.Repeat("]", maxPayloadDepth) + `}`, true},
+		{"long campsite ID", `{"campsites":{"` + strings.Repeat("9", maxCampsiteIDLen+1) + `":{}}}`, true},
+		{"long campsite type", `{"campsites":{"1":{"campsite_type":"` + strings.Repeat("x", maxCampsiteTypeLn+1) + `"}}}`, true},
+		{"bad date key", `{"campsites":{"1":{"availabilities":{"July 4":"Available"}}}}`, false},
+		{"non-numeric ID", `{"campsites":{"1":{"campsite_id":"A1"}}}`, false},
+		{"truncated", `{"campsites":{"1":`, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := decodeCampground([]byte(test.data))
+			if err == nil {
+				t.Fatal("decodeCampground succeeded")
+			}
+			var malformed *MalformedPayloadError
+			if test.malformed && !errors.As(err, &malformed) {
+				t.Errorf("error %v is not a *MalformedPayloadError", err)
+			}
+		})
+	}
+}
+
+func TestReadLimited(t *testing.T) {
+	if _, err := readLimited(bytes.NewReader(make([]byte, 10)), 10); err != nil {
+		t.Errorf("reading exactly the limit: %v", err)
+	}
+	_, err := readLimited(bytes.NewReader(make([]byte, 11)), 10)
+	var tooLarge *ResponseTooLargeError
+	if !errors.As(err, &tooLarge) || tooLarge.Limit != 10 {
+		t.Errorf("reading past the limit: %v", err)
+	}
+}
+
+// FuzzDecodeAvailability checks that no payload panics the decoder and that
+// whatever it accepts is within its limits and keyed by real dates.
+func FuzzDecodeAvailability(f *testing.F) {
+	f.Add(readFixture(f, "month.json"))
+	f.Add([]byte(`{"campsites":{}}`))
+	f.Add([]byte(`{"campsites":{"1":{"availabilities":{"2027-07-14T00:00:00-07:00":"Available"}}}}`))
+	f.Add([]byte(`{"campsites":{"1":{"quantities":{"2027-07-14T00:00:00Z":3}}}}`))
+	f.Add([]byte(`{"campsites":{"1":{},"1":{}}}`))
+	f.Add([]byte(`{"campsites":{"1":{"campsite_id":1e400}}}`))
+	f.Add([]byte(`[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[[]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]]`))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		campground, err := decodeCampground(data)
+		if err != nil {
+			return
+		}
+		for id, site := range campground.Campsites {
+			if len(id) > maxCampsiteIDLen || len(site.CampsiteType) > maxCampsiteTypeLn {
+				t.Fatalf("accepted campsite %q of type %q", id, site.CampsiteType)
+			}
+			for night := range site.Availabilities {
+				if CivilDateOf(night.Time()) != night {
+					t.Fatalf("campsite %s keyed by %+v, not a real date", id, night)
+				}
+			}
+		}
+	})
+}
+
+// FuzzAvailabilityKey checks that every availability key recreation.gov
+// could send reduces to the date written in it, whatever its offset, and
+// that the date goes to recreation.gov as midnight UTC and back unchanged.
+func FuzzAvailabilityKey(f *testing.F) {
+	f.Add("2027-07-04T00:00:00Z")
+	f.Add("2027-07-04T00:00:00-07:00")
+	f.Add("2027-07-04T23:59:59+14:00")
+	f.Add("2027-12-31T00:00:00.000Z")
+	f.Add("0000-01-01T00:00:00Z")
+	f.Fuzz(func(t *testing.T, key string) {
+		parsed, err := time.Parse(time.RFC3339, key)
+		if err != nil {
+			return
+		}
+		night := CivilDateOf(parsed)
+		if y, m, d := parsed.Date(); night.Year != y || night.Month != m || night.Day != d {
+			t.Fatalf("key %q read as %v, not the date written", key, night)
+		}
+		at := night.Time()
+		if at.Location() != time.UTC || at.Hour() != 0 || at.Minute() != 0 || at.Second() != 0 || at.Nanosecond() != 0 {
+			t.Fatalf("%v is sent as %v, not midnight UTC", night, at)
+		}
+		if again := CivilDateOf(at); again != night {
+			t.Fatalf("%v round trips through %v to %v", night, at, again)
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Location is a point in decimal degrees.
+type Location struct {
+	Latitude  float64
+	Longitude float64
+}
+
+// IsZero reports whether l is unset; recreation.gov lists campgrounds
+// without coordinates at 0,0.
+func (l Location) IsZero() bool {
+	return l == Location{}
+}
+
+// Facility is what recreation.gov's campground detail endpoint says about
+// a campground.
+type Facility struct {
+	Name     string
+	Location Location
+	// Fees are the campground's nightly rates, for sites that list none of
+	// their own.
+	Fees Fees
+	// CancellationPolicy is the campground's cancellation policy as
+	// recreation.gov words it, and ReservationFee the fee per reservation in
+	// cents. CheckIn and CheckOut are times such as "12:00 PM".
+	CancellationPolicy string
+	ReservationFee     int
+	CheckIn            string
+	CheckOut           string
+	// Season is when the campground operates, where it says.
+	Season Season
+	// Notices are the alerts posted on the campground's page, such as a
+	// closure for fire, as plain text.
+	Notices []string
+}
+
+// campgroundDetail is the part of the campground detail response used here.
+type campgroundDetail struct {
+	Campground struct {
+		Name      string    `json:"facility_name"`
+		Latitude  float64   `json:"facility_latitude"`
+		Longitude float64   `json:"facility_longitude"`
+		Rates     []feeRate `json:"rates"`
+		// The booking terms, any of which may be missing.
+		CancellationPolicy string          `json:"cancellation_policy"`
+		ReservationFee     json.RawMessage `json:"reservation_fee"`
+		CheckIn            string          `json:"checkin_time"`
+		CheckOut           string          `json:"checkout_time"`
+		Season             struct {
+			StartDate string `json:"start_date"`
+			EndDate   string `json:"end_date"`
+		} `json:"operating_season"`
+		Notices []struct {
+			Text string `json:"notice_text"`
+		} `json:"notices"`
+	} `json:"campground"`
+}
+
+// FetchFacility reads a campground's name, location, fees, booking terms,
+// operating season and notices from recreation.gov's campground detail endpoint. Any may
+// be empty.
+func (r RecreationGov) FetchFacility(ctx context.Context, campgroundID string) (Facility, error) {
+	base := r.BaseURL
+	if base == "" {
+		base = recreationGovURL
+	}
+	endpoint := fmt.Sprintf("%s/api/camps/campgrounds/%s", base, campgroundID)
+	data, err := r.Retry.do(ctx, endpoint, func() ([]byte, error) {
+		return r.fetchOnce(ctx, endpoint)
+	})
+	if err != nil {
+		return Facility{}, err
+	}
+	var raw campgroundDetail
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return Facility{}, fmt.Errorf("decoding campground %s: %w", campgroundID, err)
+	}
+	f := Facility{
+		Name:               raw.Campground.Name,
+		Location:           Location{Latitude: raw.Campground.Latitude, Longitude: raw.Campground.Longitude},
+		Fees:               decodeFees(raw.Campground.Rates),
+		CancellationPolicy: strings.Join(strings.Fields(raw.Campground.CancellationPolicy), " "),
+		CheckIn:            strings.TrimSpace(raw.Campground.CheckIn),
+		CheckOut:           strings.TrimSpace(raw.Campground.CheckOut),
+	}
+	if cents, ok := jsonPrice(raw.Campground.ReservationFee); ok {
+		f.ReservationFee = cents
+	}
+	start, startErr := ParseCivilDate(raw.Campground.Season.StartDate)
+	end, endErr := ParseCivilDate(raw.Campground.Season.EndDate)
+	if startErr == nil && endErr == nil {
+		f.Season = Season{Start: start, End: end}
+	}
+	for _, notice := range raw.Campground.Notices {
+		if text := noticeText(notice.Text); text != "" {
+			f.Notices = append(f.Notices, text)
+		}
+	}
+	return f, nil
+}
+
+var markup = regexp.MustCompile(`<[^>]*>`)
+
+// noticeText is a notice as plain text: recreation.gov posts them as HTML.
+func noticeText(raw string) string {
+	return strings.Join(strings.Fields(html.UnescapeString(markup.ReplaceAllString(raw, " "))), " ")
+}
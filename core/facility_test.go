@@ -0,0 +1,51 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestFetchFacility(t *testing.T) {
+	tests := []struct {
+		name string
+		body []byte
+		want Facility
+	}{
+		{"booking terms", readFixture(t, "campground.json"), Facility{
+			Name:               "Upper Pines",
+			Location:           Location{Latitude: 37.7357, Longitude: -119.5627},
+			Fees:               Fees{Rates: []FeeRate{{Season: "Standard", Nightly: 3600}}},
+			CancellationPolicy: "If you cancel within 48 hours of your arrival date, you will be charged the first night's fee and the $10.00 service fee.",
+			ReservationFee:     800,
+			CheckIn:            "12:00 PM",
+			CheckOut:           "11:00 AM",
+		}},
+		{"numeric fee", []byte(`{"campground":{"facility_name":"Upper Pines","reservation_fee":8}}`), Facility{Name: "Upper Pines", ReservationFee: 800}},
+		{"season", []byte(`{"campground":{"facility_name":"Upper Pines","operating_season":{"start_date":"2027-05-15","end_date":"2027-09-30T00:00:00Z"}}}`),
+			Facility{Name: "Upper Pines", Season: Season{Start: CivilDate{2027, 5, 15}, End: CivilDate{2027, 9, 30}}}},
+		{"half a season", []byte(`{"campground":{"facility_name":"Upper Pines","operating_season":{"start_date":"2027-05-15"}}}`), Facility{Name: "Upper Pines"}},
+		{"notices", []byte(`{"campground":{"facility_name":"Upper Pines","notices":[{"notice_type":"warning","notice_text":"<p>Loop A is <b>closed</b> for fire &amp; smoke.</p>"},{"notice_text":" "}]}}`),
+			Facility{Name: "Upper Pines", Notices: []string{"Loop A is closed for fire & smoke."}}},
+		{"no terms", []byte(`{"campground":{"facility_name":"Upper Pines","reservation_fee":null}}`), Facility{Name: "Upper Pines"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.Write(test.body)
+			}))
+			defer server.Close()
+
+			f, err := RecreationGov{BaseURL: server.URL}.FetchFacility(context.Background(), "232447")
+			if err != nil {
+				t.Fatalf("FetchFacility: %v", err)
+			}
+			if !reflect.DeepEqual(f, test.want) {
+				t.Errorf("FetchFacility = %+v, want %+v", f, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// FeeRate is one nightly fee, in cents, for the nights from Start through
+// End. A zero Start or End leaves that side open, so a rate with neither
+// applies all year. Season is recreation.gov's name for the period, such
+// as "Peak" or "Off Peak", where given.
+type FeeRate struct {
+	Season  string
+	Start   CivilDate
+	End     CivilDate
+	Nightly int
+}
+
+// covers reports whether r applies to night.
+func (r FeeRate) covers(night CivilDate) bool {
+	return (r.Start.IsZero() || !night.Before(r.Start)) && (r.End.IsZero() || !night.After(r.End))
+}
+
+// Fees are the nightly rates recreation.gov lists for a campsite or a
+// campground. The zero value lists none, so no price is known.
+type Fees struct {
+	Rates []FeeRate
+}
+
+// Known reports whether f lists any rate.
+func (f Fees) Known() bool {
+	return len(f.Rates) > 0
+}
+
+// NightlyOn returns the fee for night. A dated rate, such as a peak season,
+// wins over one that applies all year. It reports false when no rate
+// covers night.
+func (f Fees) NightlyOn(night CivilDate) (int, bool) {
+	fee, found, dated := 0, false, false
+	for _, r := range f.Rates {
+		if !r.covers(night) {
+			continue
+		}
+		isDated := !r.Start.IsZero() || !r.End.IsZero()
+		if !found || (isDated && !dated) {
+			fee, found, dated = r.Nightly, true, isDated
+		}
+	}
+	return fee, found
+}
+
+// StayPrice is what a stay costs at one campsite, in cents.
+type StayPrice struct {
+	Nights int
+	Total  int
+	// MaxNightly is the dearest night, which a nightly price limit is
+	// compared with.
+	MaxNightly int
+}
+
+// Nightly is the average fee per night.
+func (p StayPrice) Nightly() int {
+	if p.Nights == 0 {
+		return 0
+	}
+	return p.Total / p.Nights
+}
+
+// PriceStay adds up the fee of every night from arrival up to departure. It
+// reports false when the fee of any night is unknown.
+func (f Fees) PriceStay(arrival CivilDate, departure CivilDate) (StayPrice, bool) {
+	price := StayPrice{}
+	for _, night := range Nights(arrival, departure) {
+		fee, ok := f.NightlyOn(night)
+		if !ok {
+			return StayPrice{}, false
+		}
+		price.Nights++
+		price.Total += fee
+		if fee > price.MaxNightly {
+			price.MaxNightly = fee
+		}
+	}
+	return price, price.Nights > 0
+}
+
+// FormatPrice writes cents as dollars, such as "$35.00".
+func FormatPrice(cents int) string {
+	return fmt.Sprintf("$%d.%02d", cents/100, cents%100)
+}
+
+// PriceCents converts a price in dollars, such as 35.5, to cents.
+func PriceCents(dollars float64) int {
+	return int(math.Round(dollars * 100))
+}
+
+// feeRate is a rate as the campsite and campground detail responses give
+// it. The fee may be a number or a string such as "$35.00".
+type feeRate struct {
+	Season    string          `json:"season_type"`
+	StartDate string          `json:"start_date"`
+	EndDate   string          `json:"end_date"`
+	PerNight  json.RawMessage `json:"per_night"`
+}
+
+// decodeFees keeps the rates with a readable fee. Unreadable dates leave
+// that side of the period open rather than dropping the rate.
+func decodeFees(raw []feeRate) Fees {
+	fees := Fees{}
+	for _, r := range raw {
+		cents, ok := jsonPrice(r.PerNight)
+		if !ok {
+			continue
+		}
+		rate := FeeRate{Season: strings.TrimSpace(r.Season), Nightly: cents}
+		if d, err := ParseCivilDate(r.StartDate); err == nil {
+			rate.Start = d
+		}
+		if d, err := ParseCivilDate(r.EndDate); err == nil {
+			rate.End = d
+		}
+		fees.Rates = append(fees.Rates, rate)
+	}
+	return fees
+}
+
+// jsonPrice reads a fee in dollars sent as a number or a string, with or
+// without a dollar sign, as cents.
+func jsonPrice(raw json.RawMessage) (int, bool) {
+	s := strings.TrimPrefix(strings.Trim(string(raw), `" `), "$")
+	dollars, err := strconv.ParseFloat(s, 64)
+	if err != nil || dollars < 0 {
+		return 0, false
+	}
+	return PriceCents(dollars), true
+}
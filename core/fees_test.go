@@ -0,0 +1,63 @@
+package core
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPriceStay(t *testing.T) {
+	day := func(s string) CivilDate { return mustCivilDate(t, s) }
+	peak := Fees{Rates: []FeeRate{
+		{Season: "Off Peak", Nightly: 2000},
+		{Season: "Peak", Start: day("2027-07-15"), End: day("2027-08-31"), Nightly: 3500},
+	}}
+	tests := []struct {
+		name               string
+		fees               Fees
+		arrival, departure string
+		want               StayPrice
+		wantOK             bool
+	}{
+		{name: "flat", fees: Fees{Rates: []FeeRate{{Nightly: 3600}}}, arrival: "2027-07-14", departure: "2027-07-16",
+			want: StayPrice{Nights: 2, Total: 7200, MaxNightly: 3600}, wantOK: true},
+		{name: "into peak", fees: peak, arrival: "2027-07-13", departure: "2027-07-16",
+			want: StayPrice{Nights: 3, Total: 2000 + 2000 + 3500, MaxNightly: 3500}, wantOK: true},
+		{name: "out of peak", fees: peak, arrival: "2027-08-31", departure: "2027-09-02",
+			want: StayPrice{Nights: 2, Total: 3500 + 2000, MaxNightly: 3500}, wantOK: true},
+		{name: "off peak", fees: peak, arrival: "2027-06-01", departure: "2027-06-02",
+			want: StayPrice{Nights: 1, Total: 2000, MaxNightly: 2000}, wantOK: true},
+		{name: "no fees", arrival: "2027-07-14", departure: "2027-07-16"},
+		{name: "season ends", fees: Fees{Rates: []FeeRate{{End: day("2027-07-14"), Nightly: 2000}}}, arrival: "2027-07-14", departure: "2027-07-16"},
+		{name: "no nights", fees: peak, arrival: "2027-07-14", departure: "2027-07-14"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, ok := test.fees.PriceStay(day(test.arrival), day(test.departure))
+			if got != test.want || ok != test.wantOK {
+				t.Errorf("PriceStay() = %+v, %v, want %+v, %v", got, ok, test.want, test.wantOK)
+			}
+		})
+	}
+}
+
+func TestDecodeFees(t *testing.T) {
+	raw := []feeRate{}
+	err := json.Unmarshal([]byte(`[
+		{"season_type": "Off Peak", "per_night": 20},
+		{"season_type": " Peak ", "start_date": "2027-07-15", "end_date": "2027-08-31", "per_night": "$35.00"},
+		{"season_type": "Holiday", "per_night": "call"}
+	]`), &raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fees := decodeFees(raw)
+	if len(fees.Rates) != 2 {
+		t.Fatalf("decoded %+v, want the two readable rates", fees.Rates)
+	}
+	if r := fees.Rates[1]; r.Season != "Peak" || r.Nightly != 3500 || r.Start.String() != "2027-07-15" || r.End.String() != "2027-08-31" {
+		t.Errorf("peak rate %+v", r)
+	}
+	if (Fees{}).Known() || !fees.Known() {
+		t.Error("Known() wrong")
+	}
+}
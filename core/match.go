@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+// Scrape fetches availability for the campground from p and returns the IDs
+// of campsites available on every night from arrival up to departure. Every
+// month holding one of those nights is fetched and merged, so stays across a
+// month boundary are checked in full; a departure on the 1st adds no request.
+//
+// If ctx is done before every campsite is checked, the sites found so far are
+// returned along with a *PartialResultError. A *SeasonClosedError is returned
+// when the campground is closed for the season on every requested night.
+func Scrape(ctx context.Context, p Provider, campgroundID string, arrival CivilDate, departure CivilDate) ([]string, error) {
+	campground, err := FetchRange(ctx, p, campgroundID, arrival, departure)
+	if err != nil {
+		return nil, err
+	}
+	if seasonClosed(campground, Nights(arrival, departure)) {
+		return nil, &SeasonClosedError{CampgroundID: campgroundID, Arrival: arrival, Departure: departure}
+	}
+	return AvailableSites(ctx, campground, arrival, departure)
+}
+
+// AvailableSites returns the IDs of campsites in campground that are
+// "Available" on every night from arrival up to departure. ctx is checked
+// before each campsite, see Scrape. CheckAvailability explains the rest.
+func AvailableSites(ctx context.Context, campground Campground, arrival CivilDate, departure CivilDate) ([]string, error) {
+	result, err := CheckAvailability(ctx, campground, arrival, departure)
+	return result.Sites, err
+}
+
+// Nights returns each night from start up to, but not including, end, as
+// Availabilities keys. Dates have no zone, so a stay across a daylight
+// saving change neither gains nor loses a night.
+func Nights(start CivilDate, end CivilDate) []CivilDate {
+	return start.RangeUntil(end)
+}
+
+// NightsBetween is the number of nights Nights returns, or zero when end is
+// not later than start.
+func NightsBetween(start CivilDate, end CivilDate) int {
+	if !end.After(start) {
+		return 0
+	}
+	return start.DaysUntil(end)
+}
+
+// PartialResultError reports that matching stopped early because the context
+// was done. The sites matched before that point are still returned.
+type PartialResultError struct {
+	Checked int
+	Total   int
+	Err     error
+}
+
+func (e *PartialResultError) Error() string {
+	return fmt.Sprintf("partial result: checked %d of %d campsites: %v", e.Checked, e.Total, e.Err)
+}
+
+func (e *PartialResultError) Unwrap() error {
+	return e.Err
+}
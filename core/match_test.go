@@ -0,0 +1,104 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// staticProvider serves the same campground for every month.
+type staticProvider struct{ campground Campground }
+
+func (p staticProvider) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	return p.campground, nil
+}
+
+// Stays given as times in any zone take their nights from the calendar
+// dates written, never from the instant in UTC, and a daylight saving change
+// neither adds nor drops one.
+func TestNights(t *testing.T) {
+	pacific, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name               string
+		arrival, departure time.Time
+		want               []string
+	}{
+		{name: "two nights", arrival: time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC), departure: time.Date(2027, 7, 16, 0, 0, 0, 0, time.UTC),
+			want: []string{"2027-07-14", "2027-07-15"}},
+		{name: "zero nights", arrival: time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC), departure: time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC)},
+		{name: "departure first", arrival: time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC), departure: time.Date(2027, 7, 13, 0, 0, 0, 0, time.UTC)},
+		// Clocks go forward early on March 14 and back early on November 7.
+		{name: "spring forward", arrival: time.Date(2027, 3, 13, 0, 0, 0, 0, pacific), departure: time.Date(2027, 3, 16, 0, 0, 0, 0, pacific),
+			want: []string{"2027-03-13", "2027-03-14", "2027-03-15"}},
+		{name: "fall back", arrival: time.Date(2027, 11, 6, 0, 0, 0, 0, pacific), departure: time.Date(2027, 11, 9, 0, 0, 0, 0, pacific),
+			want: []string{"2027-11-06", "2027-11-07", "2027-11-08"}},
+		{name: "late in the day", arrival: time.Date(2027, 3, 13, 23, 30, 0, 0, pacific), departure: time.Date(2027, 3, 15, 0, 30, 0, 0, pacific),
+			want: []string{"2027-03-13", "2027-03-14"}},
+		// Auckland is a day ahead of UTC at midnight, and its clocks go back
+		// early on April 4 and forward early on September 26.
+		{name: "ahead of UTC", arrival: time.Date(2027, 7, 14, 0, 30, 0, 0, auckland), departure: time.Date(2027, 7, 16, 0, 30, 0, 0, auckland),
+			want: []string{"2027-07-14", "2027-07-15"}},
+		{name: "southern fall back", arrival: time.Date(2027, 4, 3, 0, 0, 0, 0, auckland), departure: time.Date(2027, 4, 5, 0, 0, 0, 0, auckland),
+			want: []string{"2027-04-03", "2027-04-04"}},
+		{name: "southern spring forward", arrival: time.Date(2027, 9, 25, 0, 0, 0, 0, auckland), departure: time.Date(2027, 9, 27, 0, 0, 0, 0, auckland),
+			want: []string{"2027-09-25", "2027-09-26"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			arrival, departure := CivilDateOf(test.arrival), CivilDateOf(test.departure)
+			got := []string{}
+			for _, night := range Nights(arrival, departure) {
+				if at := night.Time(); at.Location() != time.UTC || at.Hour() != 0 {
+					t.Errorf("night %v is sent as %v, not midnight UTC", night, at)
+				}
+				got = append(got, night.String())
+			}
+			if len(got) != len(test.want) || len(got) != NightsBetween(arrival, departure) {
+				t.Fatalf("Nights() = %v, NightsBetween() = %d, want %v", got, NightsBetween(arrival, departure), test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Errorf("Nights() = %v, want %v", got, test.want)
+				}
+			}
+		})
+	}
+}
+
+// A stay of no nights matches nothing, rather than reading as a closed
+// season because no night is open.
+func TestScrapeZeroNights(t *testing.T) {
+	campground, err := decodeCampground(readFixture(t, "month.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	arrival := CivilDate{2027, time.July, 14}
+	p := staticProvider{campground}
+	ctx := context.Background()
+	var closed *SeasonClosedError
+
+	sites, err := Scrape(ctx, p, "232447", arrival, arrival)
+	if errors.As(err, &closed) || err != nil || len(sites) != 0 {
+		t.Errorf("Scrape() = %v, %v, want no sites", sites, err)
+	}
+	if _, err := ScrapeDetailed(ctx, p, "232447", arrival, arrival); err != nil {
+		t.Errorf("ScrapeDetailed() error %v", err)
+	}
+	if _, err := ScrapePartialStays(ctx, p, "232447", arrival, arrival, 1); err != nil {
+		t.Errorf("ScrapePartialStays() error %v", err)
+	}
+	if seasonClosed(Campground{Campsites: map[string]Campsite{}}, nil) {
+		t.Error("a campground with no campsites is closed for a stay of no nights")
+	}
+	if seasonClosed(Campground{Campsites: map[string]Campsite{}}, Nights(arrival, arrival.Next())) {
+		t.Error("a campground with no campsites is closed for the season")
+	}
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+)
+
+// maxMatrixSites is the widest grid Matrix renders; with more sites it shows
+// a count per window instead.
+const maxMatrixSites = 12
+
+// Window is one arrival and departure pair.
+type Window struct {
+	Start CivilDate
+	End   CivilDate
+}
+
+// Label formats the window compactly, e.g. "Jul 14–16" or "Jul 30–Aug 2".
+func (w Window) Label() string {
+	start, end := w.Start.Time(), w.End.Time()
+	if w.Start.Month == w.End.Month {
+		return start.Format("Jan 2") + "–" + end.Format("2")
+	}
+	return start.Format("Jan 2") + "–" + end.Format("Jan 2")
+}
+
+// Matrix pivots runs into windows × sites, for summarising results that
+// repeat the same sites across many windows.
+type Matrix struct {
+	Windows []Window
+	Sites   []string
+	has     map[Window]map[string]bool
+}
+
+// SummarizeRuns builds a Matrix from runs, with windows in date order and
+// sites in label order.
+func SummarizeRuns(runs []Run) Matrix {
+	m := Matrix{has: map[Window]map[string]bool{}}
+	sites := map[string]bool{}
+	for _, r := range runs {
+		w := Window{r.Start, r.End}
+		if m.has[w] == nil {
+			m.has[w] = map[string]bool{}
+			m.Windows = append(m.Windows, w)
+		}
+		m.has[w][r.Site] = true
+		if !sites[r.Site] {
+			sites[r.Site] = true
+			m.Sites = append(m.Sites, r.Site)
+		}
+	}
+	sort.Slice(m.Windows, func(i, j int) bool {
+		if m.Windows[i].Start != m.Windows[j].Start {
+			return m.Windows[i].Start.Before(m.Windows[j].Start)
+		}
+		return m.Windows[i].End.Before(m.Windows[j].End)
+	})
+	sort.Slice(m.Sites, func(i, j int) bool { return NaturalLess(m.Sites[i], m.Sites[j]) })
+	return m
+}
+
+// Has reports whether site is available for window w.
+func (m Matrix) Has(w Window, site string) bool {
+	return m.has[w][site]
+}
+
+// Count returns how many sites are available for window w.
+func (m Matrix) Count(w Window) int {
+	return len(m.has[w])
+}
+
+// compact reports whether the matrix is too wide for a per-site grid.
+func (m Matrix) compact() bool {
+	return len(m.Sites) > maxMatrixSites
+}
+
+// Text renders the matrix as a fixed-width grid for a monospaced block, with
+// "x" marking an available site, or as a count per window when there are too
+// many sites to show.
+func (m Matrix) Text() string {
+	labelWidth := len("Window")
+	for _, w := range m.Windows {
+		if n := len([]rune(w.Label())); n > labelWidth {
+			labelWidth = n
+		}
+	}
+	pad := func(s string, width int) string {
+		return s + strings.Repeat(" ", width-len([]rune(s)))
+	}
+
+	var b strings.Builder
+	if m.compact() {
+		fmt.Fprintf(&b, "%s  Sites\n", pad("Window", labelWidth))
+		for _, w := range m.Windows {
+			fmt.Fprintf(&b, "%s  %d\n", pad(w.Label(), labelWidth), m.Count(w))
+		}
+		return b.String()
+	}
+	b.WriteString(pad("Window", labelWidth))
+	for _, site := range m.Sites {
+		b.WriteString("  " + site)
+	}
+	b.WriteString("\n")
+	for _, w := range m.Windows {
+		b.WriteString(pad(w.Label(), labelWidth))
+		for _, site := range m.Sites {
+			mark := "."
+			if m.Has(w, site) {
+				mark = "x"
+			}
+			b.WriteString("  " + pad(mark, len([]rune(site))))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// HTML renders the matrix as a small table with header scopes, or a count
+// per window when there are too many sites to show.
+func (m Matrix) HTML() string {
+	var b strings.Builder
+	b.WriteString(`<table aria-label="Availability by window"><caption>Availability by window</caption><thead><tr><th scope="col">Window</th>`)
+	if m.compact() {
+		b.WriteString(`<th scope="col">Sites</th>`)
+	} else {
+		for _, site := range m.Sites {
+			fmt.Fprintf(&b, `<th scope="col">%s</th>`, html.EscapeString(site))
+		}
+	}
+	b.WriteString("</tr></thead><tbody>")
+	for _, w := range m.Windows {
+		fmt.Fprintf(&b, `<tr><th scope="row">%s</th>`, html.EscapeString(w.Label()))
+		if m.compact() {
+			fmt.Fprintf(&b, "<td>%d</td>", m.Count(w))
+		} else {
+			for _, site := range m.Sites {
+				cell := "–"
+				if m.Has(w, site) {
+					cell = "Available"
+				}
+				fmt.Fprintf(&b, "<td>%s</td>", cell)
+			}
+		}
+		b.WriteString("</tr>")
+	}
+	b.WriteString("</tbody></table>")
+	return b.String()
+}
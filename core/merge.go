@@ -0,0 +1,101 @@
+package core
+
+import (
+	"context"
+	"expvar"
+	"strconv"
+	"time"
+)
+
+// FetchRange fetches every month that holds a night from start up to end and
+// merges them into one Campground with MergeMonth, in month order. It stops
+// with ctx's error once ctx is done.
+func FetchRange(ctx context.Context, p Provider, campgroundID string, start CivilDate, end CivilDate) (Campground, error) {
+	merged := Campground{}
+	for _, month := range monthsCovering(start, end) {
+		if err := ctx.Err(); err != nil {
+			return merged, err
+		}
+		campground, err := p.FetchMonth(ctx, campgroundID, month)
+		if err != nil {
+			return merged, err
+		}
+		MergeMonth(&merged, campground, month)
+	}
+	return merged, nil
+}
+
+// monthsCovering returns the first of each month, in UTC, holding a night
+// from start up to end. Months are where dates meet recreation.gov, whose
+// month requests take a timestamp.
+func monthsCovering(start CivilDate, end CivilDate) []time.Time {
+	months := []time.Time{}
+	last := end.AddDays(-1).Time()
+	for month := time.Date(start.Year, start.Month, 1, 0, 0, 0, 0, time.UTC); !month.After(last); month = month.AddDate(0, 1, 0) {
+		months = append(months, month)
+	}
+	return months
+}
+
+// MergeConflict records a campsite whose metadata changed between months.
+type MergeConflict struct {
+	CampsiteID string
+	Field      string
+	Old, New   string
+	// Month is the later month, whose value was kept.
+	Month time.Time
+}
+
+// mergeConflicts counts metadata conflicts seen by MergeMonth.
+var mergeConflicts = expvar.NewInt("campsite_metadata_conflicts")
+
+// MergeMonth merges next, the payload for month, into dst. Each campsite's
+// availability is the union of both months. When a campsite's metadata
+// differs, next is assumed to be the more recent month and wins, and the
+// discrepancy is appended to dst.Conflicts.
+func MergeMonth(dst *Campground, next Campground, month time.Time) {
+	if dst.Campsites == nil {
+		if next.Campsites == nil {
+			return
+		}
+		dst.Campsites = map[string]Campsite{}
+	}
+	for id, site := range next.Campsites {
+		existing, ok := dst.Campsites[id]
+		if !ok {
+			dst.Campsites[id] = site
+			continue
+		}
+		fields := []struct{ name, old, new string }{
+			{"campsite_id", strconv.Itoa(existing.CampsiteID), strconv.Itoa(site.CampsiteID)},
+			{"campsite_type", existing.CampsiteType, site.CampsiteType},
+			{"loop", existing.Loop, site.Loop},
+			{"site", existing.Site, site.Site},
+		}
+		for _, f := range fields {
+			if f.old != f.new {
+				dst.Conflicts = append(dst.Conflicts, MergeConflict{CampsiteID: id, Field: f.name, Old: f.old, New: f.new, Month: month})
+				mergeConflicts.Add(1)
+			}
+		}
+		availabilities := make(map[CivilDate]string, len(existing.Availabilities)+len(site.Availabilities))
+		for night, status := range existing.Availabilities {
+			availabilities[night] = status
+		}
+		for night, status := range site.Availabilities {
+			availabilities[night] = status
+		}
+		site.Availabilities = availabilities
+		if existing.Quantities != nil || site.Quantities != nil {
+			quantities := make(map[CivilDate]int, len(existing.Quantities)+len(site.Quantities))
+			for night, remaining := range existing.Quantities {
+				quantities[night] = remaining
+			}
+			for night, remaining := range site.Quantities {
+				quantities[night] = remaining
+			}
+			site.Quantities = quantities
+		}
+		dst.Campsites[id] = site
+	}
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// mergeProvider serves merge-july.json and merge-august.json for their
+// months.
+type mergeProvider struct{ t *testing.T }
+
+func (p mergeProvider) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	return decodeCampground(readFixture(p.t, "merge-"+map[time.Month]string{time.July: "july", time.August: "august"}[month.Month()]+".json"))
+}
+
+func mergeNight(t *testing.T, date string) CivilDate {
+	t.Helper()
+	return mustCivilDate(t, date)
+}
+
+// The two months disagree about site 1001's metadata and about the night of
+// Aug 1 at sites 1001 and 1010, which July's page repeats. August's page,
+// merged second, wins both; every night either lists is kept.
+func TestFetchRangeMergesMonths(t *testing.T) {
+	before := mergeConflicts.Value()
+	arrival := mergeNight(t, "2027-07-30")
+	campground, err := FetchRange(context.Background(), mergeProvider{t}, "232447", arrival, arrival.AddDays(4))
+	if err != nil {
+		t.Fatalf("FetchRange: %v", err)
+	}
+
+	want := map[string]map[string]string{
+		"1001": {"2027-07-30": "Available", "2027-07-31": "Available", "2027-08-01": "Available", "2027-08-02": "Reserved"},
+		"1002": {"2027-07-31": "Available"},
+		"1003": {"2027-08-01": "Available"},
+		"1010": {"2027-07-31": "Available", "2027-08-01": "Available"},
+	}
+	got := map[string]map[string]string{}
+	for id, site := range campground.Campsites {
+		got[id] = map[string]string{}
+		for night, status := range site.Availabilities {
+			got[id][night.String()] = status
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged availability %v, want %v", got, want)
+	}
+	wantQuantities := map[CivilDate]int{mergeNight(t, "2027-07-31"): 2, mergeNight(t, "2027-08-01"): 1}
+	if q := campground.Campsites["1010"].Quantities; !reflect.DeepEqual(q, wantQuantities) {
+		t.Errorf("1010 quantities %v, want %v", q, wantQuantities)
+	}
+
+	site := campground.Campsites["1001"]
+	if site.Loop != "Loop B" || site.Site != "B001" || site.CampsiteType != "STANDARD ELECTRIC" || site.CampsiteID != 1001 {
+		t.Errorf("1001 metadata %+v, want August's", site)
+	}
+	august := mergeNight(t, "2027-08-01").Time()
+	wantConflicts := []MergeConflict{
+		{CampsiteID: "1001", Field: "campsite_type", Old: "STANDARD NONELECTRIC", New: "STANDARD ELECTRIC", Month: august},
+		{CampsiteID: "1001", Field: "loop", Old: "Loop A", New: "Loop B", Month: august},
+		{CampsiteID: "1001", Field: "site", Old: "A001", New: "B001", Month: august},
+	}
+	if !reflect.DeepEqual(campground.Conflicts, wantConflicts) {
+		t.Errorf("conflicts %+v, want %+v", campground.Conflicts, wantConflicts)
+	}
+	if n := mergeConflicts.Value() - before; n != 3 {
+		t.Errorf("conflict counter rose by %d, want 3", n)
+	}
+}
+
+// Whichever page is merged second wins a night they disagree about, so
+// merging out of order lets the earlier month win.
+func TestMergeMonthSecondPageWins(t *testing.T) {
+	july, err := decodeCampground(readFixture(t, "merge-july.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	august, err := decodeCampground(readFixture(t, "merge-august.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	night := mergeNight(t, "2027-08-01")
+	merged := Campground{}
+	MergeMonth(&merged, august, night.Time())
+	MergeMonth(&merged, july, night.Time().AddDate(0, -1, 0))
+	if status := merged.Campsites["1001"].Availabilities[night]; status != "Reserved" {
+		t.Errorf("1001 on Aug 1 is %q, want July's Reserved", status)
+	}
+	if site := merged.Campsites["1001"]; site.Loop != "Loop A" {
+		t.Errorf("1001 loop %q, want July's", site.Loop)
+	}
+	if q := merged.Campsites["1010"].Quantities[night]; q != 0 {
+		t.Errorf("1010 has %d left on Aug 1, want July's 0", q)
+	}
+}
+
+// Merging into an empty campground copies the first page, and a page with
+// no campsites leaves the merge without any.
+func TestMergeMonthEmpty(t *testing.T) {
+	merged := Campground{}
+	MergeMonth(&merged, Campground{}, mergeNight(t, "2027-07-01").Time())
+	if merged.Campsites != nil {
+		t.Errorf("merging nothing gave %+v", merged)
+	}
+	MergeMonth(&merged, Campground{Campsites: map[string]Campsite{}}, mergeNight(t, "2027-07-01").Time())
+	if merged.Campsites == nil || len(merged.Campsites) != 0 {
+		t.Errorf("merging an empty page gave %+v, want no campsites", merged.Campsites)
+	}
+}
@@ -0,0 +1,42 @@
+package core
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// SortNatural sorts labels in place with NaturalLess.
+func SortNatural(labels []string) {
+	sort.SliceStable(labels, func(i, j int) bool { return NaturalLess(labels[i], labels[j]) })
+}
+
+// NaturalLess orders site labels such as "A9" before "A10" by comparing
+// runs of digits numerically and everything else as text.
+func NaturalLess(a, b string) bool {
+	for a != "" && b != "" {
+		ca, restA := labelChunk(a)
+		cb, restB := labelChunk(b)
+		if ca != cb {
+			na, errA := strconv.Atoi(ca)
+			nb, errB := strconv.Atoi(cb)
+			if errA == nil && errB == nil && na != nb {
+				return na < nb
+			}
+			return ca < cb
+		}
+		a, b = restA, restB
+	}
+	return len(a) < len(b)
+}
+
+// labelChunk splits off the leading run of digits or non-digits.
+func labelChunk(s string) (string, string) {
+	digit := unicode.IsDigit(rune(s[0]))
+	i := strings.IndexFunc(s, func(r rune) bool { return unicode.IsDigit(r) != digit })
+	if i < 0 {
+		return s, ""
+	}
+	return s[:i], s[i:]
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNaturalLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"A2", "A10", true},
+		{"A10", "A2", false},
+		{"9", "10", true},
+		{"10", "100", true},
+		{"100", "9", false},
+		// Equal numbers fall back to the text, so the order is total.
+		{"A010", "A10", true},
+		{"A10", "A010", false},
+		{"A1", "B1", true},
+		{"A", "A1", true},
+		{"A1", "A1", false},
+		{"Group 2", "Group 10", true},
+		{"", "A", true},
+	}
+	for _, test := range tests {
+		if got := NaturalLess(test.a, test.b); got != test.want {
+			t.Errorf("NaturalLess(%q, %q) = %v, want %v", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSortNatural(t *testing.T) {
+	labels := []string{"100", "A10", "9", "B1", "A2", "10", "A1"}
+	SortNatural(labels)
+	if want := []string{"9", "10", "100", "A1", "A2", "A10", "B1"}; !reflect.DeepEqual(labels, want) {
+		t.Errorf("SortNatural() = %v, want %v", labels, want)
+	}
+}
+
+// Site IDs of different lengths are ordered by number wherever results are
+// sorted by site.
+func TestSortedSitesNatural(t *testing.T) {
+	if got, want := RankSites([]string{"100", "10", "9"}, nil), []string{"9", "10", "100"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RankSites() = %v, want %v", got, want)
+	}
+	if got, want := RankSites([]string{"100", "10", "9"}, []string{"100"}), []string{"100", "9", "10"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("RankSites() with a priority = %v, want %v", got, want)
+	}
+
+	stays := []Stay{{Site: "100", Nights: 2}, {Site: "10", Nights: 2}, {Site: "9", Nights: 2}, {Site: "11", Nights: 3}}
+	sortStays(stays)
+	if want := []Stay{{Site: "11", Nights: 3}, {Site: "9", Nights: 2}, {Site: "10", Nights: 2}, {Site: "100", Nights: 2}}; !reflect.DeepEqual(stays, want) {
+		t.Errorf("sortStays() = %v, want %v", stays, want)
+	}
+
+	start := CivilDate{2027, time.July, 14}
+	run := func(site string, nights int) Run {
+		return Run{Site: site, Start: start, End: start.AddDays(nights)}
+	}
+	runs := []Run{run("100", 2), run("10", 2), run("9", 2)}
+	sortRuns(runs)
+	if want := []Run{run("9", 2), run("10", 2), run("100", 2)}; !reflect.DeepEqual(runs, want) {
+		t.Errorf("sortRuns() = %v, want %v", runs, want)
+	}
+	partial := []Run{run("100", 2), run("10", 2), run("9", 1), run("11", 3)}
+	sortPartialStays(partial)
+	if want := []Run{run("11", 3), run("10", 2), run("100", 2), run("9", 1)}; !reflect.DeepEqual(partial, want) {
+		t.Errorf("sortPartialStays() = %v, want %v", partial, want)
+	}
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"sync"
+	"time"
+)
+
+// DefaultBlockedCooldown is how long a Pacer refuses requests after
+// recreation.gov serves a bot challenge, when its Cooldown is unset.
+const DefaultBlockedCooldown = 10 * time.Minute
+
+// ErrBlocked matches, with errors.Is, every *BlockedError.
+var ErrBlocked = errors.New("recreation.gov is serving a bot challenge")
+
+// BlockedError is returned when recreation.gov answers with something other
+// than JSON, typically an HTML challenge page, or when a Pacer is still
+// cooling down from one. It is never retried: asking again straight away is
+// what gets a client challenged in the first place.
+type BlockedError struct {
+	URL         string
+	ContentType string
+	// Until is when the Pacer that refused the request will allow requests
+	// again, or zero when there is none.
+	Until time.Time
+}
+
+func (e *BlockedError) Error() string {
+	if e.ContentType == "" {
+		return fmt.Sprintf("%s: holding off until %s: %v", e.URL, e.Until.Format(time.RFC3339), ErrBlocked)
+	}
+	return fmt.Sprintf("%s returned %s instead of JSON: %v", e.URL, e.ContentType, ErrBlocked)
+}
+
+// Is reports target == ErrBlocked.
+func (e *BlockedError) Is(target error) bool { return target == ErrBlocked }
+
+// Pacer spaces out recreation.gov requests across every RecreationGov
+// sharing it: a token bucket holding one token that refills every Interval.
+// After a request is blocked it refuses every request for Cooldown, so the
+// rest of an invocation backs off instead of hammering the challenge. The
+// zero value does not delay requests but still cools down.
+type Pacer struct {
+	Interval time.Duration
+	Cooldown time.Duration
+	// Clock times the spacing and cooldowns. Nil means the wall clock.
+	Clock Clock
+
+	mu           sync.Mutex
+	next         time.Time
+	blockedUntil time.Time
+}
+
+// wait blocks until the next request may be sent, failing with a
+// *BlockedError during a cooldown and with ctx's error when it ends first.
+func (p *Pacer) wait(ctx context.Context, url string) error {
+	p.mu.Lock()
+	now := clockNow(p.Clock)
+	if now.Before(p.blockedUntil) {
+		until := p.blockedUntil
+		p.mu.Unlock()
+		return &BlockedError{URL: url, Until: until}
+	}
+	at := p.next
+	if at.Before(now) {
+		at = now
+	}
+	p.next = at.Add(p.Interval)
+	p.mu.Unlock()
+
+	if delay := at.Sub(now); delay > 0 {
+		timer := time.NewTimer(delay)
+		defer timer.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+	return nil
+}
+
+// BlockedUntil is when the cooldown after a blocked request ends, or the
+// zero time when p is not cooling down.
+func (p *Pacer) BlockedUntil() time.Time {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !clockNow(p.Clock).Before(p.blockedUntil) {
+		return time.Time{}
+	}
+	return p.blockedUntil
+}
+
+// block starts a cooldown.
+func (p *Pacer) block() {
+	cooldown := p.Cooldown
+	if cooldown <= 0 {
+		cooldown = DefaultBlockedCooldown
+	}
+	p.mu.Lock()
+	p.blockedUntil = clockNow(p.Clock).Add(cooldown)
+	p.mu.Unlock()
+}
+
+// notJSON reports whether a response is something other than the JSON the
+// API serves, going by its Content-Type or, when that is missing or vague,
+// its first non-blank byte.
+func notJSON(contentType string, data []byte) bool {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		switch mediaType {
+		case "text/html", "application/xhtml+xml":
+			return true
+		case "application/json":
+			return false
+		}
+	}
+	trimmed := bytes.TrimSpace(data)
+	return len(trimmed) > 0 && trimmed[0] == '<'
+}
@@ -0,0 +1,41 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestPacerCooldown(t *testing.T) {
+	tests := []struct {
+		name     string
+		cooldown time.Duration
+		elapsed  time.Duration
+		blocked  bool
+	}{
+		{"during the default cooldown", 0, DefaultBlockedCooldown - time.Second, true},
+		{"after the default cooldown", 0, DefaultBlockedCooldown, false},
+		{"during a set cooldown", time.Minute, 30 * time.Second, true},
+		{"after a set cooldown", time.Minute, time.Minute, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			clock := &fakeClock{at: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)}
+			p := &Pacer{Cooldown: test.cooldown, Clock: clock}
+			p.block()
+			clock.advance(test.elapsed)
+			if until := p.BlockedUntil(); until.IsZero() == test.blocked {
+				t.Errorf("BlockedUntil after %v = %v, want blocked %v", test.elapsed, until, test.blocked)
+			}
+			err := p.wait(context.Background(), "https://example.com")
+			var blocked *BlockedError
+			if errors.As(err, &blocked) != test.blocked {
+				t.Fatalf("wait after %v = %v, want blocked %v", test.elapsed, err, test.blocked)
+			}
+			if test.blocked && !blocked.Until.Equal(p.blockedUntil) {
+				t.Errorf("BlockedError.Until = %v, want %v", blocked.Until, p.blockedUntil)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAvailablePairs(t *testing.T) {
+	// Loop A runs A9, A10, A14, A15, A16 and an unnamed site; loop B has
+	// B1 and B2, and G1 is a group site of its own.
+	campground := Campground{Campsites: map[string]Campsite{
+		"9":   {Loop: "A", Site: "A9"},
+		"10":  {Loop: "A", Site: "A10"},
+		"14":  {Loop: "A", Site: "A14"},
+		"15":  {Loop: "A", Site: "A15"},
+		"16":  {Loop: "A", Site: "A16"},
+		"99":  {Loop: "A"},
+		"101": {Loop: "B", Site: "B1"},
+		"102": {Loop: "B", Site: "B2"},
+		"200": {Loop: "Group", Site: "G1"},
+	}}
+	tests := []struct {
+		name      string
+		available []string
+		required  []Pair
+		adjacent  bool
+		want      []Pair
+	}{
+		{name: "neighbours", available: []string{"14", "15"}, adjacent: true, want: []Pair{{"14", "15"}}},
+		{name: "natural order", available: []string{"9", "10"}, adjacent: true, want: []Pair{{"10", "9"}}},
+		{name: "a run of three", available: []string{"14", "15", "16"}, adjacent: true, want: []Pair{{"14", "15"}, {"15", "16"}}},
+		{name: "booked between", available: []string{"14", "16"}, adjacent: true, want: []Pair{}},
+		{name: "across loops", available: []string{"16", "101", "200"}, adjacent: true, want: []Pair{}},
+		{name: "unnamed site", available: []string{"16", "99"}, adjacent: true, want: []Pair{}},
+		{name: "required across loops", available: []string{"16", "101"}, required: []Pair{{"101", "16"}}, want: []Pair{{"101", "16"}}},
+		{name: "required, one booked", available: []string{"14"}, required: []Pair{{"14", "15"}}, want: []Pair{}},
+		{name: "required twice and reversed", available: []string{"14", "15"}, required: []Pair{{"15", "14"}, {"14", "15"}}, want: []Pair{{"14", "15"}}},
+		{name: "required with itself", available: []string{"14"}, required: []Pair{{"14", "14"}}, want: []Pair{}},
+		{name: "required only, neighbours not paired", available: []string{"14", "15", "101", "102"}, required: []Pair{{"101", "102"}},
+			want: []Pair{{"101", "102"}}},
+		{name: "required and adjacent overlapping", available: []string{"14", "15", "101", "102"}, required: []Pair{{"15", "14"}, {"14", "101"}}, adjacent: true,
+			want: []Pair{{"101", "102"}, {"101", "14"}, {"14", "15"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := AvailablePairs(campground, test.available, test.required, test.adjacent); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("AvailablePairs() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"sort"
+)
+
+// Pair is two campsites that are both available, reported together for
+// groups that want to be neighbours.
+type Pair [2]string
+
+// String renders the pair as "A014 + A015".
+func (p Pair) String() string {
+	return p[0] + " + " + p[1]
+}
+
+// ScrapePairs fetches availability like Scrape and returns pairs of campsites
+// that are both available on every night. required lists exact campsite ID
+// pairs to look for; with adjacent set, any two sites in the same loop whose
+// labels sort next to each other are also reported. Pairs are sorted and
+// unique.
+func ScrapePairs(ctx context.Context, p Provider, campgroundID string, arrival CivilDate, departure CivilDate,
+	required []Pair, adjacent bool) ([]Pair, error) {
+	campground, err := FetchRange(ctx, p, campgroundID, arrival, departure)
+	if err != nil {
+		return nil, err
+	}
+	if seasonClosed(campground, Nights(arrival, departure)) {
+		return nil, &SeasonClosedError{CampgroundID: campgroundID, Arrival: arrival, Departure: departure}
+	}
+	available, err := AvailableSites(ctx, campground, arrival, departure)
+	return AvailablePairs(campground, available, required, adjacent), err
+}
+
+// AvailablePairs pairs up the available campsites of campground as described
+// in ScrapePairs.
+func AvailablePairs(campground Campground, available []string, required []Pair, adjacent bool) []Pair {
+	free := map[string]bool{}
+	for _, id := range available {
+		free[id] = true
+	}
+	seen := map[Pair]bool{}
+	pairs := []Pair{}
+	add := func(a, b string) {
+		if b < a {
+			a, b = b, a
+		}
+		p := Pair{a, b}
+		if a != b && free[a] && free[b] && !seen[p] {
+			seen[p] = true
+			pairs = append(pairs, p)
+		}
+	}
+	for _, p := range required {
+		add(p[0], p[1])
+	}
+
+	if adjacent {
+		loops := map[string][]string{}
+		for id, site := range campground.Campsites {
+			if site.Site != "" {
+				loops[site.Loop] = append(loops[site.Loop], id)
+			}
+		}
+		for _, ids := range loops {
+			sort.Slice(ids, func(i, j int) bool {
+				return NaturalLess(campground.Campsites[ids[i]].Site, campground.Campsites[ids[j]].Site)
+			})
+			for i := 1; i < len(ids); i++ {
+				add(ids[i-1], ids[i])
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+	return pairs
+}
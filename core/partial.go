@@ -0,0 +1,82 @@
+package core
+
+import (
+	"context"
+	"sort"
+)
+
+// LongestOpenRun returns the longest run of consecutive available nights at
+// site from arrival up to departure, as a Run whose End is the morning after
+// its last night. The departure night itself is never needed. The earliest
+// run wins a tie. It returns false when no night is available.
+func LongestOpenRun(siteID string, site Campsite, arrival CivilDate, departure CivilDate) (Run, bool) {
+	best, found := Run{}, false
+	var start CivilDate
+	length, bestLength := 0, 0
+	for _, night := range Nights(arrival, departure) {
+		if !isAvailable(site, night) {
+			length = 0
+			continue
+		}
+		if length == 0 {
+			start = night
+		}
+		length++
+		if length > bestLength {
+			bestLength = length
+			best, found = Run{Site: siteID, Start: start, End: night.Next()}, true
+		}
+	}
+	return best, found
+}
+
+// PartialStays returns, for each campsite whose longest open run between
+// arrival and departure is at least minNights, that run. Full stays come
+// first because they are the longest, then shorter runs, then runs that
+// start earlier; ties are broken by site ID. ctx is checked before each
+// campsite as in CheckAvailability.
+func PartialStays(ctx context.Context, campground Campground, arrival CivilDate, departure CivilDate, minNights int) ([]Run, error) {
+	if minNights < 1 {
+		minNights = 1
+	}
+	ids := campground.SiteIDs()
+
+	runs := []Run{}
+	for checked, id := range ids {
+		if err := ctx.Err(); err != nil {
+			sortPartialStays(runs)
+			return runs, &PartialResultError{Checked: checked, Total: len(ids), Err: err}
+		}
+		run, ok := LongestOpenRun(id, campground.Campsites[id], arrival, departure)
+		if ok && NightsBetween(run.Start, run.End) >= minNights {
+			runs = append(runs, run)
+		}
+	}
+	sortPartialStays(runs)
+	return runs, nil
+}
+
+// ScrapePartialStays fetches the stay's months and returns PartialStays.
+func ScrapePartialStays(ctx context.Context, p Provider, campgroundID string, arrival CivilDate, departure CivilDate, minNights int) ([]Run, error) {
+	campground, err := FetchRange(ctx, p, campgroundID, arrival, departure)
+	if err != nil {
+		return nil, err
+	}
+	if seasonClosed(campground, Nights(arrival, departure)) {
+		return nil, &SeasonClosedError{CampgroundID: campgroundID, Arrival: arrival, Departure: departure}
+	}
+	return PartialStays(ctx, campground, arrival, departure, minNights)
+}
+
+func sortPartialStays(runs []Run) {
+	sort.SliceStable(runs, func(i, j int) bool {
+		li, lj := NightsBetween(runs[i].Start, runs[i].End), NightsBetween(runs[j].Start, runs[j].End)
+		if li != lj {
+			return li > lj
+		}
+		if runs[i].Start != runs[j].Start {
+			return runs[i].Start.Before(runs[j].Start)
+		}
+		return NaturalLess(runs[i].Site, runs[j].Site)
+	})
+}
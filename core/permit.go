@@ -0,0 +1,158 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Permit is one month of availability for a recreation.gov permit, such as
+// a trailhead's entry quota, keyed by division ID.
+type Permit struct {
+	ID        string
+	Divisions map[string]PermitDivision
+}
+
+// PermitDivision is one entry point or zone of a permit and its quota on
+// each date, keyed like Campsite.Availabilities.
+type PermitDivision struct {
+	ID     string
+	Quotas map[CivilDate]PermitQuota
+}
+
+// PermitQuota is how many of a date's permit slots are left.
+type PermitQuota struct {
+	Total     int `json:"total"`
+	Remaining int `json:"remaining"`
+}
+
+// permitResponse is the shape of the permit availability API's month
+// payload.
+type permitResponse struct {
+	Payload struct {
+		PermitID     string `json:"permit_id"`
+		Availability map[string]struct {
+			DivisionID       string                 `json:"division_id"`
+			DateAvailability map[string]PermitQuota `json:"date_availability"`
+		} `json:"availability"`
+	} `json:"payload"`
+}
+
+// decodePermit decodes a permit month payload, reducing each date key to
+// the date written as Campsite.UnmarshalJSON does.
+func decodePermit(data []byte) (Permit, error) {
+	if err := checkStructure(data); err != nil {
+		return Permit{}, err
+	}
+	var response permitResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return Permit{}, err
+	}
+	permit := Permit{ID: response.Payload.PermitID, Divisions: map[string]PermitDivision{}}
+	for key, division := range response.Payload.Availability {
+		id := division.DivisionID
+		if id == "" {
+			id = key
+		}
+		quotas := make(map[CivilDate]PermitQuota, len(division.DateAvailability))
+		for date, quota := range division.DateAvailability {
+			day, err := time.Parse(time.RFC3339, date)
+			if err != nil {
+				return Permit{}, fmt.Errorf("division %s date %q: %v", id, date, err)
+			}
+			quotas[CivilDateOf(day)] = quota
+		}
+		permit.Divisions[id] = PermitDivision{ID: id, Quotas: quotas}
+	}
+	return permit, nil
+}
+
+// PermitProvider fetches one month of availability for a permit. month is
+// any time within the month.
+type PermitProvider interface {
+	FetchPermitMonth(ctx context.Context, permitID string, month time.Time) (Permit, error)
+}
+
+// FetchPermitMonth implements PermitProvider with the same headers, retries
+// and pacing as FetchMonth.
+func (r RecreationGov) FetchPermitMonth(ctx context.Context, permitID string, month time.Time) (Permit, error) {
+	base := r.BaseURL
+	if base == "" {
+		base = recreationGovURL
+	}
+	firstOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	url := fmt.Sprintf("%s/api/permits/%s/availability/month?start_date=%s",
+		base, permitID, firstOfMonth.Format("2006-01-02T15:04:05.999999Z"))
+	data, err := r.Retry.do(ctx, url, func() ([]byte, error) {
+		return r.fetchOnce(ctx, url)
+	})
+	if err != nil {
+		return Permit{}, err
+	}
+	permit, err := decodePermit(data)
+	if err != nil {
+		return Permit{}, fmt.Errorf("decoding permit %s for %s: %w", permitID, month.Format("January 2006"), err)
+	}
+	return permit, nil
+}
+
+// PermitOpening is a division with slots left on a date.
+type PermitOpening struct {
+	Division  string
+	Date      CivilDate
+	Remaining int
+	Total     int
+}
+
+func (o PermitOpening) String() string {
+	return fmt.Sprintf("division %s has %d of %d slots on %s", o.Division, o.Remaining, o.Total, o.Date.Time().Format("Jan 2"))
+}
+
+// ScrapePermit fetches every month holding a date from start up to, but not
+// including, end, and returns the divisions with at least minSlots slots
+// left on those dates, ordered by date and then division. minSlots below 1
+// counts as 1.
+func ScrapePermit(ctx context.Context, p PermitProvider, permitID string, start CivilDate, end CivilDate, minSlots int) ([]PermitOpening, error) {
+	if minSlots < 1 {
+		minSlots = 1
+	}
+	wanted := map[CivilDate]bool{}
+	for _, day := range Nights(start, end) {
+		wanted[day] = true
+	}
+	// seen guards against a month payload repeating a neighbouring month's
+	// dates.
+	type divisionDay struct {
+		division string
+		day      CivilDate
+	}
+	seen := map[divisionDay]bool{}
+	openings := []PermitOpening{}
+	for _, month := range monthsCovering(start, end) {
+		if err := ctx.Err(); err != nil {
+			return openings, err
+		}
+		permit, err := p.FetchPermitMonth(ctx, permitID, month)
+		if err != nil {
+			return openings, err
+		}
+		for _, division := range permit.Divisions {
+			for day, quota := range division.Quotas {
+				key := divisionDay{division.ID, day}
+				if wanted[day] && quota.Remaining >= minSlots && !seen[key] {
+					seen[key] = true
+					openings = append(openings, PermitOpening{Division: division.ID, Date: day, Remaining: quota.Remaining, Total: quota.Total})
+				}
+			}
+		}
+	}
+	sort.Slice(openings, func(i, j int) bool {
+		if openings[i].Date != openings[j].Date {
+			return openings[i].Date.Before(openings[j].Date)
+		}
+		return openings[i].Division < openings[j].Division
+	})
+	return openings, nil
+}
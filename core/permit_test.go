@@ -0,0 +1,103 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// permit.json is a recorded month of three divisions: one keyed without
+// its division_id, one also listing a day of the next month, and one whose
+// date carries a zone offset.
+func TestDecodePermit(t *testing.T) {
+	permit, err := decodePermit(readFixture(t, "permit.json"))
+	if err != nil {
+		t.Fatalf("decodePermit: %v", err)
+	}
+	day := func(date string) CivilDate { return mustCivilDate(t, date) }
+	want := Permit{ID: "233260", Divisions: map[string]PermitDivision{
+		"166": {ID: "166", Quotas: map[CivilDate]PermitQuota{
+			day("2027-07-13"): {Total: 20, Remaining: 0},
+			day("2027-07-14"): {Total: 20, Remaining: 3},
+			day("2027-07-15"): {Total: 20, Remaining: 1},
+		}},
+		"167": {ID: "167", Quotas: map[CivilDate]PermitQuota{
+			day("2027-07-14"): {Total: 10, Remaining: 0},
+			day("2027-07-15"): {Total: 10, Remaining: 6},
+			day("2027-08-01"): {Total: 10, Remaining: 10},
+		}},
+		"168": {ID: "168", Quotas: map[CivilDate]PermitQuota{
+			day("2027-07-14"): {Total: 5, Remaining: 5},
+		}},
+	}}
+	if !reflect.DeepEqual(permit, want) {
+		t.Errorf("decodePermit() = %+v, want %+v", permit, want)
+	}
+}
+
+func TestDecodePermitMalformed(t *testing.T) {
+	for name, data := range map[string]string{
+		"not an object": `[]`,
+		"bad date":      `{"payload": {"availability": {"166": {"date_availability": {"July 14": {"total": 1}}}}}}`,
+		"bad quota":     `{"payload": {"availability": {"166": {"date_availability": {"2027-07-14T00:00:00Z": {"total": "many"}}}}}}`,
+	} {
+		if _, err := decodePermit([]byte(data)); err == nil {
+			t.Errorf("%s: decodePermit succeeded", name)
+		}
+	}
+}
+
+// fixturePermit serves permit.json for every month asked for, and records
+// the months fetched.
+type fixturePermit struct {
+	t       *testing.T
+	fetched *[]string
+}
+
+func (p fixturePermit) FetchPermitMonth(ctx context.Context, permitID string, month time.Time) (Permit, error) {
+	*p.fetched = append(*p.fetched, month.Format("2006-01"))
+	return decodePermit(readFixture(p.t, "permit.json"))
+}
+
+func TestScrapePermit(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    string
+		end      string
+		minSlots int
+		want     []string
+		fetched  []string
+	}{
+		{name: "any slot", start: "2027-07-13", end: "2027-07-16", minSlots: 0,
+			want:    []string{"division 166 has 3 of 20 slots on Jul 14", "division 168 has 5 of 5 slots on Jul 14", "division 166 has 1 of 20 slots on Jul 15", "division 167 has 6 of 10 slots on Jul 15"},
+			fetched: []string{"2027-07"}},
+		{name: "a party of four", start: "2027-07-13", end: "2027-07-16", minSlots: 4,
+			want:    []string{"division 168 has 5 of 5 slots on Jul 14", "division 167 has 6 of 10 slots on Jul 15"},
+			fetched: []string{"2027-07"}},
+		{name: "across months, each date once", start: "2027-07-31", end: "2027-08-02", minSlots: 1,
+			want:    []string{"division 167 has 10 of 10 slots on Aug 1"},
+			fetched: []string{"2027-07", "2027-08"}},
+		{name: "none left", start: "2027-07-13", end: "2027-07-14", minSlots: 1, want: []string{}, fetched: []string{"2027-07"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fetched := []string{}
+			openings, err := ScrapePermit(context.Background(), fixturePermit{t, &fetched}, "233260",
+				mustCivilDate(t, test.start), mustCivilDate(t, test.end), test.minSlots)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, opening := range openings {
+				got = append(got, opening.String())
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ScrapePermit() = %q, want %q", got, test.want)
+			}
+			if !reflect.DeepEqual(fetched, test.fetched) {
+				t.Errorf("fetched %v, want %v", fetched, test.fetched)
+			}
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"time"
+)
+
+// MonthPrior scores months of the year by how often a campground's past
+// scrapes found them open, higher being more promising. Months it does not
+// score count as zero.
+type MonthPrior map[time.Month]float64
+
+// PlanMonths returns months in the order to fetch them: by prior, highest
+// first, and in their given order among equals, so without a prior they
+// stay chronological.
+func PlanMonths(months []time.Time, prior MonthPrior) []time.Time {
+	planned := append([]time.Time{}, months...)
+	sort.SliceStable(planned, func(i, j int) bool {
+		return prior[planned[i].Month()] > prior[planned[j].Month()]
+	})
+	return planned
+}
+
+// FetchPlan is what ScrapeRunsPlanned fetched: its months in the order
+// chosen, and how many of them it requested before stopping. Horizon is
+// the first month not yet released, as given or found, and Skipped the
+// months left out for being past it.
+type FetchPlan struct {
+	Order   []time.Time
+	Fetched int
+	Horizon time.Time
+	Skipped []time.Time
+}
+
+// ScrapeRunsPlanned fetches the window's months in the order PlanMonths
+// gives s.Prior and returns FindRuns's runs with the plan it followed.
+// With s.StopAtFirst it fetches no further once the months so far hold a
+// run, and returns the runs found in them; a run needs every night
+// fetched, so none crosses into a month left out. Months from s.Horizon
+// on are not fetched, nor those after a month that comes back with every
+// night not yet released, as recreation.gov releases nights in order.
+func ScrapeRunsPlanned(ctx context.Context, p Provider, campgroundID string, s WindowSearch) ([]Run, FetchPlan, error) {
+	plan := FetchPlan{Horizon: s.Horizon}
+	if err := s.Validate(); err != nil {
+		return nil, plan, err
+	}
+	chronological := monthsCovering(s.WindowStart, s.WindowEnd)
+	plan.Order = PlanMonths(chronological, s.Prior)
+	fetched := map[time.Time]Campground{}
+	for _, month := range plan.Order {
+		if !plan.Horizon.IsZero() && !month.Before(plan.Horizon) {
+			plan.Skipped = append(plan.Skipped, month)
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, plan, err
+		}
+		campground, err := p.FetchMonth(ctx, campgroundID, month)
+		if err != nil {
+			return nil, plan, err
+		}
+		fetched[month] = campground
+		plan.Fetched++
+		if unreleasedMonth(campground) {
+			plan.Horizon = month
+		}
+		if !s.StopAtFirst || plan.Fetched == len(plan.Order) {
+			continue
+		}
+		runs, err := FindRuns(ctx, mergeFetched(chronological, fetched), s)
+		if err != nil || len(runs) > 0 {
+			return runs, plan, err
+		}
+	}
+	runs, err := FindRuns(ctx, mergeFetched(chronological, fetched), s)
+	return runs, plan, err
+}
+
+// unreleasedMonth reports whether every night campground has is not yet
+// released, and it has at least one.
+func unreleasedMonth(campground Campground) bool {
+	nights := 0
+	for _, site := range campground.Campsites {
+		for _, raw := range site.Availabilities {
+			if ParseStatus(raw) != StatusNotYetReleased {
+				return false
+			}
+			nights++
+		}
+	}
+	return nights > 0
+}
+
+// mergeFetched merges the months fetched in month order, as FetchRange
+// does, whatever order they were fetched in.
+func mergeFetched(months []time.Time, fetched map[time.Time]Campground) Campground {
+	merged := Campground{}
+	for _, month := range months {
+		if campground, ok := fetched[month]; ok {
+			MergeMonth(&merged, campground, month)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,142 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+func months(labels ...string) []time.Time {
+	parsed := []time.Time{}
+	for _, label := range labels {
+		month, err := time.Parse("2006-01", label)
+		if err != nil {
+			panic(err)
+		}
+		parsed = append(parsed, month)
+	}
+	return parsed
+}
+
+func monthLabels(months []time.Time) string {
+	labels := []string{}
+	for _, month := range months {
+		labels = append(labels, month.Format("2006-01"))
+	}
+	return strings.Join(labels, ",")
+}
+
+func TestPlanMonths(t *testing.T) {
+	window := months("2027-07", "2027-08", "2027-09")
+	tests := []struct {
+		name  string
+		prior MonthPrior
+		want  string
+	}{
+		{name: "no history", want: "2027-07,2027-08,2027-09"},
+		{name: "most open first", prior: MonthPrior{time.July: 0.1, time.August: 0.6, time.September: 0.3}, want: "2027-08,2027-09,2027-07"},
+		{name: "ties in order", prior: MonthPrior{time.September: 0.5}, want: "2027-09,2027-07,2027-08"},
+		{name: "other months", prior: MonthPrior{time.January: 0.9}, want: "2027-07,2027-08,2027-09"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := monthLabels(PlanMonths(window, test.prior)); got != test.want {
+				t.Errorf("PlanMonths() = %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// monthProvider serves site 1001 with the nights open in each month, or
+// every night not yet released in the unreleased months, and records the
+// months fetched.
+type monthProvider struct {
+	open       map[string][]string
+	unreleased map[string]bool
+	fetched    *[]time.Time
+}
+
+func (p monthProvider) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	*p.fetched = append(*p.fetched, month)
+	site := Campsite{CampsiteID: 1001, Site: "A001", Availabilities: map[CivilDate]string{}}
+	label, status, open := month.Format("2006-01"), "Reserved", p.open[month.Format("2006-01")]
+	if p.unreleased[label] {
+		status, open = "NYR", nil
+	}
+	for night := CivilDateOf(month); night.Month == month.Month(); night = night.Next() {
+		site.Availabilities[night] = status
+	}
+	for _, night := range open {
+		at, _ := ParseCivilDate(night)
+		site.Availabilities[at] = "Available"
+	}
+	return Campground{Campsites: map[string]Campsite{"1001": site}}, nil
+}
+
+func TestScrapeRunsPlanned(t *testing.T) {
+	open := map[string][]string{
+		"2027-07": {"2027-07-31"},
+		"2027-08": {"2027-08-01", "2027-08-20", "2027-08-21"},
+		"2027-09": {"2027-09-10", "2027-09-11"},
+	}
+	tests := []struct {
+		name        string
+		prior       MonthPrior
+		stopAtFirst bool
+		horizon     string
+		unreleased  string
+		wantFetched string
+		wantRuns    string
+		wantHorizon string
+		wantSkipped string
+	}{
+		{name: "in order", wantFetched: "2027-07,2027-08,2027-09", wantRuns: "07-31,08-20,09-10"},
+		{name: "by prior", prior: MonthPrior{time.September: 0.5, time.August: 0.2},
+			wantFetched: "2027-09,2027-08,2027-07", wantRuns: "07-31,08-20,09-10"},
+		{name: "stop at first in order", stopAtFirst: true, wantFetched: "2027-07,2027-08", wantRuns: "07-31,08-20"},
+		{name: "stop at first by prior", prior: MonthPrior{time.September: 0.5}, stopAtFirst: true,
+			wantFetched: "2027-09", wantRuns: "09-10"},
+		{name: "run across a month left out", prior: MonthPrior{time.August: 0.5, time.July: 0.2}, stopAtFirst: true,
+			wantFetched: "2027-08", wantRuns: "08-20"},
+		{name: "horizon", horizon: "2027-09", wantFetched: "2027-07,2027-08", wantRuns: "07-31,08-20", wantHorizon: "2027-09", wantSkipped: "2027-09"},
+		{name: "horizon before the window", horizon: "2027-06", wantFetched: "", wantRuns: "", wantHorizon: "2027-06", wantSkipped: "2027-07,2027-08,2027-09"},
+		{name: "unreleased month found", unreleased: "2027-08", wantFetched: "2027-07,2027-08", wantRuns: "", wantHorizon: "2027-08", wantSkipped: "2027-09"},
+		{name: "unreleased month found out of order", prior: MonthPrior{time.September: 0.5}, unreleased: "2027-09",
+			wantFetched: "2027-09,2027-07,2027-08", wantRuns: "07-31,08-20", wantHorizon: "2027-09"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fetched := []time.Time{}
+			search := WindowSearch{WindowStart: CivilDate{2027, time.July, 1}, WindowEnd: CivilDate{2027, time.October, 1},
+				Nights: 2, Prior: test.prior, StopAtFirst: test.stopAtFirst}
+			if test.horizon != "" {
+				search.Horizon = months(test.horizon)[0]
+			}
+			unreleased := map[string]bool{test.unreleased: true}
+			runs, plan, err := ScrapeRunsPlanned(context.Background(), monthProvider{open, unreleased, &fetched}, "232447", search)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := monthLabels(fetched); got != test.wantFetched {
+				t.Errorf("fetched %s, want %s", got, test.wantFetched)
+			}
+			if plan.Fetched != len(fetched) || len(plan.Order) != 3 {
+				t.Errorf("plan %+v, want %d of 3 months fetched", plan, len(fetched))
+			}
+			if got := monthLabels(plan.Skipped); got != test.wantSkipped {
+				t.Errorf("skipped %s, want %s", got, test.wantSkipped)
+			}
+			if horizon := monthLabels([]time.Time{plan.Horizon}); test.wantHorizon != "" && horizon != test.wantHorizon || test.wantHorizon == "" && !plan.Horizon.IsZero() {
+				t.Errorf("horizon %s, want %q", horizon, test.wantHorizon)
+			}
+			starts := []string{}
+			for _, run := range runs {
+				starts = append(starts, run.Start.Time().Format("01-02"))
+			}
+			if got := strings.Join(starts, ","); got != test.wantRuns {
+				t.Errorf("runs start %s, want %s", got, test.wantRuns)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Provider fetches one month of availability for a campground. month is any
+// time within the month; implementations use its year and month only.
+type Provider interface {
+	FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error)
+}
+
+// RecreationGov is the Provider backed by the recreation.gov availability API.
+type RecreationGov struct {
+	// Client is used for requests. http.DefaultClient is used when nil.
+	Client *http.Client
+	// MaxResponseBytes bounds each response body. DefaultMaxResponseBytes is
+	// used when zero; raise it for unusually large campgrounds.
+	MaxResponseBytes int64
+	// UserAgent and Header override the default request headers. Header
+	// values replace the defaults key by key; UserAgent wins over any
+	// User-Agent in Header.
+	UserAgent string
+	Header    http.Header
+	// Retry controls retries of throttled or failed requests. The zero value
+	// makes a single attempt.
+	Retry RetryPolicy
+	// BaseURL replaces https://www.recreation.gov, for pointing the provider
+	// at a test server.
+	BaseURL string
+	// Pacer, when set, spaces out requests and holds them off after a bot
+	// challenge. Share one between providers to pace them together.
+	Pacer *Pacer
+	// Clock reads Retry-After dates against. Nil means the wall clock.
+	Clock Clock
+}
+
+const recreationGovURL = "https://www.recreation.gov"
+
+// DefaultUserAgent identifies the scraper instead of Go's default
+// User-Agent, which recreation.gov is quicker to challenge.
+const DefaultUserAgent = "camp_finder/1.0 (+https://github.com/sgrasu/camp_finder)"
+
+// DefaultHeader is sent with every recreation.gov request unless overridden.
+var DefaultHeader = http.Header{
+	"Accept":          {"application/json"},
+	"Accept-Language": {"en-US,en;q=0.9"},
+	"User-Agent":      {DefaultUserAgent},
+}
+
+// newRequest builds a GET request with the defaults and overrides applied.
+func (r RecreationGov) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for key, values := range DefaultHeader {
+		request.Header[key] = values
+	}
+	for key, values := range r.Header {
+		request.Header[http.CanonicalHeaderKey(key)] = values
+	}
+	if r.UserAgent != "" {
+		request.Header.Set("User-Agent", r.UserAgent)
+	}
+	return request.WithContext(ctx), nil
+}
+
+// FetchMonth implements Provider.
+func (r RecreationGov) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	data, err := r.FetchMonthRaw(ctx, campgroundID, month)
+	if err != nil {
+		return Campground{}, err
+	}
+	campground, err := decodeCampground(data)
+	if err != nil {
+		return Campground{}, fmt.Errorf("decoding campground %s for %s: %w", campgroundID, month.Format("January 2006"), err)
+	}
+	return campground, nil
+}
+
+// FetchMonthRaw returns the undecoded month payload, read up to
+// MaxResponseBytes, retrying according to r.Retry. When retries run out the
+// error is a *RetryError wrapping the last attempt's; when ctx ends first, it
+// wraps ctx's error.
+func (r RecreationGov) FetchMonthRaw(ctx context.Context, campgroundID string, month time.Time) ([]byte, error) {
+	base := r.BaseURL
+	if base == "" {
+		base = recreationGovURL
+	}
+	firstOfMonth := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, time.UTC)
+	url := fmt.Sprintf("%s/api/camps/availability/campground/%s/month?start_date=%s",
+		base, campgroundID, firstOfMonth.Format("2006-01-02T15:04:05.999999Z"))
+	return r.Retry.do(ctx, url, func() ([]byte, error) {
+		return r.fetchOnce(ctx, url)
+	})
+}
+
+func (r RecreationGov) fetchOnce(ctx context.Context, url string) ([]byte, error) {
+	client := r.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := r.newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	if r.Pacer != nil {
+		if err := r.Pacer.wait(ctx, url); err != nil {
+			return nil, err
+		}
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer response.Body.Close()
+	countStatus(ctx, response.StatusCode)
+	contentType := response.Header.Get("Content-Type")
+	if response.StatusCode == http.StatusForbidden && notJSON(contentType, nil) {
+		return nil, r.blocked(url, contentType)
+	}
+	if response.StatusCode != http.StatusOK {
+		return nil, &StatusError{
+			URL:        url,
+			StatusCode: response.StatusCode,
+			RetryAfter: parseRetryAfter(response.Header.Get("Retry-After"), clockNow(r.Clock)),
+		}
+	}
+	limit := r.MaxResponseBytes
+	if limit <= 0 {
+		limit = DefaultMaxResponseBytes
+	}
+	data, err := readLimited(response.Body, limit)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", url, err)
+	}
+	if notJSON(contentType, data) {
+		return nil, r.blocked(url, contentType)
+	}
+	return data, nil
+}
+
+// blocked starts r.Pacer's cooldown and returns the *BlockedError for a
+// response that was not JSON.
+func (r RecreationGov) blocked(url string, contentType string) error {
+	if r.Pacer != nil {
+		r.Pacer.block()
+	}
+	if contentType == "" {
+		contentType = "an untyped non-JSON body"
+	}
+	return &BlockedError{URL: url, ContentType: contentType}
+}
+
+// StatusError is returned when recreation.gov answers with anything but 200
+// OK, such as a 403 or 429 when it is throttling us.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	// RetryAfter is the wait the response's Retry-After header asked for,
+	// or zero.
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("%s returned %d %s", e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrapeResponses(t *testing.T) {
+	month := readFixture(t, "month.json")
+	tests := []struct {
+		name        string
+		status      int
+		contentType string
+		header      map[string]string
+		body        []byte
+		limit       int64
+		wantSites   []string
+		// check inspects the error; when nil, the scrape must succeed.
+		check func(t *testing.T, err error)
+	}{
+		{name: "200", status: 200, contentType: "application/json", body: month, wantSites: []string{"1001"}},
+		{name: "403 json", status: 403, contentType: "application/json", body: []byte(`{"error":"forbidden"}`), check: func(t *testing.T, err error) {
+			var status *StatusError
+			if !errors.As(err, &status) || status.StatusCode != 403 || !strings.Contains(status.URL, "/campground/232447/month") {
+				t.Errorf("error %v is not a 403 *StatusError for the month", err)
+			}
+		}},
+		{name: "403 html", status: 403, contentType: "text/html", body: []byte("<html>Access denied</html>"), check: func(t *testing.T, err error) {
+			var blocked *BlockedError
+			if !errors.As(err, &blocked) {
+				t.Errorf("error %v is not a *BlockedError", err)
+			}
+		}},
+		{name: "429", status: 429, contentType: "application/json", header: map[string]string{"Retry-After": "30"}, body: []byte(`{}`), check: func(t *testing.T, err error) {
+			var status *StatusError
+			if !errors.As(err, &status) || status.StatusCode != 429 || status.RetryAfter != 30*time.Second {
+				t.Errorf("error %v is not a 429 *StatusError asking for 30s", err)
+			}
+		}},
+		{name: "429 until a date", status: 429, contentType: "application/json", header: map[string]string{"Retry-After": "Tue, 01 Jun 2027 12:01:30 GMT"}, body: []byte(`{}`), check: func(t *testing.T, err error) {
+			var status *StatusError
+			if !errors.As(err, &status) || status.RetryAfter != 90*time.Second {
+				t.Errorf("error %v is not a *StatusError asking for the 90s until the date", err)
+			}
+		}},
+		{name: "malformed json", status: 200, contentType: "application/json", body: []byte(`{"campsites":{"1001":`), check: func(t *testing.T, err error) {
+			var status *StatusError
+			if err == nil || errors.As(err, &status) {
+				t.Errorf("error %v, want a decoding error", err)
+			}
+		}},
+		{name: "too large", status: 200, contentType: "application/json", body: month, limit: 100, check: func(t *testing.T, err error) {
+			var tooLarge *ResponseTooLargeError
+			if !errors.As(err, &tooLarge) || tooLarge.Limit != 100 {
+				t.Errorf("error %v is not a *ResponseTooLargeError", err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", test.contentType)
+				for key, value := range test.header {
+					w.Header().Set(key, value)
+				}
+				w.WriteHeader(test.status)
+				w.Write(test.body)
+			}))
+			defer server.Close()
+			p := RecreationGov{BaseURL: server.URL, MaxResponseBytes: test.limit, Clock: &fakeClock{at: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)}}
+
+			arrival := CivilDate{2027, time.July, 14}
+			sites, err := Scrape(context.Background(), p, "232447", arrival, arrival.AddDays(2))
+			if test.check == nil {
+				if err != nil {
+					t.Fatalf("Scrape: %v", err)
+				}
+				if strings.Join(sites, ",") != strings.Join(test.wantSites, ",") {
+					t.Errorf("Scrape = %v, want %v", sites, test.wantSites)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatalf("Scrape = %v, want an error", sites)
+			}
+			test.check(t, err)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package core
+
+import "sort"
+
+// RankSites orders sites for booking: those in priority come first, in the
+// order given, followed by the rest in natural ID order. Priority entries
+// that are not in sites are ignored, so the result is always a permutation
+// of sites.
+func RankSites(sites []string, priority []string) []string {
+	rank := map[string]int{}
+	for i, site := range priority {
+		if _, seen := rank[site]; !seen {
+			rank[site] = i
+		}
+	}
+	ranked := append([]string(nil), sites...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		ri, iok := rank[ranked[i]]
+		rj, jok := rank[ranked[j]]
+		switch {
+		case iok && jok:
+			return ri < rj
+		case iok || jok:
+			return iok
+		default:
+			return NaturalLess(ranked[i], ranked[j])
+		}
+	})
+	return ranked
+}
@@ -0,0 +1,138 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// jitterRand is seeded per process so separate function instances do not
+// share a jitter sequence.
+var (
+	jitterMu   sync.Mutex
+	jitterRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// RetryPolicy controls how RecreationGov retries a month request that failed
+// with a network error, a 429 or a 5xx. A bot challenge is never retried.
+// The zero value makes one attempt.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first.
+	MaxAttempts int
+	// BaseBackoff is the wait before the second attempt; each later wait
+	// doubles it, up to MaxBackoff. A Retry-After header on a 429 or 503
+	// replaces the computed wait when it is longer.
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	// Jitter is the fraction, between 0 and 1, of each wait that is
+	// randomised so concurrent watches do not retry in lockstep.
+	Jitter float64
+}
+
+// DefaultRetryPolicy is what the scrape function uses unless configured
+// otherwise.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxAttempts: 4,
+	BaseBackoff: 500 * time.Millisecond,
+	MaxBackoff:  10 * time.Second,
+	Jitter:      0.2,
+}
+
+// RetryError is returned once every attempt has failed. Err is the last
+// attempt's error, so errors.As still finds a *StatusError.
+type RetryError struct {
+	URL      string
+	Attempts int
+	Err      error
+}
+
+func (e *RetryError) Error() string {
+	return fmt.Sprintf("giving up on %s after %d attempts: %v", e.URL, e.Attempts, e.Err)
+}
+
+func (e *RetryError) Unwrap() error { return e.Err }
+
+// retryable reports whether err is worth another attempt.
+func retryable(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrBlocked) {
+		return false
+	}
+	var status *StatusError
+	if errors.As(err, &status) {
+		return status.StatusCode == http.StatusTooManyRequests || status.StatusCode >= 500
+	}
+	// Anything else but an oversized body failed in transport: DNS,
+	// connection resets, a client timeout.
+	var tooLarge *ResponseTooLargeError
+	return !errors.As(err, &tooLarge)
+}
+
+// backoff returns the wait before attempt number attempt+1.
+func (p RetryPolicy) backoff(attempt int, err error) time.Duration {
+	wait := p.BaseBackoff << uint(attempt-1)
+	if p.MaxBackoff > 0 && (wait > p.MaxBackoff || wait <= 0) {
+		wait = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		jitterMu.Lock()
+		spread := jitterRand.Float64()*2 - 1
+		jitterMu.Unlock()
+		wait += time.Duration(p.Jitter * spread * float64(wait))
+	}
+	var status *StatusError
+	if errors.As(err, &status) && status.RetryAfter > wait {
+		wait = status.RetryAfter
+	}
+	return wait
+}
+
+// do calls fetch until it succeeds, fails with an error that is not worth
+// retrying, or runs out of attempts.
+func (p RetryPolicy) do(ctx context.Context, url string, fetch func() ([]byte, error)) ([]byte, error) {
+	attempts := p.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	for attempt := 1; ; attempt++ {
+		data, err := fetch()
+		if err == nil {
+			return data, nil
+		}
+		if !retryable(err) {
+			return nil, err
+		}
+		if attempt >= attempts {
+			if attempts == 1 {
+				return nil, err
+			}
+			return nil, &RetryError{URL: url, Attempts: attempt, Err: err}
+		}
+		timer := time.NewTimer(p.backoff(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("%w after %d attempts at %s, last error: %v", ctx.Err(), attempt, url, err)
+		case <-timer.C:
+		}
+	}
+}
+
+// parseRetryAfter reads a Retry-After header given either as seconds or as
+// an HTTP date. It returns zero when the header is absent or unparseable.
+func parseRetryAfter(value string, now time.Time) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil && at.After(now) {
+		return at.Sub(now)
+	}
+	return 0
+}
@@ -0,0 +1,109 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// CampgroundInfo is one campground returned by a name search.
+type CampgroundInfo struct {
+	ID    string
+	Name  string
+	State string
+	// Sites is how many campsites recreation.gov lists for it, or zero when
+	// the search does not say.
+	Sites int
+}
+
+// searchResponse is the part of recreation.gov's search API response used
+// here. Counts come back as strings or numbers depending on the entity.
+type searchResponse struct {
+	Results []struct {
+		EntityID       string          `json:"entity_id"`
+		EntityType     string          `json:"entity_type"`
+		Name           string          `json:"name"`
+		StateCode      string          `json:"state_code"`
+		Reservable     bool            `json:"reservable"`
+		CampsitesCount json.RawMessage `json:"campsites_count"`
+	} `json:"results"`
+}
+
+// SearchCampgrounds asks recreation.gov's search for reservable campgrounds
+// matching query, in the order the search ranks them.
+func (r RecreationGov) SearchCampgrounds(ctx context.Context, query string) ([]CampgroundInfo, error) {
+	base := r.BaseURL
+	if base == "" {
+		base = recreationGovURL
+	}
+	endpoint := fmt.Sprintf("%s/api/search?q=%s&fq=%s", base, url.QueryEscape(query), url.QueryEscape("entity_type:campground"))
+	data, err := r.Retry.do(ctx, endpoint, func() ([]byte, error) {
+		return r.fetchOnce(ctx, endpoint)
+	})
+	if err != nil {
+		return nil, err
+	}
+	var response searchResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, fmt.Errorf("decoding search results for %q: %w", query, err)
+	}
+	campgrounds := []CampgroundInfo{}
+	for _, result := range response.Results {
+		if result.EntityType != "campground" || !result.Reservable || result.EntityID == "" {
+			continue
+		}
+		campgrounds = append(campgrounds, CampgroundInfo{ID: result.EntityID, Name: result.Name, State: result.StateCode, Sites: jsonInt(result.CampsitesCount)})
+	}
+	return campgrounds, nil
+}
+
+// AmbiguousCampgroundError is returned by ResolveCampground when a name
+// matches more than one campground.
+type AmbiguousCampgroundError struct {
+	Name       string
+	Candidates []CampgroundInfo
+}
+
+func (e *AmbiguousCampgroundError) Error() string {
+	candidates := make([]string, len(e.Candidates))
+	for i, c := range e.Candidates {
+		candidates[i] = fmt.Sprintf("%s (%s, %s)", c.Name, c.ID, c.State)
+	}
+	return fmt.Sprintf("campground name %q is ambiguous; use one of: %s", e.Name, strings.Join(candidates, "; "))
+}
+
+// NoCampgroundError is returned by ResolveCampground when nothing matches.
+type NoCampgroundError struct {
+	Name string
+}
+
+func (e *NoCampgroundError) Error() string {
+	return fmt.Sprintf("no reservable campground matches %q", e.Name)
+}
+
+// ResolveCampground picks the campground a name refers to from the search
+// results: a single result, or the single result whose name is name apart
+// from case.
+func ResolveCampground(name string, results []CampgroundInfo) (CampgroundInfo, error) {
+	if len(results) == 0 {
+		return CampgroundInfo{}, &NoCampgroundError{Name: name}
+	}
+	if len(results) == 1 {
+		return results[0], nil
+	}
+	exact := []CampgroundInfo{}
+	for _, c := range results {
+		if strings.EqualFold(strings.TrimSpace(c.Name), strings.TrimSpace(name)) {
+			exact = append(exact, c)
+		}
+	}
+	if len(exact) == 1 {
+		return exact[0], nil
+	}
+	if len(exact) > 1 {
+		results = exact
+	}
+	return CampgroundInfo{}, &AmbiguousCampgroundError{Name: name, Candidates: results}
+}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// searchServer serves a recorded search response and records the queries
+// asked for.
+func searchServer(t *testing.T, fixture string, queries *[]string) *httptest.Server {
+	body := readFixture(t, fixture)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*queries = append(*queries, r.URL.Query().Get("q")+" "+r.URL.Query().Get("fq"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+// Only reservable campgrounds are kept, in the search's order, with their
+// site counts whether given as strings or numbers.
+func TestSearchCampgrounds(t *testing.T) {
+	queries := []string{}
+	server := searchServer(t, "search-pines.json", &queries)
+
+	got, err := RecreationGov{BaseURL: server.URL}.SearchCampgrounds(context.Background(), "pines")
+	if err != nil {
+		t.Fatalf("SearchCampgrounds: %v", err)
+	}
+	want := []CampgroundInfo{
+		{ID: "232447", Name: "Upper Pines", State: "CA", Sites: 238},
+		{ID: "232450", Name: "Lower Pines", State: "CA", Sites: 60},
+		{ID: "232449", Name: "North Pines", State: "CA", Sites: 81},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SearchCampgrounds() = %+v, want %+v", got, want)
+	}
+	if want := []string{"pines entity_type:campground"}; !reflect.DeepEqual(queries, want) {
+		t.Errorf("queries %q, want %q", queries, want)
+	}
+}
+
+func TestResolveCampground(t *testing.T) {
+	tests := []struct {
+		name    string
+		fixture string
+		query   string
+		want    string
+		wantErr string
+	}{
+		{name: "exact name among several", fixture: "search-upper-pines.json", query: "upper pines ", want: "232447"},
+		{name: "ambiguous", fixture: "search-pines.json", query: "Pines",
+			wantErr: `campground name "Pines" is ambiguous; use one of: Upper Pines (232447, CA); Lower Pines (232450, CA); North Pines (232449, CA)`},
+		{name: "no match", fixture: "search-none.json", query: "Nowhere Flat", wantErr: `no reservable campground matches "Nowhere Flat"`},
+		{name: "no exact name among several", fixture: "search-pines.json", query: "Yosemite Pines",
+			wantErr: `campground name "Yosemite Pines" is ambiguous`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			queries := []string{}
+			server := searchServer(t, test.fixture, &queries)
+			results, err := RecreationGov{BaseURL: server.URL}.SearchCampgrounds(context.Background(), test.query)
+			if err != nil {
+				t.Fatalf("SearchCampgrounds: %v", err)
+			}
+			got, err := ResolveCampground(test.query, results)
+			if test.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("ResolveCampground() = %+v, %v, want error %q", got, err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveCampground: %v", err)
+			}
+			if got.ID != test.want {
+				t.Errorf("ResolveCampground() = %+v, want ID %s", got, test.want)
+			}
+		})
+	}
+}
+
+// The two failures are told apart by type, and an ambiguous name carries
+// its candidates.
+func TestResolveCampgroundErrors(t *testing.T) {
+	var none *NoCampgroundError
+	if _, err := ResolveCampground("Nowhere Flat", nil); !errors.As(err, &none) || none.Name != "Nowhere Flat" {
+		t.Errorf("no results: got %v, want a *NoCampgroundError", err)
+	}
+	candidates := []CampgroundInfo{{ID: "232447", Name: "Upper Pines"}, {ID: "232450", Name: "Lower Pines"}}
+	var ambiguous *AmbiguousCampgroundError
+	if _, err := ResolveCampground("Pines", candidates); !errors.As(err, &ambiguous) || !reflect.DeepEqual(ambiguous.Candidates, candidates) {
+		t.Errorf("two results: got %v, want a *AmbiguousCampgroundError with both", err)
+	}
+	twins := []CampgroundInfo{{ID: "1", Name: "Pines"}, {ID: "2", Name: "pines"}, {ID: "3", Name: "Pines Flat"}}
+	if _, err := ResolveCampground("Pines", twins); !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 {
+		t.Errorf("two exact names: got %v, want only the two exact names as candidates", err)
+	}
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"fmt"
+	"time"
+)
+
+// SeasonClosedError is returned by Scrape when the campground is not
+// operating on any of the requested nights: recreation.gov marks every
+// night of the stay "Closed" at each campsite. Season is the campground's
+// operating season, where known.
+type SeasonClosedError struct {
+	CampgroundID string
+	Arrival      CivilDate
+	Departure    CivilDate
+	Season       Season
+}
+
+func (e *SeasonClosedError) Error() string {
+	return fmt.Sprintf("campground %s is closed for the season between %s and %s",
+		e.CampgroundID, e.Arrival, e.Departure)
+}
+
+// seasonClosed reports whether a decoded month payload has the closed-season
+// shape for the given nights: at least one campsite, each of them Closed on
+// every night. A payload with no campsites says nothing about the season, as
+// a wrong campground ID or a truncated response looks the same, and a stay
+// of no nights cannot fall in one.
+func seasonClosed(campground Campground, dates []CivilDate) bool {
+	if len(campground.Campsites) == 0 || len(dates) == 0 {
+		return false
+	}
+	for _, site := range campground.Campsites {
+		for _, date := range dates {
+			if ParseStatus(site.Availabilities[date]) != StatusClosed {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Season is the part of every year a campground operates, from the night of
+// Start through the night of End. Only the month and day of each are used,
+// and an End earlier in the year than Start runs over the new year. The
+// zero Season is unknown.
+type Season struct {
+	Start CivilDate
+	End   CivilDate
+}
+
+// IsZero reports whether the season is unknown.
+func (s Season) IsZero() bool {
+	return s.Start.IsZero() || s.End.IsZero()
+}
+
+// Contains reports whether night falls in the season.
+func (s Season) Contains(night CivilDate) bool {
+	if s.IsZero() {
+		return false
+	}
+	day := monthDay(night.Month, night.Day)
+	start, end := monthDay(s.Start.Month, s.Start.Day), monthDay(s.End.Month, s.End.Day)
+	if start <= end {
+		return start <= day && day <= end
+	}
+	return day >= start || day <= end
+}
+
+// Excludes reports whether the season is known and none of the nights from
+// arrival up to departure fall in it.
+func (s Season) Excludes(arrival CivilDate, departure CivilDate) bool {
+	nights := Nights(arrival, departure)
+	if s.IsZero() || len(nights) == 0 {
+		return false
+	}
+	for _, night := range nights {
+		if s.Contains(night) {
+			return false
+		}
+	}
+	return true
+}
+
+// Shift returns the stay nearest to arrival up to departure, moved by whole
+// weeks so it keeps its weekdays, whose every night is in the season and
+// which arrives no earlier than notBefore. Later stays win ties. It
+// reports false when the season is unknown or no such stay is within a
+// year.
+func (s Season) Shift(arrival CivilDate, departure CivilDate, notBefore CivilDate) (CivilDate, CivilDate, bool) {
+	if s.IsZero() || !departure.After(arrival) {
+		return CivilDate{}, CivilDate{}, false
+	}
+	fits := func(weeks int) bool {
+		a := arrival.AddDays(7 * weeks)
+		if a.Before(notBefore) {
+			return false
+		}
+		for _, night := range Nights(a, departure.AddDays(7*weeks)) {
+			if !s.Contains(night) {
+				return false
+			}
+		}
+		return true
+	}
+	for weeks := 1; weeks <= 52; weeks++ {
+		for _, w := range []int{weeks, -weeks} {
+			if fits(w) {
+				return arrival.AddDays(7 * w), departure.AddDays(7 * w), true
+			}
+		}
+	}
+	return CivilDate{}, CivilDate{}, false
+}
+
+// String writes the season such as "May 15–Sep 30".
+func (s Season) String() string {
+	if s.IsZero() {
+		return ""
+	}
+	return fmt.Sprintf("%s %d–%s %d", s.Start.Month.String()[:3], s.Start.Day, s.End.Month.String()[:3], s.End.Day)
+}
+
+// monthDay orders a day of the year without regard to leap years.
+func monthDay(m time.Month, d int) int {
+	return int(m)*100 + d
+}
@@ -0,0 +1,73 @@
+package core
+
+import (
+	"testing"
+)
+
+func TestSeasonExcludes(t *testing.T) {
+	summer := Season{Start: CivilDate{2027, 5, 15}, End: CivilDate{2027, 9, 30}}
+	winter := Season{Start: CivilDate{2026, 11, 1}, End: CivilDate{2027, 3, 31}}
+	tests := []struct {
+		name               string
+		season             Season
+		arrival, departure string
+		want               bool
+	}{
+		{"before the season", summer, "2027-04-10", "2027-04-12", true},
+		{"after the season", summer, "2027-10-01", "2027-10-03", true},
+		{"in another year's season", summer, "2031-07-14", "2031-07-16", false},
+		{"last night in season", summer, "2027-09-30", "2027-10-02", false},
+		{"first night in season", summer, "2027-05-14", "2027-05-16", false},
+		{"over the new year", winter, "2027-12-30", "2028-01-02", false},
+		{"outside a winter season", winter, "2027-07-14", "2027-07-16", true},
+		{"unknown season", Season{}, "2027-04-10", "2027-04-12", false},
+		{"no nights", summer, "2027-04-10", "2027-04-10", false},
+	}
+	for _, test := range tests {
+		arrival, departure := stayDay(t, test.arrival), stayDay(t, test.departure)
+		if got := test.season.Excludes(arrival, departure); got != test.want {
+			t.Errorf("%s: Excludes = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestSeasonShift(t *testing.T) {
+	summer := Season{Start: CivilDate{2027, 5, 15}, End: CivilDate{2027, 9, 30}}
+	tests := []struct {
+		name               string
+		arrival, departure string
+		notBefore          string
+		want               string
+	}{
+		{"forward into the season", "2027-04-23", "2027-04-25", "2027-03-01", "2027-05-21"},
+		{"back into the season", "2027-10-08", "2027-10-10", "2027-03-01", "2027-09-24"},
+		{"back into the past", "2027-10-08", "2027-10-10", "2027-10-01", "2028-05-19"},
+		{"stays keep their weekdays", "2027-10-01", "2027-10-03", "2027-03-01", "2027-09-24"},
+	}
+	for _, test := range tests {
+		arrival, departure := stayDay(t, test.arrival), stayDay(t, test.departure)
+		a, d, ok := summer.Shift(arrival, departure, stayDay(t, test.notBefore))
+		if !ok || a.String() != test.want || a.DaysUntil(d) != arrival.DaysUntil(departure) || a.Weekday() != arrival.Weekday() {
+			t.Errorf("%s: Shift = %v, %v, %v, want arrival %s", test.name, a, d, ok, test.want)
+		}
+	}
+	if _, _, ok := (Season{}).Shift(stayDay(t, "2027-04-23"), stayDay(t, "2027-04-25"), CivilDate{}); ok {
+		t.Error("an unknown season shifted a stay")
+	}
+	short := Season{Start: CivilDate{2027, 7, 4}, End: CivilDate{2027, 7, 5}}
+	if _, _, ok := short.Shift(stayDay(t, "2027-04-23"), stayDay(t, "2027-04-30"), CivilDate{}); ok {
+		t.Error("a week shifted into a two-night season")
+	}
+}
+
+func TestSeasonString(t *testing.T) {
+	s := Season{Start: CivilDate{2027, 5, 15}, End: CivilDate{2027, 9, 30}}
+	if got := s.String(); got != "May 15–Sep 30" {
+		t.Errorf("String = %q", got)
+	}
+}
+
+func stayDay(t *testing.T, s string) CivilDate {
+	t.Helper()
+	return mustCivilDate(t, s)
+}
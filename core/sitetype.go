@@ -0,0 +1,116 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// SiteFilter restricts matching to campsites by their CampsiteType. A type
+// matches a filter entry when every word of the entry appears in it, ignoring
+// case and punctuation, so "tent" matches "TENT ONLY NONELECTRIC" and
+// "standard" matches both "STANDARD NONELECTRIC" and "STANDARD ELECTRIC".
+// The zero value matches every site.
+type SiteFilter struct {
+	// Types, when set, keeps only sites matching at least one entry.
+	Types []string
+	// Exclude drops sites matching any entry, after Types is applied.
+	Exclude []string
+	// Sites, when set, keeps only the campsites with these IDs, before
+	// either of the above.
+	Sites []string
+}
+
+// IsZero reports whether f keeps every site.
+func (f SiteFilter) IsZero() bool {
+	return len(f.Types) == 0 && len(f.Exclude) == 0 && len(f.Sites) == 0
+}
+
+// Keeps reports whether the campsite with id and campsiteType passes f.
+func (f SiteFilter) Keeps(id string, campsiteType string) bool {
+	if len(f.Sites) > 0 && !containsString(f.Sites, id) {
+		return false
+	}
+	return f.Matches(campsiteType)
+}
+
+func containsString(values []string, s string) bool {
+	for _, v := range values {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Matches reports whether a campsite of the given type passes f's type
+// filters. Sites is not consulted; see Keeps.
+func (f SiteFilter) Matches(campsiteType string) bool {
+	words := typeWords(campsiteType)
+	if len(f.Types) > 0 && !matchesAny(words, f.Types) {
+		return false
+	}
+	return !matchesAny(words, f.Exclude)
+}
+
+func matchesAny(words map[string]bool, entries []string) bool {
+	for _, entry := range entries {
+		want := typeWords(entry)
+		if len(want) == 0 {
+			continue
+		}
+		all := true
+		for w := range want {
+			if !words[w] {
+				all = false
+				break
+			}
+		}
+		if all {
+			return true
+		}
+	}
+	return false
+}
+
+// typeWords splits a campsite type into upper-case words.
+func typeWords(s string) map[string]bool {
+	words := map[string]bool{}
+	for _, w := range strings.FieldsFunc(strings.ToUpper(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		words[w] = true
+	}
+	return words
+}
+
+// Apply returns campground without the campsites f rejects.
+func (f SiteFilter) Apply(campground Campground) Campground {
+	if f.IsZero() {
+		return campground
+	}
+	kept := Campground{Campsites: map[string]Campsite{}, Conflicts: campground.Conflicts}
+	for id, site := range campground.Campsites {
+		if f.Keeps(id, site.CampsiteType) {
+			kept.Campsites[id] = site
+		}
+	}
+	return kept
+}
+
+// FilteredProvider is a Provider whose months only hold the campsites that
+// pass Filter.
+type FilteredProvider struct {
+	Provider Provider
+	Filter   SiteFilter
+}
+
+// FetchMonth implements Provider.
+func (p FilteredProvider) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	campground, err := p.Provider.FetchMonth(ctx, campgroundID, month)
+	if err != nil {
+		return campground, err
+	}
+	return p.Filter.Apply(campground), nil
+}
@@ -0,0 +1,94 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// SnapshotDir is a Provider that reads stored month payloads instead of
+// calling recreation.gov, for replaying recorded traffic. Each payload is the
+// raw API response saved as <Dir>/<campground ID>/<YYYY-MM>.json. A missing
+// file is returned as an error, like a failed request.
+type SnapshotDir struct {
+	Dir string
+}
+
+// FetchMonth decodes the stored payload for the campground and month.
+func (s SnapshotDir) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	if err := ctx.Err(); err != nil {
+		return Campground{}, err
+	}
+	data, err := ioutil.ReadFile(snapshotPath(s.Dir, campgroundID, month))
+	if err != nil {
+		return Campground{}, err
+	}
+	return decodeCampground(data)
+}
+
+// snapshotPath is where a campground month is stored under dir.
+func snapshotPath(dir string, campgroundID string, month time.Time) string {
+	return filepath.Join(dir, campgroundID, month.Format("2006-01")+".json")
+}
+
+// RecordingTransport is an http.RoundTripper that saves every successful
+// month availability response it carries into Dir, in the layout SnapshotDir
+// reads, so live traffic can be replayed later. Other requests pass through
+// untouched. A response that cannot be saved fails the request, so a
+// recording is never silently incomplete.
+type RecordingTransport struct {
+	Dir string
+	// Transport makes the requests. http.DefaultTransport is used when nil.
+	Transport http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *RecordingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	transport := t.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	response, err := transport.RoundTrip(request)
+	if err != nil || response.StatusCode != http.StatusOK {
+		return response, err
+	}
+	campgroundID, month, ok := monthRequest(request.URL)
+	if !ok {
+		return response, nil
+	}
+	data, err := ioutil.ReadAll(response.Body)
+	response.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	response.Body = ioutil.NopCloser(bytes.NewReader(data))
+	path := snapshotPath(t.Dir, campgroundID, month)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("recording %s: %v", request.URL, err)
+	}
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		return nil, fmt.Errorf("recording %s: %v", request.URL, err)
+	}
+	return response, nil
+}
+
+// monthRequest picks the campground and month out of a FetchMonthRaw URL.
+func monthRequest(u *url.URL) (string, time.Time, bool) {
+	const prefix = "/api/camps/availability/campground/"
+	if !strings.HasPrefix(u.Path, prefix) || !strings.HasSuffix(u.Path, "/month") {
+		return "", time.Time{}, false
+	}
+	campgroundID := strings.TrimSuffix(strings.TrimPrefix(u.Path, prefix), "/month")
+	month, err := time.Parse("2006-01-02", strings.SplitN(u.Query().Get("start_date"), "T", 2)[0])
+	if err != nil || campgroundID == "" || strings.Contains(campgroundID, "/") {
+		return "", time.Time{}, false
+	}
+	return campgroundID, month, true
+}
@@ -0,0 +1,130 @@
+package core
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// AvailabilityStatus is a night's status as recreation.gov reports it.
+// Campsite.Availabilities keeps the raw strings; ParseStatus reads them.
+type AvailabilityStatus string
+
+// The statuses recreation.gov is known to report. StatusOpen marks
+// first-come, first-served nights that can be walked up to but not booked,
+// and StatusNotYetReleased nights whose booking window has not opened.
+const (
+	StatusAvailable               AvailabilityStatus = "Available"
+	StatusReserved                AvailabilityStatus = "Reserved"
+	StatusNotReservable           AvailabilityStatus = "Not Reservable"
+	StatusNotReservableManagement AvailabilityStatus = "Not Reservable Management"
+	StatusNotAvailable            AvailabilityStatus = "Not Available"
+	StatusOpen                    AvailabilityStatus = "Open"
+	StatusNotYetReleased          AvailabilityStatus = "NYR"
+	StatusLottery                 AvailabilityStatus = "Lottery"
+	StatusClosed                  AvailabilityStatus = "Closed"
+	// StatusUnknown stands for any other value.
+	StatusUnknown AvailabilityStatus = "Unknown"
+)
+
+var knownStatuses = map[string]AvailabilityStatus{}
+
+func init() {
+	for _, s := range []AvailabilityStatus{
+		StatusAvailable, StatusReserved, StatusNotReservable, StatusNotReservableManagement,
+		StatusNotAvailable, StatusOpen, StatusNotYetReleased, StatusLottery, StatusClosed,
+	} {
+		knownStatuses[strings.ToLower(string(s))] = s
+	}
+	knownStatuses["walk-up"] = StatusOpen
+	knownStatuses["walk up"] = StatusOpen
+}
+
+// ParseStatus returns the status raw names, ignoring case and surrounding
+// space, or StatusUnknown. Walk-up nights, however spelled, are StatusOpen.
+func ParseStatus(raw string) AvailabilityStatus {
+	if s, ok := knownStatuses[strings.ToLower(strings.TrimSpace(raw))]; ok {
+		return s
+	}
+	return StatusUnknown
+}
+
+// isAvailable reports whether site can be booked on night.
+func isAvailable(site Campsite, night CivilDate) bool {
+	return ParseStatus(site.Availabilities[night]) == StatusAvailable
+}
+
+// StatusSet collects raw status values, safe for concurrent use.
+type StatusSet struct {
+	mu     sync.Mutex
+	values map[string]bool
+}
+
+// Add records raw.
+func (s *StatusSet) Add(raw string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.values == nil {
+		s.values = map[string]bool{}
+	}
+	s.values[raw] = true
+}
+
+// Values returns what was recorded, sorted.
+func (s *StatusSet) Values() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	values := make([]string, 0, len(s.values))
+	for raw := range s.values {
+		values = append(values, raw)
+	}
+	sort.Strings(values)
+	return values
+}
+
+// StatusProvider is a Provider applying a status policy to the months it
+// fetches: each known status gets its canonical spelling, Open nights count
+// as Available when WalkUp is set, and any status ParseStatus does not know
+// is recorded in Unknown, when set, and left as is. The months are copied,
+// so a shared cache is not changed.
+type StatusProvider struct {
+	Provider Provider
+	WalkUp   bool
+	Unknown  *StatusSet
+	// Sites, when set, records the ID of every campsite fetched.
+	Sites *StatusSet
+}
+
+// FetchMonth implements Provider.
+func (p StatusProvider) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (Campground, error) {
+	campground, err := p.Provider.FetchMonth(ctx, campgroundID, month)
+	if err != nil || campground.Campsites == nil {
+		return campground, err
+	}
+	normalised := Campground{Campsites: make(map[string]Campsite, len(campground.Campsites)), Conflicts: campground.Conflicts}
+	for id, site := range campground.Campsites {
+		if p.Sites != nil {
+			p.Sites.Add(id)
+		}
+		availabilities := make(map[CivilDate]string, len(site.Availabilities))
+		for night, raw := range site.Availabilities {
+			status := ParseStatus(raw)
+			switch {
+			case status == StatusUnknown:
+				if p.Unknown != nil {
+					p.Unknown.Add(raw)
+				}
+				availabilities[night] = raw
+			case status == StatusOpen && p.WalkUp:
+				availabilities[night] = string(StatusAvailable)
+			default:
+				availabilities[night] = string(status)
+			}
+		}
+		site.Availabilities = availabilities
+		normalised.Campsites[id] = site
+	}
+	return normalised, nil
+}
@@ -0,0 +1,115 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+// statusNights are the raw statuses in statuses.json by night, and what
+// each parses to.
+var statusNights = []struct {
+	night string
+	raw   string
+	want  AvailabilityStatus
+}{
+	{"2027-07-01", "Available", StatusAvailable},
+	{"2027-07-02", "Reserved", StatusReserved},
+	{"2027-07-03", "Not Reservable", StatusNotReservable},
+	{"2027-07-04", "Not Reservable Management", StatusNotReservableManagement},
+	{"2027-07-05", "Not Available", StatusNotAvailable},
+	{"2027-07-06", "Open", StatusOpen},
+	{"2027-07-07", "Walk-up", StatusOpen},
+	{"2027-07-08", "NYR", StatusNotYetReleased},
+	{"2027-07-09", "Lottery", StatusLottery},
+	{"2027-07-10", "Closed", StatusClosed},
+	{"2027-07-11", "Blocked Maintenance", StatusUnknown},
+	{"2027-07-12", " reserved ", StatusReserved},
+}
+
+func night(t *testing.T, date string) CivilDate {
+	t.Helper()
+	return mustCivilDate(t, date)
+}
+
+func TestParseStatus(t *testing.T) {
+	campground, err := decodeCampground(readFixture(t, "statuses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	site := campground.Campsites["2001"]
+	if len(site.Availabilities) != len(statusNights) {
+		t.Fatalf("fixture has %d nights, want %d", len(site.Availabilities), len(statusNights))
+	}
+	for _, n := range statusNights {
+		raw := site.Availabilities[night(t, n.night)]
+		if raw != n.raw {
+			t.Errorf("%s: fixture has %q, want %q", n.night, raw, n.raw)
+		}
+		if got := ParseStatus(raw); got != n.want {
+			t.Errorf("ParseStatus(%q) = %q, want %q", raw, got, n.want)
+		}
+	}
+	if got := ParseStatus("walk up"); got != StatusOpen {
+		t.Errorf(`ParseStatus("walk up") = %q, want Open`, got)
+	}
+}
+
+// StatusProvider spells known statuses canonically, counts walk-up nights
+// as available only when asked to, keeps and records unknown statuses, and
+// leaves the month it was given alone.
+func TestStatusProvider(t *testing.T) {
+	campground, err := decodeCampground(readFixture(t, "statuses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, walkUp := range []bool{false, true} {
+		unknown, sites := &StatusSet{}, &StatusSet{}
+		p := StatusProvider{Provider: staticProvider{campground}, WalkUp: walkUp, Unknown: unknown, Sites: sites}
+		got, err := p.FetchMonth(context.Background(), "232447", night(t, "2027-07-01").Time())
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, n := range statusNights {
+			want := string(n.want)
+			switch {
+			case n.want == StatusUnknown:
+				want = n.raw
+			case n.want == StatusOpen && walkUp:
+				want = string(StatusAvailable)
+			}
+			if status := got.Campsites["2001"].Availabilities[night(t, n.night)]; status != want {
+				t.Errorf("walk-up %t: %s (%q) became %q, want %q", walkUp, n.night, n.raw, status, want)
+			}
+		}
+		if want := []string{"Blocked Maintenance"}; !reflect.DeepEqual(unknown.Values(), want) {
+			t.Errorf("walk-up %t: unknown statuses %q, want %q", walkUp, unknown.Values(), want)
+		}
+		if want := []string{"2001"}; !reflect.DeepEqual(sites.Values(), want) {
+			t.Errorf("walk-up %t: sites %q, want %q", walkUp, sites.Values(), want)
+		}
+	}
+	if raw := campground.Campsites["2001"].Availabilities[night(t, "2027-07-12")]; raw != " reserved " {
+		t.Errorf("the fetched month was changed to %q", raw)
+	}
+}
+
+// The breakdown counts each night under its typed status.
+func TestCheckAvailabilityStatuses(t *testing.T) {
+	campground, err := decodeCampground(readFixture(t, "statuses.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err := CheckAvailability(context.Background(), campground, night(t, "2027-07-01"), night(t, "2027-07-14"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := SiteSummary{CampsiteID: "2001", Site: "B001", CampsiteType: "STANDARD NONELECTRIC",
+		Available: 1, Reserved: 2, NotReservable: 2, Open: 2, NotYetReleased: 1, Other: 4, Missing: 1}
+	if len(result.Summaries) != 1 || result.Summaries[0] != want {
+		t.Errorf("summaries %+v, want %+v", result.Summaries, want)
+	}
+	if want := night(t, "2027-07-08"); result.LastUnreleased != want {
+		t.Errorf("last unreleased %v, want %v", result.LastUnreleased, want)
+	}
+}
@@ -0,0 +1,50 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// StatusCounts tallies recreation.gov responses by HTTP status code. It is
+// safe for concurrent use.
+type StatusCounts struct {
+	mu     sync.Mutex
+	counts map[int]int
+}
+
+// Add records one response with status code.
+func (s *StatusCounts) Add(code int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.counts == nil {
+		s.counts = map[int]int{}
+	}
+	s.counts[code]++
+}
+
+// Counts returns a copy of the tally.
+func (s *StatusCounts) Counts() map[int]int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	counts := make(map[int]int, len(s.counts))
+	for code, n := range s.counts {
+		counts[code] = n
+	}
+	return counts
+}
+
+type statusCountsKey struct{}
+
+// WithStatusCounts returns a context under which every recreation.gov
+// response is added to counts, so one run's responses are counted apart
+// from others sharing the client.
+func WithStatusCounts(ctx context.Context, counts *StatusCounts) context.Context {
+	return context.WithValue(ctx, statusCountsKey{}, counts)
+}
+
+// countStatus adds code to the counts ctx carries, if any.
+func countStatus(ctx context.Context, code int) {
+	if counts, ok := ctx.Value(statusCountsKey{}).(*StatusCounts); ok {
+		counts.Add(code)
+	}
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"context"
+	"sort"
+)
+
+// Stay is the longest run of available nights at a site from a fixed arrival.
+type Stay struct {
+	Site   string
+	Nights int
+}
+
+// ScrapeStays fetches availability for up to maxNights from arrival and
+// returns the sites that can be booked for at least minNights, longest stay
+// first.
+func ScrapeStays(ctx context.Context, p Provider, campgroundID string, arrival CivilDate, minNights int, maxNights int) ([]Stay, error) {
+	campground, err := FetchRange(ctx, p, campgroundID, arrival, arrival.AddDays(maxNights))
+	if err != nil {
+		return nil, err
+	}
+	return LongestStays(ctx, campground, arrival, minNights, maxNights)
+}
+
+// LongestStays returns, for each campsite available for at least minNights
+// consecutive nights starting at arrival, how many nights it can be booked
+// for, capped at maxNights. Stays are sorted longest first, then by site ID.
+// ctx is checked before each campsite as in AvailableSites.
+func LongestStays(ctx context.Context, campground Campground, arrival CivilDate, minNights int, maxNights int) ([]Stay, error) {
+	if minNights < 1 {
+		minNights = 1
+	}
+	dates := Nights(arrival, arrival.AddDays(maxNights))
+
+	stays := []Stay{}
+	checked := 0
+	for siteID, site := range campground.Campsites {
+		if err := ctx.Err(); err != nil {
+			sortStays(stays)
+			return stays, &PartialResultError{Checked: checked, Total: len(campground.Campsites), Err: err}
+		}
+		checked++
+		nights := 0
+		for _, date := range dates {
+			if !isAvailable(site, date) {
+				break
+			}
+			nights++
+		}
+		if nights >= minNights {
+			stays = append(stays, Stay{Site: siteID, Nights: nights})
+		}
+	}
+	sortStays(stays)
+	return stays, nil
+}
+
+func sortStays(stays []Stay) {
+	sort.Slice(stays, func(i, j int) bool {
+		if stays[i].Nights != stays[j].Nights {
+			return stays[i].Nights > stays[j].Nights
+		}
+		return NaturalLess(stays[i].Site, stays[j].Site)
+	})
+}
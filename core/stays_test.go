@@ -0,0 +1,78 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// staysCampground has a site for each entry of open, with the nights given,
+// such as "07-14", available in 2027 and every other night of June to
+// August reserved.
+func staysCampground(open map[string][]string) Campground {
+	campground := Campground{Campsites: map[string]Campsite{}}
+	for id, nights := range open {
+		campground.Campsites[id] = windowCampground(nights...).Campsites["1001"]
+	}
+	return campground
+}
+
+func TestLongestStays(t *testing.T) {
+	arrival := CivilDate{2027, time.July, 14}
+	tests := []struct {
+		name    string
+		open    map[string][]string
+		arrival CivilDate
+		min     int
+		max     int
+		want    []Stay
+	}{
+		{name: "booked on arrival", open: map[string][]string{"1001": {"07-15", "07-16"}}, min: 1, max: 4, want: []Stay{}},
+		{name: "exactly the minimum", open: map[string][]string{"1001": {"07-14", "07-15"}}, min: 2, max: 4,
+			want: []Stay{{Site: "1001", Nights: 2}}},
+		{name: "one short of the minimum", open: map[string][]string{"1001": {"07-14", "07-16"}}, min: 2, max: 4, want: []Stay{}},
+		{name: "open past the maximum", open: map[string][]string{"1001": {"07-14", "07-15", "07-16", "07-17", "07-18", "07-19"}}, min: 2, max: 4,
+			want: []Stay{{Site: "1001", Nights: 4}}},
+		{name: "no minimum", open: map[string][]string{"1001": {"07-14"}}, max: 4, want: []Stay{{Site: "1001", Nights: 1}}},
+		{name: "across a month end", open: map[string][]string{"1001": {"07-30", "07-31", "08-01", "08-02"}},
+			arrival: CivilDate{2027, time.July, 30}, min: 3, max: 7, want: []Stay{{Site: "1001", Nights: 4}}},
+		{name: "longest first, then by site", open: map[string][]string{
+			"10": {"07-14", "07-15"},
+			"9":  {"07-14", "07-15"},
+			"11": {"07-14", "07-15", "07-16"},
+			"12": {"07-15", "07-16", "07-17"},
+		}, min: 1, max: 4, want: []Stay{{Site: "11", Nights: 3}, {Site: "9", Nights: 2}, {Site: "10", Nights: 2}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			at := test.arrival
+			if at.IsZero() {
+				at = arrival
+			}
+			stays, err := LongestStays(context.Background(), staysCampground(test.open), at, test.min, test.max)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(stays, test.want) {
+				t.Errorf("LongestStays() = %v, want %v", stays, test.want)
+			}
+		})
+	}
+}
+
+// A stay that may run into the next month fetches it too.
+func TestScrapeStaysAcrossMonths(t *testing.T) {
+	fetched := []time.Time{}
+	p := monthProvider{open: map[string][]string{"2027-07": {"2027-07-30", "2027-07-31"}, "2027-08": {"2027-08-01"}}, fetched: &fetched}
+	stays, err := ScrapeStays(context.Background(), p, "232447", CivilDate{2027, time.July, 30}, 2, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Stay{{Site: "1001", Nights: 3}}; !reflect.DeepEqual(stays, want) {
+		t.Errorf("ScrapeStays() = %v, want %v", stays, want)
+	}
+	if got := monthLabels(fetched); got != "2027-07,2027-08" {
+		t.Errorf("fetched %s, want 2027-07,2027-08", got)
+	}
+}
@@ -0,0 +1,134 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TourSlot is one departure of a ticketed tour, such as a cave tour or a
+// ranger-led program, and how many of its tickets are left. Time is the
+// facility's local start time, such as "10:00".
+type TourSlot struct {
+	TourID    string
+	Date      CivilDate
+	Time      string
+	Remaining int
+	Total     int
+}
+
+// At is when the slot starts, in loc, the facility's zone.
+func (s TourSlot) At(loc *time.Location) time.Time {
+	day := s.Date.Time()
+	clock, _ := time.Parse("15:04", s.Time)
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, loc)
+}
+
+func (s TourSlot) String() string {
+	return fmt.Sprintf("tour %s has %d of %d tickets at %s on %s", s.TourID, s.Remaining, s.Total, s.Time, s.Date.Time().Format("Jan 2"))
+}
+
+// tourResponse is one slot in the ticket availability API's day payload.
+// Counts are kept per ticket category; "ANY" is the slot's shared pool.
+type tourResponse struct {
+	TourID           json.Number    `json:"tour_id"`
+	TourDate         string         `json:"tour_date"`
+	TourTime         string         `json:"tour_time"`
+	InventoryCount   map[string]int `json:"inventory_count"`
+	ReservationCount map[string]int `json:"reservation_count"`
+	Status           string         `json:"status"`
+}
+
+// decodeTours decodes a ticket availability day payload. A slot's tickets
+// left are its "ANY" inventory less its reservations, none when the slot
+// is not on sale.
+func decodeTours(data []byte) ([]TourSlot, error) {
+	if err := checkStructure(data); err != nil {
+		return nil, err
+	}
+	var response []tourResponse
+	if err := json.Unmarshal(data, &response); err != nil {
+		return nil, err
+	}
+	slots := make([]TourSlot, 0, len(response))
+	for _, r := range response {
+		date, err := ParseCivilDate(r.TourDate)
+		if err != nil {
+			return nil, fmt.Errorf("tour %s: %v", r.TourID, err)
+		}
+		start, err := time.Parse("15:04:05", r.TourTime)
+		if err != nil {
+			return nil, fmt.Errorf("tour %s time %q: %v", r.TourID, r.TourTime, err)
+		}
+		total := r.InventoryCount["ANY"]
+		remaining := total - r.ReservationCount["ANY"]
+		if r.Status != "" && r.Status != "AVAILABLE" || remaining < 0 {
+			remaining = 0
+		}
+		slots = append(slots, TourSlot{TourID: r.TourID.String(), Date: date, Time: start.Format("15:04"), Remaining: remaining, Total: total})
+	}
+	return slots, nil
+}
+
+// TourProvider fetches one day of a ticketed facility's tour slots.
+type TourProvider interface {
+	FetchTourDay(ctx context.Context, facilityID string, day CivilDate) ([]TourSlot, error)
+}
+
+// FetchTourDay implements TourProvider with the same headers, retries and
+// pacing as FetchPermitMonth.
+func (r RecreationGov) FetchTourDay(ctx context.Context, facilityID string, day CivilDate) ([]TourSlot, error) {
+	base := r.BaseURL
+	if base == "" {
+		base = recreationGovURL
+	}
+	url := fmt.Sprintf("%s/api/ticket/availability/facility/%s?date=%s", base, facilityID, day)
+	data, err := r.Retry.do(ctx, url, func() ([]byte, error) {
+		return r.fetchOnce(ctx, url)
+	})
+	if err != nil {
+		return nil, err
+	}
+	slots, err := decodeTours(data)
+	if err != nil {
+		return nil, fmt.Errorf("decoding tours of %s on %s: %w", facilityID, day, err)
+	}
+	return slots, nil
+}
+
+// ScrapeTours fetches every day from start up to, but not including, end,
+// and returns the slots on those days with at least partySize tickets
+// left, ordered by date, time and then tour. partySize below 1 counts as
+// 1.
+func ScrapeTours(ctx context.Context, p TourProvider, facilityID string, start CivilDate, end CivilDate, partySize int) ([]TourSlot, error) {
+	if partySize < 1 {
+		partySize = 1
+	}
+	slots := []TourSlot{}
+	for _, date := range Nights(start, end) {
+		if err := ctx.Err(); err != nil {
+			return slots, err
+		}
+		daySlots, err := p.FetchTourDay(ctx, facilityID, date)
+		if err != nil {
+			return slots, err
+		}
+		for _, slot := range daySlots {
+			if slot.Date == date && slot.Remaining >= partySize {
+				slots = append(slots, slot)
+			}
+		}
+	}
+	sort.Slice(slots, func(i, j int) bool {
+		if slots[i].Date != slots[j].Date {
+			return slots[i].Date.Before(slots[j].Date)
+		}
+		if slots[i].Time != slots[j].Time {
+			return slots[i].Time < slots[j].Time
+		}
+		return NaturalLess(slots[i].TourID, slots[j].TourID)
+	})
+	return slots, nil
+}
@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// Sold-out and cancelled slots have no tickets left; the others have their
+// inventory less their reservations.
+func TestDecodeTours(t *testing.T) {
+	slots, err := decodeTours(readFixture(t, "tours.json"))
+	if err != nil {
+		t.Fatalf("decodeTours: %v", err)
+	}
+	day := mustCivilDate(t, "2027-07-14")
+	want := []TourSlot{
+		{TourID: "2122", Date: day, Time: "13:30", Remaining: 4, Total: 20},
+		{TourID: "2121", Date: day, Time: "10:00", Remaining: 0, Total: 20},
+		{TourID: "2123", Date: day, Time: "16:00", Remaining: 11, Total: 12},
+		{TourID: "2124", Date: day, Time: "18:00", Remaining: 0, Total: 12},
+	}
+	if !reflect.DeepEqual(slots, want) {
+		t.Errorf("decodeTours() = %+v, want %+v", slots, want)
+	}
+}
+
+func TestDecodeToursMalformed(t *testing.T) {
+	for name, data := range map[string]string{
+		"not a list": `{"tour_id": 1}`,
+		"bad date":   `[{"tour_id": 1, "tour_date": "July 14", "tour_time": "10:00:00"}]`,
+		"bad time":   `[{"tour_id": 1, "tour_date": "2027-07-14", "tour_time": "10am"}]`,
+	} {
+		if _, err := decodeTours([]byte(data)); err == nil {
+			t.Errorf("%s: decodeTours succeeded", name)
+		}
+	}
+}
+
+// fixtureTours serves tours.json's slots on every day asked for, and
+// records the days fetched.
+type fixtureTours struct {
+	t       *testing.T
+	fetched *[]string
+}
+
+func (p fixtureTours) FetchTourDay(ctx context.Context, facilityID string, day CivilDate) ([]TourSlot, error) {
+	*p.fetched = append(*p.fetched, day.String())
+	slots, err := decodeTours(readFixture(p.t, "tours.json"))
+	for i := range slots {
+		slots[i].Date = day
+	}
+	return slots, err
+}
+
+func TestScrapeTours(t *testing.T) {
+	tests := []struct {
+		name      string
+		partySize int
+		want      []string
+	}{
+		{name: "one", partySize: 0, want: []string{"2027-07-14 13:30 2122", "2027-07-14 16:00 2123", "2027-07-15 13:30 2122", "2027-07-15 16:00 2123"}},
+		{name: "exactly the tickets left", partySize: 4, want: []string{"2027-07-14 13:30 2122", "2027-07-14 16:00 2123", "2027-07-15 13:30 2122", "2027-07-15 16:00 2123"}},
+		{name: "one more than left", partySize: 5, want: []string{"2027-07-14 16:00 2123", "2027-07-15 16:00 2123"}},
+		{name: "too many", partySize: 12, want: []string{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fetched := []string{}
+			slots, err := ScrapeTours(context.Background(), fixtureTours{t, &fetched}, "234636",
+				CivilDate{2027, time.July, 14}, CivilDate{2027, time.July, 16}, test.partySize)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, slot := range slots {
+				got = append(got, slot.Date.String()+" "+slot.Time+" "+slot.TourID)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("ScrapeTours() = %v, want %v", got, test.want)
+			}
+			if want := []string{"2027-07-14", "2027-07-15"}; !reflect.DeepEqual(fetched, want) {
+				t.Errorf("fetched %v, want %v", fetched, want)
+			}
+		})
+	}
+}
+
+func TestTourSlotAt(t *testing.T) {
+	carlsbad, err := time.LoadLocation("America/Denver")
+	if err != nil {
+		t.Skip(err)
+	}
+	slot := TourSlot{TourID: "2122", Date: mustCivilDate(t, "2027-07-14"), Time: "13:30"}
+	if got, want := slot.At(carlsbad), time.Date(2027, 7, 14, 13, 30, 0, 0, carlsbad); !got.Equal(want) {
+		t.Errorf("At() = %v, want %v", got, want)
+	}
+}
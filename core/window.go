@@ -0,0 +1,155 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Run is a stretch of consecutive available nights at one campsite, from
+// Start up to but not including End, the departure day.
+type Run struct {
+	Site  string
+	Start CivilDate
+	End   CivilDate
+}
+
+// WindowSearch describes a flexible-date search: any Nights consecutive
+// nights from WindowStart up to WindowEnd, the last possible departure.
+type WindowSearch struct {
+	WindowStart CivilDate
+	WindowEnd   CivilDate
+	Nights      int
+	// RequiredWeekdays must all be among the nights of a run, e.g. Friday
+	// and Saturday for "any week, but including the weekend".
+	RequiredWeekdays []time.Weekday
+	// Prior, StopAtFirst and Horizon plan the months fetched; see
+	// ScrapeRunsPlanned.
+	Prior       MonthPrior
+	StopAtFirst bool
+	Horizon     time.Time
+}
+
+// Validate reports searches that can never match, such as a window shorter
+// than Nights or required weekdays that do not fit in Nights nights.
+func (s WindowSearch) Validate() error {
+	if s.Nights < 1 {
+		return fmt.Errorf("nights must be at least 1, got %d", s.Nights)
+	}
+	if NightsBetween(s.WindowStart, s.WindowEnd) < s.Nights {
+		return fmt.Errorf("a %d-night stay does not fit between %s and %s", s.Nights,
+			s.WindowStart, s.WindowEnd)
+	}
+	if span := weekdaySpan(s.RequiredWeekdays); span > s.Nights {
+		return fmt.Errorf("covering %v takes at least %d consecutive nights, but the stay is %d", s.RequiredWeekdays, span, s.Nights)
+	}
+	return nil
+}
+
+// weekdaySpan returns the fewest consecutive nights that include every day
+// in days: one week minus the longest gap between required days, wrapping
+// around the week.
+func weekdaySpan(days []time.Weekday) int {
+	set := map[time.Weekday]bool{}
+	for _, d := range days {
+		set[d%7] = true
+	}
+	if len(set) == 0 {
+		return 0
+	}
+	sorted := []int{}
+	for d := range set {
+		sorted = append(sorted, int(d))
+	}
+	sort.Ints(sorted)
+	maxGap := sorted[0] + 7 - sorted[len(sorted)-1]
+	for i := 1; i < len(sorted); i++ {
+		if gap := sorted[i] - sorted[i-1]; gap > maxGap {
+			maxGap = gap
+		}
+	}
+	return 7 - maxGap + 1
+}
+
+// ScrapeRuns fetches the months the window covers from p, as
+// ScrapeRunsPlanned plans them, and returns the runs FindRuns finds in
+// them. The search is validated first.
+func ScrapeRuns(ctx context.Context, p Provider, campgroundID string, s WindowSearch) ([]Run, error) {
+	runs, _, err := ScrapeRunsPlanned(ctx, p, campgroundID, s)
+	return runs, err
+}
+
+// ParseWeekday accepts a weekday's English name or its first three letters,
+// in any case.
+func ParseWeekday(name string) (time.Weekday, error) {
+	lower := strings.ToLower(strings.TrimSpace(name))
+	for d := time.Sunday; d <= time.Saturday; d++ {
+		full := strings.ToLower(d.String())
+		if lower == full || (len(lower) == 3 && strings.HasPrefix(full, lower)) {
+			return d, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday %q", name)
+}
+
+// FindRuns returns, per campsite, each run of s.Nights available nights in
+// the window that covers the required weekdays. Runs for the same site never
+// overlap: after a match the search resumes at its departure day. Results
+// are sorted by site and start date; ctx is checked before each campsite as
+// in AvailableSites.
+func FindRuns(ctx context.Context, campground Campground, s WindowSearch) ([]Run, error) {
+	dates := Nights(s.WindowStart, s.WindowEnd)
+	runs := []Run{}
+	checked := 0
+	for siteID, site := range campground.Campsites {
+		if err := ctx.Err(); err != nil {
+			sortRuns(runs)
+			return runs, &PartialResultError{Checked: checked, Total: len(campground.Campsites), Err: err}
+		}
+		checked++
+		for start := 0; start+s.Nights <= len(dates); {
+			nights := dates[start : start+s.Nights]
+			if allAvailable(site, nights) && coversWeekdays(nights, s.RequiredWeekdays) {
+				runs = append(runs, Run{Site: siteID, Start: nights[0], End: nights[len(nights)-1].Next()})
+				start += s.Nights
+				continue
+			}
+			start++
+		}
+	}
+	sortRuns(runs)
+	return runs, nil
+}
+
+func allAvailable(site Campsite, nights []CivilDate) bool {
+	for _, night := range nights {
+		if !isAvailable(site, night) {
+			return false
+		}
+	}
+	return true
+}
+
+func coversWeekdays(nights []CivilDate, required []time.Weekday) bool {
+	have := map[time.Weekday]bool{}
+	for _, night := range nights {
+		have[night.Weekday()] = true
+	}
+	for _, d := range required {
+		if !have[d] {
+			return false
+		}
+	}
+	return true
+}
+
+func sortRuns(runs []Run) {
+	sort.Slice(runs, func(i, j int) bool {
+		if runs[i].Site != runs[j].Site {
+			return NaturalLess(runs[i].Site, runs[j].Site)
+		}
+		return runs[i].Start.Before(runs[j].Start)
+	})
+}
@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// windowCampground has one site, 1001, available on the nights given, such
+// as "07-14", in 2027 and reserved on every other night of June to August.
+func windowCampground(open ...string) Campground {
+	site := Campsite{CampsiteID: 1001, Availabilities: map[CivilDate]string{}}
+	for night := (CivilDate{2027, time.June, 1}); night.Month <= time.August; night = night.Next() {
+		site.Availabilities[night] = "Reserved"
+	}
+	for _, night := range open {
+		at, err := ParseCivilDate("2027-" + night)
+		if err != nil {
+			panic(err)
+		}
+		site.Availabilities[at] = "Available"
+	}
+	return Campground{Campsites: map[string]Campsite{"1001": site}}
+}
+
+func TestFindRuns(t *testing.T) {
+	tests := []struct {
+		name     string
+		open     []string
+		start    string
+		end      string
+		nights   int
+		weekdays []time.Weekday
+		want     string
+	}{
+		{name: "at the window start", open: []string{"07-01", "07-02"}, start: "07-01", end: "07-08", nights: 2, want: "07-01/07-03"},
+		{name: "at the window end", open: []string{"07-06", "07-07"}, start: "07-01", end: "07-08", nights: 2, want: "07-06/07-08"},
+		{name: "at both ends", open: []string{"07-01", "07-02", "07-06", "07-07"}, start: "07-01", end: "07-08", nights: 2,
+			want: "07-01/07-03,07-06/07-08"},
+		{name: "starting the night before the window", open: []string{"06-30", "07-01"}, start: "07-01", end: "07-08", nights: 2, want: ""},
+		{name: "ending on the last departure day", open: []string{"07-07", "07-08"}, start: "07-01", end: "07-08", nights: 2, want: ""},
+		{name: "window exactly the stay", open: []string{"07-01", "07-02"}, start: "07-01", end: "07-03", nights: 2, want: "07-01/07-03"},
+		{name: "one night at each end", open: []string{"07-01", "07-07"}, start: "07-01", end: "07-08", nights: 1, want: "07-01/07-02,07-07/07-08"},
+		{name: "no overlapping runs", open: []string{"07-01", "07-02", "07-03", "07-04", "07-05"}, start: "07-01", end: "07-08", nights: 2,
+			want: "07-01/07-03,07-03/07-05"},
+		{name: "across a month end to the window end", open: []string{"07-30", "07-31", "08-01"}, start: "07-30", end: "08-02", nights: 3,
+			want: "07-30/08-02"},
+		{name: "weekend at the window start", open: []string{"07-01", "07-02", "07-03", "07-04", "07-05", "07-06", "07-07"}, start: "07-02", end: "07-08",
+			nights: 2, weekdays: []time.Weekday{time.Friday, time.Saturday}, want: "07-02/07-04"},
+		// July 2, 2027 is a Friday.
+		{name: "weekend cut by the window end", open: []string{"07-01", "07-02", "07-03"}, start: "07-01", end: "07-03",
+			nights: 2, weekdays: []time.Weekday{time.Friday, time.Saturday}, want: ""},
+		{name: "weekend cut by the window start", open: []string{"07-02", "07-03", "07-04", "07-05"}, start: "07-03", end: "07-09",
+			nights: 2, weekdays: []time.Weekday{time.Friday, time.Saturday}, want: ""},
+		{name: "second weekend after a cut first", open: []string{"07-03", "07-04", "07-05", "07-06", "07-07", "07-08", "07-09", "07-10", "07-11"},
+			start: "07-03", end: "07-12", nights: 2, weekdays: []time.Weekday{time.Friday, time.Saturday}, want: "07-09/07-11"},
+		{name: "Saturday booked", open: []string{"07-01", "07-02", "07-04", "07-05"}, start: "07-01", end: "07-08",
+			nights: 3, weekdays: []time.Weekday{time.Friday, time.Saturday}, want: ""},
+		{name: "run with only the Saturday", open: []string{"07-03", "07-04", "07-05", "07-06", "07-07"}, start: "07-01", end: "07-08",
+			nights: 3, weekdays: []time.Weekday{time.Friday, time.Saturday}, want: ""},
+		{name: "longer run around the weekend", open: []string{"07-01", "07-02", "07-03", "07-04", "07-05", "07-06"}, start: "07-01", end: "07-08",
+			nights: 3, weekdays: []time.Weekday{time.Friday, time.Saturday}, want: "07-01/07-04"},
+		{name: "days apart in one run", open: []string{"07-02", "07-03", "07-04"}, start: "07-01", end: "07-08",
+			nights: 3, weekdays: []time.Weekday{time.Sunday, time.Friday}, want: "07-02/07-05"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			start, end := mustCivilDate(t, "2027-"+test.start), mustCivilDate(t, "2027-"+test.end)
+			search := WindowSearch{WindowStart: start, WindowEnd: end, Nights: test.nights, RequiredWeekdays: test.weekdays}
+			if err := search.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			runs, err := FindRuns(context.Background(), windowCampground(test.open...), search)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, run := range runs {
+				got = append(got, run.Start.Time().Format("01-02")+"/"+run.End.Time().Format("01-02"))
+			}
+			if strings.Join(got, ",") != test.want {
+				t.Errorf("runs %s, want %s", strings.Join(got, ","), test.want)
+			}
+		})
+	}
+}
+
+func TestWindowSearchValidate(t *testing.T) {
+	start := CivilDate{2027, time.July, 1}
+	tests := []struct {
+		name    string
+		search  WindowSearch
+		wantErr string
+	}{
+		{"fits exactly", WindowSearch{WindowStart: start, WindowEnd: start.AddDays(2), Nights: 2}, ""},
+		{"one night short", WindowSearch{WindowStart: start, WindowEnd: start.AddDays(1), Nights: 2}, "does not fit"},
+		{"no nights", WindowSearch{WindowStart: start, WindowEnd: start.AddDays(7)}, "at least 1"},
+		{"weekdays too far apart", WindowSearch{WindowStart: start, WindowEnd: start.AddDays(7), Nights: 2,
+			RequiredWeekdays: []time.Weekday{time.Friday, time.Sunday}}, "at least 3 consecutive nights"},
+		{"weekdays across the week's end", WindowSearch{WindowStart: start, WindowEnd: start.AddDays(7), Nights: 2,
+			RequiredWeekdays: []time.Weekday{time.Saturday, time.Sunday}}, ""},
+		{"Monday and Wednesday in two nights", WindowSearch{WindowStart: start, WindowEnd: start.AddDays(7), Nights: 2,
+			RequiredWeekdays: []time.Weekday{time.Monday, time.Wednesday}}, "at least 3 consecutive nights"},
+		{"Monday and Wednesday in three nights", WindowSearch{WindowStart: start, WindowEnd: start.AddDays(7), Nights: 3,
+			RequiredWeekdays: []time.Weekday{time.Wednesday, time.Monday}}, ""},
+		{"every day in six nights", WindowSearch{WindowStart: start, WindowEnd: start.AddDays(14), Nights: 6,
+			RequiredWeekdays: []time.Weekday{time.Sunday, time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday, time.Saturday}},
+			"at least 7 consecutive nights"},
+	}
+	for _, test := range tests {
+		err := test.search.Validate()
+		if test.wantErr == "" && err != nil || test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+			t.Errorf("%s: Validate() = %v, want %q", test.name, err, test.wantErr)
+		}
+	}
+}
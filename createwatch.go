@@ -0,0 +1,352 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+
+	scheduler "cloud.google.com/go/scheduler/apiv1"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"google.golang.org/api/iterator"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+	field_mask "google.golang.org/genproto/protobuf/field_mask"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Scheduler is the part of Cloud Scheduler that watches use, so they can
+// run against a fake; see UseServices.
+type Scheduler interface {
+	CreateJob(ctx context.Context, parent string, job *schedulerpb.Job) error
+	ListJobs(ctx context.Context, parent string) ([]*schedulerpb.Job, error)
+	DeleteJob(ctx context.Context, name string) error
+	GetJob(ctx context.Context, name string) (*schedulerpb.Job, error)
+	// UpdateJob writes the fields of job named by paths.
+	UpdateJob(ctx context.Context, job *schedulerpb.Job, paths []string) error
+	PauseJob(ctx context.Context, name string) error
+	ResumeJob(ctx context.Context, name string) error
+}
+
+// cloudScheduler is the Scheduler backed by the shared client.
+type cloudScheduler struct {
+	c *scheduler.CloudSchedulerClient
+}
+
+func (s cloudScheduler) CreateJob(ctx context.Context, parent string, job *schedulerpb.Job) error {
+	_, err := s.c.CreateJob(ctx, &schedulerpb.CreateJobRequest{Parent: parent, Job: job})
+	return err
+}
+
+func (s cloudScheduler) ListJobs(ctx context.Context, parent string) ([]*schedulerpb.Job, error) {
+	jobs := []*schedulerpb.Job{}
+	it := s.c.ListJobs(ctx, &schedulerpb.ListJobsRequest{Parent: parent})
+	for {
+		job, err := it.Next()
+		if err == iterator.Done {
+			return jobs, nil
+		}
+		if err != nil {
+			return jobs, err
+		}
+		jobs = append(jobs, job)
+	}
+}
+
+func (s cloudScheduler) DeleteJob(ctx context.Context, name string) error {
+	return s.c.DeleteJob(ctx, &schedulerpb.DeleteJobRequest{Name: name})
+}
+
+func (s cloudScheduler) GetJob(ctx context.Context, name string) (*schedulerpb.Job, error) {
+	return s.c.GetJob(ctx, &schedulerpb.GetJobRequest{Name: name})
+}
+
+func (s cloudScheduler) PauseJob(ctx context.Context, name string) error {
+	_, err := s.c.PauseJob(ctx, &schedulerpb.PauseJobRequest{Name: name})
+	return err
+}
+
+func (s cloudScheduler) ResumeJob(ctx context.Context, name string) error {
+	_, err := s.c.ResumeJob(ctx, &schedulerpb.ResumeJobRequest{Name: name})
+	return err
+}
+
+func (s cloudScheduler) UpdateJob(ctx context.Context, job *schedulerpb.Job, paths []string) error {
+	_, err := s.c.UpdateJob(ctx, &schedulerpb.UpdateJobRequest{Job: job, UpdateMask: &field_mask.FieldMask{Paths: paths}})
+	return err
+}
+
+// newWatchScheduler returns the scheduler every read or change of a watch's
+// job goes through. Tests replace it.
+var newWatchScheduler = func() (Scheduler, error) {
+	c, err := schedulerClient()
+	if err != nil {
+		return nil, err
+	}
+	return cloudScheduler{c}, nil
+}
+
+// DuplicateWatchError is returned by CreateWatch when a watch for the same
+// campground and dates already exists.
+type DuplicateWatchError struct {
+	Name JobName
+}
+
+func (e *DuplicateWatchError) Error() string {
+	return fmt.Sprintf("a watch for these campgrounds and dates already exists: %s", e.Name)
+}
+
+// CreateOptions change how CreateWatch creates a watch.
+type CreateOptions struct {
+	// Force creates the watch even when an overlapping one exists.
+	Force bool
+	// Jitter offsets a "*/n" schedule by a few minutes derived from the
+	// watch's name, as for LastMinuteOptions, so watches on the same cadence
+	// do not all hit recreation.gov at once. A merged watch keeps its
+	// schedule.
+	Jitter bool
+	// Confirm emails the watch's recipient once the watch is created, with
+	// EstimateOdds' estimate for single-campground watches. A merged watch
+	// sends nothing.
+	Confirm bool
+}
+
+// CreatedWatch is what CreateWatch did.
+type CreatedWatch struct {
+	Name JobName
+	// Merged is set when the watch was merged into the existing one named
+	// Name instead of being created.
+	Merged bool
+}
+
+// WatchID derives the job ID for m from its campgrounds and dates, so the
+// same watch always gets the same name.
+func WatchID(m MessageContent) string {
+	parts := []string{"watch", strings.Join(m.campgrounds(), "_")}
+	compact := func(s string) string {
+		if d, err := core.ParseCivilDate(s); err == nil {
+			return strings.Replace(d.String(), "-", "", -1)
+		}
+		return s
+	}
+	switch {
+	case m.Nights > 0:
+		parts = append(parts, compact(m.WindowStart), compact(m.WindowEnd), fmt.Sprintf("%dn", m.Nights))
+	case m.MaxNights > 0:
+		parts = append(parts, compact(m.Arrival), fmt.Sprintf("upto%dn", m.MaxNights))
+	default:
+		parts = append(parts, compact(m.Arrival), compact(m.Departure))
+	}
+	return strings.Join(parts, "-")
+}
+
+// CreateWatch validates m, names it with WatchID and creates a scheduler job
+// in cfg that publishes it to cfg.Topic on the cron schedule. The schedule
+// runs in m.TimeZone, or UTC. A CampgroundName is resolved to its ID first,
+// so an ambiguous name is reported now rather than on every run.
+//
+// Like the tonight and release commands, an active watch of the same owner
+// for the same campgrounds and overlapping nights is not duplicated: m is
+// merged into it and its name is returned with Merged set. opts.Force
+// creates m regardless; a watch with m's exact name is then a
+// *DuplicateWatchError.
+//
+// With WATCH_REGISTRY set, m is stored in the registry under an opaque ID
+// instead, and the job publishes only that ID.
+func CreateWatch(ctx context.Context, cfg Config, m MessageContent, cron string, opts CreateOptions) (CreatedWatch, error) {
+	if err := cfg.Validate(); err != nil {
+		return CreatedWatch{}, err
+	}
+	if err := m.resolveCampground(ctx); err != nil {
+		return CreatedWatch{}, err
+	}
+	m.Name = WatchID(m)
+	store := newWatchStore(cfg)
+	if store != nil {
+		m.Name = registryID(m)
+	}
+	if err := m.Validate(); err != nil {
+		return CreatedWatch{}, err
+	}
+	if err := checkContact(ctx, newContactStore(cfg), m); err != nil {
+		return CreatedWatch{}, err
+	}
+	start, end := m.span()
+	warning, err := checkRequestRate(cron, len(m.campgrounds()), monthsCovered(start, end))
+	if err != nil {
+		return CreatedWatch{}, err
+	}
+	if warning != "" {
+		logger.Println(warning)
+	}
+	s, err := newWatchScheduler()
+	if err != nil {
+		return CreatedWatch{}, err
+	}
+	if !opts.Force {
+		existing, err := findOverlappingWatch(ctx, s, store, cfg.Parent(), m)
+		if err != nil {
+			return CreatedWatch{}, err
+		}
+		if existing != nil {
+			name, err := mergeWatch(ctx, s, store, existing, m)
+			return CreatedWatch{Name: name, Merged: err == nil}, err
+		}
+	}
+	name := cfg.JobName(m.Name)
+	var data []byte
+	if store != nil {
+		data, err = registerWatch(ctx, store, cfg, m)
+	} else {
+		// The payload carries the full name, so the run deletes the job in
+		// cfg's location rather than the function's own.
+		m.Name = name.String()
+		data, err = json.Marshal(m)
+	}
+	if err != nil {
+		return CreatedWatch{}, err
+	}
+	timeZone := m.TimeZone
+	if timeZone == "" {
+		timeZone = "Etc/UTC"
+	}
+
+	if opts.Jitter {
+		jittered, offset := jitterSchedule(cron, name.Job)
+		logger.Printf("job %s: schedule %q offset by %d minutes to %q", name, cron, offset, jittered)
+		cron = jittered
+	}
+	err = s.CreateJob(ctx, cfg.Parent(), &schedulerpb.Job{
+		Name:     name.String(),
+		Schedule: cron,
+		TimeZone: timeZone,
+		Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{
+			TopicName: fmt.Sprintf("projects/%s/topics/%s", cfg.Project, cfg.Topic),
+			Data:      data,
+		}},
+	})
+	if status.Code(err) == codes.AlreadyExists {
+		return CreatedWatch{}, &DuplicateWatchError{Name: name}
+	}
+	if err != nil {
+		return CreatedWatch{}, fmt.Errorf("creating job %s: %v", name, err)
+	}
+	if opts.Confirm {
+		sendWatchCreated(ctx, m, name)
+	}
+	return CreatedWatch{Name: name}, nil
+}
+
+// sendWatchCreated tells m's recipient that its watch is set up and, for a
+// single campground, how its odds look. A failure is logged; the watch is
+// created either way.
+func sendWatchCreated(ctx context.Context, m MessageContent, name JobName) {
+	if err := m.resolveContact(ctx); err != nil {
+		logger.Printf("job %s: confirmation: %v", name, err)
+		return
+	}
+	start, end := m.span()
+	place := strings.Join(m.campgrounds(), ", ")
+	body := fmt.Sprintf("Your watch %s for campground %s, %s, is set up.", name.Job, place, FormatStay(start, end, m.Locale))
+	if !m.isPermit() && !m.isTour() && len(m.Campgrounds) == 0 {
+		odds, err := EstimateOdds(ctx, m.Campground, start.Time(), end.Time())
+		switch {
+		case errors.Is(err, ErrNoHistory):
+			body += " There is no scrape history to estimate its odds from."
+		case err != nil:
+			logger.Printf("job %s: estimating odds: %v", name, err)
+		default:
+			body += " " + odds.String()
+		}
+	}
+	if err := sendNoticeTo(ctx, m.recipient(), "Watch created for "+place, body, "<p>"+html.EscapeString(body)+"</p>"); err != nil {
+		logger.Printf("job %s: sending confirmation: %v", name, err)
+	}
+}
+
+// registerWatch stores m as a new active watch and returns the job payload
+// referring to it. A watch that is already registered and not completed is
+// a *DuplicateWatchError.
+func registerWatch(ctx context.Context, store WatchStore, cfg Config, m MessageContent) ([]byte, error) {
+	existing, err := store.GetWatch(ctx, m.Name)
+	switch {
+	case err == nil && existing.Status != WatchCompleted:
+		return nil, &DuplicateWatchError{Name: cfg.JobName(m.Name)}
+	case err != nil && !errors.Is(err, ErrWatchNotFound):
+		return nil, err
+	}
+	now := DefaultScraper.now().UTC()
+	if err := store.PutWatch(ctx, WatchRecord{ID: m.Name, Watch: m, Status: WatchActive, Created: now, Updated: now}); err != nil {
+		return nil, err
+	}
+	return json.Marshal(watchRef{WatchID: m.Name})
+}
+
+// ListedWatch is a watch as ListWatches reports it.
+type ListedWatch struct {
+	// Name is the watch's scheduler job. Its String is the fully-qualified
+	// name, which ControlWatch, GenerateDiagnostics and the rest accept
+	// whatever location the job is in.
+	Name     JobName
+	Watch    MessageContent
+	Schedule string
+	// JitterMinutes is how far CreateOptions.Jitter moved the schedule
+	// from the top of its interval.
+	JitterMinutes int
+	// RequestsPerHour estimates the requests to recreation.gov the watch
+	// sends in its busiest hour, from its schedule, campgrounds and months,
+	// so the heaviest watches stand out. It is zero when the schedule is
+	// not a cron the estimate understands.
+	RequestsPerHour float64
+}
+
+// ListWatches returns every watch in cfg: the payload of each legacy watch
+// job and the definition of each registered watch that has a job, named by
+// its job in cfg's location. Jobs whose payload is not a watch are skipped.
+func ListWatches(ctx context.Context, cfg Config) ([]ListedWatch, error) {
+	s, err := newWatchScheduler()
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := s.ListJobs(ctx, cfg.Parent())
+	if err != nil {
+		return nil, fmt.Errorf("listing jobs in %s: %v", cfg.Parent(), err)
+	}
+	store := newWatchStore(cfg)
+	watches := []ListedWatch{}
+	for _, job := range jobs {
+		target := job.GetPubsubTarget()
+		if target == nil {
+			continue
+		}
+		m, _, ok := watchFromJob(ctx, store, target.Data)
+		if !ok {
+			continue
+		}
+		name, err := ParseJobName(job.Name)
+		if err != nil {
+			continue
+		}
+		perHour, _ := m.requestsPerHour(job.Schedule)
+		watches = append(watches, ListedWatch{
+			Name:            name,
+			Watch:           m,
+			Schedule:        job.Schedule,
+			JitterMinutes:   jitterOffset(job.Schedule),
+			RequestsPerHour: perHour,
+		})
+	}
+	return watches, nil
+}
+
+// monthsCovered counts the calendar months holding a night from start up
+// to end, at least one.
+func monthsCovered(start core.CivilDate, end core.CivilDate) int {
+	if !end.After(start) {
+		return 1
+	}
+	last := end.AddDays(-1)
+	return (last.Year-start.Year)*12 + int(last.Month-start.Month) + 1
+}
@@ -0,0 +1,256 @@
+package scraper_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+var testConfig = scraper.Config{Project: "fakerecgov", Location: "us-central1", Topic: "scrape"}
+
+func newWatch(arrival string, departure string) scraper.MessageContent {
+	return scraper.MessageContent{Campground: "232447", Arrival: arrival, Departure: departure, NotifyEmail: "me@example.com"}
+}
+
+// jobWatch decodes the legacy watch job name publishes.
+func jobWatch(t *testing.T, h *fakerecgov.Harness, name scraper.JobName) scraper.MessageContent {
+	t.Helper()
+	job := h.Scheduler.Job(name.String())
+	if job == nil {
+		t.Fatalf("no job %s", name)
+	}
+	var m scraper.MessageContent
+	if err := json.Unmarshal(job.GetPubsubTarget().GetData(), &m); err != nil {
+		t.Fatal(err)
+	}
+	return m
+}
+
+func TestCreateWatchMerges(t *testing.T) {
+	tests := []struct {
+		name       string
+		second     scraper.MessageContent
+		opts       scraper.CreateOptions
+		wantMerged bool
+	}{
+		{"same stay", newWatch("2027-07-14", "2027-07-16"), scraper.CreateOptions{}, true},
+		{"partial overlap", newWatch("2027-07-15", "2027-07-18"), scraper.CreateOptions{}, true},
+		{"window over the stay", scraper.MessageContent{Campground: "232447", WindowStart: "2027-07-01", WindowEnd: "2027-07-31", Nights: 2, NotifyEmail: "me@example.com"}, scraper.CreateOptions{}, true},
+		{"back to back", newWatch("2027-07-16", "2027-07-18"), scraper.CreateOptions{}, false},
+		{"forced", newWatch("2027-07-15", "2027-07-18"), scraper.CreateOptions{Force: true}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(false))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			ctx := context.Background()
+			first, err := scraper.CreateWatch(ctx, testConfig, newWatch("2027-07-14", "2027-07-16"), "*/10 * * * *", scraper.CreateOptions{})
+			if err != nil || first.Merged {
+				t.Fatalf("first CreateWatch = %+v, %v", first, err)
+			}
+
+			second := test.second
+			second.NotifyPhone = "+14155550100"
+			got, err := scraper.CreateWatch(ctx, testConfig, second, "*/10 * * * *", test.opts)
+			if err != nil {
+				t.Fatalf("second CreateWatch: %v", err)
+			}
+			if got.Merged != test.wantMerged {
+				t.Fatalf("Merged = %v, want %v", got.Merged, test.wantMerged)
+			}
+			jobs, _ := h.Scheduler.ListJobs(ctx, testConfig.Parent())
+			if !test.wantMerged {
+				if len(jobs) != 2 || got.Name == first.Name {
+					t.Errorf("got %d jobs, second named %s, want a second job", len(jobs), got.Name)
+				}
+				return
+			}
+			if len(jobs) != 1 || got.Name != first.Name {
+				t.Fatalf("got %d jobs, merged into %s, want only %s", len(jobs), got.Name, first.Name)
+			}
+			if m := jobWatch(t, h, first.Name); m.NotifyPhone != second.NotifyPhone || m.Arrival != "2027-07-14" {
+				t.Errorf("merged watch %+v, want the first's dates with the second's phone", m)
+			}
+		})
+	}
+}
+
+func TestCreateWatchMergesRegistered(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	defer scraper.UseServices(scraper.Services{Watches: h.Store})()
+	ctx := context.Background()
+
+	first, err := scraper.CreateWatch(ctx, testConfig, newWatch("2027-07-14", "2027-07-16"), "*/10 * * * *", scraper.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second := newWatch("2027-07-15", "2027-07-17")
+	second.WebhookURL = "https://example.com/hook"
+	second.Priority = []string{"1002"}
+	got, err := scraper.CreateWatch(ctx, testConfig, second, "*/10 * * * *", scraper.CreateOptions{})
+	if err != nil || !got.Merged || got.Name != first.Name {
+		t.Fatalf("second CreateWatch = %+v, %v; want merged into %s", got, err, first.Name)
+	}
+	r, err := h.Store.GetWatch(ctx, first.Name.Job)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Watch.WebhookURL != second.WebhookURL || len(r.Watch.Priority) != 1 || r.Watch.Arrival != "2027-07-14" {
+		t.Errorf("registered watch %+v, want the first's dates with the second's webhook and priority", r.Watch)
+	}
+	records, _ := h.Store.ListWatchRecords(ctx)
+	if len(records) != 1 {
+		t.Errorf("registry holds %d watches, want 1", len(records))
+	}
+}
+
+func TestListWatchesRequestsPerHour(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	multi := scraper.MessageContent{Campgrounds: []string{"232447", "232450"}, Arrival: "2027-07-30", Departure: "2027-08-02"}
+	want := map[string]float64{}
+	for _, w := range []struct {
+		watch    scraper.MessageContent
+		schedule string
+		perHour  float64
+	}{
+		{newWatch("2027-07-14", "2027-07-16"), "*/5 * * * *", 12},
+		// Two campgrounds over July and August, four requests a run.
+		{multi, "*/10 6-20 * * *", 24},
+	} {
+		created, err := scraper.CreateWatch(ctx, testConfig, w.watch, w.schedule, scraper.CreateOptions{})
+		if err != nil {
+			t.Fatalf("CreateWatch: %v", err)
+		}
+		want[created.Name.Job] = w.perHour
+	}
+
+	watches, err := scraper.ListWatches(ctx, testConfig)
+	if err != nil {
+		t.Fatalf("ListWatches: %v", err)
+	}
+	if len(watches) != len(want) {
+		t.Fatalf("ListWatches returned %d watches, want %d", len(watches), len(want))
+	}
+	for _, w := range watches {
+		if w.RequestsPerHour != want[w.Name.Job] {
+			t.Errorf("watch %s estimated at %v requests/hour, want %v", w.Name, w.RequestsPerHour, want[w.Name.Job])
+		}
+	}
+}
+
+func TestCreateWatchJitter(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	jittered := map[string]bool{}
+	for i, stay := range [][2]string{{"2027-07-01", "2027-07-03"}, {"2027-07-05", "2027-07-07"}, {"2027-07-09", "2027-07-11"}, {"2027-07-13", "2027-07-15"}, {"2027-07-17", "2027-07-19"}} {
+		watch := newWatch(stay[0], stay[1])
+		watch.NotifyEmail = fmt.Sprintf("camper%d@example.com", i)
+		created, err := scraper.CreateWatch(ctx, testConfig, watch, "*/10 * * * *", scraper.CreateOptions{Jitter: i > 0})
+		if err != nil {
+			t.Fatalf("CreateWatch: %v", err)
+		}
+		jittered[created.Name.Job] = i > 0
+	}
+
+	watches, err := scraper.ListWatches(ctx, testConfig)
+	if err != nil {
+		t.Fatalf("ListWatches: %v", err)
+	}
+	offsets := map[int]bool{}
+	for _, w := range watches {
+		want := "*/10 * * * *"
+		if w.JitterMinutes > 0 {
+			want = fmt.Sprintf("%d-59/10 * * * *", w.JitterMinutes)
+		}
+		if w.Schedule != want {
+			t.Errorf("watch %s runs on %q with a %d minute offset", w.Name, w.Schedule, w.JitterMinutes)
+		}
+		if !jittered[w.Name.Job] && w.JitterMinutes != 0 {
+			t.Errorf("watch %s created without jitter is offset by %d minutes", w.Name, w.JitterMinutes)
+		}
+		if jittered[w.Name.Job] {
+			offsets[w.JitterMinutes] = true
+		}
+		if w.RequestsPerHour != 6 {
+			t.Errorf("watch %s estimated at %v requests/hour, want 6 whatever its offset", w.Name, w.RequestsPerHour)
+		}
+	}
+	if len(offsets) < 2 {
+		t.Errorf("jittered watches all offset by %v", offsets)
+	}
+}
+
+// Watches in two locations of one project are listed, controlled and ended
+// by their full names, each in its own location.
+func TestWatchesAcrossLocations(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	east := scraper.Config{Project: testConfig.Project, Location: "us-east1", Topic: testConfig.Topic}
+	locations := map[string]scraper.Config{"us-central1": testConfig, "us-east1": east}
+	created := map[string]scraper.JobName{}
+	for location, cfg := range locations {
+		c, err := scraper.CreateWatch(ctx, cfg, newWatch("2027-07-14", "2027-07-16"), "*/10 * * * *", scraper.CreateOptions{})
+		if err != nil {
+			t.Fatalf("CreateWatch in %s: %v", location, err)
+		}
+		if c.Name.Location != location || c.Merged {
+			t.Fatalf("CreateWatch in %s = %+v", location, c)
+		}
+		created[location] = c.Name
+	}
+
+	for location, cfg := range locations {
+		watches, err := scraper.ListWatches(ctx, cfg)
+		if err != nil {
+			t.Fatalf("ListWatches in %s: %v", location, err)
+		}
+		if len(watches) != 1 || watches[0].Name != created[location] {
+			t.Fatalf("ListWatches in %s = %+v, want only %s", location, watches, created[location])
+		}
+		want := "projects/fakerecgov/locations/" + location + "/jobs/watch-232447-20270714-20270716"
+		if got := watches[0].Name.String(); got != want {
+			t.Errorf("listed %s, want %s", got, want)
+		}
+	}
+
+	if err := scraper.ControlWatch(ctx, scraper.ActionPause, created["us-east1"].String()); err != nil {
+		t.Fatalf("pausing the us-east1 watch: %v", err)
+	}
+	if state := h.Scheduler.Job(created["us-east1"].String()).State; state != schedulerpb.Job_PAUSED {
+		t.Errorf("us-east1 job is %v, want paused", state)
+	}
+	if state := h.Scheduler.Job(created["us-central1"].String()).State; state != schedulerpb.Job_ENABLED {
+		t.Errorf("us-central1 job is %v after pausing the us-east1 one", state)
+	}
+
+	// The run is in neither location's deployment, so it can only find
+	// the job to delete by the payload's full name.
+	if err := h.Fire(ctx, created["us-east1"].String()); err != nil {
+		t.Fatalf("running the us-east1 watch: %v", err)
+	}
+	if n := len(h.Notifier.Alerts()); n != 1 {
+		t.Fatalf("got %d alerts, want 1", n)
+	}
+	if deleted := h.Scheduler.Deleted(); len(deleted) != 1 || deleted[0] != created["us-east1"].String() {
+		t.Errorf("deleted %v, want only %s", deleted, created["us-east1"])
+	}
+	if h.Scheduler.Job(created["us-central1"].String()) == nil {
+		t.Errorf("us-central1 job deleted by the us-east1 watch's run")
+	}
+}
@@ -0,0 +1,82 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+)
+
+// sentPrefix is where delivered-alert markers live in RESULTS_BUCKET.
+const sentPrefix = "sent/"
+
+// sentTTL bounds the dedupe window: a marker older than this no longer
+// holds back the same alert, so a site that is booked and reopens, or a
+// watch recreated days later, alerts again. Each marker carries its expiry
+// in its metadata, as claims do, since RESULTS_BUCKET has no lifecycle rule.
+const sentTTL = 24 * time.Hour
+
+// alertFingerprint identifies what an alert says, not which watch sent it:
+// the campground, the stay window and the set of sites, in any order.
+// Presentation such as ranking or the watch name does not change it.
+func alertFingerprint(a alert) string {
+	sites := append([]string(nil), a.Sites...)
+	sort.Strings(sites)
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n%s", a.CampgroundID, a.Arrival, a.Departure,
+		strings.Join(sites, ","))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// sentMarker returns the marker object for an alert to recipient, or nil
+// when RESULTS_BUCKET is not set and dedupe is disabled.
+func sentMarker(client *storage.Client, a alert, recipient string) *storage.ObjectHandle {
+	bucket := os.Getenv("RESULTS_BUCKET")
+	if bucket == "" || client == nil {
+		return nil
+	}
+	r := sha256.Sum256([]byte(recipient))
+	return client.Bucket(bucket).Object(sentPrefix + hex.EncodeToString(r[:8]) + "/" + alertFingerprint(a))
+}
+
+// alreadySent reports whether recipient was sent an alert with the same
+// content within sentTTL, whichever watch sent it. A marker without an
+// expiry counts as expired. Errors are logged and treated as not sent,
+// failing open as claimAlert does.
+func alreadySent(ctx context.Context, client *storage.Client, a alert, recipient string) bool {
+	object := sentMarker(client, a, recipient)
+	if object == nil {
+		return false
+	}
+	attrs, err := object.Attrs(ctx)
+	if err != nil {
+		if err != storage.ErrObjectNotExist {
+			logger.Println("checking sent alerts:", err)
+		}
+		return false
+	}
+	expires, err := time.Parse(time.RFC3339, attrs.Metadata["expires"])
+	return err == nil && DefaultScraper.now().Before(expires)
+}
+
+// markSent records that recipient has been sent a.
+func markSent(ctx context.Context, client *storage.Client, a alert, recipient string) error {
+	object := sentMarker(client, a, recipient)
+	if object == nil {
+		return nil
+	}
+	w := object.NewWriter(ctx)
+	w.ContentType = "text/plain"
+	w.Metadata = map[string]string{"expires": DefaultScraper.now().Add(sentTTL).UTC().Format(time.RFC3339)}
+	if _, err := w.Write([]byte(a.JobName)); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
@@ -0,0 +1,112 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// recordingNotifier is a channel to one recipient that keeps what it was
+// sent.
+type recordingNotifier struct {
+	recipient string
+	sent      *[]alert
+}
+
+func (n recordingNotifier) Channel() string { return "recording" }
+
+func (n recordingNotifier) Recipient() (string, string) { return n.recipient, n.recipient }
+
+func (n recordingNotifier) Notify(ctx context.Context, a alert, r renderedAlert) error {
+	*n.sent = append(*n.sent, a)
+	return nil
+}
+
+// Editing a watch recreates it under a new name, and its next scrape finds
+// the same sites, perhaps ranked or described differently. Its recipient
+// is not sent them again, but someone new is.
+func TestSendAlertRecreatedWatch(t *testing.T) {
+	fake := useFakeGCS(t, "results")
+	sent := []alert{}
+	recipient := "camper@example.com"
+	old := notifiersFor
+	notifiersFor = func(a alert) []notifier { return []notifier{recordingNotifier{recipient, &sent}} }
+	defer func() { notifiersFor = old }()
+	ctx := context.Background()
+
+	first := alert{JobName: "camp-232447-2027-07-14", CampgroundID: "232447", CampgroundName: "Upper Pines",
+		Arrival: core.CivilDate{Year: 2027, Month: 7, Day: 14}, Departure: core.CivilDate{Year: 2027, Month: 7, Day: 16},
+		Sites: []string{"1001", "1002"}}
+	if err := sendAlert(ctx, first); err != nil {
+		t.Fatal(err)
+	}
+	if markers := fake.names("results", sentPrefix); len(sent) != 1 || len(markers) != 1 {
+		t.Fatalf("first scrape sent %d alerts and left markers %v, want one of each", len(sent), markers)
+	}
+
+	rescan := first
+	rescan.JobName = "camp-232447-2027-07-14-edited"
+	rescan.Sites = []string{"1002", "1001"}
+	rescan.SiteNames = map[string]string{"1001": "Site 1", "1002": "Site 2"}
+	if err := sendAlert(ctx, rescan); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 1 {
+		t.Errorf("recreated watch sent %d alerts, want the first only", len(sent))
+	}
+
+	recipient = "friend@example.com"
+	if err := sendAlert(ctx, rescan); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 2 || sent[1].JobName != rescan.JobName {
+		t.Errorf("a new recipient got %d alerts in all, want the recreated watch's too", len(sent))
+	}
+
+	recipient = "camper@example.com"
+	more := rescan
+	more.Sites = append([]string{"1003"}, rescan.Sites...)
+	if err := sendAlert(ctx, more); err != nil {
+		t.Fatal(err)
+	}
+	if len(sent) != 3 {
+		t.Errorf("a new site was not alerted: %d alerts in all, want 3", len(sent))
+	}
+}
+
+// The same alert is held back only for sentTTL, after which a recreated
+// watch alerts its recipient again.
+func TestSendAlertDedupeExpires(t *testing.T) {
+	useFakeGCS(t, "results")
+	clock := useClock(t, time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	sent := []alert{}
+	old := notifiersFor
+	notifiersFor = func(a alert) []notifier { return []notifier{recordingNotifier{"camper@example.com", &sent}} }
+	defer func() { notifiersFor = old }()
+	ctx := context.Background()
+
+	a := alert{JobName: "camp-232447-2027-07-14", CampgroundID: "232447",
+		Arrival: core.CivilDate{Year: 2027, Month: 7, Day: 14}, Departure: core.CivilDate{Year: 2027, Month: 7, Day: 16},
+		Sites: []string{"1001"}}
+	for i, step := range []struct {
+		after time.Duration
+		want  int
+	}{
+		{0, 1},
+		{sentTTL - time.Minute, 1},
+		{2 * time.Minute, 2},
+		{time.Hour, 2},
+	} {
+		clock.at = clock.at.Add(step.after)
+		a.JobName = fmt.Sprintf("camp-232447-2027-07-14-%d", i)
+		if err := sendAlert(ctx, a); err != nil {
+			t.Fatal(err)
+		}
+		if len(sent) != step.want {
+			t.Errorf("step %d, %v on: %d alerts sent in all, want %d", i, step.after, len(sent), step.want)
+		}
+	}
+}
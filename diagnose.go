@@ -0,0 +1,322 @@
+package scraper
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Diagnostics is everything known about one watch, for debugging reports
+// that it is broken.
+type Diagnostics struct {
+	GeneratedAt time.Time `json:"generated_at"`
+	Job         string    `json:"job"`
+	// Spec is the watch payload with header override values that look like
+	// credentials removed.
+	Spec            *MessageContent `json:"spec,omitempty"`
+	SpecProblems    []string        `json:"spec_problems,omitempty"`
+	Schedule        string          `json:"schedule"`
+	TimeZone        string          `json:"time_zone"`
+	State           string          `json:"state"`
+	LastAttempt     *time.Time      `json:"last_attempt,omitempty"`
+	LastStatus      string          `json:"last_status,omitempty"`
+	NextRun         *time.Time      `json:"next_run,omitempty"`
+	RequestsPerHour float64         `json:"requests_per_hour"`
+	// Config holds the deployment settings that affect the watch. Secrets
+	// are reported only as set or unset.
+	Config map[string]string `json:"config"`
+	// LastResult is the registry's record of the watch's last run, saying
+	// site by site why it found nothing when it did not. Only registered
+	// watches have one.
+	LastResult *WatchResult `json:"last_result,omitempty"`
+	// Scans summarises the last week of scans of the watch's campgrounds
+	// and nights in the scrape history, newest first.
+	Scans []ScanSummary `json:"scans,omitempty"`
+	// Snapshot describes what the watch's next run is compared with.
+	Snapshot      SnapshotInfo         `json:"snapshot"`
+	RateLimit     RateLimitState       `json:"rate_limit"`
+	Notifications []NotificationRecord `json:"notifications,omitempty"`
+	// Errors lists the parts of the bundle that could not be gathered.
+	Errors []string `json:"errors,omitempty"`
+}
+
+// ScanSummary is one scan of a watch's nights at one campground, from the
+// scrape history.
+type ScanSummary struct {
+	At           time.Time `json:"at"`
+	CampgroundID string    `json:"campground_id"`
+	SiteNights   int       `json:"site_nights"`
+	Available    int       `json:"available"`
+}
+
+// SnapshotInfo describes what a watch's runs store for the next one: the
+// entries of its last scan, kept for TrackChanges watches, site monitors
+// and reservation guards, and the site-night keys of the last alert of a
+// persistent watch.
+type SnapshotInfo struct {
+	LastScanEntries  int `json:"last_scan_entries"`
+	LastNotifiedKeys int `json:"last_notified_keys"`
+}
+
+// RateLimitState is how the instance building a bundle paces its requests
+// to recreation.gov.
+type RateLimitState struct {
+	Interval string `json:"interval"`
+	// BlockedUntil is set while every request is held off because
+	// recreation.gov blocked one.
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+}
+
+// diagnosticSettings are reported by value; secretEnvVars only as set/unset.
+var diagnosticSettings = []string{
+	"ALLOW_HEADER_OVERRIDES", "RESULTS_BUCKET", "PAGES_BUCKET", "RESULTS_TOPIC", "ARCHIVE_BUCKET", "ARCHIVE_BODIES",
+	"REQUEST_RATE_WARN", "REQUEST_RATE_MAX", "REQUEST_USER_AGENT", "REQUEST_INTERVAL", "REQUEST_MAX_BYTES", "CLAIM_URL", "WATCH_REGISTRY", "METRICS",
+	"REPLAY_DIR", "RECORD_DIR", "BQ_DATASET", "BQ_TABLE", "CONTROL_BUCKET", "SCRAPE_TIMEOUT", "WATCH_TOPIC", "SCHEDULER_LOCATION",
+	"SECRETS_KMS_KEY",
+}
+
+// diagnosticScans caps the scans a bundle lists.
+const diagnosticScans = 20
+
+// GenerateDiagnostics gathers a watch's scheduler job, decoded payload,
+// validation problems, request rate, relevant configuration, last registry
+// result, recent scans, stored snapshot, the instance's rate limiting and the
+// last week of archived notifications into one JSON document. A job that does
+// not exist is an ErrWatchNotFound. Missing pieces are
+// listed in Errors rather than failing the bundle. The encoded document is
+// passed through Redact before it is returned, so emails, phone numbers and
+// configured secrets cannot appear in it whichever field they end up in.
+func GenerateDiagnostics(ctx context.Context, watchName string) ([]byte, error) {
+	name, err := ParseJobName(watchName)
+	if err != nil {
+		return nil, err
+	}
+	d := Diagnostics{GeneratedAt: DefaultScraper.now().UTC(), Job: name.String(), Config: map[string]string{}}
+	for _, setting := range diagnosticSettings {
+		d.Config[setting] = os.Getenv(setting)
+	}
+	for _, secret := range secretEnvVars {
+		d.Config[secret] = "unset"
+		if os.Getenv(secret) != "" {
+			d.Config[secret] = "set"
+		}
+	}
+
+	c, err := newWatchScheduler()
+	if err != nil {
+		return nil, err
+	}
+	job, err := c.GetJob(ctx, name.String())
+	if status.Code(err) == codes.NotFound {
+		return nil, fmt.Errorf("job %s: %w", name, ErrWatchNotFound)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("getting job %s: %v", name, err)
+	}
+	d.Schedule = job.Schedule
+	d.TimeZone = job.TimeZone
+	d.State = job.State.String()
+	d.LastAttempt = protoTime(job.LastAttemptTime)
+	d.NextRun = protoTime(job.ScheduleTime)
+	if job.Status != nil {
+		d.LastStatus = job.Status.Message
+	}
+	if data := job.GetPubsubTarget().GetData(); data != nil {
+		store := newWatchStore(activeConfig)
+		d.SpecProblems, _ = verifyPayload(ctx, store, data)
+		if m, _, ok := watchFromJob(ctx, store, data); ok {
+			headers := map[string]string{}
+			for key, value := range m.Headers {
+				if looksLikeCredential(key) {
+					value = "[REDACTED]"
+				}
+				headers[key] = value
+			}
+			if m.Headers != nil {
+				m.Headers = headers
+			}
+			d.Spec = &m
+		}
+		if ref, ok := watchRefOf(data); ok && store != nil {
+			if r, err := store.GetWatch(ctx, ref.WatchID); err == nil {
+				d.LastResult = r.LastResult
+			} else {
+				d.Errors = append(d.Errors, "registry: "+err.Error())
+			}
+		}
+	} else {
+		d.Errors = append(d.Errors, "job has no Pub/Sub payload")
+	}
+	spec := MessageContent{}
+	if d.Spec != nil {
+		spec = *d.Spec
+	}
+	if perHour, err := spec.requestsPerHour(job.Schedule); err == nil {
+		d.RequestsPerHour = perHour
+	} else {
+		d.Errors = append(d.Errors, "request rate: "+err.Error())
+	}
+
+	if d.Spec != nil {
+		scans, err := recentScans(ctx, spec)
+		if err != nil {
+			d.Errors = append(d.Errors, "scan history: "+err.Error())
+		}
+		d.Scans = scans
+		if entries, err := scanStore.LastScan(ctx, spec.Name); err == nil {
+			d.Snapshot.LastScanEntries = len(entries)
+		} else {
+			d.Errors = append(d.Errors, "last scan: "+err.Error())
+		}
+		if keys, err := stateStore.LastNotified(ctx, spec.Name); err == nil {
+			d.Snapshot.LastNotifiedKeys = len(keys)
+		} else {
+			d.Errors = append(d.Errors, "last alert: "+err.Error())
+		}
+	}
+	if p := DefaultScraper.Pacer; p != nil {
+		d.RateLimit.Interval = p.Interval.String()
+		if until := p.BlockedUntil(); !until.IsZero() {
+			until = until.UTC()
+			d.RateLimit.BlockedUntil = &until
+		}
+	}
+
+	if os.Getenv("ARCHIVE_BUCKET") != "" {
+		records, err := GetNotificationArchive(ctx, name.Job, DefaultScraper.now().Add(-7*24*time.Hour))
+		if err != nil {
+			d.Errors = append(d.Errors, "notification archive: "+err.Error())
+		}
+		for i := range records {
+			records[i].Body = ""
+		}
+		d.Notifications = records
+	}
+
+	out, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return []byte(Redact(string(out))), nil
+}
+
+// recentScans summarises the scrape history's scans of m's nights at each
+// of its campgrounds over the last week, newest first and at most
+// diagnosticScans of them. Without a history to read there are none.
+func recentScans(ctx context.Context, m MessageContent) ([]ScanSummary, error) {
+	if DefaultScraper.History == nil {
+		return nil, nil
+	}
+	reader, ok := DefaultScraper.History.Sink.(HistoryReader)
+	if !ok {
+		return nil, nil
+	}
+	since := DefaultScraper.now().Add(-7 * 24 * time.Hour)
+	start, end := m.span()
+	byScan := map[ScanSummary]*ScanSummary{}
+	for _, campgroundID := range m.campgrounds() {
+		rows, err := reader.ReadHistory(ctx, campgroundID, start.Time(), end.Time())
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if row.Backfilled || row.ScrapedAt.Before(since) {
+				continue
+			}
+			key := ScanSummary{At: row.ScrapedAt.UTC(), CampgroundID: campgroundID}
+			scan, ok := byScan[key]
+			if !ok {
+				scan = &ScanSummary{At: key.At, CampgroundID: campgroundID}
+				byScan[key] = scan
+			}
+			scan.SiteNights++
+			if core.ParseStatus(row.Status) == core.StatusAvailable {
+				scan.Available++
+			}
+		}
+	}
+	scans := make([]ScanSummary, 0, len(byScan))
+	for _, scan := range byScan {
+		scans = append(scans, *scan)
+	}
+	sort.Slice(scans, func(i, j int) bool {
+		if !scans[i].At.Equal(scans[j].At) {
+			return scans[i].At.After(scans[j].At)
+		}
+		return scans[i].CampgroundID < scans[j].CampgroundID
+	})
+	if len(scans) > diagnosticScans {
+		scans = scans[:diagnosticScans]
+	}
+	return scans, nil
+}
+
+// Diagnose is an HTTP Cloud Function answering GET ?watch=<job name> with
+// the watch's GenerateDiagnostics bundle, for admins looking into a report
+// that it is broken. Callers authenticate with "Authorization: Bearer
+// <ADMIN_API_KEY>". A job that does not exist is a 404.
+func Diagnose(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, http.StatusMethodNotAllowed, "use GET")
+		return
+	}
+	key := os.Getenv("ADMIN_API_KEY")
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(given)) != 1 {
+		writeProblem(w, http.StatusUnauthorized, "missing or wrong API key")
+		return
+	}
+	watch := r.URL.Query().Get("watch")
+	if _, err := ParseJobName(watch); err != nil {
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	bundle, err := GenerateDiagnostics(r.Context(), watch)
+	switch {
+	case errors.Is(err, ErrWatchNotFound):
+		writeProblem(w, http.StatusNotFound, err.Error())
+	case err != nil:
+		writeProblem(w, http.StatusInternalServerError, err.Error())
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(bundle)
+	}
+}
+
+// protoTimestamp is the getter half of a protobuf Timestamp, whose getters
+// are safe to call on nil.
+type protoTimestamp interface {
+	GetSeconds() int64
+	GetNanos() int32
+}
+
+func protoTime(ts protoTimestamp) *time.Time {
+	if ts.GetSeconds() == 0 && ts.GetNanos() == 0 {
+		return nil
+	}
+	t := time.Unix(ts.GetSeconds(), int64(ts.GetNanos())).UTC()
+	return &t
+}
+
+// looksLikeCredential reports whether a header name suggests its value is a
+// secret.
+func looksLikeCredential(header string) bool {
+	lower := strings.ToLower(header)
+	for _, word := range credentialHeaderWords {
+		if strings.Contains(lower, word) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,168 @@
+package scraper_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// A diagnostic bundle keeps a watch's ordinary header overrides but not the
+// values of those that look like credentials, nor its recipient's address.
+func TestGenerateDiagnosticsRedactsHeaders(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	t.Setenv("CONTACTS_API_KEY", "ck-live-2468")
+	m := e2eWatch("diagnose-headers")
+	m.NotifyEmail = "jane@example.com"
+	m.Headers = map[string]string{
+		"Authorization":   "Bearer abc123",
+		"Cookie":          "session=xyz",
+		"X-Api-Key":       "k-987",
+		"Accept-Language": "en-US",
+	}
+	data, _ := json.Marshal(m)
+	schedule(h, m.Name, data)
+
+	bundle, err := scraper.GenerateDiagnostics(context.Background(), m.Name)
+	if err != nil {
+		t.Fatalf("GenerateDiagnostics: %v", err)
+	}
+	var d scraper.Diagnostics
+	if err := json.Unmarshal(bundle, &d); err != nil {
+		t.Fatalf("decoding %s: %v", bundle, err)
+	}
+	if d.Spec == nil {
+		t.Fatalf("bundle has no spec:\n%s", bundle)
+	}
+	want := map[string]string{
+		"Authorization":   "[REDACTED]",
+		"Cookie":          "[REDACTED]",
+		"X-Api-Key":       "[REDACTED]",
+		"Accept-Language": "en-US",
+	}
+	for key, value := range want {
+		if got := d.Spec.Headers[key]; got != value {
+			t.Errorf("header %s = %q, want %q", key, got, value)
+		}
+	}
+	if d.Config["CONTACTS_API_KEY"] != "set" {
+		t.Errorf("CONTACTS_API_KEY reported as %q, want set", d.Config["CONTACTS_API_KEY"])
+	}
+	for _, secret := range []string{"abc123", "session=xyz", "k-987", "jane@example.com", "ck-live-2468"} {
+		if strings.Contains(string(bundle), secret) {
+			t.Errorf("bundle contains %q:\n%s", secret, bundle)
+		}
+	}
+}
+
+// A bundle for a registered watch that has run holds the registry's last
+// result with the reason it found nothing, its scans from the history, what
+// its next run compares with, and the settings it depends on.
+func TestGenerateDiagnosticsHistory(t *testing.T) {
+	f := e2eFixture(false)
+	f.Campgrounds[0].Sites[0].Nights = map[string]string{"2027-07-14": "Available"}
+	h := fakerecgov.Start(f)
+	defer h.Close()
+	store := &scraper.MemoryStore{}
+	defer scraper.UseServices(scraper.Services{Watches: store})()
+	sink := &scraper.MemorySink{}
+	scraper.DefaultScraper.History = &scraper.HistoryBatcher{Sink: sink}
+	t.Setenv("CONTROL_BUCKET", "control-bucket")
+	t.Setenv("SCRAPE_TIMEOUT", "45s")
+	ctx := context.Background()
+	store.PutWatch(ctx, scraper.WatchRecord{ID: "w1", Watch: e2eWatch("w1"), Status: scraper.WatchActive})
+	name := fakerecgov.JobName("diagnose-history")
+	schedule(h, name, []byte(`{"WatchID":"w1"}`))
+	for _, at := range []time.Time{
+		time.Date(2027, 5, 20, 12, 0, 0, 0, time.UTC),
+		time.Date(2027, 6, 1, 11, 0, 0, 0, time.UTC),
+		time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC),
+	} {
+		h.Clock.Set(at)
+		if err := h.Fire(ctx, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	h.Store.SetLastScan(ctx, "w1", []string{"1001", "1002"})
+
+	bundle, err := scraper.GenerateDiagnostics(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d scraper.Diagnostics
+	if err := json.Unmarshal(bundle, &d); err != nil {
+		t.Fatalf("decoding %s: %v", bundle, err)
+	}
+	if d.LastResult == nil || !strings.Contains(d.LastResult.NoMatch, "3 sites") {
+		t.Errorf("last result %+v, want why none of the 3 sites matched", d.LastResult)
+	}
+	// Three sites for two nights, one of them open, at the two scans of the
+	// last week.
+	want := []scraper.ScanSummary{
+		{At: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC), CampgroundID: "232447", SiteNights: 6, Available: 1},
+		{At: time.Date(2027, 6, 1, 11, 0, 0, 0, time.UTC), CampgroundID: "232447", SiteNights: 6, Available: 1},
+	}
+	if len(d.Scans) != len(want) {
+		t.Fatalf("scans %+v, want %+v", d.Scans, want)
+	}
+	for i := range want {
+		if !d.Scans[i].At.Equal(want[i].At) || d.Scans[i].CampgroundID != want[i].CampgroundID ||
+			d.Scans[i].SiteNights != want[i].SiteNights || d.Scans[i].Available != want[i].Available {
+			t.Errorf("scan %d %+v, want %+v", i, d.Scans[i], want[i])
+		}
+	}
+	if d.Snapshot.LastScanEntries != 2 {
+		t.Errorf("snapshot %+v, want the last scan's 2 entries", d.Snapshot)
+	}
+	if d.RateLimit.Interval == "" || d.RateLimit.BlockedUntil != nil {
+		t.Errorf("rate limit %+v, want an interval and no block", d.RateLimit)
+	}
+	if d.Config["CONTROL_BUCKET"] != "control-bucket" || d.Config["SCRAPE_TIMEOUT"] != "45s" {
+		t.Errorf("config %v, want CONTROL_BUCKET and SCRAPE_TIMEOUT", d.Config)
+	}
+}
+
+// Diagnose serves bundles to admins holding ADMIN_API_KEY.
+func TestDiagnose(t *testing.T) {
+	tests := []struct {
+		name     string
+		target   string
+		key      string
+		wantCode int
+		wantBody string
+	}{
+		{name: "no key", target: "/?watch=" + fakerecgov.JobName("diagnose-http"), key: "wrong", wantCode: 401},
+		{name: "bundle", target: "/?watch=" + fakerecgov.JobName("diagnose-http"), wantCode: 200, wantBody: `"Campground": "232447"`},
+		{name: "no watch", target: "/", wantCode: 400},
+		{name: "missing", target: "/?watch=" + fakerecgov.JobName("diagnose-missing"), wantCode: 404},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("ADMIN_API_KEY", "test-key")
+			h := fakerecgov.Start(e2eFixture(false))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("diagnose-http")
+			data, _ := json.Marshal(m)
+			schedule(h, m.Name, data)
+			r := httptest.NewRequest("GET", test.target, nil)
+			key := test.key
+			if key == "" {
+				key = "test-key"
+			}
+			r.Header.Set("Authorization", "Bearer "+key)
+			w := httptest.NewRecorder()
+			scraper.Diagnose(w, r)
+			if w.Code != test.wantCode || !strings.Contains(w.Body.String(), test.wantBody) {
+				t.Errorf("got %d %s, want %d containing %s", w.Code, w.Body, test.wantCode, test.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"cloud.google.com/go/storage"
+)
+
+// scanPrefix is where watches tracking changes keep the sites their last
+// scan found in RESULTS_BUCKET.
+const scanPrefix = "scans/"
+
+// ScanStore keeps, per watch, the sites its last complete scan found, so the
+// next scan can tell which sites have just opened.
+type ScanStore interface {
+	// LastScan returns the sites last stored for job, or none.
+	LastScan(ctx context.Context, job string) ([]string, error)
+	SetLastScan(ctx context.Context, job string, sites []string) error
+	// DeleteScan forgets job, once its watch is deleted.
+	DeleteScan(ctx context.Context, job string) error
+}
+
+// AvailabilityDiff compares the sites a scan found with those the previous
+// scan found. Each list is sorted.
+type AvailabilityDiff struct {
+	NewlyAvailable    []string
+	StillAvailable    []string
+	NoLongerAvailable []string
+}
+
+// diffSites returns how current differs from previous.
+func diffSites(previous []string, current []string) AvailabilityDiff {
+	d := AvailabilityDiff{NewlyAvailable: []string{}, StillAvailable: []string{}, NoLongerAvailable: []string{}}
+	before, now := map[string]bool{}, map[string]bool{}
+	for _, site := range previous {
+		before[site] = true
+	}
+	for _, site := range current {
+		now[site] = true
+		if before[site] {
+			d.StillAvailable = append(d.StillAvailable, site)
+		} else {
+			d.NewlyAvailable = append(d.NewlyAvailable, site)
+		}
+	}
+	for _, site := range previous {
+		if !now[site] {
+			d.NoLongerAvailable = append(d.NoLongerAvailable, site)
+		}
+	}
+	sort.Strings(d.NewlyAvailable)
+	sort.Strings(d.StillAvailable)
+	sort.Strings(d.NoLongerAvailable)
+	return d
+}
+
+// isNew reports whether site opened since the previous scan. It is false
+// for a nil diff.
+func (d *AvailabilityDiff) isNew(site string) bool {
+	if d == nil {
+		return false
+	}
+	for _, s := range d.NewlyAvailable {
+		if s == site {
+			return true
+		}
+	}
+	return false
+}
+
+// summary leads an alert, such as "Newly opened since the last check: 12,
+// 14. No longer available: 3."
+func (d AvailabilityDiff) summary() string {
+	text := "Nothing newly opened since the last check."
+	if len(d.NewlyAvailable) > 0 {
+		text = "Newly opened since the last check: " + strings.Join(d.NewlyAvailable, ", ") + "."
+	}
+	if len(d.NoLongerAvailable) > 0 {
+		text += " No longer available: " + strings.Join(d.NoLongerAvailable, ", ") + "."
+	}
+	return text
+}
+
+// tracksChanges reports whether the watch compares each scan with the last.
+func (m MessageContent) tracksChanges() bool {
+	return m.TrackChanges || m.OnlyNewlyAvailable
+}
+
+// diffScan compares a's sites with the watch's last scan and stores them as
+// the new last scan, unless the scan was incomplete or this is a dry run. A
+// store that cannot be read is logged and treated as empty, so every site
+// counts as new rather than the alert being lost.
+func diffScan(ctx context.Context, m MessageContent, a alert, save bool) AvailabilityDiff {
+	current := []string{}
+	for _, site := range a.Sites {
+		if site != rehearsalLabel {
+			current = append(current, site)
+		}
+	}
+	previous, err := scanStore.LastScan(ctx, m.Name)
+	if err != nil {
+		logger.Printf("job %s: reading last scan: %v", m.Name, err)
+	}
+	d := diffSites(previous, current)
+	if save && a.Partial == nil {
+		if err := scanStore.SetLastScan(ctx, m.Name, current); err != nil {
+			logger.Printf("job %s: saving scan: %v", m.Name, err)
+		}
+	}
+	return d
+}
+
+// leadWithNew reorders sites so newly opened ones come first, keeping the
+// order within each group.
+func leadWithNew(sites []string, d AvailabilityDiff) []string {
+	isNew := map[string]bool{}
+	for _, site := range d.NewlyAvailable {
+		isNew[site] = true
+	}
+	ordered := []string{}
+	for _, site := range sites {
+		if isNew[site] {
+			ordered = append(ordered, site)
+		}
+	}
+	for _, site := range sites {
+		if !isNew[site] {
+			ordered = append(ordered, site)
+		}
+	}
+	return ordered
+}
+
+// pruneJobState drops a deleted watch's last scan, delivery state and last
+// notified state. Failures are only logged; the objects are small and
+// harmless.
+func pruneJobState(ctx context.Context, job string) {
+	if err := scanStore.DeleteScan(ctx, job); err != nil {
+		logger.Printf("job %s: deleting last scan: %v", job, err)
+	}
+	if err := deliveryStore.DeleteDeliveryState(ctx, job); err != nil {
+		logger.Printf("job %s: deleting delivery state: %v", job, err)
+	}
+	if err := stateStore.DeleteLastNotified(ctx, job); err != nil {
+		logger.Printf("job %s: deleting last notified state: %v", job, err)
+	}
+}
+
+// LastScan implements ScanStore.
+func (s *MemoryStore) LastScan(ctx context.Context, job string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.scans[job], nil
+}
+
+// SetLastScan implements ScanStore.
+func (s *MemoryStore) SetLastScan(ctx context.Context, job string, sites []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.scans == nil {
+		s.scans = map[string][]string{}
+	}
+	s.scans[job] = append([]string(nil), sites...)
+	return nil
+}
+
+// DeleteScan implements ScanStore.
+func (s *MemoryStore) DeleteScan(ctx context.Context, job string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.scans, job)
+	return nil
+}
+
+// LastScan implements ScanStore.
+func (s BucketStore) LastScan(ctx context.Context, job string) ([]string, error) {
+	client, err := storageClient()
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Bucket(s.Bucket).Object(scanPrefix + job + ".json").NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var sites []string
+	if err := json.NewDecoder(r).Decode(&sites); err != nil {
+		return nil, fmt.Errorf("decoding last scan for %s: %v", job, err)
+	}
+	return sites, nil
+}
+
+// SetLastScan implements ScanStore.
+func (s BucketStore) SetLastScan(ctx context.Context, job string, sites []string) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	w := client.Bucket(s.Bucket).Object(scanPrefix + job + ".json").NewWriter(ctx)
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(sites); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// DeleteScan implements ScanStore.
+func (s BucketStore) DeleteScan(ctx context.Context, job string) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	err = client.Bucket(s.Bucket).Object(scanPrefix + job + ".json").Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+// scanStore is where watches tracking changes keep their last scan:
+// RESULTS_BUCKET when set, memory otherwise, as for stateStore. Replace it
+// in tests.
+var scanStore ScanStore = defaultScanStore()
+
+func defaultScanStore() ScanStore {
+	if bucket := os.Getenv("RESULTS_BUCKET"); bucket != "" {
+		return BucketStore{Bucket: bucket}
+	}
+	return &MemoryStore{}
+}
@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// Three scrapes of one watch: both sites open, then both booked, then one
+// reopened by a cancellation.
+func TestDiffScanThreeScrapes(t *testing.T) {
+	old := scanStore
+	scanStore = &MemoryStore{}
+	defer func() { scanStore = old }()
+	ctx := context.Background()
+	m := MessageContent{Name: "camp-232447-diff", TrackChanges: true}
+	scans := []struct {
+		sites       []string
+		want        AvailabilityDiff
+		wantSummary string
+	}{
+		{[]string{"1002", "1001"}, AvailabilityDiff{NewlyAvailable: []string{"1001", "1002"}, StillAvailable: []string{}, NoLongerAvailable: []string{}},
+			"Newly opened since the last check: 1001, 1002."},
+		{nil, AvailabilityDiff{NewlyAvailable: []string{}, StillAvailable: []string{}, NoLongerAvailable: []string{"1001", "1002"}},
+			"Nothing newly opened since the last check. No longer available: 1001, 1002."},
+		{[]string{"1002"}, AvailabilityDiff{NewlyAvailable: []string{"1002"}, StillAvailable: []string{}, NoLongerAvailable: []string{}},
+			"Newly opened since the last check: 1002."},
+	}
+	for i, scan := range scans {
+		d := diffScan(ctx, m, alert{Sites: scan.sites}, true)
+		if !reflect.DeepEqual(d, scan.want) {
+			t.Errorf("scan %d: diff %+v, want %+v", i+1, d, scan.want)
+		}
+		if got := d.summary(); got != scan.wantSummary {
+			t.Errorf("scan %d: summary %q, want %q", i+1, got, scan.wantSummary)
+		}
+	}
+
+	// A fourth scan still finding 1002 and newly 1001 leads with 1001.
+	d := diffScan(ctx, m, alert{Sites: []string{"1002", "1001"}}, true)
+	if want := (AvailabilityDiff{NewlyAvailable: []string{"1001"}, StillAvailable: []string{"1002"}, NoLongerAvailable: []string{}}); !reflect.DeepEqual(d, want) {
+		t.Errorf("scan 4: diff %+v, want %+v", d, want)
+	}
+	if got := leadWithNew([]string{"1002", "1001"}, d); !reflect.DeepEqual(got, []string{"1001", "1002"}) {
+		t.Errorf("leadWithNew() = %v, want 1001 first", got)
+	}
+}
+
+// Dry runs, partial scans and rehearsal labels leave the stored scan alone.
+func TestDiffScanNotSaved(t *testing.T) {
+	old := scanStore
+	store := &MemoryStore{}
+	scanStore = store
+	defer func() { scanStore = old }()
+	ctx := context.Background()
+	m := MessageContent{Name: "camp-232447-diff-unsaved", TrackChanges: true}
+	diffScan(ctx, m, alert{Sites: []string{rehearsalLabel, "1001"}}, true)
+	diffScan(ctx, m, alert{Sites: []string{"1002"}}, false)
+	diffScan(ctx, m, alert{Sites: []string{"1003"}, Partial: &core.PartialResultError{Checked: 1, Total: 3}}, true)
+	if got, _ := store.LastScan(ctx, m.Name); !reflect.DeepEqual(got, []string{"1001"}) {
+		t.Errorf("last scan %v, want [1001]", got)
+	}
+}
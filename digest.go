@@ -0,0 +1,238 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+// DigestWatch is one watch's line in the daily digest.
+type DigestWatch struct {
+	Job       string
+	Arrival   time.Time
+	Departure time.Time
+	// DaysUntil counts days from today to arrival, negative once it has
+	// passed.
+	DaysUntil int
+	Sites     []string
+	Paused    bool
+	// Error says why the watch could not be checked.
+	Error string
+}
+
+// DigestGroup holds the watches on one campground, or on one set of
+// campgrounds for a multi-campground watch.
+type DigestGroup struct {
+	Campground string
+	Watches    []DigestWatch
+}
+
+// Digest is the status of every watch, grouped by campground. Watches are
+// ordered by arrival within a group, and groups by their first arrival.
+type Digest struct {
+	Groups []DigestGroup
+}
+
+// BuildDigest checks every watch in the configured location once, at most
+// maxConcurrentCampgrounds at a time, using DefaultScraper. It only reads:
+// no job is deleted and no watch's own alerts are sent. Paused jobs are
+// listed without being checked.
+func BuildDigest(ctx context.Context) (Digest, error) {
+	s, err := newWatchScheduler()
+	if err != nil {
+		return Digest{}, err
+	}
+	jobs, err := s.ListJobs(ctx, activeConfig.Parent())
+	if err != nil {
+		return Digest{}, fmt.Errorf("listing jobs in %s: %v", activeConfig.Parent(), err)
+	}
+	store := newWatchStore(activeConfig)
+	watches := []MessageContent{}
+	paused := map[string]bool{}
+	for _, job := range jobs {
+		target := job.GetPubsubTarget()
+		if target == nil {
+			continue
+		}
+		m, status, ok := watchFromJob(ctx, store, target.Data)
+		if !ok || status == WatchCompleted {
+			continue
+		}
+		watches = append(watches, m)
+		paused[m.Name] = job.State == schedulerpb.Job_PAUSED || status == WatchPaused
+	}
+
+	lines := make([]DigestWatch, len(watches))
+	slots := make(chan struct{}, maxConcurrentCampgrounds)
+	var wg sync.WaitGroup
+	for i, m := range watches {
+		wg.Add(1)
+		go func(i int, m MessageContent) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			lines[i] = checkForDigest(ctx, m, paused[m.Name])
+		}(i, m)
+	}
+	wg.Wait()
+
+	groups := map[string][]DigestWatch{}
+	for i, m := range watches {
+		key := strings.Join(m.campgrounds(), ", ")
+		if key == "" {
+			key = m.CampgroundName
+		}
+		groups[key] = append(groups[key], lines[i])
+	}
+	return groupDigest(groups), nil
+}
+
+// checkForDigest runs one watch's match without any of its side effects.
+func checkForDigest(ctx context.Context, m MessageContent, paused bool) DigestWatch {
+	arrival := stayDate(m.Arrival)
+	line := DigestWatch{
+		Job:       m.Name,
+		Arrival:   arrival.Time(),
+		Departure: stayDate(m.Departure).Time(),
+		DaysUntil: core.CivilDateOf(DefaultScraper.now().UTC()).DaysUntil(arrival),
+		Sites:     []string{},
+		Paused:    paused,
+	}
+	if paused || m.isReservationGuard() {
+		// A guard's stay is booked; it has no openings to list.
+		return line
+	}
+	if err := ctx.Err(); err != nil {
+		line.Error = err.Error()
+		return line
+	}
+	if err := m.resolveCampground(ctx); err != nil {
+		line.Error = err.Error()
+		return line
+	}
+	var a alert
+	var err error
+	switch {
+	case m.isPermit():
+		a, err = DefaultScraper.matchPermit(ctx, m, false)
+	case m.isTour():
+		a, err = DefaultScraper.matchTour(ctx, m, false)
+	case m.isSiteMonitor():
+		a, _, err = DefaultScraper.matchSiteMonitor(ctx, m, false)
+	case len(m.Campgrounds) > 0:
+		a, err = DefaultScraper.matchCampgrounds(ctx, m, false)
+	default:
+		a, err = DefaultScraper.matchWatch(ctx, DefaultScraper.providerFor(m), m, false)
+	}
+	var partial *core.PartialResultError
+	if err != nil && !errors.As(err, &partial) {
+		line.Error = err.Error()
+	}
+	if a.Sites != nil {
+		line.Sites = a.Sites
+	}
+	return line
+}
+
+// groupDigest orders watches by arrival, then job name, within each group,
+// and groups by their first watch.
+func groupDigest(groups map[string][]DigestWatch) Digest {
+	d := Digest{Groups: []DigestGroup{}}
+	for campground, watches := range groups {
+		sort.Slice(watches, func(i, j int) bool {
+			if !watches[i].Arrival.Equal(watches[j].Arrival) {
+				return watches[i].Arrival.Before(watches[j].Arrival)
+			}
+			return watches[i].Job < watches[j].Job
+		})
+		d.Groups = append(d.Groups, DigestGroup{Campground: campground, Watches: watches})
+	}
+	sort.Slice(d.Groups, func(i, j int) bool {
+		a, b := d.Groups[i].Watches[0], d.Groups[j].Watches[0]
+		if !a.Arrival.Equal(b.Arrival) {
+			return a.Arrival.Before(b.Arrival)
+		}
+		return d.Groups[i].Campground < d.Groups[j].Campground
+	})
+	return d
+}
+
+// status is the watch's state in a few words.
+func (w DigestWatch) status() string {
+	switch {
+	case w.Paused:
+		return "paused"
+	case w.Error != "":
+		return "error: " + w.Error
+	case len(w.Sites) == 0:
+		return "nothing open"
+	}
+	return fmt.Sprintf("%d open: %s", len(w.Sites), strings.Join(w.Sites, ", "))
+}
+
+// days is DaysUntil for people, such as "in 12 days".
+func (w DigestWatch) days() string {
+	switch {
+	case w.DaysUntil == 0:
+		return "today"
+	case w.DaysUntil == 1:
+		return "tomorrow"
+	case w.DaysUntil < 0:
+		return "passed"
+	}
+	return fmt.Sprintf("in %d days", w.DaysUntil)
+}
+
+// Render returns the digest email's subject, plain text and HTML.
+func (d Digest) Render() (string, string, string) {
+	watches, found := 0, 0
+	for _, g := range d.Groups {
+		for _, w := range g.Watches {
+			watches++
+			if len(w.Sites) > 0 {
+				found++
+			}
+		}
+	}
+	subject := fmt.Sprintf("Daily watch digest: %d of %d watches have open sites", found, watches)
+	if watches == 0 {
+		return subject, "There are no active watches.", "<p>There are no active watches.</p>"
+	}
+	lines := []string{}
+	out := ""
+	for _, g := range d.Groups {
+		lines = append(lines, "Campground "+g.Campground+":")
+		out += "<h3>Campground " + html.EscapeString(g.Campground) + "</h3>" +
+			`<table><thead><tr><th scope="col">Watch</th><th scope="col">Dates</th>` +
+			`<th scope="col">Arrival</th><th scope="col">Status</th></tr></thead><tbody>`
+		for _, w := range g.Watches {
+			dates := w.Arrival.Format("Mon Jan 2") + " to " + w.Departure.Format("Mon Jan 2")
+			lines = append(lines, fmt.Sprintf("  %s, %s (%s): %s", w.Job, dates, w.days(), w.status()))
+			out += fmt.Sprintf(`<tr><th scope="row">%s</th><td>%s</td><td>%s</td><td>%s</td></tr>`,
+				html.EscapeString(w.Job), html.EscapeString(dates), html.EscapeString(w.days()), html.EscapeString(w.status()))
+		}
+		out += "</tbody></table>"
+	}
+	return subject, strings.Join(lines, "\n"), out
+}
+
+// DailyDigest is a Pub/Sub Cloud Function emailing BuildDigest to the
+// deployment's default recipient, for triggering from its own morning
+// schedule.
+func DailyDigest(ctx context.Context, m pubsub.Message) error {
+	d, err := BuildDigest(ctx)
+	if err != nil {
+		return err
+	}
+	subject, plain, htmlContent := d.Render()
+	return sendNotice(ctx, subject, plain, htmlContent)
+}
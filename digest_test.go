@@ -0,0 +1,93 @@
+package scraper_test
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+// addDigestJob schedules m as id, paused when paused is set.
+func addDigestJob(h *fakerecgov.Harness, id string, m scraper.MessageContent, paused bool) {
+	m.Name = fakerecgov.JobName(id)
+	job := &schedulerpb.Job{
+		Name:   m.Name,
+		Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{Data: fakerecgov.Message(m).Data}},
+	}
+	if paused {
+		job.State = schedulerpb.Job_PAUSED
+	}
+	h.Scheduler.AddJob(job)
+}
+
+// The digest groups watches by campground, orders each group by arrival
+// and the groups by their first arrival, and only reads: nothing is alerted
+// or deleted.
+func TestDailyDigest(t *testing.T) {
+	h := fakerecgov.Start(multiFixture("open", "reserved"))
+	defer h.Close()
+	defer scraper.UseConfig(testConfig)()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	stay := func(campground, arrival, departure string) scraper.MessageContent {
+		return scraper.MessageContent{Campground: campground, Arrival: arrival, Departure: departure}
+	}
+	addDigestJob(h, "upper-mid-july", stay("232447", "2027-07-14", "2027-07-16"), false)
+	addDigestJob(h, "lower-late-july", stay("232450", "2027-07-20", "2027-07-22"), true)
+	addDigestJob(h, "upper-early-july", stay("232447", "2027-07-01", "2027-07-03"), false)
+	addDigestJob(h, "lower-mid-july", stay("232450", "2027-07-10", "2027-07-12"), false)
+	ctx := context.Background()
+
+	d, err := scraper.BuildDigest(ctx)
+	if err != nil {
+		t.Fatalf("BuildDigest: %v", err)
+	}
+	got := map[string][]string{}
+	order := []string{}
+	for _, g := range d.Groups {
+		order = append(order, g.Campground)
+		for _, w := range g.Watches {
+			got[g.Campground] = append(got[g.Campground], strings.TrimPrefix(w.Job, fakerecgov.JobName("")))
+		}
+	}
+	want := map[string][]string{
+		"232447": {"upper-early-july", "upper-mid-july"},
+		"232450": {"lower-mid-july", "lower-late-july"},
+	}
+	if !reflect.DeepEqual(order, []string{"232447", "232450"}) || !reflect.DeepEqual(got, want) {
+		t.Errorf("groups %v with watches %v, want %v in that order", order, got, want)
+	}
+	upper, lower := d.Groups[0].Watches, d.Groups[1].Watches
+	if upper[0].DaysUntil != 30 || len(upper[0].Sites) != 0 || strings.Join(upper[1].Sites, ",") != "1001" {
+		t.Errorf("upper watches %+v, want early July in 30 days with nothing open and mid July with 1001", upper)
+	}
+	if lower[0].Paused || !lower[1].Paused || lower[1].Error != "" {
+		t.Errorf("lower watches %+v, want only late July paused", lower)
+	}
+
+	if err := scraper.DailyDigest(ctx, pubsub.Message{}); err != nil {
+		t.Fatalf("DailyDigest: %v", err)
+	}
+	notices := h.Notifier.Notices()
+	if len(notices) != 1 || notices[0].Subject != "Daily watch digest: 1 of 4 watches have open sites" {
+		t.Fatalf("got notices %+v, want one digest", notices)
+	}
+	plain := notices[0].Plain
+	lines := []string{"Campground 232447:", "upper-early-july", "upper-mid-july", "1 open: 1001", "Campground 232450:", "lower-mid-july", "lower-late-july", "paused"}
+	at := 0
+	for _, line := range lines {
+		i := strings.Index(plain[at:], line)
+		if i < 0 {
+			t.Fatalf("digest %q lacks %q after byte %d", plain, line, at)
+		}
+		at += i + len(line)
+	}
+	if alerts, deleted := h.Notifier.Alerts(), h.Scheduler.Deleted(); len(alerts) != 0 || len(deleted) != 0 {
+		t.Errorf("digest alerted %+v and deleted %v", alerts, deleted)
+	}
+}
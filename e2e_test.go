@@ -0,0 +1,739 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// e2eFixture is one campground with one site open on the nights of
+// e2eWatch's stay, when open is set, and the others reserved throughout.
+// Every test in the package uses its details, since the scraper caches them
+// for the life of the process.
+func e2eFixture(open bool) fakerecgov.Fixture {
+	nights := map[string]string{}
+	if open {
+		nights = map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}
+	}
+	return fakerecgov.Fixture{Campgrounds: []fakerecgov.Campground{{
+		ID:         "232447",
+		Name:       "Upper Pines",
+		NightlyFee: 36,
+		Sites: []fakerecgov.Site{
+			{ID: "1001", Site: "A001", Loop: "A", Type: "STANDARD NONELECTRIC", Nights: nights},
+			{ID: "1002", Site: "A002", Loop: "A", Type: "TENT ONLY NONELECTRIC", NightlyFee: 26},
+			{ID: "1003", Site: "G01", Loop: "Group", Type: "GROUP STANDARD NONELECTRIC"},
+		},
+	}}}
+}
+
+func e2eWatch(id string) scraper.MessageContent {
+	return scraper.MessageContent{
+		Name:       fakerecgov.JobName(id),
+		Campground: "232447",
+		Arrival:    "2027-07-14",
+		Departure:  "2027-07-16",
+	}
+}
+
+func TestEndToEnd(t *testing.T) {
+	tests := []struct {
+		name string
+		open bool
+		now  time.Time
+		// wantAlerts lists the site IDs of each alert sent.
+		wantAlerts  [][]string
+		wantNotice  string
+		wantDeleted bool
+		wantMonths  []string
+	}{
+		{
+			name:        "found",
+			open:        true,
+			now:         time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC),
+			wantAlerts:  [][]string{{"1001"}},
+			wantDeleted: true,
+			wantMonths:  []string{"2027-07"},
+		},
+		{
+			name:       "not found",
+			now:        time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC),
+			wantMonths: []string{"2027-07"},
+		},
+		{
+			name:        "expired",
+			open:        true,
+			now:         time.Date(2027, 7, 20, 12, 0, 0, 0, time.UTC),
+			wantNotice:  "expired without availability",
+			wantDeleted: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(test.open))
+			defer h.Close()
+			h.Clock.Set(test.now)
+			m := e2eWatch("e2e-" + strings.Replace(test.name, " ", "-", -1))
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+
+			alerts := h.Notifier.Alerts()
+			if len(alerts) != len(test.wantAlerts) {
+				t.Fatalf("got %d alerts, want %d", len(alerts), len(test.wantAlerts))
+			}
+			for i, alert := range alerts {
+				ids := []string{}
+				for _, site := range alert.Sites {
+					ids = append(ids, site.ID)
+				}
+				if strings.Join(ids, ",") != strings.Join(test.wantAlerts[i], ",") {
+					t.Errorf("alert %d has sites %v, want %v", i, ids, test.wantAlerts[i])
+				}
+				if alert.Job != m.Name || alert.CampgroundID != m.Campground {
+					t.Errorf("alert %d is for job %s at %s", i, alert.Job, alert.CampgroundID)
+				}
+			}
+
+			notices := h.Notifier.Notices()
+			if test.wantNotice == "" && len(notices) != 0 {
+				t.Errorf("got notices %+v, want none", notices)
+			}
+			if test.wantNotice != "" && (len(notices) != 1 || !strings.Contains(notices[0].Subject, test.wantNotice)) {
+				t.Errorf("got notices %+v, want one about %q", notices, test.wantNotice)
+			}
+
+			deleted := len(h.Scheduler.Deleted()) == 1 && h.Scheduler.Job(m.Name) == nil
+			if deleted != test.wantDeleted {
+				t.Errorf("job deleted = %v, want %v (deleted %v)", deleted, test.wantDeleted, h.Scheduler.Deleted())
+			}
+			if months := h.Server.MonthsFetched("232447"); strings.Join(months, ",") != strings.Join(test.wantMonths, ",") {
+				t.Errorf("fetched months %v, want %v", months, test.wantMonths)
+			}
+		})
+	}
+}
+
+func TestEndToEndFoundAfterNotFound(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := e2eWatch("e2e-later")
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("first run: %v", err)
+	}
+	if n := len(h.Notifier.Alerts()); n != 0 {
+		t.Fatalf("got %d alerts before the site opened", n)
+	}
+	h.Server.SetFixture(e2eFixture(true))
+	h.Clock.Advance(5 * time.Minute)
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("second run: %v", err)
+	}
+	if n := len(h.Notifier.Alerts()); n != 1 {
+		t.Fatalf("got %d alerts after the site opened, want 1", n)
+	}
+	if h.Scheduler.Job(m.Name) != nil {
+		t.Errorf("job %s still scheduled after its alert", m.Name)
+	}
+}
+
+// A stay from July 30 to August 2 fetches both months and merges them, so
+// only a site open on every night of both is alerted; a stay departing
+// August 1 fetches July alone.
+func TestEndToEndAcrossMonths(t *testing.T) {
+	tests := []struct {
+		name       string
+		departure  string
+		wantMonths string
+		wantSites  string
+	}{
+		{name: "into august", departure: "2027-08-02", wantMonths: "2027-07,2027-08", wantSites: "1001"},
+		{name: "departing the first", departure: "2027-08-01", wantMonths: "2027-07", wantSites: "1001,1002"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fixture := e2eFixture(false)
+			sites := fixture.Campgrounds[0].Sites
+			sites[0].Nights = map[string]string{"2027-07-30": "Available", "2027-07-31": "Available", "2027-08-01": "Available"}
+			sites[1].Nights = map[string]string{"2027-07-30": "Available", "2027-07-31": "Available"}
+			sites[2].Nights = map[string]string{"2027-07-31": "Available", "2027-08-01": "Available"}
+			h := fakerecgov.Start(fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("e2e-months-" + strings.Replace(test.name, " ", "-", -1))
+			m.Arrival, m.Departure = "2027-07-30", test.departure
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := strings.Join(h.Server.MonthsFetched("232447"), ","); got != test.wantMonths {
+				t.Errorf("fetched months %s, want %s", got, test.wantMonths)
+			}
+			alerts := h.Notifier.Alerts()
+			if len(alerts) != 1 {
+				t.Fatalf("got %d alerts, want 1", len(alerts))
+			}
+			got := []string{}
+			for _, site := range alerts[0].Sites {
+				got = append(got, site.ID)
+			}
+			sort.Strings(got)
+			if strings.Join(got, ",") != test.wantSites {
+				t.Errorf("alerted sites %v, want %s", got, test.wantSites)
+			}
+		})
+	}
+}
+
+// Nights match by calendar date however recreation.gov offsets their keys,
+// including across daylight saving changes, and a watch arriving today by
+// its own time zone still runs once UTC has moved on to tomorrow.
+func TestEndToEndTimeZones(t *testing.T) {
+	tests := []struct {
+		name               string
+		keyZone            string
+		arrival, departure string
+		now                time.Time
+		timeZone           string
+	}{
+		{name: "pacific keys", keyZone: "America/Los_Angeles", arrival: "2027-07-14", departure: "2027-07-16",
+			now: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "keys ahead of UTC", keyZone: "Pacific/Auckland", arrival: "2027-07-14", departure: "2027-07-16",
+			now: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "fall back", keyZone: "America/Los_Angeles", arrival: "2027-11-06", departure: "2027-11-08",
+			now: time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "spring forward", keyZone: "America/Los_Angeles", arrival: "2027-03-13", departure: "2027-03-15",
+			now: time.Date(2027, 2, 1, 12, 0, 0, 0, time.UTC)},
+		{name: "arriving today behind UTC", keyZone: "America/Los_Angeles", arrival: "2027-07-14", departure: "2027-07-16",
+			now: time.Date(2027, 7, 15, 5, 0, 0, 0, time.UTC), timeZone: "America/Los_Angeles"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := time.LoadLocation(test.keyZone); err != nil {
+				t.Skip(err)
+			}
+			fixture := e2eFixture(false)
+			fixture.Campgrounds[0].KeyZone = test.keyZone
+			arrival, _ := core.ParseCivilDate(test.arrival)
+			fixture.Campgrounds[0].Sites[0].Nights = map[string]string{arrival.String(): "Available", arrival.Next().String(): "Available"}
+			fixture.Campgrounds[0].Sites[1].Nights = map[string]string{arrival.Next().String(): "Available"}
+			h := fakerecgov.Start(fixture)
+			defer h.Close()
+			h.Clock.Set(test.now)
+			m := e2eWatch("e2e-zone-" + strings.Replace(test.name, " ", "-", -1))
+			m.Arrival, m.Departure, m.TimeZone = test.arrival, test.departure, test.timeZone
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			alerts := h.Notifier.Alerts()
+			if len(alerts) != 1 || len(alerts[0].Sites) != 1 || alerts[0].Sites[0].ID != "1001" {
+				t.Fatalf("got alerts %+v, want one for site 1001", alerts)
+			}
+			if alerts[0].Arrival != test.arrival || alerts[0].Departure != test.departure {
+				t.Errorf("alert for %s to %s, want %s to %s", alerts[0].Arrival, alerts[0].Departure, test.arrival, test.departure)
+			}
+		})
+	}
+}
+
+func TestNotifyByEmail(t *testing.T) {
+	t.Setenv("SENDGRID_API_KEY", "test")
+	t.Setenv("FROM_EMAIL", "campfinder@example.com")
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	arrival := time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC)
+	sites, err := scraper.NotifyByEmail(context.Background(), "232447", arrival, arrival.AddDate(0, 0, 2), "me@example.com")
+	if err != nil {
+		t.Fatalf("NotifyByEmail: %v", err)
+	}
+	if len(sites) != 1 || sites[0] != "1001" {
+		t.Errorf("NotifyByEmail found %v, want [1001]", sites)
+	}
+	notices := h.Notifier.Notices()
+	if len(notices) != 1 || notices[0].To != "me@example.com" || !strings.Contains(notices[0].Plain, "A001") {
+		t.Errorf("sent %+v, want one email to me@example.com listing A001", notices)
+	}
+}
+
+// A failed scrape is returned so Pub/Sub redelivers the message, rather than
+// read as no availability.
+func TestEndToEndScrapeFailure(t *testing.T) {
+	for _, status := range []int{403, 500} {
+		t.Run(fmt.Sprint(status), func(t *testing.T) {
+			f := e2eFixture(true)
+			f.Campgrounds[0].FailStatus = status
+			h := fakerecgov.Start(f)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch(fmt.Sprintf("e2e-fail-%d", status))
+
+			err := h.Run(context.Background(), m)
+			var statusErr *core.StatusError
+			if !errors.As(err, &statusErr) || statusErr.StatusCode != status {
+				t.Fatalf("Run error = %v, want a %d *core.StatusError", err, status)
+			}
+			if n := len(h.Notifier.Alerts()); n != 0 {
+				t.Errorf("got %d alerts from a failed scrape", n)
+			}
+			if h.Scheduler.Job(m.Name) == nil {
+				t.Errorf("job %s deleted after a failed scrape", m.Name)
+			}
+		})
+	}
+}
+
+// Two watches on one campground scraped within the cache's TTL share one
+// request for its month; once the TTL passes the month is fetched again.
+func TestEndToEndMonthCache(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	scraper.DefaultScraper.Cache = &core.MemoryCache{TTL: core.DefaultCacheTTL, Clock: h.Clock}
+	ctx := context.Background()
+
+	runs := []struct {
+		watch string
+		after time.Duration
+		want  int
+	}{
+		{"e2e-cache-first", 0, 1},
+		{"e2e-cache-second", time.Minute, 1},
+		{"e2e-cache-third", core.DefaultCacheTTL, 2},
+	}
+	for _, run := range runs {
+		h.Clock.Advance(run.after)
+		if err := h.Run(ctx, e2eWatch(run.watch)); err != nil {
+			t.Fatalf("%s: %v", run.watch, err)
+		}
+		if got := len(h.Server.MonthsFetched("232447")); got != run.want {
+			t.Errorf("after %s the month was fetched %d times, want %d", run.watch, got, run.want)
+		}
+	}
+}
+
+// A 503 is retried under a non-zero policy and the retry's result is used;
+// a campground failing every attempt gives up after MaxAttempts.
+func TestEndToEndRetry(t *testing.T) {
+	tests := []struct {
+		name      string
+		failFirst int
+		wantErr   bool
+		wantTries int
+	}{
+		{name: "503 then 200", failFirst: 1, wantTries: 2},
+		{name: "503 throughout", failFirst: 5, wantErr: true, wantTries: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			f := e2eFixture(true)
+			f.Campgrounds[0].FailStatus, f.Campgrounds[0].FailFirst = 503, test.failFirst
+			h := fakerecgov.Start(f)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			scraper.DefaultScraper.Retry = core.RetryPolicy{MaxAttempts: 3, BaseBackoff: time.Millisecond}
+			scraper.DefaultScraper.Pacer = &core.Pacer{Clock: h.Clock}
+			m := e2eWatch("e2e-retry-" + strings.Replace(test.name, " ", "-", -1))
+
+			err := h.Run(context.Background(), m)
+			if tries := len(h.Server.MonthsFetched("232447")); tries != test.wantTries {
+				t.Errorf("requested the month %d times, want %d", tries, test.wantTries)
+			}
+			if !test.wantErr {
+				if err != nil {
+					t.Fatalf("Run: %v", err)
+				}
+				if n := len(h.Notifier.Alerts()); n != 1 {
+					t.Errorf("got %d alerts, want 1 from the retried month", n)
+				}
+				return
+			}
+			var retryErr *core.RetryError
+			var statusErr *core.StatusError
+			if !errors.As(err, &retryErr) || retryErr.Attempts != 3 || !errors.As(err, &statusErr) || statusErr.StatusCode != 503 {
+				t.Fatalf("Run error = %v, want a *core.RetryError of three 503s", err)
+			}
+		})
+	}
+}
+
+// A bot challenge is not retried, whatever the policy: the run makes one
+// request and ends quietly, and the pacer holds later runs off until its
+// cooldown ends.
+func TestEndToEndBlocked(t *testing.T) {
+	f := e2eFixture(true)
+	f.Campgrounds[0].Challenge = true
+	h := fakerecgov.Start(f)
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	scraper.DefaultScraper.Retry = core.RetryPolicy{MaxAttempts: 4, BaseBackoff: time.Millisecond}
+	m := e2eWatch("e2e-blocked")
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if tries := len(h.Server.MonthsFetched("232447")); tries != 1 {
+		t.Errorf("requested the month %d times, want 1", tries)
+	}
+	if h.Scheduler.Job(m.Name) == nil {
+		t.Errorf("job %s deleted after a bot challenge", m.Name)
+	}
+	_, err := scraper.DefaultScraper.Provider().FetchMonth(context.Background(), "232447", time.Date(2027, 7, 1, 0, 0, 0, 0, time.UTC))
+	var blocked *core.BlockedError
+	if !errors.Is(err, core.ErrBlocked) || !errors.As(err, &blocked) || blocked.Until.IsZero() {
+		t.Fatalf("FetchMonth during the cooldown = %v, want a *core.BlockedError holding off", err)
+	}
+	for i := 0; i < 3; i++ {
+		h.Clock.Advance(time.Minute)
+		h.Run(context.Background(), m)
+	}
+	if tries := len(h.Server.MonthsFetched("232447")); tries != 1 {
+		t.Errorf("requested the month %d times during the cooldown, want no more", tries)
+	}
+	h.Clock.Advance(core.DefaultBlockedCooldown)
+	_, err = scraper.DefaultScraper.Provider().FetchMonth(context.Background(), "232447", time.Date(2027, 7, 1, 0, 0, 0, 0, time.UTC))
+	if !errors.Is(err, core.ErrBlocked) || !errors.As(err, &blocked) || blocked.ContentType == "" {
+		t.Errorf("FetchMonth after the cooldown = %v, want the challenge page again", err)
+	}
+	if tries := len(h.Server.MonthsFetched("232447")); tries != 2 {
+		t.Errorf("requested the month %d times after the cooldown, want 2", tries)
+	}
+	// Nor is it retried with no pacer to cool down.
+	scraper.DefaultScraper.Pacer = nil
+	scraper.DefaultScraper.Provider().FetchMonth(context.Background(), "232447", time.Date(2027, 7, 1, 0, 0, 0, 0, time.UTC))
+	if tries := len(h.Server.MonthsFetched("232447")); tries != 3 {
+		t.Errorf("requested the month %d times without a pacer, want 3", tries)
+	}
+}
+
+// cancellingCache caches nothing and cancels a run's context once its month
+// is fetched, between the fetch and the matching.
+type cancellingCache struct {
+	cancel context.CancelFunc
+}
+
+func (c cancellingCache) Get(key string) (core.Campground, bool)     { return core.Campground{}, false }
+func (c cancellingCache) Set(key string, campground core.Campground) { c.cancel() }
+
+// A run whose context ends once the month is fetched, as when the function
+// reaches its deadline, stops matching, sends nothing and publishes a
+// partial result.
+func TestEndToEndCancelledMidScrape(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	scraper.DefaultScraper.Cache = cancellingCache{cancel}
+	m := e2eWatch("e2e-cancelled")
+
+	start := time.Now()
+	err := h.Run(ctx, m)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Run took %v after cancellation", elapsed)
+	}
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if n := len(h.Notifier.Alerts()); n != 0 {
+		t.Errorf("got %d alerts from a cancelled run", n)
+	}
+	if h.Scheduler.Job(m.Name) == nil {
+		t.Errorf("job %s deleted by a cancelled run", m.Name)
+	}
+	results := h.Results.Messages()
+	if len(results) != 1 || !results[0].Partial || results[0].Notified || len(results[0].Sites) != 0 {
+		t.Errorf("published %+v, want one partial result with no sites", results)
+	}
+}
+
+func TestEndToEndAlreadyCancelled(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m := e2eWatch("e2e-already-cancelled")
+
+	if err := h.Run(ctx, m); !errors.Is(err, context.Canceled) {
+		t.Errorf("Run error = %v, want context.Canceled", err)
+	}
+	if n := len(h.Notifier.Alerts()); n != 0 {
+		t.Errorf("got %d alerts from a cancelled run", n)
+	}
+	if months := h.Server.MonthsFetched("232447"); len(months) != 0 {
+		t.Errorf("fetched %v with a cancelled context", months)
+	}
+}
+
+// A run too close to its deadline for any enrichment still alerts, without
+// the campground's name or the sites' prices.
+func TestEndToEndNearDeadline(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx, cancel := context.WithTimeout(context.Background(), 11*time.Second)
+	defer cancel()
+	m := e2eWatch("e2e-near-deadline")
+
+	if err := h.Run(ctx, m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	alerts := h.Notifier.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	if alerts[0].CampgroundName != "" {
+		t.Errorf("alert names the campground %q despite the deadline", alerts[0].CampgroundName)
+	}
+	for _, site := range alerts[0].Sites {
+		if site.TotalPrice != 0 || site.PriceUnknown {
+			t.Errorf("site %s priced despite the deadline: %+v", site.ID, site)
+		}
+	}
+}
+
+// A persistent watch's alerts say how soon the campground's openings
+// typically go once enough of them were seen booked, and the booked
+// openings are exported with the history.
+func TestEndToEndTimeToBook(t *testing.T) {
+	t.Setenv("NOTIFY_EMAIL", "me@example.com")
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	defer scraper.UseChannels()()
+	sink := &scraper.MemorySink{}
+	scraper.DefaultScraper.History = &scraper.HistoryBatcher{Sink: sink}
+	ctx := context.Background()
+	m := e2eWatch("e2e-time-to-book")
+	m.KeepJob = true
+
+	// Each opening of site 1001 is two nights, booked 15 minutes later.
+	for i := 0; i < 6; i++ {
+		h.Server.SetFixture(e2eFixture(true))
+		if err := h.Run(ctx, m); err != nil {
+			t.Fatalf("run %d open: %v", i, err)
+		}
+		notices := h.Notifier.Notices()
+		if len(notices) != i+1 {
+			t.Fatalf("run %d sent %d emails, want %d", i, len(notices), i+1)
+		}
+		note := strings.Contains(notices[i].Plain, "Sites here are typically gone within ~15 min.")
+		if want := 2*i >= 10; note != want {
+			t.Errorf("alert %d after %d bookings gives the time to book: %v, want %v\n%s", i, 2*i, note, want, notices[i].Plain)
+		}
+		h.Clock.Advance(15 * time.Minute)
+		h.Server.SetFixture(e2eFixture(false))
+		if err := h.Run(ctx, m); err != nil {
+			t.Fatalf("run %d booked: %v", i, err)
+		}
+		h.Clock.Advance(15 * time.Minute)
+	}
+	bookings := sink.Bookings()
+	if len(bookings) != 12 {
+		t.Fatalf("exported %d booked openings, want 12", len(bookings))
+	}
+	if b := bookings[0]; b.CampsiteID != "1001" || b.TimeToBook() != 15*time.Minute {
+		t.Errorf("first booked opening %+v, want site 1001 after 15 minutes", b)
+	}
+}
+
+// A CampsiteIDs watch matches only its campsites, after any other filter;
+// one naming only campsites the campground lacks fails as invalid instead
+// of never matching.
+func TestEndToEndCampsiteIDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		watch     func(m *scraper.MessageContent)
+		wantSites []string
+		wantErr   string
+	}{
+		{name: "one missing", watch: func(m *scraper.MessageContent) { m.CampsiteIDs = []string{"1001", "9999"} }, wantSites: []string{"1001"}},
+		{name: "booked", watch: func(m *scraper.MessageContent) { m.CampsiteIDs = []string{"1003"} }},
+		{name: "with a site type", watch: func(m *scraper.MessageContent) {
+			m.CampsiteIDs, m.SiteTypes = []string{"1001", "1002"}, []string{"TENT ONLY"}
+		}, wantSites: []string{"1002"}},
+		{name: "all missing", watch: func(m *scraper.MessageContent) { m.CampsiteIDs = []string{"9998", "9999"} },
+			wantErr: `invalid CampsiteIDs "9998,9999": are not campsites of campground 232447`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fixture := e2eFixture(true)
+			fixture.Campgrounds[0].Sites[1].Nights = fixture.Campgrounds[0].Sites[0].Nights
+			h := fakerecgov.Start(fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("campsite-ids-" + strings.Replace(test.name, " ", "-", -1))
+			test.watch(&m)
+
+			err := h.Run(context.Background(), m)
+			if test.wantErr != "" {
+				var invalid *scraper.ValidationError
+				if !errors.As(err, &invalid) || !strings.Contains(err.Error(), test.wantErr) {
+					t.Fatalf("Run: %v, want a validation error %q", err, test.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			got := []string{}
+			for _, alert := range h.Notifier.Alerts() {
+				for _, site := range alert.Sites {
+					got = append(got, site.ID)
+				}
+			}
+			if strings.Join(got, ",") != strings.Join(test.wantSites, ",") {
+				t.Errorf("alerted sites %v, want %v", got, test.wantSites)
+			}
+		})
+	}
+}
+
+// A watch alerting only on newly opened sites, scraped while its site is
+// open, then booked, then reopened by a cancellation, alerts on the first
+// and third scrapes and keeps its last scan for the next.
+func TestEndToEndOnlyNewlyAvailable(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	m := e2eWatch("only-newly-available")
+	m.OnlyNewlyAvailable, m.KeepJob = true, true
+
+	scrapes := []struct {
+		open       bool
+		wantAlerts int
+		wantScan   []string
+	}{
+		{open: true, wantAlerts: 1, wantScan: []string{"1001"}},
+		{open: false, wantAlerts: 1, wantScan: []string{}},
+		{open: true, wantAlerts: 2, wantScan: []string{"1001"}},
+		{open: true, wantAlerts: 2, wantScan: []string{"1001"}},
+	}
+	for i, scrape := range scrapes {
+		h.Server.SetFixture(e2eFixture(scrape.open))
+		h.Clock.Advance(10 * time.Minute)
+		if err := h.Run(ctx, m); err != nil {
+			t.Fatalf("scrape %d: %v", i+1, err)
+		}
+		if n := len(h.Notifier.Alerts()); n != scrape.wantAlerts {
+			t.Errorf("scrape %d: %d alerts so far, want %d", i+1, n, scrape.wantAlerts)
+		}
+		if scan, _ := h.Store.LastScan(ctx, m.Name); strings.Join(scan, ",") != strings.Join(scrape.wantScan, ",") {
+			t.Errorf("scrape %d: last scan %v, want %v", i+1, scan, scrape.wantScan)
+		}
+	}
+}
+
+// A run outside the watch's scan window returns without fetching anything;
+// the same watch scans once the window opens, across midnight here.
+func TestEndToEndScanWindow(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	m := e2eWatch("scan-window")
+	m.ScanWindow, m.TimeZone = &scraper.ScanWindow{Start: "22:00", End: "06:00"}, "UTC"
+
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("run outside the window: %v", err)
+	}
+	if requests := h.Server.Requests(); len(requests) != 0 {
+		t.Errorf("run outside the window made requests %v", requests)
+	}
+	h.Clock.Set(time.Date(2027, 6, 2, 1, 0, 0, 0, time.UTC))
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("run inside the window: %v", err)
+	}
+	if alerts := h.Notifier.Alerts(); len(alerts) != 1 {
+		t.Errorf("got %d alerts inside the window, want 1", len(alerts))
+	}
+}
+
+// Every run publishes a results message that a consumer decodes back to the
+// run's sites, with the found attribute set only when there were any.
+func TestEndToEndResults(t *testing.T) {
+	for _, open := range []bool{true, false} {
+		open := open
+		t.Run(fmt.Sprintf("open %v", open), func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(open))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch(fmt.Sprintf("e2e-results-%v", open))
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			published := h.Results.Messages()
+			if len(published) != 1 {
+				t.Fatalf("published %d messages, want 1", len(published))
+			}
+			data, attrs, err := scraper.EncodeResultsMessage(published[0])
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := scraper.DecodeResultsMessage(data, attrs)
+			if err != nil {
+				t.Fatalf("DecodeResultsMessage: %v", err)
+			}
+			wantSites := []string{}
+			if open {
+				wantSites = []string{"1001"}
+			}
+			if got.JobName != m.Name || got.CampgroundID != "232447" || got.Notified != open || strings.Join(got.Sites, ",") != strings.Join(wantSites, ",") ||
+				!got.Arrival.Equal(time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC)) || got.SchemaVersion != scraper.ResultsSchemaVersion {
+				t.Errorf("decoded %+v", got)
+			}
+			if want := fmt.Sprint(open); got.Attributes["found"] != want || got.Attributes["campground_id"] != "232447" {
+				t.Errorf("attributes %v, want found=%s", got.Attributes, want)
+			}
+		})
+	}
+}
+
+// A site found during quiet hours and booked before they end is not
+// alerted when they do, and the watch, which deletes itself once it alerts,
+// stays scheduled to keep looking.
+func TestEndToEndQuietHoursSitesGone(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	ctx := context.Background()
+	m := e2eWatch("quiet-hours-gone")
+	m.QuietHoursStart, m.QuietHoursEnd, m.TimeZone = "22:00", "07:00", "UTC"
+
+	h.Clock.Set(time.Date(2027, 6, 1, 23, 0, 0, 0, time.UTC))
+	if err := h.Run(ctx, m); err != nil {
+		t.Fatalf("run during quiet hours: %v", err)
+	}
+	h.Server.SetFixture(e2eFixture(false))
+	h.Clock.Set(time.Date(2027, 6, 2, 7, 30, 0, 0, time.UTC))
+	if err := h.Run(ctx, m); err != nil {
+		t.Fatalf("run after quiet hours: %v", err)
+	}
+	if alerts := h.Notifier.Alerts(); len(alerts) != 0 {
+		t.Errorf("alerted %+v on sites booked overnight", alerts)
+	}
+	if deleted := h.Scheduler.Deleted(); len(deleted) != 0 || h.Scheduler.Job(m.Name) == nil {
+		t.Errorf("watch deleted (%v) without finding anything", deleted)
+	}
+
+	h.Server.SetFixture(e2eFixture(true))
+	h.Clock.Advance(10 * time.Minute)
+	if err := h.Run(ctx, m); err != nil {
+		t.Fatalf("run once the site reopened: %v", err)
+	}
+	if alerts := h.Notifier.Alerts(); len(alerts) != 1 || len(alerts[0].Sites) != 1 || alerts[0].Sites[0].ID != "1001" {
+		t.Errorf("got alerts %+v, want site 1001 once it reopened", alerts)
+	}
+}
@@ -0,0 +1,279 @@
+package scraper
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// campsiteURL is the recreation.gov booking page for a campsite ID.
+const campsiteURL = "https://www.recreation.gov/camping/campsites/"
+
+// emailSite is one row of the alert table.
+type emailSite struct {
+	ID      string
+	Type    string
+	Status  string
+	BookURL string
+	// ClaimURL is the signed "I'm booking this" link, when claims are on.
+	ClaimURL string
+}
+
+// emailData is what the alert templates render.
+type emailData struct {
+	Summary      string
+	Misdirected  string
+	Changes      string
+	Digest       string
+	CallToAction string
+	Sites        []emailSite
+	HasTypes     bool
+	MatrixText   string
+	MatrixHTML   htmltemplate.HTML
+	Partial      string
+	Excluded     string
+	ClusterNote  string
+	SitesNote    string
+	Forecast     []string
+	BookingNotes string
+	TimeToBook   string
+	Checked      string
+	ClosingText  string
+	ClosingHTML  htmltemplate.HTML
+}
+
+var emailHTML = htmltemplate.Must(htmltemplate.New("email").Parse(
+	`<p>{{.Summary}}</p>` +
+		`{{with .Misdirected}}<p><strong>{{.}}</strong></p>{{end}}` +
+		`{{with .Digest}}<p>{{.}}</p>{{end}}` +
+		`{{with .Changes}}<p>{{.}}</p>{{end}}` +
+		`{{with .CallToAction}}<p><strong>{{.}}</strong></p>{{end}}` +
+		`{{.MatrixHTML}}` +
+		`<table aria-label="Available campsites"><caption>Available campsites</caption>` +
+		`<thead><tr><th scope="col">Site</th>{{if .HasTypes}}<th scope="col">Type</th>{{end}}` +
+		`<th scope="col">Availability</th><th scope="col">Book</th></tr></thead><tbody>` +
+		`{{range .Sites}}<tr><th scope="row">{{.ID}}</th>{{if $.HasTypes}}<td>{{.Type}}</td>{{end}}<td>{{.Status}}` +
+		`{{with .ClaimURL}} <a href="{{.}}">I'm booking this</a>{{end}}</td>` +
+		`<td>{{with .BookURL}}<a href="{{.}}">Book on recreation.gov</a>{{end}}</td></tr>{{end}}` +
+		`</tbody></table>` +
+		`{{with .Forecast}}<p>Forecast:</p><ul>{{range .}}<li>{{.}}</li>{{end}}</ul>{{end}}` +
+		`{{with .BookingNotes}}<p><strong>Booking notes:</strong> {{.}}</p>{{end}}` +
+		`{{with .TimeToBook}}<p>{{.}}</p>{{end}}` +
+		`{{with .Partial}}<p>{{.}}</p>{{end}}` +
+		`{{with .Excluded}}<p>{{.}}</p>{{end}}` +
+		`{{with .ClusterNote}}<p>{{.}}</p>{{end}}` +
+		`{{with .SitesNote}}<p>{{.}}</p>{{end}}` +
+		`{{with .Checked}}<p>{{.}}</p>{{end}}` +
+		`{{.ClosingHTML}}`))
+
+var emailText = texttemplate.Must(texttemplate.New("email").Parse(
+	`{{.Summary}}
+{{with .Misdirected}}{{.}}
+{{end}}{{with .Digest}}{{.}}
+{{end}}{{with .Changes}}{{.}}
+{{end}}{{with .MatrixText}}
+{{.}}{{end}}
+{{with .CallToAction}}{{.}}
+
+{{end}}{{range .Sites}}Site {{.ID}}{{with .Type}} ({{.}}){{end}}: {{.Status}}
+{{with .BookURL}}  Book: {{.}}
+{{end}}{{with .ClaimURL}}  Booking it? Let the others know: {{.}}
+{{end}}{{end}}{{with .Forecast}}
+Forecast:
+{{range .}}  {{.}}
+{{end}}{{end}}{{with .BookingNotes}}
+Booking notes: {{.}}
+{{end}}{{with .TimeToBook}}
+{{.}}
+{{end}}{{with .Partial}}
+{{.}}
+{{end}}{{with .Excluded}}
+{{.}}
+{{end}}{{with .ClusterNote}}
+{{.}}
+{{end}}{{with .SitesNote}}
+{{.}}
+{{end}}{{with .Checked}}
+{{.}}
+{{end}}{{with .ClosingText}}
+{{.}}
+{{end}}`))
+
+// renderEmail renders the availability alert. It has no side effects. The
+// HTML part is a table with proper header scopes preceded by a text summary,
+// with a booking link per campsite; the plain-text part states the same
+// facts line by line so neither depends on visual layout.
+func renderEmail(a alert) (subject string, plain string, htmlContent string, err error) {
+	arrivalDay, departureDay := a.day(a.Arrival), a.day(a.Departure)
+	nights := core.NightsBetween(a.Arrival, a.Departure)
+	subject = fmt.Sprintf("Available sites found for %s: %s", a.place(), a.stay())
+	if len(a.Groups) > 0 || len(a.Failed) > 0 || len(a.Paused) > 0 {
+		subject, plain, htmlContent = renderGroupedEmail(a, subject, nights)
+		return subject, plain, htmlContent, nil
+	}
+	data := emailData{Summary: fmt.Sprintf("Found %d available sites at %s for %s to %s (%d nights).",
+		len(a.Sites), a.place(), arrivalDay, departureDay, nights)}
+	if a.StayNights != nil {
+		data.Summary = fmt.Sprintf("Found %d sites at %s that can be booked from %s for up to %d nights.",
+			len(a.Sites), a.place(), arrivalDay, nights)
+	}
+	if a.OpenRanges != nil {
+		full := 0
+		for _, site := range a.Sites {
+			if partialStay(a, site) == "" {
+				full++
+			}
+		}
+		data.Summary = fmt.Sprintf("Found %d sites at %s open for at least part of %s to %s: %d for all %d nights, %d for some of them.",
+			len(a.Sites), a.place(), arrivalDay, departureDay, full, nights, len(a.Sites)-full)
+	}
+	if len(a.Runs) > 0 {
+		stay := core.NightsBetween(a.Runs[0].Start, a.Runs[0].End)
+		data.Summary = fmt.Sprintf("Found %d sites at %s with %d consecutive nights free between %s and %s.",
+			len(a.Sites), a.place(), stay, arrivalDay, departureDay)
+		m := core.SummarizeRuns(a.Runs)
+		data.MatrixText = m.Text()
+		data.MatrixHTML = htmltemplate.HTML(m.HTML())
+	}
+	if a.Permits != nil {
+		subject = fmt.Sprintf("Permit availability found for %s: %s", a.CampgroundID, a.stay())
+		data.Summary = fmt.Sprintf("Found %d openings for permit %s, by division and entry date, between %s and %s.",
+			len(a.Permits), a.CampgroundID, arrivalDay, departureDay)
+	}
+	if a.Tours != nil {
+		subject = fmt.Sprintf("Tour tickets found at facility %s: %s", a.CampgroundID, a.tourDays())
+		data.Summary = fmt.Sprintf("Found %d tour slots at facility %s with enough tickets left for your party on %s. Times are local to the facility.",
+			len(a.Tours), a.CampgroundID, a.tourDays())
+	}
+	if a.MonitoredSite != "" {
+		subject = fmt.Sprintf("Calendar changed for %s at %s", a.monitorName(), a.place())
+		data.Summary = fmt.Sprintf("Site %s at %s changed on %d nights between %s and %s since the last alert.",
+			a.siteName(a.MonitoredSite), a.place(), a.ChangedNights, arrivalDay, departureDay)
+		if listed := len(a.NightChanges); listed < a.ChangedNights {
+			data.Summary += fmt.Sprintf(" The first %d are listed.", listed)
+		}
+	}
+	if a.Rehearsal {
+		subject = "[" + rehearsalLabel + "] " + subject
+		data.Summary = "This is a rehearsal alert. " + rehearsalLabel + ". " + data.Summary
+	}
+	if a.ContactProblem != "" {
+		data.Misdirected = "This alert came to you because the watch's contact could not be looked up (" + a.ContactProblem + ")."
+	}
+	if a.Diff != nil {
+		data.Changes = a.Diff.summary()
+	}
+	if a.Held != nil {
+		data.Digest = a.Held.summary()
+	}
+	if a.Primary != "" {
+		data.CallToAction = "Book this one first: site " + a.siteName(a.Primary)
+	}
+
+	for _, site := range a.Sites {
+		status := fmt.Sprintf("available all %d nights, %s to %s", nights, arrivalDay, departureDay)
+		if n, ok := a.StayNights[site]; ok {
+			status = fmt.Sprintf("available %d nights from %s", n, arrivalDay)
+		}
+		if stays := runDates(a.Runs, site); stays != "" {
+			status = "available " + stays
+		}
+		if partial := partialStay(a, site); partial != "" {
+			status = "partial match: available " + partial
+		}
+		if n, ok := a.Remaining[site]; ok {
+			status += fmt.Sprintf(" (%d left)", n)
+		}
+		if a.UnknownCapacity[site] {
+			status += " (unknown capacity)"
+		}
+		if a.UnknownAttributes[site] {
+			status += " (attributes unknown)"
+		}
+		if price := a.priceLabel(site); price != "" {
+			status += " (" + price + ")"
+		}
+		opening, isPermit := a.Permits[site]
+		if isPermit {
+			status = fmt.Sprintf("%d of %d slots left", opening.Remaining, opening.Total)
+		}
+		slot, isTour := a.Tours[site]
+		if isTour {
+			status = fmt.Sprintf("%d of %d tickets left", slot.Remaining, slot.Total)
+		}
+		if a.Diff.isNew(site) {
+			status = "new: " + status
+		}
+		change, isChange := a.NightChanges[site]
+		if isChange {
+			status = fmt.Sprintf("%s, %s → %s", change.Night.Time().Format("Mon Jan 2"), change.Before, change.After)
+		}
+		row := emailSite{ID: a.siteName(site), Type: a.SiteTypes[site], Status: strings.ToUpper(status[:1]) + status[1:]}
+		if isCampsiteID(site) {
+			row.BookURL = campsiteURL + site
+		}
+		if isChange {
+			row.ID, row.BookURL = a.siteName(a.MonitoredSite), campsiteURL+a.MonitoredSite
+		}
+		if isPermit {
+			row.BookURL = permitURL + a.CampgroundID
+		}
+		if isTour {
+			row.BookURL = ticketURL + a.CampgroundID
+		}
+		if !a.Rehearsal && !isPermit && !isTour && !isChange {
+			row.ClaimURL = claimLink(a.JobName, site, a.ResultsPage)
+		}
+		data.HasTypes = data.HasTypes || row.Type != ""
+		data.Sites = append(data.Sites, row)
+	}
+	data.Forecast = forecastLines(a.Forecast)
+	data.BookingNotes = a.BookingNotes
+	data.TimeToBook = timeToBookNote(a.TypicallyGone)
+	if a.Partial != nil {
+		data.Partial = fmt.Sprintf("Scan stopped early: only %d of %d campsites were checked.", a.Partial.Checked, a.Partial.Total)
+	}
+	if a.MissingAttributes > 0 {
+		data.Excluded = missingAttributesNote(a.MissingAttributes)
+	}
+	data.ClusterNote = a.ClusterNote
+	data.SitesNote = a.Requested.note()
+	if !a.ScannedAt.IsZero() {
+		data.Checked = "Checked " + FormatInstant(a.ScannedAt, a.TimeZone, a.Locale) + "."
+	}
+	if len(a.Trimmed) > 0 {
+		data.Checked = strings.TrimSpace(data.Checked + " (details trimmed)")
+	}
+	if a.Closing != nil {
+		data.ClosingText = a.Closing.Text()
+		data.ClosingHTML = htmltemplate.HTML(a.Closing.HTML())
+	}
+
+	var text, markup bytes.Buffer
+	if err := emailText.Execute(&text, data); err != nil {
+		return "", "", "", fmt.Errorf("rendering plain-text email: %v", err)
+	}
+	if err := emailHTML.Execute(&markup, data); err != nil {
+		return "", "", "", fmt.Errorf("rendering HTML email: %v", err)
+	}
+	return subject, text.String(), markup.String(), nil
+}
+
+// siteName is the site's recreation.gov name, such as A012, or its ID when
+// the name is unknown.
+func (a alert) siteName(site string) string {
+	if name := a.SiteNames[site]; name != "" {
+		return name
+	}
+	return site
+}
+
+// isCampsiteID reports whether site is a bare recreation.gov campsite ID
+// rather than a label such as a pair or the rehearsal marker.
+func isCampsiteID(site string) bool {
+	return site != "" && validateCampgroundID(site) == nil
+}
@@ -0,0 +1,71 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRenderEmail(t *testing.T) {
+	subject, plain, htmlContent, err := renderEmail(goldenAlert())
+	if err != nil {
+		t.Fatalf("renderEmail: %v", err)
+	}
+	if want := "Available sites found for Upper Pines (232447): 2 nights, Wed Jul 14 → Fri Jul 16"; subject != want {
+		t.Errorf("subject %q, want %q", subject, want)
+	}
+	// The plain-text part lists every fact the table does, one site a line.
+	for _, want := range []string{
+		"Found 2 available sites at Upper Pines (232447) for Wed Jul 14 to Fri Jul 16 (2 nights).",
+		"Site A001 (STANDARD NONELECTRIC): Available",
+		"  Book: https://www.recreation.gov/camping/campsites/1001",
+		"Site A002 (TENT ONLY NONELECTRIC): Available",
+		"  Book: https://www.recreation.gov/camping/campsites/1002",
+	} {
+		if !strings.Contains(plain, want) {
+			t.Errorf("plain part lacks %q:\n%s", want, plain)
+		}
+	}
+	if strings.Contains(plain, "<") {
+		t.Errorf("plain part has markup:\n%s", plain)
+	}
+	for _, want := range []string{
+		`<th scope="col">Site</th><th scope="col">Type</th>`,
+		`<th scope="row">A001</th><td>STANDARD NONELECTRIC</td>`,
+		`<a href="https://www.recreation.gov/camping/campsites/1001">Book on recreation.gov</a>`,
+		`<a href="https://www.recreation.gov/camping/campsites/1002">Book on recreation.gov</a>`,
+	} {
+		if !strings.Contains(htmlContent, want) {
+			t.Errorf("HTML part lacks %q:\n%s", want, htmlContent)
+		}
+	}
+}
+
+// Without site types the table drops its Type column, and recreation.gov's
+// names are escaped in the HTML part but left as written in the plain one.
+func TestRenderEmailUntypedAndEscaped(t *testing.T) {
+	a := goldenAlert()
+	a.SiteTypes = nil
+	a.CampgroundName = "Pines & <Oaks>"
+	a.SiteNames["1001"] = "A<1>"
+	subject, plain, htmlContent, err := renderEmail(a)
+	if err != nil {
+		t.Fatalf("renderEmail: %v", err)
+	}
+	if !strings.HasPrefix(subject, "Available sites found for Pines & <Oaks> (232447)") {
+		t.Errorf("subject %q, want the campground name as written", subject)
+	}
+	if !strings.Contains(plain, "Site A<1>: Available") {
+		t.Errorf("plain part lacks the raw site name:\n%s", plain)
+	}
+	if strings.Contains(htmlContent, `scope="col">Type`) {
+		t.Errorf("HTML part has a Type column without types:\n%s", htmlContent)
+	}
+	for _, want := range []string{"Pines &amp; &lt;Oaks&gt;", `<th scope="row">A&lt;1&gt;</th>`} {
+		if !strings.Contains(htmlContent, want) {
+			t.Errorf("HTML part lacks %q:\n%s", want, htmlContent)
+		}
+	}
+	if strings.Contains(htmlContent, "<Oaks>") || strings.Contains(htmlContent, "A<1>") {
+		t.Errorf("HTML part has unescaped names:\n%s", htmlContent)
+	}
+}
@@ -0,0 +1,63 @@
+package scraper
+
+import (
+	"context"
+	"time"
+)
+
+// deliveryReserve is the time kept back from enrichment for sending the
+// alert itself, so a slow enrichment cannot cost the alert.
+const deliveryReserve = 10 * time.Second
+
+// enrichStage is an optional addition to an alert and how long it is
+// expected to take.
+type enrichStage struct {
+	name     string
+	estimate time.Duration
+}
+
+// enrichStages lists the enrichments, most important first: campground
+// names, fees, then weather. Facility alerts belong between names and fees
+// and photos after weather, should they be added.
+var enrichStages = []enrichStage{
+	{"names", 2 * time.Second},
+	{"fees", 5 * time.Second},
+	{"weather", weatherTimeout},
+}
+
+// enrichBudget reports whether ctx leaves time for the named stage. Each
+// stage needs its own estimate, those of the stages ahead of it in
+// enrichStages and deliveryReserve, so a tight deadline drops the least
+// important stages first. A skipped stage is logged and recorded in
+// a.Trimmed. A context without a deadline has time for everything.
+func enrichBudget(ctx context.Context, jobName string, name string, a *alert) bool {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return true
+	}
+	needed := deliveryReserve
+	for _, stage := range enrichStages {
+		needed += stage.estimate
+		if stage.name == name {
+			break
+		}
+	}
+	// Context deadlines are on the wall clock, not DefaultScraper's.
+	remaining := time.Until(deadline)
+	if remaining >= needed {
+		return true
+	}
+	logger.Printf("job %s: skipping %s: %v left before the deadline, %v needed", jobName, name, remaining.Round(time.Millisecond), needed)
+	a.trim(name)
+	return false
+}
+
+// trim records that stage was skipped, once.
+func (a *alert) trim(stage string) {
+	for _, s := range a.Trimmed {
+		if s == stage {
+			return
+		}
+	}
+	a.Trimmed = append(a.Trimmed, stage)
+}
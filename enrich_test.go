@@ -0,0 +1,51 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// With names taking 2s, fees 5s and weather 10s on top of the 10s kept for
+// delivery, a stage runs only when the time left covers it and every stage
+// ahead of it.
+func TestEnrichBudget(t *testing.T) {
+	tests := []struct {
+		name string
+		// left is the time before the deadline, or zero for none.
+		left time.Duration
+		want map[string]bool
+	}{
+		{"no deadline", 0, map[string]bool{"names": true, "fees": true, "weather": true}},
+		{"plenty", time.Minute, map[string]bool{"names": true, "fees": true, "weather": true}},
+		{"no time for weather", 20 * time.Second, map[string]bool{"names": true, "fees": true, "weather": false}},
+		{"names only", 15 * time.Second, map[string]bool{"names": true, "fees": false, "weather": false}},
+		{"delivery only", 5 * time.Second, map[string]bool{"names": false, "fees": false, "weather": false}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			if test.left > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, test.left)
+				defer cancel()
+			}
+			var a alert
+			trimmed := []string{}
+			for _, stage := range enrichStages {
+				if got := enrichBudget(ctx, "job", stage.name, &a); got != test.want[stage.name] {
+					t.Errorf("enrichBudget(%s) = %v, want %v", stage.name, got, test.want[stage.name])
+				}
+				if !test.want[stage.name] {
+					trimmed = append(trimmed, stage.name)
+				}
+			}
+			// A second check of the same stage is not recorded twice.
+			enrichBudget(ctx, "job", "weather", &a)
+			if strings.Join(a.Trimmed, ",") != strings.Join(trimmed, ",") {
+				t.Errorf("Trimmed = %v, want %v", a.Trimmed, trimmed)
+			}
+		})
+	}
+}
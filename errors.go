@@ -0,0 +1,54 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+)
+
+// MultiError collects the outcome of a fan-out operation, such as sending
+// one alert over several channels, so callers can tell "2 of 3 failed" apart
+// from a total failure while keeping each underlying error inspectable with
+// errors.Is and errors.As.
+type MultiError struct {
+	Attempted int
+	Errors    []error
+}
+
+// Add records one attempted operation and its error, if any.
+func (m *MultiError) Add(err error) {
+	m.Attempted++
+	if err != nil {
+		m.Errors = append(m.Errors, err)
+	}
+}
+
+// Err returns m if anything failed and nil otherwise.
+func (m *MultiError) Err() error {
+	if len(m.Errors) == 0 {
+		return nil
+	}
+	return m
+}
+
+// AllFailed reports whether every attempted operation failed.
+func (m *MultiError) AllFailed() bool {
+	return m.Attempted > 0 && len(m.Errors) == m.Attempted
+}
+
+// Partial reports whether some, but not all, operations failed.
+func (m *MultiError) Partial() bool {
+	return len(m.Errors) > 0 && len(m.Errors) < m.Attempted
+}
+
+func (m *MultiError) Error() string {
+	messages := make([]string, len(m.Errors))
+	for i, err := range m.Errors {
+		messages[i] = err.Error()
+	}
+	return fmt.Sprintf("%d of %d failed: %s", len(m.Errors), m.Attempted, strings.Join(messages, "; "))
+}
+
+// Unwrap exposes the individual errors to errors.Is and errors.As.
+func (m *MultiError) Unwrap() []error {
+	return m.Errors
+}
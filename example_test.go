@@ -0,0 +1,134 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// exampleServer stands in for recreation.gov in the examples: Lower Pines,
+// with site B001 open on July 14 and 15, 2027.
+func exampleServer() *fakerecgov.Server {
+	return fakerecgov.NewServer(fakerecgov.Fixture{Campgrounds: []fakerecgov.Campground{{
+		ID:   "232450",
+		Name: "Lower Pines",
+		Sites: []fakerecgov.Site{
+			{ID: "2001", Site: "B001", Loop: "B", Type: "STANDARD NONELECTRIC", Nights: map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}},
+			{ID: "2002", Site: "B002", Loop: "B", Type: "TENT ONLY NONELECTRIC"},
+		},
+	}}})
+}
+
+func ExampleFinder_FindAvailability() {
+	server := exampleServer()
+	defer server.Close()
+
+	f := scraper.NewFinder(nil)
+	f.Scraper.BaseURL = server.URL // omit to search recreation.gov
+	result, err := f.FindAvailability(context.Background(), scraper.Query{
+		Campgrounds: []string{"232450"},
+		Arrival:     time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC),
+		Departure:   time.Date(2027, 7, 16, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, site := range result.Sites {
+		fmt.Println(result.SiteNames[site], "is free, campsite", site)
+	}
+	// Output: B001 is free, campsite 2001
+}
+
+func ExampleFinder_Watch() {
+	server := exampleServer()
+	defer server.Close()
+
+	f := scraper.NewFinder(nil)
+	f.Scraper.BaseURL = server.URL
+	q := scraper.Query{
+		Campgrounds: []string{"232450"},
+		Arrival:     time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC),
+		Departure:   time.Date(2027, 7, 16, 0, 0, 0, 0, time.UTC),
+	}
+	notify := scraper.NotifierFunc(func(ctx context.Context, q scraper.Query, r scraper.FindResult) error {
+		fmt.Printf("%d site free at %s\n", len(r.Sites), q.Campgrounds[0])
+		return nil
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := f.Watch(ctx, q, notify, time.Minute); err != nil {
+		log.Fatal(err)
+	}
+	// Output: 1 site free at 232450
+}
+
+func ExampleFinder_SearchCampgrounds() {
+	server := exampleServer()
+	defer server.Close()
+
+	f := scraper.NewFinder(nil)
+	f.Scraper.BaseURL = server.URL
+	campgrounds, err := f.SearchCampgrounds(context.Background(), "pines")
+	if err != nil {
+		log.Fatal(err)
+	}
+	for _, c := range campgrounds {
+		fmt.Println(c.ID, c.Name)
+	}
+	// Output: 232450 Lower Pines
+}
+
+// NotifyByEmail needs SENDGRID_API_KEY and FROM_EMAIL set, and emails the
+// standard alert when a site is free.
+func ExampleNotifyByEmail() {
+	arrival := time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC)
+	sites, err := scraper.NotifyByEmail(context.Background(), "232447", arrival, arrival.AddDate(0, 0, 2), "me@example.com")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(len(sites), "sites found")
+}
+
+// A downstream service consumes every scrape's results from a subscription
+// to the results topic. Returning an error redelivers the message.
+func ExampleSubscribe() {
+	ctx := context.Background()
+	err := scraper.Subscribe(ctx, "campfinder-results", func(r scraper.ResultsMessage) error {
+		if len(r.Sites) == 0 {
+			return nil
+		}
+		log.Printf("%s: %d sites at %s from %s", r.JobName, len(r.Sites), r.CampgroundID, r.Arrival.Format("Jan 2"))
+		return nil
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func ExampleDecodeResultsMessage() {
+	data, attrs, err := scraper.EncodeResultsMessage(scraper.ResultsMessage{
+		JobName:      "projects/p/locations/l/jobs/watch-232447",
+		CampgroundID: "232447",
+		Sites:        []string{"1001"},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	r, err := scraper.DecodeResultsMessage(data, attrs)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println(r.SchemaVersion, r.CampgroundID, r.Sites)
+
+	var newer *scraper.UnsupportedSchemaError
+	_, err = scraper.DecodeResultsMessage(data, map[string]string{"schema_version": "99"})
+	fmt.Println(errors.As(err, &newer))
+	// Output:
+	// 1 232447 [1001]
+	// true
+}
@@ -0,0 +1,126 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// expiryZone is used for watches without a TimeZone: most watched
+// campgrounds are in the west, and erring late keeps a watch alive through
+// its arrival day everywhere in the continental US.
+const expiryZone = "America/Los_Angeles"
+
+// expiresAt is the instant a watch stops being useful: the end of its
+// arrival day, or of its departure day when ExpireAfter is "departure" or
+// the watch is a reservation guard, in the campground's time zone. Window watches expire at the end of the last
+// day a stay could still start. It returns false when the dates do not
+// parse, which Validate reports instead.
+func (m MessageContent) expiresAt() (time.Time, bool) {
+	var day core.CivilDate
+	switch {
+	case m.Nights > 0:
+		day = stayDate(m.WindowEnd).AddDays(-m.Nights)
+	case m.ExpireAfter == "departure" && m.MaxNights == 0, m.isReservationGuard():
+		day = stayDate(m.Departure)
+	default:
+		day = stayDate(m.Arrival)
+	}
+	if day.IsZero() {
+		return time.Time{}, false
+	}
+	zone := m.TimeZone
+	if zone == "" {
+		zone = expiryZone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		loc, _ = time.LoadLocation(expiryZone)
+	}
+	return time.Date(day.Year, day.Month, day.Day+1, 0, 0, 0, 0, loc), true
+}
+
+// expired reports whether the watch's dates have passed. Site monitors
+// never expire.
+func (m MessageContent) expired() bool {
+	if m.isSiteMonitor() {
+		return false
+	}
+	at, ok := m.expiresAt()
+	return ok && !DefaultScraper.now().Before(at)
+}
+
+// sendExpiredNotice is the closing message for a watch whose dates passed.
+func sendExpiredNotice(ctx context.Context, to *mail.Email, m MessageContent, summary WatchSummary) error {
+	subject := fmt.Sprintf("Watch for campground %s expired without availability", m.Campground)
+	body := fmt.Sprintf("The dates for watch %s have passed without a site opening up at campground %s, so the watch has been removed.",
+		m.Name, m.Campground)
+	if m.isReservationGuard() {
+		subject = fmt.Sprintf("Reservation guard for campground %s ended", m.Campground)
+		body = fmt.Sprintf("Your stay at campground %s has ended, so watch %s, which watched it for closures, has been removed.", m.Campground, m.Name)
+	}
+	return sendNoticeTo(ctx, to, subject, body+"\n\n"+summary.Text(), "<p>"+html.EscapeString(body)+"</p>"+summary.HTML())
+}
+
+// expireWatch ends a watch whose dates have passed: it sends the expiry
+// notice, records the closed watch and deletes the job.
+func expireWatch(ctx context.Context, m MessageContent, run *watchRun) error {
+	summary := buildWatchSummary(ctx, m, WatchExpired, nil)
+	if err := sendExpiredNotice(ctx, m.recipient(), m, summary); err != nil {
+		logger.Println(err)
+	}
+	recordClosedWatch(ctx, summary)
+	return run.deleteJob(ctx, outcomeExpired)
+}
+
+// CleanupExpiredJobs deletes every watch in the configured location whose
+// dates have passed, without sending anything, and returns the names it
+// deleted. A failed delete does not stop the others; the error is then a
+// *MultiError.
+func CleanupExpiredJobs(ctx context.Context) ([]string, error) {
+	s, err := newWatchScheduler()
+	if err != nil {
+		return nil, err
+	}
+	jobs, err := s.ListJobs(ctx, activeConfig.Parent())
+	if err != nil {
+		return nil, fmt.Errorf("listing jobs in %s: %v", activeConfig.Parent(), err)
+	}
+	store := newWatchStore(activeConfig)
+	deleted := []string{}
+	failures := &MultiError{}
+	for _, job := range jobs {
+		target := job.GetPubsubTarget()
+		if target == nil {
+			continue
+		}
+		m, _, ok := watchFromJob(ctx, store, target.Data)
+		if !ok || !m.expired() {
+			continue
+		}
+		recordClosedWatch(ctx, buildWatchSummary(ctx, m, WatchExpired, nil))
+		err := s.DeleteJob(ctx, job.Name)
+		failures.Add(err)
+		if err != nil {
+			logger.Printf("deleting expired watch %s: %v", job.Name, err)
+			continue
+		}
+		pruneJobState(ctx, m.Name)
+		markWatchCompleted(ctx, store, m.Name)
+		deleted = append(deleted, job.Name)
+	}
+	return deleted, failures.Err()
+}
+
+// ExpireWatches is a Pub/Sub Cloud Function running CleanupExpiredJobs, for
+// triggering from its own schedule.
+func ExpireWatches(ctx context.Context, m pubsub.Message) error {
+	deleted, err := CleanupExpiredJobs(ctx)
+	logger.Printf("deleted %d expired watches", len(deleted))
+	return err
+}
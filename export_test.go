@@ -0,0 +1,34 @@
+package scraper
+
+// UpdateGolden is -update, for the golden tests in package scraper_test.
+var UpdateGolden = updateGolden
+
+// JobNameFor is the full name ParseJobName gives job ID id.
+func JobNameFor(id string) string { return activeConfig.JobName(id).String() }
+
+// channelNotifiers is notifiersFor as the package defines it.
+var channelNotifiers = notifiersFor
+
+// UseChannels puts back the package's own notifiers, replaced by
+// UseServices with a Notifier, so alerts go out as email through the
+// Notices fake and to any Slack webhook the alert has. It returns a function
+// restoring the replacement.
+func UseChannels() (restore func()) {
+	injected := notifiersFor
+	notifiersFor = channelNotifiers
+	return func() { notifiersFor = injected }
+}
+
+// UseConfig makes c the package configuration, whose location entry points
+// such as BuildDigest list jobs in, and returns a function restoring it.
+func UseConfig(c Config) (restore func()) {
+	old := activeConfig
+	activeConfig = c
+	return func() { activeConfig = old }
+}
+
+// EnsureDigestJob is the bootstrap step creating the daily digest's job.
+var EnsureDigestJob = ensureDigestJob
+
+// RequiredRoles are the roles Bootstrap checks the service account for.
+var RequiredRoles = requiredRoles
@@ -0,0 +1,135 @@
+package scraper
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// Campground names, coordinates and booking terms are cached for the life
+// of the process; they rarely change. Failed lookups are not cached.
+var (
+	facilityMu    sync.Mutex
+	facilityCache = map[string]core.Facility{}
+)
+
+// facility returns what recreation.gov says about a campground, from the
+// cache when it has been looked up before. With an ingested result it is
+// only the name the result gives.
+func (s *Scraper) facility(ctx context.Context, campgroundID string) (core.Facility, error) {
+	if s.ingested != nil {
+		return core.Facility{Name: s.ingested.CampgroundName}, nil
+	}
+	facilityMu.Lock()
+	f, ok := facilityCache[campgroundID]
+	facilityMu.Unlock()
+	if ok {
+		return f, nil
+	}
+	f, err := s.Provider().FetchFacility(ctx, campgroundID)
+	if err != nil {
+		return f, err
+	}
+	facilityMu.Lock()
+	facilityCache[campgroundID] = f
+	facilityMu.Unlock()
+	return f, nil
+}
+
+// describeAlert fills in how a's campground, dates and times are shown: the
+// watch's locale and zone, and for single-campground watches how soon the
+// campground's openings typically go and its name and booking notes. A
+// failed name lookup, or one skipped for lack of time, is logged and the
+// alert uses the ID.
+func (s *Scraper) describeAlert(ctx context.Context, m MessageContent, a *alert) {
+	a.Locale, a.TimeZone = m.Locale, m.TimeZone
+	if m.isPermit() || m.isTour() || len(m.Campgrounds) > 0 {
+		return
+	}
+	if d, ok := s.typicalTimeToBook(ctx, m.Campground); ok {
+		a.TypicallyGone = d
+	}
+	if !enrichBudget(ctx, m.Name, "names", a) {
+		return
+	}
+	f, err := s.facility(ctx, m.Campground)
+	if err != nil {
+		logger.Printf("job %s: looking up campground name: %v", m.Name, err)
+		return
+	}
+	a.CampgroundName = f.Name
+	a.BookingNotes = bookingNotes(f)
+}
+
+// maxBookingNotes caps the booking notes, in characters.
+const maxBookingNotes = 300
+
+// bookingNotes sums up f's booking terms, such as "If you cancel within 48
+// hours of arrival you pay the first night. Reservation fee $8.00. Check-in
+// 12:00 PM, check-out 11:00 AM." The cancellation policy is cut short at a
+// word to keep the notes within maxBookingNotes. It is empty when f lists
+// no terms.
+func bookingNotes(f core.Facility) string {
+	terms := []string{}
+	if f.ReservationFee > 0 {
+		terms = append(terms, "Reservation fee "+core.FormatPrice(f.ReservationFee)+".")
+	}
+	switch {
+	case f.CheckIn != "" && f.CheckOut != "":
+		terms = append(terms, "Check-in "+f.CheckIn+", check-out "+f.CheckOut+".")
+	case f.CheckIn != "":
+		terms = append(terms, "Check-in "+f.CheckIn+".")
+	case f.CheckOut != "":
+		terms = append(terms, "Check-out "+f.CheckOut+".")
+	}
+	notes := strings.Join(terms, " ")
+	if f.CancellationPolicy == "" {
+		return notes
+	}
+	room := maxBookingNotes
+	if notes != "" {
+		room -= utf8.RuneCountInString(notes) + 1
+	}
+	return strings.TrimSpace(shortenToWord(f.CancellationPolicy, room) + " " + notes)
+}
+
+// shortenToWord returns s if it has at most n characters, and otherwise as
+// many of its words as fit in n characters with "…" after them.
+func shortenToWord(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	if n < 1 {
+		return ""
+	}
+	cut := string(runes[:n-1])
+	if space := strings.LastIndex(cut, " "); space > 0 {
+		cut = cut[:space]
+	}
+	return strings.TrimRight(cut, " ,;:") + "…"
+}
+
+// place names a's campground, such as "Upper Pines (232447)", or gives
+// its ID, such as "campground 232447", when the name is unknown.
+func (a alert) place() string {
+	if strings.Contains(a.CampgroundID, ",") {
+		return "campgrounds " + a.CampgroundID
+	}
+	if a.CampgroundName == "" {
+		return "campground " + a.CampgroundID
+	}
+	return a.CampgroundName + " (" + a.CampgroundID + ")"
+}
+
+// day and stay format dates in a's locale; see FormatDay and FormatStay.
+func (a alert) day(d core.CivilDate) string {
+	return FormatDay(d, a.Locale)
+}
+
+func (a alert) stay() string {
+	return FormatStay(a.Arrival, a.Departure, a.Locale)
+}
@@ -0,0 +1,52 @@
+package scraper
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+func TestBookingNotes(t *testing.T) {
+	policy := "If you cancel within 48 hours of your arrival date, you will be charged the first night's fee."
+	long := strings.Repeat("Cancellations made less than 14 days before arrival forfeit the first night’s fee. ", 6)
+	tests := []struct {
+		name     string
+		facility core.Facility
+		want     string
+		// wantCut is set when the policy must be cut at a word, before
+		// "…" and the rest of the notes, which want then holds.
+		wantCut bool
+	}{
+		{name: "everything", facility: core.Facility{CancellationPolicy: policy, ReservationFee: 800, CheckIn: "12:00 PM", CheckOut: "11:00 AM"},
+			want: policy + " Reservation fee $8.00. Check-in 12:00 PM, check-out 11:00 AM."},
+		{name: "policy only", facility: core.Facility{CancellationPolicy: policy}, want: policy},
+		{name: "fee only", facility: core.Facility{ReservationFee: 800}, want: "Reservation fee $8.00."},
+		{name: "check-out only", facility: core.Facility{CheckOut: "11:00 AM"}, want: "Check-out 11:00 AM."},
+		{name: "none", facility: core.Facility{Name: "Upper Pines"}, want: ""},
+		{name: "long policy", facility: core.Facility{CancellationPolicy: long, ReservationFee: 800},
+			wantCut: true, want: "… Reservation fee $8.00."},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := bookingNotes(test.facility)
+			if n := utf8.RuneCountInString(got); n > maxBookingNotes {
+				t.Errorf("bookingNotes has %d characters, over %d: %q", n, maxBookingNotes, got)
+			}
+			if !utf8.ValidString(got) {
+				t.Errorf("bookingNotes = %q, not valid UTF-8", got)
+			}
+			if test.wantCut {
+				kept := strings.TrimSuffix(got, test.want)
+				if kept == got || kept == "" || !strings.HasPrefix(test.facility.CancellationPolicy, kept+" ") {
+					t.Errorf("bookingNotes = %q, want the policy cut at a word and then %q", got, test.want)
+				}
+				return
+			}
+			if got != test.want {
+				t.Errorf("bookingNotes = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
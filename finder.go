@@ -0,0 +1,237 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// defaultWatchInterval is how often Finder.Watch checks when the caller
+// gives no interval.
+const defaultWatchInterval = 5 * time.Minute
+
+// Finder is the package for programs embedding it rather than deploying it
+// as a Cloud Function. It runs the same matching as ScrapeFromMessage but
+// needs nothing beyond an HTTP client: no Pub/Sub, Scheduler, bucket or
+// email configuration.
+type Finder struct {
+	Scraper *Scraper
+}
+
+// NewFinder returns a Finder talking to recreation.gov through client, or a
+// client with a 30 second timeout when client is nil.
+//
+//	f := scraper.NewFinder(nil)
+//	result, err := f.FindAvailability(ctx, scraper.Query{
+//		Campgrounds: []string{"232447"},
+//		Arrival:     arrival,
+//		Departure:   departure,
+//	})
+func NewFinder(client *http.Client) *Finder {
+	return &Finder{Scraper: NewScraper(client, core.DefaultRetryPolicy)}
+}
+
+// Query is one availability search. It takes the same options as a watch
+// payload; see MessageContent for what each one does.
+type Query struct {
+	// Campgrounds are recreation.gov campground IDs. Several are searched
+	// concurrently.
+	Campgrounds []string
+	Arrival     time.Time
+	Departure   time.Time
+	// SiteTypes and ExcludeTypes filter by campsite type, such as "tent" or
+	// "rv".
+	SiteTypes    []string
+	ExcludeTypes []string
+	// MinCapacity drops sites too small for the group, and
+	// RequiredAttributes those without the listed campsite attributes.
+	MinCapacity        int
+	RequiredAttributes map[string]string
+	// Priority lists favourite campsite IDs, best first.
+	Priority []string
+	// MaxNights makes the departure flexible: each site's longest stay from
+	// Arrival of MinNights to MaxNights nights is reported, and Departure is
+	// ignored.
+	MinNights int
+	MaxNights int
+	// MinConsecutiveNights accepts sites open for part of the stay.
+	MinConsecutiveNights int
+	// IncludeWalkUp counts first-come, first-served nights as available.
+	IncludeWalkUp bool
+	// IncludeWeather attaches each night's forecast to results from a
+	// single campground.
+	IncludeWeather bool
+}
+
+// watch is the watch payload equivalent to q.
+func (q Query) watch() MessageContent {
+	m := MessageContent{
+		Name:                 "finder-" + strings.Join(q.Campgrounds, "-"),
+		Arrival:              q.Arrival.Format(layoutISO),
+		Departure:            q.Departure.Format(layoutISO),
+		SiteTypes:            q.SiteTypes,
+		ExcludeTypes:         q.ExcludeTypes,
+		MinCapacity:          q.MinCapacity,
+		RequiredAttributes:   q.RequiredAttributes,
+		Priority:             q.Priority,
+		MinNights:            q.MinNights,
+		MaxNights:            q.MaxNights,
+		MinConsecutiveNights: q.MinConsecutiveNights,
+		IncludeWalkUp:        q.IncludeWalkUp,
+		IncludeWeather:       q.IncludeWeather,
+	}
+	if len(q.Campgrounds) == 1 {
+		m.Campground = q.Campgrounds[0]
+	} else {
+		m.Campgrounds = q.Campgrounds
+	}
+	return m
+}
+
+// FindResult is what a Query found.
+type FindResult struct {
+	// Arrival and Departure are the stay searched; with a flexible departure
+	// Departure is after the longest stay considered.
+	Arrival   time.Time
+	Departure time.Time
+	// Sites lists the matching sites, best first. Across several
+	// campgrounds each is given as "campground: site".
+	Sites []string
+	// SiteNames gives recreation.gov's name, such as A012, of the sites
+	// that have one.
+	SiteNames map[string]string
+	// Remaining gives how many units are left every night at sites booked
+	// by the unit, such as group and overflow areas.
+	Remaining map[string]int
+	// Campgrounds has the matching sites of each campground with any.
+	Campgrounds map[string][]string
+	// StayNights gives each site's longest stay for a flexible departure.
+	StayNights map[string]int
+	// OpenRanges gives the open run of each site for MinConsecutiveNights.
+	OpenRanges map[string]core.Run
+	// UnknownCapacity marks sites kept for MinCapacity whose capacity could
+	// not be read.
+	UnknownCapacity map[string]bool
+	// UnknownAttributes marks sites kept for RequiredAttributes whose
+	// details could not be read, and any site recreation.gov has no details
+	// for. MissingAttributes counts the sites dropped for not listing a
+	// required attribute.
+	UnknownAttributes map[string]bool
+	MissingAttributes int
+	// Failed gives the reason for each campground that could not be
+	// checked, when others could, and Paused for each the operator kept
+	// from being scraped.
+	Failed map[string]string
+	Paused map[string]string
+	// Incomplete is set when the scan stopped early, so some sites may be
+	// missing.
+	Incomplete bool
+	// Forecast is the forecast for each night of the stay, for
+	// IncludeWeather queries that found sites.
+	Forecast []NightForecast
+}
+
+// findResult converts a's findings for library callers.
+func findResult(a alert, campgrounds []string) FindResult {
+	r := FindResult{
+		Arrival:           a.Arrival.Time(),
+		Departure:         a.Departure.Time(),
+		Sites:             a.Sites,
+		SiteNames:         a.SiteNames,
+		Remaining:         a.Remaining,
+		Campgrounds:       map[string][]string{},
+		StayNights:        a.StayNights,
+		OpenRanges:        a.OpenRanges,
+		UnknownCapacity:   a.UnknownCapacity,
+		UnknownAttributes: a.UnknownAttributes,
+		MissingAttributes: a.MissingAttributes,
+		Failed:            a.Failed,
+		Paused:            a.Paused,
+		Incomplete:        a.Partial != nil,
+		Forecast:          a.Forecast,
+	}
+	if r.Sites == nil {
+		r.Sites = []string{}
+	}
+	if len(campgrounds) == 1 && len(a.Sites) > 0 {
+		r.Campgrounds[campgrounds[0]] = a.Sites
+	}
+	for _, g := range a.Groups {
+		r.Campgrounds[g.CampgroundID] = g.Sites
+	}
+	return r
+}
+
+// FindAvailability searches once for q. It returns a *ValidationError for a
+// query the watch payload would reject. As with core.Scrape, a
+// *core.PartialResultError comes back alongside the sites that were found.
+func (f *Finder) FindAvailability(ctx context.Context, q Query) (FindResult, error) {
+	m := q.watch()
+	if err := m.Validate(); err != nil {
+		return FindResult{}, err
+	}
+	var a alert
+	var err error
+	if len(q.Campgrounds) > 1 {
+		a, err = f.Scraper.matchCampgrounds(ctx, m, false)
+	} else {
+		a, err = f.Scraper.matchWatch(ctx, f.Scraper.providerFor(m), m, false)
+		if len(a.Sites) > 0 {
+			f.Scraper.attachForecast(ctx, m, &a)
+		}
+	}
+	return findResult(a, q.Campgrounds), err
+}
+
+// SearchCampgrounds looks up reservable campgrounds by name.
+func (f *Finder) SearchCampgrounds(ctx context.Context, query string) ([]CampgroundInfo, error) {
+	return f.Scraper.Provider().SearchCampgrounds(ctx, query)
+}
+
+// Notifier is told what Finder.Watch found.
+type Notifier interface {
+	Notify(ctx context.Context, q Query, r FindResult) error
+}
+
+// NotifierFunc lets a function be a Notifier.
+type NotifierFunc func(ctx context.Context, q Query, r FindResult) error
+
+// Notify calls fn.
+func (fn NotifierFunc) Notify(ctx context.Context, q Query, r FindResult) error {
+	return fn(ctx, q, r)
+}
+
+// Watch checks q every interval, or every five minutes when interval is not
+// positive, until some site matches, then passes the result to n and
+// returns what n returned. Failed checks are logged and retried at the next
+// interval, except for an invalid query, which is returned at once. Watch
+// otherwise runs until ctx is done.
+func (f *Finder) Watch(ctx context.Context, q Query, n Notifier, interval time.Duration) error {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	for {
+		result, err := f.FindAvailability(ctx, q)
+		var invalid *ValidationError
+		var partial *core.PartialResultError
+		switch {
+		case errors.As(err, &invalid):
+			return err
+		case err != nil && !errors.As(err, &partial):
+			logger.Printf("watch %s: %v", q.watch().Name, err)
+		case len(result.Sites) > 0:
+			return n.Notify(ctx, q, result)
+		}
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
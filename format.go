@@ -0,0 +1,112 @@
+package scraper
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// Alerts are formatted in English and Pacific time unless the watch sets
+// Locale or TimeZone.
+const (
+	defaultLocale      = "en"
+	defaultDisplayZone = "America/Los_Angeles"
+)
+
+// localeNames is how one language writes dates and counts nights.
+type localeNames struct {
+	days   [7]string
+	months [12]string
+	// day lays out a weekday, day of the month and month name.
+	day           func(weekday string, day int, month string) string
+	night, nights string
+}
+
+// locales are the languages alerts can format dates in, by ISO 639-1 code.
+var locales = map[string]localeNames{
+	"en": {
+		days:   [7]string{"Sun", "Mon", "Tue", "Wed", "Thu", "Fri", "Sat"},
+		months: [12]string{"Jan", "Feb", "Mar", "Apr", "May", "Jun", "Jul", "Aug", "Sep", "Oct", "Nov", "Dec"},
+		day:    func(w string, d int, m string) string { return fmt.Sprintf("%s %s %d", w, m, d) },
+		night:  "night", nights: "nights",
+	},
+	"es": {
+		days:   [7]string{"dom", "lun", "mar", "mié", "jue", "vie", "sáb"},
+		months: [12]string{"ene", "feb", "mar", "abr", "may", "jun", "jul", "ago", "sept", "oct", "nov", "dic"},
+		day:    func(w string, d int, m string) string { return fmt.Sprintf("%s %d %s", w, d, m) },
+		night:  "noche", nights: "noches",
+	},
+	"fr": {
+		days:   [7]string{"dim.", "lun.", "mar.", "mer.", "jeu.", "ven.", "sam."},
+		months: [12]string{"janv.", "févr.", "mars", "avr.", "mai", "juin", "juil.", "août", "sept.", "oct.", "nov.", "déc."},
+		day:    func(w string, d int, m string) string { return fmt.Sprintf("%s %d %s", w, d, m) },
+		night:  "nuit", nights: "nuits",
+	},
+	"de": {
+		days:   [7]string{"So.", "Mo.", "Di.", "Mi.", "Do.", "Fr.", "Sa."},
+		months: [12]string{"Jan.", "Feb.", "März", "Apr.", "Mai", "Juni", "Juli", "Aug.", "Sept.", "Okt.", "Nov.", "Dez."},
+		day:    func(w string, d int, m string) string { return fmt.Sprintf("%s, %d. %s", w, d, m) },
+		night:  "Nacht", nights: "Nächte",
+	},
+}
+
+// localeCode reduces a tag such as "en-US" or "fr_CA" to its language.
+func localeCode(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(locale)
+}
+
+// namesFor returns locale's names, falling back to English for a locale
+// that is unset or not supported.
+func namesFor(locale string) localeNames {
+	if names, ok := locales[localeCode(locale)]; ok {
+		return names
+	}
+	return locales[defaultLocale]
+}
+
+// FormatDay renders a stay date such as "Fri Jul 14" in locale.
+func FormatDay(d core.CivilDate, locale string) string {
+	names := namesFor(locale)
+	return names.day(names.days[d.Weekday()], d.Day, names.months[d.Month-1])
+}
+
+// FormatStay renders a stay such as "2 nights, Fri Jul 14 → Sun Jul 16" in
+// locale. Email, Slack, SMS, webhooks and the command line all use it, so
+// every channel describes a stay the same way.
+func FormatStay(arrival core.CivilDate, departure core.CivilDate, locale string) string {
+	names := namesFor(locale)
+	nights := core.NightsBetween(arrival, departure)
+	word := names.nights
+	if nights == 1 {
+		word = names.night
+	}
+	return fmt.Sprintf("%d %s, %s → %s", nights, word, FormatDay(arrival, locale), FormatDay(departure, locale))
+}
+
+// FormatInstant renders a moment, such as when availability was checked,
+// as "Fri Jul 14 15:04 PDT" in zone and locale. An unknown zone is shown in
+// Pacific time.
+func FormatInstant(t time.Time, zone string, locale string) string {
+	if zone == "" {
+		zone = defaultDisplayZone
+	}
+	loc, err := time.LoadLocation(zone)
+	if err != nil {
+		loc, _ = time.LoadLocation(defaultDisplayZone)
+	}
+	if loc != nil {
+		t = t.In(loc)
+	}
+	return FormatDay(core.CivilDateOf(t), locale) + " " + t.Format("15:04 MST")
+}
+
+// validLocale reports whether alerts can be formatted in locale.
+func validLocale(locale string) bool {
+	_, ok := locales[localeCode(locale)]
+	return ok
+}
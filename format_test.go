@@ -0,0 +1,83 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+func TestFormatStay(t *testing.T) {
+	arrival, departure := stayDate("2027-07-14"), stayDate("2027-07-16")
+	tests := []struct {
+		locale    string
+		departure core.CivilDate
+		want      string
+	}{
+		{"en", departure, "2 nights, Wed Jul 14 → Fri Jul 16"},
+		{"es", departure, "2 noches, mié 14 jul → vie 16 jul"},
+		{"fr", departure, "2 nuits, mer. 14 juil. → ven. 16 juil."},
+		{"de", departure, "2 Nächte, Mi., 14. Juli → Fr., 16. Juli"},
+		{"de", stayDate("2027-07-15"), "1 Nacht, Mi., 14. Juli → Do., 15. Juli"},
+		{"es-MX", departure, "2 noches, mié 14 jul → vie 16 jul"},
+		{"fr_CA", departure, "2 nuits, mer. 14 juil. → ven. 16 juil."},
+		{"", departure, "2 nights, Wed Jul 14 → Fri Jul 16"},
+		{"ja", departure, "2 nights, Wed Jul 14 → Fri Jul 16"},
+	}
+	for _, test := range tests {
+		if got := FormatStay(arrival, test.departure, test.locale); got != test.want {
+			t.Errorf("FormatStay(%q) = %q, want %q", test.locale, got, test.want)
+		}
+	}
+}
+
+// Stay dates are calendar days and never move with a time zone, even when
+// taken from a local midnight far from UTC.
+func TestFormatDay(t *testing.T) {
+	auckland, err := time.LoadLocation("Pacific/Auckland")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name   string
+		day    core.CivilDate
+		locale string
+		want   string
+	}{
+		{"new year's eve", stayDate("2027-12-31"), "en", "Fri Dec 31"},
+		{"new year's day", stayDate("2028-01-01"), "fr", "sam. 1 janv."},
+		{"march in german", stayDate("2027-03-01"), "de", "Mo., 1. März"},
+		{"local midnight ahead of UTC", core.CivilDateOf(time.Date(2027, 7, 14, 0, 0, 0, 0, auckland)), "en", "Wed Jul 14"},
+	}
+	for _, test := range tests {
+		if got := FormatDay(test.day, test.locale); got != test.want {
+			t.Errorf("%s: FormatDay = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+// An instant is converted to the zone before its day is read, so late on
+// July 14 in California is already July 15 in Paris.
+func TestFormatInstant(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Paris"); err != nil {
+		t.Skip(err)
+	}
+	at := time.Date(2027, 7, 15, 5, 30, 0, 0, time.UTC)
+	tests := []struct {
+		zone, locale string
+		want         string
+	}{
+		{"", "en", "Wed Jul 14 22:30 PDT"},
+		{"America/New_York", "en", "Thu Jul 15 01:30 EDT"},
+		{"Europe/Paris", "fr", "jeu. 15 juil. 07:30 CEST"},
+		{"Europe/Berlin", "de", "Do., 15. Juli 07:30 CEST"},
+		{"UTC", "es", "jue 15 jul 05:30 UTC"},
+		{"Not/AZone", "en", "Wed Jul 14 22:30 PDT"},
+		{"Asia/Tokyo", "xx", "Thu Jul 15 14:30 JST"},
+	}
+	for _, test := range tests {
+		if got := FormatInstant(at, test.zone, test.locale); got != test.want {
+			t.Errorf("FormatInstant(%q, %q) = %q, want %q", test.zone, test.locale, got, test.want)
+		}
+	}
+}
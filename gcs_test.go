@@ -0,0 +1,161 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/option"
+)
+
+// fakeGCS serves the part of the Cloud Storage API the package's markers and
+// records use: object metadata, listing by prefix, small uploads, which may
+// be conditional on the object's generation, and reads, by "bucket/object".
+// Each upload of an object bumps its generation.
+type fakeGCS struct {
+	mu      sync.Mutex
+	objects map[string]string
+	// acls holds each upload's predefined ACL, such as "publicRead".
+	acls map[string]string
+	gens map[string]int64
+	// metadata holds each object's custom metadata.
+	metadata map[string]map[string]string
+}
+
+func (f *fakeGCS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	// Once the client has written an object against an emulator host it
+	// drops the /storage/v1 prefix from JSON API paths.
+	path := strings.TrimPrefix(r.URL.Path, "/storage/v1")
+	switch {
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/b/") && strings.HasSuffix(path, "/o"):
+		bucket := strings.TrimSuffix(strings.TrimPrefix(path, "/b/"), "/o")
+		items := []map[string]string{}
+		for key := range f.objects {
+			if name := strings.TrimPrefix(key, bucket+"/"); name != key && strings.HasPrefix(name, r.URL.Query().Get("prefix")) {
+				items = append(items, map[string]string{"bucket": bucket, "name": name, "generation": strconv.FormatInt(f.gens[key], 10)})
+			}
+		}
+		sort.Slice(items, func(i, j int) bool { return items[i]["name"] < items[j]["name"] })
+		json.NewEncoder(w).Encode(map[string]interface{}{"kind": "storage#objects", "items": items})
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/b/"):
+		parts := strings.SplitN(strings.TrimPrefix(path, "/b/")+"/o/", "/o/", 3)
+		if _, ok := f.objects[parts[0]+"/"+parts[1]]; !ok {
+			http.Error(w, `{"error":{"code":404,"message":"Not Found"}}`, http.StatusNotFound)
+			return
+		}
+		key := parts[0] + "/" + parts[1]
+		json.NewEncoder(w).Encode(map[string]interface{}{"bucket": parts[0], "name": parts[1], "generation": strconv.FormatInt(f.gens[key], 10),
+			"metadata": f.metadata[key]})
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/upload/storage/v1/b/"):
+		bucket := strings.TrimSuffix(strings.TrimPrefix(path, "/upload/storage/v1/b/"), "/o")
+		name, metadata, content, err := readUpload(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		key := bucket + "/" + name
+		if match := r.URL.Query().Get("ifGenerationMatch"); match != "" && match != strconv.FormatInt(f.gens[key], 10) {
+			http.Error(w, `{"error":{"code":412,"message":"Precondition Failed"}}`, http.StatusPreconditionFailed)
+			return
+		}
+		f.objects[key] = content
+		f.metadata[key] = metadata
+		f.acls[key] = r.URL.Query().Get("predefinedAcl")
+		f.gens[key]++
+		json.NewEncoder(w).Encode(map[string]string{"bucket": bucket, "name": name, "generation": strconv.FormatInt(f.gens[key], 10)})
+	case r.Method == http.MethodGet:
+		key := strings.TrimPrefix(path, "/")
+		content, ok := f.objects[key]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("X-Goog-Generation", strconv.FormatInt(f.gens[key], 10))
+		w.Header().Set("X-Goog-Metageneration", "1")
+		io.WriteString(w, content)
+	default:
+		http.Error(w, "unexpected "+r.Method+" "+r.URL.Path, http.StatusNotImplemented)
+	}
+}
+
+// readUpload returns the object name, metadata and content of a multipart
+// upload.
+func readUpload(r *http.Request) (string, map[string]string, string, error) {
+	_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil {
+		return "", nil, "", err
+	}
+	parts := multipart.NewReader(r.Body, params["boundary"])
+	meta, err := parts.NextPart()
+	if err != nil {
+		return "", nil, "", err
+	}
+	object := struct {
+		Name     string
+		Metadata map[string]string
+	}{}
+	if err := json.NewDecoder(meta).Decode(&object); err != nil {
+		return "", nil, "", err
+	}
+	media, err := parts.NextPart()
+	if err != nil {
+		return "", nil, "", err
+	}
+	content, err := ioutil.ReadAll(media)
+	return object.Name, object.Metadata, string(content), err
+}
+
+// names lists the objects stored under prefix in bucket.
+func (f *fakeGCS) names(bucket, prefix string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	names := []string{}
+	for key := range f.objects {
+		if strings.HasPrefix(key, bucket+"/"+prefix) {
+			names = append(names, strings.TrimPrefix(key, bucket+"/"))
+		}
+	}
+	return names
+}
+
+// useFakeGCS points RESULTS_BUCKET and the package's storage client at a
+// fakeGCS until the test ends. Object reads go to the emulator host rather
+// than the JSON API, so STORAGE_EMULATOR_HOST is set to the fake as well.
+func useFakeGCS(t *testing.T, bucket string) *fakeGCS {
+	t.Helper()
+	fake := &fakeGCS{objects: map[string]string{}, acls: map[string]string{}, gens: map[string]int64{},
+		metadata: map[string]map[string]string{}}
+	server := httptest.NewServer(fake)
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(server.URL, "http://"))
+	client, err := storage.NewClient(context.Background(), option.WithEndpoint(server.URL+"/storage/v1/"),
+		option.WithoutAuthentication(), option.WithHTTPClient(server.Client()))
+	if err != nil {
+		t.Fatalf("storage.NewClient: %v", err)
+	}
+	t.Setenv("RESULTS_BUCKET", bucket)
+	clients.mu.Lock()
+	old := clients.storage
+	clients.storage = client
+	clients.mu.Unlock()
+	t.Cleanup(func() {
+		clients.mu.Lock()
+		clients.storage = old
+		clients.mu.Unlock()
+		client.Close()
+		server.Close()
+	})
+	return fake
+}
@@ -0,0 +1,215 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// updateGolden rewrites the golden files under testdata instead of comparing
+// against them: go test -run Golden -update.
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares got with testdata/golden/name, or writes it there
+// with -update.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name)
+	if *updateGolden {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -run %s -update to create it", err, t.Name())
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the golden file; run with -update if the change is intended\ngot:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// goldenAlert is a watch's alert as matchWatch would build it for a stay of
+// July 14 to 16, 2027 at Upper Pines.
+func goldenAlert() alert {
+	return alert{
+		JobName:        "projects/p/locations/l/jobs/watch-232447-20270714-20270716",
+		CampgroundID:   "232447",
+		CampgroundName: "Upper Pines",
+		Sites:          []string{"1001", "1002"},
+		SiteNames:      map[string]string{"1001": "A001", "1002": "A002"},
+		SiteTypes:      map[string]string{"1001": "STANDARD NONELECTRIC", "1002": "TENT ONLY NONELECTRIC"},
+		Arrival:        core.CivilDate{Year: 2027, Month: 7, Day: 14},
+		Departure:      core.CivilDate{Year: 2027, Month: 7, Day: 16},
+		ScannedAt:      time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+// renderAll renders a for every channel, in a form that diffs well.
+func renderAll(t *testing.T, a alert) []byte {
+	subject, plain, htmlContent, err := renderEmail(a)
+	if err != nil {
+		t.Fatalf("renderEmail: %v", err)
+	}
+	webhook, err := json.MarshalIndent(webhookPayload(a), "", "  ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sections := []string{
+		"== email subject ==", subject,
+		"== email plain ==", plain,
+		"== email html ==", htmlContent,
+		"== sms ==", strings.Join(smsMessages(a, defaultSMSParts), "\n"),
+		"== slack ==", slackAlertMessage(a).Text,
+		"== webhook ==", string(webhook),
+	}
+	return []byte(strings.Join(sections, "\n") + "\n")
+}
+
+func TestAlertGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		alert func(a *alert)
+	}{
+		{"plain", func(a *alert) {}},
+		{"priority", func(a *alert) {
+			a.Sites = []string{"1002", "1001"}
+			a.Primary = "1002"
+		}},
+		{"prices", func(a *alert) {
+			a.Prices = map[string]core.StayPrice{
+				"1001": {Nights: 2, Total: 7000, MaxNightly: 3500},
+				"1002": {Nights: 2, Total: 5200, MaxNightly: 2600},
+			}
+		}},
+		{"partial", func(a *alert) {
+			a.OpenRanges = map[string]core.Run{
+				"1001": {Site: "1001", Start: a.Arrival, End: a.Departure},
+				"1002": {Site: "1002", Start: a.Arrival, End: a.Arrival.AddDays(1)},
+			}
+		}},
+		{"flexible departure", func(a *alert) {
+			a.Departure = a.Arrival.AddDays(4)
+			a.StayNights = map[string]int{"1001": 4, "1002": 2}
+		}},
+		{"rehearsal", func(a *alert) {
+			a.Sites = append([]string{rehearsalLabel}, a.Sites...)
+			a.Rehearsal = true
+		}},
+		{"unlisted", func(a *alert) {
+			a.Sites = append(a.Sites, "1004")
+			a.SiteNames["1004"] = "A004"
+			a.UnknownAttributes = map[string]bool{"1004": true}
+			a.Prices = map[string]core.StayPrice{
+				"1001": {Nights: 2, Total: 7000, MaxNightly: 3500},
+				"1002": {Nights: 2, Total: 5200, MaxNightly: 2600},
+			}
+		}},
+		{"group sites", func(a *alert) {
+			a.Sites = append(a.Sites, "1003")
+			a.SiteNames["1003"], a.SiteTypes["1003"] = "G01", "GROUP STANDARD NONELECTRIC"
+			a.Remaining = map[string]int{"1003": 2}
+		}},
+		{"trimmed", func(a *alert) {
+			a.CampgroundName = ""
+			a.Trimmed = []string{"names", "fees", "weather"}
+		}},
+		{"weather", func(a *alert) {
+			// The forecast reaches the stay's first night only.
+			high, low, chance := 72, 48, 20
+			a.Forecast = []NightForecast{{Date: a.Arrival, High: &high, Low: &low, Unit: "F", PrecipChance: &chance, Summary: "Mostly Sunny"}}
+		}},
+		{"requested sites", func(a *alert) {
+			a.Sites = []string{"1001"}
+			a.Requested = &requestedSites{Available: []string{"1001"}, Booked: []string{"1002"}, Missing: []string{"9999"}}
+		}},
+		{"booking notes", func(a *alert) {
+			a.BookingNotes = "If you cancel within 48 hours of your arrival date, you will be charged the first night's fee. Reservation fee $8.00. Check-in 12:00 PM, check-out 11:00 AM."
+		}},
+		{"time to book", func(a *alert) {
+			a.TypicallyGone = 14 * time.Minute
+		}},
+		{"site monitor", func(a *alert) {
+			a.MonitoredSite, a.ChangedNights = "1001", 12
+			a.Sites, a.NightChanges = nil, map[string]nightChange{}
+			for _, c := range []nightChange{
+				{Night: a.Arrival, Before: "Reserved", After: "Available"},
+				{Night: a.Arrival.AddDays(1), Before: "Available", After: "Reserved"},
+			} {
+				a.Sites = append(a.Sites, c.label())
+				a.NightChanges[c.label()] = c
+			}
+		}},
+		{"window matrix", func(a *alert) {
+			a.Departure = a.Arrival.AddDays(9)
+			a.Sites = []string{"1001", "1002", "1003"}
+			a.SiteNames["1003"], a.SiteTypes["1003"] = "G01", "GROUP STANDARD NONELECTRIC"
+			first, second := a.Arrival, a.Arrival.AddDays(6)
+			a.Runs = []core.Run{
+				{Site: "1001", Start: first, End: first.AddDays(2)},
+				{Site: "1002", Start: first, End: first.AddDays(2)},
+				{Site: "1002", Start: second, End: second.AddDays(2)},
+				{Site: "1003", Start: second, End: second.AddDays(2)},
+			}
+		}},
+		{"tour", func(a *alert) {
+			a.CampgroundID, a.CampgroundName, a.SiteNames, a.SiteTypes = "234636", "", nil, nil
+			a.Departure = a.Arrival.AddDays(1)
+			a.Sites, a.Tours = nil, map[string]core.TourSlot{}
+			for _, slot := range []core.TourSlot{
+				{TourID: "2122", Date: a.Arrival, Time: "13:30", Remaining: 4, Total: 20},
+				{TourID: "2123", Date: a.Arrival, Time: "16:00", Remaining: 11, Total: 12},
+			} {
+				label := tourLabel(slot, time.FixedZone("MDT", -6*60*60))
+				a.Sites = append(a.Sites, label)
+				a.Tours[label] = slot
+			}
+		}},
+		{"campgrounds not checked", func(a *alert) {
+			group := *a
+			group.JobName, group.Sites = "", []string{"1001"}
+			a.CampgroundID = "232447, 232450, 232451"
+			a.Sites, a.Groups = []string{"232447: 1001"}, []alert{group}
+			a.Failed = map[string]string{"232450": "closed for the season on these dates"}
+			a.Paused = map[string]string{"232451": "campground 232451 is blocklisted"}
+		}},
+		{"partial result", func(a *alert) {
+			a.Partial = &core.PartialResultError{Checked: 2, Total: 3, Err: context.DeadlineExceeded}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			a := goldenAlert()
+			test.alert(&a)
+			checkGolden(t, strings.Replace(test.name, " ", "-", -1)+".golden", renderAll(t, a))
+		})
+	}
+}
+
+func TestDiffReplay(t *testing.T) {
+	golden := map[string]string{"a": "same", "b": "old", "c": "gone"}
+	got := map[string]string{"a": "same", "b": "new", "d": "fresh"}
+	want := []ReplayDiff{
+		{Job: "b", Change: ReplayChanged, Want: "old", Got: "new"},
+		{Job: "c", Change: ReplayRemoved, Want: "gone"},
+		{Job: "d", Change: ReplayAdded, Got: "fresh"},
+	}
+	diffs := DiffReplay(golden, got)
+	if len(diffs) != len(want) {
+		t.Fatalf("DiffReplay = %+v, want %+v", diffs, want)
+	}
+	for i := range want {
+		if diffs[i] != want[i] {
+			t.Errorf("diff %d = %+v, want %+v", i, diffs[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// isReservationGuard reports whether m watches over a stay already booked
+// instead of looking for one.
+func (m MessageContent) isReservationGuard() bool {
+	return m.Kind == kindReservationGuard
+}
+
+// noticePrefix marks a guard's stored notices apart from its nights.
+const noticePrefix = "notice="
+
+// closureWording picks out the notices that close something, rather than
+// those about fees, bears or opening dates.
+var closureWording = regexp.MustCompile(`(?i)\b(closed|closes|closing|closure|closures|evacuat\w*)\b`)
+
+// guardSnapshot is what a guard compares between runs: the site's calendar
+// from siteSnapshot followed by the campground's closure notices, each as
+// noticePrefix and its text.
+func guardSnapshot(site core.Campsite, notices []string, start, end core.CivilDate) []string {
+	snapshot := siteSnapshot(site, start, end)
+	for _, notice := range notices {
+		if closureWording.MatchString(notice) {
+			snapshot = append(snapshot, noticePrefix+notice)
+		}
+	}
+	return snapshot
+}
+
+// guardClosures lists what closed between two guard snapshots: nights now
+// Closed that were not, and closure notices not seen before. With no
+// previous snapshot everything closed counts, so a guard created after a
+// closure still warns. Nights reopening and notices taken down are not
+// reported; a guard never alerts on availability.
+func guardClosures(previous []string, current []string) (nights []core.CivilDate, notices []string) {
+	seen, before := map[string]bool{}, map[string]string{}
+	for _, entry := range previous {
+		seen[entry] = true
+		if i := strings.Index(entry, "="); i > 0 {
+			before[entry[:i]] = entry[i+1:]
+		}
+	}
+	for _, entry := range current {
+		if strings.HasPrefix(entry, noticePrefix) {
+			if !seen[entry] {
+				notices = append(notices, strings.TrimPrefix(entry, noticePrefix))
+			}
+			continue
+		}
+		i := strings.Index(entry, "=")
+		if i < 0 || core.ParseStatus(entry[i+1:]) != core.StatusClosed {
+			continue
+		}
+		if old, ok := before[entry[:i]]; ok && core.ParseStatus(old) == core.StatusClosed {
+			continue
+		}
+		if night, err := core.ParseCivilDate(entry[:i]); err == nil {
+			nights = append(nights, night)
+		}
+	}
+	return nights, notices
+}
+
+// guardReservation runs a reservation-guard watch: it reads the booked
+// site's calendar from Arrival, or today once that has passed, up to
+// Departure, and the campground's notices, and emails the watch's
+// recipient when a night turns Closed or a closure notice is posted. What
+// was read is stored only once the warning went out, so a failed send is
+// retried. The watch never deletes itself; it expires after Departure.
+func (s *Scraper) guardReservation(ctx context.Context, m MessageContent, run *watchRun, rehearsal bool) error {
+	start, departure := stayDate(m.Arrival), stayDate(m.Departure)
+	if today := m.today(); start.Before(today) {
+		start = today
+	}
+	site := m.CampsiteIDs[0]
+	run.alert = alert{JobName: m.Name, CampgroundID: m.Campground, Arrival: start, Departure: departure, ScannedAt: s.now().UTC()}
+	campground, err := core.FetchRange(ctx, s.providerFor(m), m.Campground, start, departure)
+	if err != nil {
+		run.outcome = outcomeScrapeError
+		return fmt.Errorf("job %s: scraping campground %s: %w", m.Name, m.Campground, err)
+	}
+	campsite, ok := campground.Campsites[site]
+	if !ok {
+		run.outcome = outcomeInvalid
+		return fmt.Errorf("job %s: %w", m.Name, &ValidationError{Field: "CampsiteIDs", Value: site, Reason: "is not a campsite of campground " + m.Campground})
+	}
+	// Not the cached facility: notices are what the guard is watching for.
+	f, err := s.Provider().FetchFacility(ctx, m.Campground)
+	if err != nil {
+		run.outcome = outcomeScrapeError
+		return fmt.Errorf("job %s: reading campground %s's notices: %w", m.Name, m.Campground, err)
+	}
+	snapshot := guardSnapshot(campsite, f.Notices, start, departure)
+	previous, err := scanStore.LastScan(ctx, m.Name)
+	if err != nil {
+		run.outcome = outcomeScrapeError
+		return fmt.Errorf("job %s: reading the last scan: %w", m.Name, err)
+	}
+	nights, notices := guardClosures(previous, snapshot)
+	run.sites = len(nights) + len(notices)
+	if run.dryRun {
+		run.outcome = outcomeNoneFound
+		if run.sites > 0 {
+			run.outcome = outcomeFound
+		}
+		return nil
+	}
+	if run.sites == 0 && !rehearsal {
+		run.outcome = outcomeNoneFound
+		saveSnapshot(ctx, m, snapshot)
+		return nil
+	}
+	name := f.Name
+	if name == "" {
+		name = "campground " + m.Campground
+	}
+	siteName := site
+	if campsite.Site != "" {
+		siteName = campsite.Site
+	}
+	if err := sendGuardNotice(ctx, m, name, siteName, nights, notices, rehearsal); err != nil {
+		run.outcome = outcomeNotifyFailed
+		return fmt.Errorf("job %s: sending the closure warning: %w", m.Name, err)
+	}
+	run.outcome = outcomeNotified
+	run.notified = true
+	if !rehearsal {
+		saveSnapshot(ctx, m, snapshot)
+	}
+	return nil
+}
+
+// sendGuardNotice warns m's recipient that their booked site closed on
+// nights, or that the campground posted notices. A rehearsal says so and
+// may list nothing.
+func sendGuardNotice(ctx context.Context, m MessageContent, name string, site string, nights []core.CivilDate, notices []string, rehearsal bool) error {
+	subject := fmt.Sprintf("Possible closure at %s for your stay", name)
+	stay := FormatStay(stayDate(m.Arrival), stayDate(m.Departure), m.Locale)
+	lines := []string{fmt.Sprintf("Something changed at %s that may affect your reservation of site %s, %s.", name, site, stay)}
+	if rehearsal {
+		subject = "Rehearsal: " + subject
+		lines[0] = fmt.Sprintf("This is a rehearsal of the reservation guard for site %s at %s, %s.", site, name, stay)
+	}
+	if len(nights) > 0 {
+		days := []string{}
+		for _, night := range nights {
+			days = append(days, FormatDay(night, m.Locale))
+		}
+		lines = append(lines, fmt.Sprintf("Site %s is now Closed on %s.", site, strings.Join(days, ", ")))
+	}
+	for _, notice := range notices {
+		lines = append(lines, "Notice: "+notice)
+	}
+	lines = append(lines, "Check the site before you go: "+campsiteURL+m.CampsiteIDs[0])
+	htmlBody := ""
+	for _, line := range lines {
+		htmlBody += "<p>" + html.EscapeString(line) + "</p>"
+	}
+	return sendNoticeTo(ctx, m.recipient(), subject, strings.Join(lines, "\n\n"), htmlBody)
+}
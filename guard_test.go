@@ -0,0 +1,165 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// guardWatch guards a booking of site A001 at e2eFixture's campground for
+// the nights of July 14 and 15.
+func guardWatch(id string) scraper.MessageContent {
+	m := e2eWatch(id)
+	m.Kind, m.CampsiteIDs = "reservation-guard", []string{"1001"}
+	return m
+}
+
+// guardFixture is e2eFixture with A001's given nights and the campground's
+// notices.
+func guardFixture(nights map[string]string, notices ...string) fakerecgov.Fixture {
+	f := e2eFixture(false)
+	f.Campgrounds[0].Sites[0].Nights = nights
+	f.Campgrounds[0].Notices = notices
+	return f
+}
+
+// A reservation guard warns once for each night of the booked site that
+// closes and each closure notice posted, never for availability, keeps
+// running after warning and ends once the stay is over.
+func TestReservationGuard(t *testing.T) {
+	type step struct {
+		nights  map[string]string
+		notices []string
+		// day is the day of June 2027 the step runs on; zero is June 1.
+		day int
+		// wantNotice is a line the step's one notice has, if any.
+		wantNotice string
+	}
+	tests := []struct {
+		name      string
+		steps     []step
+		wantEnded bool
+	}{
+		{"quiet", []step{
+			{notices: []string{"Bear boxes are required."}},
+			{nights: map[string]string{"2027-07-14": "Available"}},
+			{nights: map[string]string{"2027-07-14": "Not Reservable"}, notices: []string{"Bear boxes are required.", "Loop A reopens June 1."}},
+		}, false},
+		{"site closes", []step{
+			{},
+			{nights: map[string]string{"2027-07-15": "Closed"}, wantNotice: "Site A001 is now Closed on Thu Jul 15."},
+			{nights: map[string]string{"2027-07-15": "Closed"}},
+			{},
+			{nights: map[string]string{"2027-07-14": "Closed", "2027-07-15": "Closed"}, wantNotice: "Site A001 is now Closed on Wed Jul 14, Thu Jul 15."},
+		}, false},
+		{"closure notice", []step{
+			{notices: []string{"Bear boxes are required."}},
+			{notices: []string{"Bear boxes are required.", "Loop A is closed due to fire."}, wantNotice: "Notice: Loop A is closed due to fire."},
+			{notices: []string{"Loop A is closed due to fire."}},
+		}, false},
+		{"closed when created", []step{
+			{nights: map[string]string{"2027-07-14": "Closed"}, notices: []string{"Evacuation ordered for the valley."},
+				wantNotice: "Notice: Evacuation ordered for the valley."},
+		}, false},
+		{"stay over", []step{
+			{},
+			{day: 45},
+			{day: 47},
+		}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(guardFixture(nil))
+			defer h.Close()
+			m := guardWatch("guard-" + strings.Replace(test.name, " ", "-", -1))
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			if err := m.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			seen := 0
+			for i, s := range test.steps {
+				day := s.day
+				if day == 0 {
+					day = 1
+				}
+				h.Clock.Set(time.Date(2027, 6, day, 12, 0, 0, 0, time.UTC))
+				h.Server.SetFixture(guardFixture(s.nights, s.notices...))
+				var err error
+				if i == 0 {
+					err = h.Run(context.Background(), m)
+				} else {
+					err = h.Fire(context.Background(), m.Name)
+				}
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				notices := h.Notifier.Notices()[seen:]
+				seen += len(notices)
+				if test.wantEnded && i == len(test.steps)-1 {
+					break
+				}
+				if len(notices) != 0 && s.wantNotice == "" || len(notices) != 1 && s.wantNotice != "" {
+					t.Fatalf("step %d: got notices %+v, want one saying %q", i, notices, s.wantNotice)
+				}
+				if len(notices) == 1 && (!strings.Contains(notices[0].Plain, s.wantNotice) || !strings.Contains(notices[0].Subject, "Possible closure at Upper Pines")) {
+					t.Errorf("step %d: notice %q: %q, want %q", i, notices[0].Subject, notices[0].Plain, s.wantNotice)
+				}
+				if h.Scheduler.Job(m.Name) == nil {
+					t.Fatalf("step %d: the guard deleted itself", i)
+				}
+			}
+			if alerts := h.Notifier.Alerts(); len(alerts) != 0 {
+				t.Errorf("got alerts %+v, want none", alerts)
+			}
+			if !test.wantEnded {
+				return
+			}
+			notices := h.Notifier.Notices()
+			if h.Scheduler.Job(m.Name) != nil || len(notices) != 1 || !strings.Contains(notices[0].Subject, "Reservation guard") {
+				t.Errorf("after the stay: job %v, notices %+v, want the guard removed with its ending notice", h.Scheduler.Job(m.Name), notices)
+			}
+		})
+	}
+}
+
+// A reservation guard names exactly one campsite, may be running during
+// its stay, and takes none of the options for finding one.
+func TestReservationGuardValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		watch     func(m *scraper.MessageContent)
+		wantField string
+	}{
+		{"valid", func(m *scraper.MessageContent) {}, ""},
+		{"during the stay", func(m *scraper.MessageContent) { m.Arrival, m.Departure = "2027-05-30", "2027-06-03" }, ""},
+		{"no campsite", func(m *scraper.MessageContent) { m.CampsiteIDs = nil }, "CampsiteIDs"},
+		{"two campsites", func(m *scraper.MessageContent) { m.CampsiteIDs = []string{"1001", "1002"} }, "CampsiteIDs"},
+		{"several campgrounds", func(m *scraper.MessageContent) { m.Campgrounds = []string{"232447", "232450"} }, "Campgrounds"},
+		{"keep job", func(m *scraper.MessageContent) { m.KeepJob = true }, "KeepJob"},
+		{"track changes", func(m *scraper.MessageContent) { m.TrackChanges = true }, "TrackChanges"},
+		{"expire after", func(m *scraper.MessageContent) { m.ExpireAfter = "departure" }, "ExpireAfter"},
+		{"price", func(m *scraper.MessageContent) { m.MaxNightlyPrice = 40 }, "MaxNightlyPrice"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(guardFixture(nil))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := guardWatch("guard-validate")
+			test.watch(&m)
+			err := m.Validate()
+			var invalid *scraper.ValidationError
+			switch {
+			case test.wantField == "" && err != nil:
+				t.Errorf("Validate: %v, want nil", err)
+			case test.wantField != "" && (!errors.As(err, &invalid) || invalid.Field != test.wantField):
+				t.Errorf("Validate: %v, want an invalid %s", err, test.wantField)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// credentialHeaderWords mark header names whose values are never logged.
+var credentialHeaderWords = []string{"auth", "cookie", "token", "key", "secret", "session"}
+
+// providerFor returns DefaultScraper's cached provider for a watch; see
+// Scraper.providerFor.
+func providerFor(m MessageContent) core.Provider {
+	return DefaultScraper.providerFor(m)
+}
+
+// providerFor returns s's cached provider for a watch, applying its header
+// overrides when the operator has enabled them, or the replayed months when
+// s.ReplayDir is set. Overrides are an admin-only
+// escape hatch for when recreation.gov starts blocking the default
+// fingerprint.
+func (s *Scraper) providerFor(m MessageContent) core.Provider {
+	if s.ReplayDir != "" {
+		return s.availability()
+	}
+	provider := s.Provider()
+	if m.UserAgent == "" && len(m.Headers) == 0 {
+		return historyProvider{s.Cached(provider)}
+	}
+	if os.Getenv("ALLOW_HEADER_OVERRIDES") != "true" {
+		logger.Printf("job %s: ignoring request header overrides because ALLOW_HEADER_OVERRIDES is not set", m.Name)
+		return historyProvider{s.Cached(provider)}
+	}
+	if m.UserAgent != "" {
+		provider.UserAgent = m.UserAgent
+	}
+	provider.Header = http.Header{}
+	for key, value := range m.Headers {
+		provider.Header.Set(key, value)
+	}
+	logger.Printf("job %s: overriding request headers: User-Agent=%q %s", m.Name, m.UserAgent, describeHeaders(provider.Header))
+	return historyProvider{s.Cached(provider)}
+}
+
+// describeHeaders renders headers for logging, hiding the values of any
+// that look like credentials.
+func describeHeaders(h http.Header) string {
+	keys := make([]string, 0, len(h))
+	for key := range h {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		value := strings.Join(h[key], ",")
+		if looksLikeCredential(key) {
+			value = "[REDACTED]"
+		}
+		parts = append(parts, key+"="+Redact(value))
+	}
+	return strings.Join(parts, " ")
+}
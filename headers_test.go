@@ -0,0 +1,26 @@
+package scraper
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestDescribeHeaders(t *testing.T) {
+	t.Setenv("SENDGRID_API_KEY", "SG.abcdefgh12345")
+	h := http.Header{}
+	h.Set("Authorization", "Bearer abc123")
+	h.Set("Cookie", "session=xyz")
+	h.Set("X-Api-Key", "k")
+	h.Set("X-Session-Id", "s-1")
+	h.Set("X-Client-Secret", "shh")
+	h.Set("Accept-Language", "en-US")
+	h.Set("Referer", "https://www.recreation.gov/camping/campgrounds/232447")
+	h.Set("From", "jane@example.com")
+	h.Set("X-Forwarded", "SG.abcdefgh12345")
+	want := "Accept-Language=en-US Authorization=[REDACTED] Cookie=[REDACTED] From=j***@example.com " +
+		"Referer=https://www.recreation.gov/camping/campgrounds/232447 X-Api-Key=[REDACTED] X-Client-Secret=[REDACTED] " +
+		"X-Forwarded=[REDACTED] X-Session-Id=[REDACTED]"
+	if got := describeHeaders(h); got != want {
+		t.Errorf("describeHeaders() = %q, want %q", got, want)
+	}
+}
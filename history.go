@@ -0,0 +1,489 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+	bigquery "google.golang.org/api/bigquery/v2"
+	"google.golang.org/api/googleapi"
+)
+
+// DefaultHistoryBatch is how many rows a HistoryBatcher holds before it
+// flushes, the most BigQuery recommends per streaming insert.
+const DefaultHistoryBatch = 500
+
+// HistoryRow is one campsite's status on one night as a scrape saw it.
+type HistoryRow struct {
+	ScrapedAt    time.Time
+	CampgroundID string
+	JobName      string
+	CampsiteID   string
+	// Night is the date of the night, such as "2023-07-14".
+	Night  string
+	Status string
+	// Backfilled marks rows Backfill read from a past month rather than a
+	// watch's scrape; they hold each night's final status.
+	Backfilled bool
+}
+
+// Sink stores scrape history. Implementations must be safe for concurrent
+// use.
+type Sink interface {
+	Insert(ctx context.Context, rows []HistoryRow) error
+}
+
+// HistoryReader is a Sink whose history can be read back, as EstimateOdds
+// does.
+type HistoryReader interface {
+	// ReadHistory returns every row stored for campgroundID's nights from
+	// start up to but not including end, in no particular order.
+	ReadHistory(ctx context.Context, campgroundID string, start time.Time, end time.Time) ([]HistoryRow, error)
+}
+
+// BookingSink is a Sink that also stores the openings scrapes saw booked,
+// so their time to book can be analysed with the history.
+type BookingSink interface {
+	InsertBookings(ctx context.Context, openings []Opening) error
+}
+
+// MemorySink keeps every row and booked opening in memory, for tests.
+type MemorySink struct {
+	mu       sync.Mutex
+	rows     []HistoryRow
+	inserts  int
+	bookings []Opening
+}
+
+// Insert implements Sink.
+func (s *MemorySink) Insert(ctx context.Context, rows []HistoryRow) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows = append(s.rows, rows...)
+	s.inserts++
+	return nil
+}
+
+// Rows returns the rows inserted so far, oldest first.
+func (s *MemorySink) Rows() []HistoryRow {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]HistoryRow{}, s.rows...)
+}
+
+// Inserts counts the calls to Insert, one per batch flushed.
+func (s *MemorySink) Inserts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inserts
+}
+
+// ReadHistory implements HistoryReader.
+func (s *MemorySink) ReadHistory(ctx context.Context, campgroundID string, start time.Time, end time.Time) ([]HistoryRow, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	from, to := core.CivilDateOf(start).String(), core.CivilDateOf(end).String()
+	rows := []HistoryRow{}
+	for _, row := range s.rows {
+		if row.CampgroundID == campgroundID && row.Night >= from && row.Night < to {
+			rows = append(rows, row)
+		}
+	}
+	return rows, nil
+}
+
+// InsertBookings implements BookingSink.
+func (s *MemorySink) InsertBookings(ctx context.Context, openings []Opening) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.bookings = append(s.bookings, openings...)
+	return nil
+}
+
+// Bookings returns the booked openings inserted so far, oldest first.
+func (s *MemorySink) Bookings() []Opening {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Opening{}, s.bookings...)
+}
+
+// HistoryBatcher buffers rows for Sink, inserting them Size at a time
+// (DefaultHistoryBatch when zero) and whatever is left on Flush. Insert
+// failures are logged and the rows dropped, so history never fails a
+// scrape. It is safe for concurrent use.
+type HistoryBatcher struct {
+	Sink Sink
+	Size int
+
+	mu      sync.Mutex
+	pending []HistoryRow
+}
+
+// Add buffers rows, inserting full batches as they fill.
+func (b *HistoryBatcher) Add(ctx context.Context, rows ...HistoryRow) {
+	size := b.Size
+	if size <= 0 {
+		size = DefaultHistoryBatch
+	}
+	b.mu.Lock()
+	b.pending = append(b.pending, rows...)
+	batches := [][]HistoryRow{}
+	for len(b.pending) >= size {
+		batches = append(batches, b.pending[:size:size])
+		b.pending = b.pending[size:]
+	}
+	b.mu.Unlock()
+	for _, batch := range batches {
+		b.insert(ctx, batch)
+	}
+}
+
+// Flush inserts every buffered row.
+func (b *HistoryBatcher) Flush(ctx context.Context) {
+	b.mu.Lock()
+	batch := b.pending
+	b.pending = nil
+	b.mu.Unlock()
+	if len(batch) > 0 {
+		b.insert(ctx, batch)
+	}
+}
+
+// AddBookings stores booked openings straight away when Sink is a
+// BookingSink, and drops them otherwise. They are few, so they are not
+// batched.
+func (b *HistoryBatcher) AddBookings(ctx context.Context, openings []Opening) {
+	sink, ok := b.Sink.(BookingSink)
+	if !ok || len(openings) == 0 {
+		return
+	}
+	if err := sink.InsertBookings(ctx, openings); err != nil {
+		logger.Printf("dropping %d booked openings: %v", len(openings), err)
+	}
+}
+
+func (b *HistoryBatcher) insert(ctx context.Context, rows []HistoryRow) {
+	if err := b.Sink.Insert(ctx, rows); err != nil {
+		logger.Printf("dropping %d scrape history rows: %v", len(rows), err)
+	}
+}
+
+// historySchema is the layout BigQuerySink writes, partitioned by day of
+// scraped_at.
+var historySchema = &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+	{Name: "scraped_at", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	{Name: "campground_id", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "job_name", Type: "STRING"},
+	{Name: "campsite_id", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "night", Type: "DATE", Mode: "REQUIRED"},
+	{Name: "status", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "backfilled", Type: "BOOLEAN"},
+}}
+
+// bookingsSchema is the layout of the bookings table beside the history,
+// one row per opening seen booked, partitioned by day of booked_at.
+var bookingsSchema = &bigquery.TableSchema{Fields: []*bigquery.TableFieldSchema{
+	{Name: "booked_at", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	{Name: "first_seen", Type: "TIMESTAMP", Mode: "REQUIRED"},
+	{Name: "seconds_to_book", Type: "INTEGER", Mode: "REQUIRED"},
+	{Name: "campground_id", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "campsite_id", Type: "STRING", Mode: "REQUIRED"},
+	{Name: "night", Type: "DATE", Mode: "REQUIRED"},
+}}
+
+// BigQuerySink streams rows into a BigQuery table, and booked openings into
+// the table of the same name with "_bookings" after it. EnsureSchema creates
+// both.
+type BigQuerySink struct {
+	Project string
+	Dataset string
+	Table   string
+}
+
+// Insert implements Sink. A row BigQuery rejects fails the whole call,
+// though the rest of the batch may already be stored.
+func (s BigQuerySink) Insert(ctx context.Context, rows []HistoryRow) error {
+	svc, err := bigquery.NewService(ctx)
+	if err != nil {
+		return err
+	}
+	request := &bigquery.TableDataInsertAllRequest{}
+	for _, row := range rows {
+		// Lets BigQuery drop the duplicates of a retried insert, and of a
+		// backfilled night backfilled again.
+		id := fmt.Sprintf("%s/%s/%s/%d", row.CampgroundID, row.CampsiteID, row.Night, row.ScrapedAt.UnixNano())
+		if row.Backfilled {
+			id = fmt.Sprintf("backfill/%s/%s/%s", row.CampgroundID, row.CampsiteID, row.Night)
+		}
+		request.Rows = append(request.Rows, &bigquery.TableDataInsertAllRequestRows{
+			InsertId: id,
+			Json: map[string]bigquery.JsonValue{
+				"scraped_at":    row.ScrapedAt.UTC().Format(time.RFC3339Nano),
+				"campground_id": row.CampgroundID,
+				"job_name":      row.JobName,
+				"campsite_id":   row.CampsiteID,
+				"night":         row.Night,
+				"status":        row.Status,
+				"backfilled":    row.Backfilled,
+			},
+		})
+	}
+	return s.insertAll(ctx, svc, s.Table, request)
+}
+
+// InsertBookings implements BookingSink.
+func (s BigQuerySink) InsertBookings(ctx context.Context, openings []Opening) error {
+	svc, err := bigquery.NewService(ctx)
+	if err != nil {
+		return err
+	}
+	request := &bigquery.TableDataInsertAllRequest{}
+	for _, o := range openings {
+		request.Rows = append(request.Rows, &bigquery.TableDataInsertAllRequestRows{
+			InsertId: fmt.Sprintf("%s/%s/%s/%d", o.CampgroundID, o.CampsiteID, o.Night, o.Booked.UnixNano()),
+			Json: map[string]bigquery.JsonValue{
+				"booked_at":       o.Booked.UTC().Format(time.RFC3339Nano),
+				"first_seen":      o.FirstSeen.UTC().Format(time.RFC3339Nano),
+				"seconds_to_book": int64(o.TimeToBook() / time.Second),
+				"campground_id":   o.CampgroundID,
+				"campsite_id":     o.CampsiteID,
+				"night":           o.Night,
+			},
+		})
+	}
+	return s.insertAll(ctx, svc, s.bookingsTable(), request)
+}
+
+// ReadHistory implements HistoryReader with a query over s's table, which
+// must be in the same project.
+func (s BigQuerySink) ReadHistory(ctx context.Context, campgroundID string, start time.Time, end time.Time) ([]HistoryRow, error) {
+	svc, err := bigquery.NewService(ctx)
+	if err != nil {
+		return nil, err
+	}
+	legacy := false
+	param := func(name string, kind string, value string) *bigquery.QueryParameter {
+		return &bigquery.QueryParameter{Name: name, ParameterType: &bigquery.QueryParameterType{Type: kind}, ParameterValue: &bigquery.QueryParameterValue{Value: value}}
+	}
+	resp, err := svc.Jobs.Query(s.Project, &bigquery.QueryRequest{
+		Query: fmt.Sprintf("SELECT scraped_at, campsite_id, CAST(night AS STRING), status, COALESCE(backfilled, FALSE) FROM `%s.%s.%s`"+
+			" WHERE campground_id = @campground AND night >= @start AND night < @end", s.Project, s.Dataset, s.Table),
+		UseLegacySql:  &legacy,
+		ParameterMode: "NAMED",
+		QueryParameters: []*bigquery.QueryParameter{
+			param("campground", "STRING", campgroundID),
+			param("start", "DATE", core.CivilDateOf(start).String()),
+			param("end", "DATE", core.CivilDateOf(end).String()),
+		},
+	}).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("querying %s.%s: %v", s.Dataset, s.Table, err)
+	}
+	rows := []HistoryRow{}
+	job, results, complete, token := resp.JobReference, resp.Rows, resp.JobComplete, resp.PageToken
+	for {
+		for _, r := range results {
+			row, err := historyRowOf(campgroundID, r)
+			if err != nil {
+				return nil, fmt.Errorf("reading %s.%s: %v", s.Dataset, s.Table, err)
+			}
+			rows = append(rows, row)
+		}
+		if complete && token == "" {
+			return rows, nil
+		}
+		call := svc.Jobs.GetQueryResults(job.ProjectId, job.JobId).Location(job.Location).Context(ctx)
+		if token != "" {
+			call = call.PageToken(token)
+		}
+		more, err := call.Do()
+		if err != nil {
+			return nil, fmt.Errorf("querying %s.%s: %v", s.Dataset, s.Table, err)
+		}
+		results, complete, token = more.Rows, more.JobComplete, more.PageToken
+	}
+}
+
+// historyRowOf reads one row of ReadHistory's query. BigQuery gives
+// timestamps as seconds since the epoch, such as "1.6886232E9".
+func historyRowOf(campgroundID string, r *bigquery.TableRow) (HistoryRow, error) {
+	cells := make([]string, len(r.F))
+	for i, cell := range r.F {
+		cells[i], _ = cell.V.(string)
+	}
+	if len(cells) != 5 {
+		return HistoryRow{}, fmt.Errorf("got %d columns, want 5", len(cells))
+	}
+	seconds, err := strconv.ParseFloat(cells[0], 64)
+	if err != nil {
+		return HistoryRow{}, fmt.Errorf("scraped_at %q: %v", cells[0], err)
+	}
+	whole := math.Floor(seconds)
+	scrapedAt := time.Unix(int64(whole), int64((seconds-whole)*1e9)).UTC()
+	return HistoryRow{ScrapedAt: scrapedAt, CampgroundID: campgroundID, CampsiteID: cells[1], Night: cells[2], Status: cells[3], Backfilled: cells[4] == "true"}, nil
+}
+
+func (s BigQuerySink) bookingsTable() string {
+	return s.Table + "_bookings"
+}
+
+func (s BigQuerySink) insertAll(ctx context.Context, svc *bigquery.Service, table string, request *bigquery.TableDataInsertAllRequest) error {
+	resp, err := svc.Tabledata.InsertAll(s.Project, s.Dataset, table, request).Context(ctx).Do()
+	if err != nil {
+		return err
+	}
+	if len(resp.InsertErrors) > 0 && len(resp.InsertErrors[0].Errors) > 0 {
+		return fmt.Errorf("%d rows rejected, first: %s", len(resp.InsertErrors), resp.InsertErrors[0].Errors[0].Message)
+	}
+	return nil
+}
+
+// EnsureSchema creates s's history and bookings tables unless they already
+// exist, adding any columns an existing table lacks, and reports whether it
+// created or changed either. The dataset must exist.
+func (s BigQuerySink) EnsureSchema(ctx context.Context) (bool, error) {
+	svc, err := bigquery.NewService(ctx)
+	if err != nil {
+		return false, err
+	}
+	history, err := s.ensureTable(ctx, svc, s.Table, historySchema, "scraped_at")
+	if err != nil {
+		return false, err
+	}
+	bookings, err := s.ensureTable(ctx, svc, s.bookingsTable(), bookingsSchema, "booked_at")
+	return history || bookings, err
+}
+
+// addColumns adds the columns of schema that table lacks, such as
+// backfilled to a table created before it existed. BigQuery only allows
+// nullable columns to be added.
+func (s BigQuerySink) addColumns(ctx context.Context, svc *bigquery.Service, table *bigquery.Table, schema *bigquery.TableSchema) (bool, error) {
+	have := map[string]bool{}
+	fields := []*bigquery.TableFieldSchema{}
+	if table.Schema != nil {
+		fields = append(fields, table.Schema.Fields...)
+	}
+	for _, field := range fields {
+		have[field.Name] = true
+	}
+	for _, field := range schema.Fields {
+		if !have[field.Name] {
+			fields = append(fields, field)
+		}
+	}
+	if table.Schema != nil && len(fields) == len(table.Schema.Fields) {
+		return false, nil
+	}
+	name := table.TableReference.TableId
+	patch := &bigquery.Table{Schema: &bigquery.TableSchema{Fields: fields}}
+	if _, err := svc.Tables.Patch(s.Project, s.Dataset, name, patch).Context(ctx).Do(); err != nil {
+		return false, fmt.Errorf("adding columns to table %s.%s: %v", s.Dataset, name, err)
+	}
+	return true, nil
+}
+
+func (s BigQuerySink) ensureTable(ctx context.Context, svc *bigquery.Service, name string, schema *bigquery.TableSchema, partition string) (bool, error) {
+	existing, err := svc.Tables.Get(s.Project, s.Dataset, name).Context(ctx).Do()
+	if err == nil {
+		return s.addColumns(ctx, svc, existing, schema)
+	}
+	if e, ok := err.(*googleapi.Error); !ok || e.Code != http.StatusNotFound {
+		return false, fmt.Errorf("checking table %s.%s: %v", s.Dataset, name, err)
+	}
+	table := &bigquery.Table{
+		TableReference:   &bigquery.TableReference{ProjectId: s.Project, DatasetId: s.Dataset, TableId: name},
+		Schema:           schema,
+		TimePartitioning: &bigquery.TimePartitioning{Type: "DAY", Field: partition},
+	}
+	if _, err := svc.Tables.Insert(s.Project, s.Dataset, table).Context(ctx).Do(); err != nil {
+		return false, fmt.Errorf("creating table %s.%s: %v", s.Dataset, name, err)
+	}
+	return true, nil
+}
+
+// historyFromEnv is the batcher BQ_DATASET and BQ_TABLE select, writing to
+// that table in the deployment's project, or nil when either is unset.
+func historyFromEnv(project string) *HistoryBatcher {
+	dataset, table := os.Getenv("BQ_DATASET"), os.Getenv("BQ_TABLE")
+	if dataset == "" || table == "" {
+		return nil
+	}
+	return &HistoryBatcher{Sink: BigQuerySink{Project: project, Dataset: dataset, Table: table}}
+}
+
+// historyLog collects the rows one run's scrapes saw. It is safe for
+// concurrent use, as a watch's campgrounds are scraped in parallel.
+type historyLog struct {
+	mu   sync.Mutex
+	rows []HistoryRow
+}
+
+func (l *historyLog) add(rows []HistoryRow) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.rows = append(l.rows, rows...)
+}
+
+// take returns the rows collected, stamped with jobName.
+func (l *historyLog) take(jobName string) []HistoryRow {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rows := l.rows
+	l.rows = nil
+	for i := range rows {
+		rows[i].JobName = jobName
+	}
+	return rows
+}
+
+type historyLogKey struct{}
+
+// withHistory returns a context under which every month fetched through a
+// historyProvider is added to log.
+func withHistory(ctx context.Context, log *historyLog) context.Context {
+	return context.WithValue(ctx, historyLogKey{}, log)
+}
+
+// historyProvider adds every night of every month it fetches to the
+// historyLog its context carries, if any. Months served from the cache are
+// recorded too, as the scrape saw them.
+type historyProvider struct {
+	Provider core.Provider
+}
+
+// FetchMonth implements core.Provider.
+func (p historyProvider) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (core.Campground, error) {
+	campground, err := p.Provider.FetchMonth(ctx, campgroundID, month)
+	log, ok := ctx.Value(historyLogKey{}).(*historyLog)
+	if err != nil || !ok {
+		return campground, err
+	}
+	scrapedAt := DefaultScraper.now().UTC()
+	rows := []HistoryRow{}
+	for _, id := range campground.SiteIDs() {
+		site := campground.Campsites[id]
+		nights := make([]core.CivilDate, 0, len(site.Availabilities))
+		for night := range site.Availabilities {
+			nights = append(nights, night)
+		}
+		sort.Slice(nights, func(i, j int) bool { return nights[i].Before(nights[j]) })
+		for _, night := range nights {
+			rows = append(rows, HistoryRow{
+				ScrapedAt:    scrapedAt,
+				CampgroundID: campgroundID,
+				CampsiteID:   id,
+				Night:        night.String(),
+				Status:       site.Availabilities[night],
+			})
+		}
+	}
+	log.add(rows)
+	return campground, nil
+}
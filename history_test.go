@@ -0,0 +1,44 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	bigquery "google.golang.org/api/bigquery/v2"
+)
+
+func TestHistoryRowOf(t *testing.T) {
+	row := func(values ...interface{}) *bigquery.TableRow {
+		r := &bigquery.TableRow{}
+		for _, v := range values {
+			r.F = append(r.F, &bigquery.TableCell{V: v})
+		}
+		return r
+	}
+	tests := []struct {
+		name    string
+		row     *bigquery.TableRow
+		want    HistoryRow
+		wantErr bool
+	}{
+		{name: "timestamp", row: row("1.6886232E9", "1001", "2023-07-14", "Reserved", "false"),
+			want: HistoryRow{ScrapedAt: time.Unix(1688623200, 0).UTC(), CampgroundID: "232447", CampsiteID: "1001", Night: "2023-07-14", Status: "Reserved"}},
+		{name: "fraction", row: row("1688623200.5", "1002", "2023-07-15", "Available", "false"),
+			want: HistoryRow{ScrapedAt: time.Unix(1688623200, 5e8).UTC(), CampgroundID: "232447", CampsiteID: "1002", Night: "2023-07-15", Status: "Available"}},
+		{name: "backfilled", row: row("1.6886232E9", "1003", "2023-06-30", "Closed", "true"),
+			want: HistoryRow{ScrapedAt: time.Unix(1688623200, 0).UTC(), CampgroundID: "232447", CampsiteID: "1003", Night: "2023-06-30", Status: "Closed", Backfilled: true}},
+		{name: "bad timestamp", row: row("yesterday", "1001", "2023-07-14", "Reserved", "false"), wantErr: true},
+		{name: "short", row: row("1.6886232E9", "1001"), wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := historyRowOf("232447", test.row)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("historyRowOf error %v, want error %v", err, test.wantErr)
+			}
+			if err == nil && got != test.want {
+				t.Errorf("historyRowOf = %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
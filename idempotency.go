@@ -0,0 +1,143 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/googleapi"
+)
+
+// runPrefix is where alert claims live in RESULTS_BUCKET.
+const runPrefix = "runs/"
+
+// idempotencyTTL is how long a claim holds off a second alert for the same
+// results. It only needs to outlast Pub/Sub redelivering a run that timed
+// out, so a watch recreated later still alerts.
+const idempotencyTTL = 15 * time.Minute
+
+// IdempotencyStore records which alerts a run has started sending, so a
+// redelivered message does not send them again.
+type IdempotencyStore interface {
+	// Claim records key until ttl has passed and reports whether it was
+	// free. An expired claim is free again.
+	Claim(ctx context.Context, key string, ttl time.Duration) (bool, error)
+	// Release forgets key, so a run that failed can be retried in full.
+	Release(ctx context.Context, key string) error
+}
+
+// idempotencyKey identifies a run's alert: the job and the alert's
+// fingerprint, which covers the campground, the dates and the sites found.
+// A redelivered message finds the same results and so the same key, while
+// a later run finding different sites does not.
+func idempotencyKey(a alert) string {
+	h := sha256.Sum256([]byte(a.JobName + "\n" + alertFingerprint(a)))
+	return hex.EncodeToString(h[:])
+}
+
+// Claim implements IdempotencyStore.
+func (s *MemoryStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := DefaultScraper.now()
+	if expires, ok := s.claims[key]; ok && now.Before(expires) {
+		return false, nil
+	}
+	if s.claims == nil {
+		s.claims = map[string]time.Time{}
+	}
+	s.claims[key] = now.Add(ttl)
+	return true, nil
+}
+
+// Release implements IdempotencyStore.
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.claims, key)
+	return nil
+}
+
+// Claim implements IdempotencyStore. The claim is an object created only if
+// none exists, or replacing an expired one only if it is unchanged, so two
+// deliveries racing for the same key cannot both win.
+func (s BucketStore) Claim(ctx context.Context, key string, ttl time.Duration) (bool, error) {
+	client, err := storageClient()
+	if err != nil {
+		return false, err
+	}
+	object := client.Bucket(s.Bucket).Object(runPrefix + key)
+	conditions := storage.Conditions{DoesNotExist: true}
+	attrs, err := object.Attrs(ctx)
+	switch {
+	case err == storage.ErrObjectNotExist:
+	case err != nil:
+		return false, err
+	default:
+		expires, err := time.Parse(time.RFC3339, attrs.Metadata["expires"])
+		if err == nil && DefaultScraper.now().Before(expires) {
+			return false, nil
+		}
+		conditions = storage.Conditions{GenerationMatch: attrs.Generation}
+	}
+	w := object.If(conditions).NewWriter(ctx)
+	w.ContentType = "text/plain"
+	w.Metadata = map[string]string{"expires": DefaultScraper.now().Add(ttl).UTC().Format(time.RFC3339)}
+	if _, err := w.Write([]byte(key)); err != nil {
+		w.Close()
+		return false, err
+	}
+	err = w.Close()
+	if e, ok := err.(*googleapi.Error); ok && e.Code == http.StatusPreconditionFailed {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Release implements IdempotencyStore.
+func (s BucketStore) Release(ctx context.Context, key string) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	err = client.Bucket(s.Bucket).Object(runPrefix + key).Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+// idempotencyStore is where runs claim their alerts: RESULTS_BUCKET when
+// set, memory otherwise, as for stateStore. In memory, only redeliveries
+// to the same instance are caught. Replace it in tests.
+var idempotencyStore IdempotencyStore = defaultIdempotencyStore()
+
+func defaultIdempotencyStore() IdempotencyStore {
+	if bucket := os.Getenv("RESULTS_BUCKET"); bucket != "" {
+		return BucketStore{Bucket: bucket}
+	}
+	return &MemoryStore{}
+}
+
+// claimAlert claims a's key before it is sent and reports whether this run
+// should send it. A store that cannot be reached lets the alert through, so
+// idempotency can only ever cause a duplicate, never a lost alert.
+func claimAlert(ctx context.Context, a alert) bool {
+	claimed, err := idempotencyStore.Claim(ctx, idempotencyKey(a), idempotencyTTL)
+	if err != nil {
+		logger.Printf("job %s: claiming alert, sending anyway: %v", a.JobName, err)
+		return true
+	}
+	return claimed
+}
+
+// releaseAlert gives up a's claim after sending failed.
+func releaseAlert(ctx context.Context, a alert) {
+	if err := idempotencyStore.Release(ctx, idempotencyKey(a)); err != nil {
+		logger.Printf("job %s: releasing alert claim: %v", a.JobName, err)
+	}
+}
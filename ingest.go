@@ -0,0 +1,274 @@
+package scraper
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ScrapeResult is availability found by a scraper outside this package, for
+// a provider it does not support, submitted to Ingest.
+type ScrapeResult struct {
+	// Provider must be "external".
+	Provider     string    `json:"provider"`
+	JobName      string    `json:"job_name"`
+	CampgroundID string    `json:"campground_id"`
+	Arrival      time.Time `json:"arrival"`
+	Departure    time.Time `json:"departure"`
+	Sites        []string  `json:"sites"`
+	// CampgroundName names the campground in alerts. Optional.
+	CampgroundName string `json:"campground_name,omitempty"`
+	// Campsites describes the sites, by ID, for the watch's filters on type,
+	// capacity, attributes and price. Optional: a site it leaves out, or a
+	// detail left empty, counts as unknown, as when recreation.gov's details
+	// for a site cannot be fetched.
+	Campsites map[string]ExternalCampsite `json:"campsites,omitempty"`
+}
+
+// ExternalCampsite is what a ScrapeResult says about one site.
+type ExternalCampsite struct {
+	Name      string `json:"name,omitempty"`
+	Type      string `json:"type,omitempty"`
+	MaxPeople int    `json:"max_people,omitempty"`
+	// Attributes are named as on recreation.gov, such as "Pets Allowed".
+	Attributes map[string]string `json:"attributes,omitempty"`
+	// NightlyPrice is in dollars.
+	NightlyPrice float64 `json:"nightly_price,omitempty"`
+}
+
+// ingestResponse tells the caller what was done with a result.
+type ingestResponse struct {
+	Job     string   `json:"job"`
+	Actions []string `json:"actions"`
+}
+
+// problem is an RFC 7807 problem details body.
+type problem struct {
+	Title  string `json:"title"`
+	Status int    `json:"status"`
+	Detail string `json:"detail,omitempty"`
+}
+
+func writeProblem(w http.ResponseWriter, code int, detail string) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(problem{Title: http.StatusText(code), Status: code, Detail: detail})
+}
+
+// Ingest is an HTTP Cloud Function that accepts a POSTed ScrapeResult and
+// runs it through the named watch's filters, ranking, change tracking, quiet
+// hours, dedupe and notifiers as if the watch had scraped it, then keeps or
+// deletes the watch just the same. Results for a campground the operator
+// control blocks are refused with a 409. Callers authenticate with
+// "Authorization: Bearer <INGEST_API_KEY>".
+func Ingest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	key := os.Getenv("INGEST_API_KEY")
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(given)) != 1 {
+		writeProblem(w, http.StatusUnauthorized, "missing or wrong API key")
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		writeProblem(w, http.StatusRequestEntityTooLarge, "body is over 1 MiB")
+		return
+	}
+	var result ScrapeResult
+	if err := json.Unmarshal(body, &result); err != nil {
+		writeProblem(w, http.StatusBadRequest, "malformed JSON: "+err.Error())
+		return
+	}
+	if detail := result.validate(); detail != "" {
+		writeProblem(w, http.StatusUnprocessableEntity, detail)
+		return
+	}
+
+	ctx := r.Context()
+	if blocked, why := currentControl(ctx).Blocks(result.CampgroundID); blocked {
+		writeProblem(w, http.StatusConflict, "not ingesting while paused by the operator: "+why)
+		return
+	}
+	m, registered, code, err := lookupWatch(ctx, result.JobName)
+	if err != nil {
+		writeProblem(w, code, err.Error())
+		return
+	}
+	switch {
+	case m.isPermit() || m.isTour() || m.isSiteMonitor() || m.isReservationGuard() || len(m.Campgrounds) > 0:
+		writeProblem(w, http.StatusConflict, fmt.Sprintf("watch %s is not for a single campground", m.Name))
+		return
+	case m.Campground != result.CampgroundID:
+		writeProblem(w, http.StatusConflict, fmt.Sprintf("watch %s is for campground %s, not %s", m.Name, m.Campground, result.CampgroundID))
+		return
+	case m.expired():
+		writeProblem(w, http.StatusConflict, fmt.Sprintf("the dates of watch %s have passed", m.Name))
+		return
+	}
+	// Only a result that will be delivered reports a contact problem.
+	var contactProblem string
+	if err := m.resolveContact(ctx); err != nil {
+		contactProblem = err.Error()
+		reportContactProblem(ctx, m.Name, err)
+	}
+
+	s := *DefaultScraper
+	s.ingested = &result
+	run := &watchRun{watch: m, registered: registered, contactProblem: contactProblem}
+	a, err := s.matchWatch(ctx, ingestedMonths{result}, m, false)
+	if err != nil {
+		writeProblem(w, http.StatusServiceUnavailable, err.Error())
+		return
+	}
+	run.sites, run.alert = len(a.Sites), a
+	err = s.deliverAlert(ctx, m, a, run, false)
+	if err != nil {
+		logger.Println("ingest:", err)
+	}
+	published := run.scraped() && resultsPublisher != nil
+	if published {
+		publishResults(ctx, resultsMessage(run.alert, run.notified, 0))
+	}
+	if run.registered {
+		recordWatchResult(ctx, run, err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(ingestResponse{Job: m.Name, Actions: run.ingestActions(err, published)})
+}
+
+// ingestActions lists what deliverAlert did with an ingested result.
+func (r *watchRun) ingestActions(err error, published bool) []string {
+	actions := []string{}
+	switch {
+	case r.notified:
+		actions = append(actions, "alerted")
+	case r.outcome == outcomeNotifyFailed:
+		actions = append(actions, "alert failed: "+err.Error())
+	case r.outcome == outcomeNoneFound || r.outcome == outcomeNotReleased:
+		actions = append(actions, "no sites, nothing sent")
+	case r.outcome == outcomeKept:
+		actions = append(actions, "nothing changed, nothing sent")
+	default:
+		actions = append(actions, r.outcome)
+	}
+	if published {
+		actions = append(actions, "published results")
+	}
+	if r.deleted {
+		actions = append(actions, "deleted watch")
+	}
+	return actions
+}
+
+// ingestedMonths serves a ScrapeResult as availability: its sites are
+// available on each night of its stay and every other night is unknown.
+type ingestedMonths struct {
+	r ScrapeResult
+}
+
+// FetchMonth implements core.Provider.
+func (p ingestedMonths) FetchMonth(ctx context.Context, campgroundID string, month time.Time) (core.Campground, error) {
+	if err := ctx.Err(); err != nil {
+		return core.Campground{}, err
+	}
+	campground := core.Campground{Campsites: map[string]core.Campsite{}}
+	if campgroundID != p.r.CampgroundID {
+		return campground, nil
+	}
+	nights := core.Nights(core.CivilDateOf(p.r.Arrival), core.CivilDateOf(p.r.Departure))
+	for _, id := range p.r.Sites {
+		described := p.r.Campsites[id]
+		site := core.Campsite{CampsiteType: described.Type, Site: described.Name, Availabilities: map[core.CivilDate]string{}}
+		site.CampsiteID, _ = strconv.Atoi(id)
+		for _, night := range nights {
+			if night.Year == month.Year() && night.Month == month.Month() {
+				site.Availabilities[night] = "Available"
+			}
+		}
+		campground.Campsites[id] = site
+	}
+	return campground, nil
+}
+
+// campsiteDetail is what r says about campsite id, as recreation.gov's
+// details would.
+func (r ScrapeResult) campsiteDetail(id string) (core.CampsiteDetail, error) {
+	site, ok := r.Campsites[id]
+	if !ok {
+		return core.CampsiteDetail{}, fmt.Errorf("the ingested result does not describe campsite %s", id)
+	}
+	detail := core.CampsiteDetail{Capacity: core.Capacity{Max: site.MaxPeople}, Attributes: map[string]string{}}
+	for name, value := range site.Attributes {
+		detail.Attributes[strings.ToLower(name)] = value
+	}
+	if site.NightlyPrice > 0 {
+		detail.Fees.Rates = []core.FeeRate{{Nightly: core.PriceCents(site.NightlyPrice)}}
+	}
+	return detail, nil
+}
+
+// validate returns what is wrong with r, or "" if nothing is.
+func (r ScrapeResult) validate() string {
+	switch {
+	case r.Provider != "external":
+		return `provider must be "external"`
+	case r.JobName == "":
+		return "job_name is required"
+	case r.CampgroundID == "":
+		return "campground_id is required"
+	case r.Arrival.IsZero() || r.Departure.IsZero():
+		return "arrival and departure are required"
+	case !r.Departure.After(r.Arrival):
+		return "departure must be after arrival"
+	}
+	return ""
+}
+
+// lookupWatch fetches the watch a result is for, loading a registered one
+// from the registry, and reports whether it was registered. It returns the
+// HTTP status to report when the watch cannot be used.
+func lookupWatch(ctx context.Context, jobName string) (MessageContent, bool, int, error) {
+	m := MessageContent{}
+	name, err := ParseJobName(jobName)
+	if err != nil {
+		return m, false, http.StatusUnprocessableEntity, err
+	}
+	c, err := newWatchScheduler()
+	if err != nil {
+		logger.Println("ingest: scheduler client:", err)
+		return m, false, http.StatusServiceUnavailable, fmt.Errorf("could not reach Cloud Scheduler")
+	}
+	job, err := c.GetJob(ctx, name.String())
+	if status.Code(err) == codes.NotFound {
+		return m, false, http.StatusNotFound, fmt.Errorf("no watch named %s", jobName)
+	}
+	if err != nil {
+		logger.Println("ingest: get job:", err)
+		return m, false, http.StatusServiceUnavailable, fmt.Errorf("could not look up the watch")
+	}
+	data := job.GetPubsubTarget().GetData()
+	m, watchStatus, ok := watchFromJob(ctx, newWatchStore(activeConfig), data)
+	if !ok || m.Name == "" {
+		return m, false, http.StatusConflict, fmt.Errorf("job %s is not a watch", jobName)
+	}
+	if watchStatus != WatchActive {
+		return m, false, http.StatusConflict, fmt.Errorf("watch %s is %s", m.Name, watchStatus)
+	}
+	var ref watchRef
+	json.Unmarshal(data, &ref)
+	return m, ref.WatchID != "" && m.Name == ref.WatchID, 0, nil
+}
@@ -0,0 +1,215 @@
+package scraper_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+// schedule adds the job for m, whose payload is data when given, without
+// running it.
+func schedule(h *fakerecgov.Harness, name string, data []byte) {
+	h.Scheduler.AddJob(&schedulerpb.Job{
+		Name:   name,
+		Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{Data: data}},
+	})
+}
+
+// ingest POSTs result to Ingest with the test API key and decodes the
+// actions it reports.
+func ingest(t *testing.T, result scraper.ScrapeResult) (int, []string) {
+	t.Helper()
+	body, err := json.Marshal(result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := httptest.NewRequest(http.MethodPost, "/ingest", bytes.NewReader(body))
+	r.Header.Set("Authorization", "Bearer test-key")
+	w := httptest.NewRecorder()
+	scraper.Ingest(w, r)
+	var resp struct {
+		Actions []string `json:"actions"`
+	}
+	if w.Code == http.StatusOK {
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decoding %s: %v", w.Body, err)
+		}
+	}
+	return w.Code, resp.Actions
+}
+
+func ingestResult(job string) scraper.ScrapeResult {
+	return scraper.ScrapeResult{
+		Provider:       "external",
+		JobName:        job,
+		CampgroundID:   "232447",
+		CampgroundName: "Upper Pines",
+		Arrival:        time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC),
+		Departure:      time.Date(2027, 7, 16, 0, 0, 0, 0, time.UTC),
+		Sites:          []string{"1001", "1002"},
+		Campsites: map[string]scraper.ExternalCampsite{
+			"1001": {Name: "A001", Type: "STANDARD NONELECTRIC", MaxPeople: 6, NightlyPrice: 36, Attributes: map[string]string{"Pets Allowed": "Yes"}},
+			"1002": {Name: "A002", Type: "TENT ONLY NONELECTRIC", MaxPeople: 4, NightlyPrice: 26},
+		},
+	}
+}
+
+func TestIngest(t *testing.T) {
+	tests := []struct {
+		name  string
+		watch func(m *scraper.MessageContent)
+		// wantSites is the site IDs alerted, cheapest first, none when empty.
+		wantSites  string
+		wantAction string
+		wantKept   bool
+	}{
+		{name: "found", watch: func(m *scraper.MessageContent) {}, wantSites: "1002,1001", wantAction: "alerted"},
+		{name: "site type filter", watch: func(m *scraper.MessageContent) { m.SiteTypes = []string{"TENT ONLY"} }, wantSites: "1002", wantAction: "alerted"},
+		{name: "excluded types", watch: func(m *scraper.MessageContent) { m.ExcludeTypes = []string{"STANDARD", "TENT ONLY"} }, wantAction: "no sites, nothing sent", wantKept: true},
+		{name: "campsite IDs", watch: func(m *scraper.MessageContent) { m.CampsiteIDs = []string{"1001"} }, wantSites: "1001", wantAction: "alerted"},
+		{name: "capacity", watch: func(m *scraper.MessageContent) { m.MinCapacity = 5 }, wantSites: "1001", wantAction: "alerted"},
+		{name: "attributes", watch: func(m *scraper.MessageContent) { m.RequiredAttributes = map[string]string{"pets allowed": "Yes"} }, wantSites: "1001", wantAction: "alerted"},
+		{name: "price", watch: func(m *scraper.MessageContent) { m.MaxNightlyPrice = 30 }, wantSites: "1002", wantAction: "alerted"},
+		{name: "quiet hours", watch: func(m *scraper.MessageContent) {
+			m.TimeZone, m.QuietHoursStart, m.QuietHoursEnd = "UTC", "00:00", "23:59"
+		}, wantAction: "held for quiet hours", wantKept: true},
+		{name: "kept job", watch: func(m *scraper.MessageContent) { m.KeepJob = true }, wantSites: "1002,1001", wantAction: "alerted", wantKept: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("INGEST_API_KEY", "test-key")
+			h := fakerecgov.Start(e2eFixture(false))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("ingest-" + strings.Replace(test.name, " ", "-", -1))
+			test.watch(&m)
+			message := fakerecgov.Message(m)
+			schedule(h, m.Name, message.Data)
+
+			code, actions := ingest(t, ingestResult(m.Name))
+			if code != http.StatusOK {
+				t.Fatalf("Ingest answered %d", code)
+			}
+			if len(actions) == 0 || actions[0] != test.wantAction {
+				t.Errorf("actions %v, want %q first", actions, test.wantAction)
+			}
+			alerts := h.Notifier.Alerts()
+			var got []string
+			for _, alert := range alerts {
+				for _, site := range alert.Sites {
+					got = append(got, site.ID)
+				}
+			}
+			if strings.Join(got, ",") != test.wantSites || len(alerts) > 1 {
+				t.Errorf("alerted %d times with sites %v, want %q", len(alerts), got, test.wantSites)
+			}
+			if kept := h.Scheduler.Job(m.Name) != nil; kept != test.wantKept {
+				t.Errorf("job kept = %v, want %v", kept, test.wantKept)
+			}
+		})
+	}
+}
+
+// A persistent watch alerts again only once what is available changes.
+func TestIngestKeptJobDedupes(t *testing.T) {
+	t.Setenv("INGEST_API_KEY", "test-key")
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := e2eWatch("ingest-dedupe")
+	m.KeepJob = true
+	message := fakerecgov.Message(m)
+	schedule(h, m.Name, message.Data)
+
+	for i, want := range []string{"alerted", "nothing changed, nothing sent"} {
+		if _, actions := ingest(t, ingestResult(m.Name)); len(actions) == 0 || actions[0] != want {
+			t.Errorf("ingest %d: actions %v, want %q", i, actions, want)
+		}
+	}
+	if n := len(h.Notifier.Alerts()); n != 1 {
+		t.Errorf("got %d alerts from the same result twice, want 1", n)
+	}
+}
+
+func TestIngestRegisteredWatch(t *testing.T) {
+	t.Setenv("INGEST_API_KEY", "test-key")
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	defer scraper.UseServices(scraper.Services{Watches: h.Store})()
+	watch := e2eWatch("")
+	watch.Name = ""
+	if err := h.Store.PutWatch(context.Background(), scraper.WatchRecord{ID: "watch-ingest", Watch: watch, Status: scraper.WatchActive}); err != nil {
+		t.Fatal(err)
+	}
+	job := scraper.JobNameFor("watch-ingest")
+	schedule(h, job, []byte(`{"WatchID":"watch-ingest"}`))
+
+	code, actions := ingest(t, ingestResult(job))
+	if code != http.StatusOK || len(actions) == 0 || actions[0] != "alerted" {
+		t.Fatalf("Ingest answered %d with %v", code, actions)
+	}
+	if h.Scheduler.Job(job) != nil {
+		t.Errorf("job %s kept after its alert", job)
+	}
+	r, err := h.Store.GetWatch(context.Background(), "watch-ingest")
+	if err != nil || r.Status != scraper.WatchCompleted || r.LastResult == nil {
+		t.Errorf("registry record %+v (%v), want it completed with its result", r, err)
+	}
+}
+
+func TestIngestRejects(t *testing.T) {
+	t.Setenv("INGEST_API_KEY", "test-key")
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := e2eWatch("ingest-rejects")
+	// With no contact store the contact cannot be found, which a rejected
+	// result must not report.
+	m.Contact = "family"
+	message := fakerecgov.Message(m)
+	schedule(h, m.Name, message.Data)
+
+	tests := []struct {
+		name   string
+		result func(r *scraper.ScrapeResult)
+		want   int
+	}{
+		{"unknown watch", func(r *scraper.ScrapeResult) { r.JobName = fakerecgov.JobName("nope") }, http.StatusNotFound},
+		{"other campground", func(r *scraper.ScrapeResult) { r.CampgroundID = "232450" }, http.StatusConflict},
+		{"wrong provider", func(r *scraper.ScrapeResult) { r.Provider = "recreation.gov" }, http.StatusUnprocessableEntity},
+		{"bad job name", func(r *scraper.ScrapeResult) { r.JobName = "a/b" }, http.StatusUnprocessableEntity},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := ingestResult(m.Name)
+			test.result(&result)
+			if code, _ := ingest(t, result); code != test.want {
+				t.Errorf("Ingest answered %d, want %d", code, test.want)
+			}
+		})
+	}
+
+	r := httptest.NewRequest(http.MethodPost, "/ingest", strings.NewReader(`{}`))
+	r.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	scraper.Ingest(w, r)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong key answered %d", w.Code)
+	}
+	if n := len(h.Notifier.Alerts()); n != 0 {
+		t.Errorf("rejected results sent %d alerts", n)
+	}
+	if notices := h.Notifier.Notices(); len(notices) != 0 {
+		t.Errorf("rejected results sent %+v", notices)
+	}
+}
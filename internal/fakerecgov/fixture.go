@@ -0,0 +1,196 @@
+// Package fakerecgov fakes recreation.gov, Cloud Scheduler and the alert
+// channels so the scraper's whole scrape, notify and delete flow can be
+// tested without a network. A Fixture declares campgrounds, each site's
+// status on each night, ticketed tours and permits; Server serves it from
+// the availability, campsite detail, campground detail, search, ticket and
+// permit endpoints, and Harness wires the server, a Scheduler, a Notifier
+// and a scraper.MemoryStore into the scraper package.
+package fakerecgov
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// Fixture is what the fake recreation.gov knows.
+type Fixture struct {
+	Campgrounds []Campground `json:"campgrounds"`
+	// Tours are ticketed facilities, served from the ticket availability
+	// endpoint.
+	Tours []TourFacility `json:"tours,omitempty"`
+	// Permits are entry permits, served from the permit availability
+	// endpoint.
+	Permits []Permit `json:"permits,omitempty"`
+}
+
+// Permit is a recreation.gov permit, such as a trailhead's entry quota, in
+// a Fixture.
+type Permit struct {
+	ID        string           `json:"id"`
+	Divisions []PermitDivision `json:"divisions"`
+}
+
+// PermitDivision is one entry point of a permit: its daily quota, and the
+// slots left on the dates listed in Remaining, such as "2027-07-14". Dates
+// not listed are not served.
+type PermitDivision struct {
+	ID        string         `json:"id"`
+	Total     int            `json:"total"`
+	Remaining map[string]int `json:"remaining"`
+}
+
+// TourFacility is a facility selling tour tickets, such as a cave, in a
+// Fixture.
+type TourFacility struct {
+	ID    string     `json:"id"`
+	Slots []TourSlot `json:"slots"`
+}
+
+// TourSlot is one departure of a tour: its date, such as "2027-07-14", its
+// local start time, such as "10:00", and its tickets. Status, such as
+// "SOLD_OUT", is "AVAILABLE" when empty.
+type TourSlot struct {
+	TourID   int    `json:"tour_id"`
+	Date     string `json:"date"`
+	Time     string `json:"time"`
+	Capacity int    `json:"capacity"`
+	Booked   int    `json:"booked"`
+	Status   string `json:"status,omitempty"`
+}
+
+// Campground is one campground in a Fixture.
+type Campground struct {
+	ID        string  `json:"id"`
+	Name      string  `json:"name"`
+	State     string  `json:"state,omitempty"`
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+	// NightlyFee, in dollars, is the campground's rate for sites that have
+	// none of their own. Zero lists no rate.
+	NightlyFee float64 `json:"nightly_fee,omitempty"`
+	// SeasonStart and SeasonEnd, such as "2027-05-15" and "2027-09-30", are
+	// the campground's operating season. Either empty lists none.
+	SeasonStart string `json:"season_start,omitempty"`
+	SeasonEnd   string `json:"season_end,omitempty"`
+	// Notices are the alerts posted on the campground's page.
+	Notices []string `json:"notices,omitempty"`
+	// FailStatus, when set, is the HTTP status every availability request
+	// for the campground gets instead of its months, or with FailFirst, the
+	// first FailFirst requests.
+	FailStatus int `json:"fail_status,omitempty"`
+	FailFirst  int `json:"fail_first,omitempty"`
+	// Challenge makes every availability request for the campground get a
+	// 403 HTML page, as recreation.gov's bot challenge does.
+	Challenge bool `json:"challenge,omitempty"`
+	// KeyZone, such as "America/Los_Angeles", keys the campground's nights
+	// by their local midnight in that zone, offset included, instead of by
+	// midnight UTC.
+	KeyZone string `json:"key_zone,omitempty"`
+	Sites   []Site `json:"sites"`
+}
+
+// Site is one campsite in a Fixture.
+type Site struct {
+	// ID is the numeric campsite ID.
+	ID   string `json:"id"`
+	Site string `json:"site,omitempty"`
+	Loop string `json:"loop,omitempty"`
+	Type string `json:"type,omitempty"`
+	// Nights maps dates such as "2027-07-14" to the night's status, such as
+	// "Available" or "Reserved". Nights not listed are Reserved.
+	Nights     map[string]string `json:"nights"`
+	MinPeople  int               `json:"min_people,omitempty"`
+	MaxPeople  int               `json:"max_people,omitempty"`
+	Attributes map[string]string `json:"attributes,omitempty"`
+	NightlyFee float64           `json:"nightly_fee,omitempty"`
+	// Unlisted leaves the site out of the campsite details endpoint, as
+	// for a site added since recreation.gov's metadata was built.
+	Unlisted bool `json:"unlisted,omitempty"`
+	// DetailStatus, when set, is the HTTP status the campsite details
+	// endpoint answers for the site instead of its details.
+	DetailStatus int `json:"detail_status,omitempty"`
+}
+
+// ParseFixture decodes a JSON fixture and checks its dates.
+func ParseFixture(data []byte) (Fixture, error) {
+	var f Fixture
+	if err := json.Unmarshal(data, &f); err != nil {
+		return Fixture{}, fmt.Errorf("decoding fixture: %v", err)
+	}
+	for _, c := range f.Campgrounds {
+		if c.ID == "" {
+			return Fixture{}, fmt.Errorf("fixture campground %q has no id", c.Name)
+		}
+		for _, s := range c.Sites {
+			for night := range s.Nights {
+				if _, err := core.ParseCivilDate(night); err != nil {
+					return Fixture{}, fmt.Errorf("campground %s site %s: %v", c.ID, s.ID, err)
+				}
+			}
+		}
+	}
+	for _, p := range f.Permits {
+		for _, d := range p.Divisions {
+			for date := range d.Remaining {
+				if _, err := core.ParseCivilDate(date); err != nil {
+					return Fixture{}, fmt.Errorf("permit %s division %s: %v", p.ID, d.ID, err)
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+// LoadFixture reads a JSON fixture from path.
+func LoadFixture(path string) (Fixture, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Fixture{}, err
+	}
+	return ParseFixture(data)
+}
+
+// campground finds id in f.
+func (f Fixture) campground(id string) (Campground, bool) {
+	for _, c := range f.Campgrounds {
+		if c.ID == id {
+			return c, true
+		}
+	}
+	return Campground{}, false
+}
+
+// tours finds the ticketed facility id in f.
+func (f Fixture) tours(id string) (TourFacility, bool) {
+	for _, t := range f.Tours {
+		if t.ID == id {
+			return t, true
+		}
+	}
+	return TourFacility{}, false
+}
+
+// permit finds the permit id in f.
+func (f Fixture) permit(id string) (Permit, bool) {
+	for _, p := range f.Permits {
+		if p.ID == id {
+			return p, true
+		}
+	}
+	return Permit{}, false
+}
+
+// site finds the campsite id in any of f's campgrounds.
+func (f Fixture) site(id string) (Site, bool) {
+	for _, c := range f.Campgrounds {
+		for _, s := range c.Sites {
+			if s.ID == id {
+				return s, true
+			}
+		}
+	}
+	return Site{}, false
+}
@@ -0,0 +1,30 @@
+package fakerecgov
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFixture(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{"valid", `{"campgrounds":[{"id":"1","sites":[{"id":"10","nights":{"2027-07-14":"Available"}}]}]}`, ""},
+		{"no id", `{"campgrounds":[{"name":"Upper Pines"}]}`, "has no id"},
+		{"bad night", `{"campgrounds":[{"id":"1","sites":[{"id":"10","nights":{"July 14":"Available"}}]}]}`, "site 10"},
+		{"not json", `campgrounds`, "decoding fixture"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseFixture([]byte(test.data))
+			if test.wantErr == "" && err != nil {
+				t.Fatalf("ParseFixture: %v", err)
+			}
+			if test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)) {
+				t.Fatalf("ParseFixture error = %v, want one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package fakerecgov
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+// JobName is the full Cloud Scheduler name of job id in the fake project,
+// for a watch's Name.
+func JobName(id string) string {
+	return "projects/fakerecgov/locations/us-central1/jobs/" + id
+}
+
+// Harness runs the scraper package against fakes: Server in place of
+// recreation.gov, Scheduler of Cloud Scheduler, Notifier of every alert
+// channel and of SendGrid's notices, Results of the results topic, Store of
+// the state watches keep between runs and Clock of the wall clock. Store
+// also serves as the watch registry once a test installs it with
+// scraper.UseServices(scraper.Services{Watches: h.Store}).
+type Harness struct {
+	Server    *Server
+	Scheduler *Scheduler
+	Notifier  *Notifier
+	Results   *Publisher
+	Store     *scraper.MemoryStore
+	Clock     *Clock
+
+	scraper *scraper.Scraper
+	restore func()
+}
+
+// Start serves f and installs the fakes. scraper.DefaultScraper is replaced
+// by one pointed at the server that makes single attempts and caches no
+// months, so a failure is seen at once and SetFixture takes effect on the
+// next run. Clock starts at the current time. Close puts everything back.
+// The scraper package's state is global, so tests using a Harness must not
+// run in parallel, and it caches campsite and campground details for the
+// life of the process, so the fixtures of one test binary should agree on
+// the details of each ID.
+func Start(f Fixture) *Harness {
+	h := &Harness{
+		Server:    NewServer(f),
+		Scheduler: &Scheduler{},
+		Notifier:  &Notifier{},
+		Results:   &Publisher{},
+		Store:     &scraper.MemoryStore{},
+		Clock:     NewClock(time.Now()),
+		scraper:   scraper.DefaultScraper,
+	}
+	s := scraper.NewScraper(nil, core.RetryPolicy{})
+	s.BaseURL, s.Cache, s.Log, s.Clock = h.Server.URL, nil, nil, h.Clock
+	scraper.DefaultScraper = s
+	h.restore = scraper.UseServices(scraper.Services{
+		Scheduler:  h.Scheduler,
+		Notifier:   h.Notifier,
+		Notices:    h.Notifier,
+		Results:    h.Results,
+		Store:      h.Store,
+		Scans:      h.Store,
+		Deliveries: h.Store,
+		Claims:     h.Store,
+		Openings:   h.Store,
+		Backfills:  h.Store,
+		Clock:      h.Clock,
+	})
+	return h
+}
+
+// Close stops the server and restores what Start replaced.
+func (h *Harness) Close() {
+	h.restore()
+	scraper.DefaultScraper = h.scraper
+	h.Server.Close()
+}
+
+// Message is the Pub/Sub message the job for m delivers.
+func Message(m scraper.MessageContent) pubsub.Message {
+	data, _ := json.Marshal(m)
+	return pubsub.Message{Data: data}
+}
+
+// Run adds m's job to the Scheduler, unless it is already there, and runs
+// scraper.ScrapeFromMessage once on its message. m.Name should come from
+// JobName.
+func (h *Harness) Run(ctx context.Context, m scraper.MessageContent) error {
+	message := Message(m)
+	if h.Scheduler.Job(m.Name) == nil {
+		h.Scheduler.AddJob(&schedulerpb.Job{
+			Name:     m.Name,
+			Schedule: "*/5 * * * *",
+			Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{
+				TopicName: "projects/fakerecgov/topics/scrape",
+				Data:      message.Data,
+			}},
+		})
+	}
+	return scraper.ScrapeFromMessage(ctx, message)
+}
+
+// Fire runs scraper.ScrapeFromMessage on what job name's job publishes now,
+// its Pub/Sub attributes included, as Cloud Scheduler would.
+func (h *Harness) Fire(ctx context.Context, name string) error {
+	job := h.Scheduler.Job(name)
+	if job == nil {
+		return fmt.Errorf("no job %s", name)
+	}
+	target := job.GetPubsubTarget()
+	return scraper.ScrapeFromMessage(ctx, pubsub.Message{Data: target.GetData(), Attributes: target.GetAttributes()})
+}
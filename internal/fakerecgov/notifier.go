@@ -0,0 +1,71 @@
+package fakerecgov
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sgrasu/camp_finder/scraper"
+)
+
+// Notifier is a scraper.AlertNotifier and scraper.NoticeSender that records
+// every alert and notice. When Err is set, it records an alert and then
+// fails its delivery; notices always succeed. It is safe for concurrent use.
+type Notifier struct {
+	Err error
+
+	mu      sync.Mutex
+	alerts  []scraper.WebhookPayload
+	notices []scraper.Notice
+}
+
+// NotifyAlert implements scraper.AlertNotifier.
+func (n *Notifier) NotifyAlert(ctx context.Context, alert scraper.WebhookPayload) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, alert)
+	return n.Err
+}
+
+// SendNotice implements scraper.NoticeSender.
+func (n *Notifier) SendNotice(ctx context.Context, notice scraper.Notice) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.notices = append(n.notices, notice)
+	return nil
+}
+
+// Notices lists the notices sent so far, oldest first.
+func (n *Notifier) Notices() []scraper.Notice {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]scraper.Notice{}, n.notices...)
+}
+
+// Alerts lists the alerts delivered so far, oldest first.
+func (n *Notifier) Alerts() []scraper.WebhookPayload {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]scraper.WebhookPayload{}, n.alerts...)
+}
+
+// Publisher is a scraper.ResultsPublisher that records every results
+// message. It is safe for concurrent use.
+type Publisher struct {
+	mu       sync.Mutex
+	messages []scraper.ResultsMessage
+}
+
+// Publish implements scraper.ResultsPublisher.
+func (p *Publisher) Publish(ctx context.Context, r scraper.ResultsMessage) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.messages = append(p.messages, r)
+	return nil
+}
+
+// Messages lists the results messages published so far, oldest first.
+func (p *Publisher) Messages() []scraper.ResultsMessage {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]scraper.ResultsMessage{}, p.messages...)
+}
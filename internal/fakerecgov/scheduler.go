@@ -0,0 +1,145 @@
+package fakerecgov
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Scheduler is an in-memory scraper.Scheduler. Like Cloud Scheduler, it
+// answers for a job it does not have with a NotFound status. It is safe for
+// concurrent use.
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*schedulerpb.Job
+	deleted []string
+	calls   []string
+}
+
+// call records an RPC, such as "PauseJob", on the job or parent named.
+func (s *Scheduler) call(rpc string, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls = append(s.calls, rpc+" "+name)
+}
+
+// Calls lists every RPC made, such as "PauseJob projects/p/locations/l/jobs/j",
+// oldest first. AddJob and Job are not RPCs and are not listed.
+func (s *Scheduler) Calls() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.calls...)
+}
+
+// AddJob stores job as if it had been created, enabled unless it has a
+// state.
+func (s *Scheduler) AddJob(job *schedulerpb.Job) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if job.State == schedulerpb.Job_STATE_UNSPECIFIED {
+		job.State = schedulerpb.Job_ENABLED
+	}
+	if s.jobs == nil {
+		s.jobs = map[string]*schedulerpb.Job{}
+	}
+	s.jobs[job.Name] = job
+}
+
+// Job returns the job named name, or nil.
+func (s *Scheduler) Job(name string) *schedulerpb.Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.jobs[name]
+}
+
+// Deleted lists the names of the jobs deleted, in order.
+func (s *Scheduler) Deleted() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.deleted...)
+}
+
+// CreateJob implements scraper.Scheduler.
+func (s *Scheduler) CreateJob(ctx context.Context, parent string, job *schedulerpb.Job) error {
+	s.call("CreateJob", job.Name)
+	if s.Job(job.Name) != nil {
+		return status.Errorf(codes.AlreadyExists, "job %s already exists", job.Name)
+	}
+	s.AddJob(job)
+	return nil
+}
+
+// ListJobs implements scraper.Scheduler.
+func (s *Scheduler) ListJobs(ctx context.Context, parent string) ([]*schedulerpb.Job, error) {
+	s.call("ListJobs", parent)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	jobs := []*schedulerpb.Job{}
+	for name, job := range s.jobs {
+		if strings.HasPrefix(name, parent+"/jobs/") {
+			jobs = append(jobs, job)
+		}
+	}
+	return jobs, nil
+}
+
+// DeleteJob implements scraper.Scheduler.
+func (s *Scheduler) DeleteJob(ctx context.Context, name string) error {
+	s.call("DeleteJob", name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[name]; !ok {
+		return status.Errorf(codes.NotFound, "job %s not found", name)
+	}
+	delete(s.jobs, name)
+	s.deleted = append(s.deleted, name)
+	return nil
+}
+
+// GetJob implements scraper.Scheduler.
+func (s *Scheduler) GetJob(ctx context.Context, name string) (*schedulerpb.Job, error) {
+	s.call("GetJob", name)
+	job := s.Job(name)
+	if job == nil {
+		return nil, status.Errorf(codes.NotFound, "job %s not found", name)
+	}
+	return job, nil
+}
+
+// UpdateJob implements scraper.Scheduler. The whole job is replaced,
+// whatever paths name.
+func (s *Scheduler) UpdateJob(ctx context.Context, job *schedulerpb.Job, paths []string) error {
+	s.call("UpdateJob", job.Name)
+	if s.Job(job.Name) == nil {
+		return status.Errorf(codes.NotFound, "job %s not found", job.Name)
+	}
+	s.AddJob(job)
+	return nil
+}
+
+// PauseJob implements scraper.Scheduler.
+func (s *Scheduler) PauseJob(ctx context.Context, name string) error {
+	s.call("PauseJob", name)
+	return s.setState(name, schedulerpb.Job_PAUSED)
+}
+
+// ResumeJob implements scraper.Scheduler.
+func (s *Scheduler) ResumeJob(ctx context.Context, name string) error {
+	s.call("ResumeJob", name)
+	return s.setState(name, schedulerpb.Job_ENABLED)
+}
+
+func (s *Scheduler) setState(name string, state schedulerpb.Job_State) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job, ok := s.jobs[name]
+	if !ok {
+		return status.Errorf(codes.NotFound, "job %s not found", name)
+	}
+	job.State = state
+	return nil
+}
@@ -0,0 +1,300 @@
+package fakerecgov
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Server is a fake recreation.gov serving a Fixture. Point a
+// scraper.Scraper or core.RecreationGov at it with BaseURL set to URL.
+type Server struct {
+	URL string
+
+	srv      *httptest.Server
+	mu       sync.Mutex
+	fixture  Fixture
+	requests []string
+	// failed counts each campground's availability requests failed with
+	// FailStatus.
+	failed map[string]int
+}
+
+// NewServer starts a Server for f. Close it when done.
+func NewServer(f Fixture) *Server {
+	s := &Server{fixture: f, failed: map[string]int{}}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/camps/availability/campground/", s.month)
+	mux.HandleFunc("/api/camps/campsites/", s.campsite)
+	mux.HandleFunc("/api/camps/campgrounds/", s.campground)
+	mux.HandleFunc("/api/search", s.search)
+	mux.HandleFunc("/api/ticket/availability/facility/", s.ticket)
+	mux.HandleFunc("/api/permits/", s.permit)
+	s.srv = httptest.NewServer(s.record(mux))
+	s.URL = s.srv.URL
+	return s
+}
+
+// Close shuts the server down.
+func (s *Server) Close() {
+	s.srv.Close()
+}
+
+// SetFixture replaces what the server serves, such as between two runs of
+// one watch.
+func (s *Server) SetFixture(f Fixture) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.fixture = f
+}
+
+// Requests lists the path and query of every request served, oldest first.
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// MonthsFetched lists the months of campgroundID's availability requested,
+// such as "2027-07", in request order.
+func (s *Server) MonthsFetched(campgroundID string) []string {
+	prefix := "/api/camps/availability/campground/" + campgroundID + "/month?start_date="
+	months := []string{}
+	for _, r := range s.Requests() {
+		if strings.HasPrefix(r, prefix) && len(r) >= len(prefix)+7 {
+			months = append(months, r[len(prefix):len(prefix)+7])
+		}
+	}
+	return months
+}
+
+func (s *Server) record(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests = append(s.requests, r.URL.RequestURI())
+		s.mu.Unlock()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fail reports whether this availability request for c fails, counting
+// it when it does.
+func (s *Server) fail(c Campground) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if c.FailFirst > 0 && s.failed[c.ID] >= c.FailFirst {
+		return false
+	}
+	s.failed[c.ID]++
+	return true
+}
+
+func (s *Server) current() Fixture {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fixture
+}
+
+// month serves one month of a campground's availability. Every night of the
+// month is listed, Reserved unless the fixture says otherwise.
+func (s *Server) month(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/camps/availability/campground/"), "/")
+	c, ok := s.current().campground(parts[0])
+	if len(parts) != 2 || parts[1] != "month" || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if c.Challenge {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte("<html><body>Please verify you are a human</body></html>"))
+		return
+	}
+	if c.FailStatus != 0 && s.fail(c) {
+		http.Error(w, http.StatusText(c.FailStatus), c.FailStatus)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, r.URL.Query().Get("start_date"))
+	if err != nil {
+		http.Error(w, "bad start_date", http.StatusBadRequest)
+		return
+	}
+	first := time.Date(start.Year(), start.Month(), 1, 0, 0, 0, 0, time.UTC)
+	zone := time.UTC
+	if c.KeyZone != "" {
+		if zone, err = time.LoadLocation(c.KeyZone); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+	campsites := map[string]interface{}{}
+	for _, site := range c.Sites {
+		nights := map[string]string{}
+		for night := first; night.Month() == first.Month(); night = night.AddDate(0, 0, 1) {
+			status, ok := site.Nights[night.Format("2006-01-02")]
+			if !ok {
+				status = "Reserved"
+			}
+			key := time.Date(night.Year(), night.Month(), night.Day(), 0, 0, 0, 0, zone)
+			nights[key.Format(time.RFC3339)] = status
+		}
+		campsites[site.ID] = map[string]interface{}{
+			"campsite_id":    site.ID,
+			"site":           site.Site,
+			"loop":           site.Loop,
+			"campsite_type":  site.Type,
+			"availabilities": nights,
+		}
+	}
+	writeJSON(w, map[string]interface{}{"campsites": campsites})
+}
+
+// campsite serves a campsite's details.
+func (s *Server) campsite(w http.ResponseWriter, r *http.Request) {
+	site, ok := s.current().site(strings.TrimPrefix(r.URL.Path, "/api/camps/campsites/"))
+	if !ok || site.Unlisted {
+		http.NotFound(w, r)
+		return
+	}
+	if site.DetailStatus != 0 {
+		http.Error(w, http.StatusText(site.DetailStatus), site.DetailStatus)
+		return
+	}
+	attributes := []map[string]string{}
+	for name, value := range site.Attributes {
+		attributes = append(attributes, map[string]string{"attribute_name": name, "attribute_value": value})
+	}
+	writeJSON(w, map[string]interface{}{"campsite": map[string]interface{}{
+		"campsite_id":    site.ID,
+		"min_num_people": site.MinPeople,
+		"max_num_people": site.MaxPeople,
+		"attributes":     attributes,
+		"rates":          rates(site.NightlyFee),
+	}})
+}
+
+// campground serves a campground's details.
+func (s *Server) campground(w http.ResponseWriter, r *http.Request) {
+	c, ok := s.current().campground(strings.TrimPrefix(r.URL.Path, "/api/camps/campgrounds/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	detail := map[string]interface{}{
+		"facility_id":        c.ID,
+		"facility_name":      c.Name,
+		"facility_latitude":  c.Latitude,
+		"facility_longitude": c.Longitude,
+		"rates":              rates(c.NightlyFee),
+	}
+	if c.SeasonStart != "" && c.SeasonEnd != "" {
+		detail["operating_season"] = map[string]string{"start_date": c.SeasonStart, "end_date": c.SeasonEnd}
+	}
+	notices := []map[string]string{}
+	for _, text := range c.Notices {
+		notices = append(notices, map[string]string{"notice_type": "warning", "notice_text": "<p>" + text + "</p>"})
+	}
+	detail["notices"] = notices
+	writeJSON(w, map[string]interface{}{"campground": detail})
+}
+
+// search serves the reservable campgrounds whose names contain the query,
+// ignoring case.
+func (s *Server) search(w http.ResponseWriter, r *http.Request) {
+	query := strings.ToLower(r.URL.Query().Get("q"))
+	results := []map[string]interface{}{}
+	for _, c := range s.current().Campgrounds {
+		if !strings.Contains(strings.ToLower(c.Name), query) {
+			continue
+		}
+		results = append(results, map[string]interface{}{
+			"entity_id":       c.ID,
+			"entity_type":     "campground",
+			"name":            c.Name,
+			"state_code":      c.State,
+			"reservable":      true,
+			"campsites_count": len(c.Sites),
+		})
+	}
+	writeJSON(w, map[string]interface{}{"results": results})
+}
+
+// permit serves one month of a permit's quotas, each division listing the
+// dates of the month the fixture gives it.
+func (s *Server) permit(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/api/permits/"), "/")
+	p, ok := s.current().permit(parts[0])
+	if len(parts) != 3 || parts[1] != "availability" || parts[2] != "month" || !ok {
+		http.NotFound(w, r)
+		return
+	}
+	start, err := time.Parse(time.RFC3339, r.URL.Query().Get("start_date"))
+	if err != nil {
+		http.Error(w, "bad start_date", http.StatusBadRequest)
+		return
+	}
+	availability := map[string]interface{}{}
+	for _, division := range p.Divisions {
+		dates := map[string]interface{}{}
+		for date, remaining := range division.Remaining {
+			day, err := time.Parse("2006-01-02", date)
+			if err != nil || day.Year() != start.Year() || day.Month() != start.Month() {
+				continue
+			}
+			dates[day.Format("2006-01-02T15:04:05Z")] = map[string]int{"total": division.Total, "remaining": remaining}
+		}
+		availability[division.ID] = map[string]interface{}{
+			"division_id":       division.ID,
+			"date_availability": dates,
+		}
+	}
+	writeJSON(w, map[string]interface{}{"payload": map[string]interface{}{
+		"permit_id":    p.ID,
+		"availability": availability,
+	}})
+}
+
+// ticket serves one day of a ticketed facility's tour slots.
+func (s *Server) ticket(w http.ResponseWriter, r *http.Request) {
+	facility, ok := s.current().tours(strings.TrimPrefix(r.URL.Path, "/api/ticket/availability/facility/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	date := r.URL.Query().Get("date")
+	slots := []map[string]interface{}{}
+	for _, slot := range facility.Slots {
+		if slot.Date != date {
+			continue
+		}
+		status := slot.Status
+		if status == "" {
+			status = "AVAILABLE"
+		}
+		slots = append(slots, map[string]interface{}{
+			"tour_id":           slot.TourID,
+			"tour_date":         slot.Date,
+			"tour_time":         slot.Time + ":00",
+			"inventory_count":   map[string]int{"ANY": slot.Capacity},
+			"reservation_count": map[string]int{"ANY": slot.Booked},
+			"status":            status,
+		})
+	}
+	writeJSON(w, slots)
+}
+
+// rates lists a year-round nightly fee, or none when it is zero.
+func rates(fee float64) []map[string]interface{} {
+	if fee == 0 {
+		return []map[string]interface{}{}
+	}
+	return []map[string]interface{}{{"season_type": "Standard", "per_night": fee}}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,75 @@
+package fakerecgov
+
+import (
+	"context"
+	"sync"
+)
+
+// Sheet is a scraper.SheetClient holding its cells in memory. Writes counts
+// the cells SetCell has written. It is safe for concurrent use.
+type Sheet struct {
+	mu     sync.Mutex
+	rows   [][]string
+	writes int
+}
+
+// NewSheet returns a sheet holding rows, headers first.
+func NewSheet(rows ...[]string) *Sheet {
+	s := &Sheet{}
+	s.SetRows(rows...)
+	return s
+}
+
+// SetRows replaces the sheet's cells, as an editor would.
+func (s *Sheet) SetRows(rows ...[]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rows = copyRows(rows)
+}
+
+// Rows implements scraper.SheetClient.
+func (s *Sheet) Rows(ctx context.Context) ([][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return copyRows(s.rows), nil
+}
+
+// SetCell implements scraper.SheetClient, growing the sheet as needed.
+func (s *Sheet) SetCell(ctx context.Context, row int, column int, value string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for len(s.rows) <= row {
+		s.rows = append(s.rows, nil)
+	}
+	for len(s.rows[row]) <= column {
+		s.rows[row] = append(s.rows[row], "")
+	}
+	s.rows[row][column] = value
+	s.writes++
+	return nil
+}
+
+// Cell returns the value at row and column, or "" outside the sheet.
+func (s *Sheet) Cell(row int, column int) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if row >= len(s.rows) || column >= len(s.rows[row]) {
+		return ""
+	}
+	return s.rows[row][column]
+}
+
+// Writes counts the cells written so far.
+func (s *Sheet) Writes() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.writes
+}
+
+func copyRows(rows [][]string) [][]string {
+	copied := make([][]string, len(rows))
+	for i, row := range rows {
+		copied[i] = append([]string{}, row...)
+	}
+	return copied
+}
@@ -0,0 +1,46 @@
+package scraper
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+)
+
+// jitterSchedule offsets a "*/n" minute field by a stable amount derived from
+// key, so watches sharing a cadence fire spread across the interval instead
+// of all at the top of it. "*/10 * * * *" becomes, say, "7-59/10 * * * *",
+// which keeps the same rate. Schedules with any other minute field are
+// returned unchanged with an offset of zero.
+func jitterSchedule(schedule, key string) (string, int) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 || !strings.HasPrefix(fields[0], "*/") {
+		return schedule, 0
+	}
+	step, err := strconv.Atoi(strings.TrimPrefix(fields[0], "*/"))
+	if err != nil || step <= 1 || step > 59 {
+		return schedule, 0
+	}
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	offset := int(h.Sum32() % uint32(step))
+	if offset == 0 {
+		return schedule, 0
+	}
+	fields[0] = fmt.Sprintf("%d-59/%d", offset, step)
+	return strings.Join(fields, " "), offset
+}
+
+// jitterOffset reads back the offset jitterSchedule gave schedule: the start
+// of a "n-59/step" minute field, or zero for any other schedule.
+func jitterOffset(schedule string) int {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return 0
+	}
+	var offset, step int
+	if n, err := fmt.Sscanf(fields[0], "%d-59/%d", &offset, &step); err != nil || n != 2 || offset <= 0 || offset >= step {
+		return 0
+	}
+	return offset
+}
@@ -0,0 +1,68 @@
+package scraper
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestJitterSchedule(t *testing.T) {
+	tests := []struct {
+		schedule string
+		// spread is set when the schedule is one jitterSchedule offsets.
+		spread bool
+	}{
+		{"*/10 * * * *", true},
+		{"*/5 8-20 * * 1-5", true},
+		{"*/1 * * * *", false},
+		{"0 * * * *", false},
+		{"15,45 * * * *", false},
+		{"*/10 * * *", false},
+	}
+	for _, test := range tests {
+		t.Run(test.schedule, func(t *testing.T) {
+			offsets := map[int]bool{}
+			for i := 0; i < 50; i++ {
+				key := fmt.Sprintf("watch-232447-%d", i)
+				jittered, offset := jitterSchedule(test.schedule, key)
+				if again, _ := jitterSchedule(test.schedule, key); again != jittered {
+					t.Fatalf("jitterSchedule(%q) is not stable: %q then %q", key, jittered, again)
+				}
+				if got := jitterOffset(jittered); got != offset {
+					t.Errorf("jitterOffset(%q) = %d, want %d", jittered, got, offset)
+				}
+				if offset == 0 && jittered != test.schedule {
+					t.Errorf("jitterSchedule(%q) = %q with no offset", key, jittered)
+				}
+				rate, err := cronRunsPerHour(jittered)
+				want, wantErr := cronRunsPerHour(test.schedule)
+				if (err != nil) != (wantErr != nil) || rate != want {
+					t.Errorf("%q runs %v times an hour, %q %v", jittered, rate, test.schedule, want)
+				}
+				offsets[offset] = true
+			}
+			if spread := len(offsets) > 1; spread != test.spread {
+				t.Errorf("offsets %v, want spread %v", offsets, test.spread)
+			}
+		})
+	}
+}
+
+func TestJitterOffset(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     int
+	}{
+		{"7-59/10 * * * *", 7},
+		{"3-59/5 8-20 * * *", 3},
+		{"*/10 * * * *", 0},
+		{"0-59/10 * * * *", 0},
+		{"5-30/10 * * * *", 0},
+		{"12-59/10 * * * *", 0},
+		{"7-59/10 * * *", 0},
+	}
+	for _, test := range tests {
+		if got := jitterOffset(test.schedule); got != test.want {
+			t.Errorf("jitterOffset(%q) = %d, want %d", test.schedule, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package scraper
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var jobIDPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,500}$`)
+
+// JobName is a parsed Cloud Scheduler job resource name of the form
+// projects/{project}/locations/{location}/jobs/{job}.
+type JobName struct {
+	Project  string
+	Location string
+	Job      string
+}
+
+func (n JobName) String() string {
+	return fmt.Sprintf("projects/%s/locations/%s/jobs/%s", n.Project, n.Location, n.Job)
+}
+
+// JobNameError reports a job name that is neither a valid bare job ID nor a
+// well-formed fully-qualified resource name.
+type JobNameError struct {
+	Name   string
+	Reason string
+}
+
+func (e *JobNameError) Error() string {
+	return fmt.Sprintf("invalid job name %q: %s", e.Name, e.Reason)
+}
+
+// ParseJobName accepts either a bare job ID, which is resolved against the
+// configured project and location, or a fully-qualified resource name.
+func ParseJobName(name string) (JobName, error) {
+	if !strings.Contains(name, "/") {
+		if !jobIDPattern.MatchString(name) {
+			return JobName{}, &JobNameError{name, "job ID may only contain letters, digits, hyphens and underscores"}
+		}
+		return activeConfig.JobName(name), nil
+	}
+
+	parts := strings.Split(name, "/")
+	if len(parts) != 6 || parts[0] != "projects" || parts[2] != "locations" || parts[4] != "jobs" {
+		return JobName{}, &JobNameError{name, "want projects/{project}/locations/{location}/jobs/{job}"}
+	}
+	n := JobName{Project: parts[1], Location: parts[3], Job: parts[5]}
+	if n.Project == "" || n.Location == "" {
+		return JobName{}, &JobNameError{name, "project and location must not be empty"}
+	}
+	if !jobIDPattern.MatchString(n.Job) {
+		return JobName{}, &JobNameError{name, "job ID may only contain letters, digits, hyphens and underscores"}
+	}
+	return n, nil
+}
@@ -0,0 +1,48 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseJobName(t *testing.T) {
+	useConfig(t, Config{Project: "camp-finder-test", Location: "us-west2", Topic: "scrape"})
+	tests := []struct {
+		name string
+		want JobName
+		// wantErr is set when the name must be a *JobNameError.
+		wantErr bool
+	}{
+		{"watch-232447", JobName{"camp-finder-test", "us-west2", "watch-232447"}, false},
+		{"projects/camp-finder-test/locations/us-west2/jobs/watch-232447", JobName{"camp-finder-test", "us-west2", "watch-232447"}, false},
+		{"projects/other-project/locations/us-east1/jobs/tonight-232447", JobName{"other-project", "us-east1", "tonight-232447"}, false},
+		{"", JobName{}, true},
+		{"watch 232447", JobName{}, true},
+		{"locations/us-east1/jobs/watch-232447", JobName{}, true},
+		{"projects/p/regions/us-east1/jobs/watch-232447", JobName{}, true},
+		{"projects//locations/us-east1/jobs/watch-232447", JobName{}, true},
+		{"projects/p/locations/us-east1/jobs/", JobName{}, true},
+		{"projects/p/locations/us-east1/jobs/watch/extra", JobName{}, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := ParseJobName(test.name)
+			var invalid *JobNameError
+			if test.wantErr {
+				if !errors.As(err, &invalid) || invalid.Name != test.name {
+					t.Fatalf("ParseJobName error = %v, want a *JobNameError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseJobName: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("ParseJobName = %+v, want %+v", got, test.want)
+			}
+			if again, err := ParseJobName(got.String()); err != nil || again != got {
+				t.Errorf("ParseJobName(%s) = %+v, %v; want it unchanged", got, again, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// LastMinuteOptions configures a watch for tonight, and optionally tomorrow
+// night, that scans aggressively and expires at a local cutoff hour.
+type LastMinuteOptions struct {
+	CampgroundID string
+	// Tomorrow extends the stay to two nights.
+	Tomorrow bool
+	// CutoffHour is the local hour after which the watch gives up. Defaults to 20.
+	CutoffHour int
+	// TimeZone is the campground's IANA zone. Defaults to America/Los_Angeles.
+	TimeZone string
+	// Schedule is the scan cron. Defaults to every five minutes.
+	Schedule string
+	// NotifyPhone is the E.164 number texted when a site opens. Texts are
+	// sent before any other channel, since tonight's site is gone in
+	// minutes.
+	NotifyPhone string
+	// NotifyEmail and NotifyName address the watch's emails, the
+	// deployment's recipient by default.
+	NotifyEmail string
+	NotifyName  string
+	// Force creates the watch even when an overlapping one exists.
+	Force bool
+	// Jitter offsets a "*/n" schedule by a few minutes derived from the job
+	// name, so watches created together do not all hit recreation.gov at
+	// once.
+	Jitter bool
+	// ProjectID, Location and Topic default to the package configuration,
+	// GCP_PROJECT, SCHEDULER_LOCATION and WATCH_TOPIC.
+	ProjectID string
+	Location  string
+	Topic     string
+}
+
+func (o *LastMinuteOptions) setDefaults() {
+	if o.CutoffHour == 0 {
+		o.CutoffHour = 20
+	}
+	if o.TimeZone == "" {
+		o.TimeZone = "America/Los_Angeles"
+	}
+	if o.Schedule == "" {
+		o.Schedule = "*/5 * * * *"
+	}
+	if o.ProjectID == "" {
+		o.ProjectID = activeConfig.Project
+	}
+	if o.Location == "" {
+		o.Location = activeConfig.Location
+	}
+	if o.Topic == "" {
+		o.Topic = activeConfig.Topic
+	}
+}
+
+// CreateLastMinuteWatch creates a scheduler job watching tonight's dates at
+// the campground. The job deletes itself once a site is found or the cutoff
+// passes, whichever comes first. If an overlapping watch already exists it is
+// returned instead, with merged set; see createWatchJob.
+func CreateLastMinuteWatch(ctx context.Context, opts LastMinuteOptions) (JobName, bool, error) {
+	opts.setDefaults()
+	loc, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return JobName{}, false, fmt.Errorf("invalid time zone %q: %v", opts.TimeZone, err)
+	}
+	now := DefaultScraper.now().In(loc)
+	cutoff := time.Date(now.Year(), now.Month(), now.Day(), opts.CutoffHour, 0, 0, 0, loc)
+	if !now.Before(cutoff) {
+		return JobName{}, false, fmt.Errorf("it is already past %s in %s", cutoff.Format("3pm"), opts.TimeZone)
+	}
+	nights := 1
+	if opts.Tomorrow {
+		nights = 2
+	}
+	arrival := core.CivilDateOf(now)
+	departure := arrival.AddDays(nights)
+	warning, err := checkRequestRate(opts.Schedule, 1, monthsCovered(arrival, departure))
+	if err != nil {
+		return JobName{}, false, err
+	}
+	if warning != "" {
+		logger.Println(warning)
+	}
+
+	name := JobName{
+		Project:  opts.ProjectID,
+		Location: opts.Location,
+		Job:      fmt.Sprintf("tonight-%s-%s", opts.CampgroundID, arrival),
+	}
+	schedule := opts.Schedule
+	if opts.Jitter {
+		var offset int
+		schedule, offset = jitterSchedule(schedule, name.Job)
+		logger.Printf("job %s: schedule %q offset by %d minutes to %q", name.Job, opts.Schedule, offset, schedule)
+	}
+	m := MessageContent{
+		Name:        name.Job,
+		Campground:  opts.CampgroundID,
+		Arrival:     arrival.Time().Format(layoutISO),
+		Departure:   departure.Time().Format(layoutISO),
+		Cutoff:      cutoff.Format(time.RFC3339),
+		TimeZone:    opts.TimeZone,
+		NotifyPhone: opts.NotifyPhone,
+		NotifyEmail: opts.NotifyEmail,
+		NotifyName:  opts.NotifyName,
+	}
+	if err := m.Validate(); err != nil {
+		return JobName{}, false, err
+	}
+
+	return createWatchJob(ctx, name, schedule, opts.TimeZone, opts.Topic, m, opts.Force)
+}
+
+// cutoffPassed reports whether a watch with a cutoff has run out of time.
+func cutoffPassed(m MessageContent) bool {
+	if m.Cutoff == "" {
+		return false
+	}
+	cutoff, err := time.Parse(time.RFC3339, m.Cutoff)
+	if err != nil {
+		logger.Printf("ignoring invalid cutoff %q: %v", m.Cutoff, err)
+		return false
+	}
+	return !DefaultScraper.now().Before(cutoff)
+}
+
+// sendNoLuckNotice is the closing message for a watch that hit its cutoff,
+// or for a release watch, its burst. It rescans once so the notice can say
+// how close the campground came; the notice is still sent without that line
+// if the scan fails.
+func sendNoLuckNotice(ctx context.Context, m MessageContent, summary WatchSummary) error {
+	subject := fmt.Sprintf("No luck tonight at %s", m.Campground)
+	body := fmt.Sprintf("No sites opened up at campground %s before the cutoff, so the watch %s has been removed.",
+		m.Campground, m.Name)
+	if release, err := time.Parse(time.RFC3339, m.Release); err == nil {
+		release = release.In(m.location())
+		subject = fmt.Sprintf("Nothing bookable at the %s release at %s", release.Format("Jan 2"), m.Campground)
+		body = fmt.Sprintf("No sites at campground %s became bookable in the %d minutes either side of their expected release at %s, so the release watch %s has been removed.",
+			m.Campground, int(releaseBurst/time.Minute), release.Format("3:04 PM MST on Jan 2"), m.Name)
+	}
+	result, err := core.ScrapeDetailed(ctx, providerFor(m), m.Campground, stayDate(m.Arrival), stayDate(m.Departure))
+	if err != nil {
+		logger.Printf("job %s: final scan for the no-luck summary: %v", m.Name, err)
+	} else {
+		filtered := result.Filter(m.siteFilter())
+		body += " At the cutoff: " + filtered.Summary() + "."
+		if len(filtered.Sites) == 0 && len(result.Sites) > 0 {
+			body += " " + filteredOutNote(m.siteFilter(), len(result.Sites)) + "."
+		}
+	}
+	return sendNoticeTo(ctx, m.recipient(), subject, body+"\n\n"+summary.Text(), "<p>"+html.EscapeString(body)+"</p>"+summary.HTML())
+}
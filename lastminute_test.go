@@ -0,0 +1,133 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+func TestCreateLastMinuteWatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      scraper.LastMinuteOptions
+		wantField string
+	}{
+		{name: "deployment recipient", opts: scraper.LastMinuteOptions{}},
+		{name: "phone and email", opts: scraper.LastMinuteOptions{NotifyPhone: "+14155550100", NotifyEmail: "me@example.com"}},
+		{name: "bad phone", opts: scraper.LastMinuteOptions{NotifyPhone: "415-555-0100"}, wantField: "NotifyPhone"},
+		{name: "bad email", opts: scraper.LastMinuteOptions{NotifyEmail: "Me <me@example.com>"}, wantField: "NotifyEmail"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(false))
+			defer h.Close()
+			// 11am in Los Angeles, well before the 8pm cutoff.
+			h.Clock.Set(time.Date(2027, 7, 14, 18, 0, 0, 0, time.UTC))
+			opts := test.opts
+			opts.CampgroundID = "232447"
+			opts.ProjectID, opts.Location, opts.Topic = testConfig.Project, testConfig.Location, testConfig.Topic
+
+			name, _, err := scraper.CreateLastMinuteWatch(context.Background(), opts)
+			if test.wantField != "" {
+				var invalid *scraper.ValidationError
+				if !errors.As(err, &invalid) || invalid.Field != test.wantField {
+					t.Fatalf("CreateLastMinuteWatch error = %v, want a %s ValidationError", err, test.wantField)
+				}
+				if jobs, _ := h.Scheduler.ListJobs(context.Background(), testConfig.Parent()); len(jobs) != 0 {
+					t.Errorf("created %d jobs for an invalid watch", len(jobs))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("CreateLastMinuteWatch: %v", err)
+			}
+			m := jobWatch(t, h, name)
+			if m.Name != name.String() {
+				t.Errorf("watch named %q, want its job's full name %s", m.Name, name)
+			}
+			if m.NotifyPhone != opts.NotifyPhone || m.NotifyEmail != opts.NotifyEmail {
+				t.Errorf("watch texts %q and emails %q, want %q and %q", m.NotifyPhone, m.NotifyEmail, opts.NotifyPhone, opts.NotifyEmail)
+			}
+			if m.Arrival != "2027-7-14" || m.Departure != "2027-7-15" || m.Cutoff != "2027-07-14T20:00:00-07:00" {
+				t.Errorf("watch for %s to %s with cutoff %s, want tonight until 8pm", m.Arrival, m.Departure, m.Cutoff)
+			}
+		})
+	}
+}
+
+// A release watch that finds nothing in its burst closes with a release-day
+// notice rather than the last-minute one.
+func TestReleaseWatchNoLuck(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip(err)
+	}
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	// Ten minutes before January 14's dates open at 10am Eastern.
+	h.Clock.Set(time.Date(2027, 7, 14, 13, 50, 0, 0, time.UTC))
+	ctx := context.Background()
+	watch, err := scraper.CreateReleaseWatch(ctx, scraper.ReleaseOptions{
+		CampgroundID: "232447",
+		Arrival:      core.CivilDate{Year: 2028, Month: time.January, Day: 14},
+		Departure:    core.CivilDate{Year: 2028, Month: time.January, Day: 16},
+		ProjectID:    testConfig.Project, Location: testConfig.Location, Topic: testConfig.Topic,
+	})
+	if err != nil {
+		t.Fatalf("CreateReleaseWatch: %v", err)
+	}
+	if want := time.Date(2027, 7, 14, 14, 0, 0, 0, time.UTC); !watch.Release.Equal(want) {
+		t.Errorf("release at %v, want %v", watch.Release, want)
+	}
+	if schedule := h.Scheduler.Job(watch.Name.String()).Schedule; schedule != "* 9,10 14 7 *" {
+		t.Errorf("scheduled %q, want every minute from 9:45 to 10:15", schedule)
+	}
+
+	h.Clock.Set(time.Date(2027, 7, 14, 14, 16, 0, 0, time.UTC))
+	if err := h.Fire(ctx, watch.Name.String()); err != nil {
+		t.Fatalf("running the watch after its burst: %v", err)
+	}
+	notices := h.Notifier.Notices()
+	if len(notices) != 1 || notices[0].Subject != "Nothing bookable at the Jul 14 release at 232447" {
+		t.Fatalf("got notices %+v, want one release-day no-luck notice", notices)
+	}
+	if !strings.Contains(notices[0].Plain, "10:00 AM EDT on Jul 14") {
+		t.Errorf("notice %q does not give the release time", notices[0].Plain)
+	}
+	if h.Scheduler.Job(watch.Name.String()) != nil {
+		t.Errorf("release watch still scheduled after its burst")
+	}
+}
+
+// An explicit ReleaseHour of 0 is a midnight release, not the 10am default,
+// and its burst straddles the day before.
+func TestReleaseWatchMidnight(t *testing.T) {
+	if _, err := time.LoadLocation("America/New_York"); err != nil {
+		t.Skip(err)
+	}
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 7, 13, 12, 0, 0, 0, time.UTC))
+	midnight := 0
+	watch, err := scraper.CreateReleaseWatch(context.Background(), scraper.ReleaseOptions{
+		CampgroundID: "232447",
+		Arrival:      core.CivilDate{Year: 2028, Month: time.January, Day: 14},
+		Departure:    core.CivilDate{Year: 2028, Month: time.January, Day: 16},
+		ReleaseHour:  &midnight,
+		ProjectID:    testConfig.Project, Location: testConfig.Location, Topic: testConfig.Topic,
+	})
+	if err != nil {
+		t.Fatalf("CreateReleaseWatch: %v", err)
+	}
+	if want := time.Date(2027, 7, 14, 4, 0, 0, 0, time.UTC); !watch.Release.Equal(want) {
+		t.Errorf("release at %v, want midnight Eastern, %v", watch.Release, want)
+	}
+	if schedule := h.Scheduler.Job(watch.Name.String()).Schedule; schedule != "* 0,23 13,14 7 *" {
+		t.Errorf("scheduled %q, want every minute from 23:45 to 00:15", schedule)
+	}
+}
@@ -0,0 +1,167 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+)
+
+// Log severities understood by Cloud Logging.
+const (
+	severityInfo    = "INFO"
+	severityWarning = "WARNING"
+	severityError   = "ERROR"
+)
+
+// Outcomes of a ScrapeFromMessage run, as logged in LogEntry.Outcome.
+const (
+	outcomeInvalid       = "invalid payload"
+	outcomePaused        = "paused by operator"
+	outcomeCutoff        = "cutoff passed"
+	outcomeExpired       = "expired"
+	outcomeSeasonClosed  = "season closed"
+	outcomeSeasonShifted = "moved into season"
+	outcomeOutsideScan   = "outside scan window"
+	outcomeSnoozed       = "snoozed"
+	outcomeScrapeError   = "scrape error"
+	outcomeBlocked       = "blocked by recreation.gov"
+	outcomeNoneFound     = "no availability"
+	outcomeNotReleased   = "not yet released"
+	outcomeFound         = "found"
+	outcomeNothingNew    = "nothing newly available"
+	outcomeHeld          = "held for quiet hours"
+	outcomeCapped        = "daily alert cap reached"
+	outcomeNotified      = "notified"
+	outcomeNotifyFailed  = "notification failed"
+	outcomeDuplicate     = "already alerted"
+	outcomeKept          = "kept"
+)
+
+// LogEntry is one structured log record. Cloud Logging reads severity and
+// message from the JSON line and keeps the rest as searchable fields.
+type LogEntry struct {
+	Severity     string `json:"severity"`
+	Message      string `json:"message"`
+	JobName      string `json:"jobName,omitempty"`
+	CampgroundID string `json:"campgroundID,omitempty"`
+	Arrival      string `json:"arrival,omitempty"`
+	Departure    string `json:"departure,omitempty"`
+	// Outcome is how the run ended, such as "notified" or "scrape error".
+	Outcome    string `json:"outcome,omitempty"`
+	DurationMs int64  `json:"durationMs"`
+	SitesFound int    `json:"sitesFound"`
+	JobDeleted bool   `json:"jobDeleted"`
+	DryRun     bool   `json:"dryRun,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Logger receives the summary entry of every ScrapeFromMessage run. Set
+// DefaultScraper.Log to capture them.
+type Logger interface {
+	Log(entry LogEntry)
+}
+
+// JSONLogger writes each entry as one line of JSON, redacted like the rest
+// of the package's logs.
+type JSONLogger struct {
+	W io.Writer
+}
+
+// Log implements Logger.
+func (l JSONLogger) Log(entry LogEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		logger.Printf("encoding log entry: %v", err)
+		return
+	}
+	redactingWriter{l.W}.Write(append(line, '\n'))
+}
+
+// jsonLineWriter turns each line the package's logger writes into a Cloud
+// Logging entry, so free-form messages are still attributed a severity.
+type jsonLineWriter struct {
+	w io.Writer
+}
+
+func (j jsonLineWriter) Write(p []byte) (int, error) {
+	line, err := json.Marshal(struct {
+		Severity string `json:"severity"`
+		Message  string `json:"message"`
+	}{severityInfo, strings.TrimRight(string(p), "\n")})
+	if err != nil {
+		return 0, err
+	}
+	if _, err := j.w.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// watchRun collects what one ScrapeFromMessage run did for its summary
+// entry.
+type watchRun struct {
+	// dryRun is set by the caller to skip notifications and job changes.
+	dryRun  bool
+	watch   MessageContent
+	alert   alert
+	outcome string
+	sites   int
+	deleted bool
+	// notified is set once an alert went out on at least one channel.
+	notified bool
+	// registered is set when the watch was loaded from the registry.
+	registered bool
+	// contactProblem says why the watch's contact could not be resolved,
+	// so its alerts went to the deployment's recipient.
+	contactProblem string
+}
+
+// scraped reports whether the run got as far as scraping the campground.
+func (r *watchRun) scraped() bool {
+	switch r.outcome {
+	case outcomeNoneFound, outcomeFound, outcomeNotified, outcomeNotifyFailed, outcomeKept, outcomeNothingNew,
+		outcomeHeld, outcomeCapped, outcomeNotReleased:
+		return true
+	}
+	return false
+}
+
+// deleteJob deletes the run's job, recording outcome and whether the
+// delete went through.
+func (r *watchRun) deleteJob(ctx context.Context, outcome string) error {
+	r.outcome = outcome
+	err := deleteJob(ctx, r.watch.Name)
+	r.deleted = err == nil
+	return err
+}
+
+// entry is the run's summary record. A run that returned an error is logged
+// as one; a rejected payload is a warning, as retrying will not help.
+func (r watchRun) entry(err error, elapsed time.Duration) LogEntry {
+	e := LogEntry{
+		Severity:     severityInfo,
+		Message:      "watch run: " + r.outcome,
+		DryRun:       r.dryRun,
+		JobName:      r.watch.Name,
+		CampgroundID: strings.Join(r.watch.campgrounds(), ","),
+		Arrival:      r.watch.Arrival,
+		Departure:    r.watch.Departure,
+		Outcome:      r.outcome,
+		DurationMs:   int64(elapsed / time.Millisecond),
+		SitesFound:   r.sites,
+		JobDeleted:   r.deleted,
+	}
+	if r.outcome == outcomeBlocked {
+		e.Severity = severityWarning
+	}
+	if err != nil {
+		e.Severity = severityError
+		if r.outcome == outcomeInvalid {
+			e.Severity = severityWarning
+		}
+		e.Error = err.Error()
+	}
+	return e
+}
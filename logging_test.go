@@ -0,0 +1,75 @@
+package scraper_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// capturingLogger keeps the summary entries it is given.
+type capturingLogger struct {
+	mu      sync.Mutex
+	entries []scraper.LogEntry
+}
+
+func (l *capturingLogger) Log(entry scraper.LogEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+// Every run ends with exactly one summary entry naming the watch, its stay
+// and how the run ended.
+func TestRunSummaryEntry(t *testing.T) {
+	failing := e2eFixture(false)
+	failing.Campgrounds[0].FailStatus = 500
+	tests := []struct {
+		name    string
+		fixture fakerecgov.Fixture
+		watch   func(m *scraper.MessageContent)
+		want    scraper.LogEntry
+	}{
+		{"found", e2eFixture(true), func(m *scraper.MessageContent) {},
+			scraper.LogEntry{Severity: "INFO", Message: "watch run: notified", Outcome: "notified", SitesFound: 1, JobDeleted: true}},
+		{"none", e2eFixture(false), func(m *scraper.MessageContent) {},
+			scraper.LogEntry{Severity: "INFO", Message: "watch run: no availability", Outcome: "no availability"}},
+		{"error", failing, func(m *scraper.MessageContent) {},
+			scraper.LogEntry{Severity: "ERROR", Message: "watch run: scrape error", Outcome: "scrape error"}},
+		{"skipped", e2eFixture(true), func(m *scraper.MessageContent) {
+			m.ScanWindow, m.TimeZone = &scraper.ScanWindow{Start: "22:00", End: "06:00"}, "UTC"
+		}, scraper.LogEntry{Severity: "INFO", Message: "watch run: outside scan window", Outcome: "outside scan window"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(test.fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			log := &capturingLogger{}
+			scraper.DefaultScraper.Log = log
+			m := e2eWatch("summary-" + test.name)
+			test.watch(&m)
+
+			err := h.Run(context.Background(), m)
+			if len(log.entries) != 1 {
+				t.Fatalf("run logged %d summary entries, want 1: %+v", len(log.entries), log.entries)
+			}
+			got := log.entries[0]
+			if (got.Error != "") != (err != nil) || (err != nil && got.Error != err.Error()) {
+				t.Errorf("entry error %q, run returned %v", got.Error, err)
+			}
+			if got.DurationMs < 0 {
+				t.Errorf("entry duration %dms", got.DurationMs)
+			}
+			want := test.want
+			want.JobName, want.CampgroundID, want.Arrival, want.Departure = m.Name, "232447", m.Arrival, m.Departure
+			want.Error, want.DurationMs = got.Error, got.DurationMs
+			if got != want {
+				t.Errorf("entry %+v, want %+v", got, want)
+			}
+		})
+	}
+}
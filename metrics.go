@@ -0,0 +1,146 @@
+package scraper
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	monitoring "google.golang.org/api/monitoring/v3"
+)
+
+// RunMetrics is what one ScrapeFromMessage run measured.
+type RunMetrics struct {
+	// CampgroundID is the watch's campground, or its campgrounds joined by
+	// commas.
+	CampgroundID string
+	Outcome      string
+	Duration     time.Duration
+	// Statuses counts recreation.gov responses by HTTP status code. Months
+	// served from the cache are not counted.
+	Statuses       map[int]int
+	SitesChecked   int
+	SitesAvailable int
+	// Notifications is 1 when an alert went out on at least one channel.
+	Notifications int
+	// Errors is 1 when the run failed.
+	Errors int
+}
+
+// Metrics receives the measurements of every ScrapeFromMessage run that is
+// not a dry run. Implementations must be safe for concurrent use and should
+// not hold up the run for long.
+type Metrics interface {
+	Record(ctx context.Context, m RunMetrics)
+}
+
+// NopMetrics discards everything.
+type NopMetrics struct{}
+
+// Record implements Metrics.
+func (NopMetrics) Record(ctx context.Context, m RunMetrics) {}
+
+// MemoryMetrics keeps every run in memory, for tests.
+type MemoryMetrics struct {
+	mu   sync.Mutex
+	runs []RunMetrics
+}
+
+// Record implements Metrics.
+func (m *MemoryMetrics) Record(ctx context.Context, run RunMetrics) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.runs = append(m.runs, run)
+}
+
+// Runs returns the runs recorded so far, oldest first.
+func (m *MemoryMetrics) Runs() []RunMetrics {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]RunMetrics{}, m.runs...)
+}
+
+// metrics is the run's measurements.
+func (r watchRun) metrics(err error, elapsed time.Duration, statuses map[int]int) RunMetrics {
+	m := RunMetrics{
+		CampgroundID:   strings.Join(r.watch.campgrounds(), ","),
+		Outcome:        r.outcome,
+		Duration:       elapsed,
+		Statuses:       statuses,
+		SitesChecked:   r.alert.Checked,
+		SitesAvailable: r.sites,
+	}
+	if r.notified {
+		m.Notifications = 1
+	}
+	if err != nil {
+		m.Errors = 1
+	}
+	return m
+}
+
+// metricPrefix names the custom metrics CloudMonitoring writes.
+const metricPrefix = "custom.googleapis.com/camp_finder/"
+
+// CloudMonitoring writes each run as custom metrics to Cloud Monitoring,
+// labelled with the campground ID: scrape_duration in seconds, sites_checked,
+// sites_available, notifications and errors, and http_responses per status
+// code. Each is a gauge with one point per run, so sum them over a window to
+// chart them. Failures to write are logged.
+type CloudMonitoring struct {
+	Project string
+}
+
+// Record implements Metrics.
+func (c CloudMonitoring) Record(ctx context.Context, m RunMetrics) {
+	svc, err := monitoring.NewService(ctx)
+	if err != nil {
+		logger.Printf("writing metrics: monitoring.NewService: %v", err)
+		return
+	}
+	now := DefaultScraper.now().UTC().Format(time.RFC3339Nano)
+	labels := map[string]string{"campground": m.CampgroundID}
+	point := func(name string, labels map[string]string, value *monitoring.TypedValue) *monitoring.TimeSeries {
+		return &monitoring.TimeSeries{
+			Metric:     &monitoring.Metric{Type: metricPrefix + name, Labels: labels},
+			Resource:   &monitoring.MonitoredResource{Type: "global", Labels: map[string]string{"project_id": c.Project}},
+			MetricKind: "GAUGE",
+			Points:     []*monitoring.Point{{Interval: &monitoring.TimeInterval{EndTime: now}, Value: value}},
+		}
+	}
+	count := func(name string, labels map[string]string, n int) *monitoring.TimeSeries {
+		value := int64(n)
+		return point(name, labels, &monitoring.TypedValue{Int64Value: &value})
+	}
+	seconds := m.Duration.Seconds()
+	series := []*monitoring.TimeSeries{
+		point("scrape_duration", labels, &monitoring.TypedValue{DoubleValue: &seconds}),
+		count("sites_checked", labels, m.SitesChecked),
+		count("sites_available", labels, m.SitesAvailable),
+		count("notifications", labels, m.Notifications),
+		count("errors", labels, m.Errors),
+	}
+	for code, n := range m.Statuses {
+		series = append(series, count("http_responses", map[string]string{"campground": m.CampgroundID, "status": strconv.Itoa(code)}, n))
+	}
+	request := &monitoring.CreateTimeSeriesRequest{TimeSeries: series}
+	if _, err := svc.Projects.TimeSeries.Create("projects/"+c.Project, request).Context(ctx).Do(); err != nil {
+		logger.Printf("writing metrics for %s: %v", m.CampgroundID, err)
+	}
+}
+
+// metricsFromEnv is the recorder METRICS selects: "cloud-monitoring" for
+// CloudMonitoring in the deployment's project, otherwise none.
+func metricsFromEnv(project string) Metrics {
+	switch value := os.Getenv("METRICS"); value {
+	case "":
+		return NopMetrics{}
+	case "cloud-monitoring":
+		return CloudMonitoring{Project: project}
+	default:
+		logger.Printf("ignoring METRICS %q: only \"cloud-monitoring\" is supported", value)
+		return NopMetrics{}
+	}
+}
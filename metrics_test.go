@@ -0,0 +1,64 @@
+package scraper_test
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// Every run records one RunMetrics for its campground: a run finding sites
+// counts them and its notification, one finding none counts only what it
+// checked, and a failed one counts its error and the response behind it.
+func TestRunMetrics(t *testing.T) {
+	failing := e2eFixture(false)
+	failing.Campgrounds[0].FailStatus = http.StatusBadRequest
+	tests := []struct {
+		name    string
+		fixture fakerecgov.Fixture
+		wantErr bool
+		want    scraper.RunMetrics
+	}{
+		{name: "found", fixture: e2eFixture(true), want: scraper.RunMetrics{CampgroundID: "232447", Outcome: "notified",
+			Statuses: map[int]int{200: 1}, SitesChecked: 3, SitesAvailable: 1, Notifications: 1}},
+		{name: "none", fixture: e2eFixture(false), want: scraper.RunMetrics{CampgroundID: "232447", Outcome: "no availability",
+			Statuses: map[int]int{200: 1}, SitesChecked: 3}},
+		{name: "error", fixture: failing, wantErr: true, want: scraper.RunMetrics{CampgroundID: "232447", Outcome: "scrape error",
+			Statuses: map[int]int{400: 1}, Errors: 1}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(test.fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			metrics := &scraper.MemoryMetrics{}
+			scraper.DefaultScraper.Metrics = metrics
+
+			err := h.Run(context.Background(), e2eWatch("metrics-"+test.name))
+			if (err != nil) != test.wantErr {
+				t.Fatalf("Run: %v, want error %t", err, test.wantErr)
+			}
+			runs := metrics.Runs()
+			if len(runs) != 1 {
+				t.Fatalf("recorded %d runs, want 1", len(runs))
+			}
+			got := runs[0]
+			if got.Duration < 0 {
+				t.Errorf("duration %v", got.Duration)
+			}
+			got.Duration = 0
+			// Campsite and facility lookups for the alert are cached for the
+			// process, so only the month request is sure to be counted.
+			if ok := got.Statuses[http.StatusOK]; ok > 1 && len(got.Statuses) == 1 {
+				got.Statuses = map[int]int{http.StatusOK: 1}
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("recorded %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
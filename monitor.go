@@ -0,0 +1,233 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// maxMonitorNights caps the changed nights one site-monitor alert lists.
+// The rest are counted in its summary.
+const maxMonitorNights = 10
+
+// isSiteMonitor reports whether m follows one campsite's calendar instead of
+// looking for a stay.
+func (m MessageContent) isSiteMonitor() bool {
+	return m.Kind == kindSiteMonitor
+}
+
+// nightChange is one night whose status changed at a monitored site.
+type nightChange struct {
+	Night         core.CivilDate
+	Before, After string
+}
+
+// label is how the change is listed in an alert's Sites, such as "Tue Jul
+// 14: Reserved → Available".
+func (c nightChange) label() string {
+	return fmt.Sprintf("%s: %s → %s", c.Night.Time().Format("Mon Jan 2"), c.Before, c.After)
+}
+
+// siteSnapshot lists the status of site on each night from start up to end
+// as "2027-07-14=Reserved", in night order. A night the calendar does not
+// list is "Not listed".
+func siteSnapshot(site core.Campsite, start core.CivilDate, end core.CivilDate) []string {
+	snapshot := []string{}
+	for _, night := range core.Nights(start, end) {
+		status := site.Availabilities[night]
+		if status == "" {
+			status = "Not listed"
+		}
+		snapshot = append(snapshot, night.String()+"="+status)
+	}
+	return snapshot
+}
+
+// diffSnapshots returns the nights whose status differs between two
+// snapshots from siteSnapshot, in night order. Nights in only one of them,
+// as the window moves on, are not changes.
+func diffSnapshots(previous []string, current []string) []nightChange {
+	before := map[string]string{}
+	for _, entry := range previous {
+		if i := strings.Index(entry, "="); i > 0 {
+			before[entry[:i]] = entry[i+1:]
+		}
+	}
+	changes := []nightChange{}
+	for _, entry := range current {
+		i := strings.Index(entry, "=")
+		if i < 0 {
+			continue
+		}
+		old, ok := before[entry[:i]]
+		if !ok || old == entry[i+1:] {
+			continue
+		}
+		night, err := core.ParseCivilDate(entry[:i])
+		if err != nil {
+			continue
+		}
+		changes = append(changes, nightChange{Night: night, Before: old, After: entry[i+1:]})
+	}
+	return changes
+}
+
+// monitorSite runs a site-monitor watch: it reads the site's calendar from
+// Arrival, or today once that has passed, up to Departure and alerts with
+// every night whose status changed since the last alert, or since the
+// first scan, which only records the calendar. The calendar is stored only
+// once an alert went out, so changes held by quiet hours or the daily cap
+// are batched into the next one. The watch never deletes itself; its owner
+// removes it.
+func (s *Scraper) monitorSite(ctx context.Context, m MessageContent, run *watchRun, rehearsal bool) error {
+	a, snapshot, err := s.matchSiteMonitor(ctx, m, rehearsal)
+	a.ContactProblem = run.contactProblem
+	run.alert = a
+	if err != nil {
+		run.outcome = outcomeScrapeError
+		if _, ok := err.(*ValidationError); ok {
+			run.outcome = outcomeInvalid
+		}
+		return fmt.Errorf("job %s: %w", m.Name, err)
+	}
+	if rehearsal {
+		logger.Printf("rehearsal for job %s: %d changed nights found", m.Name, len(a.Sites)-1)
+		run.sites = len(a.Sites) - 1
+	}
+	if run.dryRun {
+		run.outcome = outcomeNoneFound
+		if len(a.Sites) > 0 {
+			run.outcome = outcomeFound
+		}
+		return nil
+	}
+	if rehearsal {
+		s.describeAlert(ctx, m, &a)
+		if err := sendAlert(ctx, a); err != nil {
+			run.outcome = outcomeNotifyFailed
+			return fmt.Errorf("job %s: sending alerts: %w", m.Name, err)
+		}
+		run.outcome = outcomeNotified
+		run.notified = true
+		return nil
+	}
+	if len(a.Sites) == 0 {
+		run.outcome = outcomeNoneFound
+		saveSnapshot(ctx, m, snapshot)
+		return nil
+	}
+	gated := m.limitsDelivery()
+	var delivery deliveryState
+	if gated {
+		state, held, capped, err := gateDelivery(ctx, m, &a)
+		switch {
+		case err != nil:
+			logger.Printf("%v; alerting without quiet hours or a daily cap", err)
+			gated = false
+		case held:
+			run.alert = a
+			run.outcome = outcomeHeld
+			return nil
+		case capped:
+			run.outcome = outcomeCapped
+			return nil
+		}
+		delivery = state
+		run.alert = a
+	}
+	s.describeAlert(ctx, m, &a)
+	a.Persistent = true
+	err = sendAlert(ctx, a)
+	if multi, ok := err.(*MultiError); ok && multi.AllFailed() {
+		run.outcome = outcomeNotifyFailed
+		return fmt.Errorf("job %s: sending alerts: %w", m.Name, err)
+	}
+	if err != nil {
+		logger.Println("sending alerts:", err)
+	}
+	run.outcome = outcomeNotified
+	run.notified = true
+	if gated {
+		recordDelivery(ctx, m, delivery)
+	}
+	saveSnapshot(ctx, m, snapshot)
+	return nil
+}
+
+// saveSnapshot stores the calendar the watch's next scan is compared with.
+// A failure only means the next alert may repeat changes, so it is logged.
+func saveSnapshot(ctx context.Context, m MessageContent, snapshot []string) {
+	if err := scanStore.SetLastScan(ctx, m.Name, snapshot); err != nil {
+		logger.Printf("job %s: saving calendar: %v", m.Name, err)
+	}
+}
+
+// matchSiteMonitor reads the monitored site's calendar and builds the alert
+// for the nights that changed since the stored calendar, without storing
+// anything. Each of the alert's Sites is a nightChange label, described in
+// NightChanges; at most maxMonitorNights are listed. It also returns the
+// calendar read, to be stored once the alert is delivered.
+func (s *Scraper) matchSiteMonitor(ctx context.Context, m MessageContent, rehearsal bool) (alert, []string, error) {
+	start, departure := stayDate(m.Arrival), stayDate(m.Departure)
+	if today := m.today(); start.Before(today) {
+		start = today
+	}
+	site := m.CampsiteIDs[0]
+	a := alert{
+		JobName:       m.Name,
+		Recipient:     m.recipient(),
+		NotifyPhone:   m.NotifyPhone,
+		SlackWebhook:  m.slackWebhook,
+		WebhookURL:    m.WebhookURL,
+		CampgroundID:  m.Campground,
+		Arrival:       start,
+		Departure:     departure,
+		Rehearsal:     rehearsal,
+		ScannedAt:     s.now().UTC(),
+		MonitoredSite: site,
+		NightChanges:  map[string]nightChange{},
+	}
+	snapshot := []string{}
+	if start.Before(departure) {
+		campground, err := core.FetchRange(ctx, s.providerFor(m), m.Campground, start, departure)
+		if err != nil {
+			return a, nil, fmt.Errorf("scraping campground %s: %w", m.Campground, err)
+		}
+		campsite, ok := campground.Campsites[site]
+		if !ok {
+			return a, nil, &ValidationError{Field: "CampsiteIDs", Value: site, Reason: "is not a campsite of campground " + m.Campground}
+		}
+		if campsite.Site != "" {
+			a.SiteNames = map[string]string{site: campsite.Site}
+		}
+		snapshot = siteSnapshot(campsite, start, departure)
+	}
+	previous, err := scanStore.LastScan(ctx, m.Name)
+	if err != nil {
+		return a, nil, fmt.Errorf("reading the last calendar: %w", err)
+	}
+	changes := diffSnapshots(previous, snapshot)
+	a.ChangedNights = len(changes)
+	if len(changes) > maxMonitorNights {
+		changes = changes[:maxMonitorNights]
+	}
+	available := []string{}
+	for _, change := range changes {
+		a.NightChanges[change.label()] = change
+		available = append(available, change.label())
+	}
+	if rehearsal {
+		available = append([]string{rehearsalLabel}, available...)
+	}
+	if len(available) > 0 {
+		a.Sites = available
+	}
+	return a, snapshot, nil
+}
+
+// monitorName is the monitored site as alerts name it, such as "site A001".
+func (a alert) monitorName() string {
+	return "site " + a.siteName(a.MonitoredSite)
+}
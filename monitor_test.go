@@ -0,0 +1,189 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// monitorWatch follows site A001 of e2eFixture's campground for the nights
+// of July 14 to 19.
+func monitorWatch(id string) scraper.MessageContent {
+	m := e2eWatch(id)
+	m.Kind, m.CampsiteIDs, m.Departure = "site-monitor", []string{"1001"}, "2027-07-20"
+	return m
+}
+
+// monitorFixture is e2eFixture with A001's given nights; the rest are
+// reserved.
+func monitorFixture(nights map[string]string) fakerecgov.Fixture {
+	f := e2eFixture(false)
+	f.Campgrounds[0].Sites[0].Nights = nights
+	return f
+}
+
+// A site monitor records the calendar on its first scan, then alerts with
+// the nights that changed since its last alert, held by quiet hours and
+// snoozes, and keeps running whatever it finds.
+func TestSiteMonitor(t *testing.T) {
+	type step struct {
+		nights map[string]string
+		// hour is the UTC hour on June 1, 2027 the step runs at; zero is
+		// noon.
+		hour    int
+		snoozed bool
+		// wantAlert lists the sites of the step's one alert, if any.
+		wantAlert []string
+	}
+	tests := []struct {
+		name  string
+		watch func(m *scraper.MessageContent)
+		steps []step
+	}{
+		{"first scan records only", func(m *scraper.MessageContent) {}, []step{
+			{nights: map[string]string{"2027-07-14": "Available"}},
+		}},
+		{"change", func(m *scraper.MessageContent) {}, []step{
+			{},
+			{nights: map[string]string{"2027-07-14": "Available", "2027-07-16": "Not Reservable"},
+				wantAlert: []string{"Wed Jul 14: Reserved → Available", "Fri Jul 16: Reserved → Not Reservable"}},
+			{nights: map[string]string{"2027-07-14": "Available", "2027-07-16": "Not Reservable"}},
+			{wantAlert: []string{"Wed Jul 14: Available → Reserved", "Fri Jul 16: Not Reservable → Reserved"}},
+		}},
+		{"quiet hours batch changes", func(m *scraper.MessageContent) {
+			m.QuietHoursStart, m.QuietHoursEnd, m.TimeZone = "11:00", "13:00", "UTC"
+		}, []step{
+			{hour: 10},
+			{nights: map[string]string{"2027-07-14": "Available"}},
+			{nights: map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}},
+			{nights: map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}, hour: 14,
+				wantAlert: []string{"Wed Jul 14: Reserved → Available", "Thu Jul 15: Reserved → Available"}},
+		}},
+		{"snoozed", func(m *scraper.MessageContent) {}, []step{
+			{},
+			{nights: map[string]string{"2027-07-14": "Available"}, snoozed: true},
+			{nights: map[string]string{"2027-07-14": "Available"}, hour: 15,
+				wantAlert: []string{"Wed Jul 14: Reserved → Available"}},
+		}},
+		{"nights capped", func(m *scraper.MessageContent) { m.Arrival, m.Departure = "2027-07-01", "2027-07-31" }, []step{
+			{},
+			{nights: julyNights("Available"), wantAlert: []string{
+				"Thu Jul 1: Reserved → Available", "Fri Jul 2: Reserved → Available", "Sat Jul 3: Reserved → Available",
+				"Sun Jul 4: Reserved → Available", "Mon Jul 5: Reserved → Available", "Tue Jul 6: Reserved → Available",
+				"Wed Jul 7: Reserved → Available", "Thu Jul 8: Reserved → Available", "Fri Jul 9: Reserved → Available",
+				"Sat Jul 10: Reserved → Available",
+			}},
+		}},
+		{"dates passed", func(m *scraper.MessageContent) { m.Arrival, m.Departure = "2027-05-01", "2027-05-10" }, []step{
+			{}, {nights: map[string]string{"2027-05-02": "Available"}},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(monitorFixture(nil))
+			defer h.Close()
+			m := monitorWatch("monitor-" + strings.Replace(test.name, " ", "-", -1))
+			test.watch(&m)
+			if err := m.Validate(); err != nil {
+				t.Fatalf("Validate: %v", err)
+			}
+			sent := 0
+			for i, s := range test.steps {
+				hour := s.hour
+				if hour == 0 {
+					hour = 12
+				}
+				h.Clock.Set(time.Date(2027, 6, 1, hour, 0, 0, 0, time.UTC))
+				h.Server.SetFixture(monitorFixture(s.nights))
+				var err error
+				if i == 0 {
+					err = h.Run(context.Background(), m)
+				} else {
+					target := h.Scheduler.Job(m.Name).GetPubsubTarget()
+					target.Attributes = nil
+					if s.snoozed {
+						target.Attributes = map[string]string{"snoozed_until": "2027-06-01T13:00:00Z"}
+					}
+					err = h.Fire(context.Background(), m.Name)
+				}
+				if err != nil {
+					t.Fatalf("step %d: %v", i, err)
+				}
+				alerts := h.Notifier.Alerts()[sent:]
+				sent += len(alerts)
+				if len(alerts) != 0 && s.wantAlert == nil || len(alerts) != 1 && s.wantAlert != nil {
+					t.Fatalf("step %d: got %d alerts, want sites %v", i, len(alerts), s.wantAlert)
+				}
+				if len(alerts) == 1 {
+					ids := []string{}
+					for _, site := range alerts[0].Sites {
+						ids = append(ids, site.ID)
+					}
+					if strings.Join(ids, "|") != strings.Join(s.wantAlert, "|") {
+						t.Errorf("step %d: alert has sites %v, want %v", i, ids, s.wantAlert)
+					}
+				}
+				if h.Scheduler.Job(m.Name) == nil {
+					t.Fatalf("step %d: the watch deleted itself", i)
+				}
+			}
+			if notices := h.Notifier.Notices(); len(notices) != 0 {
+				t.Errorf("got notices %+v, want none", notices)
+			}
+		})
+	}
+}
+
+// julyNights gives every night of July 2027 status.
+func julyNights(status string) map[string]string {
+	nights := map[string]string{}
+	for night := time.Date(2027, 7, 1, 0, 0, 0, 0, time.UTC); night.Month() == time.July; night = night.AddDate(0, 0, 1) {
+		nights[night.Format("2006-01-02")] = status
+	}
+	return nights
+}
+
+// A site monitor follows exactly one campsite and takes none of the options
+// that only make sense for finding a stay.
+func TestSiteMonitorValidate(t *testing.T) {
+	tests := []struct {
+		name      string
+		watch     func(m *scraper.MessageContent)
+		wantField string
+	}{
+		{"valid", func(m *scraper.MessageContent) {}, ""},
+		{"arrival passed", func(m *scraper.MessageContent) { m.Arrival = "2027-05-01" }, ""},
+		{"quiet hours", func(m *scraper.MessageContent) { m.QuietHoursStart, m.QuietHoursEnd = "22:00", "07:00" }, ""},
+		{"no campsite", func(m *scraper.MessageContent) { m.CampsiteIDs = nil }, "CampsiteIDs"},
+		{"two campsites", func(m *scraper.MessageContent) { m.CampsiteIDs = []string{"1001", "1002"} }, "CampsiteIDs"},
+		{"several campgrounds", func(m *scraper.MessageContent) { m.Campgrounds = []string{"232447", "232450"} }, "Campgrounds"},
+		{"keep job", func(m *scraper.MessageContent) { m.KeepJob = true }, "KeepJob"},
+		{"cutoff", func(m *scraper.MessageContent) { m.Cutoff = "2027-07-01T00:00:00Z" }, "Cutoff"},
+		{"track changes", func(m *scraper.MessageContent) { m.TrackChanges = true }, "TrackChanges"},
+		{"expire after", func(m *scraper.MessageContent) { m.ExpireAfter = "departure" }, "ExpireAfter"},
+		{"capacity", func(m *scraper.MessageContent) { m.MinCapacity = 4 }, "MinCapacity"},
+		{"unknown kind", func(m *scraper.MessageContent) { m.Kind = "site-watch" }, "Kind"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(monitorFixture(nil))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := monitorWatch("monitor-validate")
+			test.watch(&m)
+			err := m.Validate()
+			var invalid *scraper.ValidationError
+			switch {
+			case test.wantField == "" && err != nil:
+				t.Errorf("Validate: %v, want nil", err)
+			case test.wantField != "" && (!errors.As(err, &invalid) || invalid.Field != test.wantField):
+				t.Errorf("Validate: %v, want an invalid %s", err, test.wantField)
+			}
+		})
+	}
+}
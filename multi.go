@@ -0,0 +1,217 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// maxConcurrentCampgrounds bounds how many campgrounds of one watch are
+// scraped at once.
+const maxConcurrentCampgrounds = 4
+
+// errCampgroundsPaused is returned by matchCampgrounds when the operator
+// control blocks every campground of the watch, so the run ends paused as a
+// single-campground one would.
+var errCampgroundsPaused = errors.New("every campground is paused by the operator")
+
+// campgrounds returns every campground the watch covers: Campgrounds when
+// set, otherwise the single Campground.
+func (m MessageContent) campgrounds() []string {
+	if len(m.Campgrounds) > 0 {
+		return m.Campgrounds
+	}
+	return []string{m.Campground}
+}
+
+// matchCampgrounds runs matchWatch for each of the watch's campgrounds,
+// at most maxConcurrentCampgrounds at a time, and combines the results into
+// one alert grouped by campground. A campground that fails or is closed for
+// the season is listed in the alert's Failed and does not stop the others.
+// The error is a *core.SeasonClosedError when every campground is out of
+// season, and otherwise a *MultiError only when every campground still in
+// season failed. Closed campgrounds are left out of that check, so one that
+// is closed alongside others that failed is retried rather than ended as
+// closed. Campgrounds the operator control blocks are not scraped, and are
+// listed in Paused rather than counted as failures; when every one is, the
+// error is errCampgroundsPaused.
+func (s *Scraper) matchCampgrounds(ctx context.Context, m MessageContent, rehearsal bool) (alert, error) {
+	ids := m.campgrounds()
+	groups := make([]alert, len(ids))
+	errs := make([]error, len(ids))
+
+	control := currentControl(ctx)
+	paused := map[string]string{}
+	slots := make(chan struct{}, maxConcurrentCampgrounds)
+	var wg sync.WaitGroup
+	for i, id := range ids {
+		if blocked, why := control.Blocks(id); blocked {
+			paused[id] = why
+			continue
+		}
+		wg.Add(1)
+		go func(i int, id string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if err := ctx.Err(); err != nil {
+				errs[i] = err
+				return
+			}
+			single := m
+			single.Campground, single.Campgrounds = id, nil
+			groups[i], errs[i] = s.matchWatch(ctx, s.providerFor(single), single, false)
+		}(i, id)
+	}
+	wg.Wait()
+
+	a := alert{
+		JobName:      m.Name,
+		Recipient:    m.recipient(),
+		NotifyPhone:  m.NotifyPhone,
+		SlackWebhook: m.slackWebhook,
+		WebhookURL:   m.WebhookURL,
+		CampgroundID: strings.Join(ids, ", "),
+		Arrival:      stayDate(m.Arrival),
+		Departure:    stayDate(m.Departure),
+		Rehearsal:    rehearsal,
+		ScannedAt:    s.now().UTC(),
+		Failed:       map[string]string{},
+		Paused:       paused,
+	}
+	failures := &MultiError{}
+	var firstClosed *core.SeasonClosedError
+	closedCount := 0
+	for i, id := range ids {
+		if why, ok := paused[id]; ok {
+			logger.Printf("job %s: campground %s: paused by operator: %s", m.Name, id, why)
+			continue
+		}
+		g := groups[i]
+		var closed *core.SeasonClosedError
+		switch {
+		case errors.As(errs[i], &closed):
+			a.Failed[id] = "closed for the season on these dates"
+			closedCount++
+			if firstClosed == nil {
+				firstClosed = closed
+			}
+		case g.Partial == nil && errs[i] != nil:
+			a.Failed[id] = errs[i].Error()
+			logger.Printf("job %s: campground %s: %v", m.Name, id, errs[i])
+			failures.Add(errs[i])
+		default:
+			failures.Add(errs[i])
+		}
+		if g.Partial != nil && a.Partial == nil {
+			a.Partial = g.Partial
+		}
+		a.MissingAttributes += g.MissingAttributes
+		for _, stage := range g.Trimmed {
+			a.trim(stage)
+		}
+		a.Checked += g.Checked
+		if len(g.Sites) == 0 {
+			continue
+		}
+		g.CampgroundID = id
+		a.Groups = append(a.Groups, g)
+		for _, site := range g.Sites {
+			a.Sites = append(a.Sites, id+": "+site)
+		}
+	}
+	if rehearsal {
+		a.Sites = append([]string{rehearsalLabel}, a.Sites...)
+	}
+	if len(paused) == len(ids) {
+		return a, errCampgroundsPaused
+	}
+	if closedCount == len(ids) {
+		// Every campground is out of season, so the watch ends like a
+		// single-campground one would.
+		return a, firstClosed
+	}
+	if failures.AllFailed() {
+		return a, failures
+	}
+	return a, nil
+}
+
+// renderGroups renders the per-campground sections of a multi-campground
+// alert, followed by the campgrounds that could not be checked and those
+// paused by the operator.
+func renderGroups(a alert) ([]string, string) {
+	lines := []string{}
+	out := ""
+	for _, g := range a.Groups {
+		lines = append(lines, fmt.Sprintf("Campground %s:", g.CampgroundID))
+		out += "<h3>Campground " + html.EscapeString(g.CampgroundID) + "</h3><ul>"
+		if g.Primary != "" {
+			lines = append(lines, "  Book this one first: site "+g.siteName(g.Primary))
+		}
+		for _, site := range g.Sites {
+			label := "Site " + site
+			if price := g.priceLabel(site); price != "" {
+				label += " (" + price + ")"
+			}
+			lines = append(lines, "  "+label)
+			item := html.EscapeString(label)
+			if link := claimLink(a.JobName, g.CampgroundID+"/"+site, a.ResultsPage); link != "" && !a.Rehearsal {
+				lines = append(lines, "    Booking it? Let the others know: "+link)
+				item += fmt.Sprintf(` <a href="%s">I'm booking this</a>`, html.EscapeString(link))
+			}
+			out += "<li>" + item + "</li>"
+		}
+		out += "</ul>"
+	}
+	failed := failedLines(a)
+	if len(failed) > 0 {
+		lines = append(lines, "", "Not checked:")
+		out += "<p>Not checked:</p><ul>"
+		for _, line := range failed {
+			lines = append(lines, "  "+line)
+			out += "<li>" + html.EscapeString(line) + "</li>"
+		}
+		out += "</ul>"
+	}
+	paused := pausedLines(a)
+	if len(paused) > 0 {
+		lines = append(lines, "", "Paused:")
+		out += "<p>Paused:</p><ul>"
+		for _, line := range paused {
+			lines = append(lines, "  "+line)
+			out += "<li>" + html.EscapeString(line) + "</li>"
+		}
+		out += "</ul>"
+	}
+	return lines, out
+}
+
+// failedLines describes each campground in a.Failed, in ID order.
+func failedLines(a alert) []string {
+	return campgroundLines(a.Failed)
+}
+
+// pausedLines does the same for a.Paused.
+func pausedLines(a alert) []string {
+	return campgroundLines(a.Paused)
+}
+
+func campgroundLines(reasons map[string]string) []string {
+	ids := make([]string, 0, len(reasons))
+	for id := range reasons {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	lines := make([]string, len(ids))
+	for i, id := range ids {
+		lines[i] = fmt.Sprintf("Campground %s: %s", id, reasons[id])
+	}
+	return lines
+}
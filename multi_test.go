@@ -0,0 +1,108 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// multiFixture is e2eFixture's campground alongside Lower Pines, with the
+// details the examples use. Each is open, closed for the season or failing
+// with a 500 on the nights of e2eWatch's stay, as upper and lower say,
+// or else fully reserved.
+func multiFixture(upper, lower string) fakerecgov.Fixture {
+	f := e2eFixture(upper == "open")
+	f.Campgrounds = append(f.Campgrounds, fakerecgov.Campground{
+		ID:   "232450",
+		Name: "Lower Pines",
+		Sites: []fakerecgov.Site{
+			{ID: "2001", Site: "B001", Loop: "B", Type: "STANDARD NONELECTRIC"},
+			{ID: "2002", Site: "B002", Loop: "B", Type: "TENT ONLY NONELECTRIC"},
+		},
+	})
+	for i, state := range []string{upper, lower} {
+		c := &f.Campgrounds[i]
+		switch state {
+		case "open":
+			c.Sites[0].Nights = map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}
+		case "closed":
+			for j := range c.Sites {
+				c.Sites[j].Nights = map[string]string{"2027-07-14": "Closed", "2027-07-15": "Closed"}
+			}
+		case "failing":
+			c.FailStatus = 500
+		}
+	}
+	return f
+}
+
+// A multi-campground run is retried only when every campground still in
+// season failed; one closed for the season neither ends the watch nor stands
+// in for a success.
+func TestMatchCampgrounds(t *testing.T) {
+	tests := []struct {
+		name         string
+		upper, lower string
+		// wantAlert lists the sites of the one alert expected, if any.
+		wantAlert   []string
+		wantRetry   bool
+		wantDeleted bool
+		wantNotice  string
+	}{
+		{name: "open and failing", upper: "open", lower: "failing", wantAlert: []string{"232447: 1001"}, wantDeleted: true},
+		{name: "failing and open", upper: "failing", lower: "open", wantAlert: []string{"232450: 2001"}, wantDeleted: true},
+		{name: "open and closed", upper: "open", lower: "closed", wantAlert: []string{"232447: 1001"}, wantDeleted: true},
+		{name: "closed and failing", upper: "closed", lower: "failing", wantRetry: true},
+		{name: "failing and closed", upper: "failing", lower: "closed", wantRetry: true},
+		{name: "both failing", upper: "failing", lower: "failing", wantRetry: true},
+		{name: "both closed", upper: "closed", lower: "closed", wantDeleted: true, wantNotice: "is closed"},
+		{name: "nothing free", upper: "reserved", lower: "reserved"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(multiFixture(test.upper, test.lower))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("multi-" + strings.Replace(test.name, " ", "-", -1))
+			m.Campground, m.Campgrounds = "", []string{"232447", "232450"}
+
+			err := h.Run(context.Background(), m)
+			var closed *core.SeasonClosedError
+			if retried := err != nil; retried != test.wantRetry {
+				t.Fatalf("Run error = %v, want retry %v", err, test.wantRetry)
+			}
+			if errors.As(err, &closed) {
+				t.Errorf("Run error %v carries a closed season", err)
+			}
+
+			alerts := h.Notifier.Alerts()
+			if len(alerts) != 0 && test.wantAlert == nil || len(alerts) != 1 && test.wantAlert != nil {
+				t.Fatalf("got %d alerts, want sites %v", len(alerts), test.wantAlert)
+			}
+			if len(alerts) == 1 {
+				ids := []string{}
+				for _, site := range alerts[0].Sites {
+					ids = append(ids, site.ID)
+				}
+				if strings.Join(ids, ",") != strings.Join(test.wantAlert, ",") {
+					t.Errorf("alert has sites %v, want %v", ids, test.wantAlert)
+				}
+			}
+			if deleted := h.Scheduler.Job(m.Name) == nil; deleted != test.wantDeleted {
+				t.Errorf("job deleted = %v, want %v", deleted, test.wantDeleted)
+			}
+			notices := h.Notifier.Notices()
+			if test.wantNotice == "" && len(notices) != 0 {
+				t.Errorf("got notices %+v, want none", notices)
+			}
+			if test.wantNotice != "" && (len(notices) != 1 || !strings.Contains(notices[0].Subject, test.wantNotice)) {
+				t.Errorf("got notices %+v, want one saying %q", notices, test.wantNotice)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// renderedAlert is an alert rendered once for every channel to use.
+type renderedAlert struct {
+	Subject string
+	Plain   string
+	HTML    string
+}
+
+// notifier delivers alerts over one channel to one destination.
+type notifier interface {
+	// Channel names the channel in logs and the archive.
+	Channel() string
+	// Recipient keys the dedupe state. Label is what the archive records,
+	// so it must not hold secrets such as webhook URLs.
+	Recipient() (key string, label string)
+	Notify(ctx context.Context, a alert, r renderedAlert) error
+}
+
+// notifiersFor returns a notifier for every destination the alert has:
+// email always, Slack when SLACK_WEBHOOK_URL is set or the watch's contact
+// has a webhook, SMS when the watch
+// has a phone number and Twilio is configured, and the watch's webhook
+// when WEBHOOK_SECRET is set. The text goes first when the alert is
+// TextFirst. Tests replace it.
+var notifiersFor = func(a alert) []notifier {
+	to := a.Recipient
+	if to == nil {
+		to = defaultRecipient()
+	}
+	notifiers := []notifier{emailNotifier{to: to}}
+	if webhook := os.Getenv("SLACK_WEBHOOK_URL"); webhook != "" {
+		notifiers = append(notifiers, slackNotifier{webhook: webhook, label: "SLACK_WEBHOOK_URL"})
+	}
+	if a.SlackWebhook != "" && a.SlackWebhook != os.Getenv("SLACK_WEBHOOK_URL") {
+		notifiers = append(notifiers, slackNotifier{webhook: a.SlackWebhook, label: "contact Slack webhook"})
+	}
+	if a.NotifyPhone != "" {
+		if sms, ok := twilioFromEnv(a.NotifyPhone); ok && a.TextFirst {
+			notifiers = append([]notifier{sms}, notifiers...)
+		} else if ok {
+			notifiers = append(notifiers, sms)
+		} else {
+			logger.Printf("job %s: not texting %s because TWILIO_SID, TWILIO_TOKEN or TWILIO_FROM is not set", a.JobName, a.NotifyPhone)
+		}
+	}
+	if a.WebhookURL != "" {
+		if webhook, ok := webhookFromEnv(a.WebhookURL); ok {
+			notifiers = append(notifiers, webhook)
+		} else {
+			logger.Printf("job %s: not calling its webhook because WEBHOOK_SECRET is not set", a.JobName)
+		}
+	}
+	return notifiers
+}
+
+type emailNotifier struct {
+	to *mail.Email
+}
+
+func (n emailNotifier) Channel() string { return "email" }
+
+func (n emailNotifier) Recipient() (string, string) { return n.to.Address, n.to.Address }
+
+func (n emailNotifier) Notify(ctx context.Context, a alert, r renderedAlert) error {
+	return sendNoticeTo(ctx, n.to, r.Subject, r.Plain, r.HTML)
+}
+
+type slackNotifier struct {
+	webhook string
+	// label names the webhook in the archive without giving it away.
+	label string
+}
+
+func (n slackNotifier) Channel() string { return "slack" }
+
+func (n slackNotifier) Recipient() (string, string) { return n.webhook, n.label }
+
+func (n slackNotifier) Notify(ctx context.Context, a alert, r renderedAlert) error {
+	return sendSlack(ctx, n.webhook, a)
+}
+
+// maxSMSLength is the longest body Twilio accepts, in characters.
+const maxSMSLength = 1600
+
+// defaultSMSParts is how many texts an alert may take unless SMS_MAX_PARTS
+// says otherwise.
+const defaultSMSParts = 2
+
+// twilioNotifier texts alerts through the Twilio Messages API.
+type twilioNotifier struct {
+	sid   string
+	token string
+	from  string
+	to    string
+	// maxParts is the most texts one alert is split into.
+	maxParts int
+	// baseURL replaces https://api.twilio.com, for tests.
+	baseURL string
+}
+
+// twilioFromEnv returns a notifier texting to, if TWILIO_SID, TWILIO_TOKEN
+// and TWILIO_FROM are all set. SMS_MAX_PARTS, when a positive number, caps
+// the texts per alert instead of defaultSMSParts.
+func twilioFromEnv(to string) (twilioNotifier, bool) {
+	n := twilioNotifier{sid: os.Getenv("TWILIO_SID"), token: os.Getenv("TWILIO_TOKEN"), from: os.Getenv("TWILIO_FROM"), to: to, maxParts: defaultSMSParts}
+	if parts, err := strconv.Atoi(os.Getenv("SMS_MAX_PARTS")); err == nil && parts > 0 {
+		n.maxParts = parts
+	}
+	return n, n.sid != "" && n.token != "" && n.from != ""
+}
+
+func (n twilioNotifier) Channel() string { return "sms" }
+
+func (n twilioNotifier) Recipient() (string, string) { return n.to, n.to }
+
+// Notify sends the alert as smsMessages gives it, one text after another,
+// stopping at the first that fails.
+func (n twilioNotifier) Notify(ctx context.Context, a alert, r renderedAlert) error {
+	for _, body := range smsMessages(a, n.maxParts) {
+		if err := n.send(ctx, body); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// send sends one text.
+func (n twilioNotifier) send(ctx context.Context, body string) error {
+	base := n.baseURL
+	if base == "" {
+		base = "https://api.twilio.com"
+	}
+	endpoint := fmt.Sprintf("%s/2010-04-01/Accounts/%s/Messages.json", base, n.sid)
+	form := url.Values{"To": {n.to}, "From": {n.from}, "Body": {body}}
+	request, err := http.NewRequest(http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.SetBasicAuth(n.sid, n.token)
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("sending sms: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		body, _ := ioutil.ReadAll(io.LimitReader(response.Body, 4096))
+		return fmt.Errorf("sending sms to %s: Twilio returned %d: %s", n.to, response.StatusCode, body)
+	}
+	return nil
+}
+
+// smsMessages is a short alert for texting in at most maxParts texts: the
+// campground, dates, the sites best first and a booking link, split by
+// textChunks to fit maxSMSLength characters each.
+func smsMessages(a alert, maxParts int) []string {
+	head := fmt.Sprintf("Campsites open at %s, %s: ", a.place(), a.stay())
+	if a.MonitoredSite != "" {
+		head = fmt.Sprintf("Site %s at %s changed on %d nights: ", a.siteName(a.MonitoredSite), a.place(), a.ChangedNights)
+	}
+	if a.Tours != nil {
+		head = fmt.Sprintf("Tour tickets at facility %s, %s: ", a.CampgroundID, a.tourDays())
+	}
+	if a.Rehearsal {
+		head = "[" + rehearsalLabel + "] " + head
+	}
+	if a.Primary != "" {
+		head += "book " + a.siteName(a.Primary) + " first. Sites: "
+	}
+	tail := ""
+	if len(a.Sites) > 0 && isCampsiteID(a.Sites[0]) {
+		tail = " " + campsiteURL + a.Sites[0]
+	}
+	if a.MonitoredSite != "" {
+		tail = " " + campsiteURL + a.MonitoredSite
+	}
+	if a.Tours != nil {
+		tail = " " + ticketURL + a.CampgroundID
+	}
+	if a.ResultsURL != "" {
+		tail += " Full results: " + a.ResultsURL
+	}
+	labels := make([]string, 0, len(a.Sites))
+	for _, site := range a.Sites {
+		label := a.siteName(site)
+		if run, ok := a.OpenRanges[site]; ok && partialStay(a, site) != "" {
+			label += fmt.Sprintf(" (%s-%s only)", run.Start.Time().Format("Jan 2"), run.End.Time().Format("Jan 2"))
+		}
+		if price, ok := a.Prices[site]; ok {
+			label += " " + core.FormatPrice(price.Total)
+		}
+		labels = append(labels, label)
+	}
+	return textChunks(head, labels, tail, maxSMSLength, maxParts)
+}
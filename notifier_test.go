@@ -0,0 +1,167 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+func TestNotifiersForOrder(t *testing.T) {
+	t.Setenv("SLACK_WEBHOOK_URL", "https://hooks.slack.example/T000")
+	t.Setenv("TWILIO_SID", "AC000")
+	t.Setenv("TWILIO_TOKEN", "token")
+	t.Setenv("TWILIO_FROM", "+14155550199")
+	tests := []struct {
+		name  string
+		alert alert
+		want  string
+	}{
+		{"no phone", alert{}, "email,slack"},
+		{"phone", alert{NotifyPhone: "+14155550100"}, "email,slack,sms"},
+		{"last minute", alert{NotifyPhone: "+14155550100", TextFirst: true}, "sms,email,slack"},
+		{"last minute without a phone", alert{TextFirst: true}, "email,slack"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			channels := []string{}
+			for _, n := range notifiersFor(test.alert) {
+				channels = append(channels, n.Channel())
+			}
+			if got := strings.Join(channels, ","); got != test.want {
+				t.Errorf("channels %s, want %s", got, test.want)
+			}
+		})
+	}
+}
+
+// smsAlert is an alert for n sites named by name.
+func smsAlert(n int, name func(i int) string) alert {
+	a := alert{
+		CampgroundID: "232447",
+		Arrival:      core.CivilDate{Year: 2027, Month: 7, Day: 14},
+		Departure:    core.CivilDate{Year: 2027, Month: 7, Day: 16},
+		SiteNames:    map[string]string{},
+	}
+	for i := 0; i < n; i++ {
+		site := fmt.Sprint(10000 + i)
+		a.Sites = append(a.Sites, site)
+		a.SiteNames[site] = name(i)
+	}
+	return a
+}
+
+// In one text, as many whole sites fit as the limit allows.
+func TestSMSMessages(t *testing.T) {
+	tests := []struct {
+		name  string
+		alert alert
+		// wantListed is how many sites the text names in full, or -1 for
+		// as many as fit.
+		wantListed int
+	}{
+		{"few sites", smsAlert(3, func(i int) string { return fmt.Sprintf("A%03d", i) }), 3},
+		{"many sites", smsAlert(500, func(i int) string { return fmt.Sprintf("A%03d", i) }), -1},
+		// Each name is 8 characters but 10 bytes, so a byte count would
+		// list fewer and could cut one in two.
+		{"multibyte names", smsAlert(500, func(i int) string { return fmt.Sprintf("Ñandú%03d", i) }), -1},
+		{"multibyte campground", func() alert {
+			a := smsAlert(10, func(i int) string { return fmt.Sprintf("A%03d", i) })
+			a.CampgroundName = strings.Repeat("É", 2000)
+			return a
+		}(), 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			bodies := smsMessages(test.alert, 1)
+			if len(bodies) != 1 {
+				t.Fatalf("got %d texts, want 1", len(bodies))
+			}
+			body := bodies[0]
+			if !utf8.ValidString(body) {
+				t.Fatalf("body is not valid UTF-8: %q", body)
+			}
+			if n := utf8.RuneCountInString(body); n > maxSMSLength {
+				t.Fatalf("body is %d characters, over %d", n, maxSMSLength)
+			}
+			listed := 0
+			for _, site := range test.alert.Sites {
+				if strings.Contains(body, test.alert.SiteNames[site]+",") || strings.Contains(body, test.alert.SiteNames[site]+" ") {
+					listed++
+				}
+			}
+			if test.wantListed >= 0 && listed != test.wantListed {
+				t.Errorf("lists %d sites, want %d", listed, test.wantListed)
+			}
+			if rest := len(test.alert.Sites) - listed; test.wantListed != 0 && rest > 0 {
+				if !strings.Contains(body, fmt.Sprintf(" and %d more", rest)) {
+					t.Errorf("lists %d sites but does not count the other %d: %q", listed, rest, body)
+				}
+				if n := utf8.RuneCountInString(body); n < maxSMSLength-30 {
+					t.Errorf("stopped at %d characters with sites left to list", n)
+				}
+			}
+		})
+	}
+}
+
+func TestSMSMessagesPrimary(t *testing.T) {
+	a := smsAlert(2, func(i int) string { return fmt.Sprintf("B%03d", i) })
+	a.Primary = a.Sites[1]
+	if body := smsMessages(a, defaultSMSParts)[0]; !strings.Contains(body, "book B001 first") {
+		t.Errorf("body %q does not name the site to book first", body)
+	}
+}
+
+// A long alert goes out as numbered texts, in order, up to SMS_MAX_PARTS.
+func TestTwilioNotifyParts(t *testing.T) {
+	t.Setenv("TWILIO_SID", "AC000")
+	t.Setenv("TWILIO_TOKEN", "token")
+	t.Setenv("TWILIO_FROM", "+14155550199")
+	tests := []struct {
+		name     string
+		maxParts string
+		sites    int
+		want     int
+	}{
+		{"short", "", 3, 1},
+		{"long", "", 500, 2},
+		{"long with three allowed", "3", 1000, 3},
+		{"unreadable setting", "many", 500, 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("SMS_MAX_PARTS", test.maxParts)
+			var bodies []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				r.ParseForm()
+				bodies = append(bodies, r.PostForm.Get("Body"))
+				w.WriteHeader(http.StatusCreated)
+			}))
+			defer server.Close()
+			n, ok := twilioFromEnv("+14155550100")
+			if !ok {
+				t.Fatal("twilioFromEnv found no Twilio settings")
+			}
+			n.baseURL = server.URL
+
+			a := smsAlert(test.sites, func(i int) string { return fmt.Sprintf("A%03d", i) })
+			if err := n.Notify(context.Background(), a, renderedAlert{}); err != nil {
+				t.Fatalf("Notify: %v", err)
+			}
+			if len(bodies) != test.want {
+				t.Fatalf("sent %d texts, want %d", len(bodies), test.want)
+			}
+			for i, body := range bodies {
+				if prefix := fmt.Sprintf("(%d/%d) ", i+1, test.want); test.want > 1 && !strings.HasPrefix(body, prefix) {
+					t.Errorf("text %d starts %.20q, want %q", i, body, prefix)
+				}
+			}
+		})
+	}
+}
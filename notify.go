@@ -0,0 +1,322 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sendgrid/rest"
+	"github.com/sendgrid/sendgrid-go"
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// alert is everything a notification channel needs to describe one result.
+type alert struct {
+	JobName      string
+	CampgroundID string
+	// CampgroundName is recreation.gov's name for the campground, where it
+	// could be looked up.
+	CampgroundName string
+	// BookingNotes sums up the campground's booking terms for the email;
+	// see bookingNotes.
+	BookingNotes string
+	// TypicallyGone is how long the campground's openings typically last
+	// before they are booked, or zero without enough history; see
+	// typicalTimeToBook.
+	TypicallyGone time.Duration
+	// Locale and TimeZone are the watch's, for formatting dates and times.
+	Locale   string
+	TimeZone string
+	Sites    []string
+	// Primary is the site to book first when the watch ranks its sites. It
+	// is also listed in Sites.
+	Primary string
+	// StayNights is set for flexible-departure watches and holds how many
+	// nights from Arrival each site can be booked for.
+	StayNights map[string]int
+	// Runs is set for flexible-window watches and holds every matching
+	// stay; it is summarised as a windows × sites matrix.
+	Runs []core.Run
+	// OpenRanges is set for watches accepting partial stays and holds each
+	// site's longest open run, which may be shorter than the stay.
+	OpenRanges map[string]core.Run
+	// UnknownCapacity marks sites kept by a MinCapacity watch without a
+	// known capacity.
+	UnknownCapacity map[string]bool
+	// UnknownAttributes marks sites kept by a RequiredAttributes watch whose
+	// details could not be read, and on any watch the sites recreation.gov
+	// has no details for. MissingAttributes counts the sites dropped for not
+	// listing a required attribute at all.
+	UnknownAttributes map[string]bool
+	MissingAttributes int
+	// Diff is set for watches tracking changes and compares the sites with
+	// the previous scan's.
+	Diff *AvailabilityDiff
+	// Held is the digest of findings held during quiet hours that this
+	// alert delivers.
+	Held      *heldDigest
+	Arrival   core.CivilDate
+	Departure core.CivilDate
+	Partial   *core.PartialResultError
+	Rehearsal bool
+	// ResultsPage names the hosted results page in PAGES_BUCKET, when one
+	// is published, and ResultsURL links to it once it is.
+	ResultsPage string
+	ResultsURL  string
+	// ScannedAt is when the availability behind the alert was fetched.
+	ScannedAt time.Time
+	// Closing is the end-of-watch summary when this alert ends the watch.
+	Closing *WatchSummary
+	// Groups holds one alert per campground with availability when the
+	// watch covers several; Sites is then labelled "campground: site".
+	// Failed maps campgrounds that could not be checked to the reason, and
+	// Paused those the operator control kept from being scraped.
+	Groups []alert
+	Failed map[string]string
+	Paused map[string]string
+	// Persistent is set for watches that keep running after alerting. They
+	// track what they last reported themselves, so the sent-marker dedupe
+	// is skipped: a site that is booked and then reopens alerts again.
+	Persistent bool
+	// Permits is set for permit watches and describes the division and date
+	// each of Sites stands for.
+	Permits map[string]core.PermitOpening
+	// Tours is set for tour watches and describes the slot each of Sites
+	// stands for.
+	Tours map[string]core.TourSlot
+	// MonitoredSite is set for site-monitor watches and is the campsite
+	// followed. Each of Sites is then a changed night, described in
+	// NightChanges, and ChangedNights counts every changed night, listed
+	// or not.
+	MonitoredSite string
+	NightChanges  map[string]nightChange
+	ChangedNights int
+	// SiteNames maps campsite IDs to recreation.gov's site name, such as
+	// A012, where known.
+	SiteNames map[string]string
+	// SiteTypes maps campsite IDs to their CampsiteType, where known.
+	SiteTypes map[string]string
+	// Remaining holds how many units are left every night at sites booked
+	// by the unit, such as group and overflow areas.
+	Remaining map[string]int
+	// ClusterNote is set for FindAdjacentSites watches that found no
+	// cluster, and says that Sites are single sites.
+	ClusterNote string
+	// Prices holds what the stay costs at each site whose price is known.
+	// It is nil when no site's price is known and the watch has no
+	// MaxNightlyPrice, so alerts say nothing of prices.
+	Prices map[string]core.StayPrice
+	// Requested is set for CampsiteIDs watches.
+	Requested *requestedSites
+	// Checked counts the campsites scanned, for metrics.
+	Checked int
+	// Class is how the campground looked for the stay, and LastUnreleased
+	// the latest night not yet open for booking. They are set for plain
+	// stays only.
+	Class          core.Classification
+	LastUnreleased core.CivilDate
+	// NoMatch is the per-site breakdown of a plain stay that found nothing,
+	// as logged, and is kept with a registered watch's last result.
+	NoMatch string
+	// Forecast is set for IncludeWeather watches and holds the forecast
+	// for each night of the stay it reaches.
+	Forecast []NightForecast
+	// Trimmed names the enrichment stages skipped for want of time before
+	// the run's deadline; see enrichBudget.
+	Trimmed []string
+	// Recipient is the watch's email recipient; nil means the deployment
+	// default. NotifyPhone, when set, is also texted.
+	Recipient   *mail.Email
+	NotifyPhone string
+	// TextFirst sends the text ahead of every other channel, for
+	// last-minute watches.
+	TextFirst bool
+	// WebhookURL, when set, receives a signed JSON copy of the alert.
+	WebhookURL string
+	// SlackWebhook is the watch contact's own Slack webhook, posted to as
+	// well as SLACK_WEBHOOK_URL.
+	SlackWebhook string
+	// ContactProblem says why the watch's contact could not be resolved,
+	// when the alert goes to the deployment's recipient instead.
+	ContactProblem string
+}
+
+// notifyAddress receives email notices when neither the watch nor
+// NOTIFY_EMAIL names a recipient.
+const notifyAddress = "sgrasu17@gmail.com"
+
+// defaultRecipient is the deployment's own recipient: NOTIFY_EMAIL, or
+// notifyAddress when that is unset.
+func defaultRecipient() *mail.Email {
+	if address := os.Getenv("NOTIFY_EMAIL"); address != "" {
+		return mail.NewEmail("", address)
+	}
+	return mail.NewEmail("Stefan", notifyAddress)
+}
+
+// sendAlert delivers a over every configured channel. A failure on one
+// channel does not stop the others; the returned error is a *MultiError.
+// Channels that were already sent the same results, by this watch or any
+// other, are skipped so editing or recreating a watch does not repeat an
+// alert; rehearsals always go out.
+func sendAlert(ctx context.Context, a alert) error {
+	notifiers := notifiersFor(a)
+	if linksResults(notifiers) {
+		page, err := newResultsPage()
+		if err != nil {
+			logger.Printf("job %s: naming results page: %v", a.JobName, err)
+		}
+		a.ResultsPage = page
+	}
+	subject, plain, htmlContent, err := renderEmail(a)
+	if err != nil {
+		return err
+	}
+	if a.ResultsPage != "" {
+		url, err := publishResultsPage(ctx, a.ResultsPage, subject, htmlContent)
+		if err != nil {
+			logger.Printf("job %s: publishing results page: %v", a.JobName, err)
+		}
+		a.ResultsURL = url
+	}
+	sent := &MultiError{}
+
+	var store *storage.Client
+	if os.Getenv("RESULTS_BUCKET") != "" || os.Getenv("ARCHIVE_BUCKET") != "" {
+		client, err := storageClient()
+		if err != nil {
+			logger.Println("alerts will not be deduplicated or archived:", err)
+		} else {
+			store = client
+		}
+	}
+	dedupe := store
+	if a.Rehearsal || a.Persistent {
+		dedupe = nil
+	}
+	rendered := renderedAlert{Subject: subject, Plain: plain, HTML: htmlContent}
+	for _, n := range notifiers {
+		channel := n.Channel()
+		recipient, label := n.Recipient()
+		if alreadySent(ctx, dedupe, a, recipient) {
+			logger.Printf("job %s: %s already has these results, skipping", a.JobName, channel)
+			archiveNotification(ctx, store, a, channel, label, subject, plain, "skipped: already sent")
+			continue
+		}
+		err := n.Notify(ctx, a, rendered)
+		sent.Add(err)
+		if err != nil {
+			archiveNotification(ctx, store, a, channel, label, subject, plain, "failed: "+err.Error())
+			continue
+		}
+		archiveNotification(ctx, store, a, channel, label, subject, plain, "sent")
+		if err := markSent(ctx, dedupe, a, recipient); err != nil {
+			logger.Println("recording sent alert:", err)
+		}
+	}
+	return sent.Err()
+}
+
+// partialStay describes a site's open run when it covers fewer nights than
+// the alert's stay, such as "3 of 5 nights, Jul 2–5"; it is empty for a
+// full stay or a site without a run.
+func partialStay(a alert, site string) string {
+	run, ok := a.OpenRanges[site]
+	nights, stay := core.NightsBetween(run.Start, run.End), core.NightsBetween(a.Arrival, a.Departure)
+	if !ok || nights == stay {
+		return ""
+	}
+	return fmt.Sprintf("%d of %d nights, %s", nights, stay, runDates([]core.Run{run}, site))
+}
+
+// runDates lists a site's runs as "Jul 14–16, Jul 21–23", or "" when it has
+// none.
+func runDates(runs []core.Run, site string) string {
+	dates := []string{}
+	for _, run := range runs {
+		if run.Site != site {
+			continue
+		}
+		end := run.End.Time().Format("2")
+		if run.End.Month != run.Start.Month {
+			end = run.End.Time().Format("Jan 2")
+		}
+		dates = append(dates, run.Start.Time().Format("Jan 2")+"–"+end)
+	}
+	return strings.Join(dates, ", ")
+}
+
+// renderGroupedEmail renders a multi-campground alert.
+func renderGroupedEmail(a alert, subject string, nights int) (string, string, string) {
+	sites := 0
+	for _, g := range a.Groups {
+		sites += len(g.Sites)
+	}
+	summary := fmt.Sprintf("Found %d available sites across %d campgrounds for %s to %s (%d nights).",
+		sites, len(a.Groups), a.day(a.Arrival), a.day(a.Departure), nights)
+	if a.Rehearsal {
+		subject = "[" + rehearsalLabel + "] " + subject
+		summary = "This is a rehearsal alert. " + rehearsalLabel + ". " + summary
+	}
+	groupLines, groupHTML := renderGroups(a)
+	lines := append([]string{summary, ""}, groupLines...)
+	htmlContent := "<p>" + html.EscapeString(summary) + "</p>" + groupHTML
+	if a.Closing != nil {
+		lines = append(lines, "", a.Closing.Text())
+		htmlContent += a.Closing.HTML()
+	}
+	return subject, strings.Join(lines, "\n"), htmlContent
+}
+
+// sendNotice emails the deployment's default recipient.
+func sendNotice(ctx context.Context, subject string, plainTextContent string, htmlContent string) error {
+	return sendNoticeTo(ctx, defaultRecipient(), subject, plainTextContent, htmlContent)
+}
+
+// sendNoticeTo emails one recipient: every notice, from alerts by email to
+// expiry, season and digest messages, goes through it. Tests replace it.
+var sendNoticeTo = emailNotice
+
+// emailNotice emails one recipient from FROM_EMAIL, or the deployment's own
+// sender when that is unset.
+func emailNotice(ctx context.Context, to *mail.Email, subject string, plainTextContent string, htmlContent string) error {
+	sender := os.Getenv("FROM_EMAIL")
+	if sender == "" {
+		sender = "stefan@stefangrasu.com"
+	}
+	from := mail.NewEmail(" Stefan", sender)
+	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
+	response, err := sendGrid(ctx, message)
+	if err != nil {
+		return fmt.Errorf("sending email: %v", err)
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("sending email to %s: SendGrid returned %d: %s", to.Address, response.StatusCode, response.Body)
+	}
+	return nil
+}
+
+// sendGridHost is where sendGrid sends mail; tests point it at a fake.
+var sendGridHost = "https://api.sendgrid.com"
+
+// sendGrid is sendgrid.Client.Send bound to ctx, which the client library
+// has no way to take.
+func sendGrid(ctx context.Context, message *mail.SGMailV3) (*rest.Response, error) {
+	request := sendgrid.GetRequest(os.Getenv("SENDGRID_API_KEY"), "/v3/mail/send", sendGridHost)
+	request.Method = rest.Post
+	request.Body = mail.GetRequestBody(message)
+	httpRequest, err := rest.BuildRequestObject(request)
+	if err != nil {
+		return nil, err
+	}
+	response, err := rest.DefaultClient.HTTPClient.Do(httpRequest.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return rest.BuildResponse(response)
+}
@@ -0,0 +1,108 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// fakeSendGrid answers every send with status and body, and records the
+// last request's authorization and mail.
+type fakeSendGrid struct {
+	status        int
+	body          string
+	authorization string
+	sent          struct {
+		From             struct{ Email string } `json:"from"`
+		Subject          string                 `json:"subject"`
+		Personalizations []struct {
+			To []struct{ Email string } `json:"to"`
+		} `json:"personalizations"`
+	}
+}
+
+func (f *fakeSendGrid) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || r.URL.Path != "/v3/mail/send" {
+		http.NotFound(w, r)
+		return
+	}
+	f.authorization = r.Header.Get("Authorization")
+	data, _ := ioutil.ReadAll(r.Body)
+	json.Unmarshal(data, &f.sent)
+	w.WriteHeader(f.status)
+	w.Write([]byte(f.body))
+}
+
+// useFakeSendGrid points sendGrid at a fake answering with status and
+// body, with SENDGRID_API_KEY and FROM_EMAIL set.
+func useFakeSendGrid(t *testing.T, status int, body string) *fakeSendGrid {
+	fake := &fakeSendGrid{status: status, body: body}
+	server := httptest.NewServer(fake)
+	t.Cleanup(server.Close)
+	host := sendGridHost
+	sendGridHost = server.URL
+	t.Cleanup(func() { sendGridHost = host })
+	t.Setenv("SENDGRID_API_KEY", "SG.test-key")
+	t.Setenv("FROM_EMAIL", "alerts@example.com")
+	return fake
+}
+
+func TestEmailNotice(t *testing.T) {
+	fake := useFakeSendGrid(t, http.StatusAccepted, "")
+
+	err := emailNotice(context.Background(), mail.NewEmail("", "me@example.com"), "Sites found", "plain", "<p>html</p>")
+	if err != nil {
+		t.Fatalf("emailNotice: %v", err)
+	}
+	if fake.authorization != "Bearer SG.test-key" {
+		t.Errorf("authorized with %q, want the SENDGRID_API_KEY", fake.authorization)
+	}
+	if fake.sent.From.Email != "alerts@example.com" || fake.sent.Subject != "Sites found" {
+		t.Errorf("sent %+v, want Sites found from FROM_EMAIL", fake.sent)
+	}
+	if p := fake.sent.Personalizations; len(p) != 1 || len(p[0].To) != 1 || p[0].To[0].Email != "me@example.com" {
+		t.Errorf("sent to %+v, want me@example.com", p)
+	}
+}
+
+// A response other than 2xx is an error naming the recipient, the status
+// and SendGrid's explanation.
+func TestEmailNoticeRejected(t *testing.T) {
+	useFakeSendGrid(t, http.StatusBadRequest, `{"errors":[{"message":"Does not contain a valid address.","field":"personalizations.0.to.0.email"}]}`)
+
+	err := emailNotice(context.Background(), mail.NewEmail("", "not-an-address"), "Sites found", "plain", "<p>html</p>")
+	if err == nil {
+		t.Fatal("emailNotice succeeded on a 400")
+	}
+	for _, want := range []string{"not-an-address", "SendGrid returned 400", "Does not contain a valid address."} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("emailNotice error %q does not contain %q", err, want)
+		}
+	}
+}
+
+// A watch's NotifyEmail is emailed in place of NOTIFY_EMAIL.
+func TestEmailNoticeRecipient(t *testing.T) {
+	fake := useFakeSendGrid(t, http.StatusAccepted, "")
+	t.Setenv("NOTIFY_EMAIL", "admin@example.com")
+	for _, test := range []struct {
+		watch MessageContent
+		want  string
+	}{
+		{MessageContent{NotifyEmail: "me@example.com", NotifyName: "Me"}, "me@example.com"},
+		{MessageContent{}, "admin@example.com"},
+	} {
+		if err := emailNotice(context.Background(), test.watch.recipient(), "Sites found", "plain", "<p>html</p>"); err != nil {
+			t.Fatalf("emailNotice: %v", err)
+		}
+		if p := fake.sent.Personalizations; len(p) != 1 || len(p[0].To) != 1 || p[0].To[0].Email != test.want {
+			t.Errorf("watch with NotifyEmail %q emailed %+v, want %s", test.watch.NotifyEmail, p, test.want)
+		}
+	}
+}
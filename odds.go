@@ -0,0 +1,170 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// The comparable nights of a stay are those on the same weekdays within
+// oddsSpreadDays of it, this year and in each of the oddsYears before (a
+// year back being 52 weeks, to keep the weekday). At least
+// minOddsSiteNights site-nights of history over minOddsNights nights are
+// needed for an estimate.
+const (
+	oddsSpreadDays    = 14
+	oddsYears         = 2
+	minOddsSiteNights = 20
+	minOddsNights     = 4
+)
+
+// ErrNoHistory is returned by EstimateOdds when there is no scrape history
+// to read.
+var ErrNoHistory = errors.New("no scrape history to read: set BQ_DATASET and BQ_TABLE")
+
+// OddsRating is the rough chance EstimateOdds gives a stay.
+type OddsRating string
+
+// The ratings, from most to least hopeful.
+const (
+	OddsHigh   OddsRating = "high"
+	OddsMedium OddsRating = "medium"
+	OddsLow    OddsRating = "low"
+)
+
+// Odds is how comparable nights at a campground went in past scrapes.
+type Odds struct {
+	CampgroundID string `json:"campground_id"`
+	// Enough is false when the history is too thin to rate the stay;
+	// Reason then says what is missing and Rating is empty.
+	Enough bool       `json:"enough"`
+	Reason string     `json:"reason,omitempty"`
+	Rating OddsRating `json:"rating,omitempty"`
+	// Nights counts the comparable past nights seen and SiteNights the
+	// campsites seen bookable or booked on them.
+	Nights     int `json:"nights"`
+	SiteNights int `json:"site_nights"`
+	// Occupancy is the share of SiteNights last seen reserved.
+	Occupancy float64 `json:"occupancy"`
+	// CancellationRate is the share of the site-nights seen reserved by
+	// one scrape that a later scrape saw available again. Site-nights
+	// scraped once say nothing of cancellations and are left out.
+	CancellationRate float64 `json:"cancellation_rate"`
+}
+
+// String sums o up, such as "Medium chance: comparable nights were 92%
+// booked (48 site-nights on 12 nights) and 25% of bookings seen were
+// cancelled."
+func (o Odds) String() string {
+	if !o.Enough {
+		return "Not enough history to estimate the odds: " + o.Reason + "."
+	}
+	rating := string(o.Rating)
+	return fmt.Sprintf("%s chance: comparable nights were %.0f%% booked (%d site-nights on %d nights) and %.0f%% of bookings seen were cancelled.",
+		strings.ToUpper(rating[:1])+rating[1:], o.Occupancy*100, o.SiteNights, o.Nights, o.CancellationRate*100)
+}
+
+// EstimateOdds rates the chance of finding a site at campgroundID from
+// arrival to departure from s.History, which must be a HistoryReader: how
+// booked comparable past nights ended up and how often their bookings
+// were cancelled. Only nights before today count, as later ones may still
+// change. With too little history it says so in the Odds rather than
+// guessing, and without any to read it returns ErrNoHistory.
+func (s *Scraper) EstimateOdds(ctx context.Context, campgroundID string, arrival time.Time, departure time.Time) (Odds, error) {
+	if s.History == nil {
+		return Odds{}, ErrNoHistory
+	}
+	reader, ok := s.History.Sink.(HistoryReader)
+	if !ok {
+		return Odds{}, ErrNoHistory
+	}
+	if !departure.After(arrival) {
+		return Odds{}, &ValidationError{Field: "Departure", Value: core.CivilDateOf(departure).String(), Reason: "must be after the arrival"}
+	}
+	weekdays := map[time.Weekday]bool{}
+	for night := arrival; night.Before(departure) && len(weekdays) < 7; night = night.AddDate(0, 0, 1) {
+		weekdays[night.Weekday()] = true
+	}
+	today := core.CivilDateOf(s.now()).String()
+	seen := map[string][]HistoryRow{}
+	for year := 0; year <= oddsYears; year++ {
+		shift := -364 * year
+		rows, err := reader.ReadHistory(ctx, campgroundID, arrival.AddDate(0, 0, shift-oddsSpreadDays), departure.AddDate(0, 0, shift+oddsSpreadDays))
+		if err != nil {
+			return Odds{}, fmt.Errorf("reading history for %s: %v", campgroundID, err)
+		}
+		for _, row := range rows {
+			night, err := core.ParseCivilDate(row.Night)
+			if err != nil || row.Night >= today || !weekdays[night.Time().Weekday()] {
+				continue
+			}
+			key := row.CampsiteID + "/" + row.Night
+			seen[key] = append(seen[key], row)
+		}
+	}
+	return oddsOf(campgroundID, seen), nil
+}
+
+// oddsOf rates the site-nights seen, each with every scrape of it.
+func oddsOf(campgroundID string, seen map[string][]HistoryRow) Odds {
+	odds := Odds{CampgroundID: campgroundID}
+	nights := map[string]bool{}
+	reserved, tracked, cancelled := 0, 0, 0
+	for _, rows := range seen {
+		sort.Slice(rows, func(i, j int) bool { return rows[i].ScrapedAt.Before(rows[j].ScrapedAt) })
+		last, wasReserved, reopened, scrapes := core.StatusUnknown, false, false, 0
+		for _, row := range rows {
+			status := core.ParseStatus(row.Status)
+			if status != core.StatusAvailable && status != core.StatusReserved {
+				continue
+			}
+			reopened = reopened || wasReserved && status == core.StatusAvailable
+			wasReserved = wasReserved || status == core.StatusReserved
+			last = status
+			scrapes++
+		}
+		if scrapes == 0 {
+			continue
+		}
+		nights[rows[0].Night] = true
+		odds.SiteNights++
+		if last == core.StatusReserved {
+			reserved++
+		}
+		if wasReserved && scrapes > 1 {
+			tracked++
+			if reopened {
+				cancelled++
+			}
+		}
+	}
+	odds.Nights = len(nights)
+	if odds.SiteNights < minOddsSiteNights || odds.Nights < minOddsNights {
+		odds.Reason = fmt.Sprintf("only %d site-nights on %d comparable nights were scraped, and at least %d on %d are needed", odds.SiteNights, odds.Nights, minOddsSiteNights, minOddsNights)
+		return odds
+	}
+	odds.Enough = true
+	odds.Occupancy = float64(reserved) / float64(odds.SiteNights)
+	if tracked > 0 {
+		odds.CancellationRate = float64(cancelled) / float64(tracked)
+	}
+	switch {
+	case odds.Occupancy < 0.75:
+		odds.Rating = OddsHigh
+	case odds.Occupancy >= 0.95 && odds.CancellationRate < 0.1:
+		odds.Rating = OddsLow
+	default:
+		odds.Rating = OddsMedium
+	}
+	return odds
+}
+
+// EstimateOdds is Scraper.EstimateOdds using DefaultScraper.
+func EstimateOdds(ctx context.Context, campgroundID string, arrival time.Time, departure time.Time) (Odds, error) {
+	return DefaultScraper.EstimateOdds(ctx, campgroundID, arrival, departure)
+}
@@ -0,0 +1,146 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// oddsHistory is scrapes of sites 1001 to 1005 on every night from first
+// to last, status giving each scrape's status in turn for a site and night.
+// The scrapes are a day apart, starting 30 days before the night.
+func oddsHistory(first string, last string, status func(site int, night time.Time, scrape int) string, scrapes int) []scraper.HistoryRow {
+	rows := []scraper.HistoryRow{}
+	start, _ := core.ParseCivilDate(first)
+	end, _ := core.ParseCivilDate(last)
+	for night := start.Time(); !night.After(end.Time()); night = night.AddDate(0, 0, 1) {
+		for site := 0; site < 5; site++ {
+			for scrape := 0; scrape < scrapes; scrape++ {
+				rows = append(rows, scraper.HistoryRow{
+					ScrapedAt:    night.AddDate(0, 0, scrape-30),
+					CampgroundID: "232447",
+					CampsiteID:   fmt.Sprintf("100%d", site+1),
+					Night:        core.CivilDateOf(night).String(),
+					Status:       status(site, night, scrape),
+				})
+			}
+		}
+	}
+	return rows
+}
+
+func TestEstimateOdds(t *testing.T) {
+	reserved := func(site int, night time.Time, scrape int) string { return "Reserved" }
+	tests := []struct {
+		name    string
+		history []scraper.HistoryRow
+		// noHistory leaves the Scraper without a History.
+		noHistory  bool
+		wantErr    error
+		wantRating scraper.OddsRating
+		// want is the start of the odds' summary.
+		want string
+	}{
+		{name: "no history store", noHistory: true, wantErr: scraper.ErrNoHistory},
+		{name: "empty", want: "Not enough history to estimate the odds: only 0 site-nights on 0 comparable nights"},
+		{name: "sparse", history: oddsHistory("2026-07-15", "2026-07-16", reserved, 1),
+			want: "Not enough history to estimate the odds: only 10 site-nights on 2 comparable nights"},
+		{name: "other weekdays", history: oddsHistory("2026-07-17", "2026-07-20", reserved, 1),
+			want: "Not enough history"},
+		{name: "future nights", history: oddsHistory("2027-06-02", "2027-07-31", reserved, 1),
+			want: "Not enough history"},
+		{name: "mostly free", wantRating: scraper.OddsHigh,
+			history: oddsHistory("2026-07-01", "2026-07-31", func(site int, night time.Time, scrape int) string {
+				if site < 2 {
+					return "Reserved"
+				}
+				return "Available"
+			}, 1),
+			want: "High chance: comparable nights were 40% booked (50 site-nights on 10 nights) and 0% of bookings seen were cancelled."},
+		{name: "booked solid", wantRating: scraper.OddsLow, history: oddsHistory("2026-07-01", "2026-07-31", reserved, 2),
+			want: "Low chance: comparable nights were 100% booked (50 site-nights on 10 nights) and 0% of bookings seen were cancelled."},
+		{name: "booked with cancellations", wantRating: scraper.OddsMedium,
+			history: oddsHistory("2025-07-01", "2025-07-31", func(site int, night time.Time, scrape int) string {
+				if site == 0 && scrape == 1 {
+					return "Available"
+				}
+				return "Reserved"
+			}, 3),
+			want: "Medium chance: comparable nights were 100% booked (50 site-nights on 10 nights) and 20% of bookings seen were cancelled."},
+		{name: "closed sites ignored", history: oddsHistory("2026-07-01", "2026-07-31", func(site int, night time.Time, scrape int) string { return "Not Reservable" }, 1),
+			want: "Not enough history"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := scraper.NewScraper(nil, core.RetryPolicy{})
+			s.Clock = fakerecgov.NewClock(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			if !test.noHistory {
+				sink := &scraper.MemorySink{}
+				sink.Insert(context.Background(), test.history)
+				s.History = &scraper.HistoryBatcher{Sink: sink}
+			}
+
+			odds, err := s.EstimateOdds(context.Background(), "232447", time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC), time.Date(2027, 7, 16, 0, 0, 0, 0, time.UTC))
+			if !errors.Is(err, test.wantErr) {
+				t.Fatalf("EstimateOdds error %v, want %v", err, test.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if odds.Rating != test.wantRating || odds.Enough != (test.wantRating != "") {
+				t.Errorf("rating %q, enough %v, want %q", odds.Rating, odds.Enough, test.wantRating)
+			}
+			if got := odds.String(); !strings.HasPrefix(got, test.want) {
+				t.Errorf("odds %q, want %q", got, test.want)
+			}
+		})
+	}
+}
+
+// CreateWatch with Confirm emails the recipient that the watch is set up,
+// saying whether there is history to estimate its odds from.
+func TestCreateWatchConfirm(t *testing.T) {
+	tests := []struct {
+		name    string
+		history bool
+		confirm bool
+		want    string
+	}{
+		{name: "no confirmation"},
+		{name: "no history", confirm: true, want: "There is no scrape history to estimate its odds from."},
+		{name: "thin history", confirm: true, history: true, want: "Not enough history to estimate the odds: only 0 site-nights"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(false))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			if test.history {
+				scraper.DefaultScraper.History = &scraper.HistoryBatcher{Sink: &scraper.MemorySink{}}
+			}
+			ctx := context.Background()
+
+			_, err := scraper.CreateWatch(ctx, testConfig, newWatch("2027-07-14", "2027-07-16"), "*/10 * * * *", scraper.CreateOptions{Confirm: test.confirm})
+			if err != nil {
+				t.Fatal(err)
+			}
+			notices := h.Notifier.Notices()
+			if test.want == "" {
+				if len(notices) != 0 {
+					t.Errorf("sent %+v, want nothing", notices)
+				}
+				return
+			}
+			if len(notices) != 1 || notices[0].To != "me@example.com" || !strings.Contains(notices[0].Plain, "is set up.") || !strings.Contains(notices[0].Plain, test.want) {
+				t.Fatalf("sent %+v, want one confirmation to me@example.com saying %q", notices, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package scraper_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// A pair watch alerts each pair of sites free for the whole stay as one
+// line: neighbours in a loop for AdjacentPairs, and the exact pairs asked
+// for in RequiredPairs. A site open alone is not alerted.
+func TestPairWatch(t *testing.T) {
+	nights := map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}
+	tests := []struct {
+		name     string
+		open     []int
+		adjacent bool
+		required []core.Pair
+		want     []string
+	}{
+		{name: "adjacent", open: []int{0, 1, 2}, adjacent: true, want: []string{"1001 + 1002"}},
+		{name: "adjacent, one booked", open: []int{0, 2}, adjacent: true},
+		{name: "required", open: []int{0, 1, 2}, required: []core.Pair{{"1003", "1001"}}, want: []string{"1001 + 1003"}},
+		{name: "required, one booked", open: []int{0, 1}, required: []core.Pair{{"1001", "1003"}}},
+		{name: "both", open: []int{0, 1, 2}, adjacent: true, required: []core.Pair{{"1002", "1001"}, {"1002", "1003"}},
+			want: []string{"1001 + 1002", "1002 + 1003"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fixture := e2eFixture(false)
+			for _, i := range test.open {
+				fixture.Campgrounds[0].Sites[i].Nights = nights
+			}
+			h := fakerecgov.Start(fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("pairs")
+			m.AdjacentPairs, m.RequiredPairs = test.adjacent, test.required
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			alerts := h.Notifier.Alerts()
+			if test.want == nil {
+				if len(alerts) != 0 {
+					t.Errorf("got alerts %+v, want none", alerts)
+				}
+				return
+			}
+			if len(alerts) != 1 {
+				t.Fatalf("got %d alerts, want 1", len(alerts))
+			}
+			got := []string{}
+			for _, site := range alerts[0].Sites {
+				got = append(got, site.ID)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("alerted %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+// A kept pair watch is not alerted again to a pair it already sent.
+func TestPairWatchRepeat(t *testing.T) {
+	fixture := e2eFixture(true)
+	fixture.Campgrounds[0].Sites[1].Nights = fixture.Campgrounds[0].Sites[0].Nights
+	h := fakerecgov.Start(fixture)
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := e2eWatch("pairs-repeat")
+	m.AdjacentPairs, m.KeepJob = true, true
+
+	for i := 0; i < 2; i++ {
+		if err := h.Run(context.Background(), m); err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+		h.Clock.Advance(time.Hour)
+	}
+	if alerts := h.Notifier.Alerts(); len(alerts) != 1 {
+		t.Errorf("got %d alerts, want the pair once", len(alerts))
+	}
+}
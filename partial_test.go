@@ -0,0 +1,68 @@
+package scraper_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// partialFixture opens e2eFixture's sites on the given nights, for a stay
+// of the 14th to the 17th: 1001 for its first night only, 1002 for all
+// three and 1003 for the last night and the departure night, which the stay
+// does not need.
+func partialFixture() fakerecgov.Fixture {
+	fixture := e2eFixture(false)
+	nights := [][]string{{"2027-07-14"}, {"2027-07-14", "2027-07-15", "2027-07-16"}, {"2027-07-16", "2027-07-17"}}
+	for i, open := range nights {
+		fixture.Campgrounds[0].Sites[i].Nights = map[string]string{}
+		for _, night := range open {
+			fixture.Campgrounds[0].Sites[i].Nights[night] = "Available"
+		}
+	}
+	return fixture
+}
+
+func TestPartialStays(t *testing.T) {
+	tests := []struct {
+		name      string
+		minNights int
+		priority  []string
+		// want is each site alerted with its open dates, empty for a full
+		// stay.
+		want [][3]string
+	}{
+		{name: "one night", minNights: 1, want: [][3]string{
+			{"1002", "", ""}, {"1001", "2027-07-14", "2027-07-15"}, {"1003", "2027-07-16", "2027-07-17"}}},
+		{name: "departure night not counted", minNights: 2, want: [][3]string{{"1002", "", ""}}},
+		{name: "full stays before priority", minNights: 1, priority: []string{"1003", "1001"}, want: [][3]string{
+			{"1002", "", ""}, {"1001", "2027-07-14", "2027-07-15"}, {"1003", "2027-07-16", "2027-07-17"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(partialFixture())
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("partial-" + test.name[:3])
+			m.Departure = "2027-07-17"
+			m.MinConsecutiveNights, m.Priority = test.minNights, test.priority
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			alerts := h.Notifier.Alerts()
+			if len(alerts) != 1 {
+				t.Fatalf("got %d alerts, want 1", len(alerts))
+			}
+			got := [][3]string{}
+			for _, site := range alerts[0].Sites {
+				got = append(got, [3]string{site.ID, site.OpenFrom, site.OpenUntil})
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("alerted %v, want %v", got, test.want)
+			}
+		})
+	}
+}
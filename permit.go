@@ -0,0 +1,134 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// Watch kinds, as given in MessageContent.Kind.
+const (
+	kindCampground       = "campground"
+	kindPermit           = "permit"
+	kindSiteMonitor      = "site-monitor"
+	kindTour             = "tour"
+	kindReservationGuard = "reservation-guard"
+)
+
+// permitURL is the recreation.gov booking page for a permit ID.
+const permitURL = "https://www.recreation.gov/permits/"
+
+// isPermit reports whether m watches a permit rather than a campground.
+func (m MessageContent) isPermit() bool {
+	return m.Kind == kindPermit
+}
+
+// validateKind rejects an unknown Kind, campground-only options on a
+// permit, site-monitor, tour or reservation-guard watch, and tour options on
+// any other. A site monitor or reservation guard names exactly one campsite.
+func (m MessageContent) validateKind() error {
+	if err := m.validateTour(); err != nil {
+		return err
+	}
+	switch m.Kind {
+	case "", kindCampground:
+		return nil
+	case kindPermit, kindSiteMonitor, kindTour, kindReservationGuard:
+	default:
+		return &ValidationError{Field: "Kind", Value: m.Kind, Reason: `must be "campground", "permit", "site-monitor", "tour" or "reservation-guard"`}
+	}
+	campgroundOnly := []struct {
+		field string
+		set   bool
+	}{
+		{"CampgroundName", m.CampgroundName != ""},
+		{"Campgrounds", len(m.Campgrounds) > 0},
+		{"Nights", m.Nights > 0},
+		{"MaxNights", m.MaxNights > 0},
+		{"MinConsecutiveNights", m.MinConsecutiveNights > 0},
+		{"Priority", len(m.Priority) > 0},
+		{"AdjacentPairs", m.AdjacentPairs || len(m.RequiredPairs) > 0},
+		{"FindAdjacentSites", m.FindAdjacentSites},
+		{"SiteTypes", len(m.SiteTypes) > 0 || len(m.ExcludeTypes) > 0},
+		{"CampsiteIDs", len(m.CampsiteIDs) > 0 && !m.isSiteMonitor() && !m.isReservationGuard()},
+		{"RequiredAttributes", len(m.RequiredAttributes) > 0},
+		{"MaxNightlyPrice", m.MaxNightlyPrice > 0},
+		{"IncludeWeather", m.IncludeWeather},
+	}
+	if m.isTour() {
+		campgroundOnly = append(campgroundOnly, struct {
+			field string
+			set   bool
+		}{"MinCapacity", m.MinCapacity > 0})
+	}
+	if m.isSiteMonitor() || m.isReservationGuard() {
+		if len(m.CampsiteIDs) != 1 {
+			return &ValidationError{Field: "CampsiteIDs", Reason: fmt.Sprintf("must name exactly one campsite for %s watches", m.Kind)}
+		}
+		campgroundOnly = append(campgroundOnly, []struct {
+			field string
+			set   bool
+		}{
+			{"MinCapacity", m.MinCapacity > 0},
+			{"Cutoff", m.Cutoff != ""},
+			{"KeepJob", m.KeepJob},
+			{"TrackChanges", m.TrackChanges || m.OnlyNewlyAvailable},
+			{"ExpireAfter", m.ExpireAfter != ""},
+		}...)
+	}
+	for _, option := range campgroundOnly {
+		if option.set {
+			return &ValidationError{Field: option.field, Reason: fmt.Sprintf("is not supported for %s watches", m.Kind)}
+		}
+	}
+	return nil
+}
+
+// permitLabel is how an opening is listed in an alert's Sites.
+func permitLabel(o core.PermitOpening) string {
+	return fmt.Sprintf("division %s on %s", o.Division, o.Date.Time().Format("Mon Jan 2"))
+}
+
+// matchPermit scrapes the permit in m and builds the alert it would send,
+// without any side effects, like matchWatch. Each of the alert's Sites is a
+// division and entry date with at least MinCapacity slots left, described
+// in Permits.
+func (s *Scraper) matchPermit(ctx context.Context, m MessageContent, rehearsal bool) (alert, error) {
+	arrival, departure := stayDate(m.Arrival), stayDate(m.Departure)
+	a := alert{
+		JobName:      m.Name,
+		Recipient:    m.recipient(),
+		NotifyPhone:  m.NotifyPhone,
+		SlackWebhook: m.slackWebhook,
+		WebhookURL:   m.WebhookURL,
+		CampgroundID: m.Campground,
+		Arrival:      arrival,
+		Departure:    departure,
+		Rehearsal:    rehearsal,
+		ScannedAt:    s.now().UTC(),
+		Permits:      map[string]core.PermitOpening{},
+	}
+	openings, err := core.ScrapePermit(ctx, s.Provider(), m.Campground, arrival, departure, m.MinCapacity)
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return a, err
+	}
+	if err != nil && len(openings) > 0 {
+		logger.Printf("job %s: permit scan stopped early, reporting %d openings found so far: %v", m.Name, len(openings), err)
+		err = nil
+	}
+	available := []string{}
+	for _, opening := range openings {
+		label := permitLabel(opening)
+		a.Permits[label] = opening
+		available = append(available, label)
+	}
+	if rehearsal {
+		available = append([]string{rehearsalLabel}, available...)
+	}
+	if len(available) > 0 {
+		a.Sites = available
+	}
+	return a, err
+}
@@ -0,0 +1,76 @@
+package scraper_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+func permitFixture() fakerecgov.Fixture {
+	return fakerecgov.Fixture{Permits: []fakerecgov.Permit{{ID: "233260", Divisions: []fakerecgov.PermitDivision{
+		{ID: "166", Total: 20, Remaining: map[string]int{"2027-07-14": 3, "2027-07-15": 0, "2027-08-01": 2}},
+		{ID: "167", Total: 10, Remaining: map[string]int{"2027-07-14": 1, "2027-07-31": 6}},
+	}}}}
+}
+
+// A permit watch is alerted to each division and date with enough slots
+// left for its party, across the months its dates span, and its job is
+// then deleted.
+func TestPermitWatch(t *testing.T) {
+	h := fakerecgov.Start(permitFixture())
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := scraper.MessageContent{Name: fakerecgov.JobName("permit"), Kind: "permit", Campground: "233260",
+		Arrival: "2027-07-14", Departure: "2027-08-02", MinCapacity: 2}
+	schedule(h, m.Name, nil)
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []string{
+		"/api/permits/233260/availability/month?start_date=2027-07-01T00:00:00Z",
+		"/api/permits/233260/availability/month?start_date=2027-08-01T00:00:00Z",
+	}
+	if got := h.Server.Requests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests %v, want %v", got, want)
+	}
+	alerts := h.Notifier.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	sites := []string{}
+	for _, site := range alerts[0].Sites {
+		sites = append(sites, site.ID)
+	}
+	if want := []string{"division 166 on Wed Jul 14", "division 167 on Sat Jul 31", "division 166 on Sun Aug 1"}; !reflect.DeepEqual(sites, want) {
+		t.Errorf("alerted %v, want %v", sites, want)
+	}
+	if deleted := h.Scheduler.Deleted(); len(deleted) != 1 || h.Scheduler.Job(m.Name) != nil {
+		t.Errorf("deleted %v, want the watch's job", deleted)
+	}
+}
+
+// With no division holding enough slots the watch sends nothing and keeps
+// its job.
+func TestPermitWatchFull(t *testing.T) {
+	h := fakerecgov.Start(permitFixture())
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := scraper.MessageContent{Name: fakerecgov.JobName("permit-full"), Kind: "permit", Campground: "233260",
+		Arrival: "2027-07-14", Departure: "2027-07-16", MinCapacity: 4}
+	schedule(h, m.Name, nil)
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if alerts := h.Notifier.Alerts(); len(alerts) != 0 {
+		t.Errorf("got alerts %+v, want none", alerts)
+	}
+	if deleted := h.Scheduler.Deleted(); len(deleted) != 0 {
+		t.Errorf("deleted %v, want none", deleted)
+	}
+}
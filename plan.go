@@ -0,0 +1,141 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// monthPrior scores the months of a flexible window by the share of
+// campgroundID's site-nights in each that s.History saw available, over
+// the window and the year before it, so the months most often open are
+// fetched first. It is nil, leaving the months in order, without history
+// to read; a failure to read it is logged and treated the same.
+func (s *Scraper) monthPrior(ctx context.Context, jobName string, campgroundID string, start core.CivilDate, end core.CivilDate) core.MonthPrior {
+	if s.History == nil {
+		return nil
+	}
+	reader, ok := s.History.Sink.(HistoryReader)
+	if !ok {
+		return nil
+	}
+	rows, err := reader.ReadHistory(ctx, campgroundID, start.Time().AddDate(-1, 0, 0), end.Time())
+	if err != nil {
+		logger.Printf("job %s: reading history to plan months: %v", jobName, err)
+		return nil
+	}
+	available, seen := map[time.Month]int{}, map[time.Month]int{}
+	for _, row := range rows {
+		night, err := core.ParseCivilDate(row.Night)
+		if err != nil {
+			continue
+		}
+		switch core.ParseStatus(row.Status) {
+		case core.StatusAvailable:
+			available[night.Month]++
+			seen[night.Month]++
+		case core.StatusReserved:
+			seen[night.Month]++
+		}
+	}
+	if len(seen) == 0 {
+		return nil
+	}
+	prior := core.MonthPrior{}
+	for month, n := range seen {
+		prior[month] = float64(available[month]) / float64(n)
+	}
+	return prior
+}
+
+// logFetchPlan logs the months a flexible window's run fetched, in the
+// order it fetched them, how many of the window's it needed and those it
+// left out as not yet released.
+func logFetchPlan(jobName string, plan core.FetchPlan) {
+	logger.Printf("job %s: fetched %d of %d months, planned %s", jobName, plan.Fetched, len(plan.Order), monthList(plan.Order))
+	if len(plan.Skipped) > 0 {
+		logger.Printf("job %s: not fetching %s, as nights from %s are not yet released", jobName, monthList(plan.Skipped), plan.Horizon.Format("2006-01"))
+	}
+}
+
+func monthList(months []time.Time) string {
+	labels := []string{}
+	for _, month := range months {
+		labels = append(labels, month.Format("2006-01"))
+	}
+	return strings.Join(labels, ", ")
+}
+
+// horizonTTL is how long a campground's release horizon is kept. Booking
+// windows roll forward daily, so a month not yet released today may have
+// nights released tomorrow.
+const horizonTTL = 24 * time.Hour
+
+// horizonCache keeps, per campground, the first month ScrapeRunsPlanned
+// found not yet released, so later runs skip it without asking. A nil
+// cache keeps nothing.
+type horizonCache struct {
+	mu      sync.Mutex
+	entries map[string]horizonEntry
+}
+
+type horizonEntry struct {
+	month   time.Time
+	expires time.Time
+}
+
+// get returns campgroundID's horizon, or zero when none is kept or it has
+// expired by now.
+func (c *horizonCache) get(campgroundID string, now time.Time) time.Time {
+	if c == nil {
+		return time.Time{}
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry, ok := c.entries[campgroundID]
+	if !ok || !now.Before(entry.expires) {
+		return time.Time{}
+	}
+	return entry.month
+}
+
+// set keeps month as campgroundID's horizon for horizonTTL from now; a
+// zero month forgets it.
+func (c *horizonCache) set(campgroundID string, month time.Time, now time.Time) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = map[string]horizonEntry{}
+	}
+	if month.IsZero() {
+		delete(c.entries, campgroundID)
+		return
+	}
+	c.entries[campgroundID] = horizonEntry{month: month, expires: now.Add(horizonTTL)}
+}
+
+// noticeBeyondHorizon tells the watch's recipient, once per watch, that
+// its window runs past horizon, the first month its campground has not
+// released, and that those months are not checked until they are.
+func noticeBeyondHorizon(ctx context.Context, m MessageContent, horizon time.Time) {
+	end := stayDate(m.WindowEnd)
+	claimed, err := idempotencyStore.Claim(ctx, "horizon/"+m.Name, end.Time().Sub(DefaultScraper.now())+24*time.Hour)
+	if err == nil && !claimed {
+		return
+	}
+	subject := fmt.Sprintf("Nights from %s are not bookable yet", horizon.Format("January 2006"))
+	body := fmt.Sprintf("Watch %s looks for stays until %s, but campground %s has not released nights from %s on. "+
+		"Those months are checked once recreation.gov releases them; the rest of the window is checked as usual.",
+		m.Name, end.Time().Format("Jan 2, 2006"), m.Campground, horizon.Format("January 2006"))
+	if err := sendNoticeTo(ctx, m.recipient(), subject, body, "<p>"+html.EscapeString(body)+"</p>"); err != nil {
+		logger.Printf("job %s: sending the release horizon notice: %v", m.Name, err)
+	}
+}
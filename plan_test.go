@@ -0,0 +1,112 @@
+package scraper_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// A flexible window's months are fetched most open first by the
+// campground's history, and in order without any; stopping at the first
+// match then saves the rest.
+func TestFlexibleWindowMonthOrder(t *testing.T) {
+	// Last August was mostly open and last July fully booked.
+	history := []scraper.HistoryRow{}
+	for day := 1; day <= 10; day++ {
+		status := "Available"
+		if day > 8 {
+			status = "Reserved"
+		}
+		history = append(history,
+			scraper.HistoryRow{CampgroundID: "232447", CampsiteID: "1001", Night: time.Date(2026, 8, day, 0, 0, 0, 0, time.UTC).Format("2006-01-02"), Status: status},
+			scraper.HistoryRow{CampgroundID: "232447", CampsiteID: "1001", Night: time.Date(2026, 7, day, 0, 0, 0, 0, time.UTC).Format("2006-01-02"), Status: "Reserved"})
+	}
+	tests := []struct {
+		name        string
+		history     bool
+		stopAtFirst bool
+		wantMonths  string
+	}{
+		{name: "no history", wantMonths: "2027-07,2027-08,2027-09"},
+		{name: "history", history: true, wantMonths: "2027-08,2027-07,2027-09"},
+		{name: "stop at first without history", stopAtFirst: true, wantMonths: "2027-07,2027-08"},
+		{name: "stop at first", history: true, stopAtFirst: true, wantMonths: "2027-08"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fixture := e2eFixture(false)
+			fixture.Campgrounds[0].Sites[0].Nights = map[string]string{"2027-08-20": "Available", "2027-08-21": "Available"}
+			h := fakerecgov.Start(fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			sink := &scraper.MemorySink{}
+			if test.history {
+				sink.Insert(context.Background(), history)
+			}
+			scraper.DefaultScraper.History = &scraper.HistoryBatcher{Sink: sink}
+			m := scraper.MessageContent{Name: fakerecgov.JobName("flexible-" + strings.Replace(test.name, " ", "-", -1)), Campground: "232447",
+				Nights: 2, WindowStart: "2027-07-01", WindowEnd: "2027-10-01", StopAtFirstMatch: test.stopAtFirst}
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := strings.Join(h.Server.MonthsFetched("232447"), ","); got != test.wantMonths {
+				t.Errorf("fetched months %s, want %s", got, test.wantMonths)
+			}
+			if alerts := h.Notifier.Alerts(); len(alerts) != 1 || len(alerts[0].Sites) != 1 {
+				t.Errorf("got alerts %+v, want one for the August run", alerts)
+			}
+		})
+	}
+}
+
+// A window running into months not yet released fetches them only until
+// one comes back all unreleased, and for a day after skips them; the
+// watch's recipient is told once.
+func TestFlexibleWindowHorizon(t *testing.T) {
+	fixture := e2eFixture(false)
+	for i := range fixture.Campgrounds[0].Sites {
+		site := &fixture.Campgrounds[0].Sites[i]
+		site.Nights = map[string]string{}
+		for night := time.Date(2027, 9, 1, 0, 0, 0, 0, time.UTC); night.Month() == time.September; night = night.AddDate(0, 0, 1) {
+			site.Nights[night.Format("2006-01-02")] = "NYR"
+		}
+	}
+	fixture.Campgrounds[0].Sites[0].Nights["2027-08-20"] = "Available"
+	fixture.Campgrounds[0].Sites[0].Nights["2027-08-21"] = "Available"
+	h := fakerecgov.Start(fixture)
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := scraper.MessageContent{Name: fakerecgov.JobName("flexible-horizon"), Campground: "232447",
+		Nights: 2, WindowStart: "2027-07-01", WindowEnd: "2027-10-01", KeepJob: true}
+
+	runs := []struct {
+		after      time.Duration
+		wantMonths string
+	}{
+		{0, "2027-07,2027-08,2027-09"},
+		{time.Hour, "2027-07,2027-08"},
+		{25 * time.Hour, "2027-07,2027-08,2027-09"},
+	}
+	for i, run := range runs {
+		h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC).Add(run.after))
+		before := len(h.Server.MonthsFetched("232447"))
+		if err := h.Run(context.Background(), m); err != nil {
+			t.Fatalf("run %d: %v", i, err)
+		}
+		if got := strings.Join(h.Server.MonthsFetched("232447")[before:], ","); got != run.wantMonths {
+			t.Errorf("run %d fetched months %s, want %s", i, got, run.wantMonths)
+		}
+	}
+	notices := h.Notifier.Notices()
+	if len(notices) != 1 || !strings.Contains(notices[0].Subject, "September 2027") {
+		t.Errorf("got notices %+v, want one about September", notices)
+	}
+	if alerts := h.Notifier.Alerts(); len(alerts) != 1 {
+		t.Errorf("got %d alerts, want the August run once", len(alerts))
+	}
+}
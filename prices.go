@@ -0,0 +1,124 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// StayPrices prices the stay at each site from its campsite details, or
+// the campground's fees for sites that list none. Sites whose price is
+// unknown, because the details do not list a fee for every night or could
+// not be fetched, are left out.
+func (s *Scraper) StayPrices(ctx context.Context, campgroundID string, sites []string, arrival time.Time, departure time.Time) map[string]core.StayPrice {
+	stays := map[string]core.Run{}
+	for _, site := range sites {
+		stays[site] = core.Run{Site: site, Start: core.CivilDateOf(arrival), End: core.CivilDateOf(departure)}
+	}
+	return s.priceStays(ctx, "check", campgroundID, sites, stays, nil)
+}
+
+// StayPrices is Scraper.StayPrices using DefaultScraper.
+func StayPrices(ctx context.Context, campgroundID string, sites []string, arrival time.Time, departure time.Time) map[string]core.StayPrice {
+	return DefaultScraper.StayPrices(ctx, campgroundID, sites, arrival, departure)
+}
+
+// priceStays prices each site's stay in stays, but for those in unlisted.
+// The campground's fees are looked up only when a site lists none of its
+// own.
+func (s *Scraper) priceStays(ctx context.Context, jobName string, campgroundID string, sites []string, stays map[string]core.Run, unlisted map[string]bool) map[string]core.StayPrice {
+	details, errs := s.campsiteDetails(ctx, sites, unlisted)
+	prices := map[string]core.StayPrice{}
+	var campground *core.Fees
+	for i, site := range sites {
+		if errs[i] != nil {
+			logger.Printf("job %s: price of site %s unknown: %v", jobName, site, errs[i])
+			continue
+		}
+		fees := details[i].Fees
+		if !fees.Known() {
+			if campground == nil {
+				f, err := s.facility(ctx, campgroundID)
+				if err != nil {
+					logger.Printf("job %s: looking up campground fees: %v", jobName, err)
+				}
+				campground = &f.Fees
+			}
+			fees = *campground
+		}
+		stay := stays[site]
+		if price, ok := fees.PriceStay(stay.Start, stay.End); ok {
+			prices[site] = price
+		}
+	}
+	return prices
+}
+
+// siteStays is the stay to price at each site: the longest stay of a
+// flexible departure, the open dates of a partial match, and otherwise the
+// stay asked for.
+func siteStays(a alert, sites []string) map[string]core.Run {
+	stays := map[string]core.Run{}
+	for _, site := range sites {
+		stay := core.Run{Site: site, Start: a.Arrival, End: a.Departure}
+		if n, ok := a.StayNights[site]; ok {
+			stay.End = a.Arrival.AddDays(n)
+		}
+		if run, ok := a.OpenRanges[site]; ok && partialStay(a, site) != "" {
+			stay.Start, stay.End = run.Start, run.End
+		}
+		stays[site] = stay
+	}
+	return stays
+}
+
+// filterByPrice drops the sites with a known price whose dearest night costs
+// more than maxNightly cents. Sites whose price is unknown are kept.
+func filterByPrice(sites []string, prices map[string]core.StayPrice, maxNightly int) []string {
+	kept := []string{}
+	for _, site := range sites {
+		if price, ok := prices[site]; ok && price.MaxNightly > maxNightly {
+			continue
+		}
+		kept = append(kept, site)
+	}
+	return kept
+}
+
+// cheapestFirst orders sites by the total price of their stay, those whose
+// price is unknown last, otherwise keeping their order.
+func cheapestFirst(sites []string, prices map[string]core.StayPrice) []string {
+	sorted := append([]string{}, sites...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		pi, iKnown := prices[sorted[i]]
+		pj, jKnown := prices[sorted[j]]
+		if iKnown != jKnown {
+			return iKnown
+		}
+		return iKnown && pi.Total < pj.Total
+	})
+	return sorted
+}
+
+// priceLabel describes what the stay costs at site, such as "$70.00 total,
+// $35.00/night", "$65.00 total, up to $35.00/night" when the fee varies
+// between nights, or "price unknown". It is empty when a is not priced.
+func (a alert) priceLabel(site string) string {
+	if a.Prices == nil {
+		return ""
+	}
+	price, ok := a.Prices[site]
+	if !ok {
+		return "price unknown"
+	}
+	if price.Nights == 1 {
+		return core.FormatPrice(price.Total)
+	}
+	if price.MaxNightly*price.Nights != price.Total {
+		return fmt.Sprintf("%s total, up to %s/night", core.FormatPrice(price.Total), core.FormatPrice(price.MaxNightly))
+	}
+	return fmt.Sprintf("%s total, %s/night", core.FormatPrice(price.Total), core.FormatPrice(price.Nightly()))
+}
@@ -0,0 +1,87 @@
+package scraper_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// A priced watch without a priority lists its sites cheapest first, those
+// with no fee listed anywhere last, rather than by ID.
+func TestPricedSitesCheapestFirst(t *testing.T) {
+	open := map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}
+	h := fakerecgov.Start(fakerecgov.Fixture{Campgrounds: []fakerecgov.Campground{{
+		ID:   "232449",
+		Name: "Wawona",
+		Sites: []fakerecgov.Site{
+			{ID: "2101", Site: "A01", Loop: "A", Type: "STANDARD NONELECTRIC", NightlyFee: 30, Nights: open},
+			{ID: "2102", Site: "A02", Loop: "A", Type: "STANDARD NONELECTRIC", Nights: open},
+			{ID: "2103", Site: "A03", Loop: "A", Type: "STANDARD NONELECTRIC", NightlyFee: 20, Nights: open},
+		},
+	}}})
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := e2eWatch("prices-cheapest-first")
+	m.Campground = "232449"
+	m.MaxNightlyPrice = 50
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	alerts := h.Notifier.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	ids, totals, unknown := []string{}, []float64{}, []bool{}
+	for _, site := range alerts[0].Sites {
+		ids = append(ids, site.ID)
+		totals = append(totals, site.TotalPrice)
+		unknown = append(unknown, site.PriceUnknown)
+	}
+	if want := []string{"2103", "2101", "2102"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("sites %v, want %v", ids, want)
+	}
+	if want := []float64{40, 60, 0}; !reflect.DeepEqual(totals, want) {
+		t.Errorf("totals %v, want %v", totals, want)
+	}
+	if want := []bool{false, false, true}; !reflect.DeepEqual(unknown, want) {
+		t.Errorf("price unknown %v, want %v", unknown, want)
+	}
+}
+
+// A priority still puts its sites first, ahead of cheaper ones.
+func TestPricedSitesPriority(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	h.Server.SetFixture(allOpen(e2eFixture(true)))
+	m := e2eWatch("prices-priority")
+	m.MaxNightlyPrice = 50
+	m.Priority = []string{"1003"}
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	alerts := h.Notifier.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	ids := []string{}
+	for _, site := range alerts[0].Sites {
+		ids = append(ids, site.ID)
+	}
+	if want := []string{"1003", "1001", "1002"}; !reflect.DeepEqual(ids, want) {
+		t.Errorf("sites %v, want %v", ids, want)
+	}
+}
+
+// allOpen opens every site of fixture on e2eWatch's nights.
+func allOpen(fixture fakerecgov.Fixture) fakerecgov.Fixture {
+	for i := range fixture.Campgrounds[0].Sites {
+		fixture.Campgrounds[0].Sites[i].Nights = map[string]string{"2027-07-14": "Available", "2027-07-15": "Available"}
+	}
+	return fixture
+}
@@ -0,0 +1,50 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// NotifyByEmail is the simplest way to use the package: it scrapes the
+// campground once for every night from arrival up to departure and, if any
+// site is free, emails the standard alert to toEmail. It needs only
+// SENDGRID_API_KEY and FROM_EMAIL in the environment.
+//
+// The matching sites are returned whether or not an email was sent. As with
+// core.Scrape, a *core.PartialResultError comes back alongside the sites
+// that were found, and those sites are still emailed.
+//
+//	sites, err := scraper.NotifyByEmail(ctx, "232447", arrival, departure, "me@example.com")
+func NotifyByEmail(ctx context.Context, campgroundID string, arrival time.Time, departure time.Time, toEmail string) ([]string, error) {
+	if os.Getenv("SENDGRID_API_KEY") == "" || os.Getenv("FROM_EMAIL") == "" {
+		return nil, errors.New("NotifyByEmail needs SENDGRID_API_KEY and FROM_EMAIL set")
+	}
+	m := MessageContent{
+		Name:       "quick-" + campgroundID,
+		Campground: campgroundID,
+		Arrival:    arrival.Format(layoutISO),
+		Departure:  departure.Format(layoutISO),
+	}
+	a, err := DefaultScraper.matchWatch(ctx, DefaultScraper.availability(), m, false)
+	var partial *core.PartialResultError
+	if err != nil && !errors.As(err, &partial) {
+		return nil, err
+	}
+	if len(a.Sites) == 0 {
+		return nil, err
+	}
+	subject, plain, htmlContent, renderErr := renderEmail(a)
+	if renderErr != nil {
+		return a.Sites, renderErr
+	}
+	if sendErr := sendNoticeTo(ctx, mail.NewEmail("", toEmail), subject, plain, htmlContent); sendErr != nil {
+		return a.Sites, fmt.Errorf("found %d sites but could not email them: %v", len(a.Sites), sendErr)
+	}
+	return a.Sites, err
+}
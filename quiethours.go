@@ -0,0 +1,246 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// deliveryPrefix is where watches with quiet hours or a daily cap keep
+// their deliveryState in RESULTS_BUCKET.
+const deliveryPrefix = "delivery/"
+
+// heldDigest accumulates what a watch found during its quiet hours, to be
+// sent as one alert once they end.
+type heldDigest struct {
+	Runs  int       `json:"runs"`
+	First time.Time `json:"first"`
+	Last  time.Time `json:"last"`
+	// Sites is every site any held run found.
+	Sites []string `json:"sites"`
+}
+
+// deliveryState is a watch's alerts sent on its local Day, and any digest
+// held during quiet hours.
+type deliveryState struct {
+	Day  string      `json:"day"`
+	Sent int         `json:"sent"`
+	Held *heldDigest `json:"held,omitempty"`
+}
+
+// DeliveryStore keeps the per-watch state behind quiet hours and the daily
+// alert cap.
+type DeliveryStore interface {
+	DeliveryState(ctx context.Context, job string) (deliveryState, error)
+	SetDeliveryState(ctx context.Context, job string, state deliveryState) error
+	// DeleteDeliveryState forgets job, once its watch is deleted.
+	DeleteDeliveryState(ctx context.Context, job string) error
+}
+
+// limitsDelivery reports whether the watch has quiet hours or a daily cap.
+func (m MessageContent) limitsDelivery() bool {
+	return m.QuietHoursStart != "" || m.MaxNotificationsPerDay > 0
+}
+
+// location is the zone of the watch's quiet hours and daily cap: TimeZone,
+// else the TZ environment variable, else UTC.
+func (m MessageContent) location() *time.Location {
+	for _, zone := range []string{m.TimeZone, os.Getenv("TZ")} {
+		if zone == "" {
+			continue
+		}
+		loc, err := time.LoadLocation(zone)
+		if err == nil {
+			return loc
+		}
+		logger.Printf("job %s: unknown time zone %q", m.Name, zone)
+	}
+	return time.UTC
+}
+
+// gateDelivery decides whether a's alert goes out now. During quiet hours a
+// finding is added to the held digest and held is set; finding nothing is
+// left to the caller, as outside them. Outside them a held digest is folded
+// into a, whose sites are only those this run found: a held site the latest
+// scan no longer shows has been booked, so when none remain the digest is
+// dropped rather than alerted. Once the watch has sent MaxNotificationsPerDay alerts today, capped is
+// set. The returned state must be passed to recordDelivery.
+func gateDelivery(ctx context.Context, m MessageContent, a *alert) (state deliveryState, held bool, capped bool, err error) {
+	state, err = deliveryStore.DeliveryState(ctx, m.Name)
+	if err != nil {
+		return state, false, false, fmt.Errorf("job %s: reading delivery state: %w", m.Name, err)
+	}
+	now := DefaultScraper.now().In(m.location())
+	if today := core.CivilDateOf(now).String(); state.Day != today {
+		state.Day, state.Sent = today, 0
+	}
+	quiet := false
+	if m.QuietHoursStart != "" {
+		window := ScanWindow{Start: m.QuietHoursStart, End: m.QuietHoursEnd}
+		if quiet, err = window.contains(now, now.Location()); err != nil {
+			logger.Printf("job %s: ignoring quiet hours: %v", m.Name, err)
+			quiet = false
+		}
+	}
+	if quiet {
+		if len(a.Sites) == 0 {
+			return state, false, false, nil
+		}
+		state.Held = holdSites(state.Held, a.Sites, now)
+		logger.Printf("job %s: quiet hours, holding %d sites for the digest", m.Name, len(a.Sites))
+		return state, true, false, deliveryStore.SetDeliveryState(ctx, m.Name, state)
+	}
+	if state.Held != nil {
+		if len(a.Sites) == 0 {
+			logger.Printf("job %s: quiet hours are over and none of the %d held sites is still available; dropping the digest",
+				m.Name, len(state.Held.Sites))
+			state.Held = nil
+			if err := deliveryStore.SetDeliveryState(ctx, m.Name, state); err != nil {
+				logger.Printf("job %s: saving delivery state: %v", m.Name, err)
+			}
+			return state, false, false, nil
+		}
+		a.Held = state.Held
+	}
+	if len(a.Sites) > 0 && m.MaxNotificationsPerDay > 0 && state.Sent >= m.MaxNotificationsPerDay {
+		logger.Printf("job %s: already sent %d alerts today, the daily maximum; suppressing %d sites",
+			m.Name, state.Sent, len(a.Sites))
+		return state, false, true, nil
+	}
+	return state, false, false, nil
+}
+
+// recordDelivery stores state after a gated alert went out: it counts
+// toward today's cap and any held digest has been delivered. A failure only
+// means the digest may be repeated or the cap undercounted, so it is logged.
+func recordDelivery(ctx context.Context, m MessageContent, state deliveryState) {
+	state.Sent++
+	state.Held = nil
+	if err := deliveryStore.SetDeliveryState(ctx, m.Name, state); err != nil {
+		logger.Printf("job %s: saving delivery state: %v", m.Name, err)
+	}
+}
+
+// holdSites adds a finding at now to the digest d, which may be nil.
+func holdSites(d *heldDigest, sites []string, now time.Time) *heldDigest {
+	if d == nil {
+		d = &heldDigest{First: now}
+	}
+	d.Runs++
+	d.Last = now
+	seen := map[string]bool{}
+	for _, site := range d.Sites {
+		seen[site] = true
+	}
+	for _, site := range sites {
+		if !seen[site] {
+			seen[site] = true
+			d.Sites = append(d.Sites, site)
+		}
+	}
+	sort.Strings(d.Sites)
+	return d
+}
+
+// summary leads a digest alert, such as "During quiet hours, 4 checks from
+// 10:05pm to 6:55am found sites: 12, 14."
+func (d heldDigest) summary() string {
+	checks := "1 check at " + d.First.Format("3:04pm")
+	if d.Runs > 1 {
+		checks = fmt.Sprintf("%d checks from %s to %s", d.Runs, d.First.Format("3:04pm"), d.Last.Format("3:04pm"))
+	}
+	return fmt.Sprintf("During quiet hours, %s found sites: %s.", checks, strings.Join(d.Sites, ", "))
+}
+
+// DeliveryState implements DeliveryStore.
+func (s *MemoryStore) DeliveryState(ctx context.Context, job string) (deliveryState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delivery[job], nil
+}
+
+// SetDeliveryState implements DeliveryStore.
+func (s *MemoryStore) SetDeliveryState(ctx context.Context, job string, state deliveryState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.delivery == nil {
+		s.delivery = map[string]deliveryState{}
+	}
+	s.delivery[job] = state
+	return nil
+}
+
+// DeleteDeliveryState implements DeliveryStore.
+func (s *MemoryStore) DeleteDeliveryState(ctx context.Context, job string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.delivery, job)
+	return nil
+}
+
+// DeliveryState implements DeliveryStore.
+func (s BucketStore) DeliveryState(ctx context.Context, job string) (deliveryState, error) {
+	var state deliveryState
+	client, err := storageClient()
+	if err != nil {
+		return state, err
+	}
+	r, err := client.Bucket(s.Bucket).Object(deliveryPrefix + job + ".json").NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	defer r.Close()
+	if err := json.NewDecoder(r).Decode(&state); err != nil {
+		return state, fmt.Errorf("decoding delivery state for %s: %v", job, err)
+	}
+	return state, nil
+}
+
+// SetDeliveryState implements DeliveryStore.
+func (s BucketStore) SetDeliveryState(ctx context.Context, job string, state deliveryState) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	w := client.Bucket(s.Bucket).Object(deliveryPrefix + job + ".json").NewWriter(ctx)
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// DeleteDeliveryState implements DeliveryStore.
+func (s BucketStore) DeleteDeliveryState(ctx context.Context, job string) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	err = client.Bucket(s.Bucket).Object(deliveryPrefix + job + ".json").Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+// deliveryStore is where watches keep their deliveryState: RESULTS_BUCKET
+// when set, memory otherwise, as for stateStore. Replace it in tests.
+var deliveryStore DeliveryStore = defaultDeliveryStore()
+
+func defaultDeliveryStore() DeliveryStore {
+	if bucket := os.Getenv("RESULTS_BUCKET"); bucket != "" {
+		return BucketStore{Bucket: bucket}
+	}
+	return &MemoryStore{}
+}
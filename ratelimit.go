@@ -0,0 +1,134 @@
+package scraper
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Default limits on the recreation.gov request rate a single watch may
+// imply, overridable with REQUEST_RATE_WARN and REQUEST_RATE_MAX.
+const (
+	defaultWarnRequestsPerHour = 60
+	defaultMaxRequestsPerHour  = 360
+)
+
+// RequestRateError rejects a watch whose schedule would send more requests
+// per hour than allowed.
+type RequestRateError struct {
+	Schedule    string
+	Campgrounds int
+	Months      int
+	RunsPerHour float64
+	PerHour     float64
+	MaxPerHour  float64
+}
+
+func (e *RequestRateError) Error() string {
+	return fmt.Sprintf("schedule %q runs %.0f times an hour; × %d campgrounds × %d months = %.0f requests/hour, over the limit of %.0f. "+
+		"Use a slower schedule, or a short-lived burst watch if you really need to scan this often",
+		e.Schedule, e.RunsPerHour, e.Campgrounds, e.Months, e.PerHour, e.MaxPerHour)
+}
+
+// EstimateRequestsPerHour estimates the recreation.gov requests per hour
+// implied by running a watch covering the given number of campgrounds and
+// months on a cron schedule. Day-of-month, month and weekday restrictions
+// are ignored, so the estimate is for the busiest hour of the busiest day.
+func EstimateRequestsPerHour(schedule string, campgrounds int, months int) (float64, error) {
+	runs, err := cronRunsPerHour(schedule)
+	if err != nil {
+		return 0, err
+	}
+	return runs * float64(campgrounds*months), nil
+}
+
+// requestsPerHour is EstimateRequestsPerHour for m, covering its campgrounds
+// and the months of its span.
+func (m MessageContent) requestsPerHour(schedule string) (float64, error) {
+	start, end := m.span()
+	return EstimateRequestsPerHour(schedule, len(m.campgrounds()), monthsCovered(start, end))
+}
+
+// checkRequestRate returns a warning when the estimate is above the warn
+// threshold and a *RequestRateError when it is above the maximum.
+func checkRequestRate(schedule string, campgrounds int, months int) (string, error) {
+	runs, err := cronRunsPerHour(schedule)
+	if err != nil {
+		return "", err
+	}
+	perHour := runs * float64(campgrounds*months)
+	if max := envFloat("REQUEST_RATE_MAX", defaultMaxRequestsPerHour); perHour > max {
+		return "", &RequestRateError{schedule, campgrounds, months, runs, perHour, max}
+	}
+	if warn := envFloat("REQUEST_RATE_WARN", defaultWarnRequestsPerHour); perHour > warn {
+		return fmt.Sprintf("schedule %q implies about %.0f requests/hour to recreation.gov (warning threshold %.0f)",
+			schedule, perHour, warn), nil
+	}
+	return "", nil
+}
+
+func envFloat(name string, fallback float64) float64 {
+	if v, err := strconv.ParseFloat(os.Getenv(name), 64); err == nil && v > 0 {
+		return v
+	}
+	return fallback
+}
+
+// cronRunsPerHour is how often a five-field cron schedule fires in its
+// busiest hour: every matching minute of any hour it runs in. recreation.gov
+// throttles by the hour, so a schedule confined to a few hours is as heavy
+// as one running all day; the hours field is only checked.
+func cronRunsPerHour(schedule string) (float64, error) {
+	fields := strings.Fields(schedule)
+	if len(fields) != 5 {
+		return 0, fmt.Errorf("schedule %q: want 5 cron fields, got %d", schedule, len(fields))
+	}
+	minutes, err := cronFieldCount(fields[0], 0, 59)
+	if err != nil {
+		return 0, fmt.Errorf("schedule %q minutes: %v", schedule, err)
+	}
+	if _, err := cronFieldCount(fields[1], 0, 23); err != nil {
+		return 0, fmt.Errorf("schedule %q hours: %v", schedule, err)
+	}
+	return float64(minutes), nil
+}
+
+// cronFieldCount returns how many values in [min, max] a cron field matches.
+func cronFieldCount(field string, min int, max int) (int, error) {
+	matched := map[int]bool{}
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("bad step in %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+		lo, hi := min, max
+		if part != "*" {
+			bounds := strings.SplitN(part, "-", 2)
+			var err error
+			if lo, err = strconv.Atoi(bounds[0]); err != nil {
+				return 0, fmt.Errorf("bad value %q", part)
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = strconv.Atoi(bounds[1]); err != nil {
+					return 0, fmt.Errorf("bad range %q", part)
+				}
+			} else if step > 1 {
+				hi = max
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+		for v := lo; v <= hi; v += step {
+			matched[v] = true
+		}
+	}
+	return len(matched), nil
+}
@@ -0,0 +1,96 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestCronRunsPerHour(t *testing.T) {
+	tests := []struct {
+		schedule string
+		want     float64
+	}{
+		{"* * * * *", 60},
+		{"*/5 * * * *", 12},
+		{"0 * * * *", 1},
+		{"0,30 * * * *", 2},
+		// A schedule confined to a few hours is as heavy while it runs.
+		{"* 9-10 * * *", 60},
+		{"*/10 6 * * 1-5", 6},
+		{"0 */6 * * *", 1},
+	}
+	for _, test := range tests {
+		t.Run(test.schedule, func(t *testing.T) {
+			got, err := cronRunsPerHour(test.schedule)
+			if err != nil {
+				t.Fatalf("cronRunsPerHour: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("cronRunsPerHour = %v, want %v", got, test.want)
+			}
+		})
+	}
+	for _, schedule := range []string{"* * * *", "*/0 * * * *", "60 * * * *", "* 24 * * *"} {
+		if _, err := cronRunsPerHour(schedule); err == nil {
+			t.Errorf("cronRunsPerHour(%q) accepted", schedule)
+		}
+	}
+}
+
+func TestRequestsPerHour(t *testing.T) {
+	tests := []struct {
+		name     string
+		watch    MessageContent
+		schedule string
+		want     float64
+	}{
+		{"single campground", MessageContent{Campground: "232447", Arrival: "2027-07-14", Departure: "2027-07-16"}, "*/5 * * * *", 12},
+		{"across a month end", MessageContent{Campground: "232447", Arrival: "2027-07-30", Departure: "2027-08-02"}, "*/5 * * * *", 24},
+		{"departing on the 1st", MessageContent{Campground: "232447", Arrival: "2027-07-30", Departure: "2027-08-01"}, "*/5 * * * *", 12},
+		{"three campgrounds", MessageContent{Campgrounds: []string{"232447", "232450", "232449"}, Arrival: "2027-07-14", Departure: "2027-07-16"}, "*/10 * * * *", 18},
+		{"flexible window", MessageContent{Campground: "232447", WindowStart: "2027-06-15", WindowEnd: "2027-09-10", Nights: 2}, "*/15 * * * *", 16},
+		{"flexible departure", MessageContent{Campground: "232447", Arrival: "2027-07-28", Departure: "2027-07-30", MaxNights: 5}, "0 * * * *", 2},
+		{"multi-campground window", MessageContent{Campgrounds: []string{"232447", "232450"}, WindowStart: "2027-07-01", WindowEnd: "2027-08-31", Nights: 3}, "* 8-18 * * *", 240},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := test.watch.requestsPerHour(test.schedule)
+			if err != nil {
+				t.Fatalf("requestsPerHour: %v", err)
+			}
+			if got != test.want {
+				t.Errorf("requestsPerHour(%q) = %v, want %v", test.schedule, got, test.want)
+			}
+		})
+	}
+}
+
+func TestCheckRequestRate(t *testing.T) {
+	t.Setenv("REQUEST_RATE_WARN", "60")
+	t.Setenv("REQUEST_RATE_MAX", "360")
+	tests := []struct {
+		name        string
+		schedule    string
+		campgrounds int
+		months      int
+		wantWarning bool
+		wantReject  bool
+	}{
+		{"quiet", "*/5 * * * *", 1, 1, false, false},
+		{"every minute", "* * * * *", 2, 1, true, false},
+		{"every minute for an hour a day", "* 9 * * *", 2, 1, true, false},
+		{"ten campgrounds every minute", "* * * * *", 10, 1, false, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			warning, err := checkRequestRate(test.schedule, test.campgrounds, test.months)
+			var rate *RequestRateError
+			if rejected := errors.As(err, &rate); rejected != test.wantReject {
+				t.Fatalf("checkRequestRate error = %v, want rejected %v", err, test.wantReject)
+			}
+			if (warning != "") != test.wantWarning {
+				t.Errorf("checkRequestRate warning = %q, want a warning %v", warning, test.wantWarning)
+			}
+		})
+	}
+}
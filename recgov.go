@@ -0,0 +1,174 @@
+package scraper
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// Scraper holds the HTTP client, retry policy and month cache used to talk
+// to recreation.gov.
+type Scraper struct {
+	Client *http.Client
+	Retry  core.RetryPolicy
+	// BaseURL overrides the recreation.gov address, for tests.
+	BaseURL string
+	// Cache holds recently fetched months so watches on the same campground
+	// share fetches. Nil disables caching.
+	Cache core.Cache
+	// Log receives one summary entry per ScrapeFromMessage run. Nil drops
+	// them.
+	Log Logger
+	// Metrics receives the measurements of each ScrapeFromMessage run. Nil
+	// drops them.
+	Metrics Metrics
+	// History receives every campsite's status on every night each
+	// ScrapeFromMessage run fetched. Nil records none.
+	History *HistoryBatcher
+	// UserAgent replaces core.DefaultUserAgent on every request.
+	UserAgent string
+	// MaxResponseBytes bounds each response body, for campgrounds too large
+	// for core.DefaultMaxResponseBytes. Zero uses the default.
+	MaxResponseBytes int64
+	// Pacer spaces out every request s makes and holds them all off after a
+	// bot challenge. Nil sends requests as fast as they come.
+	Pacer *core.Pacer
+	// ReplayDir, when set, replaces recreation.gov's availability with the
+	// months stored there; see core.SnapshotDir. ScrapeFromMessage then
+	// logs the alerts it would send instead of sending them, and leaves
+	// jobs alone.
+	ReplayDir string
+	// Clock is the time s and its cache, pacer and requests go by, and, for
+	// DefaultScraper, every time-dependent decision the package makes. Nil
+	// means the wall clock.
+	Clock Clock
+
+	// ingested, when set, supplies the campsite and campground details s
+	// would otherwise fetch, for a result Ingest accepted from another
+	// provider.
+	ingested *ScrapeResult
+	// horizons holds each campground's first month found not yet released;
+	// nil keeps none.
+	horizons *horizonCache
+}
+
+// now is the current time by s.Clock.
+func (s *Scraper) now() time.Time {
+	if s.Clock != nil {
+		return s.Clock.Now()
+	}
+	return time.Now()
+}
+
+// since is the time elapsed since t by s.Clock.
+func (s *Scraper) since(t time.Time) time.Duration {
+	if s.Clock != nil {
+		return s.Clock.Since(t)
+	}
+	return time.Since(t)
+}
+
+// scraperClock reads a Scraper's clock on every call, so that setting
+// Clock after NewScraper also moves the cache and pacer it made.
+type scraperClock struct{ s *Scraper }
+
+func (c scraperClock) Now() time.Time { return c.s.now() }
+
+// NewScraper returns a Scraper using client, or a client with a 30 second
+// timeout when client is nil, and retry, caching months in memory for
+// core.DefaultCacheTTL, cooling down for core.DefaultBlockedCooldown after a
+// bot challenge, logging run summaries as JSON to stderr and recording no
+// metrics. The cache and pacer go by s.Clock.
+func NewScraper(client *http.Client, retry core.RetryPolicy) *Scraper {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	s := &Scraper{Client: client, Retry: retry, Log: JSONLogger{os.Stderr}, Metrics: NopMetrics{}}
+	s.Cache = &core.MemoryCache{TTL: core.DefaultCacheTTL, Clock: scraperClock{s}}
+	s.Pacer = &core.Pacer{Clock: scraperClock{s}}
+	s.horizons = &horizonCache{}
+	return s
+}
+
+// DefaultScraper is used by ScrapeFromMessage and the package-level helpers.
+// Replace it to point them somewhere else.
+var DefaultScraper = defaultScraper()
+
+// defaultScraper applies REQUEST_USER_AGENT, REQUEST_INTERVAL, the minimum
+// gap between requests such as 500ms, REQUEST_MAX_BYTES, METRICS,
+// BQ_DATASET and BQ_TABLE, REPLAY_DIR and RECORD_DIR to NewScraper's
+// defaults.
+func defaultScraper() *Scraper {
+	s := NewScraper(nil, core.DefaultRetryPolicy)
+	s.Metrics = metricsFromEnv(activeConfig.Project)
+	s.History = historyFromEnv(activeConfig.Project)
+	s.ReplayDir = os.Getenv("REPLAY_DIR")
+	if dir := os.Getenv("RECORD_DIR"); dir != "" {
+		s.RecordTo(dir)
+	}
+	s.UserAgent = os.Getenv("REQUEST_USER_AGENT")
+	if value := os.Getenv("REQUEST_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			logger.Printf("ignoring REQUEST_INTERVAL %q: not a duration such as 500ms", value)
+		} else {
+			s.Pacer.Interval = interval
+		}
+	}
+	if value := os.Getenv("REQUEST_MAX_BYTES"); value != "" {
+		limit, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || limit <= 0 {
+			logger.Printf("ignoring REQUEST_MAX_BYTES %q: not a positive number of bytes", value)
+		} else {
+			s.MaxResponseBytes = limit
+		}
+	}
+	return s
+}
+
+// Provider returns the recreation.gov provider backed by s.
+func (s *Scraper) Provider() core.RecreationGov {
+	return core.RecreationGov{Client: s.Client, Retry: s.Retry, BaseURL: s.BaseURL, UserAgent: s.UserAgent, MaxResponseBytes: s.MaxResponseBytes,
+		Pacer: s.Pacer, Clock: scraperClock{s}}
+}
+
+// RecordTo saves every month s fetches from recreation.gov into dir, for
+// replaying with ReplayDir later.
+func (s *Scraper) RecordTo(dir string) {
+	client := *s.Client
+	client.Transport = &core.RecordingTransport{Dir: dir, Transport: client.Transport}
+	s.Client = &client
+}
+
+// availability is where s reads campground months: ReplayDir when set,
+// otherwise recreation.gov through the cache.
+func (s *Scraper) availability() core.Provider {
+	if s.ReplayDir != "" {
+		return core.SnapshotDir{Dir: s.ReplayDir}
+	}
+	return s.Cached(s.Provider())
+}
+
+// Cached wraps p in s.Cache, or returns it unchanged when caching is off.
+func (s *Scraper) Cached(p core.Provider) core.Provider {
+	if s.Cache == nil {
+		return p
+	}
+	return core.CachedProvider{Provider: p, Cache: s.Cache}
+}
+
+// ScrapeAvailability scrapes recreation.gov for the campground and dates
+// specified. See core.Scrape for the errors it may return.
+func (s *Scraper) ScrapeAvailability(ctx context.Context, campgroundID string, arrival time.Time, departure time.Time) ([]string, error) {
+	return core.Scrape(ctx, s.availability(), campgroundID, core.CivilDateOf(arrival), core.CivilDateOf(departure))
+}
+
+// ScrapeAvailabilityDetailed is ScrapeAvailability with the per-site status
+// breakdown, for explaining why nothing matched.
+func (s *Scraper) ScrapeAvailabilityDetailed(ctx context.Context, campgroundID string, arrival time.Time, departure time.Time) (core.AvailabilityResult, error) {
+	return core.ScrapeDetailed(ctx, s.availability(), campgroundID, core.CivilDateOf(arrival), core.CivilDateOf(departure))
+}
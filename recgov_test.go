@@ -0,0 +1,14 @@
+package scraper
+
+import "testing"
+
+// REQUEST_MAX_BYTES raises the response limit of every request the default
+// scraper makes; a value that is not a positive size is ignored.
+func TestDefaultScraperMaxResponseBytes(t *testing.T) {
+	for value, want := range map[string]int64{"": 0, "33554432": 32 << 20, "32MB": 0, "-1": 0} {
+		t.Setenv("REQUEST_MAX_BYTES", value)
+		if got := defaultScraper().Provider().MaxResponseBytes; got != want {
+			t.Errorf("REQUEST_MAX_BYTES %q: provider limit %d, want %d", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package scraper
+
+import (
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// secretEnvVars hold credentials whose values must never appear in logs.
+var secretEnvVars = []string{"SENDGRID_API_KEY", "SLACK_WEBHOOK_URL", "SLACK_SIGNING_SECRET", "CLAIM_SECRET", "INGEST_API_KEY", "TWILIO_TOKEN", "WEBHOOK_SECRET", "SCRAPE_API_KEY", "CONTACTS_API_KEY", "ADMIN_API_KEY"}
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+-])[A-Za-z0-9._%+-]*@([A-Za-z0-9.-]+\.[A-Za-z]{2,})`)
+	// Phone numbers in E.164 or common North American forms. Deliberately
+	// narrow so dates and campground IDs are left alone.
+	phonePattern = regexp.MustCompile(`\+\d{10,15}|\(\d{3}\) ?\d{3}-\d{4}|\b\d{3}[-.]\d{3}[-.]\d{4}\b`)
+	digitPattern = regexp.MustCompile(`\d`)
+	// Slack webhook paths are bearer credentials.
+	slackHookPattern = regexp.MustCompile(`hooks\.slack\.com/services/[A-Za-z0-9/_-]+`)
+)
+
+// Redact masks personal data and secrets in s: email addresses become
+// s***@domain, phone numbers keep only their last four digits, and any
+// configured secret is removed entirely.
+func Redact(s string) string {
+	for _, name := range secretEnvVars {
+		if secret := os.Getenv(name); len(secret) >= 8 {
+			s = strings.Replace(s, secret, "[REDACTED]", -1)
+		}
+	}
+	s = slackHookPattern.ReplaceAllString(s, "hooks.slack.com/services/[REDACTED]")
+	s = emailPattern.ReplaceAllString(s, "$1***@$2")
+	return phonePattern.ReplaceAllStringFunc(s, func(phone string) string {
+		digits := digitPattern.FindAllString(phone, -1)
+		return "***" + strings.Join(digits[len(digits)-4:], "")
+	})
+}
+
+type redactingWriter struct {
+	w io.Writer
+}
+
+func (r redactingWriter) Write(p []byte) (int, error) {
+	if _, err := r.w.Write([]byte(Redact(string(p)))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logger is the package's logger. Each line is written as a Cloud Logging
+// JSON entry, which carries its own timestamp, and passes through Redact.
+var logger = log.New(jsonLineWriter{redactingWriter{os.Stderr}}, "", 0)
@@ -0,0 +1,40 @@
+package scraper
+
+import "testing"
+
+func TestRedact(t *testing.T) {
+	t.Setenv("SENDGRID_API_KEY", "SG.abcdefgh12345")
+	t.Setenv("TWILIO_TOKEN", "8chars!!")
+	t.Setenv("WEBHOOK_SECRET", "short77")
+	t.Setenv("CONTACTS_API_KEY", "ck-live-2468")
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"email", "sending to jane.doe+camp@example.com", "sending to j***@example.com"},
+		{"emails", "a@b.co and bob@mail.example.org", "a***@b.co and b***@mail.example.org"},
+		{"E.164 phone", "texting +14155550100", "texting ***0100"},
+		{"parenthesised phone", "call (415) 555-0100 now", "call ***0100 now"},
+		{"dashed phone", "call 415-555-0100", "call ***0100"},
+		{"dotted phone", "call 415.555.0100", "call ***0100"},
+		{"slack hook", "posting to https://hooks.slack.com/services/T000/B000/XXXXXXXX failed", "posting to https://hooks.slack.com/services/[REDACTED] failed"},
+		{"env secret", "Authorization: Bearer SG.abcdefgh12345", "Authorization: Bearer [REDACTED]"},
+		{"contacts key", "GET /contacts/jane with Bearer ck-live-2468: 401", "GET /contacts/jane with Bearer [REDACTED]: 401"},
+		{"eight character secret", "token=8chars!!", "token=[REDACTED]"},
+		{"short secret left alone", "secret short77", "secret short77"},
+		{"ISO date", "stay 2027-07-14 to 2027-07-16", "stay 2027-07-14 to 2027-07-16"},
+		{"US date", "arriving 07/14/2027", "arriving 07/14/2027"},
+		{"RFC 3339 time", "cutoff 2027-07-14T20:00:00-07:00", "cutoff 2027-07-14T20:00:00-07:00"},
+		{"campground ID", "campground 232447 site 1001", "campground 232447 site 1001"},
+		{"job name", "job watch-232447-20270714-20270716", "job watch-232447-20270714-20270716"},
+		{"long campground ID", "permit 4675321-1234567890", "permit 4675321-1234567890"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := Redact(test.in); got != test.want {
+				t.Errorf("Redact(%q) = %q, want %q", test.in, got, test.want)
+			}
+		})
+	}
+}
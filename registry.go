@@ -0,0 +1,399 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"google.golang.org/api/firestore/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// WatchStatus is where a registered watch is in its life.
+type WatchStatus string
+
+// Registered watch statuses.
+const (
+	WatchActive    WatchStatus = "active"
+	WatchPaused    WatchStatus = "paused"
+	WatchCompleted WatchStatus = "completed"
+)
+
+// Outcomes of runs of registered watches that do not scrape.
+const (
+	outcomeWatchPaused = "watch paused"
+	outcomeCompleted   = "watch completed"
+)
+
+// WatchRecord is a watch's document in the registry. Its scheduler job
+// carries only the ID, so the watch can change without recreating the job.
+type WatchRecord struct {
+	ID      string         `json:"id"`
+	Watch   MessageContent `json:"watch"`
+	Status  WatchStatus    `json:"status"`
+	Created time.Time      `json:"created"`
+	Updated time.Time      `json:"updated"`
+	// LastResult summarises the watch's most recent run.
+	LastResult *WatchResult `json:"last_result,omitempty"`
+	// Source is set for watches defined by a sheet row; see SyncSheet.
+	Source *WatchSource `json:"source,omitempty"`
+}
+
+// WatchResult summarises one run of a registered watch.
+type WatchResult struct {
+	At      time.Time `json:"at"`
+	Outcome string    `json:"outcome"`
+	Sites   int       `json:"sites"`
+	Error   string    `json:"error,omitempty"`
+	// NoMatch is why a run that found nothing did, site by site.
+	NoMatch string `json:"no_match,omitempty"`
+}
+
+// watchRef is the job payload of a registered watch.
+type watchRef struct {
+	WatchID string
+}
+
+// ErrWatchNotFound is returned by a WatchStore for an unknown watch ID.
+var ErrWatchNotFound = errors.New("watch not found")
+
+// WatchStore is the watch registry.
+type WatchStore interface {
+	// GetWatch returns the record for id, or ErrWatchNotFound.
+	GetWatch(ctx context.Context, id string) (WatchRecord, error)
+	// PutWatch creates or replaces the record with r's ID.
+	PutWatch(ctx context.Context, r WatchRecord) error
+	// ListWatchRecords returns every record, ordered by ID.
+	ListWatchRecords(ctx context.Context) ([]WatchRecord, error)
+}
+
+// GetWatch implements WatchStore.
+func (s *MemoryStore) GetWatch(ctx context.Context, id string) (WatchRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.watches[id]
+	if !ok {
+		return WatchRecord{}, ErrWatchNotFound
+	}
+	return r, nil
+}
+
+// PutWatch implements WatchStore.
+func (s *MemoryStore) PutWatch(ctx context.Context, r WatchRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.watches == nil {
+		s.watches = map[string]WatchRecord{}
+	}
+	s.watches[r.ID] = r
+	return nil
+}
+
+// ListWatchRecords implements WatchStore.
+func (s *MemoryStore) ListWatchRecords(ctx context.Context) ([]WatchRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	records := make([]WatchRecord, 0, len(s.watches))
+	for _, r := range s.watches {
+		records = append(records, r)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+// FirestoreStore is a WatchStore keeping one document per watch in
+// Collection of Project's default Firestore database. The record is stored
+// as JSON in the document's "record" field, next to "status" and "updated"
+// for browsing in the console.
+type FirestoreStore struct {
+	Project    string
+	Collection string
+}
+
+func (s FirestoreStore) parent() string {
+	return fmt.Sprintf("projects/%s/databases/(default)/documents", s.Project)
+}
+
+func (s FirestoreStore) document(id string) string {
+	return s.parent() + "/" + s.Collection + "/" + id
+}
+
+// GetWatch implements WatchStore.
+func (s FirestoreStore) GetWatch(ctx context.Context, id string) (WatchRecord, error) {
+	svc, err := firestore.NewService(ctx)
+	if err != nil {
+		return WatchRecord{}, fmt.Errorf("firestore.NewService: %v", err)
+	}
+	doc, err := svc.Projects.Databases.Documents.Get(s.document(id)).Context(ctx).Do()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound {
+		return WatchRecord{}, ErrWatchNotFound
+	}
+	if err != nil {
+		return WatchRecord{}, fmt.Errorf("reading watch %s: %v", id, err)
+	}
+	return decodeWatchDocument(ctx, doc)
+}
+
+// PutWatch implements WatchStore.
+func (s FirestoreStore) PutWatch(ctx context.Context, r WatchRecord) error {
+	svc, err := firestore.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("firestore.NewService: %v", err)
+	}
+	doc, err := encodeWatchDocument(ctx, r)
+	if err != nil {
+		return err
+	}
+	if _, err := svc.Projects.Databases.Documents.Patch(s.document(r.ID), doc).Context(ctx).Do(); err != nil {
+		return fmt.Errorf("writing watch %s: %v", r.ID, err)
+	}
+	return nil
+}
+
+// ListWatchRecords implements WatchStore.
+func (s FirestoreStore) ListWatchRecords(ctx context.Context) ([]WatchRecord, error) {
+	svc, err := firestore.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("firestore.NewService: %v", err)
+	}
+	records := []WatchRecord{}
+	err = svc.Projects.Databases.Documents.List(s.parent(), s.Collection).Pages(ctx, func(page *firestore.ListDocumentsResponse) error {
+		for _, doc := range page.Documents {
+			r, err := decodeWatchDocument(ctx, doc)
+			if err != nil {
+				return err
+			}
+			records = append(records, r)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("listing watches in %s: %v", s.Collection, err)
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].ID < records[j].ID })
+	return records, nil
+}
+
+// encodeWatchDocument is r's document. The watch's webhook and request
+// headers are left out of "record" and stored in "secrets", encrypted with
+// SECRETS_KMS_KEY; a watch with either cannot be stored without it.
+func encodeWatchDocument(ctx context.Context, r WatchRecord) (*firestore.Document, error) {
+	secrets := watchSecrets{WebhookURL: r.Watch.WebhookURL, Headers: r.Watch.Headers}
+	r.Watch.WebhookURL, r.Watch.Headers = "", nil
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	doc := &firestore.Document{Fields: map[string]firestore.Value{
+		"record":  {StringValue: string(data)},
+		"status":  {StringValue: string(r.Status)},
+		"updated": {TimestampValue: r.Updated.UTC().Format(time.RFC3339Nano)},
+	}}
+	if secrets.WebhookURL != "" || len(secrets.Headers) > 0 {
+		sealed, err := sealSecrets(ctx, secrets)
+		if err != nil {
+			return nil, fmt.Errorf("storing the webhook and headers of watch %s: %w", r.ID, err)
+		}
+		doc.Fields["secrets"] = firestore.Value{StringValue: sealed}
+	}
+	return doc, nil
+}
+
+// decodeWatchDocument is the record encodeWatchDocument stored, with its
+// secrets decrypted. Records written before secrets were split out still
+// hold them in "record" and read as they are.
+func decodeWatchDocument(ctx context.Context, doc *firestore.Document) (WatchRecord, error) {
+	var r WatchRecord
+	if err := json.Unmarshal([]byte(doc.Fields["record"].StringValue), &r); err != nil {
+		return WatchRecord{}, fmt.Errorf("decoding %s: %v", doc.Name, err)
+	}
+	if sealed := doc.Fields["secrets"].StringValue; sealed != "" {
+		var secrets watchSecrets
+		if err := openSecrets(ctx, sealed, &secrets); err != nil {
+			return WatchRecord{}, fmt.Errorf("decoding the secrets of %s: %w", doc.Name, err)
+		}
+		r.Watch.WebhookURL, r.Watch.Headers = secrets.WebhookURL, secrets.Headers
+	}
+	return r, nil
+}
+
+// newWatchStore returns the registry for cfg: the WATCH_REGISTRY Firestore
+// collection in cfg.Project, or nil when that is unset, which keeps each
+// watch in its scheduler job as before. Tests replace it.
+var newWatchStore = func(cfg Config) WatchStore {
+	if collection := os.Getenv("WATCH_REGISTRY"); collection != "" {
+		return FirestoreStore{Project: cfg.Project, Collection: collection}
+	}
+	return nil
+}
+
+// registryID is the opaque ID of a registered watch. It is derived from
+// WatchID so duplicates are still caught, without spelling out the
+// campground and dates in the job name.
+func registryID(m MessageContent) string {
+	sum := sha256.Sum256([]byte(WatchID(m)))
+	return "watch-" + hex.EncodeToString(sum[:8])
+}
+
+// watchFromJob decodes a job payload: a legacy watch is returned as is and
+// active, and a registered one is loaded from store with its status. ok is
+// false when the payload is neither, or names a watch the registry does not
+// have.
+func watchFromJob(ctx context.Context, store WatchStore, data []byte) (MessageContent, WatchStatus, bool) {
+	var m MessageContent
+	if err := json.Unmarshal(data, &m); err != nil {
+		return m, "", false
+	}
+	if m.Name != "" {
+		return m, WatchActive, true
+	}
+	var ref watchRef
+	if err := json.Unmarshal(data, &ref); err != nil || ref.WatchID == "" || store == nil {
+		return m, "", false
+	}
+	r, err := store.GetWatch(ctx, ref.WatchID)
+	if err != nil {
+		logger.Printf("job for watch %s: %v", ref.WatchID, err)
+		return m, "", false
+	}
+	m = r.Watch
+	m.Name = r.ID
+	return m, r.Status, true
+}
+
+// markWatchCompleted marks the registered watch id completed. Watches that
+// are not in store are left alone.
+func markWatchCompleted(ctx context.Context, store WatchStore, id string) {
+	if store == nil {
+		return
+	}
+	r, err := store.GetWatch(ctx, id)
+	if err != nil {
+		return
+	}
+	r.Status, r.Updated = WatchCompleted, DefaultScraper.now().UTC()
+	if err := store.PutWatch(ctx, r); err != nil {
+		logger.Printf("marking watch %s completed: %v", id, err)
+	}
+}
+
+// UpdateWatch replaces the definition of the registered watch id with m,
+// keeping its ID, status and schedule. It needs WATCH_REGISTRY; watches
+// created before it are changed by recreating them.
+func UpdateWatch(ctx context.Context, cfg Config, id string, m MessageContent) error {
+	store := newWatchStore(cfg)
+	if store == nil {
+		return fmt.Errorf("updating watch %s: WATCH_REGISTRY is not set", id)
+	}
+	return updateRegisteredWatch(ctx, store, id, m)
+}
+
+// updateRegisteredWatch is UpdateWatch on store.
+func updateRegisteredWatch(ctx context.Context, store WatchStore, id string, m MessageContent) error {
+	r, err := store.GetWatch(ctx, id)
+	if err != nil {
+		return fmt.Errorf("updating watch %s: %w", id, err)
+	}
+	if r.Status == WatchCompleted {
+		return fmt.Errorf("updating watch %s: it has completed", id)
+	}
+	if err := m.resolveCampground(ctx); err != nil {
+		return err
+	}
+	m.Name = id
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	if err := checkContact(ctx, newContactStore(activeConfig), m); err != nil {
+		return err
+	}
+	r.Watch, r.Updated = m, DefaultScraper.now().UTC()
+	return store.PutWatch(ctx, r)
+}
+
+// PauseWatch stops the registered watch id from scanning until it is made
+// active again. Its job keeps firing, but each run ends straight away.
+func PauseWatch(ctx context.Context, cfg Config, id string) error {
+	store := newWatchStore(cfg)
+	if store == nil {
+		return fmt.Errorf("pausing watch %s: WATCH_REGISTRY is not set", id)
+	}
+	r, err := store.GetWatch(ctx, id)
+	if err != nil {
+		return fmt.Errorf("pausing watch %s: %w", id, err)
+	}
+	if r.Status != WatchActive {
+		return fmt.Errorf("pausing watch %s: it is %s", id, r.Status)
+	}
+	r.Status, r.Updated = WatchPaused, DefaultScraper.now().UTC()
+	return store.PutWatch(ctx, r)
+}
+
+// loadRegisteredWatch replaces run's watch with the registered watch id.
+// done is set, with run's outcome, when the run should go no further
+// because the watch is paused or has completed.
+func loadRegisteredWatch(ctx context.Context, run *watchRun, id string) (MessageContent, bool, error) {
+	store := newWatchStore(activeConfig)
+	if store == nil {
+		run.outcome = outcomeInvalid
+		return MessageContent{}, true, fmt.Errorf("job for watch %s: WATCH_REGISTRY is not set", id)
+	}
+	r, err := store.GetWatch(ctx, id)
+	if errors.Is(err, ErrWatchNotFound) {
+		run.outcome = outcomeInvalid
+		return MessageContent{}, true, fmt.Errorf("job for watch %s: %w", id, err)
+	}
+	if err != nil {
+		run.outcome = outcomeScrapeError
+		return MessageContent{}, true, err
+	}
+	m := r.Watch
+	m.Name = r.ID
+	run.watch, run.registered = m, true
+	switch r.Status {
+	case WatchPaused:
+		run.outcome = outcomeWatchPaused
+		return m, true, nil
+	case WatchCompleted:
+		if run.dryRun {
+			run.outcome = outcomeCompleted
+			return m, true, nil
+		}
+		return m, true, run.deleteJob(ctx, outcomeCompleted)
+	}
+	return m, false, nil
+}
+
+// recordWatchResult stores run's outcome on its registry document, marking
+// the watch completed once its job is gone.
+func recordWatchResult(ctx context.Context, run *watchRun, runErr error) {
+	store := newWatchStore(activeConfig)
+	if store == nil {
+		return
+	}
+	r, err := store.GetWatch(ctx, run.watch.Name)
+	if err != nil {
+		logger.Printf("recording result of watch %s: %v", run.watch.Name, err)
+		return
+	}
+	now := DefaultScraper.now().UTC()
+	r.LastResult = &WatchResult{At: now, Outcome: run.outcome, Sites: run.sites, NoMatch: run.alert.NoMatch}
+	if runErr != nil {
+		r.LastResult.Error = runErr.Error()
+	}
+	if run.deleted {
+		r.Status = WatchCompleted
+	}
+	r.Updated = now
+	if err := store.PutWatch(ctx, r); err != nil {
+		logger.Printf("recording result of watch %s: %v", run.watch.Name, err)
+	}
+}
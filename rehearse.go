@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// A message with the attribute mode=rehearse runs the watch's scrape as usual
+// but adds one synthetic result, sends it through the real notification
+// channels and leaves the job in place.
+const (
+	modeAttribute = "mode"
+	modeRehearse  = "rehearse"
+)
+
+// rehearsalLabel marks the synthetic result and every alert that contains it.
+const rehearsalLabel = "TEST — not a real opening"
+
+// Rehearse triggers a rehearsal of the watch called jobName by publishing the
+// job's own payload to its topic with mode=rehearse set.
+func Rehearse(ctx context.Context, jobName string) error {
+	name, err := ParseJobName(jobName)
+	if err != nil {
+		return err
+	}
+	c, err := newWatchScheduler()
+	if err != nil {
+		return err
+	}
+
+	job, err := c.GetJob(ctx, name.String())
+	if err != nil {
+		return fmt.Errorf("getting job %s: %v", name, err)
+	}
+	target := job.GetPubsubTarget()
+	if target == nil {
+		return fmt.Errorf("job %s does not publish to Pub/Sub", name)
+	}
+	// TopicName has the form projects/{project}/topics/{topic}.
+	parts := strings.Split(target.TopicName, "/")
+	if len(parts) != 4 {
+		return fmt.Errorf("job %s has unexpected topic %q", name, target.TopicName)
+	}
+
+	topic, err := sharedTopic(parts[1], parts[3])
+	if err != nil {
+		return err
+	}
+
+	attributes := map[string]string{modeAttribute: modeRehearse}
+	for k, v := range target.Attributes {
+		if k != modeAttribute {
+			attributes[k] = v
+		}
+	}
+	result := topic.Publish(ctx, &pubsub.Message{Data: target.Data, Attributes: attributes})
+	if _, err := result.Get(ctx); err != nil {
+		return fmt.Errorf("publishing rehearsal for %s: %v", name, err)
+	}
+	logger.Printf("rehearsal requested for job %s", name)
+	return nil
+}
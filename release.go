@@ -0,0 +1,160 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// releaseBurst is how long before and after the release instant a release
+// watch scans.
+const releaseBurst = 15 * time.Minute
+
+// ReleaseOptions configures a watch for the moment a stay's dates first
+// become bookable.
+type ReleaseOptions struct {
+	CampgroundID string
+	Arrival      core.CivilDate
+	Departure    core.CivilDate
+	// MonthsAhead is the booking window; recreation.gov's rolling window is
+	// 6 months for most campgrounds, which is the default.
+	MonthsAhead int
+	// ReleaseHour and TimeZone give the local hour dates are released at,
+	// 10:00 America/New_York by default. ReleaseHour is a pointer so that
+	// a midnight release, hour 0, can be told apart from unset.
+	ReleaseHour *int
+	TimeZone    string
+	// ProjectID, Location and Topic default to the package configuration,
+	// GCP_PROJECT, SCHEDULER_LOCATION and WATCH_TOPIC.
+	ProjectID string
+	Location  string
+	Topic     string
+	// Force creates the watch even when an overlapping one exists.
+	Force bool
+}
+
+func (o *ReleaseOptions) setDefaults() {
+	if o.MonthsAhead == 0 {
+		o.MonthsAhead = 6
+	}
+	if o.ReleaseHour == nil {
+		hour := 10
+		o.ReleaseHour = &hour
+	}
+	if o.TimeZone == "" {
+		o.TimeZone = "America/New_York"
+	}
+	if o.ProjectID == "" {
+		o.ProjectID = activeConfig.Project
+	}
+	if o.Location == "" {
+		o.Location = activeConfig.Location
+	}
+	if o.Topic == "" {
+		o.Topic = activeConfig.Topic
+	}
+}
+
+// ReleaseInstant returns when arrival first becomes bookable: monthsAhead
+// months earlier at hour in loc. When the earlier month is shorter, the
+// release is on its last day, so a stay from August 31 releases on the last
+// day of February. The hour is local wall-clock time, so it holds across DST;
+// on the day an hour is skipped, a release in it is at the end of the gap.
+func ReleaseInstant(arrival core.CivilDate, monthsAhead int, hour int, loc *time.Location) time.Time {
+	first := time.Date(arrival.Year, arrival.Month-time.Month(monthsAhead), 1, 0, 0, 0, 0, loc)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := arrival.Day
+	if day > lastDay {
+		day = lastDay
+	}
+	release := time.Date(first.Year(), first.Month(), day, hour, 0, 0, 0, loc)
+	if release.Hour() != hour {
+		release = time.Date(first.Year(), first.Month(), day, hour+1, 0, 0, 0, loc)
+	}
+	return release
+}
+
+// ReleaseWatch is the watch created by CreateReleaseWatch and the instant it
+// is centred on. Merged is set when an overlapping watch was reused.
+type ReleaseWatch struct {
+	Name    JobName
+	Release time.Time
+	Merged  bool
+}
+
+// CreateReleaseWatch creates a watch that scans every minute from 15 minutes
+// before the release instant to 15 minutes after. A minute is the finest
+// schedule Cloud Scheduler's cron runs; scanning twice a run would hold each
+// invocation idle for 30 seconds to save at most as many. It alerts and
+// deletes itself on the first bookable result, and otherwise removes itself
+// at the end of the burst with a release-day no-luck notice.
+func CreateReleaseWatch(ctx context.Context, opts ReleaseOptions) (ReleaseWatch, error) {
+	opts.setDefaults()
+	loc, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return ReleaseWatch{}, fmt.Errorf("invalid time zone %q: %v", opts.TimeZone, err)
+	}
+	if hour := *opts.ReleaseHour; hour < 0 || hour > 23 {
+		return ReleaseWatch{}, fmt.Errorf("release hour %d is not between 0 and 23", hour)
+	}
+	if !opts.Departure.After(opts.Arrival) {
+		return ReleaseWatch{}, fmt.Errorf("departure %s is not after arrival %s", opts.Departure, opts.Arrival)
+	}
+	release := ReleaseInstant(opts.Arrival, opts.MonthsAhead, *opts.ReleaseHour, loc)
+	start, end := release.Add(-releaseBurst), release.Add(releaseBurst)
+	if !DefaultScraper.now().Before(end) {
+		return ReleaseWatch{Release: release}, fmt.Errorf("%s was released at %s", opts.Arrival, release.Format(time.RFC1123))
+	}
+
+	name := JobName{
+		Project:  opts.ProjectID,
+		Location: opts.Location,
+		Job:      fmt.Sprintf("release-%s-%s", opts.CampgroundID, opts.Arrival),
+	}
+	m := MessageContent{
+		Name:       name.Job,
+		Campground: opts.CampgroundID,
+		Arrival:    opts.Arrival.String(),
+		Departure:  opts.Departure.String(),
+		Cutoff:     end.Format(time.RFC3339),
+		Release:    release.Format(time.RFC3339),
+		ScanWindow: &ScanWindow{Start: start.Format("15:04"), End: end.Format("15:04")},
+		TimeZone:   opts.TimeZone,
+	}
+	schedule := burstSchedule(start, end)
+	logger.Printf("job %s: release at %s, scanning on %q", name.Job, release.Format(time.RFC3339), schedule)
+	name, merged, err := createWatchJob(ctx, name, schedule, opts.TimeZone, opts.Topic, m, opts.Force)
+	return ReleaseWatch{Name: name, Release: release, Merged: merged}, err
+}
+
+// burstSchedule returns an every-minute cron covering the hours from start to
+// end in their location. Cron cannot express a range across hours or days
+// exactly, so it may also fire outside the burst; the watch's ScanWindow and
+// Cutoff turn those runs into no-ops.
+func burstSchedule(start time.Time, end time.Time) string {
+	hours, days, months := map[int]bool{}, map[int]bool{}, map[int]bool{}
+	for t := start.Truncate(time.Minute); !t.After(end); t = t.Add(time.Minute) {
+		hours[t.Hour()] = true
+		days[t.Day()] = true
+		months[int(t.Month())] = true
+	}
+	return fmt.Sprintf("* %s %s %s *", cronList(hours), cronList(days), cronList(months))
+}
+
+func cronList(set map[int]bool) string {
+	values := make([]int, 0, len(set))
+	for v := range set {
+		values = append(values, v)
+	}
+	sort.Ints(values)
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
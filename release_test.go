@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+func TestReleaseInstant(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name        string
+		arrival     string
+		monthsAhead int
+		hour        int
+		want        time.Time
+	}{
+		{name: "six months", arrival: "2027-07-14", monthsAhead: 6, hour: 10, want: time.Date(2027, 1, 14, 15, 0, 0, 0, time.UTC)},
+		{name: "across a year", arrival: "2027-03-01", monthsAhead: 6, hour: 10, want: time.Date(2026, 9, 1, 14, 0, 0, 0, time.UTC)},
+		{name: "march 31 release", arrival: "2027-10-31", monthsAhead: 7, hour: 10, want: time.Date(2027, 3, 31, 14, 0, 0, 0, time.UTC)},
+		{name: "march 31 arrival", arrival: "2027-03-31", monthsAhead: 6, hour: 10, want: time.Date(2026, 9, 30, 14, 0, 0, 0, time.UTC)},
+		{name: "end of february", arrival: "2027-08-31", monthsAhead: 6, hour: 10, want: time.Date(2027, 2, 28, 15, 0, 0, 0, time.UTC)},
+		{name: "leap day", arrival: "2028-08-31", monthsAhead: 6, hour: 10, want: time.Date(2028, 2, 29, 15, 0, 0, 0, time.UTC)},
+		// Clocks go forward on March 14, 2027 and back on November 7.
+		{name: "day clocks go forward", arrival: "2027-09-14", monthsAhead: 6, hour: 10, want: time.Date(2027, 3, 14, 14, 0, 0, 0, time.UTC)},
+		{name: "day clocks go back", arrival: "2028-05-07", monthsAhead: 6, hour: 10, want: time.Date(2027, 11, 7, 15, 0, 0, 0, time.UTC)},
+		{name: "midnight", arrival: "2027-07-14", monthsAhead: 6, hour: 0, want: time.Date(2027, 1, 14, 5, 0, 0, 0, time.UTC)},
+		{name: "hour skipped by DST", arrival: "2027-09-14", monthsAhead: 6, hour: 2, want: time.Date(2027, 3, 14, 7, 0, 0, 0, time.UTC)},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			arrival, err := core.ParseCivilDate(test.arrival)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ReleaseInstant(arrival, test.monthsAhead, test.hour, newYork); !got.Equal(test.want) {
+				t.Errorf("ReleaseInstant() = %v, want %v", got, test.want.In(newYork))
+			}
+		})
+	}
+}
+
+// The burst fires every minute of the hours it covers in the job's zone,
+// which are the hours on the local clock when it crosses midnight or a DST
+// change.
+func TestBurstSchedule(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+	tests := []struct {
+		name    string
+		release time.Time
+		want    string
+	}{
+		{name: "within two hours", release: time.Date(2027, 1, 14, 10, 0, 0, 0, newYork), want: "* 9,10 14 1 *"},
+		{name: "within an hour", release: time.Date(2027, 1, 14, 10, 30, 0, 0, newYork), want: "* 10 14 1 *"},
+		{name: "across midnight", release: time.Date(2027, 3, 31, 24, 5, 0, 0, newYork), want: "* 0,23 1,31 3,4 *"},
+		{name: "across the DST change", release: time.Date(2027, 3, 14, 7, 0, 0, 0, time.UTC).In(newYork), want: "* 1,3 14 3 *"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := burstSchedule(test.release.Add(-releaseBurst), test.release.Add(releaseBurst)); got != test.want {
+				t.Errorf("burstSchedule() = %q, want %q", got, test.want)
+			}
+		})
+	}
+}
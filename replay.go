@@ -0,0 +1,86 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// ReplayChange is how a watch's notification differs from the golden set.
+type ReplayChange string
+
+// Replay differences.
+const (
+	ReplayAdded   ReplayChange = "added"
+	ReplayRemoved ReplayChange = "removed"
+	ReplayChanged ReplayChange = "changed"
+)
+
+// ReplayDiff is one watch whose replayed notification differs from golden.
+type ReplayDiff struct {
+	Job    string
+	Change ReplayChange
+	Want   string
+	Got    string
+}
+
+// Replay runs each watch through the matching and email rendering pipeline
+// against stored snapshots, with nothing sent, deleted or published. It
+// returns the plain-text email each watch would send, keyed by job name;
+// watches that would send nothing are left out. A watch whose snapshot is
+// missing or fails to decode stops the replay, since its result would be
+// meaningless.
+func Replay(ctx context.Context, snapshots core.SnapshotDir, watches []MessageContent) (map[string]string, error) {
+	notifications := map[string]string{}
+	for _, m := range watches {
+		a, err := DefaultScraper.matchWatch(ctx, snapshots, m, false)
+		var closed *core.SeasonClosedError
+		var partial *core.PartialResultError
+		if err != nil && !errors.As(err, &closed) && !errors.As(err, &partial) {
+			return notifications, err
+		}
+		if len(a.Sites) == 0 {
+			continue
+		}
+		subject, plain, _, err := renderEmail(a)
+		if err != nil {
+			return notifications, err
+		}
+		notifications[m.Name] = subject + "\n\n" + plain
+	}
+	return notifications, nil
+}
+
+// logReplayedAlert logs the email a replayed run would have sent.
+func logReplayedAlert(a alert) {
+	subject, plain, _, err := renderEmail(a)
+	if err != nil {
+		logger.Printf("job %s: replay: %v", a.JobName, err)
+		return
+	}
+	logger.Printf("job %s: replay, not sending:\n%s\n\n%s", a.JobName, subject, plain)
+}
+
+// DiffReplay compares replayed notifications against a golden set, sorted
+// by job name.
+func DiffReplay(golden map[string]string, got map[string]string) []ReplayDiff {
+	diffs := []ReplayDiff{}
+	for job, want := range golden {
+		g, ok := got[job]
+		switch {
+		case !ok:
+			diffs = append(diffs, ReplayDiff{Job: job, Change: ReplayRemoved, Want: want})
+		case g != want:
+			diffs = append(diffs, ReplayDiff{Job: job, Change: ReplayChanged, Want: want, Got: g})
+		}
+	}
+	for job, g := range got {
+		if _, ok := golden[job]; !ok {
+			diffs = append(diffs, ReplayDiff{Job: job, Change: ReplayAdded, Got: g})
+		}
+	}
+	sort.Slice(diffs, func(i, j int) bool { return diffs[i].Job < diffs[j].Job })
+	return diffs
+}
@@ -0,0 +1,61 @@
+package scraper_test
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// TestReplayGolden replays the watches in testdata/replay against its
+// snapshots and diffs the notifications against golden.json, as the replay
+// command does. Campsite and campground details come from a fake
+// recreation.gov, so nothing leaves the process.
+func TestReplayGolden(t *testing.T) {
+	dir := filepath.Join("testdata", "replay")
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "watches.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var watches []scraper.MessageContent
+	if err := json.Unmarshal(data, &watches); err != nil {
+		t.Fatal(err)
+	}
+	got, err := scraper.Replay(context.Background(), core.SnapshotDir{Dir: filepath.Join(dir, "snapshots")}, watches)
+	if err != nil {
+		t.Fatalf("Replay: %v", err)
+	}
+
+	goldenFile := filepath.Join(dir, "golden.json")
+	if *scraper.UpdateGolden {
+		out, err := json.MarshalIndent(got, "", "  ")
+		if err == nil {
+			err = ioutil.WriteFile(goldenFile, append(out, '\n'), 0644)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	golden := map[string]string{}
+	data, err = ioutil.ReadFile(goldenFile)
+	if err == nil {
+		err = json.Unmarshal(data, &golden)
+	}
+	if err != nil {
+		t.Fatalf("%v; run go test -run TestReplayGolden -update to create it", err)
+	}
+	for _, d := range scraper.DiffReplay(golden, got) {
+		t.Errorf("%s %s\nwant: %q\ngot:  %q", d.Change, d.Job, d.Want, d.Got)
+	}
+}
@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"strings"
+)
+
+// requestedSites sorts the campsites a CampsiteIDs watch asked for by what
+// the scan found.
+type requestedSites struct {
+	Available []string
+	Booked    []string
+	// Missing lists IDs the campground does not have, most likely typos.
+	Missing []string
+}
+
+// requestedSitesFor sorts requested into those among available, those the
+// campground has but that did not match, and those it does not have. seen
+// is every campsite ID the scan fetched; when it is empty, as after a
+// failed fetch, none is reported missing.
+func requestedSitesFor(requested []string, seen []string, available []string) *requestedSites {
+	exists, open := map[string]bool{}, map[string]bool{}
+	for _, id := range seen {
+		exists[id] = true
+	}
+	for _, id := range available {
+		open[id] = true
+	}
+	r := &requestedSites{}
+	for _, id := range dedupeStrings(requested) {
+		switch {
+		case open[id]:
+			r.Available = append(r.Available, id)
+		case len(seen) > 0 && !exists[id]:
+			r.Missing = append(r.Missing, id)
+		default:
+			r.Booked = append(r.Booked, id)
+		}
+	}
+	return r
+}
+
+// allMissing reports whether the campground has none of the campsites, so
+// the watch can never match.
+func (r *requestedSites) allMissing() bool {
+	return r != nil && len(r.Available) == 0 && len(r.Booked) == 0 && len(r.Missing) > 0
+}
+
+// note is r as a line for alerts, such as "Your campsites: available 1234;
+// not available 5678; not at this campground 9999 (check the ID in the
+// site's recreation.gov address)." It is empty for nil.
+func (r *requestedSites) note() string {
+	if r == nil {
+		return ""
+	}
+	parts := []string{}
+	if len(r.Available) > 0 {
+		parts = append(parts, "available "+strings.Join(r.Available, ", "))
+	}
+	if len(r.Booked) > 0 {
+		parts = append(parts, "not available "+strings.Join(r.Booked, ", "))
+	}
+	if len(r.Missing) > 0 {
+		parts = append(parts, "not at this campground "+strings.Join(r.Missing, ", ")+" (check the ID in the site's recreation.gov address)")
+	}
+	return "Your campsites: " + strings.Join(parts, "; ") + "."
+}
@@ -0,0 +1,55 @@
+package scraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRequestedSitesFor(t *testing.T) {
+	tests := []struct {
+		name      string
+		requested []string
+		seen      []string
+		available []string
+		want      *requestedSites
+	}{
+		{name: "each kind", requested: []string{"1001", "1002", "9999"}, seen: []string{"1001", "1002", "1003"}, available: []string{"1001"},
+			want: &requestedSites{Available: []string{"1001"}, Booked: []string{"1002"}, Missing: []string{"9999"}}},
+		{name: "repeated", requested: []string{"9999", "1001", "9999"}, seen: []string{"1001"},
+			want: &requestedSites{Booked: []string{"1001"}, Missing: []string{"9999"}}},
+		{name: "nothing fetched", requested: []string{"1001", "9999"},
+			want: &requestedSites{Booked: []string{"1001", "9999"}}},
+		{name: "all missing", requested: []string{"9998", "9999"}, seen: []string{"1001"},
+			want: &requestedSites{Missing: []string{"9998", "9999"}}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := requestedSitesFor(test.requested, test.seen, test.available)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("requestedSitesFor() = %+v, want %+v", got, test.want)
+			}
+			if want := test.name == "all missing"; got.allMissing() != want {
+				t.Errorf("allMissing() = %t, want %t", got.allMissing(), want)
+			}
+		})
+	}
+}
+
+func TestRequestedSitesNote(t *testing.T) {
+	tests := []struct {
+		name string
+		r    *requestedSites
+		want string
+	}{
+		{name: "none", r: nil, want: ""},
+		{name: "each kind", r: &requestedSites{Available: []string{"1001"}, Booked: []string{"1002", "1003"}, Missing: []string{"9999"}},
+			want: "Your campsites: available 1001; not available 1002, 1003; not at this campground 9999 (check the ID in the site's recreation.gov address)."},
+		{name: "only missing", r: &requestedSites{Missing: []string{"9998", "9999"}},
+			want: "Your campsites: not at this campground 9998, 9999 (check the ID in the site's recreation.gov address)."},
+	}
+	for _, test := range tests {
+		if got := test.r.note(); got != test.want {
+			t.Errorf("%s: note() = %q, want %q", test.name, got, test.want)
+		}
+	}
+}
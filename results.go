@@ -0,0 +1,210 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// ResultsSchemaVersion is the version of ResultsMessage written by this
+// package. It is carried in the schemaVersionAttribute message attribute so
+// consumers can reject payloads newer than they understand without parsing
+// them.
+const ResultsSchemaVersion = 1
+
+// Message attributes for filtering subscriptions, such as
+// attributes.found = "true".
+const (
+	schemaVersionAttribute = "schema_version"
+	campgroundAttribute    = "campground_id"
+	foundAttribute         = "found"
+)
+
+// ResultsMessage is the payload published to the results topic after every
+// scrape, whether or not it found availability.
+type ResultsMessage struct {
+	SchemaVersion int       `json:"schema_version"`
+	JobName       string    `json:"job_name"`
+	CampgroundID  string    `json:"campground_id"`
+	Arrival       time.Time `json:"arrival"`
+	Departure     time.Time `json:"departure"`
+	Sites         []string  `json:"sites"`
+	// DurationMs is how long the run took, and Notified whether it sent an
+	// alert on at least one channel.
+	DurationMs int64 `json:"duration_ms"`
+	Notified   bool  `json:"notified"`
+	// Partial is set when the scrape was cut short and Sites may be missing
+	// some openings.
+	Partial    bool   `json:"partial,omitempty"`
+	Rehearsal  bool   `json:"rehearsal,omitempty"`
+	ResultsURL string `json:"results_url,omitempty"`
+	// Attributes holds the Pub/Sub message attributes. It is not part of the
+	// JSON body.
+	Attributes map[string]string `json:"-"`
+}
+
+// UnsupportedSchemaError is returned when a results message was written with
+// a newer schema than this package understands.
+type UnsupportedSchemaError struct {
+	Version int
+}
+
+func (e *UnsupportedSchemaError) Error() string {
+	return fmt.Sprintf("results message schema version %d is newer than supported version %d", e.Version, ResultsSchemaVersion)
+}
+
+// EncodeResultsMessage returns the body and attributes to publish for r,
+// stamping the current schema version.
+func EncodeResultsMessage(r ResultsMessage) ([]byte, map[string]string, error) {
+	r.SchemaVersion = ResultsSchemaVersion
+	data, err := json.Marshal(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	attrs := map[string]string{}
+	for key, value := range r.Attributes {
+		attrs[key] = value
+	}
+	attrs[schemaVersionAttribute] = strconv.Itoa(ResultsSchemaVersion)
+	return data, attrs, nil
+}
+
+// DecodeResultsMessage parses a results message body and its attributes.
+// Messages without a version are treated as version 1.
+func DecodeResultsMessage(data []byte, attrs map[string]string) (ResultsMessage, error) {
+	var r ResultsMessage
+	version := 1
+	if v, ok := attrs[schemaVersionAttribute]; ok {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return r, fmt.Errorf("bad %s attribute %q: %v", schemaVersionAttribute, v, err)
+		}
+		version = n
+	}
+	if version > ResultsSchemaVersion {
+		return r, &UnsupportedSchemaError{Version: version}
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("decoding results message: %v", err)
+	}
+	if r.SchemaVersion == 0 {
+		r.SchemaVersion = version
+	}
+	r.Attributes = attrs
+	return r, nil
+}
+
+// Subscribe receives results messages from subscriptionID until ctx is done,
+// calling handler for each one. A message is acked when handler returns nil
+// and nacked for redelivery when it returns an error. Messages that cannot be
+// decoded are logged and acked, since redelivering them would never succeed;
+// messages with a newer schema are nacked so an upgraded consumer can take
+// them.
+func Subscribe(ctx context.Context, subscriptionID string, handler func(ResultsMessage) error) error {
+	client, err := pubsub.NewClient(ctx, activeConfig.Project)
+	if err != nil {
+		return fmt.Errorf("pubsub.NewClient: %v", err)
+	}
+	defer client.Close()
+
+	return client.Subscription(subscriptionID).Receive(ctx, func(ctx context.Context, m *pubsub.Message) {
+		r, err := DecodeResultsMessage(m.Data, m.Attributes)
+		if err != nil {
+			logger.Printf("results message %s: %v", m.ID, err)
+			if _, newer := err.(*UnsupportedSchemaError); newer {
+				m.Nack()
+			} else {
+				m.Ack()
+			}
+			return
+		}
+		if err := handler(r); err != nil {
+			logger.Printf("results message %s: handler: %v", m.ID, err)
+			m.Nack()
+			return
+		}
+		m.Ack()
+	})
+}
+
+// ResultsPublisher sends results messages downstream.
+type ResultsPublisher interface {
+	Publish(ctx context.Context, r ResultsMessage) error
+}
+
+// PubSubPublisher publishes results messages to a Pub/Sub topic. It shares
+// one client per project across invocations, so a warm function instance
+// does not reconnect for every run.
+type PubSubPublisher struct {
+	Project string
+	Topic   string
+}
+
+// Publish implements ResultsPublisher, waiting for the server to accept the
+// message.
+func (p PubSubPublisher) Publish(ctx context.Context, r ResultsMessage) error {
+	data, attrs, err := EncodeResultsMessage(r)
+	if err != nil {
+		return err
+	}
+	t, err := sharedTopic(p.Project, p.Topic)
+	if err != nil {
+		return err
+	}
+	if _, err := t.Publish(ctx, &pubsub.Message{Data: data, Attributes: attrs}).Get(ctx); err != nil {
+		return fmt.Errorf("publishing to %s: %v", p.Topic, err)
+	}
+	return nil
+}
+
+// resultsPublisher receives a message after every scrape. It is nil, and
+// nothing is published, unless RESULTS_TOPIC is set. Replace it in tests.
+var resultsPublisher ResultsPublisher = defaultResultsPublisher()
+
+func defaultResultsPublisher() ResultsPublisher {
+	if topic := os.Getenv("RESULTS_TOPIC"); topic != "" {
+		return PubSubPublisher{Project: activeConfig.Project, Topic: topic}
+	}
+	return nil
+}
+
+// resultsMessage describes a's scrape for the results topic, with the
+// attributes subscriptions can filter on.
+func resultsMessage(a alert, notified bool, elapsed time.Duration) ResultsMessage {
+	sites := a.Sites
+	if sites == nil {
+		sites = []string{}
+	}
+	return ResultsMessage{
+		JobName:      a.JobName,
+		CampgroundID: a.CampgroundID,
+		Arrival:      a.Arrival.Time(),
+		Departure:    a.Departure.Time(),
+		Sites:        sites,
+		DurationMs:   elapsed.Milliseconds(),
+		Notified:     notified,
+		Partial:      a.Partial != nil,
+		Rehearsal:    a.Rehearsal,
+		ResultsURL:   a.ResultsURL,
+		Attributes: map[string]string{
+			campgroundAttribute: a.CampgroundID,
+			foundAttribute:      strconv.FormatBool(len(a.Sites) > 0),
+		},
+	}
+}
+
+// publishResults sends r to resultsPublisher, if there is one. Failures are
+// only logged: the scrape itself succeeded.
+func publishResults(ctx context.Context, r ResultsMessage) {
+	if resultsPublisher == nil {
+		return
+	}
+	if err := resultsPublisher.Publish(ctx, r); err != nil {
+		logger.Printf("job %s: publishing results: %v", r.JobName, err)
+	}
+}
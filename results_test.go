@@ -0,0 +1,167 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"cloud.google.com/go/pubsub/pstest"
+)
+
+func TestDecodeResultsMessage(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		attrs   map[string]string
+		want    int
+		wantErr bool
+		newer   bool
+	}{
+		{name: "current", data: `{"schema_version":1,"campground_id":"1"}`, attrs: map[string]string{"schema_version": "1"}, want: 1},
+		{name: "unversioned", data: `{"campground_id":"1"}`, want: 1},
+		{name: "newer", data: `{"schema_version":2}`, attrs: map[string]string{"schema_version": "2"}, wantErr: true, newer: true},
+		{name: "bad version", data: `{}`, attrs: map[string]string{"schema_version": "one"}, wantErr: true},
+		{name: "bad body", data: `{"sites":"1001"}`, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, err := DecodeResultsMessage([]byte(test.data), test.attrs)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("DecodeResultsMessage error = %v, want error %v", err, test.wantErr)
+			}
+			var newer *UnsupportedSchemaError
+			if errors.As(err, &newer) != test.newer {
+				t.Errorf("error %v: newer schema = %v, want %v", err, !test.newer, test.newer)
+			}
+			if err == nil && r.SchemaVersion != test.want {
+				t.Errorf("SchemaVersion = %d, want %d", r.SchemaVersion, test.want)
+			}
+		})
+	}
+}
+
+// startEmulator points the Pub/Sub clients at an in-process emulator with
+// topic, and a subscription of the same name to it, in the deployment's
+// project.
+func startEmulator(t *testing.T, topic string) *pstest.Server {
+	t.Helper()
+	srv := pstest.NewServer()
+	t.Cleanup(func() { srv.Close() })
+	t.Setenv("PUBSUB_EMULATOR_HOST", srv.Addr)
+	// Shared clients are dialled to this emulator, so drop them with it.
+	t.Cleanup(func() { Shutdown() })
+
+	ctx := context.Background()
+	client, err := pubsub.NewClient(ctx, activeConfig.Project)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	created, err := client.CreateTopic(ctx, topic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.CreateSubscription(ctx, topic, pubsub.SubscriptionConfig{Topic: created, AckDeadline: 10 * time.Second}); err != nil {
+		t.Fatal(err)
+	}
+	return srv
+}
+
+func TestResultsRoundTrip(t *testing.T) {
+	startEmulator(t, "results")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	sent := ResultsMessage{
+		JobName:      "projects/p/locations/l/jobs/watch-232447",
+		CampgroundID: "232447",
+		Arrival:      time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC),
+		Departure:    time.Date(2027, 7, 16, 0, 0, 0, 0, time.UTC),
+		Sites:        []string{"1001", "1002"},
+		Notified:     true,
+		Attributes:   map[string]string{foundAttribute: "true"},
+	}
+	publisher := PubSubPublisher{Project: activeConfig.Project, Topic: "results"}
+	if err := publisher.Publish(ctx, sent); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+
+	// The handler fails the first delivery, so the message must come back.
+	var mu sync.Mutex
+	deliveries := []ResultsMessage{}
+	err := Subscribe(ctx, "results", func(r ResultsMessage) error {
+		mu.Lock()
+		defer mu.Unlock()
+		deliveries = append(deliveries, r)
+		if len(deliveries) == 1 {
+			return errors.New("not yet")
+		}
+		cancel()
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(deliveries) != 2 {
+		t.Fatalf("got %d deliveries, want the nacked one and its redelivery", len(deliveries))
+	}
+	got := deliveries[1]
+	if got.SchemaVersion != ResultsSchemaVersion || got.JobName != sent.JobName || got.CampgroundID != sent.CampgroundID ||
+		!got.Arrival.Equal(sent.Arrival) || !got.Departure.Equal(sent.Departure) || len(got.Sites) != 2 || !got.Notified {
+		t.Errorf("received %+v, want %+v", got, sent)
+	}
+	if got.Attributes[foundAttribute] != "true" || got.Attributes[schemaVersionAttribute] != "1" {
+		t.Errorf("received attributes %v", got.Attributes)
+	}
+}
+
+// Subscribe acks a message it cannot decode, since it would never succeed,
+// and nacks one with a newer schema for an upgraded consumer; neither
+// reaches the handler.
+func TestSubscribeUndeliverable(t *testing.T) {
+	srv := startEmulator(t, "results")
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	topic := "projects/" + activeConfig.Project + "/topics/results"
+	garbled := srv.Publish(topic, []byte(`{"sites":"1001"}`), nil)
+	newer := srv.Publish(topic, []byte(`{"schema_version":2}`), map[string]string{schemaVersionAttribute: "2"})
+	good := srv.Publish(topic, []byte(`{"schema_version":1,"campground_id":"232447"}`), map[string]string{schemaVersionAttribute: "1"})
+
+	var mu sync.Mutex
+	handled := []string{}
+	done := make(chan error)
+	go func() {
+		done <- Subscribe(ctx, "results", func(r ResultsMessage) error {
+			mu.Lock()
+			defer mu.Unlock()
+			handled = append(handled, r.CampgroundID)
+			return nil
+		})
+	}()
+	// Acks reach the emulator asynchronously, so wait for all three
+	// messages to be settled before stopping.
+	for ctx.Err() == nil && (srv.Message(garbled).Acks == 0 || srv.Message(newer).Deliveries == 0 || srv.Message(good).Acks == 0) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	cancel()
+	if err := <-done; err != nil && ctx.Err() == nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(handled) != 1 || handled[0] != "232447" {
+		t.Errorf("handled %v, want only the good message", handled)
+	}
+	for id, wantAcks := range map[string]int{garbled: 1, newer: 0, good: 1} {
+		if msg := srv.Message(id); msg.Deliveries == 0 || msg.Acks != wantAcks {
+			t.Errorf("message %s delivered %d times and acked %d, want acked %d", msg.Data, msg.Deliveries, msg.Acks, wantAcks)
+		}
+	}
+}
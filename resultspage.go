@@ -0,0 +1,80 @@
+package scraper
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"os"
+
+	"cloud.google.com/go/storage"
+)
+
+// resultsPagePrefix is where results pages live in PAGES_BUCKET. The
+// bucket's lifecycle rule (see Bootstrap) deletes them after a few days.
+const resultsPagePrefix = "results/"
+
+// newResultsPage picks an unguessable object name for an alert's results
+// page, so its claim links can name the page before it is published. It
+// returns "" when PAGES_BUCKET is not set, which turns pages off.
+func newResultsPage() (string, error) {
+	if os.Getenv("PAGES_BUCKET") == "" {
+		return "", nil
+	}
+	token := make([]byte, 16)
+	if _, err := rand.Read(token); err != nil {
+		return "", err
+	}
+	return resultsPagePrefix + hex.EncodeToString(token) + ".html", nil
+}
+
+// publishResultsPage uploads the rendered email as a standalone page named
+// object in PAGES_BUCKET, so short-form channels can link to the full
+// results, and returns its URL. Only the page itself is made public: the
+// bucket cannot be listed, so one link does not lead to the others. The page
+// holds only the rendered results, never recipients.
+func publishResultsPage(ctx context.Context, object string, subject string, htmlContent string) (string, error) {
+	bucket := os.Getenv("PAGES_BUCKET")
+	client, err := storageClient()
+	if err != nil {
+		return "", err
+	}
+	if err := writePage(ctx, client.Bucket(bucket).Object(object), resultsPage(subject, htmlContent)); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("https://storage.googleapis.com/%s/%s", bucket, object), nil
+}
+
+// writePage stores page as object, readable by anyone with the link.
+func writePage(ctx context.Context, object *storage.ObjectHandle, page string) error {
+	w := object.NewWriter(ctx)
+	w.ContentType = "text/html; charset=utf-8"
+	w.CacheControl = "no-store"
+	w.PredefinedACL = "publicRead"
+	if _, err := w.Write([]byte(page)); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// resultsPage wraps an alert's rendered email in a standalone page that
+// search engines are asked not to index.
+func resultsPage(subject string, htmlContent string) string {
+	return "<!DOCTYPE html><html lang=\"en\"><head><meta charset=\"utf-8\">" +
+		"<meta name=\"robots\" content=\"noindex\"><title>" + html.EscapeString(subject) + "</title></head>" +
+		"<body><h1>" + html.EscapeString(subject) + "</h1>" + htmlContent + "</body></html>"
+}
+
+// linksResults reports whether any of notifiers is a short-form channel,
+// which links to the results page rather than carrying every result.
+func linksResults(notifiers []notifier) bool {
+	for _, n := range notifiers {
+		switch n.(type) {
+		case slackNotifier, twilioNotifier:
+			return true
+		}
+	}
+	return false
+}
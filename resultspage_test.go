@@ -0,0 +1,146 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// Pages go to PAGES_BUCKET alone, each made public on its own, and never
+// into RESULTS_BUCKET, which holds watch state.
+func TestPublishResultsPage(t *testing.T) {
+	gcs := useFakeGCS(t, "state")
+	t.Setenv("PAGES_BUCKET", "pages")
+
+	page, err := newResultsPage()
+	if err != nil {
+		t.Fatal(err)
+	}
+	url, err := publishResultsPage(context.Background(), page, "Sites at <Upper Pines>", "<p>A001</p>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	match := regexp.MustCompile(`^https://storage\.googleapis\.com/pages/(results/[0-9a-f]{32}\.html)$`).FindStringSubmatch(url)
+	if match == nil {
+		t.Fatalf("page URL %q", url)
+	}
+	if names := gcs.names("pages", ""); len(names) != 1 || names[0] != match[1] {
+		t.Errorf("pages bucket holds %v, want only %s", names, match[1])
+	}
+	if names := gcs.names("state", ""); len(names) != 0 {
+		t.Errorf("state bucket holds %v, want nothing", names)
+	}
+	if acl := gcs.acls["pages/"+match[1]]; acl != "publicRead" {
+		t.Errorf("page ACL %q, want publicRead", acl)
+	}
+	content := gcs.objects["pages/"+match[1]]
+	for _, want := range []string{`<meta name="robots" content="noindex">`, "<title>Sites at &lt;Upper Pines&gt;</title>", "<p>A001</p>"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("page lacks %q:\n%s", want, content)
+		}
+	}
+
+	// Another page gets another name.
+	if again, err := newResultsPage(); err != nil || again == page {
+		t.Errorf("second page %q, %v, want a new name", again, err)
+	}
+}
+
+func TestPublishResultsPageOff(t *testing.T) {
+	gcs := useFakeGCS(t, "state")
+	t.Setenv("PAGES_BUCKET", "")
+	if page, err := newResultsPage(); page != "" || err != nil {
+		t.Errorf("newResultsPage = %q, %v, want no page with PAGES_BUCKET unset", page, err)
+	}
+	sent := []alert{}
+	old := notifiersFor
+	notifiersFor = func(a alert) []notifier {
+		return []notifier{slackNotifier{webhook: "http://127.0.0.1:0"}, recordingNotifier{"camper@example.com", &sent}}
+	}
+	defer func() { notifiersFor = old }()
+	sendAlert(context.Background(), goldenAlert())
+	for name := range gcs.objects {
+		if strings.Contains(name, resultsPagePrefix) {
+			t.Errorf("stored page %s with pages off", name)
+		}
+	}
+	if len(sent) != 1 || sent[0].ResultsURL != "" {
+		t.Errorf("sent %+v, want one alert without a page", sent)
+	}
+}
+
+// Short-form channels link the one page published per alert; email carries
+// the results itself and does not.
+func TestResultsPageLinks(t *testing.T) {
+	for _, pages := range []string{"pages", ""} {
+		t.Run("PAGES_BUCKET="+pages, func(t *testing.T) {
+			gcs := useFakeGCS(t, "state")
+			t.Setenv("PAGES_BUCKET", pages)
+			useClock(t, time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			bodies := map[string]string{}
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.Contains(r.URL.Path, "/Messages.json") {
+					r.ParseForm()
+					bodies["sms"] = r.PostForm.Get("Body")
+					w.WriteHeader(http.StatusCreated)
+					return
+				}
+				var message slackMessage
+				json.NewDecoder(r.Body).Decode(&message)
+				bodies["slack"] = message.Text
+			}))
+			defer server.Close()
+			oldNotice, oldNotifiers := sendNoticeTo, notifiersFor
+			sendNoticeTo = func(ctx context.Context, to *mail.Email, subject string, plain string, html string) error {
+				bodies["email"] = plain + html
+				return nil
+			}
+			notifiersFor = func(a alert) []notifier {
+				return []notifier{
+					emailNotifier{to: mail.NewEmail("", "camper@example.com")},
+					slackNotifier{webhook: server.URL + "/slack", label: "test"},
+					twilioNotifier{sid: "AC000", token: "token", from: "+14155550199", to: "+14155550100", maxParts: 2, baseURL: server.URL},
+				}
+			}
+			defer func() { sendNoticeTo, notifiersFor = oldNotice, oldNotifiers }()
+
+			a := goldenAlert()
+			a.JobName = "projects/camp-finder/locations/us-central1/jobs/links-" + pages
+			if err := sendAlert(context.Background(), a); err != nil {
+				t.Fatal(err)
+			}
+			published := gcs.names("pages", resultsPagePrefix)
+			if pages == "" {
+				if len(published) != 0 {
+					t.Errorf("published %v with pages off", published)
+				}
+				for channel, body := range bodies {
+					if strings.Contains(body, "Full results") {
+						t.Errorf("%s links a results page with pages off: %s", channel, body)
+					}
+				}
+				return
+			}
+			if len(published) != 1 {
+				t.Fatalf("published %v, want one page for every channel", published)
+			}
+			url := "https://storage.googleapis.com/pages/" + published[0]
+			if !strings.Contains(bodies["slack"], "<"+url+"|Full results>") {
+				t.Errorf("Slack message lacks the page link: %s", bodies["slack"])
+			}
+			if !strings.Contains(bodies["sms"], "Full results: "+url) {
+				t.Errorf("text lacks the page link: %s", bodies["sms"])
+			}
+			if strings.Contains(bodies["email"], url) {
+				t.Errorf("email links the page")
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package scraper
+
+import (
+	"fmt"
+	"time"
+)
+
+// ScanWindow limits a watch to scanning between two local times of day,
+// written as "15:04". A window whose End is before its Start runs across
+// midnight, so {"22:00", "02:00"} scans late at night only. Start and End
+// are wall-clock times, so a window keeps its local hours across DST changes.
+type ScanWindow struct {
+	Start string
+	End   string
+}
+
+// contains reports whether t, converted to loc, falls within the window. The
+// start is inclusive and the end exclusive.
+func (w ScanWindow) contains(t time.Time, loc *time.Location) (bool, error) {
+	start, err := minuteOfDay(w.Start)
+	if err != nil {
+		return false, err
+	}
+	end, err := minuteOfDay(w.End)
+	if err != nil {
+		return false, err
+	}
+	local := t.In(loc)
+	now := local.Hour()*60 + local.Minute()
+	if start <= end {
+		return start <= now && now < end, nil
+	}
+	return now >= start || now < end, nil
+}
+
+func minuteOfDay(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("scan window time %q is not HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// outsideScanWindow reports whether the watch should skip this run. A watch
+// without a window, or with an invalid one, always scans; the campground's
+// zone comes from the watch and falls back to UTC.
+func outsideScanWindow(m MessageContent) bool {
+	if m.ScanWindow == nil {
+		return false
+	}
+	loc := time.UTC
+	if m.TimeZone != "" {
+		l, err := time.LoadLocation(m.TimeZone)
+		if err != nil {
+			logger.Printf("job %s: unknown time zone %q, using UTC for its scan window", m.Name, m.TimeZone)
+		} else {
+			loc = l
+		}
+	}
+	in, err := m.ScanWindow.contains(DefaultScraper.now(), loc)
+	if err != nil {
+		logger.Printf("job %s: ignoring scan window: %v", m.Name, err)
+		return false
+	}
+	return !in
+}
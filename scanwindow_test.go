@@ -0,0 +1,68 @@
+package scraper
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOutsideScanWindow(t *testing.T) {
+	pacific, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skip(err)
+	}
+	daytime := &ScanWindow{Start: "06:00", End: "23:00"}
+	overnight := &ScanWindow{Start: "22:00", End: "02:00"}
+	local := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2027, month, day, hour, minute, 0, 0, pacific)
+	}
+	utc := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2027, month, day, hour, minute, 0, 0, time.UTC)
+	}
+	tests := []struct {
+		name    string
+		window  *ScanWindow
+		zone    string
+		at      time.Time
+		outside bool
+	}{
+		{"no window", nil, "America/Los_Angeles", local(7, 14, 3, 0), false},
+		{"before the start", daytime, "America/Los_Angeles", local(7, 14, 5, 59), true},
+		{"at the start", daytime, "America/Los_Angeles", local(7, 14, 6, 0), false},
+		{"just before the end", daytime, "America/Los_Angeles", local(7, 14, 22, 59), false},
+		{"at the end", daytime, "America/Los_Angeles", local(7, 14, 23, 0), true},
+
+		{"overnight, before the start", overnight, "America/Los_Angeles", local(7, 14, 21, 59), true},
+		{"overnight, at the start", overnight, "America/Los_Angeles", local(7, 14, 22, 0), false},
+		{"overnight, before midnight", overnight, "America/Los_Angeles", local(7, 14, 23, 59), false},
+		{"overnight, at midnight", overnight, "America/Los_Angeles", local(7, 15, 0, 0), false},
+		{"overnight, before the end", overnight, "America/Los_Angeles", local(7, 15, 1, 59), false},
+		{"overnight, at the end", overnight, "America/Los_Angeles", local(7, 15, 2, 0), true},
+		{"overnight, midday", overnight, "America/Los_Angeles", local(7, 15, 12, 0), true},
+
+		// 13:30 UTC is 05:30 PST the day before clocks go forward on Mar
+		// 14, and 06:30 PDT that day.
+		{"the day before spring forward", daytime, "America/Los_Angeles", utc(3, 13, 13, 30), true},
+		{"the day of spring forward", daytime, "America/Los_Angeles", utc(3, 14, 13, 30), false},
+		{"across the skipped hour", &ScanWindow{Start: "01:00", End: "03:00"}, "America/Los_Angeles", utc(3, 14, 9, 59), false},
+		{"after the skipped hour", &ScanWindow{Start: "01:00", End: "03:00"}, "America/Los_Angeles", utc(3, 14, 10, 0), true},
+		// 01:30 comes twice on Nov 7, once in PDT and once in PST, and is in
+		// the window both times.
+		{"the first 01:30 of fall back", &ScanWindow{Start: "01:00", End: "02:00"}, "America/Los_Angeles", utc(11, 7, 8, 30), false},
+		{"the second 01:30 of fall back", &ScanWindow{Start: "01:00", End: "02:00"}, "America/Los_Angeles", utc(11, 7, 9, 30), false},
+		{"after fall back", &ScanWindow{Start: "01:00", End: "02:00"}, "America/Los_Angeles", utc(11, 7, 10, 0), true},
+		{"overnight across fall back", overnight, "America/Los_Angeles", utc(11, 7, 9, 59), false},
+
+		{"no zone is UTC", daytime, "", utc(7, 14, 5, 0), true},
+		{"unknown zone is UTC", daytime, "Pacific/Nowhere", utc(7, 14, 7, 0), false},
+		{"unreadable window always scans", &ScanWindow{Start: "6am", End: "23:00"}, "America/Los_Angeles", local(7, 14, 3, 0), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useClock(t, test.at)
+			m := MessageContent{Name: "camp-232447-scan-window", ScanWindow: test.window, TimeZone: test.zone}
+			if got := outsideScanWindow(m); got != test.outside {
+				t.Errorf("outsideScanWindow() at %v = %t, want %t", test.at.In(pacific), got, test.outside)
+			}
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package scraper
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+)
+
+// maxScrapeRequestBytes bounds a ScrapeHTTP body; a watch payload is a few
+// hundred bytes.
+const maxScrapeRequestBytes = 64 << 10
+
+// ScrapeResponse is the body ScrapeHTTP answers a successful run with.
+type ScrapeResponse struct {
+	Job       string        `json:"job"`
+	Outcome   string        `json:"outcome"`
+	Arrival   string        `json:"arrival,omitempty"`
+	Departure string        `json:"departure,omitempty"`
+	Sites     []WebhookSite `json:"sites"`
+	DryRun    bool          `json:"dry_run,omitempty"`
+	// Incomplete is set when the scan stopped before checking every
+	// campsite.
+	Incomplete bool `json:"incomplete,omitempty"`
+	JobDeleted bool `json:"job_deleted"`
+}
+
+// ScrapeHTTP is an HTTP Cloud Function running the watch POSTed as a
+// MessageContent exactly as ScrapeFromMessage would, and answering with the
+// sites found. With ?dryRun=true nothing is sent and the job is left alone.
+// Callers authenticate with "Authorization: Bearer <SCRAPE_API_KEY>".
+//
+// A payload that does not decode or validate is a 400, a failed scrape or
+// notification a 502, and anything else a 200, with an empty site list when
+// nothing is available.
+func ScrapeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, http.StatusMethodNotAllowed, "use POST")
+		return
+	}
+	key := os.Getenv("SCRAPE_API_KEY")
+	given := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if key == "" || subtle.ConstantTimeCompare([]byte(key), []byte(given)) != 1 {
+		writeProblem(w, http.StatusUnauthorized, "missing or wrong API key")
+		return
+	}
+	if configErr != nil {
+		writeProblem(w, http.StatusInternalServerError, "invalid configuration: "+configErr.Error())
+		return
+	}
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxScrapeRequestBytes))
+	if err != nil {
+		writeProblem(w, http.StatusRequestEntityTooLarge, "body is over 64 KiB")
+		return
+	}
+
+	run := &watchRun{dryRun: r.URL.Query().Get("dryRun") == "true"}
+	err = scrapeLogged(r.Context(), pubsub.Message{Data: body}, run)
+	switch {
+	case err != nil && run.outcome == outcomeInvalid:
+		writeProblem(w, http.StatusBadRequest, err.Error())
+		return
+	case errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded):
+		writeProblem(w, http.StatusGatewayTimeout, err.Error())
+		return
+	case err != nil:
+		writeProblem(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	resp := ScrapeResponse{
+		Job:        run.watch.Name,
+		Outcome:    run.outcome,
+		Sites:      webhookSites(run.alert),
+		DryRun:     run.dryRun,
+		Incomplete: run.alert.Partial != nil,
+		JobDeleted: run.deleted,
+	}
+	if !run.alert.Arrival.IsZero() {
+		resp.Arrival, resp.Departure = run.alert.Arrival.String(), run.alert.Departure.String()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
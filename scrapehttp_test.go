@@ -0,0 +1,112 @@
+package scraper_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// scrapeHTTP POSTs body to ScrapeHTTP at target with authorization, when
+// given, and returns the response.
+func scrapeHTTP(method string, target string, authorization string, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if authorization != "" {
+		r.Header.Set("Authorization", authorization)
+	}
+	w := httptest.NewRecorder()
+	scraper.ScrapeHTTP(w, r)
+	return w
+}
+
+// Requests without the API key, or whose payload does not decode or
+// validate, are refused before anything is scraped.
+func TestScrapeHTTPRefused(t *testing.T) {
+	t.Setenv("SCRAPE_API_KEY", "test-key")
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	valid, _ := json.Marshal(e2eWatch("scrape-http-refused"))
+	tests := []struct {
+		name          string
+		method        string
+		authorization string
+		body          string
+		want          int
+	}{
+		{name: "GET", method: http.MethodGet, authorization: "Bearer test-key", want: http.StatusMethodNotAllowed},
+		{name: "no key", method: http.MethodPost, body: string(valid), want: http.StatusUnauthorized},
+		{name: "wrong key", method: http.MethodPost, authorization: "Bearer other-key", body: string(valid), want: http.StatusUnauthorized},
+		{name: "key without Bearer", method: http.MethodPost, authorization: "Basic test-key", body: string(valid), want: http.StatusUnauthorized},
+		{name: "malformed body", method: http.MethodPost, authorization: "Bearer test-key", body: `{"Name": `, want: http.StatusBadRequest},
+		{name: "invalid watch", method: http.MethodPost, authorization: "Bearer test-key", body: `{"Campground": "232447"}`, want: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			w := scrapeHTTP(test.method, "/scrape", test.authorization, test.body)
+			if w.Code != test.want {
+				t.Errorf("status %d, want %d: %s", w.Code, test.want, w.Body)
+			}
+			if ct := w.Header().Get("Content-Type"); ct != "application/problem+json" {
+				t.Errorf("Content-Type %q, want a problem", ct)
+			}
+		})
+	}
+	if requests := h.Server.Requests(); len(requests) != 0 {
+		t.Errorf("refused requests fetched %v", requests)
+	}
+}
+
+// A good request scrapes the watch and answers with the sites found; a dry
+// run sends nothing and leaves the job alone.
+func TestScrapeHTTP(t *testing.T) {
+	t.Setenv("SCRAPE_API_KEY", "test-key")
+	tests := []struct {
+		name        string
+		target      string
+		wantAlerts  int
+		wantDeleted bool
+	}{
+		{name: "dry run", target: "/scrape?dryRun=true"},
+		{name: "run", target: "/scrape", wantAlerts: 1, wantDeleted: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(true))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("scrape-http-" + strings.Replace(test.name, " ", "-", -1))
+			body, _ := json.Marshal(m)
+			schedule(h, m.Name, body)
+
+			w := scrapeHTTP(http.MethodPost, test.target, "Bearer test-key", string(body))
+			if w.Code != http.StatusOK {
+				t.Fatalf("status %d: %s", w.Code, w.Body)
+			}
+			var resp scraper.ScrapeResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("decoding %s: %v", w.Body, err)
+			}
+			if resp.Job != m.Name || resp.Arrival != "2027-07-14" || resp.Departure != "2027-07-16" {
+				t.Errorf("answered %+v, want the watch's job and stay", resp)
+			}
+			if len(resp.Sites) != 1 || resp.Sites[0].ID != "1001" || resp.Sites[0].Name != "A001" {
+				t.Errorf("answered sites %+v, want A001", resp.Sites)
+			}
+			if resp.DryRun != (test.wantAlerts == 0) || resp.JobDeleted != test.wantDeleted {
+				t.Errorf("answered dry run %v, job deleted %v", resp.DryRun, resp.JobDeleted)
+			}
+			if n := len(h.Notifier.Alerts()); n != test.wantAlerts {
+				t.Errorf("got %d alerts, want %d", n, test.wantAlerts)
+			}
+			if deleted := h.Scheduler.Job(m.Name) == nil; deleted != test.wantDeleted {
+				t.Errorf("job deleted %v, want %v", deleted, test.wantDeleted)
+			}
+		})
+	}
+}
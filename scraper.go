@@ -23,207 +23,840 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io/ioutil"
-	"log"
-	"net/http"
-	"os"
 	"strings"
-	"sync"
-	"sync/atomic"
 	"time"
 
 	"cloud.google.com/go/pubsub"
-	scheduler "cloud.google.com/go/scheduler/apiv1"
-	"github.com/sendgrid/sendgrid-go"
 	"github.com/sendgrid/sendgrid-go/helpers/mail"
-	"google.golang.org/api/iterator"
-	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 const layoutISO = "2006-1-2"
 
-//Campground message
-type Campground struct {
-	Campsites map[string]Campsite
-}
-
-//Campsite campsite
-type Campsite struct {
-	CampsiteID     int    `json:"campsite_id"`
-	CampsiteType   string `json:"campsite_type"`
-	Availabilities map[time.Time]string
-}
+// Campground and Campsite are kept here for existing importers; they are
+// defined in the core package.
+type (
+	Campground = core.Campground
+	Campsite   = core.Campsite
+)
 
 // MessageContent is the payload of a Pub/Sub event.
 type MessageContent struct {
 	Name       string
 	Campground string
-	Arrival    string
-	Departure  string
+	// CampgroundName names the campground instead of giving its ID. It is
+	// resolved with recreation.gov's search when Campground is empty, and a
+	// name matching several campgrounds is rejected.
+	CampgroundName string
+	// Kind is "permit" to watch the permit whose ID is in Campground, such
+	// as a trailhead's entry quota, instead of a campground. Arrival up to
+	// Departure are then the acceptable entry dates and MinCapacity is the
+	// number of slots the group needs. Kind is "site-monitor" to follow the
+	// one campsite in CampsiteIDs from Arrival up to Departure and alert
+	// whenever any of its nights changes status; see monitorSite. Kind is
+	// "tour" to watch the ticketed tours, such as cave tours, of the
+	// facility whose ID is in Campground; see PartySize. Kind is
+	// "reservation-guard" to watch over a stay already booked at the one
+	// campsite in CampsiteIDs and warn when it closes; see guardReservation.
+	// The default is "campground".
+	Kind      string
+	Arrival   string
+	Departure string
+	// Cutoff is an optional RFC 3339 instant after which the watch gives up
+	// and deletes itself.
+	Cutoff string
+	// Release is set by CreateReleaseWatch to the RFC 3339 instant the
+	// stay's dates are expected to be released, and makes the notice sent at
+	// the Cutoff a release-day one.
+	Release string
+	// UserAgent and Headers override the recreation.gov request headers for
+	// this watch. They are ignored unless ALLOW_HEADER_OVERRIDES is "true".
+	UserAgent string
+	Headers   map[string]string
+	// Priority lists favourite campsite IDs, best first. When set, alerts
+	// lead with the best available one as the site to book first. Ignored
+	// with MaxNights or MinConsecutiveNights, whose longest stays come first.
+	Priority []string
+	// MaxNights switches the watch to flexible departure: Departure is
+	// ignored and the alert reports, per site, the longest stay from Arrival
+	// of at least MinNights and at most MaxNights nights.
+	MinNights int
+	MaxNights int
+	// MinConsecutiveNights accepts sites open for only part of the stay:
+	// any site with at least this many consecutive available nights between
+	// Arrival and Departure matches, and the alert gives the open dates and
+	// lists sites open every night first. Zero requires every night.
+	MinConsecutiveNights int
+	// IncludeWalkUp counts first-come, first-served ("Open") nights as
+	// available. They cannot be booked ahead, so by default they are not.
+	IncludeWalkUp bool
+	// MinCapacity drops sites that take fewer people, read from each
+	// matching site's details. Sites whose capacity is unknown are kept and
+	// marked as such. Ignored for pair watches.
+	MinCapacity int
+	// PartySize is how many tickets a tour watch needs from one slot, on a
+	// date from Arrival up to Departure and, when TourTimes is set,
+	// starting within one of its local times of day, in TimeZone.
+	PartySize int
+	TourTimes []ScanWindow
+	// RequiredAttributes keeps sites whose details list each attribute, by
+	// name in any case, with a matching value: "Yes" compares text ignoring
+	// case, ">=30" compares the number the value starts with, and "" only
+	// requires the attribute. Sites not listing one are dropped and counted
+	// in the alert. Ignored for pair watches.
+	RequiredAttributes map[string]string
+	// MaxNightlyPrice, in dollars, drops sites whose fee for any night of
+	// the stay is higher, read from each matching site's details or the
+	// campground's fees. Sites whose price is unknown are kept and marked as
+	// such. Matching sites are priced whether or not it is set, and listed
+	// cheapest first unless Priority ranks them. Ignored for pair watches
+	// and flexible windows.
+	MaxNightlyPrice float64
+	// ScanWindow restricts scanning to local hours in TimeZone, the
+	// campground's IANA zone (UTC when empty). Runs outside it do nothing.
+	ScanWindow *ScanWindow
+	TimeZone   string
+	// Locale, such as "en" or "fr-CA", is the language alerts write dates
+	// in: en, es, fr or de, defaulting to English. Times in alerts are shown
+	// in TimeZone, or Pacific time when it is unset.
+	Locale string
+	// AdjacentPairs reports pairs of neighbouring sites in the same loop,
+	// and RequiredPairs exact pairs of campsite IDs, instead of single
+	// sites. Each alert line is then a pair.
+	AdjacentPairs bool
+	RequiredPairs []core.Pair
+	// FindAdjacentSites reports clusters of at least MinSites (default 2)
+	// available sites in one loop whose site numbers are at most MaxSiteGap
+	// (default 3) apart, such as "Loop B: B014, B015, B017", instead of
+	// single sites. When there is no such cluster the single sites are
+	// reported with a note.
+	FindAdjacentSites bool
+	MinSites          int
+	MaxSiteGap        int
+	// SiteTypes keeps only campsites whose type matches one entry, and
+	// ExcludeTypes drops those matching any; see core.SiteFilter.
+	SiteTypes    []string
+	ExcludeTypes []string
+	// CampsiteIDs restricts the watch to these campsites, by the numeric
+	// recreation.gov ID in each site's address. The alert says which of
+	// them are available, which are booked and which the campground does
+	// not have. Not supported with Campgrounds or pair and cluster watches.
+	CampsiteIDs []string
+	// Campgrounds watches several campgrounds with one job and one alert.
+	// When set, Campground is ignored.
+	Campgrounds []string
+	// Nights switches the watch to a flexible window: any Nights
+	// consecutive nights from WindowStart up to WindowEnd, the last
+	// departure, that include every RequiredWeekdays day ("Fri", "Saturday").
+	// Arrival and Departure are ignored.
+	Nights           int
+	WindowStart      string
+	WindowEnd        string
+	RequiredWeekdays []string
+	// A flexible window's months are fetched in the order the campground's
+	// history found them most open, and StopAtFirstMatch stops fetching
+	// once the months so far hold a run. Its alerts list only those runs,
+	// but a long window then costs fewer requests. Once a month comes back
+	// with no night released yet, it and later months are skipped for a
+	// day, and the recipient is told once.
+	StopAtFirstMatch bool
+	// KeepJob makes the watch persistent: the job is not deleted after an
+	// alert, and later runs alert again only when the available sites and
+	// nights differ from those last reported.
+	KeepJob bool
+	// TrackChanges compares each scan's sites with the previous scan's, and
+	// alerts lead with the sites that have just opened, such as after a
+	// cancellation. OnlyNewlyAvailable also suppresses alerts in which no
+	// site is new, and implies TrackChanges.
+	TrackChanges       bool
+	OnlyNewlyAvailable bool
+	// QuietHoursStart and QuietHoursEnd, such as "22:00" and "07:00", hold
+	// alerts during those local hours; the window may cross midnight.
+	// Findings are collected and sent as one digest by the first run after
+	// the window. MaxNotificationsPerDay caps the alerts sent per local day;
+	// zero is unlimited. Both use TimeZone, else the TZ environment
+	// variable, else UTC.
+	QuietHoursStart        string
+	QuietHoursEnd          string
+	MaxNotificationsPerDay int
+	// NotifyEmail and NotifyName address the watch's emails. Without them
+	// email goes to NOTIFY_EMAIL, or the deployment's own address.
+	NotifyEmail string
+	NotifyName  string
+	// NotifyPhone is an E.164 number, such as +14155550100, to text alerts
+	// to. It needs TWILIO_SID, TWILIO_TOKEN and TWILIO_FROM.
+	NotifyPhone string
+	// Contact names one of Owner's contacts to alert instead of NotifyEmail,
+	// NotifyName and NotifyPhone. It is looked up on every run, so editing
+	// the contact changes where the watch alerts; see Contact. Owner is
+	// whose watch it is, such as the email address of the person managing
+	// it, and may be empty for the deployment's own contacts.
+	Contact string
+	Owner   string
+	// ExpireAfter is "departure" to keep scanning until the end of the
+	// departure day; by default the watch expires at the end of its arrival
+	// day. Either is in TimeZone, or Pacific time when that is unset.
+	ExpireAfter string
+	// WebhookURL receives each alert as signed JSON; see WebhookPayload.
+	// It needs WEBHOOK_SECRET.
+	WebhookURL string
+	// IncludeWeather adds the National Weather Service forecast for each
+	// night of the stay to alerts, when the stay is within the forecast's
+	// week. It applies to single-campground watches only.
+	IncludeWeather bool
+	// AnnounceClaims tells the watch's recipients, by email and Slack,
+	// whenever someone claims a site through an alert's "I'm booking this"
+	// link; see ClaimSite.
+	AnnounceClaims bool
+	// SeasonShift moves a watch whose stay turns out to be outside the
+	// campground's operating season to the nearest stay inside it, by whole
+	// weeks so the weekdays stay the same, instead of removing it. It
+	// applies to watches with fixed Arrival and Departure dates.
+	SeasonShift bool
+
+	// slackWebhook is the resolved contact's Slack webhook; see
+	// resolveContact.
+	slackWebhook string
 }
 
-// ScrapeFromMessage consumes a Pub/Sub message.
+// recipient is who the watch's emails go to.
+func (m MessageContent) recipient() *mail.Email {
+	if m.NotifyEmail != "" {
+		return mail.NewEmail(m.NotifyName, m.NotifyEmail)
+	}
+	return defaultRecipient()
+}
+
+// windowSearch returns the watch's flexible-window search.
+func (m MessageContent) windowSearch() (core.WindowSearch, error) {
+	s := core.WindowSearch{WindowStart: stayDate(m.WindowStart), WindowEnd: stayDate(m.WindowEnd), Nights: m.Nights}
+	for _, name := range m.RequiredWeekdays {
+		d, err := core.ParseWeekday(name)
+		if err != nil {
+			return s, err
+		}
+		s.RequiredWeekdays = append(s.RequiredWeekdays, d)
+	}
+	return s, nil
+}
+
+// siteFilter returns the watch's campsite type filter.
+func (m MessageContent) siteFilter() core.SiteFilter {
+	return core.SiteFilter{Types: m.SiteTypes, Exclude: m.ExcludeTypes, Sites: m.CampsiteIDs}
+}
+
+// ScrapeFromMessage consumes a Pub/Sub message. Every outbound call shares
+// ctx, bounded by the configured Timeout, so a run past its deadline gives up
+// rather than being killed mid-request. Each run ends with one summary entry
+// to DefaultScraper.Log.
 func ScrapeFromMessage(ctx context.Context, m pubsub.Message) error {
+	return scrapeLogged(ctx, m, &watchRun{})
+}
+
+// scrapeLogged runs scrapeMessage, logs the run's summary entry and, when
+// the campground was scraped, publishes the results.
+func scrapeLogged(ctx context.Context, m pubsub.Message, run *watchRun) error {
+	start := DefaultScraper.now()
+	if DefaultScraper.ReplayDir != "" {
+		run.dryRun = true
+	}
+	statuses := &core.StatusCounts{}
+	scrapeCtx := core.WithStatusCounts(ctx, statuses)
+	// History is collected whether or not it is exported, as it also
+	// tracks how long openings last.
+	history := &historyLog{}
+	if !run.dryRun {
+		scrapeCtx = withHistory(scrapeCtx, history)
+	}
+	err := scrapeMessage(scrapeCtx, m, run)
+	elapsed := DefaultScraper.since(start)
+	if !run.dryRun {
+		rows := history.take(run.watch.Name)
+		booked := DefaultScraper.trackOpenings(ctx, rows)
+		if DefaultScraper.History != nil {
+			// Flushed before the function returns, as the instance may be
+			// frozen or stopped straight after.
+			DefaultScraper.History.Add(ctx, rows...)
+			DefaultScraper.History.AddBookings(ctx, booked)
+			DefaultScraper.History.Flush(ctx)
+		}
+	}
+	if DefaultScraper.Log != nil {
+		DefaultScraper.Log.Log(run.entry(err, elapsed))
+	}
+	if DefaultScraper.Metrics != nil && !run.dryRun {
+		DefaultScraper.Metrics.Record(ctx, run.metrics(err, elapsed, statuses.Counts()))
+	}
+	if run.scraped() && !run.dryRun {
+		publishResults(ctx, resultsMessage(run.alert, run.notified, elapsed))
+	}
+	if run.registered && !run.dryRun {
+		recordWatchResult(ctx, run, err)
+	}
+	return err
+}
+
+// scrapeMessage is the body of ScrapeFromMessage, shared with ScrapeHTTP. In
+// a dry run it scrapes and records the alert on run, but sends nothing and
+// leaves the job alone.
+func scrapeMessage(ctx context.Context, m pubsub.Message, run *watchRun) error {
+	if configErr != nil {
+		run.outcome = outcomeInvalid
+		return fmt.Errorf("invalid configuration: %w", configErr)
+	}
+	if activeConfig.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, activeConfig.Timeout)
+		defer cancel()
+	}
 	messageContent := MessageContent{}
-	err := json.Unmarshal([]byte(m.Data), &messageContent)
-	if err != nil {
-		log.Println(err)
+	if err := json.Unmarshal([]byte(m.Data), &messageContent); err != nil {
+		run.outcome = outcomeInvalid
+		return fmt.Errorf("decoding watch payload: %w", err)
+	}
+	run.watch = messageContent
+	// A registered watch's job carries only its ID; the payload has
+	// already decoded, so the reference does too.
+	var ref watchRef
+	json.Unmarshal([]byte(m.Data), &ref)
+	if messageContent.Name == "" && ref.WatchID != "" {
+		registered, done, err := loadRegisteredWatch(ctx, run, ref.WatchID)
+		if done {
+			return err
+		}
+		messageContent = registered
 	}
 	if len(messageContent.Name) <= 0 {
-		return errors.New("this is an error")
+		run.outcome = outcomeInvalid
+		return fmt.Errorf("rejecting watch payload: %w", messageContent.Validate())
 	}
-	id := messageContent.Campground
 	jobName := messageContent.Name
-	arrival, _ := time.Parse(layoutISO, messageContent.Arrival)
-	departure, _ := time.Parse(layoutISO, messageContent.Departure)
-	available := ScrapeAvailability(id, arrival, departure)
-	if len(available) > 0 {
-		sendEmail(id, available, arrival.Format("Mon Jan 2"), departure.Format("Mon Jan 2"))
-		deleteJob(jobName)
+	if err := messageContent.resolveCampground(ctx); err != nil {
+		var ambiguous *core.AmbiguousCampgroundError
+		var none *core.NoCampgroundError
+		run.outcome = outcomeScrapeError
+		if errors.As(err, &ambiguous) || errors.As(err, &none) {
+			run.outcome = outcomeInvalid
+		}
+		return fmt.Errorf("job %s: %w", jobName, err)
 	}
-	return nil
+	if err := messageContent.resolveContact(ctx); err != nil {
+		run.contactProblem = err.Error()
+		if !run.dryRun {
+			reportContactProblem(ctx, jobName, err)
+		}
+	}
+	run.watch = messageContent
+	if blocked, why := currentControl(ctx).Blocks(messageContent.Campground); blocked {
+		logger.Printf("job %s: paused by operator: %s", jobName, why)
+		run.outcome = outcomePaused
+		return nil
+	}
+	if run.dryRun && (cutoffPassed(messageContent) || messageContent.expired()) {
+		run.outcome = outcomeCutoff
+		if messageContent.expired() {
+			run.outcome = outcomeExpired
+		}
+		return nil
+	}
+	if cutoffPassed(messageContent) {
+		summary := buildWatchSummary(ctx, messageContent, WatchCutoff, nil)
+		if err := sendNoLuckNotice(ctx, messageContent, summary); err != nil {
+			logger.Println(err)
+		}
+		recordClosedWatch(ctx, summary)
+		return run.deleteJob(ctx, outcomeCutoff)
+	}
+	if messageContent.expired() {
+		logger.Printf("job %s: dates have passed, expiring", jobName)
+		return expireWatch(ctx, messageContent, run)
+	}
+	// Validated after the cutoff and expiry checks so a watch whose dates
+	// have passed still closes itself.
+	if err := messageContent.Validate(); err != nil {
+		run.outcome = outcomeInvalid
+		return fmt.Errorf("job %s: rejecting watch payload: %w", jobName, err)
+	}
+	rehearsal := m.Attributes[modeAttribute] == modeRehearse
+	if until, ok := snoozed(m.Attributes); ok && !rehearsal {
+		logger.Printf("job %s: snoozed until %s", jobName, until.Format(time.RFC3339))
+		run.outcome = outcomeSnoozed
+		return nil
+	}
+	if !rehearsal && outsideScanWindow(messageContent) {
+		run.outcome = outcomeOutsideScan
+		return nil
+	}
+	if messageContent.isSiteMonitor() {
+		return DefaultScraper.monitorSite(ctx, messageContent, run, rehearsal)
+	}
+	if messageContent.isReservationGuard() {
+		return DefaultScraper.guardReservation(ctx, messageContent, run, rehearsal)
+	}
+	var a alert
+	var err error
+	switch {
+	case messageContent.isPermit():
+		a, err = DefaultScraper.matchPermit(ctx, messageContent, rehearsal)
+	case messageContent.isTour():
+		a, err = DefaultScraper.matchTour(ctx, messageContent, rehearsal)
+	case len(messageContent.Campgrounds) > 0:
+		a, err = DefaultScraper.matchCampgrounds(ctx, messageContent, rehearsal)
+	default:
+		a, err = DefaultScraper.matchWatch(ctx, DefaultScraper.providerFor(messageContent), messageContent, rehearsal)
+	}
+	run.sites = len(a.Sites)
+	run.alert = a
+	if errors.Is(err, errCampgroundsPaused) {
+		logger.Printf("job %s: paused by operator: %v", jobName, err)
+		run.outcome = outcomePaused
+		return nil
+	}
+	var closed *core.SeasonClosedError
+	if errors.As(err, &closed) && run.dryRun {
+		run.outcome = outcomeSeasonClosed
+		return nil
+	}
+	if errors.As(err, &closed) && !rehearsal {
+		logger.Println(closed)
+		return closeOutOfSeason(ctx, run, messageContent, closed)
+	}
+	if a.Partial == nil && errors.Is(err, core.ErrBlocked) {
+		// A retry would only meet the same challenge, so the run ends
+		// quietly and the next scheduled one tries again.
+		logger.Printf("job %s: backing off: %v", jobName, err)
+		run.outcome = outcomeBlocked
+		return nil
+	}
+	if a.Partial != nil {
+		logger.Println(a.Partial)
+	} else if err != nil && !errors.As(err, &closed) {
+		// Returning the error lets Cloud Functions retry, rather than
+		// mistaking a failed scrape for no availability.
+		run.outcome = outcomeScrapeError
+		return fmt.Errorf("job %s: scraping campground %s: %w", jobName, messageContent.Campground, err)
+	}
+	if !rehearsal && !run.dryRun {
+		paceForRelease(ctx, messageContent, a, m.Attributes)
+	}
+	if a.Requested.allMissing() {
+		run.outcome = outcomeInvalid
+		return fmt.Errorf("job %s: %w", jobName, &ValidationError{Field: "CampsiteIDs", Value: strings.Join(a.Requested.Missing, ","),
+			Reason: "are not campsites of campground " + messageContent.Campground})
+	}
+	if rehearsal {
+		logger.Printf("rehearsal for job %s: %d real sites found", jobName, len(a.Sites)-1)
+		run.sites = len(a.Sites) - 1
+	}
+	return DefaultScraper.deliverAlert(ctx, messageContent, a, run, rehearsal)
 }
 
-//TestPub is just an example of publishing to google pub/sub
-func TestPub(available []string) error {
-	projectID := "camp-finder-258618"
-	topicID := "TEST_PUB_TOPIC"
-	ctx := context.Background()
-	client, err := pubsub.NewClient(ctx, projectID)
-	if err != nil {
-		return fmt.Errorf("pubsub.NewClient: %v", err)
-	}
-
-	var wg sync.WaitGroup
-	var totalErrors uint64
-	t := client.Topic(topicID)
-
-	result := t.Publish(ctx, &pubsub.Message{
-		Data: []byte("Found these " + strings.Join(available, ",")),
-	})
-	wg.Add(1)
-	go func(res *pubsub.PublishResult) {
-		defer wg.Done()
-		// The Get method blocks until a server-generated ID or
-		// an error is returned for the published message.
-		_, err := res.Get(ctx)
-		if err != nil {
-			// Error handling code can be added here.
-			log.Println(fmt.Sprintf("Failed to publish: %v", err))
-			atomic.AddUint64(&totalErrors, 1)
-			return
+// deliverAlert takes a watch's matched alert the rest of the way: change
+// tracking, quiet hours and the daily cap, the idempotency claim and the
+// notifiers, then keeps or deletes the job as the watch asks. Ingest shares
+// it, so an ingested result is treated exactly like a scrape.
+func (s *Scraper) deliverAlert(ctx context.Context, m MessageContent, a alert, run *watchRun, rehearsal bool) error {
+	jobName := m.Name
+	a.ContactProblem = run.contactProblem
+	if m.tracksChanges() && !rehearsal {
+		diff := diffScan(ctx, m, a, !run.dryRun)
+		a.Diff = &diff
+		a.Sites = leadWithNew(a.Sites, diff)
+		run.alert = a
+		if m.OnlyNewlyAvailable && len(a.Sites) > 0 && len(diff.NewlyAvailable) == 0 {
+			logger.Printf("job %s: no newly available sites, not notifying", jobName)
+			run.outcome = outcomeNothingNew
+			return nil
+		}
+	}
+	if run.dryRun {
+		run.outcome = outcomeNoneFound
+		if len(a.Sites) > 0 {
+			run.outcome = outcomeFound
+			if s.ReplayDir != "" {
+				logReplayedAlert(a)
+			}
+		}
+		return nil
+	}
+	gated := m.limitsDelivery() && !rehearsal
+	var delivery deliveryState
+	if gated {
+		state, held, capped, err := gateDelivery(ctx, m, &a)
+		switch {
+		case err != nil:
+			logger.Printf("%v; alerting without quiet hours or a daily cap", err)
+			gated = false
+		case held:
+			run.alert = a
+			run.outcome = outcomeHeld
+			return nil
+		case capped:
+			run.outcome = outcomeCapped
+			return nil
 		}
-	}(result)
-	wg.Wait()
-	if totalErrors > 0 {
-		return fmt.Errorf(" messages did not publish successfully")
+		delivery = state
+		run.alert = a
+	}
+	if len(a.Sites) > 0 {
+		s.describeAlert(ctx, m, &a)
+		s.attachForecast(ctx, m, &a)
+	}
+	if m.KeepJob && !rehearsal {
+		run.outcome = outcomeKept
+		sent, err := notifyIfChanged(ctx, m, a)
+		run.notified = sent
+		if sent && gated {
+			recordDelivery(ctx, m, delivery)
+		}
+		return err
+	}
+	if len(a.Sites) > 0 {
+		if !rehearsal && !claimAlert(ctx, a) {
+			// A redelivery of a run that is sending, or has sent, this
+			// alert. That run deletes the job once it has sent; deleting
+			// it here could end the watch before a failed send is retried.
+			logger.Printf("job %s: these results were already alerted, not sending again", jobName)
+			run.outcome = outcomeDuplicate
+			return nil
+		}
+		if !rehearsal {
+			summary := buildWatchSummary(ctx, m, WatchFound, a.Sites)
+			a.Closing = &summary
+		}
+		if err := sendAlert(ctx, a); err != nil {
+			// Channels that did get the alert are skipped on the retry.
+			if !rehearsal {
+				releaseAlert(ctx, a)
+			}
+			run.outcome = outcomeNotifyFailed
+			return fmt.Errorf("job %s: sending alerts: %w", jobName, err)
+		}
+		run.outcome = outcomeNotified
+		run.notified = true
+		if gated {
+			recordDelivery(ctx, m, delivery)
+		}
+		if !rehearsal {
+			recordClosedWatch(ctx, *a.Closing)
+			return run.deleteJob(ctx, outcomeNotified)
+		}
+		return nil
+	}
+	run.outcome = outcomeNoneFound
+	if a.Class == core.ClassNotYetReleased {
+		run.outcome = outcomeNotReleased
 	}
 	return nil
 }
 
-//ScrapeAvailability scrape recreation.gov for the campground and dates specified
-func ScrapeAvailability(campgroundID string, arrival time.Time, departure time.Time) []string {
-	firstOfMonth := time.Date(arrival.Year(), arrival.Month(), 1, 0, 0, 0, 0, time.UTC)
-	url := fmt.Sprintf("https://www.recreation.gov/api/camps/availability/campground/%s/month?start_date=%s",
-		campgroundID, firstOfMonth.Format("2006-01-02T15:04:05.999999Z"))
-	response, _ := http.Get(url)
-	campground := Campground{}
-	data, _ := ioutil.ReadAll(response.Body)
-	json.Unmarshal([]byte(data), &campground)
-	availableSites := getAvailableSites(campground, arrival, departure)
-	return availableSites
-}
-
-func getAvailableSites(campground Campground, arrival time.Time, departure time.Time) []string {
-	dates := getDates(arrival, departure)
-
-	count := 0
-	campsiteNames := []string{}
-	for siteID, site := range campground.Campsites {
-		for idx, date := range dates {
-			if site.Availabilities[date] != "Available" {
-				break
-				//fmt.Println(id + " site available on " + date.Format("Mon Jan 2"))
-			} else if idx == len(dates)-1 {
-				count++
-				campsiteNames = append(campsiteNames, siteID)
+// matchWatch scrapes p for the watch in m and builds the alert it would send,
+// without any side effects. The alert has no Sites when nothing matched. The
+// error is whatever the scrape returned; a *core.PartialResultError is also
+// recorded on the alert.
+func (s *Scraper) matchWatch(ctx context.Context, p core.Provider, m MessageContent, rehearsal bool) (alert, error) {
+	arrival, departure := stayDate(m.Arrival), stayDate(m.Departure)
+	a := alert{
+		JobName:      m.Name,
+		Recipient:    m.recipient(),
+		NotifyPhone:  m.NotifyPhone,
+		SlackWebhook: m.slackWebhook,
+		TextFirst:    m.Cutoff != "",
+		WebhookURL:   m.WebhookURL,
+		CampgroundID: m.Campground,
+		Arrival:      arrival,
+		Departure:    departure,
+		Rehearsal:    rehearsal,
+		ScannedAt:    s.now().UTC(),
+	}
+
+	var available []string
+	var err error
+	unknown, checked := &core.StatusSet{}, &core.StatusSet{}
+	p = core.StatusProvider{Provider: p, WalkUp: m.IncludeWalkUp, Unknown: unknown, Sites: checked}
+	defer func() {
+		if values := unknown.Values(); len(values) > 0 {
+			logger.Printf("job %s: campground %s reported unknown availability statuses %q, treated as unavailable", m.Name, m.Campground, values)
+		}
+	}()
+	filter := m.siteFilter()
+	unfiltered := p
+	if !filter.IsZero() {
+		p = core.FilteredProvider{Provider: p, Filter: filter}
+	}
+	if m.Nights > 0 {
+		var search core.WindowSearch
+		if search, err = m.windowSearch(); err == nil {
+			a.Arrival, a.Departure = search.WindowStart, search.WindowEnd
+			search.Prior, search.StopAtFirst = s.monthPrior(ctx, m.Name, m.Campground, search.WindowStart, search.WindowEnd), m.StopAtFirstMatch
+			search.Horizon = s.horizons.get(m.Campground, s.now())
+			var plan core.FetchPlan
+			a.Runs, plan, err = core.ScrapeRunsPlanned(ctx, p, m.Campground, search)
+			logFetchPlan(m.Name, plan)
+			if !plan.Horizon.Equal(search.Horizon) {
+				logger.Printf("job %s: campground %s has released no nights from %s", m.Name, m.Campground, plan.Horizon.Format("2006-01"))
+				s.horizons.set(m.Campground, plan.Horizon, s.now())
 			}
+			if !plan.Horizon.IsZero() && plan.Horizon.Before(search.WindowEnd.Time()) && !rehearsal {
+				noticeBeyondHorizon(ctx, m, plan.Horizon)
+			}
+		}
+		available = runSites(a.Runs)
+	} else if m.MaxNights > 0 {
+		a.Departure = arrival.AddDays(m.MaxNights)
+		available, a.StayNights, err = scrapeStays(ctx, p, m, arrival)
+	} else if m.MinConsecutiveNights > 0 {
+		var runs []core.Run
+		runs, err = core.ScrapePartialStays(ctx, p, m.Campground, arrival, departure, m.MinConsecutiveNights)
+		a.OpenRanges = map[string]core.Run{}
+		for _, run := range runs {
+			available = append(available, run.Site)
+			a.OpenRanges[run.Site] = run
+		}
+	} else if m.FindAdjacentSites {
+		var clusters []core.Cluster
+		search := core.ClusterSearch{MinSites: m.MinSites, MaxGap: m.MaxSiteGap}
+		clusters, available, err = core.ScrapeClusters(ctx, p, m.Campground, arrival, departure, search)
+		if len(clusters) > 0 {
+			available = nil
+			for _, c := range clusters {
+				available = append(available, c.String())
+			}
+		} else if len(available) > 0 {
+			a.ClusterNote = clusterNote(search)
+		}
+	} else if m.AdjacentPairs || len(m.RequiredPairs) > 0 {
+		var pairs []core.Pair
+		pairs, err = core.ScrapePairs(ctx, p, m.Campground, arrival, departure, m.RequiredPairs, m.AdjacentPairs)
+		for _, pair := range pairs {
+			available = append(available, pair.String())
 		}
+	} else {
+		var result core.AvailabilityResult
+		result, err = core.ScrapeDetailed(ctx, unfiltered, m.Campground, arrival, departure)
+		if err == nil && len(result.Sites) == 0 && !m.isPermit() {
+			err = s.outOfSeason(ctx, m, arrival, departure)
+		}
+		filtered := result.Filter(filter)
+		available = filtered.Sites
+		a.Class, a.LastUnreleased = filtered.Class, filtered.LastUnreleased
+		a.SiteTypes, a.SiteNames = map[string]string{}, map[string]string{}
+		for _, summary := range filtered.Summaries {
+			if summary.CampsiteType != "" {
+				a.SiteTypes[summary.CampsiteID] = summary.CampsiteType
+			}
+			if summary.Site != "" {
+				a.SiteNames[summary.CampsiteID] = summary.Site
+			}
+			if summary.Remaining > 0 {
+				if a.Remaining == nil {
+					a.Remaining = map[string]int{}
+				}
+				a.Remaining[summary.CampsiteID] = summary.Remaining
+			}
+		}
+		if err == nil && len(available) == 0 {
+			a.NoMatch = filtered.Summary()
+			logger.Printf("job %s: %s", m.Name, a.NoMatch)
+			if len(result.Sites) > 0 {
+				logger.Printf("job %s: %s", m.Name, filteredOutNote(filter, len(result.Sites)))
+			}
+		}
+	}
+	errors.As(err, &a.Partial)
+	var unlisted map[string]bool
+	if !m.groupsSites() && len(available) > 0 && (m.MinCapacity > 0 || len(m.RequiredAttributes) > 0 || a.Runs == nil) {
+		unlisted = s.unlistedSites(ctx, m.Name, m.Campground, available)
+	}
+	if m.MinCapacity > 0 && !m.groupsSites() && len(available) > 0 {
+		available, a.UnknownCapacity = s.filterByCapacity(ctx, m.Name, available, m.MinCapacity, unlisted)
+		a.Runs = keepRuns(a.Runs, available)
+	}
+	if len(m.RequiredAttributes) > 0 && !m.groupsSites() && len(available) > 0 {
+		available, a.UnknownAttributes, a.MissingAttributes = s.filterByAttributes(ctx, m.Name, available, m.RequiredAttributes, unlisted)
+		a.Runs = keepRuns(a.Runs, available)
+	}
+	for _, site := range available {
+		if unlisted[site] {
+			if a.UnknownAttributes == nil {
+				a.UnknownAttributes = map[string]bool{}
+			}
+			a.UnknownAttributes[site] = true
+		}
+	}
+	// Prices are an enrichment unless the watch filters on them.
+	if !m.groupsSites() && a.Runs == nil && len(available) > 0 && (m.MaxNightlyPrice > 0 || enrichBudget(ctx, m.Name, "fees", &a)) {
+		a.Prices = s.priceStays(ctx, m.Name, m.Campground, available, siteStays(a, available), unlisted)
+		if m.MaxNightlyPrice > 0 {
+			available = filterByPrice(available, a.Prices, core.PriceCents(m.MaxNightlyPrice))
+		} else if len(a.Prices) == 0 {
+			a.Prices = nil
+		}
+		if a.StayNights == nil && a.OpenRanges == nil && len(m.Priority) == 0 {
+			available = cheapestFirst(available, a.Prices)
+		}
+	}
+	// Without a priority, sites keep the order found, which may be by price.
+	// Partial stays keep theirs too, so full stays stay first.
+	if a.StayNights == nil && a.OpenRanges == nil && len(m.Priority) > 0 {
+		available = core.RankSites(available, m.Priority)
+		if len(available) > 0 {
+			a.Primary = available[0]
+		}
+	}
+	if len(m.CampsiteIDs) > 0 {
+		a.Requested = requestedSitesFor(m.CampsiteIDs, checked.Values(), available)
+		if len(a.Requested.Missing) > 0 {
+			logger.Printf("job %s: campground %s has no campsites %s", m.Name, m.Campground, strings.Join(a.Requested.Missing, ", "))
+		}
+	}
+	if rehearsal {
+		available = append([]string{rehearsalLabel}, available...)
 	}
-	return campsiteNames
+	if len(available) > 0 {
+		a.Sites = available
+	}
+	a.Checked = len(checked.Values())
+	return a, err
 }
 
-func getDates(startDate time.Time, endDate time.Time) []time.Time {
-	dates := []time.Time{}
+// groupsSites reports whether m's alerts list pairs or clusters of sites
+// rather than campsite IDs, which the per-site filters cannot apply to.
+func (m MessageContent) groupsSites() bool {
+	return m.AdjacentPairs || len(m.RequiredPairs) > 0 || m.FindAdjacentSites
+}
 
-	for day := 0; day < int(endDate.Sub(startDate).Hours()/24); day++ {
-		hours := fmt.Sprintf("%dh", 24*day)
-		dayDuration, _ := time.ParseDuration(hours)
-		dates = append(dates, startDate.Add(dayDuration))
+// clusterNote explains that the sites listed are single because none were
+// close enough together.
+func clusterNote(search core.ClusterSearch) string {
+	minSites, maxGap := search.MinSites, search.MaxGap
+	if minSites < 2 {
+		minSites = 2
 	}
-	return dates
+	if maxGap <= 0 {
+		maxGap = core.DefaultMaxSiteGap
+	}
+	return fmt.Sprintf("No %d available sites are within %d site numbers of each other in one loop, so these are single sites.", minSites, maxGap)
+}
 
+// keepRuns drops the runs of sites not in sites.
+func keepRuns(runs []core.Run, sites []string) []core.Run {
+	if runs == nil {
+		return nil
+	}
+	keep := map[string]bool{}
+	for _, site := range sites {
+		keep[site] = true
+	}
+	kept := []core.Run{}
+	for _, run := range runs {
+		if keep[run.Site] {
+			kept = append(kept, run)
+		}
+	}
+	return kept
 }
 
-func sendEmail(id string, availableSites []string, arrival string, departure string) {
-	from := mail.NewEmail(" Stefan", "stefan@stefangrasu.com")
-	subject := fmt.Sprintf("Available sites found for %s between %s and %s", id, arrival, departure)
-	to := mail.NewEmail("Stefan", "sgrasu17@gmail.com")
-	plainTextContent := "and easy to do anywhere, even with Go"
-	htmlContent := "<strong>" + "Found these available sites: " + strings.Join(availableSites, ",") + "</strong>"
-	message := mail.NewSingleEmail(from, subject, to, plainTextContent, htmlContent)
-	client := sendgrid.NewSendClient(os.Getenv("SENDGRID_API_KEY"))
-	response, err := client.Send(message)
-	if err != nil {
-		log.Println(err)
-	} else {
-		fmt.Println(response.StatusCode)
-		fmt.Println(response.Body)
-		fmt.Println(response.Headers)
+// runSites lists the sites with at least one run, in run order.
+func runSites(runs []core.Run) []string {
+	seen := map[string]bool{}
+	sites := []string{}
+	for _, run := range runs {
+		if !seen[run.Site] {
+			seen[run.Site] = true
+			sites = append(sites, run.Site)
+		}
 	}
+	return sites
 }
 
-func logJobs() error {
-	ctx := context.Background()
-	c, err := scheduler.NewCloudSchedulerClient(ctx)
-	if err != nil {
-		log.Println("Failed to list jobs: ", err)
+// filteredOutNote explains that n sites were available but none of the
+// type, or none of the campsites, the watch asked for.
+func filteredOutNote(filter core.SiteFilter, n int) string {
+	note := fmt.Sprintf("%d sites were available but none matched the site type filter", n)
+	if len(filter.Sites) > 0 {
+		note = fmt.Sprintf("%d sites were available but none matched the site filter (campsites: %s)", n, strings.Join(filter.Sites, ", "))
+	}
+	if len(filter.Types) > 0 {
+		note += " (types: " + strings.Join(filter.Types, ", ") + ")"
+	}
+	if len(filter.Exclude) > 0 {
+		note += " (excluding: " + strings.Join(filter.Exclude, ", ") + ")"
 	}
+	return note
+}
 
-	req := &schedulerpb.ListJobsRequest{
-		Parent: "projects/camp-finder-258618/locations/us-west2",
+// stayDate parses a watch date, or returns the zero date when it does not
+// parse.
+func stayDate(s string) core.CivilDate {
+	d, err := core.ParseCivilDate(s)
+	if err != nil {
+		return core.CivilDate{}
 	}
-	it := c.ListJobs(ctx, req)
-	for {
-		_, err := it.Next()
-		if err == iterator.Done {
-			return nil
-		}
-		if err != nil {
-			log.Println("whyhwy2", err)
-			return err
-		}
-		//log.Println(resp.Description)
+	return d
+}
+
+// scrapeStays runs a flexible-departure watch, returning the matching sites
+// longest stay first along with each site's achievable nights.
+func scrapeStays(ctx context.Context, p core.Provider, m MessageContent, arrival core.CivilDate) ([]string, map[string]int, error) {
+	stays, err := core.ScrapeStays(ctx, p, m.Campground, arrival, m.MinNights, m.MaxNights)
+	sites := make([]string, 0, len(stays))
+	nights := make(map[string]int, len(stays))
+	for _, stay := range stays {
+		sites = append(sites, stay.Site)
+		nights[stay.Site] = stay.Nights
 	}
-	return nil
+	return sites, nights, err
+}
+
+// ScrapeAvailability scrape recreation.gov for the campground and dates
+// specified using DefaultScraper. See core.Scrape for the errors it may
+// return.
+func ScrapeAvailability(ctx context.Context, campgroundID string, arrival time.Time, departure time.Time) ([]string, error) {
+	return DefaultScraper.ScrapeAvailability(ctx, campgroundID, arrival, departure)
+}
+
+// ScrapeAvailabilityDetailed is ScrapeAvailability with a per-site status
+// breakdown, using DefaultScraper.
+func ScrapeAvailabilityDetailed(ctx context.Context, campgroundID string, arrival time.Time, departure time.Time) (core.AvailabilityResult, error) {
+	return DefaultScraper.ScrapeAvailabilityDetailed(ctx, campgroundID, arrival, departure)
 }
 
-func deleteJob(jobName string) error {
-	//log.Println("gonna try and delte")
-	ctx := context.Background()
-	c, err := scheduler.NewCloudSchedulerClient(ctx)
+func logJobs(ctx context.Context) error {
+	watches, err := ListWatches(ctx, activeConfig)
 	if err != nil {
-		log.Println("error cuz", err)
+		logger.Println("Failed to list jobs: ", err)
 		return err
 	}
+	for _, w := range watches {
+		logger.Printf("watch %s: campground %s, %s to %s, about %.0f requests/hour", w.Name, w.Watch.Campground, w.Watch.Arrival, w.Watch.Departure, w.RequestsPerHour)
+	}
+	return nil
+}
 
-	req := &schedulerpb.DeleteJobRequest{
-		Name: fmt.Sprintf("projects/camp-finder-258618/locations/us-west2/jobs/%s", jobName),
+// deleteJob deletes a job given either its bare ID or its fully-qualified
+// resource name. A job that is already gone is not an error, so a retried
+// invocation can finish cleanly.
+func deleteJob(ctx context.Context, jobName string) error {
+	name, err := ParseJobName(jobName)
+	if err != nil {
+		return err
 	}
-	err = c.DeleteJob(ctx, req)
+	c, err := newWatchScheduler()
 	if err != nil {
-		log.Println("didn't delete because", err)
 		return err
 	}
+	err = c.DeleteJob(ctx, name.String())
+	if status.Code(err) == codes.NotFound {
+		logger.Printf("job %s was already deleted", name)
+		pruneJobState(ctx, jobName)
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("deleting job %s: %v", name, err)
+	}
+	pruneJobState(ctx, jobName)
 	return nil
 }
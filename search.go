@@ -0,0 +1,65 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// CampgroundInfo is kept here for importers; it is defined in core.
+type CampgroundInfo = core.CampgroundInfo
+
+// SearchCampgrounds looks up reservable campgrounds by name on
+// recreation.gov, using DefaultScraper.
+func SearchCampgrounds(ctx context.Context, query string) ([]CampgroundInfo, error) {
+	return DefaultScraper.Provider().SearchCampgrounds(ctx, query)
+}
+
+// resolvedNames caches campground names resolved to IDs for the life of the
+// process. Campgrounds are not renamed often enough to need expiry.
+var (
+	resolvedMu    sync.Mutex
+	resolvedNames = map[string]string{}
+)
+
+// resolveCampgroundName returns the ID of the one campground called name,
+// or a *core.AmbiguousCampgroundError listing the candidates, or a
+// *core.NoCampgroundError.
+func resolveCampgroundName(ctx context.Context, name string) (string, error) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	resolvedMu.Lock()
+	id, ok := resolvedNames[key]
+	resolvedMu.Unlock()
+	if ok {
+		return id, nil
+	}
+	results, err := SearchCampgrounds(ctx, name)
+	if err != nil {
+		return "", fmt.Errorf("searching for campground %q: %w", name, err)
+	}
+	match, err := core.ResolveCampground(name, results)
+	if err != nil {
+		return "", err
+	}
+	resolvedMu.Lock()
+	resolvedNames[key] = match.ID
+	resolvedMu.Unlock()
+	return match.ID, nil
+}
+
+// resolveCampground fills in m.Campground from m.CampgroundName when the
+// watch names its campground instead of giving the ID.
+func (m *MessageContent) resolveCampground(ctx context.Context) error {
+	if m.Campground != "" || len(m.Campgrounds) > 0 || m.CampgroundName == "" {
+		return nil
+	}
+	id, err := resolveCampgroundName(ctx, m.CampgroundName)
+	if err != nil {
+		return err
+	}
+	m.Campground = id
+	return nil
+}
@@ -0,0 +1,90 @@
+package scraper_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// searchRequests counts the search requests the fake served.
+func searchRequests(h *fakerecgov.Harness) int {
+	n := 0
+	for _, request := range h.Server.Requests() {
+		if strings.HasPrefix(request, "/api/search") {
+			n++
+		}
+	}
+	return n
+}
+
+// A watch naming its campground is run against the one campground of that
+// name, and the name is looked up only once.
+func TestCampgroundNameResolved(t *testing.T) {
+	fixture := e2eFixture(true)
+	fixture.Campgrounds[0].Name = "Resolved Pines"
+	h := fakerecgov.Start(fixture)
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := e2eWatch("name-resolved")
+	m.Campground, m.CampgroundName, m.KeepJob = "", "resolved pines", true
+
+	for run := 0; run < 2; run++ {
+		if err := h.Run(context.Background(), m); err != nil {
+			t.Fatalf("run %d: %v", run, err)
+		}
+	}
+	if n := searchRequests(h); n != 1 {
+		t.Errorf("made %d search requests, want 1", n)
+	}
+	if alerts := h.Notifier.Alerts(); len(alerts) == 0 || alerts[0].CampgroundID != "232447" {
+		t.Errorf("got alerts %+v, want campground 232447's", alerts)
+	}
+}
+
+// A name matching several campgrounds, or none, fails the run before any
+// availability is fetched; an ambiguous one lists the candidates.
+func TestCampgroundNameUnresolved(t *testing.T) {
+	fixture := e2eFixture(true)
+	fixture.Campgrounds[0].Name = "Upper Cedars"
+	fixture.Campgrounds = append(fixture.Campgrounds, fakerecgov.Campground{ID: "232450", Name: "Lower Cedars", State: "CA"})
+	fixture.Campgrounds[0].State = "CA"
+	tests := []struct {
+		name    string
+		query   string
+		wantErr string
+	}{
+		{name: "ambiguous", query: "Cedars",
+			wantErr: `campground name "Cedars" is ambiguous; use one of: Upper Cedars (232447, CA); Lower Cedars (232450, CA)`},
+		{name: "no match", query: "Nowhere Flat", wantErr: `no reservable campground matches "Nowhere Flat"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("name-" + strings.Replace(test.name, " ", "-", -1))
+			m.Campground, m.CampgroundName = "", test.query
+
+			err := h.Run(context.Background(), m)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Fatalf("Run: %v, want %q", err, test.wantErr)
+			}
+			var ambiguous *core.AmbiguousCampgroundError
+			var none *core.NoCampgroundError
+			if !errors.As(err, &ambiguous) && !errors.As(err, &none) {
+				t.Errorf("Run: %T is neither resolution error", err)
+			}
+			if got := h.Server.MonthsFetched("232447"); len(got) != 0 {
+				t.Errorf("fetched months %v, want none", got)
+			}
+			if alerts := h.Notifier.Alerts(); len(alerts) != 0 {
+				t.Errorf("got alerts %+v, want none", alerts)
+			}
+		})
+	}
+}
@@ -0,0 +1,130 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// outOfSeason returns a *core.SeasonClosedError when the campground's
+// operating season, from its details, leaves out every night from arrival up
+// to departure. A failed lookup is logged and taken as no evidence.
+func (s *Scraper) outOfSeason(ctx context.Context, m MessageContent, arrival core.CivilDate, departure core.CivilDate) error {
+	f, err := s.facility(ctx, m.Campground)
+	if err != nil {
+		logger.Printf("job %s: looking up campground season: %v", m.Name, err)
+		return nil
+	}
+	if !f.Season.Excludes(arrival, departure) {
+		return nil
+	}
+	return &core.SeasonClosedError{CampgroundID: m.Campground, Arrival: arrival, Departure: departure, Season: f.Season}
+}
+
+// closeOutOfSeason handles a watch whose stay is outside the campground's
+// season. With SeasonShift the watch moves to the nearest in-season stay
+// when there is one; otherwise it is removed. Either way the owner is told
+// once.
+func closeOutOfSeason(ctx context.Context, run *watchRun, m MessageContent, closed *core.SeasonClosedError) error {
+	name := "campground " + closed.CampgroundID
+	if f, err := DefaultScraper.facility(ctx, closed.CampgroundID); err != nil {
+		logger.Printf("job %s: looking up campground name: %v", m.Name, err)
+	} else {
+		if f.Name != "" {
+			name = f.Name
+		}
+		if closed.Season.IsZero() {
+			closed.Season = f.Season
+		}
+	}
+	if m.SeasonShift && m.Nights == 0 && len(m.Campgrounds) == 0 {
+		today := core.CivilDateOf(DefaultScraper.now())
+		if arrival, departure, ok := closed.Season.Shift(closed.Arrival, closed.Departure, today); ok {
+			err := shiftWatch(ctx, run, arrival, departure)
+			if err == nil {
+				run.outcome = outcomeSeasonShifted
+				sendSeasonNotice(ctx, m, name, closed, fmt.Sprintf("This watch now looks for %s instead.", FormatStay(arrival, departure, m.Locale)), nil)
+				return nil
+			}
+			logger.Printf("job %s: moving the watch into the season: %v", m.Name, err)
+		}
+	}
+	summary := buildWatchSummary(ctx, m, WatchSeasonClosed, nil)
+	sendSeasonNotice(ctx, m, name, closed, "This watch has been removed. Create a new watch for dates inside the operating season.", &summary)
+	recordClosedWatch(ctx, summary)
+	return run.deleteJob(ctx, outcomeSeasonClosed)
+}
+
+// sendSeasonNotice tells the watch owner their dates fall outside the
+// campground's season, followed by what became of the watch and, when it
+// ended, its summary. The notice is sent once per stay, however often the
+// run is retried.
+func sendSeasonNotice(ctx context.Context, m MessageContent, name string, closed *core.SeasonClosedError, outcome string, summary *WatchSummary) {
+	ttl := closed.Departure.Time().Sub(DefaultScraper.now()) + 24*time.Hour
+	if ttl < 24*time.Hour {
+		ttl = 24 * time.Hour
+	}
+	claimed, err := idempotencyStore.Claim(ctx, "season/"+m.Name+"/"+closed.Arrival.String(), ttl)
+	if err == nil && !claimed {
+		return
+	}
+	stay := FormatStay(closed.Arrival, closed.Departure, m.Locale)
+	subject := fmt.Sprintf("%s is closed for your dates", name)
+	body := fmt.Sprintf("%s appears to be closed for the season for %s. ", name, stay)
+	if !closed.Season.IsZero() {
+		body = fmt.Sprintf("%s's season appears to run %s; your dates, %s, fall outside it. ", name, closed.Season, stay)
+	}
+	body += outcome
+	plain, htmlBody := body, "<p>"+html.EscapeString(body)+"</p>"
+	if summary != nil {
+		plain, htmlBody = plain+"\n\n"+summary.Text(), htmlBody+summary.HTML()
+	}
+	if err := sendNoticeTo(ctx, m.recipient(), subject, plain, htmlBody); err != nil {
+		logger.Printf("job %s: sending the season notice: %v", m.Name, err)
+	}
+}
+
+// shiftWatch moves the run's watch to the stay from arrival up to
+// departure, in the registry or in its job's payload. The stored watch is
+// rewritten rather than the run's copy, which holds what the run resolved,
+// such as the contact's address.
+func shiftWatch(ctx context.Context, run *watchRun, arrival core.CivilDate, departure core.CivilDate) error {
+	if run.registered {
+		store := newWatchStore(activeConfig)
+		r, err := store.GetWatch(ctx, run.watch.Name)
+		if err != nil {
+			return err
+		}
+		r.Watch.Arrival, r.Watch.Departure = arrival.String(), departure.String()
+		return updateRegisteredWatch(ctx, store, r.ID, r.Watch)
+	}
+	name, err := ParseJobName(run.watch.Name)
+	if err != nil {
+		return err
+	}
+	c, err := newWatchScheduler()
+	if err != nil {
+		return err
+	}
+	job, err := c.GetJob(ctx, name.String())
+	if err != nil {
+		return err
+	}
+	target := job.GetPubsubTarget()
+	if target == nil {
+		return fmt.Errorf("job %s has no Pub/Sub target", job.Name)
+	}
+	payload := map[string]interface{}{}
+	if err := json.Unmarshal(target.Data, &payload); err != nil {
+		return err
+	}
+	payload["Arrival"], payload["Departure"] = arrival.String(), departure.String()
+	if target.Data, err = json.Marshal(payload); err != nil {
+		return err
+	}
+	return c.UpdateJob(ctx, job, []string{"pubsub_target.data"})
+}
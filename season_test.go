@@ -0,0 +1,139 @@
+package scraper_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	scraper "github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// seasonFixture is a campground with one site and nothing open. Its
+// season, when set, is June 15 to September 20.
+func seasonFixture(id string, name string, season bool, nights map[string]string) fakerecgov.Fixture {
+	c := fakerecgov.Campground{ID: id, Name: name, Sites: []fakerecgov.Site{{ID: "2001", Site: "001", Nights: nights}}}
+	if season {
+		c.SeasonStart, c.SeasonEnd = "2027-06-15", "2027-09-20"
+	}
+	return fakerecgov.Fixture{Campgrounds: []fakerecgov.Campground{c}}
+}
+
+func TestEndToEndOutOfSeason(t *testing.T) {
+	closed := map[string]string{"2027-07-14": "Closed", "2027-07-15": "Closed"}
+	tests := []struct {
+		name      string
+		fixture   fakerecgov.Fixture
+		arrival   string
+		departure string
+		// wantNotice is a line of the one notice's body; empty wants none.
+		wantNotice  string
+		wantDeleted bool
+	}{
+		{
+			name:        "outside the listed season",
+			fixture:     seasonFixture("232460", "Tuolumne Meadows", true, nil),
+			arrival:     "2027-04-23",
+			departure:   "2027-04-25",
+			wantNotice:  "Tuolumne Meadows's season appears to run Jun 15–Sep 20; your dates, 2 nights, Fri Apr 23 → Sun Apr 25, fall outside it.",
+			wantDeleted: true,
+		},
+		{
+			name:        "every night closed",
+			fixture:     seasonFixture("232461", "Crane Flat", false, closed),
+			arrival:     "2027-07-14",
+			departure:   "2027-07-16",
+			wantNotice:  "Crane Flat appears to be closed for the season for 2 nights, Wed Jul 14 → Fri Jul 16.",
+			wantDeleted: true,
+		},
+		{
+			name:      "inside the listed season",
+			fixture:   seasonFixture("232460", "Tuolumne Meadows", true, nil),
+			arrival:   "2027-07-14",
+			departure: "2027-07-16",
+		},
+		{
+			name:      "no campsites",
+			fixture:   fakerecgov.Fixture{Campgrounds: []fakerecgov.Campground{{ID: "232462", Name: "White Wolf"}}},
+			arrival:   "2027-07-14",
+			departure: "2027-07-16",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(test.fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC))
+			m := scraper.MessageContent{
+				Name:       fakerecgov.JobName("season-" + strings.Replace(test.name, " ", "-", -1)),
+				Campground: test.fixture.Campgrounds[0].ID,
+				Arrival:    test.arrival,
+				Departure:  test.departure,
+			}
+
+			// A retried run finds the job gone but must not repeat the notice.
+			for i := 0; i < 2; i++ {
+				if err := h.Run(context.Background(), m); err != nil {
+					t.Fatalf("Run %d: %v", i, err)
+				}
+			}
+
+			notices := h.Notifier.Notices()
+			if test.wantNotice == "" && len(notices) != 0 {
+				t.Errorf("got notices %+v, want none", notices)
+			}
+			if test.wantNotice != "" && (len(notices) != 1 || !strings.Contains(notices[0].Plain, test.wantNotice)) {
+				t.Errorf("got notices %+v, want one saying %q", notices, test.wantNotice)
+			}
+			if deleted := len(h.Scheduler.Deleted()) > 0; deleted != test.wantDeleted {
+				t.Errorf("job deleted = %v, want %v", deleted, test.wantDeleted)
+			}
+		})
+	}
+}
+
+// With SeasonShift the watch moves by whole weeks to the first stay inside
+// the season and carries on scanning for it.
+func TestEndToEndSeasonShift(t *testing.T) {
+	h := fakerecgov.Start(seasonFixture("232460", "Tuolumne Meadows", true, nil))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 3, 1, 12, 0, 0, 0, time.UTC))
+	m := scraper.MessageContent{
+		Name:        fakerecgov.JobName("season-shift"),
+		Campground:  "232460",
+		Arrival:     "2027-04-23",
+		Departure:   "2027-04-25",
+		SeasonShift: true,
+	}
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	job := h.Scheduler.Job(m.Name)
+	if job == nil {
+		t.Fatalf("job deleted, want it moved into the season")
+	}
+	var moved scraper.MessageContent
+	if err := json.Unmarshal(job.GetPubsubTarget().GetData(), &moved); err != nil {
+		t.Fatal(err)
+	}
+	if moved.Arrival != "2027-06-18" || moved.Departure != "2027-06-20" || !moved.SeasonShift {
+		t.Errorf("moved watch %+v, want 2027-06-18 to 2027-06-20", moved)
+	}
+	notices := h.Notifier.Notices()
+	if len(notices) != 1 || !strings.Contains(notices[0].Plain, "This watch now looks for 2 nights, Fri Jun 18 → Sun Jun 20 instead.") {
+		t.Errorf("got notices %+v, want one saying where the watch moved", notices)
+	}
+
+	if err := h.Fire(context.Background(), m.Name); err != nil {
+		t.Fatalf("Fire: %v", err)
+	}
+	if h.Scheduler.Job(m.Name) == nil || len(h.Notifier.Notices()) != 1 {
+		t.Errorf("the moved watch was closed again: notices %+v", h.Notifier.Notices())
+	}
+	if months := h.Server.MonthsFetched("232460"); strings.Join(months, ",") != "2027-04,2027-06" {
+		t.Errorf("fetched months %v, want April then June", months)
+	}
+}
@@ -0,0 +1,101 @@
+package scraper
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"google.golang.org/api/cloudkms/v1"
+)
+
+// secretCipher encrypts the credentials of watches and contacts before
+// they are written to Firestore, and decrypts them once read back.
+type secretCipher interface {
+	Encrypt(ctx context.Context, plaintext []byte) (string, error)
+	Decrypt(ctx context.Context, ciphertext string) ([]byte, error)
+}
+
+// kmsCipher is a secretCipher using the Cloud KMS key Key, named
+// projects/P/locations/L/keyRings/R/cryptoKeys/K.
+type kmsCipher struct {
+	Key string
+}
+
+// Encrypt implements secretCipher.
+func (c kmsCipher) Encrypt(ctx context.Context, plaintext []byte) (string, error) {
+	svc, err := cloudkms.NewService(ctx)
+	if err != nil {
+		return "", fmt.Errorf("cloudkms.NewService: %v", err)
+	}
+	req := &cloudkms.EncryptRequest{Plaintext: base64.StdEncoding.EncodeToString(plaintext)}
+	resp, err := svc.Projects.Locations.KeyRings.CryptoKeys.Encrypt(c.Key, req).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("encrypting with %s: %v", c.Key, err)
+	}
+	return resp.Ciphertext, nil
+}
+
+// Decrypt implements secretCipher.
+func (c kmsCipher) Decrypt(ctx context.Context, ciphertext string) ([]byte, error) {
+	svc, err := cloudkms.NewService(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("cloudkms.NewService: %v", err)
+	}
+	req := &cloudkms.DecryptRequest{Ciphertext: ciphertext}
+	resp, err := svc.Projects.Locations.KeyRings.CryptoKeys.Decrypt(c.Key, req).Context(ctx).Do()
+	if err != nil {
+		return nil, fmt.Errorf("decrypting with %s: %v", c.Key, err)
+	}
+	return base64.StdEncoding.DecodeString(resp.Plaintext)
+}
+
+// errNoSecretsKey is returned when a credential would be stored without a
+// key to encrypt it.
+var errNoSecretsKey = errors.New("SECRETS_KMS_KEY is not set")
+
+// newSecretCipher returns the cipher for stored credentials: the
+// SECRETS_KMS_KEY Cloud KMS key, or nil when that is unset, in which case
+// nothing holding a credential can be stored. Tests replace it.
+var newSecretCipher = func() secretCipher {
+	if key := os.Getenv("SECRETS_KMS_KEY"); key != "" {
+		return kmsCipher{Key: key}
+	}
+	return nil
+}
+
+// sealSecrets encrypts v, as JSON, for storing next to a record.
+func sealSecrets(ctx context.Context, v interface{}) (string, error) {
+	c := newSecretCipher()
+	if c == nil {
+		return "", errNoSecretsKey
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return c.Encrypt(ctx, data)
+}
+
+// openSecrets decrypts what sealSecrets stored into v.
+func openSecrets(ctx context.Context, sealed string, v interface{}) error {
+	c := newSecretCipher()
+	if c == nil {
+		return errNoSecretsKey
+	}
+	data, err := c.Decrypt(ctx, sealed)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// watchSecrets are the credentials a watch may carry: its webhook, whose
+// URL often embeds a token, and its request headers. They are kept out of
+// the registry record and stored encrypted beside it.
+type watchSecrets struct {
+	WebhookURL string            `json:"webhook_url,omitempty"`
+	Headers    map[string]string `json:"headers,omitempty"`
+}
@@ -0,0 +1,156 @@
+package scraper
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"google.golang.org/api/firestore/v1"
+)
+
+// fakeCipher stands in for Cloud KMS, marking what it encrypts.
+type fakeCipher struct{}
+
+func (fakeCipher) Encrypt(ctx context.Context, plaintext []byte) (string, error) {
+	return "sealed:" + base64.StdEncoding.EncodeToString(plaintext), nil
+}
+
+func (fakeCipher) Decrypt(ctx context.Context, ciphertext string) ([]byte, error) {
+	if !strings.HasPrefix(ciphertext, "sealed:") {
+		return nil, errors.New("not sealed")
+	}
+	return base64.StdEncoding.DecodeString(strings.TrimPrefix(ciphertext, "sealed:"))
+}
+
+func useCipher(t *testing.T, c secretCipher) {
+	old := newSecretCipher
+	newSecretCipher = func() secretCipher { return c }
+	t.Cleanup(func() { newSecretCipher = old })
+}
+
+const (
+	testWebhook = "https://hooks.example.com/alerts?token=hook-token-123"
+	testSlack   = "https://hooks.slack.com/services/T000/B000/slack-token-456"
+)
+
+// Watch webhooks and headers, and contacts' Slack webhooks, never reach the
+// stored record in the clear, and read back as they were written.
+func TestStoredSecrets(t *testing.T) {
+	ctx := context.Background()
+	watch := MessageContent{Name: "watch-1", Campground: "232447", WebhookURL: testWebhook,
+		Headers: map[string]string{"Authorization": "Bearer header-token-789"}}
+	contact := Contact{Owner: "sam@example.com", Name: "mom", Email: "mom@example.com", Slack: testSlack}
+	secrets := []string{"hook-token-123", "header-token-789", "slack-token-456"}
+	tests := []struct {
+		name   string
+		cipher secretCipher
+		// encode stores a record and decode reads it back, returning it for
+		// comparison with what was stored.
+		encode     func() (*firestore.Document, error)
+		decode     func(doc *firestore.Document) (interface{}, error)
+		want       interface{}
+		wantSealed bool
+		wantErr    error
+	}{
+		{
+			name:   "watch",
+			cipher: fakeCipher{},
+			encode: func() (*firestore.Document, error) {
+				return encodeWatchDocument(ctx, WatchRecord{ID: "watch-1", Watch: watch, Status: WatchActive})
+			},
+			decode: func(doc *firestore.Document) (interface{}, error) {
+				r, err := decodeWatchDocument(ctx, doc)
+				return r.Watch, err
+			},
+			want:       watch,
+			wantSealed: true,
+		},
+		{
+			name:   "watch without secrets",
+			cipher: nil,
+			encode: func() (*firestore.Document, error) {
+				return encodeWatchDocument(ctx, WatchRecord{ID: "watch-2", Watch: MessageContent{Name: "watch-2"}})
+			},
+			decode: func(doc *firestore.Document) (interface{}, error) {
+				r, err := decodeWatchDocument(ctx, doc)
+				return r.Watch, err
+			},
+			want: MessageContent{Name: "watch-2"},
+		},
+		{
+			name:   "watch without a key",
+			cipher: nil,
+			encode: func() (*firestore.Document, error) {
+				return encodeWatchDocument(ctx, WatchRecord{ID: "watch-1", Watch: watch})
+			},
+			wantErr: errNoSecretsKey,
+		},
+		{
+			name:   "watch stored before secrets were split out",
+			cipher: nil,
+			encode: func() (*firestore.Document, error) {
+				return &firestore.Document{Fields: map[string]firestore.Value{
+					"record": {StringValue: `{"id":"watch-1","watch":{"Name":"watch-1","WebhookURL":"` + testWebhook + `"}}`},
+				}}, nil
+			},
+			decode: func(doc *firestore.Document) (interface{}, error) {
+				r, err := decodeWatchDocument(ctx, doc)
+				return r.Watch.WebhookURL, err
+			},
+			want: testWebhook,
+		},
+		{
+			name:   "contact",
+			cipher: fakeCipher{},
+			encode: func() (*firestore.Document, error) { return encodeContactDocument(ctx, contact) },
+			decode: func(doc *firestore.Document) (interface{}, error) {
+				return decodeContactDocument(ctx, doc)
+			},
+			want:       contact,
+			wantSealed: true,
+		},
+		{
+			name:    "contact without a key",
+			cipher:  nil,
+			encode:  func() (*firestore.Document, error) { return encodeContactDocument(ctx, contact) },
+			wantErr: errNoSecretsKey,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			useCipher(t, test.cipher)
+			doc, err := test.encode()
+			if test.wantErr != nil {
+				if !errors.Is(err, test.wantErr) {
+					t.Fatalf("encoding: %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.wantSealed {
+				for key, value := range doc.Fields {
+					for _, secret := range secrets {
+						if strings.Contains(value.StringValue, secret) {
+							t.Errorf("field %s holds %q in the clear: %s", key, secret, value.StringValue)
+						}
+					}
+				}
+				if !strings.HasPrefix(doc.Fields["secrets"].StringValue, "sealed:") {
+					t.Errorf("secrets %q, want them sealed", doc.Fields["secrets"].StringValue)
+				}
+			}
+			got, err := test.decode(doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("read back %+v, want %+v", got, test.want)
+			}
+		})
+	}
+}
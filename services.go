@@ -0,0 +1,133 @@
+package scraper
+
+import (
+	"context"
+
+	"github.com/sendgrid/sendgrid-go/helpers/mail"
+)
+
+// Services are what ScrapeFromMessage and the other entry points talk to
+// besides recreation.gov. UseServices swaps them for fakes so the whole
+// scrape, notify and delete flow can run in a test; the internal/fakerecgov
+// package has a fake of each and of recreation.gov itself.
+type Services struct {
+	// Scheduler replaces Cloud Scheduler.
+	Scheduler Scheduler
+	// Notifier receives every alert in place of email, Slack, SMS and
+	// webhooks.
+	Notifier AlertNotifier
+	// Notices receives every email notice, such as the expiry and season
+	// messages and digests, in place of SendGrid.
+	Notices NoticeSender
+	// Results receives the message published after every scrape, in place
+	// of the RESULTS_TOPIC publisher.
+	Results ResultsPublisher
+	// Store, Scans, Deliveries and Claims replace the state persistent,
+	// change-tracking, rate-limited and redelivered watches keep. A
+	// *MemoryStore serves as all four.
+	Store      Store
+	Scans      ScanStore
+	Deliveries DeliveryStore
+	Claims     IdempotencyStore
+	// Watches replaces the WATCH_REGISTRY registry, and Contacts the
+	// CONTACTS_COLLECTION contacts.
+	Watches  WatchStore
+	Contacts ContactStore
+	// Openings replaces where campgrounds' openings are tracked for their
+	// time to book.
+	Openings OpeningStore
+	// Backfills replaces where Backfill keeps the months it finished.
+	Backfills BackfillStore
+	// Clock replaces DefaultScraper's clock.
+	Clock Clock
+}
+
+// AlertNotifier receives alerts, described as for a watch's webhook, in
+// place of the configured channels; see Services.
+type AlertNotifier interface {
+	NotifyAlert(ctx context.Context, alert WebhookPayload) error
+}
+
+// Notice is one email notice.
+type Notice struct {
+	To      string
+	Subject string
+	Plain   string
+	HTML    string
+}
+
+// NoticeSender receives email notices in place of SendGrid; see Services.
+type NoticeSender interface {
+	SendNotice(ctx context.Context, notice Notice) error
+}
+
+// UseServices installs the fields of s that are set, keeping the others, and
+// returns a function that puts back what it replaced. It is meant for tests
+// and must not be called while a run is in flight.
+func UseServices(s Services) (restore func()) {
+	scheduler, notifiers, notices, results, state, scans, deliveries, claims, watches, contacts, openings, backfills := newWatchScheduler, notifiersFor, sendNoticeTo, resultsPublisher, stateStore, scanStore, deliveryStore, idempotencyStore, newWatchStore, newContactStore, openingStore, backfillStore
+	timed, wall := DefaultScraper, DefaultScraper.Clock
+	if s.Scheduler != nil {
+		fake := s.Scheduler
+		newWatchScheduler = func() (Scheduler, error) { return fake, nil }
+	}
+	if s.Notifier != nil {
+		fake := alertNotifier{s.Notifier}
+		notifiersFor = func(a alert) []notifier { return []notifier{fake} }
+	}
+	if s.Notices != nil {
+		fake := s.Notices
+		sendNoticeTo = func(ctx context.Context, to *mail.Email, subject string, plainTextContent string, htmlContent string) error {
+			return fake.SendNotice(ctx, Notice{To: to.Address, Subject: subject, Plain: plainTextContent, HTML: htmlContent})
+		}
+	}
+	if s.Results != nil {
+		resultsPublisher = s.Results
+	}
+	if s.Store != nil {
+		stateStore = s.Store
+	}
+	if s.Scans != nil {
+		scanStore = s.Scans
+	}
+	if s.Deliveries != nil {
+		deliveryStore = s.Deliveries
+	}
+	if s.Claims != nil {
+		idempotencyStore = s.Claims
+	}
+	if s.Watches != nil {
+		fake := s.Watches
+		newWatchStore = func(cfg Config) WatchStore { return fake }
+	}
+	if s.Contacts != nil {
+		fake := s.Contacts
+		newContactStore = func(cfg Config) ContactStore { return fake }
+	}
+	if s.Openings != nil {
+		openingStore = s.Openings
+	}
+	if s.Backfills != nil {
+		backfillStore = s.Backfills
+	}
+	if s.Clock != nil {
+		timed.Clock = s.Clock
+	}
+	return func() {
+		newWatchScheduler, notifiersFor, sendNoticeTo, resultsPublisher, stateStore, scanStore, deliveryStore, idempotencyStore, newWatchStore, newContactStore, openingStore, backfillStore = scheduler, notifiers, notices, results, state, scans, deliveries, claims, watches, contacts, openings, backfills
+		timed.Clock = wall
+	}
+}
+
+// alertNotifier delivers alerts to an AlertNotifier.
+type alertNotifier struct {
+	n AlertNotifier
+}
+
+func (n alertNotifier) Channel() string { return "injected" }
+
+func (n alertNotifier) Recipient() (string, string) { return "injected", "injected" }
+
+func (n alertNotifier) Notify(ctx context.Context, a alert, r renderedAlert) error {
+	return n.n.NotifyAlert(ctx, webhookPayload(a))
+}
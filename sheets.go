@@ -0,0 +1,416 @@
+package scraper
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/pubsub"
+	"google.golang.org/api/sheets/v4"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// defaultSheetSchedule is how often watches created from a sheet run.
+const defaultSheetSchedule = "*/10 * * * *"
+
+// sheetColumns are the headers a watch sheet's first row must have, in any
+// order and case. "filters" and "status" are optional; a missing status
+// column is added after the last.
+var sheetColumns = []string{"name", "campground", "arrival", "departure", "email"}
+
+// SheetClient reads and writes the watch sheet, so SyncSheet can run
+// against a fake.
+type SheetClient interface {
+	// Rows returns the sheet's rows, headers first. Trailing empty cells
+	// may be left out.
+	Rows(ctx context.Context) ([][]string, error)
+	// SetCell writes value to the cell at row and column, both counted
+	// from 0.
+	SetCell(ctx context.Context, row int, column int, value string) error
+}
+
+// WatchSource records where a registered watch was defined outside the
+// API: the sheet, the row's name and a digest of the row as last synced.
+type WatchSource struct {
+	Sheet  string `json:"sheet"`
+	Row    string `json:"row"`
+	Digest string `json:"digest"`
+}
+
+// SheetSyncOptions change how SyncSheet reconciles a sheet.
+type SheetSyncOptions struct {
+	// Sheet identifies the sheet, so watches from several sheets are kept
+	// apart. It is the spreadsheet ID and tab for SyncSheetFromMessage.
+	Sheet string
+	// Schedule is the cron schedule of created watches; the default is
+	// every ten minutes.
+	Schedule string
+}
+
+// SheetSync is what SyncSheet did, by row name. Failed maps each row that
+// could not be synced to the reason, as written to its status cell.
+type SheetSync struct {
+	Created []string
+	Updated []string
+	Expired []string
+	Failed  map[string]string
+}
+
+// sheetRow is a data row of the watch sheet.
+type sheetRow struct {
+	// index is the row's position in the sheet, from 0 for the headers.
+	index  int
+	name   string
+	cells  map[string]string
+	status string
+}
+
+// SyncSheet reconciles the registered watches defined by the sheet with its
+// rows. Each row with a name is a watch: a new row creates one, a changed
+// row updates it and a removed row ends it. A row that does not make a
+// valid watch is reported in its status cell and leaves any watch it had
+// alone; it does not stop the other rows. Every row's status cell then
+// shows the outcome, or the watch's state and last run. It needs
+// WATCH_REGISTRY. The error covers reading the sheet, a sheet without the
+// columns of sheetColumns and status cells that could not be written, as a
+// *MultiError.
+func SyncSheet(ctx context.Context, cfg Config, sheet SheetClient, opts SheetSyncOptions) (SheetSync, error) {
+	result := SheetSync{Created: []string{}, Updated: []string{}, Expired: []string{}, Failed: map[string]string{}}
+	store := newWatchStore(cfg)
+	if store == nil {
+		return result, fmt.Errorf("syncing sheet %s: WATCH_REGISTRY is not set", opts.Sheet)
+	}
+	if opts.Schedule == "" {
+		opts.Schedule = defaultSheetSchedule
+	}
+	values, err := sheet.Rows(ctx)
+	if err != nil {
+		return result, fmt.Errorf("reading sheet %s: %v", opts.Sheet, err)
+	}
+	rows, statusColumn, err := parseSheet(values)
+	if err != nil {
+		return result, fmt.Errorf("sheet %s: %w", opts.Sheet, err)
+	}
+	failures := &MultiError{}
+	if statusColumn == len(headerCells(values)) {
+		failures.Add(sheet.SetCell(ctx, 0, statusColumn, "status"))
+	}
+
+	records, err := store.ListWatchRecords(ctx)
+	if err != nil {
+		return result, fmt.Errorf("syncing sheet %s: %v", opts.Sheet, err)
+	}
+	synced := map[string]WatchRecord{}
+	for _, r := range records {
+		if r.Source != nil && r.Source.Sheet == opts.Sheet {
+			if current, ok := synced[r.Source.Row]; !ok || current.Status == WatchCompleted {
+				synced[r.Source.Row] = r
+			}
+		}
+	}
+
+	seen := map[string]int{}
+	for _, row := range rows {
+		text := ""
+		if first, ok := seen[row.name]; ok {
+			text = fmt.Sprintf("error: row %d has the same name", first+1)
+			result.Failed[row.name] = text
+		} else {
+			seen[row.name] = row.index
+			text = syncSheetRow(ctx, cfg, store, opts, row, synced, &result)
+		}
+		if text != row.status {
+			failures.Add(sheet.SetCell(ctx, row.index, statusColumn, text))
+		}
+	}
+
+	for name, r := range synced {
+		if _, ok := seen[name]; ok || r.Status == WatchCompleted {
+			continue
+		}
+		if err := endSheetWatch(ctx, cfg, store, r); err != nil {
+			logger.Printf("sheet %s: ending watch %s for removed row %q: %v", opts.Sheet, r.ID, name, err)
+			failures.Add(err)
+			continue
+		}
+		logger.Printf("sheet %s: row %q removed, ended watch %s", opts.Sheet, name, r.ID)
+		result.Expired = append(result.Expired, name)
+	}
+	return result, failures.Err()
+}
+
+// syncSheetRow creates or updates the watch for row and returns its status
+// text. A failure is also recorded in result.Failed.
+func syncSheetRow(ctx context.Context, cfg Config, store WatchStore, opts SheetSyncOptions, row sheetRow, synced map[string]WatchRecord, result *SheetSync) string {
+	fail := func(err error) string {
+		text := "error: " + err.Error()
+		result.Failed[row.name] = text
+		return text
+	}
+	m, err := row.watch()
+	if err != nil {
+		return fail(err)
+	}
+	source := WatchSource{Sheet: opts.Sheet, Row: row.name, Digest: row.digest()}
+	existing, ok := synced[row.name]
+	switch {
+	case ok && existing.Status == WatchCompleted && existing.Source.Digest == source.Digest:
+		return sheetStatus(existing, "")
+	case ok && existing.Status != WatchCompleted:
+		if existing.Source.Digest == source.Digest {
+			return sheetStatus(existing, "")
+		}
+		if err := updateRegisteredWatch(ctx, store, existing.ID, m); err != nil {
+			return fail(err)
+		}
+		r, err := setWatchSource(ctx, store, existing.ID, source)
+		if err != nil {
+			return fail(err)
+		}
+		result.Updated = append(result.Updated, row.name)
+		return sheetStatus(r, "updated")
+	}
+	created, err := CreateWatch(ctx, cfg, m, opts.Schedule, CreateOptions{Force: true})
+	if err != nil {
+		return fail(err)
+	}
+	r, err := setWatchSource(ctx, store, created.Name.Job, source)
+	if err != nil {
+		return fail(err)
+	}
+	result.Created = append(result.Created, row.name)
+	return sheetStatus(r, "created")
+}
+
+// setWatchSource records on the registered watch id that it comes from
+// source, and returns its record.
+func setWatchSource(ctx context.Context, store WatchStore, id string, source WatchSource) (WatchRecord, error) {
+	r, err := store.GetWatch(ctx, id)
+	if err != nil {
+		return r, err
+	}
+	r.Source = &source
+	return r, store.PutWatch(ctx, r)
+}
+
+// endSheetWatch deletes the job of a watch whose row was removed and marks
+// it completed. A job that is already gone is not an error.
+func endSheetWatch(ctx context.Context, cfg Config, store WatchStore, r WatchRecord) error {
+	s, err := newWatchScheduler()
+	if err != nil {
+		return err
+	}
+	if err := s.DeleteJob(ctx, cfg.JobName(r.ID).String()); err != nil && status.Code(err) != codes.NotFound {
+		return fmt.Errorf("deleting job for watch %s: %v", r.ID, err)
+	}
+	pruneJobState(ctx, r.ID)
+	markWatchCompleted(ctx, store, r.ID)
+	return nil
+}
+
+// sheetStatus is the status cell for a synced watch, such as "created;
+// active" or "active; last run: no availability".
+func sheetStatus(r WatchRecord, action string) string {
+	status := string(r.Status)
+	if action != "" {
+		status = action + "; " + status
+	}
+	if r.LastResult != nil {
+		status += "; last run: " + r.LastResult.Outcome
+		if r.LastResult.Error != "" {
+			status += " (" + r.LastResult.Error + ")"
+		}
+	}
+	return status
+}
+
+// headerCells returns the first row of values, or none.
+func headerCells(values [][]string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+	return values[0]
+}
+
+// parseSheet reads the headers and the named rows of values. It returns the
+// rows with a name and the status column, which is one past the last
+// header when the sheet has none.
+func parseSheet(values [][]string) ([]sheetRow, int, error) {
+	headers := headerCells(values)
+	columns := map[string]int{}
+	for i, header := range headers {
+		columns[strings.ToLower(strings.TrimSpace(header))] = i
+	}
+	for _, name := range sheetColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, 0, fmt.Errorf("no %q column; the first row must name the columns %s", name, strings.Join(sheetColumns, ", "))
+		}
+	}
+	statusColumn, ok := columns["status"]
+	if !ok {
+		statusColumn = len(headers)
+	}
+	rows := []sheetRow{}
+	for i := 1; i < len(values); i++ {
+		cell := func(column int) string {
+			if column < len(values[i]) {
+				return strings.TrimSpace(values[i][column])
+			}
+			return ""
+		}
+		row := sheetRow{index: i, cells: map[string]string{}, status: cell(statusColumn)}
+		for name, column := range columns {
+			if name != "status" {
+				row.cells[name] = cell(column)
+			}
+		}
+		row.name = row.cells["name"]
+		if row.name != "" {
+			rows = append(rows, row)
+		}
+	}
+	return rows, statusColumn, nil
+}
+
+// digest identifies the row's definition, so an edit can be told apart from
+// a row that was synced already.
+func (row sheetRow) digest() string {
+	parts := []string{}
+	for _, name := range append(append([]string{}, sheetColumns...), "filters") {
+		parts = append(parts, row.cells[name])
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x1f")))
+	return hex.EncodeToString(sum[:8])
+}
+
+// watch builds the watch row defines. The campground is an ID, or else a
+// name resolved when the watch is created. An email is required, so the
+// watch's alerts reach whoever the row is for.
+func (row sheetRow) watch() (MessageContent, error) {
+	m := MessageContent{Arrival: row.cells["arrival"], Departure: row.cells["departure"], NotifyEmail: row.cells["email"]}
+	if campground := row.cells["campground"]; isDigits(campground) {
+		m.Campground = campground
+	} else {
+		m.CampgroundName = campground
+	}
+	if m.Campground == "" && m.CampgroundName == "" {
+		return m, &ValidationError{Field: "campground", Reason: "must not be empty"}
+	}
+	if m.NotifyEmail == "" {
+		return m, &ValidationError{Field: "email", Reason: "must not be empty"}
+	}
+	return m, applySheetFilters(&m, row.cells["filters"])
+}
+
+// applySheetFilters sets the options in a filters cell, such as "types=TENT
+// ONLY NONELECTRIC; sites=1001,1002; capacity=4; max price=40; weather",
+// on m.
+func applySheetFilters(m *MessageContent, filters string) error {
+	for _, filter := range strings.Split(filters, ";") {
+		filter = strings.TrimSpace(filter)
+		if filter == "" {
+			continue
+		}
+		key, value := filter, ""
+		if i := strings.Index(filter, "="); i >= 0 {
+			key, value = strings.TrimSpace(filter[:i]), strings.TrimSpace(filter[i+1:])
+		}
+		list := func() []string {
+			items := []string{}
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			return items
+		}
+		var err error
+		switch strings.ToLower(key) {
+		case "sites":
+			m.CampsiteIDs = list()
+		case "types":
+			m.SiteTypes = list()
+		case "exclude":
+			m.ExcludeTypes = list()
+		case "capacity":
+			m.MinCapacity, err = strconv.Atoi(value)
+		case "max price":
+			m.MaxNightlyPrice, err = strconv.ParseFloat(value, 64)
+		case "weather":
+			m.IncludeWeather = true
+		default:
+			return &ValidationError{Field: "filters", Value: key, Reason: "is not one of sites, types, exclude, capacity, max price or weather"}
+		}
+		if err != nil {
+			return &ValidationError{Field: "filters", Value: filter, Reason: "is not a number"}
+		}
+	}
+	return nil
+}
+
+// googleSheet is the SheetClient for one tab of a Google spreadsheet.
+type googleSheet struct {
+	svc         *sheets.Service
+	spreadsheet string
+	tab         string
+}
+
+// Rows implements SheetClient.
+func (g googleSheet) Rows(ctx context.Context) ([][]string, error) {
+	response, err := g.svc.Spreadsheets.Values.Get(g.spreadsheet, g.tab).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	rows := make([][]string, len(response.Values))
+	for i, values := range response.Values {
+		for _, v := range values {
+			rows[i] = append(rows[i], fmt.Sprint(v))
+		}
+	}
+	return rows, nil
+}
+
+// SetCell implements SheetClient.
+func (g googleSheet) SetCell(ctx context.Context, row int, column int, value string) error {
+	cell := fmt.Sprintf("%s!%s%d", g.tab, columnLetters(column), row+1)
+	_, err := g.svc.Spreadsheets.Values.Update(g.spreadsheet, cell, &sheets.ValueRange{Values: [][]interface{}{{value}}}).
+		ValueInputOption("RAW").Context(ctx).Do()
+	return err
+}
+
+// columnLetters is the A1 name of a column counted from 0: A to Z, then AA.
+func columnLetters(column int) string {
+	letters := ""
+	for column++; column > 0; column = (column - 1) / 26 {
+		letters = string(rune('A'+(column-1)%26)) + letters
+	}
+	return letters
+}
+
+// SyncSheetFromMessage is a Pub/Sub Cloud Function running SyncSheet on the
+// WATCH_SHEET spreadsheet's WATCH_SHEET_TAB tab ("Watches" by default), for
+// triggering from its own schedule.
+func SyncSheetFromMessage(ctx context.Context, m pubsub.Message) error {
+	spreadsheet := os.Getenv("WATCH_SHEET")
+	if spreadsheet == "" {
+		return fmt.Errorf("WATCH_SHEET is not set")
+	}
+	tab := os.Getenv("WATCH_SHEET_TAB")
+	if tab == "" {
+		tab = "Watches"
+	}
+	svc, err := sheets.NewService(ctx)
+	if err != nil {
+		return fmt.Errorf("sheets.NewService: %v", err)
+	}
+	result, err := SyncSheet(ctx, activeConfig, googleSheet{svc: svc, spreadsheet: spreadsheet, tab: tab},
+		SheetSyncOptions{Sheet: spreadsheet + "/" + tab})
+	logger.Printf("sheet %s/%s: created %d watches, updated %d, ended %d; %d rows failed",
+		spreadsheet, tab, len(result.Created), len(result.Updated), len(result.Expired), len(result.Failed))
+	return err
+}
@@ -0,0 +1,197 @@
+package scraper_test
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+var sheetHeaders = []string{"Name", "Campground", "Arrival", "Departure", "Email", "Filters", "Status"}
+
+// sheetRow is a row of the watch sheet for e2eFixture's campground.
+func sheetRow(name string, arrival string, departure string, filters string) []string {
+	return []string{name, "232447", arrival, departure, "mom@example.com", filters}
+}
+
+// SyncSheet creates, updates and ends watches to match the sheet's rows,
+// row by row, and writes each row's outcome to its status cell.
+func TestSyncSheet(t *testing.T) {
+	yosemite := sheetRow("Yosemite", "2027-07-14", "2027-07-16", "types=STANDARD NONELECTRIC; capacity=4")
+	tests := []struct {
+		name string
+		// first is synced, then second, if set.
+		first, second [][]string
+		wantCreated   []string
+		wantUpdated   []string
+		wantExpired   []string
+		wantFailed    []string
+		// wantStatus holds the start of the status cell of each data row,
+		// from 1, after the last sync.
+		wantStatus map[int]string
+		// wantWatches maps the rows with an active watch to its departure.
+		wantWatches map[string]string
+	}{
+		{
+			name:        "new rows",
+			first:       [][]string{yosemite, sheetRow("Bad date", "2027-07-32", "2027-08-01", ""), sheetRow("Bad filter", "2027-07-14", "2027-07-16", "pets"), {"", "232447"}},
+			wantCreated: []string{"Yosemite"},
+			wantFailed:  []string{"Bad date", "Bad filter"},
+			wantStatus:  map[int]string{1: "created; active", 2: "error: invalid Arrival", 3: `error: invalid filters "pets"`, 4: ""},
+			wantWatches: map[string]string{"Yosemite": "2027-07-16"},
+		},
+		{
+			name:        "unchanged",
+			first:       [][]string{yosemite},
+			second:      [][]string{append(yosemite, "created; active")},
+			wantStatus:  map[int]string{1: "active"},
+			wantWatches: map[string]string{"Yosemite": "2027-07-16"},
+		},
+		{
+			name:        "edited",
+			first:       [][]string{yosemite},
+			second:      [][]string{sheetRow("Yosemite", "2027-07-14", "2027-07-18", "types=STANDARD NONELECTRIC; capacity=4")},
+			wantUpdated: []string{"Yosemite"},
+			wantStatus:  map[int]string{1: "updated; active"},
+			wantWatches: map[string]string{"Yosemite": "2027-07-18"},
+		},
+		{
+			name:        "removed",
+			first:       [][]string{yosemite, sheetRow("Later", "2027-08-14", "2027-08-16", "")},
+			second:      [][]string{sheetRow("Later", "2027-08-14", "2027-08-16", "")},
+			wantExpired: []string{"Yosemite"},
+			wantStatus:  map[int]string{1: "active"},
+			wantWatches: map[string]string{"Later": "2027-08-16"},
+		},
+		{
+			name:        "invalid edit keeps the watch",
+			first:       [][]string{yosemite},
+			second:      [][]string{sheetRow("Yosemite", "2027-07-14", "2027-07-12", "")},
+			wantFailed:  []string{"Yosemite"},
+			wantStatus:  map[int]string{1: "error: invalid Departure"},
+			wantWatches: map[string]string{"Yosemite": "2027-07-16"},
+		},
+		{
+			name:        "duplicate names",
+			first:       [][]string{yosemite, sheetRow("Yosemite", "2027-08-14", "2027-08-16", "")},
+			wantCreated: []string{"Yosemite"},
+			wantFailed:  []string{"Yosemite"},
+			wantStatus:  map[int]string{1: "created; active", 2: "error: row 2 has the same name"},
+			wantWatches: map[string]string{"Yosemite": "2027-07-16"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(false))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			defer scraper.UseServices(scraper.Services{Watches: h.Store})()
+			ctx := context.Background()
+			opts := scraper.SheetSyncOptions{Sheet: "family/Watches"}
+
+			sheet := fakerecgov.NewSheet(append([][]string{sheetHeaders}, test.first...)...)
+			got, err := scraper.SyncSheet(ctx, testConfig, sheet, opts)
+			if err != nil {
+				t.Fatalf("first SyncSheet: %v", err)
+			}
+			if test.second != nil {
+				sheet.SetRows(append([][]string{sheetHeaders}, test.second...)...)
+				if got, err = scraper.SyncSheet(ctx, testConfig, sheet, opts); err != nil {
+					t.Fatalf("second SyncSheet: %v", err)
+				}
+			}
+
+			failed := []string{}
+			for name := range got.Failed {
+				failed = append(failed, name)
+			}
+			sort.Strings(failed)
+			for _, c := range []struct {
+				what      string
+				got, want []string
+			}{
+				{"created", got.Created, test.wantCreated},
+				{"updated", got.Updated, test.wantUpdated},
+				{"expired", got.Expired, test.wantExpired},
+				{"failed", failed, test.wantFailed},
+			} {
+				if strings.Join(c.got, ",") != strings.Join(c.want, ",") {
+					t.Errorf("%s %v, want %v", c.what, c.got, c.want)
+				}
+			}
+			for row, want := range test.wantStatus {
+				if status := sheet.Cell(row, 6); !strings.HasPrefix(status, want) || want == "" && status != "" {
+					t.Errorf("row %d status %q, want %q", row, status, want)
+				}
+			}
+
+			records, _ := h.Store.ListWatchRecords(ctx)
+			watches := map[string]string{}
+			for _, r := range records {
+				if r.Source == nil || r.Status != scraper.WatchActive {
+					continue
+				}
+				watches[r.Source.Row] = r.Watch.Departure
+				if h.Scheduler.Job(testConfig.JobName(r.ID).String()) == nil {
+					t.Errorf("active watch %s for row %q has no job", r.ID, r.Source.Row)
+				}
+				if r.Watch.NotifyEmail != "mom@example.com" {
+					t.Errorf("watch for row %q alerts %q, want the row's email", r.Source.Row, r.Watch.NotifyEmail)
+				}
+				if r.Source.Row == "Yosemite" && (r.Watch.MinCapacity != 4 || strings.Join(r.Watch.SiteTypes, ",") != "STANDARD NONELECTRIC") {
+					t.Errorf("Yosemite watch has capacity %d and types %v, want the row's filters", r.Watch.MinCapacity, r.Watch.SiteTypes)
+				}
+			}
+			if !reflect.DeepEqual(watches, test.wantWatches) {
+				t.Errorf("active watches %v, want %v", watches, test.wantWatches)
+			}
+			jobs, _ := h.Scheduler.ListJobs(ctx, testConfig.Parent())
+			if len(jobs) != len(test.wantWatches) {
+				t.Errorf("got %d jobs, want %d", len(jobs), len(test.wantWatches))
+			}
+		})
+	}
+}
+
+// Once a new row's status has settled, a sync that changes nothing writes
+// nothing, and a sheet without the required columns is an error that
+// touches no watch.
+func TestSyncSheetWrites(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	defer scraper.UseServices(scraper.Services{Watches: h.Store})()
+	ctx := context.Background()
+	opts := scraper.SheetSyncOptions{Sheet: "family/Watches"}
+
+	sheet := fakerecgov.NewSheet(sheetHeaders[:6], sheetRow("Yosemite", "2027-07-14", "2027-07-16", ""))
+	if _, err := scraper.SyncSheet(ctx, testConfig, sheet, opts); err != nil {
+		t.Fatal(err)
+	}
+	if header := sheet.Cell(0, 6); header != "status" {
+		t.Errorf("status header %q, want one added", header)
+	}
+	if _, err := scraper.SyncSheet(ctx, testConfig, sheet, opts); err != nil {
+		t.Fatal(err)
+	}
+	writes := sheet.Writes()
+	if _, err := scraper.SyncSheet(ctx, testConfig, sheet, opts); err != nil {
+		t.Fatal(err)
+	}
+	if n := sheet.Writes() - writes; n != 0 {
+		t.Errorf("an unchanged sheet was written %d times", n)
+	}
+
+	sheet.SetRows([]string{"Name", "Campground", "Arrival"}, []string{"Yosemite", "232447", "2027-07-14"})
+	if _, err := scraper.SyncSheet(ctx, testConfig, sheet, opts); err == nil || !strings.Contains(err.Error(), `no "departure" column`) {
+		t.Errorf("SyncSheet error %v, want the missing column", err)
+	}
+	if jobs, _ := h.Scheduler.ListJobs(ctx, testConfig.Parent()); len(jobs) != 1 {
+		t.Errorf("got %d jobs after a bad sheet, want the watch kept", len(jobs))
+	}
+}
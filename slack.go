@@ -0,0 +1,319 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Slack action IDs carried on the alert buttons. The button value is the
+// job name of the watch the alert came from.
+const (
+	slackActionPause  = "pause_watch"
+	slackActionDelete = "delete_watch"
+	slackActionSnooze = "snooze_watch"
+)
+
+// slackSnooze is how long the Snooze button quiets a watch for.
+const slackSnooze = 24 * time.Hour
+
+// slackMaxSkew is how old a signed Slack request may be before it is
+// rejected as a possible replay.
+const slackMaxSkew = 5 * time.Minute
+
+type slackText struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type slackButton struct {
+	Type     string    `json:"type"`
+	Text     slackText `json:"text"`
+	ActionID string    `json:"action_id"`
+	Value    string    `json:"value"`
+	Style    string    `json:"style,omitempty"`
+}
+
+type slackBlock struct {
+	Type     string        `json:"type"`
+	Text     *slackText    `json:"text,omitempty"`
+	Elements []slackButton `json:"elements,omitempty"`
+}
+
+type slackMessage struct {
+	Text            string       `json:"text"`
+	Blocks          []slackBlock `json:"blocks,omitempty"`
+	ReplaceOriginal bool         `json:"replace_original,omitempty"`
+}
+
+// sendSlack posts the alert to an incoming webhook.
+func sendSlack(ctx context.Context, webhook string, a alert) error {
+	return postSlack(ctx, webhook, slackAlertMessage(a))
+}
+
+// slackAlertMessage renders a for Slack, linking the hosted results page
+// when there is one, with buttons to pause or delete the watch handled by
+// SlackAction.
+func slackAlertMessage(a alert) slackMessage {
+	sites := a.Sites
+	if len(a.SiteNames) > 0 {
+		sites = make([]string, len(a.Sites))
+		for i, site := range a.Sites {
+			sites[i] = a.siteName(site)
+		}
+	}
+	if a.StayNights != nil {
+		sites = make([]string, len(a.Sites))
+		for i, site := range a.Sites {
+			sites[i] = fmt.Sprintf("%s (%d nights)", a.siteName(site), a.StayNights[site])
+		}
+	}
+	if a.OpenRanges != nil {
+		sites = make([]string, len(a.Sites))
+		for i, site := range a.Sites {
+			sites[i] = a.siteName(site)
+			if partial := partialStay(a, site); partial != "" {
+				sites[i] = fmt.Sprintf("%s (partial: %s)", sites[i], partial)
+			}
+		}
+	}
+	if a.Tours != nil {
+		sites = make([]string, len(a.Sites))
+		for i, site := range a.Sites {
+			sites[i] = site
+			if slot, ok := a.Tours[site]; ok {
+				sites[i] += fmt.Sprintf(" (%d of %d tickets left)", slot.Remaining, slot.Total)
+			}
+		}
+	}
+	if len(a.UnknownCapacity) > 0 || len(a.UnknownAttributes) > 0 || len(a.Remaining) > 0 || a.Prices != nil {
+		sites = append([]string{}, sites...)
+		for i, site := range a.Sites {
+			if n, ok := a.Remaining[site]; ok {
+				sites[i] += fmt.Sprintf(" (%d left)", n)
+			}
+			if a.UnknownCapacity[site] {
+				sites[i] += " (unknown capacity)"
+			}
+			if a.UnknownAttributes[site] {
+				sites[i] += " (attributes unknown)"
+			}
+			if price := a.priceLabel(site); price != "" {
+				sites[i] += " (" + price + ")"
+			}
+		}
+	}
+	text := fmt.Sprintf("Available sites found for %s, %s: %s", a.place(), a.stay(), strings.Join(sites, ", "))
+	if a.MonitoredSite != "" {
+		text = fmt.Sprintf("Calendar changed for %s at %s on %d nights: %s", a.monitorName(), a.place(), a.ChangedNights, strings.Join(sites, ", "))
+	}
+	if a.Tours != nil {
+		text = fmt.Sprintf("Tour tickets found at facility %s, %s: %s. Book at %s%s", a.CampgroundID, a.tourDays(), strings.Join(sites, ", "), ticketURL, a.CampgroundID)
+	}
+	if a.Primary != "" {
+		text = "*Book this one first: site " + a.siteName(a.Primary) + "*\n" + text
+	}
+	if a.Diff != nil {
+		text = "*" + a.Diff.summary() + "*\n" + text
+	}
+	if a.Held != nil {
+		text = "_" + a.Held.summary() + "_\n" + text
+	}
+	if len(a.Runs) > 0 {
+		text += "\n```\n" + core.SummarizeRuns(a.Runs).Text() + "```"
+	}
+	if lines := forecastLines(a.Forecast); len(lines) > 0 {
+		text += "\nForecast: " + strings.Join(lines, "; ")
+	}
+	if note := timeToBookNote(a.TypicallyGone); note != "" {
+		text += "\n_" + note + "_"
+	}
+	if a.MissingAttributes > 0 {
+		text += "\n_" + missingAttributesNote(a.MissingAttributes) + "_"
+	}
+	if a.ClusterNote != "" {
+		text += "\n_" + a.ClusterNote + "_"
+	}
+	if note := a.Requested.note(); note != "" {
+		text += "\n_" + note + "_"
+	}
+	for _, line := range failedLines(a) {
+		text += "\n_Not checked: " + line + "_"
+	}
+	for _, line := range pausedLines(a) {
+		text += "\n_Paused: " + line + "_"
+	}
+	if a.Rehearsal {
+		text = "*[" + rehearsalLabel + "]* " + text
+	}
+	if a.ResultsURL != "" {
+		text += fmt.Sprintf(" <%s|Full results>", a.ResultsURL)
+	}
+	return slackMessage{
+		Text: text,
+		Blocks: []slackBlock{
+			{Type: "section", Text: &slackText{Type: "mrkdwn", Text: text}},
+			{Type: "actions", Elements: []slackButton{
+				{Type: "button", Text: slackText{"plain_text", "Pause watch"}, ActionID: slackActionPause, Value: a.JobName},
+				{Type: "button", Text: slackText{"plain_text", "Snooze 24h"}, ActionID: slackActionSnooze, Value: a.JobName},
+				{Type: "button", Text: slackText{"plain_text", "Delete watch"}, ActionID: slackActionDelete, Value: a.JobName, Style: "danger"},
+			}},
+		},
+	}
+}
+
+func postSlack(ctx context.Context, target string, message slackMessage) error {
+	body, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("posting to slack: %v", err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("posting to slack: status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// SlackAction is an HTTP function receiving Slack interaction payloads from
+// the alert buttons. Requests are verified against SLACK_SIGNING_SECRET before
+// the action is applied to the watch named in the button value, and the
+// original alert is replaced with the outcome.
+func SlackAction(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "request too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	err = verifySlackSignature(os.Getenv("SLACK_SIGNING_SECRET"), r.Header.Get("X-Slack-Request-Timestamp"),
+		r.Header.Get("X-Slack-Signature"), body, DefaultScraper.now())
+	if err != nil {
+		logger.Println("rejected slack action:", err)
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "malformed payload", http.StatusBadRequest)
+		return
+	}
+	payload := struct {
+		ResponseURL string `json:"response_url"`
+		User        struct {
+			Name string `json:"name"`
+		} `json:"user"`
+		Actions []struct {
+			ActionID string `json:"action_id"`
+			Value    string `json:"value"`
+		} `json:"actions"`
+	}{}
+	if err := json.Unmarshal([]byte(form.Get("payload")), &payload); err != nil || len(payload.Actions) == 0 {
+		http.Error(w, "malformed payload", http.StatusBadRequest)
+		return
+	}
+
+	action := payload.Actions[0]
+	outcome := applySlackAction(r.Context(), action.ActionID, action.Value, payload.User.Name)
+	w.WriteHeader(http.StatusOK)
+
+	if payload.ResponseURL == "" {
+		return
+	}
+	if err := postSlack(r.Context(), payload.ResponseURL, slackMessage{Text: outcome, ReplaceOriginal: true}); err != nil {
+		logger.Println("failed to update slack message:", err)
+	}
+}
+
+// verifySlackSignature checks a request against Slack's v0 signing scheme.
+func verifySlackSignature(secret string, timestamp string, signature string, body []byte, now time.Time) error {
+	if secret == "" {
+		return fmt.Errorf("SLACK_SIGNING_SECRET is not set")
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad timestamp %q", timestamp)
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return fmt.Errorf("timestamp is %v away from now", skew)
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":"))
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("signature mismatch")
+	}
+	return nil
+}
+
+// applySlackAction performs a button action and returns the text that
+// replaces the alert in Slack.
+func applySlackAction(ctx context.Context, actionID string, jobName string, user string) string {
+	parsed, err := ParseJobName(jobName)
+	if err != nil {
+		return err.Error()
+	}
+	c, err := newWatchScheduler()
+	if err != nil {
+		logger.Println("slack action: scheduler client:", err)
+		return "Could not reach Cloud Scheduler, try again."
+	}
+
+	name := parsed.String()
+	job, err := c.GetJob(ctx, name)
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return fmt.Sprintf("Watch %s no longer exists.", jobName)
+		}
+		logger.Println("slack action: get job:", err)
+		return "Could not look up the watch, try again."
+	}
+
+	switch actionID {
+	case slackActionPause:
+		if err := c.PauseJob(ctx, name); err != nil {
+			logger.Println("slack action: pause job:", err)
+			return "Could not pause the watch, try again."
+		}
+		return fmt.Sprintf("Watch %s paused by %s.", jobName, user)
+	case slackActionSnooze:
+		until, err := snoozeJob(ctx, c, job, slackSnooze)
+		if err != nil {
+			logger.Println("slack action:", err)
+			return "Could not snooze the watch, try again."
+		}
+		return fmt.Sprintf("Watch %s snoozed by %s until %s.", jobName, user, until.Format("Jan 2 15:04 MST"))
+	case slackActionDelete:
+		if err := deleteJob(ctx, jobName); err != nil {
+			logger.Println("slack action: delete job:", err)
+			return "Could not delete the watch, try again."
+		}
+		markWatchCompleted(ctx, newWatchStore(activeConfig), parsed.Job)
+		return fmt.Sprintf("Watch %s deleted by %s.", jobName, user)
+	}
+	return fmt.Sprintf("Unknown action %q.", actionID)
+}
@@ -0,0 +1,247 @@
+package scraper_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+const slackSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// slackResponses collects the messages SlackAction posts to a payload's
+// response_url.
+type slackResponses struct {
+	mu    sync.Mutex
+	texts []string
+}
+
+func (s *slackResponses) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var message struct {
+		Text string `json:"text"`
+	}
+	json.NewDecoder(r.Body).Decode(&message)
+	s.mu.Lock()
+	s.texts = append(s.texts, message.Text)
+	s.mu.Unlock()
+}
+
+func (s *slackResponses) last() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.texts) == 0 {
+		return ""
+	}
+	return s.texts[len(s.texts)-1]
+}
+
+// slackRequest is the interaction Slack sends when user presses the button
+// actionID on an alert for jobName, signed at now with secret.
+func slackRequest(secret string, now time.Time, responseURL string, actionID string, jobName string) *http.Request {
+	payload := fmt.Sprintf(`{"response_url":%q,"user":{"name":"sam"},"actions":[{"action_id":%q,"value":%q}]}`,
+		responseURL, actionID, jobName)
+	body := url.Values{"payload": {payload}}.Encode()
+	timestamp := fmt.Sprint(now.Unix())
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + body))
+	r := httptest.NewRequest(http.MethodPost, "/slack", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestSlackActionSignature(t *testing.T) {
+	now := time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		secret  string
+		request func() *http.Request
+		want    int
+	}{
+		{"signed", slackSecret, func() *http.Request {
+			return slackRequest(slackSecret, now, "", "pause_watch", fakerecgov.JobName("slack-signed"))
+		}, http.StatusOK},
+		{"wrong secret", slackSecret, func() *http.Request {
+			return slackRequest("not the secret", now, "", "pause_watch", fakerecgov.JobName("slack-signed"))
+		}, http.StatusUnauthorized},
+		{"replayed", slackSecret, func() *http.Request {
+			return slackRequest(slackSecret, now.Add(-6*time.Minute), "", "pause_watch", fakerecgov.JobName("slack-signed"))
+		}, http.StatusUnauthorized},
+		{"tampered", slackSecret, func() *http.Request {
+			r := slackRequest(slackSecret, now, "", "pause_watch", fakerecgov.JobName("slack-signed"))
+			body, _ := ioutil.ReadAll(r.Body)
+			r.Body = ioutil.NopCloser(strings.NewReader(strings.Replace(string(body), "pause_watch", "delete_watch", 1)))
+			return r
+		}, http.StatusUnauthorized},
+		{"unsigned", slackSecret, func() *http.Request {
+			r := slackRequest(slackSecret, now, "", "pause_watch", fakerecgov.JobName("slack-signed"))
+			r.Header.Del("X-Slack-Signature")
+			return r
+		}, http.StatusUnauthorized},
+		{"no secret configured", "", func() *http.Request {
+			return slackRequest("", now, "", "pause_watch", fakerecgov.JobName("slack-signed"))
+		}, http.StatusUnauthorized},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("SLACK_SIGNING_SECRET", test.secret)
+			h := fakerecgov.Start(e2eFixture(true))
+			defer h.Close()
+			h.Clock.Set(now)
+			h.Scheduler.AddJob(&schedulerpb.Job{Name: fakerecgov.JobName("slack-signed")})
+
+			w := httptest.NewRecorder()
+			scraper.SlackAction(w, test.request())
+			if w.Code != test.want {
+				t.Errorf("SlackAction answered %d, want %d", w.Code, test.want)
+			}
+			paused := h.Scheduler.Job(fakerecgov.JobName("slack-signed")).State == schedulerpb.Job_PAUSED
+			if paused != (test.want == http.StatusOK) {
+				t.Errorf("job paused = %v after a %d", paused, w.Code)
+			}
+		})
+	}
+}
+
+func TestSlackActionDispatch(t *testing.T) {
+	now := time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		action string
+		// missing leaves the watch's job out of the scheduler.
+		missing   bool
+		wantText  string
+		wantState schedulerpb.Job_State
+		// wantGone is set when the job must be gone afterwards.
+		wantGone bool
+	}{
+		{name: "pause", action: "pause_watch", wantText: "paused by sam", wantState: schedulerpb.Job_PAUSED},
+		{name: "snooze", action: "snooze_watch", wantText: "snoozed by sam until Jun 2 12:00 UTC", wantState: schedulerpb.Job_ENABLED},
+		{name: "delete", action: "delete_watch", wantText: "deleted by sam", wantGone: true},
+		{name: "unknown", action: "book_it", wantText: `Unknown action "book_it"`, wantState: schedulerpb.Job_ENABLED},
+		{name: "watch gone", action: "pause_watch", missing: true, wantText: "no longer exists", wantGone: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("SLACK_SIGNING_SECRET", slackSecret)
+			h := fakerecgov.Start(e2eFixture(true))
+			defer h.Close()
+			h.Clock.Set(now)
+			responses := &slackResponses{}
+			server := httptest.NewServer(responses)
+			defer server.Close()
+			ctx := context.Background()
+			m := e2eWatch("slack-" + strings.Replace(test.name, " ", "-", -1))
+			if !test.missing {
+				h.Scheduler.AddJob(&schedulerpb.Job{Name: m.Name, Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{}}})
+			}
+			h.Store.SetLastScan(ctx, m.Name, []string{"1001"})
+			h.Store.SetLastNotified(ctx, m.Name, []string{"1001"})
+
+			w := httptest.NewRecorder()
+			scraper.SlackAction(w, slackRequest(slackSecret, now, server.URL, test.action, m.Name))
+			if w.Code != http.StatusOK {
+				t.Fatalf("SlackAction answered %d", w.Code)
+			}
+			if text := responses.last(); !strings.Contains(text, test.wantText) {
+				t.Errorf("replaced the alert with %q, want it to say %q", text, test.wantText)
+			}
+			job := h.Scheduler.Job(m.Name)
+			if (job == nil) != test.wantGone {
+				t.Fatalf("job exists = %v, want %v", job != nil, !test.wantGone)
+			}
+			if job != nil && job.State != test.wantState {
+				t.Errorf("job state %v, want %v", job.State, test.wantState)
+			}
+			scan, _ := h.Store.LastScan(ctx, m.Name)
+			notified, _ := h.Store.LastNotified(ctx, m.Name)
+			if pruned := len(scan) == 0 && len(notified) == 0; pruned != (test.action == "delete_watch") {
+				t.Errorf("stored state pruned = %v after %s", pruned, test.action)
+			}
+		})
+	}
+}
+
+func TestSlackDeleteCompletesRegisteredWatch(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", slackSecret)
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	defer scraper.UseServices(scraper.Services{Watches: h.Store})()
+	ctx := context.Background()
+	// The button carries the watch's ID, which names its job in the
+	// deployment's own project and location.
+	cfg, _ := scraper.ConfigFromEnv()
+	created, err := scraper.CreateWatch(ctx, cfg, newWatch("2027-07-14", "2027-07-16"), "*/10 * * * *", scraper.CreateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	scraper.SlackAction(w, slackRequest(slackSecret, h.Clock.Now(), "", "delete_watch", created.Name.Job))
+	if w.Code != http.StatusOK {
+		t.Fatalf("SlackAction answered %d", w.Code)
+	}
+	if h.Scheduler.Job(created.Name.String()) != nil {
+		t.Errorf("job %s still scheduled", created.Name)
+	}
+	r, err := h.Store.GetWatch(ctx, created.Name.Job)
+	if err != nil || r.Status != scraper.WatchCompleted {
+		t.Errorf("registered watch %+v, %v; want it completed", r, err)
+	}
+}
+
+// A snoozed watch's runs find nothing to alert for 24 hours, by the fake
+// clock, and then alert as normal.
+func TestSlackSnooze(t *testing.T) {
+	t.Setenv("SLACK_SIGNING_SECRET", slackSecret)
+	h := fakerecgov.Start(e2eFixture(true))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	m := e2eWatch("slack-snoozed")
+	h.Scheduler.AddJob(&schedulerpb.Job{Name: m.Name, Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{
+		Data: fakerecgov.Message(m).Data,
+	}}})
+
+	w := httptest.NewRecorder()
+	scraper.SlackAction(w, slackRequest(slackSecret, h.Clock.Now(), "", "snooze_watch", m.Name))
+	if w.Code != http.StatusOK {
+		t.Fatalf("SlackAction answered %d", w.Code)
+	}
+	for _, after := range []time.Duration{0, 23*time.Hour + 59*time.Minute} {
+		h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC).Add(after))
+		if err := h.Fire(ctx, m.Name); err != nil {
+			t.Fatalf("run %v into the snooze: %v", after, err)
+		}
+		if n := len(h.Notifier.Alerts()); n != 0 {
+			t.Fatalf("got %d alerts %v into the snooze", n, after)
+		}
+		if months := h.Server.MonthsFetched("232447"); len(months) != 0 {
+			t.Fatalf("fetched %v %v into the snooze", months, after)
+		}
+	}
+
+	h.Clock.Set(time.Date(2027, 6, 2, 12, 0, 0, 0, time.UTC))
+	if err := h.Fire(ctx, m.Name); err != nil {
+		t.Fatalf("run after the snooze: %v", err)
+	}
+	if n := len(h.Notifier.Alerts()); n != 1 {
+		t.Errorf("got %d alerts once the snooze ended, want 1", n)
+	}
+}
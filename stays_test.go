@@ -0,0 +1,65 @@
+package scraper_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// A flexible-departure watch alerts the sites bookable for at least
+// MinNights from Arrival, longest stay first, after its type filters.
+func TestFlexibleDepartureWatch(t *testing.T) {
+	open := func(nights ...string) map[string]string {
+		m := map[string]string{}
+		for _, night := range nights {
+			m[night] = "Available"
+		}
+		return m
+	}
+	tests := []struct {
+		name     string
+		types    []string
+		excluded []string
+		want     []string
+	}{
+		{name: "all types", want: []string{"1002", "1001"}},
+		{name: "standard sites", types: []string{"STANDARD"}, want: []string{"1001"}},
+		{name: "no tent sites", excluded: []string{"TENT ONLY"}, want: []string{"1001"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fixture := e2eFixture(false)
+			sites := fixture.Campgrounds[0].Sites
+			sites[0].Nights = open("2027-07-30", "2027-07-31", "2027-08-01")
+			sites[1].Nights = open("2027-07-30", "2027-07-31", "2027-08-01", "2027-08-02", "2027-08-03", "2027-08-04", "2027-08-05")
+			sites[2].Nights = open("2027-07-30")
+			h := fakerecgov.Start(fixture)
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			m := e2eWatch("flexible-departure")
+			m.Arrival, m.Departure, m.MinNights, m.MaxNights = "2027-07-30", "", 2, 5
+			m.SiteTypes, m.ExcludeTypes = test.types, test.excluded
+
+			if err := h.Run(context.Background(), m); err != nil {
+				t.Fatalf("Run: %v", err)
+			}
+			if got := h.Server.MonthsFetched("232447"); !reflect.DeepEqual(got, []string{"2027-07", "2027-08"}) {
+				t.Errorf("fetched months %v, want July and August", got)
+			}
+			alerts := h.Notifier.Alerts()
+			if len(alerts) != 1 {
+				t.Fatalf("got %d alerts, want 1", len(alerts))
+			}
+			got := []string{}
+			for _, site := range alerts[0].Sites {
+				got = append(got, site.ID)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("alerted %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// statePrefix is where persistent watches keep their last-notified state in
+// RESULTS_BUCKET.
+const statePrefix = "state/"
+
+// Store keeps, per watch, the set of site and night keys last notified, so
+// a persistent watch only alerts when availability changes.
+type Store interface {
+	// LastNotified returns the keys last stored for job, or none.
+	LastNotified(ctx context.Context, job string) ([]string, error)
+	SetLastNotified(ctx context.Context, job string, keys []string) error
+	// DeleteLastNotified forgets job, once its watch is deleted.
+	DeleteLastNotified(ctx context.Context, job string) error
+}
+
+// MemoryStore is a Store, ScanStore, DeliveryStore, WatchStore,
+// ContactStore, OpeningStore, BackfillStore and IdempotencyStore held in
+// process memory. State is lost whenever the
+// function instance is recycled, so it suits tests and local runs only.
+type MemoryStore struct {
+	mu       sync.Mutex
+	state    map[string][]string
+	scans    map[string][]string
+	delivery map[string]deliveryState
+	watches  map[string]WatchRecord
+	claims   map[string]time.Time
+	contacts map[string]Contact
+	openings map[string]OpeningState
+	// openingVersions counts the writes to each campground's openings.
+	openingVersions map[string]int64
+	backfill        map[string][]string
+}
+
+// LastNotified implements Store.
+func (s *MemoryStore) LastNotified(ctx context.Context, job string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state[job], nil
+}
+
+// SetLastNotified implements Store.
+func (s *MemoryStore) SetLastNotified(ctx context.Context, job string, keys []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == nil {
+		s.state = map[string][]string{}
+	}
+	s.state[job] = append([]string(nil), keys...)
+	return nil
+}
+
+// DeleteLastNotified implements Store.
+func (s *MemoryStore) DeleteLastNotified(ctx context.Context, job string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.state, job)
+	return nil
+}
+
+// BucketStore is a Store keeping one JSON object per watch under state/ in
+// a GCS bucket, and a ScanStore doing the same under scans/.
+type BucketStore struct {
+	Bucket string
+}
+
+// LastNotified implements Store.
+func (s BucketStore) LastNotified(ctx context.Context, job string) ([]string, error) {
+	client, err := storageClient()
+	if err != nil {
+		return nil, err
+	}
+	r, err := client.Bucket(s.Bucket).Object(statePrefix + job + ".json").NewReader(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var keys []string
+	if err := json.NewDecoder(r).Decode(&keys); err != nil {
+		return nil, fmt.Errorf("decoding state for %s: %v", job, err)
+	}
+	return keys, nil
+}
+
+// SetLastNotified implements Store.
+func (s BucketStore) SetLastNotified(ctx context.Context, job string, keys []string) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	w := client.Bucket(s.Bucket).Object(statePrefix + job + ".json").NewWriter(ctx)
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(keys); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// DeleteLastNotified implements Store.
+func (s BucketStore) DeleteLastNotified(ctx context.Context, job string) error {
+	client, err := storageClient()
+	if err != nil {
+		return err
+	}
+	err = client.Bucket(s.Bucket).Object(statePrefix + job + ".json").Delete(ctx)
+	if err == storage.ErrObjectNotExist {
+		return nil
+	}
+	return err
+}
+
+// stateStore is where persistent watches keep their state: RESULTS_BUCKET
+// when set, memory otherwise. Replace it in tests.
+var stateStore Store = defaultStore()
+
+func defaultStore() Store {
+	if bucket := os.Getenv("RESULTS_BUCKET"); bucket != "" {
+		return BucketStore{Bucket: bucket}
+	}
+	return &MemoryStore{}
+}
+
+// availabilityKeys lists every site and night in a as "site@YYYY-MM-DD",
+// sorted, so two alerts with the same openings have the same keys.
+func availabilityKeys(a alert) []string {
+	keys := []string{}
+	add := func(site string, start, end core.CivilDate) {
+		for _, night := range core.Nights(start, end) {
+			keys = append(keys, site+"@"+night.String())
+		}
+	}
+	switch {
+	case len(a.Runs) > 0:
+		for _, run := range a.Runs {
+			add(run.Site, run.Start, run.End)
+		}
+	case a.OpenRanges != nil:
+		for _, run := range a.OpenRanges {
+			add(run.Site, run.Start, run.End)
+		}
+	default:
+		for _, site := range a.Sites {
+			if site == rehearsalLabel {
+				continue
+			}
+			end := a.Departure
+			if n, ok := a.StayNights[site]; ok {
+				end = a.Arrival.AddDays(n)
+			}
+			add(site, a.Arrival, end)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// notifyIfChanged is the tail of a persistent watch's run: it alerts only
+// when the openings differ from those last notified, and never deletes the
+// job. Incomplete scans are ignored. The stored state is only replaced once an alert has gone out on at
+// least one channel, so a failed delivery is retried on the next run. sent
+// reports whether an alert went out.
+func notifyIfChanged(ctx context.Context, m MessageContent, a alert) (sent bool, err error) {
+	if a.Partial != nil {
+		// An incomplete scan would look like sites disappearing.
+		logger.Printf("job %s: scan incomplete, leaving notified state as is", m.Name)
+		return false, nil
+	}
+	keys := availabilityKeys(a)
+	last, err := stateStore.LastNotified(ctx, m.Name)
+	if err != nil {
+		return false, fmt.Errorf("job %s: reading notified state: %w", m.Name, err)
+	}
+	if sameStrings(keys, last) {
+		logger.Printf("job %s: availability unchanged, not notifying", m.Name)
+		return false, nil
+	}
+	if len(a.Sites) > 0 {
+		a.Persistent = true
+		err := sendAlert(ctx, a)
+		if multi, ok := err.(*MultiError); ok && multi.AllFailed() {
+			return false, fmt.Errorf("job %s: sending alerts: %w", m.Name, err)
+		}
+		if err != nil {
+			logger.Println("sending alerts:", err)
+		}
+		sent = true
+	}
+	return sent, stateStore.SetLastNotified(ctx, m.Name, keys)
+}
+
+func sameStrings(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,224 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"google.golang.org/api/iterator"
+)
+
+// closedPrefix is where closed-watch summaries live in ARCHIVE_BUCKET.
+const closedPrefix = "closed/"
+
+// WatchOutcome is why a watch ended.
+type WatchOutcome string
+
+// Watch outcomes.
+const (
+	WatchFound        WatchOutcome = "found"
+	WatchCutoff       WatchOutcome = "cutoff"
+	WatchSeasonClosed WatchOutcome = "season closed"
+	WatchExpired      WatchOutcome = "expired"
+)
+
+// WatchSummary is the end-of-watch record: how long the watch ran and what
+// it reported over its lifetime. Fields are left zero when their source is
+// unavailable; scans are not recorded anywhere, so only the last one is
+// known.
+type WatchSummary struct {
+	Job          string       `json:"job"`
+	CampgroundID string       `json:"campground_id"`
+	Arrival      time.Time    `json:"arrival"`
+	Departure    time.Time    `json:"departure"`
+	Outcome      WatchOutcome `json:"outcome"`
+	// Since is when the job was created or last edited.
+	Since    time.Time `json:"since,omitempty"`
+	EndedAt  time.Time `json:"ended_at"`
+	LastScan time.Time `json:"last_scan,omitempty"`
+	// FirstAvailable is when an alert for the watch was first sent.
+	FirstAvailable time.Time `json:"first_available,omitempty"`
+	Notifications  int       `json:"notifications"`
+	// Sites is every site reported over the watch's lifetime, including
+	// the ones in the alert being sent now.
+	Sites   []string `json:"sites"`
+	Claimed []string `json:"claimed"`
+}
+
+// buildWatchSummary gathers what is known about m's lifetime from the
+// scheduler job, the notification archive and the claim records. It never
+// fails; a source that cannot be read is logged and skipped.
+func buildWatchSummary(ctx context.Context, m MessageContent, outcome WatchOutcome, sites []string) WatchSummary {
+	now := DefaultScraper.now().UTC()
+	s := WatchSummary{
+		Job:          m.Name,
+		CampgroundID: m.Campground,
+		Arrival:      stayDate(m.Arrival).Time(),
+		Departure:    stayDate(m.Departure).Time(),
+		Outcome:      outcome,
+		EndedAt:      now,
+		Sites:        []string{},
+		Claimed:      []string{},
+	}
+	if since, last, err := jobTimes(ctx, m.Name); err != nil {
+		logger.Printf("job %s: summary: %v", m.Name, err)
+	} else {
+		s.Since, s.LastScan = since, last
+	}
+
+	reported := map[string]bool{}
+	if os.Getenv("ARCHIVE_BUCKET") != "" {
+		records, err := GetNotificationArchive(ctx, m.Name, time.Time{})
+		if err != nil {
+			logger.Printf("job %s: summary: %v", m.Name, err)
+		}
+		for _, r := range records {
+			if r.Rehearsal || r.Outcome != "sent" {
+				continue
+			}
+			s.Notifications++
+			if s.FirstAvailable.IsZero() {
+				s.FirstAvailable = r.At
+			}
+			for _, site := range r.Sites {
+				reported[site] = true
+			}
+		}
+	}
+	if len(sites) > 0 && s.FirstAvailable.IsZero() {
+		s.FirstAvailable = now
+	}
+	for _, site := range sites {
+		reported[site] = true
+	}
+	for site := range reported {
+		s.Sites = append(s.Sites, site)
+	}
+	sort.Strings(s.Sites)
+
+	if claimed, err := claimedSites(ctx, m.Name); err != nil {
+		logger.Printf("job %s: summary: %v", m.Name, err)
+	} else {
+		s.Claimed = claimed
+	}
+	return s
+}
+
+// jobTimes returns when the job was last edited by a user and when it last
+// ran.
+func jobTimes(ctx context.Context, jobName string) (time.Time, time.Time, error) {
+	name, err := ParseJobName(jobName)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	c, err := newWatchScheduler()
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	job, err := c.GetJob(ctx, name.String())
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("getting job %s: %v", name, err)
+	}
+	var since, last time.Time
+	if t := protoTime(job.GetUserUpdateTime()); t != nil {
+		since = *t
+	}
+	if t := protoTime(job.GetLastAttemptTime()); t != nil {
+		last = *t
+	}
+	return since, last, nil
+}
+
+// claimedSites lists the sites someone used a claim link for, or nil when
+// claims are not configured.
+func claimedSites(ctx context.Context, job string) ([]string, error) {
+	bucket := os.Getenv("RESULTS_BUCKET")
+	if bucket == "" {
+		return nil, nil
+	}
+	client, err := storageClient()
+	if err != nil {
+		return nil, err
+	}
+	prefix := claimPrefix + job + "/"
+	sites := []string{}
+	it := client.Bucket(bucket).Objects(ctx, &storage.Query{Prefix: prefix})
+	for {
+		attrs, err := it.Next()
+		if err == iterator.Done {
+			break
+		}
+		if err != nil {
+			return sites, err
+		}
+		sites = append(sites, strings.TrimSuffix(strings.TrimPrefix(attrs.Name, prefix), ".json"))
+	}
+	sort.Strings(sites)
+	return sites, nil
+}
+
+// Text renders the summary as plain-text lines.
+func (s WatchSummary) Text() string {
+	lines := []string{fmt.Sprintf("Watch %s ended (%s).", s.Job, s.Outcome)}
+	if !s.Since.IsZero() {
+		lines = append(lines, fmt.Sprintf("Ran for %s, since %s.",
+			s.EndedAt.Sub(s.Since).Round(time.Minute), s.Since.Format(time.RFC1123)))
+	}
+	if !s.LastScan.IsZero() {
+		lines = append(lines, "Last scheduled scan: "+s.LastScan.Format(time.RFC1123)+".")
+	}
+	if s.FirstAvailable.IsZero() {
+		lines = append(lines, "No availability was reported.")
+	} else {
+		lines = append(lines, fmt.Sprintf("Availability first reported %s; %d notifications sent.",
+			s.FirstAvailable.Format(time.RFC1123), s.Notifications))
+	}
+	if len(s.Sites) > 0 {
+		lines = append(lines, "Sites reported: "+strings.Join(s.Sites, ", ")+".")
+	}
+	if len(s.Claimed) > 0 {
+		lines = append(lines, "Marked as booking: "+strings.Join(s.Claimed, ", ")+".")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// HTML renders the summary as a paragraph per line of Text.
+func (s WatchSummary) HTML() string {
+	out := ""
+	for _, line := range strings.Split(s.Text(), "\n") {
+		out += "<p>" + html.EscapeString(line) + "</p>"
+	}
+	return out
+}
+
+// recordClosedWatch stores s in ARCHIVE_BUCKET as the watch's closed record.
+// Like archiveNotification it is best effort.
+func recordClosedWatch(ctx context.Context, s WatchSummary) {
+	bucket := os.Getenv("ARCHIVE_BUCKET")
+	if bucket == "" {
+		return
+	}
+	client, err := storageClient()
+	if err != nil {
+		logger.Println("recording closed watch:", err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, archiveTimeout)
+	defer cancel()
+	w := client.Bucket(bucket).Object(closedPrefix + s.Job + ".json").NewWriter(ctx)
+	w.ContentType = "application/json"
+	if err := json.NewEncoder(w).Encode(s); err != nil {
+		w.Close()
+		logger.Println("recording closed watch:", err)
+		return
+	}
+	if err := w.Close(); err != nil {
+		logger.Println("recording closed watch:", err)
+	}
+}
@@ -0,0 +1,122 @@
+package scraper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// ticketURL is the recreation.gov booking page for a ticketed facility's
+// ID.
+const ticketURL = "https://www.recreation.gov/ticket/facility/"
+
+// isTour reports whether m watches a facility's ticketed tours.
+func (m MessageContent) isTour() bool {
+	return m.Kind == kindTour
+}
+
+// validateTour rejects tour options on watches of another kind, and a
+// negative PartySize or unreadable TourTimes on tour watches.
+func (m MessageContent) validateTour() error {
+	if !m.isTour() {
+		if m.PartySize != 0 || len(m.TourTimes) > 0 {
+			field := "PartySize"
+			if m.PartySize == 0 {
+				field = "TourTimes"
+			}
+			return &ValidationError{Field: field, Reason: "is only supported for tour watches"}
+		}
+		return nil
+	}
+	if m.PartySize < 0 {
+		return &ValidationError{Field: "PartySize", Reason: fmt.Sprintf("%d is negative", m.PartySize)}
+	}
+	for _, w := range m.TourTimes {
+		for _, value := range []string{w.Start, w.End} {
+			if _, err := minuteOfDay(value); err != nil {
+				return &ValidationError{Field: "TourTimes", Value: value, Reason: "is not a local time such as 09:30"}
+			}
+		}
+	}
+	return nil
+}
+
+// tourLabel is how a slot is listed in an alert's Sites, with its start in
+// loc, such as "Wed Jul 14 10:00 AM PDT, tour 2121".
+func tourLabel(slot core.TourSlot, loc *time.Location) string {
+	return fmt.Sprintf("%s, tour %s", slot.At(loc).Format("Mon Jan 2 3:04 PM MST"), slot.TourID)
+}
+
+// tourDays describes the days a's tour watch covers, the last being the
+// day before its Departure, such as "Wed Jul 14 to Thu Jul 15".
+func (a alert) tourDays() string {
+	first, last := a.day(a.Arrival), a.day(a.Departure.AddDays(-1))
+	if first == last {
+		return first
+	}
+	return first + " to " + last
+}
+
+// wantedTime reports whether slot starts within one of m's TourTimes, or m
+// has none.
+func (m MessageContent) wantedTime(slot core.TourSlot, loc *time.Location) bool {
+	if len(m.TourTimes) == 0 {
+		return true
+	}
+	start := slot.At(loc)
+	for _, w := range m.TourTimes {
+		if in, err := w.contains(start, loc); err == nil && in {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTour scrapes the tours in m and builds the alert it would send,
+// without any side effects, like matchPermit. Each of the alert's Sites is a
+// slot with at least PartySize tickets left at a wanted time, described in
+// Tours; start times are in m's TimeZone, the facility's.
+func (s *Scraper) matchTour(ctx context.Context, m MessageContent, rehearsal bool) (alert, error) {
+	arrival, departure := stayDate(m.Arrival), stayDate(m.Departure)
+	a := alert{
+		JobName:      m.Name,
+		Recipient:    m.recipient(),
+		NotifyPhone:  m.NotifyPhone,
+		SlackWebhook: m.slackWebhook,
+		WebhookURL:   m.WebhookURL,
+		CampgroundID: m.Campground,
+		Arrival:      arrival,
+		Departure:    departure,
+		Rehearsal:    rehearsal,
+		ScannedAt:    s.now().UTC(),
+		Tours:        map[string]core.TourSlot{},
+	}
+	slots, err := core.ScrapeTours(ctx, s.Provider(), m.Campground, arrival, departure, m.PartySize)
+	if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) {
+		return a, err
+	}
+	if err != nil && len(slots) > 0 {
+		logger.Printf("job %s: tour scan stopped early, reporting %d slots found so far: %v", m.Name, len(slots), err)
+		err = nil
+	}
+	loc := m.location()
+	available := []string{}
+	for _, slot := range slots {
+		if !m.wantedTime(slot, loc) {
+			continue
+		}
+		label := tourLabel(slot, loc)
+		a.Tours[label] = slot
+		available = append(available, label)
+	}
+	if rehearsal {
+		available = append([]string{rehearsalLabel}, available...)
+	}
+	if len(available) > 0 {
+		a.Sites = available
+	}
+	return a, err
+}
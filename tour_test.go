@@ -0,0 +1,74 @@
+package scraper_test
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// A tour watch is alerted to the slots at its wanted times with tickets
+// left for its whole party, listed at the facility's local time; sold-out,
+// cancelled and too-full slots are left out.
+func TestTourWatch(t *testing.T) {
+	if _, err := time.LoadLocation("America/Denver"); err != nil {
+		t.Skip(err)
+	}
+	h := fakerecgov.Start(fakerecgov.Fixture{Tours: []fakerecgov.TourFacility{{ID: "234636", Slots: []fakerecgov.TourSlot{
+		{TourID: 2121, Date: "2027-07-14", Time: "10:00", Capacity: 20, Booked: 20, Status: "SOLD_OUT"},
+		{TourID: 2122, Date: "2027-07-14", Time: "13:30", Capacity: 20, Booked: 16},
+		{TourID: 2123, Date: "2027-07-14", Time: "16:00", Capacity: 12, Booked: 1},
+		{TourID: 2121, Date: "2027-07-15", Time: "10:00", Capacity: 20, Booked: 17},
+		{TourID: 2122, Date: "2027-07-15", Time: "13:30", Capacity: 20, Booked: 2, Status: "CANCELLED"},
+		{TourID: 2121, Date: "2027-07-16", Time: "10:00", Capacity: 20},
+	}}}})
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := scraper.MessageContent{Name: fakerecgov.JobName("tour"), Kind: "tour", Campground: "234636",
+		Arrival: "2027-07-14", Departure: "2027-07-16", PartySize: 4, TimeZone: "America/Denver",
+		TourTimes: []scraper.ScanWindow{{Start: "09:00", End: "15:00"}}}
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	want := []string{
+		"/api/ticket/availability/facility/234636?date=2027-07-14",
+		"/api/ticket/availability/facility/234636?date=2027-07-15",
+	}
+	if got := h.Server.Requests(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests %v, want %v", got, want)
+	}
+	alerts := h.Notifier.Alerts()
+	if len(alerts) != 1 {
+		t.Fatalf("got %d alerts, want 1", len(alerts))
+	}
+	sites := []string{}
+	for _, site := range alerts[0].Sites {
+		sites = append(sites, site.ID)
+	}
+	if want := []string{"Wed Jul 14 1:30 PM MDT, tour 2122"}; !reflect.DeepEqual(sites, want) {
+		t.Errorf("alerted slots %v, want %v", sites, want)
+	}
+}
+
+// A tour watch without any slot to offer sends nothing.
+func TestTourWatchSoldOut(t *testing.T) {
+	h := fakerecgov.Start(fakerecgov.Fixture{Tours: []fakerecgov.TourFacility{{ID: "234636", Slots: []fakerecgov.TourSlot{
+		{TourID: 2121, Date: "2027-07-14", Time: "10:00", Capacity: 20, Booked: 20, Status: "SOLD_OUT"},
+		{TourID: 2122, Date: "2027-07-14", Time: "13:30", Capacity: 20, Booked: 18},
+	}}}})
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	m := scraper.MessageContent{Name: fakerecgov.JobName("tour-sold-out"), Kind: "tour", Campground: "234636",
+		Arrival: "2027-07-14", Departure: "2027-07-15", PartySize: 3}
+
+	if err := h.Run(context.Background(), m); err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if alerts := h.Notifier.Alerts(); len(alerts) != 0 {
+		t.Errorf("got alerts %+v, want none", alerts)
+	}
+}
@@ -0,0 +1,100 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+const (
+	// resumeScheduleAttribute is set on the Pub/Sub target of a job whose
+	// checks were slowed while its stay was not yet released, and holds the
+	// schedule to restore.
+	resumeScheduleAttribute = "resume_schedule"
+	// unreleasedSchedule is how often a watch waiting on the booking
+	// window checks.
+	unreleasedSchedule = "0 */6 * * *"
+	// unreleasedLead is how long before the estimated release a slowed
+	// watch goes back to its own schedule, so it never sleeps through the
+	// release itself.
+	unreleasedLead = 6 * time.Hour
+)
+
+// estimatedRelease is when a stay whose last unreleased night is last
+// should become bookable, going by ReleaseOptions' defaults: the usual
+// 6-month rolling window, released at 10:00 Eastern. recreation.gov's
+// availability does not say when nights are released, so this is a guess.
+func estimatedRelease(last core.CivilDate) time.Time {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		loc = time.UTC
+	}
+	return ReleaseInstant(last, 6, 10, loc)
+}
+
+// paceForRelease slows the job of a watch whose stay is not yet released to
+// unreleasedSchedule, and restores its own schedule once the stay is
+// released or the estimated release is close. attributes are the message's,
+// which carry the schedule to restore while the job is slowed. Failures are
+// logged only; the watch keeps working at whatever cadence it has.
+func paceForRelease(ctx context.Context, m MessageContent, a alert, attributes map[string]string) {
+	resume := attributes[resumeScheduleAttribute]
+	waiting := a.Class == core.ClassNotYetReleased
+	var release time.Time
+	if waiting {
+		release = estimatedRelease(a.LastUnreleased)
+		waiting = DefaultScraper.now().Before(release.Add(-unreleasedLead))
+	}
+	switch {
+	case waiting && resume == "":
+		previous, err := reschedule(ctx, m.Name, unreleasedSchedule, true)
+		if err != nil {
+			logger.Printf("job %s: slowing checks until release: %v", m.Name, err)
+			return
+		}
+		logger.Printf("job %s: booking opens ~%s, checking every 6 hours until then instead of on %q",
+			m.Name, release.Format("Jan 2"), previous)
+	case waiting:
+		logger.Printf("job %s: booking opens ~%s, checks stay slowed", m.Name, release.Format("Jan 2"))
+	case resume != "":
+		if _, err := reschedule(ctx, m.Name, resume, false); err != nil {
+			logger.Printf("job %s: restoring schedule %q: %v", m.Name, resume, err)
+			return
+		}
+		logger.Printf("job %s: back on its schedule %q", m.Name, resume)
+	}
+}
+
+// reschedule sets the schedule of jobName's job and returns the one it
+// replaced. With remember set, the replaced schedule is kept in the job's
+// resumeScheduleAttribute; otherwise the attribute is cleared.
+func reschedule(ctx context.Context, jobName string, schedule string, remember bool) (string, error) {
+	name, err := ParseJobName(jobName)
+	if err != nil {
+		return "", err
+	}
+	c, err := newWatchScheduler()
+	if err != nil {
+		return "", err
+	}
+	job, err := c.GetJob(ctx, name.String())
+	if err != nil {
+		return "", err
+	}
+	target := job.GetPubsubTarget()
+	if target == nil {
+		return "", fmt.Errorf("job %s has no Pub/Sub target", name)
+	}
+	previous := job.Schedule
+	if target.Attributes == nil {
+		target.Attributes = map[string]string{}
+	}
+	delete(target.Attributes, resumeScheduleAttribute)
+	if remember {
+		target.Attributes[resumeScheduleAttribute] = previous
+	}
+	job.Schedule = schedule
+	return previous, c.UpdateJob(ctx, job, []string{"schedule", "pubsub_target.attributes"})
+}
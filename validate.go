@@ -0,0 +1,218 @@
+package scraper
+
+import (
+	"fmt"
+	netmail "net/mail"
+	"net/url"
+	"regexp"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+var phoneNumberPattern = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+// ValidationError reports the first field of a watch payload that cannot be
+// scraped as written.
+type ValidationError struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	if e.Value == "" {
+		return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+	}
+	return fmt.Sprintf("invalid %s %q: %s", e.Field, e.Value, e.Reason)
+}
+
+// Validate checks that m can be scraped: a name, numeric campground IDs (or
+// a CampgroundName, resolved when the watch runs), dates that parse (in any
+// format core.ParseCivilDate accepts, so both "2006-1-2" and "2006-01-02"),
+// a departure after the arrival and a stay that has not already started.
+// Which dates are required depends on the watch's mode.
+func (m MessageContent) Validate() error {
+	if m.Name == "" {
+		return &ValidationError{Field: "Name", Reason: "must not be empty"}
+	}
+	if err := m.validateKind(); err != nil {
+		return err
+	}
+	if m.Campground != "" || len(m.Campgrounds) > 0 || m.CampgroundName == "" {
+		for _, id := range m.campgrounds() {
+			if err := validateCampgroundID(id); err != nil {
+				return err
+			}
+		}
+	}
+	if m.NotifyEmail != "" {
+		if address, err := netmail.ParseAddress(m.NotifyEmail); err != nil || address.Address != m.NotifyEmail {
+			return &ValidationError{Field: "NotifyEmail", Value: m.NotifyEmail, Reason: "is not a plain email address"}
+		}
+	}
+	if m.Contact != "" && (m.NotifyEmail != "" || m.NotifyName != "" || m.NotifyPhone != "") {
+		return &ValidationError{Field: "Contact", Value: m.Contact, Reason: "cannot be combined with NotifyEmail, NotifyName or NotifyPhone"}
+	}
+	if m.NotifyPhone != "" && !phoneNumberPattern.MatchString(m.NotifyPhone) {
+		return &ValidationError{Field: "NotifyPhone", Value: m.NotifyPhone, Reason: "is not an E.164 number such as +14155550100"}
+	}
+	if m.WebhookURL != "" {
+		if u, err := url.Parse(m.WebhookURL); err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			return &ValidationError{Field: "WebhookURL", Value: m.WebhookURL, Reason: "is not an http or https URL"}
+		}
+	}
+	if m.MinCapacity < 0 {
+		return &ValidationError{Field: "MinCapacity", Reason: fmt.Sprintf("%d is negative", m.MinCapacity)}
+	}
+	if m.MaxNightlyPrice < 0 {
+		return &ValidationError{Field: "MaxNightlyPrice", Reason: fmt.Sprintf("%g is negative", m.MaxNightlyPrice)}
+	}
+	if m.MinSites < 0 || m.MaxSiteGap < 0 {
+		return &ValidationError{Field: "MinSites", Reason: "MinSites and MaxSiteGap must not be negative"}
+	}
+	if (m.MinSites > 0 || m.MaxSiteGap > 0) && !m.FindAdjacentSites {
+		return &ValidationError{Field: "MinSites", Reason: "MinSites and MaxSiteGap need FindAdjacentSites"}
+	}
+	if len(m.CampsiteIDs) > 0 && (len(m.Campgrounds) > 0 || m.groupsSites()) {
+		return &ValidationError{Field: "CampsiteIDs", Reason: "cannot be combined with Campgrounds, AdjacentPairs, RequiredPairs or FindAdjacentSites"}
+	}
+	for _, id := range m.CampsiteIDs {
+		if !isDigits(id) {
+			return &ValidationError{Field: "CampsiteIDs", Value: id, Reason: "is not a numeric campsite ID; use the number in the site's recreation.gov address, not its site name"}
+		}
+	}
+	if m.FindAdjacentSites && (m.AdjacentPairs || len(m.RequiredPairs) > 0) {
+		return &ValidationError{Field: "FindAdjacentSites", Reason: "cannot be combined with AdjacentPairs or RequiredPairs"}
+	}
+	for name, expr := range m.RequiredAttributes {
+		if err := core.ValidateAttributeRequirement(expr); err != nil {
+			return &ValidationError{Field: "RequiredAttributes", Value: name, Reason: err.Error()}
+		}
+	}
+	if m.TimeZone != "" {
+		if _, err := time.LoadLocation(m.TimeZone); err != nil {
+			return &ValidationError{Field: "TimeZone", Value: m.TimeZone, Reason: "is not an IANA time zone such as America/Los_Angeles"}
+		}
+	}
+	if m.Locale != "" && !validLocale(m.Locale) {
+		return &ValidationError{Field: "Locale", Value: m.Locale, Reason: "is not one of en, es, fr or de"}
+	}
+	if (m.QuietHoursStart == "") != (m.QuietHoursEnd == "") {
+		return &ValidationError{Field: "QuietHoursEnd", Value: m.QuietHoursEnd, Reason: "must be set together with QuietHoursStart"}
+	}
+	for field, value := range map[string]string{"QuietHoursStart": m.QuietHoursStart, "QuietHoursEnd": m.QuietHoursEnd} {
+		if _, err := time.Parse("15:04", value); value != "" && err != nil {
+			return &ValidationError{Field: field, Value: value, Reason: "is not a local time such as 22:00"}
+		}
+	}
+	if m.MaxNotificationsPerDay < 0 {
+		return &ValidationError{Field: "MaxNotificationsPerDay", Reason: fmt.Sprintf("%d is negative", m.MaxNotificationsPerDay)}
+	}
+	if m.ExpireAfter != "" && m.ExpireAfter != "arrival" && m.ExpireAfter != "departure" {
+		return &ValidationError{Field: "ExpireAfter", Value: m.ExpireAfter, Reason: `must be "arrival" or "departure"`}
+	}
+	if m.Cutoff != "" {
+		if _, err := time.Parse(time.RFC3339, m.Cutoff); err != nil {
+			return &ValidationError{Field: "Cutoff", Value: m.Cutoff, Reason: "is not an RFC 3339 time"}
+		}
+	}
+	if m.Release != "" {
+		if _, err := time.Parse(time.RFC3339, m.Release); err != nil {
+			return &ValidationError{Field: "Release", Value: m.Release, Reason: "is not an RFC 3339 time"}
+		}
+	}
+	today := m.today()
+
+	if m.Nights > 0 {
+		start, err := validateDate("WindowStart", m.WindowStart)
+		if err != nil {
+			return err
+		}
+		end, err := validateDate("WindowEnd", m.WindowEnd)
+		if err != nil {
+			return err
+		}
+		if !end.After(today) {
+			return &ValidationError{Field: "WindowEnd", Value: m.WindowEnd, Reason: "is already past"}
+		}
+		search, err := m.windowSearch()
+		if err != nil {
+			return &ValidationError{Field: "RequiredWeekdays", Reason: err.Error()}
+		}
+		search.WindowStart, search.WindowEnd = start, end
+		if err := search.Validate(); err != nil {
+			return &ValidationError{Field: "Nights", Reason: err.Error()}
+		}
+		return nil
+	}
+
+	arrival, err := validateDate("Arrival", m.Arrival)
+	if err != nil {
+		return err
+	}
+	if arrival.Before(today) && m.ExpireAfter != "departure" && !m.isSiteMonitor() && !m.isReservationGuard() {
+		return &ValidationError{Field: "Arrival", Value: m.Arrival, Reason: "is in the past"}
+	}
+	if m.MaxNights > 0 {
+		if m.MinNights > m.MaxNights {
+			return &ValidationError{Field: "MinNights", Reason: fmt.Sprintf("%d is more than MaxNights %d", m.MinNights, m.MaxNights)}
+		}
+		return nil
+	}
+	departure, err := validateDate("Departure", m.Departure)
+	if err != nil {
+		return err
+	}
+	nights := core.NightsBetween(arrival, departure)
+	if nights < 1 {
+		return &ValidationError{Field: "Departure", Value: m.Departure, Reason: "is not after Arrival " + m.Arrival}
+	}
+	if m.MinConsecutiveNights < 0 || m.MinConsecutiveNights > nights {
+		return &ValidationError{Field: "MinConsecutiveNights", Reason: fmt.Sprintf("%d is not between 0 and the stay's %d nights", m.MinConsecutiveNights, nights)}
+	}
+	return nil
+}
+
+// today is the current date at the campground, or in UTC when the watch has
+// no usable time zone.
+func (m MessageContent) today() core.CivilDate {
+	now := DefaultScraper.now().UTC()
+	if m.TimeZone != "" {
+		if loc, err := time.LoadLocation(m.TimeZone); err == nil {
+			now = now.In(loc)
+		}
+	}
+	return core.CivilDateOf(now)
+}
+
+func validateDate(field string, value string) (core.CivilDate, error) {
+	if value == "" {
+		return core.CivilDate{}, &ValidationError{Field: field, Reason: "must not be empty"}
+	}
+	d, err := core.ParseCivilDate(value)
+	if err != nil {
+		return core.CivilDate{}, &ValidationError{Field: field, Value: value, Reason: "is not a YYYY-MM-DD date"}
+	}
+	return d, nil
+}
+
+func validateCampgroundID(id string) error {
+	if id == "" {
+		return &ValidationError{Field: "Campground", Reason: "must not be empty"}
+	}
+	if !isDigits(id) {
+		return &ValidationError{Field: "Campground", Value: id, Reason: "must be a numeric recreation.gov ID"}
+	}
+	return nil
+}
+
+// isDigits reports whether s is a non-empty run of ASCII digits.
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
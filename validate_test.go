@@ -0,0 +1,72 @@
+package scraper
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMessageContentValidate(t *testing.T) {
+	useClock(t, time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	valid := MessageContent{Name: "projects/p/locations/l/jobs/j", Campground: "232447", Arrival: "2027-07-14", Departure: "2027-07-16"}
+	tests := []struct {
+		name  string
+		watch func(m *MessageContent)
+		// wantField is the field the ValidationError names, empty when m is
+		// valid.
+		wantField string
+	}{
+		{"valid", func(m *MessageContent) {}, ""},
+		{"short dates", func(m *MessageContent) { m.Arrival, m.Departure = "2027-7-14", "2027-7-16" }, ""},
+		{"arrival today", func(m *MessageContent) { m.Arrival = "2027-06-01" }, ""},
+		{"no name", func(m *MessageContent) { m.Name = "" }, "Name"},
+		{"no campground", func(m *MessageContent) { m.Campground = "" }, "Campground"},
+		{"campground name as ID", func(m *MessageContent) { m.Campground = "Upper Pines" }, "Campground"},
+		{"campground ID with letters", func(m *MessageContent) { m.Campground = "23244a" }, "Campground"},
+		{"campground name instead", func(m *MessageContent) { m.Campground, m.CampgroundName = "", "Upper Pines" }, ""},
+		{"US dates", func(m *MessageContent) { m.Arrival, m.Departure = "07/14/2027", "7/16/2027" }, ""},
+		{"unparsed arrival", func(m *MessageContent) { m.Arrival = "14.07.2027" }, "Arrival"},
+		{"unparsed departure", func(m *MessageContent) { m.Departure = "July 16" }, "Departure"},
+		{"zero nights", func(m *MessageContent) { m.Departure = m.Arrival }, "Departure"},
+		{"departure before arrival", func(m *MessageContent) { m.Departure = "2027-07-13" }, "Departure"},
+		{"past arrival", func(m *MessageContent) { m.Arrival = "2027-05-31" }, "Arrival"},
+		{"past arrival kept until departure", func(m *MessageContent) { m.Arrival, m.ExpireAfter = "2027-05-31", "departure" }, ""},
+		{"partial stay longer than the stay", func(m *MessageContent) { m.MinConsecutiveNights = 3 }, "MinConsecutiveNights"},
+		{"tour", func(m *MessageContent) {
+			m.Kind, m.PartySize, m.TourTimes = "tour", 4, []ScanWindow{{Start: "09:00", End: "15:00"}}
+		}, ""},
+		{"party size on a campground", func(m *MessageContent) { m.PartySize = 4 }, "PartySize"},
+		{"tour times on a campground", func(m *MessageContent) { m.TourTimes = []ScanWindow{{Start: "09:00", End: "15:00"}} }, "TourTimes"},
+		{"negative party size", func(m *MessageContent) { m.Kind, m.PartySize = "tour", -1 }, "PartySize"},
+		{"unreadable tour time", func(m *MessageContent) { m.Kind, m.TourTimes = "tour", []ScanWindow{{Start: "9am", End: "15:00"}} }, "TourTimes"},
+		{"capacity on a tour", func(m *MessageContent) { m.Kind, m.MinCapacity = "tour", 4 }, "MinCapacity"},
+		{"window with a weekend", func(m *MessageContent) {
+			m.Arrival, m.Departure, m.WindowStart, m.WindowEnd, m.Nights = "", "", "2027-07-01", "2027-07-31", 2
+			m.RequiredWeekdays = []string{"Fri", "Saturday"}
+		}, ""},
+		{"required weekdays farther apart than the stay", func(m *MessageContent) {
+			m.Arrival, m.Departure, m.WindowStart, m.WindowEnd, m.Nights = "", "", "2027-07-01", "2027-07-31", 2
+			m.RequiredWeekdays = []string{"Mon", "Wed"}
+		}, "Nights"},
+		{"unknown required weekday", func(m *MessageContent) {
+			m.Arrival, m.Departure, m.WindowStart, m.WindowEnd, m.Nights = "", "", "2027-07-01", "2027-07-31", 2
+			m.RequiredWeekdays = []string{"Caturday"}
+		}, "RequiredWeekdays"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			m := valid
+			test.watch(&m)
+			err := m.Validate()
+			var invalid *ValidationError
+			switch {
+			case test.wantField == "" && err != nil:
+				t.Errorf("Validate() = %v, want valid", err)
+			case test.wantField != "" && !errors.As(err, &invalid):
+				t.Errorf("Validate() = %v, want a ValidationError of %s", err, test.wantField)
+			case test.wantField != "" && invalid.Field != test.wantField:
+				t.Errorf("Validate() = %v, want one of %s", err, test.wantField)
+			}
+		})
+	}
+}
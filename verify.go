@@ -0,0 +1,135 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// WatchProblem describes one watch whose payload fails validation.
+type WatchProblem struct {
+	Job      string
+	Problems []string
+	// Migrated is set when every problem was a fixable date format and the
+	// job was rewritten.
+	Migrated bool
+}
+
+// VerifyOptions controls VerifyWatches.
+type VerifyOptions struct {
+	// Migrate rewrites watches whose only problems are legacy date formats.
+	// Jobs referring to registered watches are left as they are.
+	Migrate bool
+}
+
+// VerifyWatches checks every Pub/Sub-targeted job in the default location
+// against the current payload rules without scraping, returning the watches
+// that would fail. A job referring to a registered watch is checked as the
+// registry holds it. Jobs with other targets, and the daily digest's job,
+// are not watches and are skipped.
+func VerifyWatches(ctx context.Context, opts VerifyOptions) ([]WatchProblem, error) {
+	c, err := newWatchScheduler()
+	if err != nil {
+		return nil, err
+	}
+
+	jobs, err := c.ListJobs(ctx, activeConfig.Parent())
+	report := []WatchProblem{}
+	if err != nil {
+		return report, err
+	}
+	store := newWatchStore(activeConfig)
+	for _, job := range jobs {
+		target := job.GetPubsubTarget()
+		if name, err := ParseJobName(job.Name); target == nil || err == nil && name.Job == digestJobID {
+			continue
+		}
+		problems, fixed := verifyPayload(ctx, store, target.Data)
+		if len(problems) == 0 {
+			continue
+		}
+		p := WatchProblem{Job: job.Name, Problems: problems}
+		if opts.Migrate && fixed != nil {
+			target.Data = fixed
+			if err := c.UpdateJob(ctx, job, []string{"pubsub_target.data"}); err != nil {
+				p.Problems = append(p.Problems, "migration failed: "+err.Error())
+			} else {
+				p.Migrated = true
+			}
+		}
+		report = append(report, p)
+	}
+	return report, nil
+}
+
+// verifyPayload is validatePayload for any job payload. A reference to a
+// registered watch is resolved and validated; it is never migrated, since
+// the job holds only the reference.
+func verifyPayload(ctx context.Context, store WatchStore, data []byte) ([]string, []byte) {
+	ref, ok := watchRefOf(data)
+	if !ok {
+		return validatePayload(data)
+	}
+	m, _, ok := watchFromJob(ctx, store, data)
+	if !ok {
+		return []string{fmt.Sprintf("watch %s is not in the registry", ref.WatchID)}, nil
+	}
+	if err := m.Validate(); err != nil {
+		return []string{err.Error()}, nil
+	}
+	return nil, nil
+}
+
+// watchRefOf returns the reference a job payload holds instead of a watch.
+func watchRefOf(data []byte) (watchRef, bool) {
+	var m MessageContent
+	var ref watchRef
+	if json.Unmarshal(data, &m) != nil || m.Name != "" || json.Unmarshal(data, &ref) != nil || ref.WatchID == "" {
+		return ref, false
+	}
+	return ref, true
+}
+
+// validatePayload returns the problems with a watch payload, as Validate
+// sees them. Dates in a legacy format are problems too, and when they are
+// the only ones it also returns the payload rewritten to the current format.
+func validatePayload(data []byte) ([]string, []byte) {
+	var m MessageContent
+	if err := json.Unmarshal(data, &m); err != nil {
+		return []string{"payload is not valid JSON: " + err.Error()}, nil
+	}
+	problems := []string{}
+	dates := []struct {
+		field string
+		value *string
+	}{
+		{"Arrival", &m.Arrival}, {"Departure", &m.Departure}, {"WindowStart", &m.WindowStart}, {"WindowEnd", &m.WindowEnd},
+	}
+	for _, date := range dates {
+		if *date.value == "" {
+			continue
+		}
+		if _, err := time.Parse(layoutISO, *date.value); err == nil {
+			continue
+		}
+		// A date that does not parse at all is left to Validate.
+		if d, err := core.ParseCivilDate(*date.value); err == nil {
+			problems = append(problems, fmt.Sprintf("%s %q uses a legacy date format", date.field, *date.value))
+			*date.value = d.String()
+		}
+	}
+	if err := m.Validate(); err != nil {
+		return append(problems, err.Error()), nil
+	}
+	if len(problems) == 0 {
+		return problems, nil
+	}
+	fixed, err := json.Marshal(m)
+	if err != nil {
+		return problems, nil
+	}
+	return problems, fixed
+}
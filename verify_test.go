@@ -0,0 +1,107 @@
+package scraper_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+)
+
+// Verifying watches applies Validate to each job, whichever dates its mode
+// needs, and resolves registered watches from the registry. Only legacy
+// dates are migrated, and never in a reference to a registered watch.
+func TestVerifyWatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		// want is part of the one problem reported last, if any.
+		want         string
+		wantMigrated string
+	}{
+		{name: "dated", payload: `{"Name":"n","Campground":"232447","Arrival":"2027-07-14","Departure":"2027-07-16"}`},
+		{name: "window", payload: `{"Name":"n","Campground":"232447","Nights":2,"WindowStart":"2027-07-01","WindowEnd":"2027-07-31"}`},
+		{name: "window past", payload: `{"Name":"n","Campground":"232447","Nights":2,"WindowStart":"2027-05-01","WindowEnd":"2027-05-31"}`,
+			want: "invalid WindowEnd"},
+		{name: "window without an end", payload: `{"Name":"n","Campground":"232447","Nights":2,"WindowStart":"2027-07-01"}`,
+			want: "invalid WindowEnd: must not be empty"},
+		{name: "flexible departure", payload: `{"Name":"n","Campground":"232447","Arrival":"2027-07-14","MinNights":2,"MaxNights":4}`},
+		{name: "flexible departure too short", payload: `{"Name":"n","Campground":"232447","Arrival":"2027-07-14","MinNights":5,"MaxNights":4}`,
+			want: "invalid MinNights"},
+		{name: "no departure", payload: `{"Name":"n","Campground":"232447","Arrival":"2027-07-14"}`, want: "invalid Departure: must not be empty"},
+		{name: "legacy dates", payload: `{"Name":"n","Campground":"232447","Arrival":"07/14/2027","Departure":"07/16/2027"}`,
+			want: `Departure "07/16/2027" uses a legacy date format`, wantMigrated: `"Arrival":"2027-07-14","Departure":"2027-07-16"`},
+		{name: "legacy window", payload: `{"Name":"n","Campground":"232447","Nights":2,"WindowStart":"07/01/2027","WindowEnd":"07/31/2027"}`,
+			want: `WindowEnd "07/31/2027" uses a legacy date format`, wantMigrated: `"WindowStart":"2027-07-01","WindowEnd":"2027-07-31"`},
+		{name: "legacy and invalid", payload: `{"Name":"n","Campground":"232447","Arrival":"07/16/2027","Departure":"07/14/2027"}`,
+			want: "invalid Departure"},
+		{name: "registered", payload: `{"WatchID":"w1"}`},
+		{name: "registered invalid", payload: `{"WatchID":"w2"}`, want: "invalid Departure"},
+		{name: "not registered", payload: `{"WatchID":"w3"}`, want: "watch w3 is not in the registry"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			h := fakerecgov.Start(e2eFixture(false))
+			defer h.Close()
+			h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+			defer scraper.UseConfig(testConfig)()
+			store := &scraper.MemoryStore{}
+			defer scraper.UseServices(scraper.Services{Watches: store})()
+			ctx := context.Background()
+			store.PutWatch(ctx, scraper.WatchRecord{ID: "w1", Watch: e2eWatch("w1"), Status: scraper.WatchActive})
+			invalid := e2eWatch("w2")
+			invalid.Arrival, invalid.Departure = "07/16/2027", "07/14/2027"
+			store.PutWatch(ctx, scraper.WatchRecord{ID: "w2", Watch: invalid, Status: scraper.WatchActive})
+			name := fakerecgov.JobName("verify")
+			schedule(h, name, []byte(test.payload))
+
+			problems, err := scraper.VerifyWatches(ctx, scraper.VerifyOptions{Migrate: true})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if test.want == "" {
+				if len(problems) != 0 {
+					t.Errorf("problems %+v, want none", problems)
+				}
+				return
+			}
+			if len(problems) != 1 || !strings.Contains(problems[0].Problems[len(problems[0].Problems)-1], test.want) {
+				t.Fatalf("problems %+v, want %q", problems, test.want)
+			}
+			data := string(h.Scheduler.Job(name).GetPubsubTarget().GetData())
+			if problems[0].Migrated != (test.wantMigrated != "") || test.wantMigrated != "" && !strings.Contains(data, test.wantMigrated) ||
+				test.wantMigrated == "" && data != test.payload {
+				t.Errorf("migrated %v to %s, want %q", problems[0].Migrated, data, test.wantMigrated)
+			}
+		})
+	}
+}
+
+// A bundle for a registered watch shows the watch the registry holds, not
+// the reference in its job.
+func TestGenerateDiagnosticsRegistered(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	store := &scraper.MemoryStore{}
+	defer scraper.UseServices(scraper.Services{Watches: store})()
+	ctx := context.Background()
+	store.PutWatch(ctx, scraper.WatchRecord{ID: "w1", Watch: e2eWatch("w1"), Status: scraper.WatchActive})
+	name := fakerecgov.JobName("diagnose-registered")
+	schedule(h, name, []byte(`{"WatchID":"w1"}`))
+
+	bundle, err := scraper.GenerateDiagnostics(ctx, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var d scraper.Diagnostics
+	if err := json.Unmarshal(bundle, &d); err != nil {
+		t.Fatalf("decoding %s: %v", bundle, err)
+	}
+	if d.Spec == nil || d.Spec.Name != "w1" || d.Spec.Campground != "232447" || len(d.SpecProblems) != 0 {
+		t.Errorf("spec %+v with problems %v, want watch w1 without any", d.Spec, d.SpecProblems)
+	}
+}
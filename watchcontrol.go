@@ -0,0 +1,127 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Actions a ControlMessage can ask for.
+const (
+	ActionPause  = "pause"
+	ActionResume = "resume"
+	ActionCancel = "cancel"
+)
+
+// snoozedUntilAttribute is set on the Pub/Sub target of a snoozed job and
+// holds when, in RFC 3339, its runs start scanning again.
+const snoozedUntilAttribute = "snoozed_until"
+
+// ControlMessage asks ControlFromMessage to pause, resume or cancel the
+// watch whose job is Job, given as a job ID or a full job name.
+type ControlMessage struct {
+	Action string
+	Job    string
+}
+
+// ControlFromMessage is a Pub/Sub Cloud Function, deployed on its own
+// topic, that applies a ControlMessage with ControlWatch.
+func ControlFromMessage(ctx context.Context, m pubsub.Message) error {
+	var c ControlMessage
+	if err := json.Unmarshal(m.Data, &c); err != nil {
+		return fmt.Errorf("decoding control message: %w", err)
+	}
+	if err := ControlWatch(ctx, c.Action, c.Job); err != nil {
+		return err
+	}
+	logger.Printf("job %s: %s done", c.Job, c.Action)
+	return nil
+}
+
+// ControlWatch pauses a watch's job so it stops running but keeps its
+// configuration, resumes a paused one, or cancels one by deleting the job
+// and its stored state, as deleteJob does when a watch ends. Each action
+// succeeds when the job is already in the state asked for, so a redelivered
+// message is harmless; only cancel succeeds for a job that does not exist.
+// Alert claims are not removed, as they are keyed by the results and
+// expire within minutes anyway.
+func ControlWatch(ctx context.Context, action string, jobName string) error {
+	switch action {
+	case ActionPause, ActionResume, ActionCancel:
+	default:
+		return fmt.Errorf("unknown control action %q: want %q, %q or %q", action, ActionPause, ActionResume, ActionCancel)
+	}
+	if action == ActionCancel {
+		return deleteJob(ctx, jobName)
+	}
+	name, err := ParseJobName(jobName)
+	if err != nil {
+		return err
+	}
+	c, err := newWatchScheduler()
+	if err != nil {
+		return err
+	}
+	job, err := c.GetJob(ctx, name.String())
+	if status.Code(err) == codes.NotFound {
+		return fmt.Errorf("cannot %s job %s: it does not exist", action, name)
+	}
+	if err != nil {
+		return fmt.Errorf("getting job %s: %v", name, err)
+	}
+	switch {
+	case action == ActionPause && job.State == schedulerpb.Job_PAUSED:
+		logger.Printf("job %s is already paused", name)
+	case action == ActionPause:
+		err = c.PauseJob(ctx, name.String())
+	case job.State == schedulerpb.Job_ENABLED:
+		logger.Printf("job %s is already running", name)
+	default:
+		err = c.ResumeJob(ctx, name.String())
+	}
+	if err != nil {
+		return fmt.Errorf("%s job %s: %v", action, name, err)
+	}
+	return nil
+}
+
+// snoozeJob stops job's runs from scanning for d by recording the end of the
+// snooze on its Pub/Sub target, and returns that time. The job keeps firing,
+// so nothing needs to run to end the snooze; a watch that expires or hits
+// its cutoff meanwhile still closes itself.
+func snoozeJob(ctx context.Context, c Scheduler, job *schedulerpb.Job, d time.Duration) (time.Time, error) {
+	target := job.GetPubsubTarget()
+	if target == nil {
+		return time.Time{}, fmt.Errorf("job %s has no Pub/Sub target", job.Name)
+	}
+	until := DefaultScraper.now().Add(d).UTC().Truncate(time.Second)
+	if target.Attributes == nil {
+		target.Attributes = map[string]string{}
+	}
+	target.Attributes[snoozedUntilAttribute] = until.Format(time.RFC3339)
+	if err := c.UpdateJob(ctx, job, []string{"pubsub_target.attributes"}); err != nil {
+		return time.Time{}, fmt.Errorf("snoozing job %s: %v", job.Name, err)
+	}
+	return until, nil
+}
+
+// snoozed returns when the snooze recorded in a message's attributes ends,
+// and whether it is still in force.
+func snoozed(attributes map[string]string) (time.Time, bool) {
+	value, ok := attributes[snoozedUntilAttribute]
+	if !ok {
+		return time.Time{}, false
+	}
+	until, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		logger.Printf("ignoring invalid %s %q: %v", snoozedUntilAttribute, value, err)
+		return time.Time{}, false
+	}
+	return until, DefaultScraper.now().Before(until)
+}
@@ -0,0 +1,103 @@
+package scraper_test
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/pubsub"
+	"github.com/sgrasu/camp_finder/scraper"
+	"github.com/sgrasu/camp_finder/scraper/internal/fakerecgov"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+// A watch created, then paused, resumed and cancelled, each twice, issues
+// only the RPCs each step needs: a step finding its job already in the
+// state asked for reads it and stops, and cancel clears the watch's state.
+func TestControlWatch(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	h.Clock.Set(time.Date(2027, 6, 1, 12, 0, 0, 0, time.UTC))
+	ctx := context.Background()
+	created, err := scraper.CreateWatch(ctx, testConfig, newWatch("2027-07-14", "2027-07-16"), "*/10 * * * *", scraper.CreateOptions{})
+	if err != nil {
+		t.Fatalf("CreateWatch: %v", err)
+	}
+	name := created.Name.String()
+	if calls := h.Scheduler.Calls(); len(calls) == 0 || calls[len(calls)-1] != "CreateJob "+name {
+		t.Fatalf("CreateWatch made %q, want it to end with CreateJob", calls)
+	}
+	h.Store.SetLastScan(ctx, name, []string{"1001"})
+	h.Store.SetLastNotified(ctx, name, []string{"1001/2027-07-14"})
+
+	steps := []struct {
+		action    string
+		wantCalls []string
+		wantState schedulerpb.Job_State
+	}{
+		{scraper.ActionPause, []string{"GetJob", "PauseJob"}, schedulerpb.Job_PAUSED},
+		{scraper.ActionPause, []string{"GetJob"}, schedulerpb.Job_PAUSED},
+		{scraper.ActionResume, []string{"GetJob", "ResumeJob"}, schedulerpb.Job_ENABLED},
+		{scraper.ActionResume, []string{"GetJob"}, schedulerpb.Job_ENABLED},
+		{scraper.ActionCancel, []string{"DeleteJob"}, schedulerpb.Job_STATE_UNSPECIFIED},
+		{scraper.ActionCancel, []string{"DeleteJob"}, schedulerpb.Job_STATE_UNSPECIFIED},
+	}
+	for i, step := range steps {
+		before := len(h.Scheduler.Calls())
+		data, _ := json.Marshal(scraper.ControlMessage{Action: step.action, Job: name})
+		if err := scraper.ControlFromMessage(ctx, pubsub.Message{Data: data}); err != nil {
+			t.Fatalf("step %d, %s: %v", i, step.action, err)
+		}
+		want := []string{}
+		for _, rpc := range step.wantCalls {
+			want = append(want, rpc+" "+name)
+		}
+		if got := h.Scheduler.Calls()[before:]; !reflect.DeepEqual(got, want) {
+			t.Errorf("step %d, %s: made %q, want %q", i, step.action, got, want)
+		}
+		state := schedulerpb.Job_STATE_UNSPECIFIED
+		if job := h.Scheduler.Job(name); job != nil {
+			state = job.State
+		}
+		if state != step.wantState {
+			t.Errorf("step %d, %s: job is %v, want %v", i, step.action, state, step.wantState)
+		}
+	}
+	if scan, _ := h.Store.LastScan(ctx, name); len(scan) != 0 {
+		t.Errorf("last scan %v kept after cancel", scan)
+	}
+	if notified, _ := h.Store.LastNotified(ctx, name); len(notified) != 0 {
+		t.Errorf("last notified %v kept after cancel", notified)
+	}
+}
+
+// Pausing or resuming a job that does not exist fails after looking it up;
+// an unknown action fails without any RPC.
+func TestControlWatchRejected(t *testing.T) {
+	h := fakerecgov.Start(e2eFixture(false))
+	defer h.Close()
+	ctx := context.Background()
+	name := fakerecgov.JobName("control-missing")
+	tests := []struct {
+		action    string
+		wantErr   string
+		wantCalls []string
+	}{
+		{scraper.ActionPause, "does not exist", []string{"GetJob " + name}},
+		{scraper.ActionResume, "does not exist", []string{"GetJob " + name}},
+		{"snooze", `unknown control action "snooze"`, []string{}},
+	}
+	for _, test := range tests {
+		before := len(h.Scheduler.Calls())
+		err := scraper.ControlWatch(ctx, test.action, name)
+		if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+			t.Errorf("%s: got %v, want %q", test.action, err, test.wantErr)
+		}
+		if got := h.Scheduler.Calls()[before:]; !reflect.DeepEqual(got, test.wantCalls) {
+			t.Errorf("%s: made %q, want %q", test.action, got, test.wantCalls)
+		}
+	}
+}
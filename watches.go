@@ -0,0 +1,247 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+	schedulerpb "google.golang.org/genproto/googleapis/cloud/scheduler/v1"
+)
+
+// createWatchJob creates a scheduler job that publishes m to topic in the
+// job's project on schedule.
+//
+// Unless force is set, an existing watch that overlaps m is reused instead:
+// m's channels, priority sites and filters are merged into it and its name
+// is returned with merged set; see overlaps and mergeWatches.
+func createWatchJob(ctx context.Context, name JobName, schedule string, timeZone string, topic string,
+	m MessageContent, force bool) (JobName, bool, error) {
+	c, err := newWatchScheduler()
+	if err != nil {
+		return JobName{}, false, err
+	}
+	parent := fmt.Sprintf("projects/%s/locations/%s", name.Project, name.Location)
+
+	if !force {
+		store := newWatchStore(Config{Project: name.Project, Location: name.Location})
+		existing, err := findOverlappingWatch(ctx, c, store, parent, m)
+		if err != nil {
+			return JobName{}, false, err
+		}
+		if existing != nil {
+			merged, err := mergeWatch(ctx, c, store, existing, m)
+			return merged, err == nil, err
+		}
+	}
+
+	// As with CreateWatch, the payload names the job in full so the run
+	// deletes it in its own location.
+	m.Name = name.String()
+	data, err := json.Marshal(m)
+	if err != nil {
+		return JobName{}, false, err
+	}
+	err = c.CreateJob(ctx, parent, &schedulerpb.Job{
+		Name:     name.String(),
+		Schedule: schedule,
+		TimeZone: timeZone,
+		Target: &schedulerpb.Job_PubsubTarget{PubsubTarget: &schedulerpb.PubsubTarget{
+			TopicName: fmt.Sprintf("projects/%s/topics/%s", name.Project, topic),
+			Data:      data,
+		}},
+	})
+	if err != nil {
+		return JobName{}, false, fmt.Errorf("creating job %s: %v", name, err)
+	}
+	return name, false, nil
+}
+
+// span is the nights m could alert for, from the first up to the morning
+// after the last: its stay, its arrival up to the longest flexible
+// departure, or a window watch's window.
+func (m MessageContent) span() (core.CivilDate, core.CivilDate) {
+	switch {
+	case m.Nights > 0:
+		return stayDate(m.WindowStart), stayDate(m.WindowEnd)
+	case m.MaxNights > 0:
+		arrival := stayDate(m.Arrival)
+		return arrival, arrival.AddDays(m.MaxNights)
+	}
+	return stayDate(m.Arrival), stayDate(m.Departure)
+}
+
+// owner identifies whose watch m is: the contact it alerts, or else the
+// address its emails go to.
+func (m MessageContent) owner() string {
+	if m.Contact != "" {
+		return "contact:" + contactKey(m.Owner, m.Contact)
+	}
+	return strings.ToLower(m.recipient().Address)
+}
+
+// overlaps reports whether m and other are duplicates to merge: the same
+// owner watching the same campgrounds for at least one night in common.
+// Watches texting different numbers or posting to different webhooks are
+// kept apart, since a merged watch can only have one of each.
+func (m MessageContent) overlaps(other MessageContent) bool {
+	if m.owner() != other.owner() || m.isPermit() != other.isPermit() || m.isTour() != other.isTour() || m.isSiteMonitor() || other.isSiteMonitor() || m.isReservationGuard() || other.isReservationGuard() {
+		return false
+	}
+	mine, theirs := append([]string{}, m.campgrounds()...), append([]string{}, other.campgrounds()...)
+	sort.Strings(mine)
+	sort.Strings(theirs)
+	if strings.Join(mine, ",") != strings.Join(theirs, ",") {
+		return false
+	}
+	if conflicting(m.NotifyPhone, other.NotifyPhone) || conflicting(m.WebhookURL, other.WebhookURL) {
+		return false
+	}
+	start, end := m.span()
+	otherStart, otherEnd := other.span()
+	return start.Before(otherEnd) && otherStart.Before(end)
+}
+
+func conflicting(a string, b string) bool {
+	return a != "" && b != "" && a != b
+}
+
+// mergeWatches folds m into existing and reports whether anything changed.
+// Channels existing lacks are taken from m and priority sites are added.
+// Filters are widened so the merged watch alerts for whatever either would:
+// a type, campsite or attribute filter only one of them has is dropped,
+// excluded types must be excluded by both, and the lower capacity and
+// higher price limit apply. existing keeps its dates.
+func mergeWatches(existing MessageContent, m MessageContent) (MessageContent, bool) {
+	merged := existing
+	if merged.NotifyEmail == "" {
+		merged.NotifyEmail, merged.NotifyName = m.NotifyEmail, m.NotifyName
+	}
+	if merged.NotifyPhone == "" {
+		merged.NotifyPhone = m.NotifyPhone
+	}
+	if merged.WebhookURL == "" {
+		merged.WebhookURL = m.WebhookURL
+	}
+	merged.Priority = dedupeStrings(append(append([]string{}, existing.Priority...), m.Priority...))
+	if len(merged.Priority) == 0 {
+		merged.Priority = existing.Priority
+	}
+	merged.SiteTypes = widenKept(existing.SiteTypes, m.SiteTypes)
+	merged.CampsiteIDs = widenKept(existing.CampsiteIDs, m.CampsiteIDs)
+	merged.ExcludeTypes = nil
+	for _, t := range existing.ExcludeTypes {
+		if containsFold(m.ExcludeTypes, t) {
+			merged.ExcludeTypes = append(merged.ExcludeTypes, t)
+		}
+	}
+	if m.MinCapacity < merged.MinCapacity {
+		merged.MinCapacity = m.MinCapacity
+	}
+	if m.MaxNightlyPrice == 0 || (merged.MaxNightlyPrice != 0 && m.MaxNightlyPrice > merged.MaxNightlyPrice) {
+		merged.MaxNightlyPrice = m.MaxNightlyPrice
+	}
+	merged.RequiredAttributes = nil
+	for name, value := range existing.RequiredAttributes {
+		if other, ok := m.RequiredAttributes[name]; ok && other == value {
+			if merged.RequiredAttributes == nil {
+				merged.RequiredAttributes = map[string]string{}
+			}
+			merged.RequiredAttributes[name] = value
+		}
+	}
+	return merged, !reflect.DeepEqual(merged, existing)
+}
+
+// widenKept is the union of two filters keeping only the values listed,
+// where an empty one keeps everything.
+func widenKept(a []string, b []string) []string {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	return dedupeStrings(append(append([]string{}, a...), b...))
+}
+
+func containsFold(values []string, s string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// overlappingWatch is an existing watch that m overlaps, with its job.
+type overlappingWatch struct {
+	job   *schedulerpb.Job
+	watch MessageContent
+	// registered is set when the watch's definition is in the registry.
+	registered bool
+}
+
+// findOverlappingWatch returns the first enabled, active watch under parent,
+// legacy or registered in store, that m overlaps.
+func findOverlappingWatch(ctx context.Context, c Scheduler, store WatchStore, parent string, m MessageContent) (*overlappingWatch, error) {
+	jobs, err := c.ListJobs(ctx, parent)
+	if err != nil {
+		return nil, fmt.Errorf("listing watches: %v", err)
+	}
+	for _, job := range jobs {
+		if job.State != schedulerpb.Job_ENABLED || job.GetPubsubTarget() == nil {
+			continue
+		}
+		data := job.GetPubsubTarget().Data
+		other, status, ok := watchFromJob(ctx, store, data)
+		if !ok || status != WatchActive || !m.overlaps(other) {
+			continue
+		}
+		var ref watchRef
+		json.Unmarshal(data, &ref)
+		return &overlappingWatch{job: job, watch: other, registered: ref.WatchID != ""}, nil
+	}
+	return nil, nil
+}
+
+// mergeWatch merges m into the overlapping watch, in the registry or in its
+// job's payload, and returns the watch's job name.
+func mergeWatch(ctx context.Context, c Scheduler, store WatchStore, existing *overlappingWatch, m MessageContent) (JobName, error) {
+	name, err := ParseJobName(existing.job.Name)
+	if err != nil {
+		return JobName{}, err
+	}
+	logger.Printf("watch %s overlaps existing watch %s; merging instead of creating a duplicate", m.Name, name.Job)
+	merged, changed := mergeWatches(existing.watch, m)
+	if !changed {
+		return name, nil
+	}
+	if existing.registered {
+		if err := updateRegisteredWatch(ctx, store, existing.watch.Name, merged); err != nil {
+			return JobName{}, fmt.Errorf("merging into %s: %w", name, err)
+		}
+		return name, nil
+	}
+	data, err := json.Marshal(merged)
+	if err != nil {
+		return JobName{}, err
+	}
+	existing.job.GetPubsubTarget().Data = data
+	if err := c.UpdateJob(ctx, existing.job, []string{"pubsub_target.data"}); err != nil {
+		return JobName{}, fmt.Errorf("merging into %s: %v", name, err)
+	}
+	return name, nil
+}
+
+func dedupeStrings(values []string) []string {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			out = append(out, v)
+		}
+	}
+	return out
+}
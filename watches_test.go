@@ -0,0 +1,137 @@
+package scraper
+
+import (
+	"reflect"
+	"testing"
+)
+
+func stayWatch(arrival string, departure string) MessageContent {
+	return MessageContent{Campground: "232447", Arrival: arrival, Departure: departure, NotifyEmail: "me@example.com"}
+}
+
+func windowWatch(start string, end string, nights int) MessageContent {
+	return MessageContent{Campground: "232447", WindowStart: start, WindowEnd: end, Nights: nights, NotifyEmail: "me@example.com"}
+}
+
+// with returns m changed by change.
+func with(m MessageContent, change func(m *MessageContent)) MessageContent {
+	change(&m)
+	return m
+}
+
+func TestOverlaps(t *testing.T) {
+	july := stayWatch("2027-07-14", "2027-07-16")
+	tests := []struct {
+		name string
+		a, b MessageContent
+		want bool
+	}{
+		{"same stay", july, july, true},
+		{"partial stay overlap", july, stayWatch("2027-07-15", "2027-07-18"), true},
+		{"contained stay", stayWatch("2027-07-10", "2027-07-20"), stayWatch("2027-07-14", "2027-07-15"), true},
+		{"back to back", july, stayWatch("2027-07-16", "2027-07-18"), false},
+		{"window around a stay", windowWatch("2027-07-01", "2027-07-31", 2), july, true},
+		{"window after a stay", windowWatch("2027-07-16", "2027-07-31", 2), july, false},
+		{"windows partly overlapping", windowWatch("2027-07-10", "2027-07-20", 2), windowWatch("2027-07-19", "2027-07-25", 3), true},
+		{"windows back to back", windowWatch("2027-07-10", "2027-07-20", 2), windowWatch("2027-07-20", "2027-07-25", 2), false},
+		{"flexible departure reaching a stay", with(stayWatch("2027-07-10", ""), func(m *MessageContent) { m.MaxNights = 5 }), july, true},
+		{"flexible departure short of a stay", with(stayWatch("2027-07-10", ""), func(m *MessageContent) { m.MaxNights = 4 }), july, false},
+		{"other owner", july, with(july, func(m *MessageContent) { m.NotifyEmail = "you@example.com" }), false},
+		{"owner in any case", july, with(july, func(m *MessageContent) { m.NotifyEmail = "Me@Example.com" }), true},
+		{"other campground", july, with(july, func(m *MessageContent) { m.Campground = "232450" }), false},
+		{"same campgrounds in another order",
+			with(july, func(m *MessageContent) { m.Campground, m.Campgrounds = "", []string{"232447", "232450"} }),
+			with(july, func(m *MessageContent) { m.Campground, m.Campgrounds = "", []string{"232450", "232447"} }), true},
+		{"permit and campground", july, with(july, func(m *MessageContent) { m.Kind = "permit" }), false},
+		{"site monitor and campground", july, with(july, func(m *MessageContent) { m.Kind, m.CampsiteIDs = "site-monitor", []string{"1001"} }), false},
+		{"site monitors of one site",
+			with(july, func(m *MessageContent) { m.Kind, m.CampsiteIDs = "site-monitor", []string{"1001"} }),
+			with(july, func(m *MessageContent) { m.Kind, m.CampsiteIDs = "site-monitor", []string{"1001"} }), false},
+		{"same contact",
+			with(july, func(m *MessageContent) { m.NotifyEmail, m.Contact, m.Owner = "", "mom", "sam@example.com" }),
+			with(july, func(m *MessageContent) { m.NotifyEmail, m.Contact, m.Owner = "", "Mom", "Sam@example.com" }), true},
+		{"other contact",
+			with(july, func(m *MessageContent) { m.NotifyEmail, m.Contact, m.Owner = "", "mom", "sam@example.com" }),
+			with(july, func(m *MessageContent) { m.NotifyEmail, m.Contact, m.Owner = "", "dad", "sam@example.com" }), false},
+		{"contact and address", july, with(july, func(m *MessageContent) { m.NotifyEmail, m.Contact = "", "mom" }), false},
+		{"phone added", july, with(july, func(m *MessageContent) { m.NotifyPhone = "+14155550100" }), true},
+		{"phones differ",
+			with(july, func(m *MessageContent) { m.NotifyPhone = "+14155550100" }),
+			with(july, func(m *MessageContent) { m.NotifyPhone = "+14155550199" }), false},
+		{"webhooks differ",
+			with(july, func(m *MessageContent) { m.WebhookURL = "https://a.example.com" }),
+			with(july, func(m *MessageContent) { m.WebhookURL = "https://b.example.com" }), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := test.a.overlaps(test.b); got != test.want {
+				t.Errorf("overlaps = %v, want %v", got, test.want)
+			}
+			if got := test.b.overlaps(test.a); got != test.want {
+				t.Errorf("overlaps reversed = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestMergeWatches(t *testing.T) {
+	july := stayWatch("2027-07-14", "2027-07-16")
+	tests := []struct {
+		name        string
+		existing, m MessageContent
+		want        MessageContent
+		wantChanged bool
+	}{
+		{"identical", july, july, july, false},
+		{"channels added",
+			july,
+			with(july, func(m *MessageContent) { m.NotifyPhone, m.WebhookURL = "+14155550100", "https://example.com/hook" }),
+			with(july, func(m *MessageContent) { m.NotifyPhone, m.WebhookURL = "+14155550100", "https://example.com/hook" }), true},
+		{"email filled in",
+			with(july, func(m *MessageContent) { m.NotifyEmail = "" }),
+			with(july, func(m *MessageContent) { m.NotifyName = "Me" }),
+			with(july, func(m *MessageContent) { m.NotifyName = "Me" }), true},
+		{"priority appended",
+			with(july, func(m *MessageContent) { m.Priority = []string{"1001"} }),
+			with(july, func(m *MessageContent) { m.Priority = []string{"1002", "1001"} }),
+			with(july, func(m *MessageContent) { m.Priority = []string{"1001", "1002"} }), true},
+		{"type filters unioned",
+			with(july, func(m *MessageContent) { m.SiteTypes = []string{"TENT ONLY"} }),
+			with(july, func(m *MessageContent) { m.SiteTypes = []string{"STANDARD"} }),
+			with(july, func(m *MessageContent) { m.SiteTypes = []string{"TENT ONLY", "STANDARD"} }), true},
+		{"type filter dropped when one has none",
+			with(july, func(m *MessageContent) { m.SiteTypes, m.CampsiteIDs = []string{"TENT ONLY"}, []string{"1001"} }),
+			july, july, true},
+		{"exclusions both share kept",
+			with(july, func(m *MessageContent) { m.ExcludeTypes = []string{"GROUP", "RV"} }),
+			with(july, func(m *MessageContent) { m.ExcludeTypes = []string{"group"} }),
+			with(july, func(m *MessageContent) { m.ExcludeTypes = []string{"GROUP"} }), true},
+		{"loosest limits",
+			with(july, func(m *MessageContent) { m.MinCapacity, m.MaxNightlyPrice = 6, 30 }),
+			with(july, func(m *MessageContent) { m.MinCapacity, m.MaxNightlyPrice = 4, 40 }),
+			with(july, func(m *MessageContent) { m.MinCapacity, m.MaxNightlyPrice = 4, 40 }), true},
+		{"no price limit wins",
+			with(july, func(m *MessageContent) { m.MaxNightlyPrice = 30 }),
+			july, july, true},
+		{"shared attributes kept",
+			with(july, func(m *MessageContent) {
+				m.RequiredAttributes = map[string]string{"pets allowed": "Yes", "shade": "Full"}
+			}),
+			with(july, func(m *MessageContent) {
+				m.RequiredAttributes = map[string]string{"pets allowed": "Yes", "shade": "Partial"}
+			}),
+			with(july, func(m *MessageContent) { m.RequiredAttributes = map[string]string{"pets allowed": "Yes"} }), true},
+		{"dates kept", july, stayWatch("2027-07-15", "2027-07-18"), july, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, changed := mergeWatches(test.existing, test.m)
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("merged\n%+v\nwant\n%+v", got, test.want)
+			}
+			if changed != test.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, test.wantChanged)
+			}
+		})
+	}
+}
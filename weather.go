@@ -0,0 +1,200 @@
+package scraper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// weatherTimeout bounds the whole forecast lookup, so a slow weather
+// service cannot hold up an alert.
+const weatherTimeout = 10 * time.Second
+
+// NightForecast is the forecast for one night of a stay: the day's high and
+// the night's low, and the higher of their chances of precipitation.
+type NightForecast struct {
+	Date core.CivilDate
+	// High and Low are nil when the forecast has no period for them.
+	High *int
+	Low  *int
+	Unit string
+	// PrecipChance is a percentage, nil when the forecast does not say.
+	PrecipChance *int
+	Summary      string
+}
+
+// String renders the forecast compactly, such as "Sat Jun 14: 72°F / 48°F,
+// 20% precip, Mostly Sunny".
+func (f NightForecast) String() string {
+	temps := []string{}
+	for _, t := range []*int{f.High, f.Low} {
+		if t != nil {
+			temps = append(temps, fmt.Sprintf("%d°%s", *t, f.Unit))
+		}
+	}
+	parts := []string{strings.Join(temps, " / ")}
+	if f.PrecipChance != nil {
+		parts = append(parts, fmt.Sprintf("%d%% precip", *f.PrecipChance))
+	}
+	if f.Summary != "" {
+		parts = append(parts, f.Summary)
+	}
+	return f.Date.Time().Format("Mon Jan 2") + ": " + strings.Join(parts, ", ")
+}
+
+// NOAAWeather reads forecasts from the National Weather Service API at
+// api.weather.gov, which covers the United States only.
+type NOAAWeather struct {
+	// Client is used for requests. http.DefaultClient is used when nil.
+	Client *http.Client
+	// BaseURL replaces https://api.weather.gov, for tests.
+	BaseURL string
+}
+
+const noaaURL = "https://api.weather.gov"
+
+// weatherService is where forecasts come from. Tests point it at a stub.
+var weatherService = NOAAWeather{}
+
+// noaaPeriod is one period of a NOAA forecast, a day or a night.
+type noaaPeriod struct {
+	StartTime                  time.Time `json:"startTime"`
+	IsDaytime                  bool      `json:"isDaytime"`
+	Temperature                *int      `json:"temperature"`
+	TemperatureUnit            string    `json:"temperatureUnit"`
+	ShortForecast              string    `json:"shortForecast"`
+	ProbabilityOfPrecipitation struct {
+		Value *int `json:"value"`
+	} `json:"probabilityOfPrecipitation"`
+}
+
+func (w NOAAWeather) get(ctx context.Context, url string, v interface{}) error {
+	client := w.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	request, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	// The API asks every client to identify itself.
+	request.Header.Set("User-Agent", core.DefaultUserAgent)
+	request.Header.Set("Accept", "application/geo+json")
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("fetching %s: %w", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %d %s", url, response.StatusCode, http.StatusText(response.StatusCode))
+	}
+	if err := json.NewDecoder(response.Body).Decode(v); err != nil {
+		return fmt.Errorf("decoding %s: %v", url, err)
+	}
+	return nil
+}
+
+// Forecast returns the forecast for each night from arrival up to
+// departure that the NWS forecast reaches, about a week out.
+func (w NOAAWeather) Forecast(ctx context.Context, at core.Location, arrival time.Time, departure time.Time) ([]NightForecast, error) {
+	base := w.BaseURL
+	if base == "" {
+		base = noaaURL
+	}
+	var point struct {
+		Properties struct {
+			Forecast string `json:"forecast"`
+		} `json:"properties"`
+	}
+	if err := w.get(ctx, fmt.Sprintf("%s/points/%.4f,%.4f", base, at.Latitude, at.Longitude), &point); err != nil {
+		return nil, err
+	}
+	if point.Properties.Forecast == "" {
+		return nil, fmt.Errorf("no forecast for %.4f,%.4f", at.Latitude, at.Longitude)
+	}
+	var forecast struct {
+		Properties struct {
+			Periods []noaaPeriod `json:"periods"`
+		} `json:"properties"`
+	}
+	if err := w.get(ctx, point.Properties.Forecast, &forecast); err != nil {
+		return nil, err
+	}
+	return nightForecasts(forecast.Properties.Periods, core.CivilDateOf(arrival), core.CivilDateOf(departure)), nil
+}
+
+// nightForecasts pairs each night's day and night periods, by the local
+// date they start on.
+func nightForecasts(periods []noaaPeriod, arrival core.CivilDate, departure core.CivilDate) []NightForecast {
+	byDate := map[core.CivilDate]*NightForecast{}
+	for _, p := range periods {
+		date := core.CivilDateOf(p.StartTime)
+		f, ok := byDate[date]
+		if !ok {
+			f = &NightForecast{Date: date, Unit: p.TemperatureUnit}
+			byDate[date] = f
+		}
+		if p.IsDaytime {
+			f.High, f.Summary = p.Temperature, p.ShortForecast
+		} else {
+			f.Low = p.Temperature
+			if f.Summary == "" {
+				f.Summary = p.ShortForecast
+			}
+		}
+		if chance := p.ProbabilityOfPrecipitation.Value; chance != nil && (f.PrecipChance == nil || *chance > *f.PrecipChance) {
+			f.PrecipChance = chance
+		}
+	}
+	nights := []NightForecast{}
+	for _, night := range core.Nights(arrival, departure) {
+		if f, ok := byDate[night]; ok {
+			nights = append(nights, *f)
+		}
+	}
+	return nights
+}
+
+// attachForecast adds the stay's forecast to an alert with sites for a
+// single-campground IncludeWeather watch. Any failure is logged and the
+// alert goes out without weather, as it does when the run is too close to
+// its deadline.
+func (s *Scraper) attachForecast(ctx context.Context, m MessageContent, a *alert) {
+	if !m.IncludeWeather || m.isPermit() || m.isTour() || len(m.Campgrounds) > 0 || len(a.Sites) == 0 {
+		return
+	}
+	if !enrichBudget(ctx, m.Name, "weather", a) {
+		return
+	}
+	ctx, cancel := context.WithTimeout(ctx, weatherTimeout)
+	defer cancel()
+	facility, err := s.facility(ctx, m.Campground)
+	if err == nil && facility.Location.IsZero() {
+		err = fmt.Errorf("campground %s has no location", m.Campground)
+	}
+	if err != nil {
+		logger.Printf("job %s: sending without weather: %v", m.Name, err)
+		return
+	}
+	forecast, err := weatherService.Forecast(ctx, facility.Location, a.Arrival.Time(), a.Departure.Time())
+	if err != nil {
+		logger.Printf("job %s: sending without weather: %v", m.Name, err)
+		return
+	}
+	a.Forecast = forecast
+}
+
+// forecastLines renders each night's forecast, or nothing when there is
+// none.
+func forecastLines(forecast []NightForecast) []string {
+	lines := []string{}
+	for _, f := range forecast {
+		lines = append(lines, f.String())
+	}
+	return lines
+}
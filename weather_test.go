@@ -0,0 +1,139 @@
+package scraper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/sgrasu/camp_finder/scraper/core"
+)
+
+// weatherStub serves recreation.gov's facility endpoint and NOAA's points
+// and forecast endpoints from one server. The forecast runs from the
+// evening of Jul 13 to the day of Jul 15, so a stay to Jul 17 outlasts it.
+type weatherStub struct {
+	*httptest.Server
+	// forecastStatus, when set, fails the forecast request.
+	forecastStatus int
+
+	mu       sync.Mutex
+	requests []string
+}
+
+const stubForecast = `{"properties": {"periods": [
+	{"startTime": "2027-07-13T18:00:00-07:00", "isDaytime": false, "temperature": 50, "temperatureUnit": "F",
+	 "shortForecast": "Clear", "probabilityOfPrecipitation": {"value": null}},
+	{"startTime": "2027-07-14T06:00:00-07:00", "isDaytime": true, "temperature": 72, "temperatureUnit": "F",
+	 "shortForecast": "Mostly Sunny", "probabilityOfPrecipitation": {"value": 10}},
+	{"startTime": "2027-07-14T18:00:00-07:00", "isDaytime": false, "temperature": 48, "temperatureUnit": "F",
+	 "shortForecast": "Slight Chance Showers", "probabilityOfPrecipitation": {"value": 20}},
+	{"startTime": "2027-07-15T06:00:00-07:00", "isDaytime": true, "temperature": 75, "temperatureUnit": "F",
+	 "shortForecast": "Sunny", "probabilityOfPrecipitation": {"value": null}}
+]}}`
+
+func newWeatherStub(t *testing.T) *weatherStub {
+	stub := &weatherStub{}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stub.mu.Lock()
+		stub.requests = append(stub.requests, r.URL.Path)
+		stub.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/camps/campgrounds/"):
+			if strings.HasSuffix(r.URL.Path, "/000000") {
+				fmt.Fprint(w, `{"campground": {"facility_name": "Nowhere"}}`)
+				return
+			}
+			fmt.Fprint(w, `{"campground": {"facility_name": "Upper Pines", "facility_latitude": 37.7357, "facility_longitude": -119.5627}}`)
+		case strings.HasPrefix(r.URL.Path, "/points/"):
+			if r.Header.Get("User-Agent") == "" {
+				http.Error(w, "identify yourself", http.StatusForbidden)
+				return
+			}
+			fmt.Fprintf(w, `{"properties": {"forecast": "%s/gridpoints/HNX/1,2/forecast"}}`, stub.URL)
+		case r.URL.Path == "/gridpoints/HNX/1,2/forecast":
+			if stub.forecastStatus != 0 {
+				http.Error(w, http.StatusText(stub.forecastStatus), stub.forecastStatus)
+				return
+			}
+			fmt.Fprint(w, stubForecast)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(stub.Close)
+	old := weatherService
+	weatherService = NOAAWeather{BaseURL: stub.URL}
+	t.Cleanup(func() { weatherService = old })
+	return stub
+}
+
+func (s *weatherStub) paths() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+// A forecast ending partway through the stay covers the nights it reaches:
+// the last one has the day's high but no low.
+func TestNOAAForecast(t *testing.T) {
+	stub := newWeatherStub(t)
+	forecast, err := weatherService.Forecast(context.Background(), core.Location{Latitude: 37.7357, Longitude: -119.5627},
+		time.Date(2027, 7, 14, 0, 0, 0, 0, time.UTC), time.Date(2027, 7, 17, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("Forecast: %v", err)
+	}
+	want := []string{"Wed Jul 14: 72°F / 48°F, 20% precip, Mostly Sunny", "Thu Jul 15: 75°F, Sunny"}
+	if got := forecastLines(forecast); !reflect.DeepEqual(got, want) {
+		t.Errorf("forecast %q, want %q", got, want)
+	}
+	if forecast[1].Low != nil {
+		t.Errorf("Jul 15 low %d, want none", *forecast[1].Low)
+	}
+	if want := []string{"/points/37.7357,-119.5627", "/gridpoints/HNX/1,2/forecast"}; !reflect.DeepEqual(stub.paths(), want) {
+		t.Errorf("requests %v, want %v", stub.paths(), want)
+	}
+}
+
+// attachForecast looks the campground's location up on recreation.gov and
+// attaches its forecast, and on any failure leaves the alert without one.
+func TestAttachForecast(t *testing.T) {
+	tests := []struct {
+		name           string
+		campground     string
+		includeWeather bool
+		forecastStatus int
+		want           []string
+		wantRequests   int
+	}{
+		{name: "forecast", campground: "288001", includeWeather: true,
+			want: []string{"Wed Jul 14: 72°F / 48°F, 20% precip, Mostly Sunny", "Thu Jul 15: 75°F, Sunny"}, wantRequests: 3},
+		{name: "not asked for", campground: "288002", want: []string{}},
+		{name: "forecast failing", campground: "288003", includeWeather: true, forecastStatus: http.StatusServiceUnavailable,
+			want: []string{}, wantRequests: 3},
+		{name: "no location", campground: "000000", includeWeather: true, want: []string{}, wantRequests: 1},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stub := newWeatherStub(t)
+			stub.forecastStatus = test.forecastStatus
+			s := &Scraper{BaseURL: stub.URL, Client: stub.Client()}
+			m := MessageContent{Name: "camp-" + test.campground, Campground: test.campground, IncludeWeather: test.includeWeather}
+			a := alert{Sites: []string{"1001"}, Arrival: core.CivilDate{Year: 2027, Month: 7, Day: 14}, Departure: core.CivilDate{Year: 2027, Month: 7, Day: 17}}
+
+			s.attachForecast(context.Background(), m, &a)
+			if got := forecastLines(a.Forecast); !reflect.DeepEqual(got, test.want) {
+				t.Errorf("forecast %q, want %q", got, test.want)
+			}
+			if got := len(stub.paths()); got != test.wantRequests {
+				t.Errorf("made %d requests %v, want %d", got, stub.paths(), test.wantRequests)
+			}
+		})
+	}
+}
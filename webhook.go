@@ -0,0 +1,196 @@
+package scraper
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+// webhookSignatureHeader carries "sha256=" and the hex HMAC-SHA256 of the
+// request body keyed with WEBHOOK_SECRET.
+const webhookSignatureHeader = "X-Campfinder-Signature"
+
+// webhookAttempts and webhookBackoff bound retries of a webhook delivery.
+const (
+	webhookAttempts = 3
+	webhookBackoff  = time.Second
+)
+
+// WebhookSite is one available campsite in a WebhookPayload.
+type WebhookSite struct {
+	ID string `json:"id"`
+	// Name is recreation.gov's site name, such as A012, where known.
+	Name string `json:"name,omitempty"`
+	Type string `json:"type,omitempty"`
+	// OpenFrom and OpenUntil are the first night and the checkout date of
+	// a partial match, which is open for only part of the stay.
+	OpenFrom  string `json:"open_from,omitempty"`
+	OpenUntil string `json:"open_until,omitempty"`
+	// UnknownCapacity is set when the watch has a MinCapacity but the
+	// site's capacity could not be read.
+	UnknownCapacity bool `json:"unknown_capacity,omitempty"`
+	// UnknownAttributes is the same for RequiredAttributes, and is set on
+	// any watch for a site recreation.gov has no details for.
+	UnknownAttributes bool `json:"unknown_attributes,omitempty"`
+	// TotalPrice and MaxNightlyPrice are the cost of the stay and of its
+	// dearest night, in dollars, when the site's fees are known.
+	// PriceUnknown is set when the watch priced its sites but not this one.
+	TotalPrice      float64 `json:"total_price,omitempty"`
+	MaxNightlyPrice float64 `json:"max_nightly_price,omitempty"`
+	PriceUnknown    bool    `json:"price_unknown,omitempty"`
+}
+
+// WebhookPayload is the JSON body POSTed to a watch's WebhookURL.
+type WebhookPayload struct {
+	Job          string `json:"job"`
+	CampgroundID string `json:"campground_id"`
+	// CampgroundName is empty when recreation.gov did not give one.
+	CampgroundName string `json:"campground_name,omitempty"`
+	Arrival        string `json:"arrival"`
+	Departure      string `json:"departure"`
+	// Stay is the stay as the watch's Locale writes it, such as
+	// "2 nights, Fri Jul 14 → Sun Jul 16".
+	Stay      string        `json:"stay"`
+	Sites     []WebhookSite `json:"sites"`
+	ScannedAt time.Time     `json:"scanned_at"`
+	Rehearsal bool          `json:"rehearsal,omitempty"`
+}
+
+// webhookNotifier POSTs alerts to a URL of the watch owner's choosing.
+type webhookNotifier struct {
+	url    string
+	secret string
+	// backoff is the wait before the second attempt, doubling after.
+	backoff time.Duration
+}
+
+func (n webhookNotifier) Channel() string { return "webhook" }
+
+// Recipient implements notifier. URLs can embed tokens, so only the host
+// is archived.
+func (n webhookNotifier) Recipient() (string, string) {
+	return n.url, n.host()
+}
+
+// host is the URL's host, which is all of it that errors, logs and the
+// archive may show.
+func (n webhookNotifier) host() string {
+	if u, err := url.Parse(n.url); err == nil && u.Host != "" {
+		return u.Host
+	}
+	return "(unparseable URL)"
+}
+
+func (n webhookNotifier) Notify(ctx context.Context, a alert, r renderedAlert) error {
+	body, err := json.Marshal(webhookPayload(a))
+	if err != nil {
+		return err
+	}
+	signature := "sha256=" + webhookSignature(n.secret, body)
+
+	backoff := n.backoff
+	for attempt := 1; ; attempt++ {
+		retry, err := n.post(ctx, body, signature)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt == webhookAttempts {
+			return fmt.Errorf("webhook %s: giving up after %d attempts: %v", n.host(), attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("webhook %s: %v", n.host(), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// post makes one delivery attempt, reporting whether a failure is worth
+// retrying: network errors, 429s and 5xx responses are. The errors net/http
+// returns quote the full URL, so only their cause is kept.
+func (n webhookNotifier) post(ctx context.Context, body []byte, signature string) (bool, error) {
+	request, err := http.NewRequest(http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return false, withoutURL(err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(webhookSignatureHeader, signature)
+	response, err := http.DefaultClient.Do(request.WithContext(ctx))
+	if err != nil {
+		return ctx.Err() == nil, withoutURL(err)
+	}
+	response.Body.Close()
+	if response.StatusCode >= 200 && response.StatusCode <= 299 {
+		return false, nil
+	}
+	retry := response.StatusCode == http.StatusTooManyRequests || response.StatusCode >= 500
+	return retry, fmt.Errorf("returned %d %s", response.StatusCode, http.StatusText(response.StatusCode))
+}
+
+// withoutURL strips the URL from a *url.Error, leaving the operation and its
+// cause.
+func withoutURL(err error) error {
+	if u, ok := err.(*url.Error); ok {
+		return fmt.Errorf("%s: %v", u.Op, u.Err)
+	}
+	return err
+}
+
+// webhookPayload describes a for its webhook.
+func webhookPayload(a alert) WebhookPayload {
+	return WebhookPayload{
+		Job:            a.JobName,
+		CampgroundID:   a.CampgroundID,
+		CampgroundName: a.CampgroundName,
+		Arrival:        a.Arrival.String(),
+		Departure:      a.Departure.String(),
+		Stay:           a.stay(),
+		Sites:          webhookSites(a),
+		ScannedAt:      a.ScannedAt,
+		Rehearsal:      a.Rehearsal,
+	}
+}
+
+// webhookSites describes the alert's sites for a webhook payload.
+func webhookSites(a alert) []WebhookSite {
+	sites := []WebhookSite{}
+	for _, site := range a.Sites {
+		entry := WebhookSite{ID: site, Name: a.SiteNames[site], Type: a.SiteTypes[site], UnknownCapacity: a.UnknownCapacity[site],
+			UnknownAttributes: a.UnknownAttributes[site]}
+		if run, ok := a.OpenRanges[site]; ok && partialStay(a, site) != "" {
+			entry.OpenFrom, entry.OpenUntil = run.Start.String(), run.End.String()
+		}
+		if price, ok := a.Prices[site]; ok {
+			entry.TotalPrice, entry.MaxNightlyPrice = float64(price.Total)/100, float64(price.MaxNightly)/100
+		} else {
+			entry.PriceUnknown = a.Prices != nil
+		}
+		sites = append(sites, entry)
+	}
+	return sites
+}
+
+// webhookSignature is the hex HMAC-SHA256 of body. Receivers recompute it
+// with the shared secret and compare it to the signature header.
+func webhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookFromEnv returns a notifier for target signed with WEBHOOK_SECRET, if
+// that is set; unsigned deliveries could be forged by anyone who learns the
+// URL, so none are made.
+func webhookFromEnv(target string) (webhookNotifier, bool) {
+	secret := os.Getenv("WEBHOOK_SECRET")
+	return webhookNotifier{url: target, secret: secret, backoff: webhookBackoff}, secret != ""
+}
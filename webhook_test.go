@@ -0,0 +1,153 @@
+package scraper
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// webhookReceiver answers each delivery with the next of statuses, then
+// 200s, and records what it was sent.
+type webhookReceiver struct {
+	statuses   []int
+	bodies     [][]byte
+	signatures []string
+}
+
+func (rc *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	rc.bodies = append(rc.bodies, body)
+	rc.signatures = append(rc.signatures, r.Header.Get("X-Campfinder-Signature"))
+	status := http.StatusOK
+	if n := len(rc.bodies); n <= len(rc.statuses) {
+		status = rc.statuses[n-1]
+	}
+	w.WriteHeader(status)
+}
+
+// The signature header is the HMAC-SHA256 of the exact body delivered,
+// keyed with WEBHOOK_SECRET, which the receiver can check.
+func TestWebhookSignature(t *testing.T) {
+	t.Setenv("WEBHOOK_SECRET", "s3cret-webhook-key")
+	receiver := &webhookReceiver{}
+	server := httptest.NewServer(receiver)
+	defer server.Close()
+	n, ok := webhookFromEnv(server.URL + "/hook?token=abc")
+	if !ok {
+		t.Fatal("webhookFromEnv made no notifier with WEBHOOK_SECRET set")
+	}
+
+	if err := n.Notify(context.Background(), goldenAlert(), renderedAlert{}); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if len(receiver.bodies) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(receiver.bodies))
+	}
+	mac := hmac.New(sha256.New, []byte("s3cret-webhook-key"))
+	mac.Write(receiver.bodies[0])
+	if want := "sha256=" + hex.EncodeToString(mac.Sum(nil)); receiver.signatures[0] != want {
+		t.Errorf("signature %q, want %q", receiver.signatures[0], want)
+	}
+	var payload WebhookPayload
+	if err := json.Unmarshal(receiver.bodies[0], &payload); err != nil {
+		t.Fatal(err)
+	}
+	if payload.CampgroundID != "232447" || len(payload.Sites) != 2 || payload.Sites[0].Name != "A001" {
+		t.Errorf("delivered %+v, want the alert's two Upper Pines sites", payload)
+	}
+
+	t.Setenv("WEBHOOK_SECRET", "")
+	if _, ok := webhookFromEnv(server.URL); ok {
+		t.Error("webhookFromEnv made a notifier without WEBHOOK_SECRET")
+	}
+}
+
+// A 503 or 429 is retried with the same signed body; a 400 is not, and
+// steady 503s give up after webhookAttempts.
+func TestWebhookRetry(t *testing.T) {
+	tests := []struct {
+		name         string
+		statuses     []int
+		wantAttempts int
+		wantErr      string
+	}{
+		{name: "503 then 200", statuses: []int{503}, wantAttempts: 2},
+		{name: "429 then 200", statuses: []int{429}, wantAttempts: 2},
+		{name: "400", statuses: []int{400}, wantAttempts: 1, wantErr: "giving up after 1 attempts: returned 400 Bad Request"},
+		{name: "503 throughout", statuses: []int{503, 503, 503}, wantAttempts: 3, wantErr: "giving up after 3 attempts: returned 503 Service Unavailable"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			receiver := &webhookReceiver{statuses: test.statuses}
+			server := httptest.NewServer(receiver)
+			defer server.Close()
+			n := webhookNotifier{url: server.URL, secret: "s3cret-webhook-key", backoff: time.Millisecond}
+
+			err := n.Notify(context.Background(), goldenAlert(), renderedAlert{})
+			switch {
+			case test.wantErr == "" && err != nil:
+				t.Fatalf("Notify: %v", err)
+			case test.wantErr != "" && (err == nil || !strings.Contains(err.Error(), test.wantErr)):
+				t.Fatalf("Notify error = %v, want %q", err, test.wantErr)
+			}
+			if len(receiver.bodies) != test.wantAttempts {
+				t.Fatalf("got %d deliveries, want %d", len(receiver.bodies), test.wantAttempts)
+			}
+			for i := 1; i < len(receiver.bodies); i++ {
+				if string(receiver.bodies[i]) != string(receiver.bodies[0]) || receiver.signatures[i] != receiver.signatures[0] {
+					t.Errorf("attempt %d sent a different body or signature", i+1)
+				}
+			}
+		})
+	}
+}
+
+// Webhook URLs can carry secret tokens, so a failed delivery's error, which
+// is logged and archived, names only the host, whether the receiver refused
+// it or could not be reached.
+func TestWebhookFailureArchivesHostOnly(t *testing.T) {
+	useFakeGCS(t, "results")
+	t.Setenv("ARCHIVE_BUCKET", "results")
+	refusing := httptest.NewServer(&webhookReceiver{statuses: []int{400}})
+	defer refusing.Close()
+	gone := httptest.NewServer(http.NotFoundHandler())
+	gone.Close()
+	ctx := context.Background()
+
+	for i, base := range []string{refusing.URL, gone.URL} {
+		target := base + "/hooks/T0KEN-PATH?token=s3cret-query"
+		old := notifiersFor
+		notifiersFor = func(a alert) []notifier {
+			return []notifier{webhookNotifier{url: target, secret: "s3cret-webhook-key", backoff: time.Millisecond}}
+		}
+		a := goldenAlert()
+		a.JobName = fmt.Sprintf("webhook-failure-%d", i)
+		err := sendAlert(ctx, a)
+		notifiersFor = old
+		if err == nil {
+			t.Fatalf("%s: sendAlert succeeded, want the webhook failure", base)
+		}
+		records, rerr := GetNotificationArchive(ctx, a.JobName, time.Time{})
+		if rerr != nil || len(records) != 1 {
+			t.Fatalf("%s: archived %+v, %v, want one record", base, records, rerr)
+		}
+		host := strings.TrimPrefix(base, "http://")
+		for _, got := range []string{err.Error(), records[0].Outcome, records[0].Recipient} {
+			if strings.Contains(got, "T0KEN-PATH") || strings.Contains(got, "s3cret-query") || !strings.Contains(got, host) {
+				t.Errorf("%s: %q should name the host and nothing else of the URL", base, got)
+			}
+		}
+		if !strings.HasPrefix(records[0].Outcome, "failed: ") {
+			t.Errorf("%s: outcome %q, want a failure", base, records[0].Outcome)
+		}
+	}
+}